@@ -0,0 +1,226 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/guacsec/guac/pkg/config"
+	"github.com/guacsec/guac/pkg/handler/collector"
+	"github.com/guacsec/guac/pkg/handler/collector/artifactrepo"
+	"github.com/guacsec/guac/pkg/handler/processor"
+	"github.com/guacsec/guac/pkg/handler/processor/process"
+	"github.com/guacsec/guac/pkg/logging"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+type artifactRepoOptions struct {
+	// repoType is the repository manager, artifactory or nexus
+	repoType string
+	// baseURL of the repository manager
+	baseURL string
+	// repoPath is the repository to collect, optionally followed by a folder
+	repoPath string
+	// patterns of the names of the sidecar files to collect
+	patterns []string
+	// properties holding documents, artifactory only
+	properties []string
+	// interval to poll the repository at, 0 to collect once
+	pollInterval time.Duration
+}
+
+var artifactRepoCmd = &cobra.Command{
+	Use:   "artifact-repo [flags] base-url repo[/path]",
+	Short: "takes the SBOMs and attestations stored in an Artifactory or Nexus repository to add to GUAC graph",
+	Long: `takes the SBOMs and attestations stored in an Artifactory or Nexus repository to add to GUAC graph.
+
+The files under the repository path whose name matches one of the
+--repo-file-pattern globs are collected, by default the usual SBOM and
+attestation file names such as *.spdx.json and *.intoto.jsonl. With Artifactory,
+the documents held by the --repo-property properties of the files are
+collected too. Set ARTIFACTORY_TOKEN, or ARTIFACTORY_USER and
+ARTIFACTORY_PASSWORD, to authenticate to Artifactory, and NEXUS_USER and
+NEXUS_PASSWORD to authenticate to Nexus. With --repo-poll-interval the
+repository is polled and only new or changed files are ingested.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := logging.WithLogger(context.Background())
+		logger := logging.FromContext(ctx)
+
+		opts, err := validateArtifactRepoFlags(guacConfig.ArtifactRepo, args)
+		if err != nil {
+			fmt.Printf("unable to validate flags: %v\n", err)
+			_ = cmd.Help()
+			os.Exit(1)
+		}
+
+		// Register collector
+		collectorOpts := []artifactrepo.Option{}
+		if len(opts.properties) > 0 {
+			collectorOpts = append(collectorOpts, artifactrepo.WithProperties(opts.properties...))
+		}
+		var repoCollector collector.Collector
+		var collectorType string
+		switch opts.repoType {
+		case "artifactory":
+			repoCollector, err = artifactrepo.NewArtifactoryCollector(ctx, opts.baseURL, opts.repoPath, opts.patterns, opts.pollInterval, collectorOpts...)
+			collectorType = artifactrepo.CollectorArtifactory
+		case "nexus":
+			repoCollector, err = artifactrepo.NewNexusCollector(ctx, opts.baseURL, opts.repoPath, opts.patterns, opts.pollInterval, collectorOpts...)
+			collectorType = artifactrepo.CollectorNexus
+		}
+		if err != nil {
+			logger.Errorf("error: %v", err)
+			os.Exit(1)
+		}
+		err = collector.RegisterDocumentCollector(repoCollector, collectorType)
+		if err != nil {
+			logger.Errorf("unable to register %s collector: %v", opts.repoType, err)
+		}
+
+		// Get pipeline of components
+		processorFunc, err := getProcessor(ctx)
+		if err != nil {
+			logger.Errorf("error: %v", err)
+			os.Exit(1)
+		}
+		ingestorFunc, err := getIngestor(ctx)
+		if err != nil {
+			logger.Errorf("error: %v", err)
+			os.Exit(1)
+		}
+		assemblerFunc, err := getAssembler(ctx, guacConfig.GraphDB)
+		if err != nil {
+			logger.Errorf("error: %v", err)
+			os.Exit(1)
+		}
+
+		totalNum := 0
+		gotErr := false
+		// Set emit function to go through the entire pipeline
+		emit := func(d *processor.Document) error {
+			totalNum += 1
+			start := time.Now()
+
+			docTree, err := processorFunc(d)
+			if errors.Is(err, process.ErrUnsupportedDocument) {
+				// counted and reported in the summary
+				return nil
+			}
+			if err != nil {
+				gotErr = true
+				return fmt.Errorf("unable to process doc: %v, format: %v, document: %v", err, d.Format, d.Type)
+			}
+
+			graphs, err := ingestorFunc(docTree)
+			if err != nil {
+				gotErr = true
+				if len(graphs) == 0 {
+					return fmt.Errorf("unable to ingest doc tree: %v", err)
+				}
+				logger.Errorf("partially ingesting doc tree: %v", err)
+			}
+
+			err = assemblerFunc(graphs)
+			if err != nil {
+				gotErr = true
+				return fmt.Errorf("unable to assemble graphs: %v", err)
+			}
+			t := time.Now()
+			elapsed := t.Sub(start)
+			logger.Infof("[%v] completed doc %+v", elapsed, d.SourceInformation)
+			return nil
+		}
+
+		// Collect
+		errHandler := func(err error) bool {
+			if err == nil {
+				logger.Info("collector ended gracefully")
+				return true
+			}
+			if errors.Is(err, collector.ErrCollectorTimeout) {
+				logger.Warnf("collector stopped: %v", err)
+				return true
+			}
+			if errors.Is(err, collector.ErrDocumentRead) {
+				gotErr = true
+				logger.Warnf("skipped document: %v", err)
+				return true
+			}
+			logger.Errorf("collector ended with error: %v", err)
+			return false
+		}
+		if err := collector.CollectWithTimeout(ctx, emit, errHandler, guacConfig.Collector.Timeout); err != nil {
+			logger.Fatal(err)
+		}
+
+		process.LogUnsupportedDocuments(ctx)
+		if gotErr {
+			logger.Fatalf("completed ingestion with errors")
+		} else {
+			logger.Infof("completed ingesting %v documents", totalNum)
+		}
+	},
+}
+
+func validateArtifactRepoFlags(c config.ArtifactRepo, args []string) (artifactRepoOptions, error) {
+	var opts artifactRepoOptions
+	opts.patterns = c.FilePatterns
+
+	switch c.Type {
+	case "artifactory", "nexus":
+		opts.repoType = c.Type
+	default:
+		return opts, fmt.Errorf("repo-type must be artifactory or nexus, got %q", c.Type)
+	}
+	if len(c.Properties) > 0 && c.Type != "artifactory" {
+		return opts, fmt.Errorf("repo-property is only supported by artifactory")
+	}
+	opts.properties = c.Properties
+
+	if c.PollInterval < 0 {
+		return opts, fmt.Errorf("repo-poll-interval must not be negative")
+	}
+	opts.pollInterval = c.PollInterval
+
+	if len(args) != 2 {
+		return opts, fmt.Errorf("expected positional arguments for the base url and the repository")
+	}
+	opts.baseURL = args[0]
+	opts.repoPath = args[1]
+
+	return opts, nil
+}
+
+func init() {
+	artifactRepoFlags := artifactRepoCmd.Flags()
+	artifactRepoFlags.String("repo-type", "artifactory", "repository manager to collect from, artifactory or nexus")
+	artifactRepoFlags.StringSlice("repo-file-pattern", nil, "glob of the names of the files to collect, can be repeated, defaults to common SBOM and attestation names")
+	artifactRepoFlags.StringSlice("repo-property", nil, "artifactory property holding a document or its url, can be repeated")
+	artifactRepoFlags.Duration("repo-poll-interval", 0, "interval to poll the repository for new or changed files, 0 to collect once")
+	for _, name := range []string{"repo-type", "repo-file-pattern", "repo-property", "repo-poll-interval"} {
+		if err := viper.BindPFlag(name, artifactRepoFlags.Lookup(name)); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to bind flag: %v", err)
+			os.Exit(1)
+		}
+	}
+	rootCmd.AddCommand(artifactRepoCmd)
+}
@@ -0,0 +1,191 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/guacsec/guac/pkg/config"
+	"github.com/guacsec/guac/pkg/handler/collector"
+	"github.com/guacsec/guac/pkg/handler/collector/azblob"
+	"github.com/guacsec/guac/pkg/handler/processor"
+	"github.com/guacsec/guac/pkg/handler/processor/process"
+	"github.com/guacsec/guac/pkg/logging"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+type azureBlobOptions struct {
+	// container to collect documents from, by URL or name
+	container string
+	// prefix of the blobs to collect
+	prefix string
+	// interval to poll the container at, 0 to collect once
+	pollInterval time.Duration
+}
+
+var azureBlobCmd = &cobra.Command{
+	Use:   "azblob [flags] container",
+	Short: "takes the documents stored in an Azure Blob Storage container to add to GUAC graph",
+	Long: `takes the documents stored in an Azure Blob Storage container to add to GUAC graph.
+
+The container is given by URL, e.g. https://account.blob.core.windows.net/sboms,
+or by name in the storage account set by AZURE_STORAGE_ACCOUNT. A container URL
+with a SAS token is read with it, otherwise the service principal set by
+AZURE_TENANT_ID, AZURE_CLIENT_ID and AZURE_CLIENT_SECRET or else the managed
+identity authenticates. With --azblob-poll-interval the container is polled and
+only new or updated blobs (by etag) are ingested, skipping content that was
+already ingested.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := logging.WithLogger(context.Background())
+		logger := logging.FromContext(ctx)
+
+		opts, err := validateAzureBlobFlags(guacConfig.AzureBlob, args)
+		if err != nil {
+			fmt.Printf("unable to validate flags: %v\n", err)
+			_ = cmd.Help()
+			os.Exit(1)
+		}
+
+		// Register collector
+		azureBlobCollector, err := azblob.NewAzureBlobCollector(ctx, opts.container, opts.prefix, opts.pollInterval)
+		if err != nil {
+			logger.Errorf("error: %v", err)
+			os.Exit(1)
+		}
+		err = collector.RegisterDocumentCollector(azureBlobCollector, azblob.CollectorAzureBlob)
+		if err != nil {
+			logger.Errorf("unable to register azure blob collector: %v", err)
+		}
+
+		// Get pipeline of components
+		processorFunc, err := getProcessor(ctx)
+		if err != nil {
+			logger.Errorf("error: %v", err)
+			os.Exit(1)
+		}
+		ingestorFunc, err := getIngestor(ctx)
+		if err != nil {
+			logger.Errorf("error: %v", err)
+			os.Exit(1)
+		}
+		assemblerFunc, err := getAssembler(ctx, guacConfig.GraphDB)
+		if err != nil {
+			logger.Errorf("error: %v", err)
+			os.Exit(1)
+		}
+
+		totalNum := 0
+		gotErr := false
+		// Set emit function to go through the entire pipeline
+		emit := func(d *processor.Document) error {
+			totalNum += 1
+			start := time.Now()
+
+			docTree, err := processorFunc(d)
+			if errors.Is(err, process.ErrUnsupportedDocument) {
+				// counted and reported in the summary
+				return nil
+			}
+			if err != nil {
+				gotErr = true
+				return fmt.Errorf("unable to process doc: %v, format: %v, document: %v", err, d.Format, d.Type)
+			}
+
+			graphs, err := ingestorFunc(docTree)
+			if err != nil {
+				gotErr = true
+				if len(graphs) == 0 {
+					return fmt.Errorf("unable to ingest doc tree: %v", err)
+				}
+				logger.Errorf("partially ingesting doc tree: %v", err)
+			}
+
+			err = assemblerFunc(graphs)
+			if err != nil {
+				gotErr = true
+				return fmt.Errorf("unable to assemble graphs: %v", err)
+			}
+			t := time.Now()
+			elapsed := t.Sub(start)
+			logger.Infof("[%v] completed doc %+v", elapsed, d.SourceInformation)
+			return nil
+		}
+
+		// Collect
+		errHandler := func(err error) bool {
+			if err == nil {
+				logger.Info("collector ended gracefully")
+				return true
+			}
+			if errors.Is(err, collector.ErrCollectorTimeout) {
+				logger.Warnf("collector stopped: %v", err)
+				return true
+			}
+			if errors.Is(err, collector.ErrDocumentRead) {
+				gotErr = true
+				logger.Warnf("skipped document: %v", err)
+				return true
+			}
+			logger.Errorf("collector ended with error: %v", err)
+			return false
+		}
+		if err := collector.CollectWithTimeout(ctx, emit, errHandler, guacConfig.Collector.Timeout); err != nil {
+			logger.Fatal(err)
+		}
+
+		process.LogUnsupportedDocuments(ctx)
+		if gotErr {
+			logger.Fatalf("completed ingestion with errors")
+		} else {
+			logger.Infof("completed ingesting %v documents", totalNum)
+		}
+	},
+}
+
+func validateAzureBlobFlags(c config.AzureBlob, args []string) (azureBlobOptions, error) {
+	var opts azureBlobOptions
+	opts.prefix = c.Prefix
+
+	if c.PollInterval < 0 {
+		return opts, fmt.Errorf("azblob-poll-interval must not be negative")
+	}
+	opts.pollInterval = c.PollInterval
+
+	if len(args) != 1 {
+		return opts, fmt.Errorf("expected positional argument for container")
+	}
+	opts.container = args[0]
+
+	return opts, nil
+}
+
+func init() {
+	azureBlobFlags := azureBlobCmd.Flags()
+	azureBlobFlags.String("azblob-prefix", "", "only collect the blobs whose name starts with this prefix")
+	azureBlobFlags.Duration("azblob-poll-interval", 0, "interval to poll the container for new or updated blobs, 0 to collect once")
+	for _, name := range []string{"azblob-prefix", "azblob-poll-interval"} {
+		if err := viper.BindPFlag(name, azureBlobFlags.Lookup(name)); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to bind flag: %v", err)
+			os.Exit(1)
+		}
+	}
+	rootCmd.AddCommand(azureBlobCmd)
+}
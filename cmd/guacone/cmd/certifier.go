@@ -19,12 +19,17 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/guacsec/guac/pkg/assembler/graphdb"
 	"github.com/guacsec/guac/pkg/certifier"
 	"github.com/guacsec/guac/pkg/certifier/certify"
 	root_package "github.com/guacsec/guac/pkg/certifier/components"
+	"github.com/guacsec/guac/pkg/certifier/depsdev"
+	"github.com/guacsec/guac/pkg/certifier/osv"
+	"github.com/guacsec/guac/pkg/certifier/scorecard"
 	"github.com/guacsec/guac/pkg/handler/processor"
 	"github.com/guacsec/guac/pkg/logging"
 	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
@@ -39,21 +44,34 @@ var certifierCmd = &cobra.Command{
 		ctx := logging.WithLogger(context.Background())
 		logger := logging.FromContext(ctx)
 
-		opts, err := validateCertifierFlags(
-			viper.GetString("gdbuser"),
-			viper.GetString("gdbpass"),
-			viper.GetString("gdbaddr"),
-			viper.GetString("realm"),
-		)
+		if guacConfig.Certifier.DepsDev {
+			if err := certify.RegisterCertifier(depsdev.NewDepsDevCertifier, certifier.CertifierDepsDev); err != nil {
+				logger.Errorf("error: %v", err)
+				os.Exit(1)
+			}
+		}
+		if guacConfig.Certifier.OSVFeed {
+			if err := certify.RegisterCertifier(osv.NewOSVFeedCertifier, certifier.CertifierOSVFeed); err != nil {
+				logger.Errorf("error: %v", err)
+				os.Exit(1)
+			}
+		}
 
-		if err != nil {
-			fmt.Printf("unable to validate flags: %v\n", err)
-			_ = cmd.Help()
-			os.Exit(1)
+		if guacConfig.Certifier.Scorecard {
+			scorecardCertifier := scorecard.NewScorecardCertifier
+			if binary := guacConfig.Certifier.ScorecardBinary; binary != "" {
+				scorecardCertifier = func() certifier.Certifier {
+					return scorecard.NewScorecardRunnerCertifier(binary)
+				}
+			}
+			if err := certify.RegisterCertifier(scorecardCertifier, certifier.CertifierScorecard); err != nil {
+				logger.Errorf("error: %v", err)
+				os.Exit(1)
+			}
 		}
 
-		authToken := graphdb.CreateAuthTokenWithUsernameAndPassword(opts.user, opts.pass, opts.realm)
-		client, err := graphdb.NewGraphClient(opts.dbAddr, authToken)
+		authToken := graphdb.CreateAuthTokenWithUsernameAndPassword(guacConfig.GraphDB.User, guacConfig.GraphDB.Pass, guacConfig.GraphDB.Realm)
+		client, err := getGraphClient(guacConfig.GraphDB, authToken)
 		if err != nil {
 			logger.Errorf("error: %v", err)
 			os.Exit(1)
@@ -70,7 +88,7 @@ var certifierCmd = &cobra.Command{
 			logger.Errorf("error: %v", err)
 			os.Exit(1)
 		}
-		assemblerFunc, err := getAssembler(opts)
+		assemblerFunc, err := getAssembler(ctx, guacConfig.GraphDB)
 		if err != nil {
 			logger.Errorf("error: %v", err)
 			os.Exit(1)
@@ -92,7 +110,7 @@ var certifierCmd = &cobra.Command{
 			docTree, err := processorFunc(d)
 			if err != nil {
 				gotErr = true
-				return fmt.Errorf("unable to process doc: %v, fomat: %v, document: %v", err, d.Format, d.Type)
+				return fmt.Errorf("unable to process doc: %v, format: %v, document: %v", err, d.Format, d.Type)
 			}
 
 			graphs, err := ingestorFunc(docTree)
@@ -122,7 +140,13 @@ var certifierCmd = &cobra.Command{
 			return false
 		}
 
-		if err := certify.Certify(ctx, packageQueryFunc(), emit, errHandler); err != nil {
+		if interval := guacConfig.Certifier.Interval; interval > 0 {
+			ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			if err := certify.CertifyPeriodically(ctx, packageQueryFunc(), interval, emit, errHandler); err != nil {
+				logger.Fatal(err)
+			}
+		} else if err := certify.Certify(ctx, packageQueryFunc(), emit, errHandler); err != nil {
 			logger.Fatal(err)
 		}
 		if gotErr {
@@ -133,16 +157,6 @@ var certifierCmd = &cobra.Command{
 	},
 }
 
-func validateCertifierFlags(user string, pass string, dbAddr string, realm string) (options, error) {
-	var opts options
-	opts.user = user
-	opts.pass = pass
-	opts.dbAddr = dbAddr
-	opts.realm = realm
-
-	return opts, nil
-}
-
 func getPackageQuery(client neo4j.Driver) (func() certifier.QueryComponents, error) {
 	return func() certifier.QueryComponents {
 		packageQuery := root_package.NewPackageQuery(client)
@@ -151,5 +165,17 @@ func getPackageQuery(client neo4j.Driver) (func() certifier.QueryComponents, err
 }
 
 func init() {
+	certifierFlags := certifierCmd.Flags()
+	certifierFlags.Bool("depsdev", false, "also enrich the packages with the dependencies, licenses and scorecards of deps.dev")
+	certifierFlags.Bool("osv-feed", false, "also ingest the OSV entries of the vulnerabilities of the packages, with their aliases and affected versions")
+	certifierFlags.Bool("scorecard", false, "also ingest the OpenSSF Scorecard results of the source repositories of the packages")
+	certifierFlags.String("scorecard-binary", "", "path of a scorecard binary to score the repositories with, instead of querying the Scorecard API; requires GITHUB_AUTH_TOKEN")
+	certifierFlags.Duration("certifier-interval", 0, "interval to certify the packages of the graph again, 0 to certify once")
+	for _, name := range []string{"depsdev", "osv-feed", "scorecard", "scorecard-binary", "certifier-interval"} {
+		if err := viper.BindPFlag(name, certifierFlags.Lookup(name)); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to bind flag: %v", err)
+			os.Exit(1)
+		}
+	}
 	rootCmd.AddCommand(certifierCmd)
 }
@@ -25,9 +25,9 @@ import (
 	"github.com/guacsec/guac/pkg/collectsub/client"
 	"github.com/guacsec/guac/pkg/collectsub/collectsub"
 	"github.com/guacsec/guac/pkg/collectsub/collectsub/input"
+	"github.com/guacsec/guac/pkg/config"
 	"github.com/guacsec/guac/pkg/logging"
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
 )
 
 type csubClientOptions struct {
@@ -43,9 +43,7 @@ func setupCsubClient(cmd *cobra.Command, args []string) (context.Context, *clien
 	ctx := logging.WithLogger(context.Background())
 	logger := logging.FromContext(ctx)
 
-	opts, err := validateCsubClientFlags(
-		viper.GetString("csub-addr"),
-	)
+	opts, err := validateCsubClientFlags(guacConfig.CollectSub)
 
 	if err != nil {
 		fmt.Printf("unable to validate flags: %v\n", err)
@@ -61,9 +59,9 @@ func setupCsubClient(cmd *cobra.Command, args []string) (context.Context, *clien
 	return ctx, csubClient
 }
 
-func validateCsubClientFlags(addr string) (csubClientOptions, error) {
+func validateCsubClientFlags(c config.CollectSub) (csubClientOptions, error) {
 	return csubClientOptions{
-		addr: addr,
+		addr: c.Addr,
 	}, nil
 }
 
@@ -21,9 +21,9 @@ import (
 	"os"
 
 	"github.com/guacsec/guac/pkg/collectsub/server"
+	"github.com/guacsec/guac/pkg/config"
 	"github.com/guacsec/guac/pkg/logging"
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
 )
 
 type csubServerOptions struct {
@@ -37,9 +37,7 @@ var csubServerCmd = &cobra.Command{
 		ctx := logging.WithLogger(context.Background())
 		logger := logging.FromContext(ctx)
 
-		opts, err := validateCsubServerFlags(
-			viper.GetInt("csub-listen-port"),
-		)
+		opts, err := validateCsubServerFlags(guacConfig.CollectSub)
 
 		if err != nil {
 			fmt.Printf("unable to validate flags: %v\n", err)
@@ -59,9 +57,9 @@ var csubServerCmd = &cobra.Command{
 	},
 }
 
-func validateCsubServerFlags(port int) (csubServerOptions, error) {
+func validateCsubServerFlags(c config.CollectSub) (csubServerOptions, error) {
 	return csubServerOptions{
-		port: port,
+		port: c.ListenPort,
 	}, nil
 }
 
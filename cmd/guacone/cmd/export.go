@@ -0,0 +1,205 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/guacsec/guac/pkg/assembler"
+	"github.com/guacsec/guac/pkg/config"
+	"github.com/guacsec/guac/pkg/handler/collector"
+	"github.com/guacsec/guac/pkg/handler/collector/file"
+	"github.com/guacsec/guac/pkg/handler/processor"
+	"github.com/guacsec/guac/pkg/handler/processor/process"
+	"github.com/guacsec/guac/pkg/ingestor/verifier"
+	"github.com/guacsec/guac/pkg/ingestor/verifier/sigstore_verifier"
+	"github.com/guacsec/guac/pkg/logging"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+type exportOptions struct {
+	// path to folder with documents to collect
+	path string
+	// format of the exported graph
+	format assembler.ExportFormat
+	// file to write the graph to, stdout if empty
+	output string
+}
+
+var exportCmd = &cobra.Command{
+	Use:   "export [flags] file_path",
+	Short: "take a folder of files and export the resulting GUAC graph as GraphML, JSON or DOT",
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := logging.WithLogger(context.Background())
+		logger := logging.FromContext(ctx)
+
+		opts, err := validateExportFlags(guacConfig.Export, args)
+		if err != nil {
+			fmt.Printf("unable to validate flags: %v\n", err)
+			_ = cmd.Help()
+			os.Exit(1)
+		}
+
+		// Register Verifier
+		verifierOpts, err := sigstoreVerifierOptions()
+		if err != nil {
+			logger.Errorf("unable to load the verifier trust roots: %v", err)
+			os.Exit(1)
+		}
+		sigstoreAndKeyVerifier := sigstore_verifier.NewSigstoreAndKeyVerifier(verifierOpts...)
+		err = verifier.RegisterVerifier(sigstoreAndKeyVerifier, sigstoreAndKeyVerifier.Type())
+		if err != nil {
+			logger.Errorf("unable to register key provider: %v", err)
+		}
+
+		// Register collector
+		fileCollector := file.NewFileCollector(ctx, opts.path, false, time.Second)
+		err = collector.RegisterDocumentCollector(fileCollector, file.FileCollector)
+		if err != nil {
+			logger.Errorf("unable to register file collector: %v", err)
+		}
+
+		// Get pipeline of components
+		processorFunc, err := getProcessor(ctx)
+		if err != nil {
+			logger.Errorf("error: %v", err)
+			os.Exit(1)
+		}
+		ingestorFunc, err := getIngestor(ctx)
+		if err != nil {
+			logger.Errorf("error: %v", err)
+			os.Exit(1)
+		}
+
+		combined := assembler.Graph{
+			Nodes: []assembler.GuacNode{},
+			Edges: []assembler.GuacEdge{},
+		}
+		gotErr := false
+		// Set emit function to process and parse documents into a single graph
+		emit := func(d *processor.Document) error {
+			docTree, err := processorFunc(d)
+			if errors.Is(err, process.ErrUnsupportedDocument) {
+				// counted and reported in the summary
+				return nil
+			}
+			if err != nil {
+				gotErr = true
+				return fmt.Errorf("unable to process doc: %v, format: %v, document: %v", err, d.Format, d.Type)
+			}
+
+			graphs, err := ingestorFunc(docTree)
+			for _, g := range graphs {
+				combined.AppendGraph(g)
+			}
+			if err != nil {
+				gotErr = true
+				return fmt.Errorf("unable to ingest doc tree: %v", err)
+			}
+			return nil
+		}
+
+		// Collect
+		errHandler := func(err error) bool {
+			if err == nil {
+				logger.Info("collector ended gracefully")
+				return true
+			}
+			if errors.Is(err, collector.ErrCollectorTimeout) {
+				gotErr = true
+				logger.Warnf("collector stopped: %v", err)
+				return true
+			}
+			if errors.Is(err, collector.ErrDocumentRead) {
+				gotErr = true
+				logger.Warnf("skipped document: %v", err)
+				return true
+			}
+			logger.Errorf("collector ended with error: %v", err)
+			return false
+		}
+		if err := collector.CollectWithTimeout(ctx, emit, errHandler, guacConfig.Collector.Timeout); err != nil {
+			logger.Fatal(err)
+		}
+
+		process.LogUnsupportedDocuments(ctx)
+		if gotErr {
+			logger.Fatalf("completed collection with errors, not exporting graph")
+		}
+
+		if opts.output == "" {
+			if err := assembler.ExportGraph(os.Stdout, combined, opts.format); err != nil {
+				logger.Fatalf("unable to export graph: %v", err)
+			}
+		} else if err := exportToFile(opts.output, combined, opts.format); err != nil {
+			logger.Fatalf("unable to export graph: %v", err)
+		}
+		logger.Infof("exported %v nodes and %v edges as %v", len(combined.Nodes), len(combined.Edges), opts.format)
+	},
+}
+
+// exportToFile exports the graph to the file at path, closing it before
+// returning so that a failed write of the end of the file is reported
+func exportToFile(path string, g assembler.Graph, format assembler.ExportFormat) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("unable to create output file: %w", err)
+	}
+	err = assembler.ExportGraph(f, g, format)
+	if closeErr := f.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("unable to close output file: %w", closeErr)
+	}
+	return err
+}
+
+func validateExportFlags(c config.Export, args []string) (exportOptions, error) {
+	var opts exportOptions
+
+	for _, f := range assembler.ExportFormats {
+		if string(f) == c.Format {
+			opts.format = f
+		}
+	}
+	if opts.format == "" {
+		return opts, fmt.Errorf("unsupported format %q, expected one of %v", c.Format, assembler.ExportFormats)
+	}
+	opts.output = c.Output
+
+	if len(args) != 1 {
+		return opts, fmt.Errorf("expected positional argument for file_path")
+	}
+	opts.path = args[0]
+
+	return opts, nil
+}
+
+func init() {
+	exportFlags := exportCmd.Flags()
+	exportFlags.String("format", string(assembler.ExportFormatJSON), "format of the exported graph: graphml, json or dot")
+	exportFlags.String("output", "", "file to write the exported graph to (defaults to stdout)")
+	for _, name := range []string{"format", "output"} {
+		if err := viper.BindPFlag(name, exportFlags.Lookup(name)); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to bind flag: %v", err)
+			os.Exit(1)
+		}
+	}
+	rootCmd.AddCommand(exportCmd)
+}
@@ -20,17 +20,19 @@ import (
 	"errors"
 	"fmt"
 	"os"
-	"strings"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/guacsec/guac/pkg/assembler"
 	"github.com/guacsec/guac/pkg/assembler/graphdb"
+	"github.com/guacsec/guac/pkg/config"
 	"github.com/guacsec/guac/pkg/handler/collector"
 	"github.com/guacsec/guac/pkg/handler/collector/file"
+	"github.com/guacsec/guac/pkg/handler/collector/ndjson"
 	"github.com/guacsec/guac/pkg/handler/processor"
+	"github.com/guacsec/guac/pkg/handler/processor/hashcache"
 	"github.com/guacsec/guac/pkg/handler/processor/process"
-	"github.com/guacsec/guac/pkg/ingestor/key"
-	"github.com/guacsec/guac/pkg/ingestor/key/inmemory"
 	"github.com/guacsec/guac/pkg/ingestor/parser"
 	"github.com/guacsec/guac/pkg/ingestor/verifier"
 	"github.com/guacsec/guac/pkg/ingestor/verifier/sigstore_verifier"
@@ -39,36 +41,65 @@ import (
 	"github.com/spf13/viper"
 )
 
-type options struct {
-	dbAddr string
-	user   string
-	pass   string
-	realm  string
-	// path to the pem file
-	keyPath string
-	// ID related to the key being stored
-	keyID string
-	// path to folder with documents to collect
-	path string
-	// map of image repo and tags
-	repoTags map[string][]string
+type filesOptions struct {
+	// paths to folders with documents to collect
+	paths []string
+	// glob patterns of the files to collect, relative to each path
+	include []string
+	exclude []string
+	// newline-delimited list of files to collect instead of walking paths
+	fileList string
+	// collect each line of the paths, newline-delimited JSON files, as a
+	// document
+	ndjson bool
+	// read a single document from stdin, given as the `-` path
+	stdin bool
+	// watch the paths for new and modified files, collected once left
+	// unchanged for watchSettle
+	watch       bool
+	watchSettle time.Duration
 }
 
+// collectPathEnv is the environment variable giving the folder to collect
+// when neither file_path nor --path is set
+const collectPathEnv = "GUAC_COLLECT_PATH"
+
 var exampleCmd = &cobra.Command{
-	Use:   "files [flags] file_path",
+	Use:   "files [flags] [file_path]",
 	Short: "take a folder of files and create a GUAC graph",
+	Long: `take one or more folders of files and create a GUAC graph.
+
+Folders are given as file_path and/or with the repeatable --path flag, or
+else with the GUAC_COLLECT_PATH environment variable. The
+--include and --exclude glob patterns are matched against the path of each
+file relative to its folder, e.g. "**/*.json" or "vendor/**".
+
+Alternatively, --file-list names a file listing the files to collect, one
+per line, in which case no folder is walked. Listed files that do not exist
+are logged and skipped.
+
+With --ndjson, the paths are newline-delimited JSON files, such as an
+export of attestations, which are streamed so that each line is collected
+as a separate document. Lines that are not valid JSON are logged with
+their line number and skipped.
+
+A file_path of "-" reads a single document from standard input, e.g.
+"cat sbom.json | guacone files -", whose type and format are detected from
+its content.
+
+With --watch, the folders and their subfolders are then watched for new and
+modified files until the process is interrupted, so that documents added to a
+large archive are ingested as they arrive without walking it again. A file is
+collected once it was left unchanged for --watch-settle.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		ctx := logging.WithLogger(context.Background())
+		// interrupting a watch still saves the seen document cache
+		ctx, stop := signal.NotifyContext(logging.WithLogger(context.Background()), os.Interrupt, syscall.SIGTERM)
+		defer stop()
 		logger := logging.FromContext(ctx)
+		serveMetrics(ctx)
+		healthServer, watchdog := serveHealth(ctx)
 
-		opts, err := validateFlags(
-			viper.GetString("gdbuser"),
-			viper.GetString("gdbpass"),
-			viper.GetString("gdbaddr"),
-			viper.GetString("realm"),
-			viper.GetString("verifier-keyPath"),
-			viper.GetString("verifier-keyID"),
-			args)
+		opts, err := validateFlags(guacConfig.Files, args)
 		if err != nil {
 			fmt.Printf("unable to validate flags: %v\n", err)
 			_ = cmd.Help()
@@ -76,37 +107,47 @@ var exampleCmd = &cobra.Command{
 		}
 
 		// Register Keystore
-		inmemory := inmemory.NewInmemoryProvider()
-		err = key.RegisterKeyProvider(inmemory, inmemory.Type())
-		if err != nil {
-			logger.Errorf("unable to register key provider: %v", err)
-		}
-
-		if opts.keyPath != "" && opts.keyID != "" {
-			keyRaw, err := os.ReadFile(opts.keyPath)
-			if err != nil {
-				logger.Errorf("error: %v", err)
-				os.Exit(1)
-			}
-			err = key.Store(ctx, opts.keyID, keyRaw, inmemory.Type())
-			if err != nil {
-				logger.Errorf("error: %v", err)
-				os.Exit(1)
-			}
+		if err := registerVerifierKeys(ctx); err != nil {
+			logger.Errorf("error: %v", err)
+			os.Exit(1)
 		}
 
 		// Register Verifier
-		sigstoreAndKeyVerifier := sigstore_verifier.NewSigstoreAndKeyVerifier()
+		verifierOpts, err := sigstoreVerifierOptions()
+		if err != nil {
+			logger.Errorf("unable to load the verifier trust roots: %v", err)
+			os.Exit(1)
+		}
+		sigstoreAndKeyVerifier := sigstore_verifier.NewSigstoreAndKeyVerifier(verifierOpts...)
 		err = verifier.RegisterVerifier(sigstoreAndKeyVerifier, sigstoreAndKeyVerifier.Type())
 		if err != nil {
 			logger.Errorf("unable to register key provider: %v", err)
 		}
 
 		// Register collector
-		fileCollector := file.NewFileCollector(ctx, opts.path, false, time.Second)
-		err = collector.RegisterDocumentCollector(fileCollector, file.FileCollector)
-		if err != nil {
-			logger.Errorf("unable to register file collector: %v", err)
+		if opts.stdin {
+			stdinCollector := file.NewStdinCollector(ctx, os.Stdin)
+			err = collector.RegisterDocumentCollector(stdinCollector, file.StdinCollector)
+			if err != nil {
+				logger.Errorf("unable to register stdin collector: %v", err)
+			}
+		} else if opts.ndjson {
+			ndjsonCollector := ndjson.NewNDJSONCollector(ctx, opts.paths)
+			err = collector.RegisterDocumentCollector(ndjsonCollector, ndjson.NDJSONCollector)
+			if err != nil {
+				logger.Errorf("unable to register ndjson collector: %v", err)
+			}
+		} else {
+			fileCollector := file.NewFileCollectorWithPatterns(ctx, opts.paths, opts.include, opts.exclude, false, time.Second)
+			if opts.watch {
+				fileCollector = file.NewFileWatchCollector(ctx, opts.paths, opts.include, opts.exclude, opts.watchSettle)
+			} else if opts.fileList != "" {
+				fileCollector = file.NewFileListCollector(ctx, opts.fileList, false, time.Second)
+			}
+			err = collector.RegisterDocumentCollector(fileCollector, file.FileCollector)
+			if err != nil {
+				logger.Errorf("unable to register file collector: %v", err)
+			}
 		}
 
 		// Get pipeline of components
@@ -120,36 +161,75 @@ var exampleCmd = &cobra.Command{
 			logger.Errorf("error: %v", err)
 			os.Exit(1)
 		}
-		assemblerFunc, err := getAssembler(opts)
+		backend, err := getBackend(ctx, guacConfig.GraphDB)
+		if err != nil {
+			logger.Errorf("error: %v", err)
+			os.Exit(1)
+		}
+		defer backend.Close()
+		if healthServer != nil {
+			healthServer.AddReadinessCheck("graphdb", func(ctx context.Context) error {
+				return backend.Ping()
+			})
+		}
+		assemblerFunc, err := newAssembler(ctx, backend)
+		if err != nil {
+			logger.Errorf("error: %v", err)
+			os.Exit(1)
+		}
+
+		seenCache, err := getSeenCache(guacConfig.SeenCache)
 		if err != nil {
 			logger.Errorf("error: %v", err)
 			os.Exit(1)
 		}
 
 		totalNum := 0
+		skippedNum := 0
 		gotErr := false
 		// Set emit function to go through the entire pipeline
 		emit := func(d *processor.Document) error {
 			totalNum += 1
 			start := time.Now()
 
+			hash := hashcache.HashDocument(d)
+			if seenCache.Seen(hash) {
+				skippedNum += 1
+				logger.Infof("skipping unchanged doc %+v", d.SourceInformation)
+				return nil
+			}
+
 			docTree, err := processorFunc(d)
+			if errors.Is(err, process.ErrUnsupportedDocument) {
+				// counted and reported in the summary
+				return nil
+			}
 			if err != nil {
 				gotErr = true
-				return fmt.Errorf("unable to process doc: %v, fomat: %v, document: %v", err, d.Format, d.Type)
+				return fmt.Errorf("unable to process doc: %v, format: %v, document: %v", err, d.Format, d.Type)
 			}
 
-			graphs, err := ingestorFunc(docTree)
-			if err != nil {
+			// documents of the tree that parsed are ingested even if others did not
+			graphs, parseErr := ingestorFunc(docTree)
+			if parseErr != nil {
 				gotErr = true
-				return fmt.Errorf("unable to ingest doc tree: %v", err)
+				if len(graphs) == 0 {
+					return fmt.Errorf("unable to ingest doc tree: %v", parseErr)
+				}
 			}
 
+			storeDone := watchdog.Watch("storing graphs")
 			err = assemblerFunc(graphs)
+			storeDone()
 			if err != nil {
 				gotErr = true
 				return fmt.Errorf("unable to assemble graphs: %v", err)
 			}
+			if parseErr != nil {
+				// not added to the seen cache so that it is ingested again
+				return fmt.Errorf("partially ingested doc tree: %v", parseErr)
+			}
+			seenCache.Add(hash)
 			t := time.Now()
 			elapsed := t.Sub(start)
 			logger.Infof("[%v] completed doc %+v", elapsed, d.SourceInformation)
@@ -162,48 +242,117 @@ var exampleCmd = &cobra.Command{
 				logger.Info("collector ended gracefully")
 				return true
 			}
+			if errors.Is(err, collector.ErrCollectorTimeout) {
+				logger.Warnf("collector stopped: %v", err)
+				return true
+			}
+			if errors.Is(err, collector.ErrDocumentRead) {
+				gotErr = true
+				logger.Warnf("skipped document: %v", err)
+				return true
+			}
 			logger.Errorf("collector ended with error: %v", err)
 			return false
 		}
-		if err := collector.Collect(ctx, emit, errHandler); err != nil {
+		if healthServer != nil {
+			healthServer.MarkStarted()
+		}
+		if err := collector.CollectWithTimeout(ctx, emit, errHandler, guacConfig.Collector.Timeout); err != nil {
 			logger.Fatal(err)
 		}
 
+		if path := guacConfig.SeenCache.File; path != "" {
+			if err := seenCache.Save(path); err != nil {
+				logger.Errorf("unable to persist seen document cache: %v", err)
+			}
+		}
+
+		process.LogUnsupportedDocuments(ctx)
 		if gotErr {
 			logger.Fatalf("completed ingestion with errors")
 		} else {
-			logger.Infof("completed ingesting %v documents", totalNum)
+			logger.Infof("completed ingesting %v documents, skipped %v unchanged", totalNum, skippedNum)
 		}
 	},
 }
 
-func validateFlags(user string, pass string, dbAddr string, realm string, keyPath string, keyID string, args []string) (options, error) {
-	var opts options
-	opts.user = user
-	opts.pass = pass
-	opts.dbAddr = dbAddr
-	opts.realm = realm
-
-	if keyPath != "" {
-		if strings.HasSuffix(keyPath, "pem") {
-			opts.keyPath = keyPath
-		} else {
-			return opts, errors.New("key must be passed in as a pem file")
+func validateFlags(files config.Files, args []string) (filesOptions, error) {
+	var opts filesOptions
+	if len(args) > 1 {
+		return opts, fmt.Errorf("expected at most one positional argument for file_path")
+	}
+	if files.NDJSON && files.FileList != "" {
+		return opts, errors.New("ndjson cannot be used together with file-list")
+	}
+	if files.Watch && (files.NDJSON || files.FileList != "") {
+		return opts, errors.New("watch cannot be used together with ndjson or file-list")
+	}
+	if files.Watch && files.WatchSettle <= 0 {
+		return opts, errors.New("watch-settle must be positive")
+	}
+	if files.FileList != "" {
+		if len(args) > 0 || len(files.Paths) > 0 {
+			return opts, errors.New("file-list cannot be used together with file_path or --path")
 		}
+		if len(files.Include) > 0 || len(files.Exclude) > 0 {
+			return opts, errors.New("include and exclude patterns do not apply to file-list")
+		}
+		opts.fileList = files.FileList
+		return opts, nil
+	}
+	opts.paths = append(append(opts.paths, args...), files.Paths...)
+	if len(opts.paths) == 0 && files.CollectPath != "" {
+		opts.paths = []string{files.CollectPath}
 	}
-	if keyPath != "" {
-		opts.keyID = keyID
+	if len(opts.paths) == 0 {
+		return opts, fmt.Errorf("expected positional argument for file_path, --path or the %s environment variable", collectPathEnv)
 	}
 
-	if len(args) != 1 {
-		return opts, fmt.Errorf("expected positional argument for file_path")
+	for _, p := range opts.paths {
+		if p != file.StdinPath {
+			continue
+		}
+		if len(opts.paths) > 1 {
+			return opts, errors.New("stdin, given as -, must be the only path")
+		}
+		if files.NDJSON || files.Watch || len(files.Include) > 0 || len(files.Exclude) > 0 {
+			return opts, errors.New("ndjson, watch, include and exclude do not apply to stdin")
+		}
+		opts.stdin = true
+		return opts, nil
 	}
 
-	opts.path = args[0]
+	if files.NDJSON {
+		if len(files.Include) > 0 || len(files.Exclude) > 0 {
+			return opts, errors.New("include and exclude patterns do not apply to ndjson files")
+		}
+		opts.ndjson = true
+		return opts, nil
+	}
+
+	if err := file.ValidatePatterns(append(append([]string{}, files.Include...), files.Exclude...)); err != nil {
+		return opts, err
+	}
+	opts.include = files.Include
+	opts.exclude = files.Exclude
+	opts.watch = files.Watch
+	opts.watchSettle = files.WatchSettle
 
 	return opts, nil
 }
 
+// getSeenCache returns the cache of ingested document hashes, loaded from
+// its file if it is set
+func getSeenCache(c config.SeenCache) (*hashcache.Cache, error) {
+	seenCache := hashcache.New(c.Size, c.TTL)
+	if c.File != "" {
+		if err := seenCache.Load(c.File); err != nil {
+			return nil, err
+		}
+	}
+	return seenCache, nil
+}
+
 func getProcessor(ctx context.Context) (func(*processor.Document) (processor.DocumentTree, error), error) {
 	return func(d *processor.Document) (processor.DocumentTree, error) {
 		return process.Process(ctx, d)
@@ -211,40 +360,40 @@ func getProcessor(ctx context.Context) (func(*processor.Document) (processor.Doc
 }
 func getIngestor(ctx context.Context) (func(processor.DocumentTree) ([]assembler.Graph, error), error) {
 	return func(doc processor.DocumentTree) ([]assembler.Graph, error) {
-		inputs, err := parser.ParseDocumentTree(ctx, doc)
-		if err != nil {
-			return nil, err
-		}
-		return inputs, nil
+		// on a *parser.TreeError, inputs has the graphs of the documents
+		// that did parse
+		return parser.ParseDocumentTree(ctx, doc)
 	}, nil
 }
 
-func getAssembler(opts options) (func([]assembler.Graph) error, error) {
-	authToken := graphdb.CreateAuthTokenWithUsernameAndPassword(
-		opts.user,
-		opts.pass,
-		opts.realm,
-	)
-
-	client, err := graphdb.NewGraphClient(opts.dbAddr, authToken)
+// getAssembler returns a function storing graphs to the gdb-backend graph db,
+// limited to gdb-write-rate write operations per second if set. The documents
+// of the graphs supersede the earlier documents of their source.
+func getAssembler(ctx context.Context, graphDB config.GraphDB) (func([]assembler.Graph) error, error) {
+	backend, err := getBackend(ctx, graphDB)
 	if err != nil {
 		return nil, err
 	}
+	return newAssembler(ctx, backend)
+}
 
-	err = createIndices(client)
+// newAssembler returns a function storing graphs to backend, once its indices
+// are created, the documents of the graphs superseding the earlier documents
+// of their source
+func newAssembler(ctx context.Context, backend assembler.Backend) (func([]assembler.Graph) error, error) {
+	err := createIndices(backend)
 	if err != nil {
 		return nil, err
 	}
 
 	return func(gs []assembler.Graph) error {
-		combined := assembler.Graph{
-			Nodes: []assembler.GuacNode{},
-			Edges: []assembler.GuacEdge{},
-		}
-		for _, g := range gs {
-			combined.AppendGraph(g)
+		builder := assembler.NewConcurrentGraphBuilder()
+		builder.AppendGraph(gs...)
+		g, err := assembler.Supersede(backend, builder.Graph())
+		if err != nil {
+			return err
 		}
-		if err := assembler.StoreGraph(combined, client); err != nil {
+		if err := backend.StoreGraph(ctx, g); err != nil {
 			return err
 		}
 
@@ -252,18 +401,56 @@ func getAssembler(opts options) (func([]assembler.Graph) error, error) {
 	}, nil
 }
 
-func createIndices(client graphdb.Client) error {
+// getBackend connects to the gdb-backend graph db, over mTLS if a client
+// certificate is configured, limiting writes to gdb-write-rate operations
+// per second if set, in transactions of up to gdb-batch-size writes
+func getBackend(ctx context.Context, graphDB config.GraphDB) (assembler.Backend, error) {
+	return assembler.NewBackend(ctx, graphDB.Backend, assembler.BackendConfig{
+		Address:        graphDB.Addr,
+		User:           graphDB.User,
+		Password:       graphDB.Pass,
+		Realm:          graphDB.Realm,
+		TLSCertPath:    graphDB.TLSCert,
+		TLSKeyPath:     graphDB.TLSKey,
+		TLSCAPath:      graphDB.TLSCA,
+		WriteLimiter:   assembler.NewWriteLimiter(graphDB.WriteRate),
+		WriteBatchSize: graphDB.BatchSize,
+	})
+}
+
+// getGraphClient connects to the graph db, over mTLS if a client certificate
+// is configured, for the commands running Cypher queries which require the
+// neo4j backend
+func getGraphClient(graphDB config.GraphDB, authToken graphdb.AuthToken) (graphdb.Client, error) {
+	if backend := graphDB.Backend; backend != assembler.Neo4jBackend {
+		return nil, fmt.Errorf("this command requires the %s backend, got %s", assembler.Neo4jBackend, backend)
+	}
+	if graphDB.TLSCert == "" {
+		return graphdb.NewGraphClient(graphDB.Addr, authToken)
+	}
+	tlsConfig, err := graphdb.LoadClientTLSConfig(graphDB.TLSCert, graphDB.TLSKey, graphDB.TLSCA)
+	if err != nil {
+		return nil, err
+	}
+	return graphdb.NewGraphClientWithTLS(graphDB.Addr, authToken, tlsConfig)
+}
+
+func createIndices(backend assembler.Backend) error {
 	indices := map[string][]string{
-		"Artifact":      {"digest", "name"},
-		"Package":       {"purl", "name"},
-		"Metadata":      {"id"},
-		"Attestation":   {"digest"},
-		"Vulnerability": {"id"},
+		"Artifact":          {"digest", "name", "digest_sha1", "digest_sha256", "digest_sha512"},
+		"Package":           {"purl", "name"},
+		"Metadata":          {"id"},
+		"Attestation":       {"digest", "collected_at", "statement_time"},
+		"Builder":           {"id"},
+		"Vulnerability":     {"id"},
+		"License":           {"id"},
+		"ExternalReference": {"url"},
+		"Document":          {"digest", "collected_at", "source_uri", "source"},
 	}
 
 	for label, attributes := range indices {
 		for _, attribute := range attributes {
-			err := assembler.CreateIndexOn(client, label, attribute)
+			err := backend.CreateIndexOn(label, attribute)
 			if err != nil {
 				return err
 			}
@@ -274,5 +461,24 @@ func createIndices(client graphdb.Client) error {
 }
 
 func init() {
+	filesFlags := exampleCmd.Flags()
+	filesFlags.StringSlice("path", nil, "folder with documents to collect, can be repeated")
+	filesFlags.StringSlice("include", nil, "glob pattern of the files to collect, relative to each path, can be repeated")
+	filesFlags.StringSlice("exclude", nil, "glob pattern of the files or folders to skip, relative to each path, can be repeated")
+	filesFlags.String("file-list", "", "file listing the files to collect, one per line, instead of walking folders")
+	filesFlags.Bool("ndjson", false, "stream the paths as newline-delimited JSON files, collecting each line as a document")
+	filesFlags.Bool("watch", false, "watch the folders for new and modified files after collecting them, until interrupted")
+	filesFlags.Duration("watch-settle", 2*time.Second, "time a watched file must be left unchanged before it is collected")
+	for _, name := range []string{"path", "include", "exclude", "file-list", "ndjson", "watch", "watch-settle"} {
+		if err := viper.BindPFlag(name, filesFlags.Lookup(name)); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to bind flag: %v", err)
+			os.Exit(1)
+		}
+	}
+	// the path can also be given by environment, e.g. when running in a container
+	if err := viper.BindEnv("collect-path", collectPathEnv); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to bind environment variable: %v", err)
+		os.Exit(1)
+	}
 	rootCmd.AddCommand(exampleCmd)
 }
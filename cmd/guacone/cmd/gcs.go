@@ -0,0 +1,210 @@
+//
+// Copyright 2022 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/guacsec/guac/pkg/config"
+	"github.com/guacsec/guac/pkg/handler/collector"
+	"github.com/guacsec/guac/pkg/handler/collector/gcs"
+	"github.com/guacsec/guac/pkg/handler/processor"
+	"github.com/guacsec/guac/pkg/handler/processor/process"
+	"github.com/guacsec/guac/pkg/logging"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+type gcsOptions struct {
+	// bucket to collect documents from
+	bucket string
+	// prefix of the objects to collect
+	prefix string
+	// interval to poll the bucket at, 0 to collect once
+	pollInterval time.Duration
+	// project billed for requester pays buckets
+	userProject string
+	// subscription to the notifications of the bucket
+	subscription string
+}
+
+var gcsCmd = &cobra.Command{
+	Use:   "gcs [flags] bucket",
+	Short: "takes the documents stored in a GCS bucket to add to GUAC graph",
+	Long: `takes the documents stored in a GCS bucket to add to GUAC graph.
+
+Authenticates with the application default credentials. With --gcs-poll-interval
+the bucket is polled and only new or updated objects (by generation) are
+ingested. Requester pays buckets need --gcs-user-project to bill the requests.
+
+With --gcs-subscription, the objects are ingested as they are created or
+overwritten, from the Pub/Sub notifications of the bucket received on the
+subscription, e.g. projects/my-project/subscriptions/sboms. The notifications
+are set up with: gsutil notification create -t TOPIC -f json gs://BUCKET`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := logging.WithLogger(context.Background())
+		logger := logging.FromContext(ctx)
+
+		opts, err := validateGCSFlags(guacConfig.GCS, args)
+		if err != nil {
+			fmt.Printf("unable to validate flags: %v\n", err)
+			_ = cmd.Help()
+			os.Exit(1)
+		}
+
+		// Register collector
+		collectorOpts := []gcs.Option{}
+		if opts.userProject != "" {
+			collectorOpts = append(collectorOpts, gcs.WithUserProject(opts.userProject))
+		}
+		if opts.subscription != "" {
+			collectorOpts = append(collectorOpts, gcs.WithNotifications(opts.subscription))
+		}
+		gcsCollector, err := gcs.NewGCSCollector(ctx, opts.bucket, opts.prefix, opts.pollInterval, collectorOpts...)
+		if err != nil {
+			logger.Errorf("error: %v", err)
+			os.Exit(1)
+		}
+		err = collector.RegisterDocumentCollector(gcsCollector, gcs.CollectorGCS)
+		if err != nil {
+			logger.Errorf("unable to register gcs collector: %v", err)
+		}
+
+		// Get pipeline of components
+		processorFunc, err := getProcessor(ctx)
+		if err != nil {
+			logger.Errorf("error: %v", err)
+			os.Exit(1)
+		}
+		ingestorFunc, err := getIngestor(ctx)
+		if err != nil {
+			logger.Errorf("error: %v", err)
+			os.Exit(1)
+		}
+		assemblerFunc, err := getAssembler(ctx, guacConfig.GraphDB)
+		if err != nil {
+			logger.Errorf("error: %v", err)
+			os.Exit(1)
+		}
+
+		totalNum := 0
+		gotErr := false
+		// Set emit function to go through the entire pipeline
+		emit := func(d *processor.Document) error {
+			totalNum += 1
+			start := time.Now()
+
+			docTree, err := processorFunc(d)
+			if errors.Is(err, process.ErrUnsupportedDocument) {
+				// counted and reported in the summary
+				return nil
+			}
+			if err != nil {
+				gotErr = true
+				return fmt.Errorf("unable to process doc: %v, format: %v, document: %v", err, d.Format, d.Type)
+			}
+
+			graphs, err := ingestorFunc(docTree)
+			if err != nil {
+				gotErr = true
+				if len(graphs) == 0 {
+					return fmt.Errorf("unable to ingest doc tree: %v", err)
+				}
+				logger.Errorf("partially ingesting doc tree: %v", err)
+			}
+
+			err = assemblerFunc(graphs)
+			if err != nil {
+				gotErr = true
+				return fmt.Errorf("unable to assemble graphs: %v", err)
+			}
+			t := time.Now()
+			elapsed := t.Sub(start)
+			logger.Infof("[%v] completed doc %+v", elapsed, d.SourceInformation)
+			return nil
+		}
+
+		// Collect
+		errHandler := func(err error) bool {
+			if err == nil {
+				logger.Info("collector ended gracefully")
+				return true
+			}
+			if errors.Is(err, collector.ErrCollectorTimeout) {
+				logger.Warnf("collector stopped: %v", err)
+				return true
+			}
+			if errors.Is(err, collector.ErrDocumentRead) {
+				gotErr = true
+				logger.Warnf("skipped document: %v", err)
+				return true
+			}
+			logger.Errorf("collector ended with error: %v", err)
+			return false
+		}
+		if err := collector.CollectWithTimeout(ctx, emit, errHandler, guacConfig.Collector.Timeout); err != nil {
+			logger.Fatal(err)
+		}
+
+		process.LogUnsupportedDocuments(ctx)
+		if gotErr {
+			logger.Fatalf("completed ingestion with errors")
+		} else {
+			logger.Infof("completed ingesting %v documents", totalNum)
+		}
+	},
+}
+
+func validateGCSFlags(c config.GCS, args []string) (gcsOptions, error) {
+	var opts gcsOptions
+	opts.prefix = c.Prefix
+	opts.userProject = c.UserProject
+	opts.subscription = c.Subscription
+
+	if c.PollInterval < 0 {
+		return opts, fmt.Errorf("gcs-poll-interval must not be negative")
+	}
+	if c.PollInterval > 0 && c.Subscription != "" {
+		return opts, fmt.Errorf("gcs-poll-interval and gcs-subscription are mutually exclusive")
+	}
+	opts.pollInterval = c.PollInterval
+
+	if len(args) != 1 {
+		return opts, fmt.Errorf("expected positional argument for bucket")
+	}
+	opts.bucket = args[0]
+
+	return opts, nil
+}
+
+func init() {
+	gcsFlags := gcsCmd.Flags()
+	gcsFlags.String("gcs-prefix", "", "only collect the objects whose name starts with this prefix")
+	gcsFlags.Duration("gcs-poll-interval", 0, "interval to poll the bucket for new or updated objects, 0 to collect once")
+	gcsFlags.String("gcs-user-project", "", "project to bill for requests to requester pays buckets")
+	gcsFlags.String("gcs-subscription", "", "Pub/Sub subscription to the notifications of the bucket, to collect objects as they change")
+	for _, name := range []string{"gcs-prefix", "gcs-poll-interval", "gcs-user-project", "gcs-subscription"} {
+		if err := viper.BindPFlag(name, gcsFlags.Lookup(name)); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to bind flag: %v", err)
+			os.Exit(1)
+		}
+	}
+	rootCmd.AddCommand(gcsCmd)
+}
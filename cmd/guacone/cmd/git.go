@@ -0,0 +1,210 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/guacsec/guac/pkg/config"
+	"github.com/guacsec/guac/pkg/handler/collector"
+	"github.com/guacsec/guac/pkg/handler/collector/file"
+	git_collector "github.com/guacsec/guac/pkg/handler/collector/git"
+	"github.com/guacsec/guac/pkg/handler/processor"
+	"github.com/guacsec/guac/pkg/handler/processor/process"
+	"github.com/guacsec/guac/pkg/logging"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+type gitOptions struct {
+	// url of the repository to collect
+	url string
+	// directory the repository is cloned to
+	dir string
+	// branch to track, the default branch if empty
+	branch string
+	// patterns of the files to collect
+	patterns []string
+	// interval to poll the repository at, 0 to collect once
+	pollInterval time.Duration
+}
+
+var gitCmd = &cobra.Command{
+	Use:   "git [flags] url",
+	Short: "takes the SBOMs and attestations committed to a git repository to add to GUAC graph",
+	Long: `takes the SBOMs and attestations committed to a git repository to add to GUAC graph.
+
+The repository is cloned to --git-dir, or pulled if it was cloned there before,
+and the files whose path matches one of the --git-pattern globs are collected,
+by default the usual SBOM and attestation file names and .sbom directories.
+With --git-branch a branch other than the default one is tracked. With
+--git-poll-interval the new commits are pulled and the files they changed are
+ingested.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := logging.WithLogger(context.Background())
+		logger := logging.FromContext(ctx)
+
+		opts, err := validateGitFlags(guacConfig.Git, args)
+		if err != nil {
+			fmt.Printf("unable to validate flags: %v\n", err)
+			_ = cmd.Help()
+			os.Exit(1)
+		}
+
+		// Register collector
+		collectorOpts := []git_collector.Option{git_collector.WithPatterns(opts.patterns)}
+		if opts.branch != "" {
+			collectorOpts = append(collectorOpts, git_collector.WithBranch(opts.branch))
+		}
+		gitCollector := git_collector.NewGitDocumentCollector(ctx, opts.url, opts.dir, opts.pollInterval > 0, opts.pollInterval, collectorOpts...)
+		err = collector.RegisterDocumentCollector(gitCollector, git_collector.CollectorGitDocument)
+		if err != nil {
+			logger.Errorf("unable to register git collector: %v", err)
+		}
+
+		// Get pipeline of components
+		processorFunc, err := getProcessor(ctx)
+		if err != nil {
+			logger.Errorf("error: %v", err)
+			os.Exit(1)
+		}
+		ingestorFunc, err := getIngestor(ctx)
+		if err != nil {
+			logger.Errorf("error: %v", err)
+			os.Exit(1)
+		}
+		assemblerFunc, err := getAssembler(ctx, guacConfig.GraphDB)
+		if err != nil {
+			logger.Errorf("error: %v", err)
+			os.Exit(1)
+		}
+
+		totalNum := 0
+		gotErr := false
+		// Set emit function to go through the entire pipeline
+		emit := func(d *processor.Document) error {
+			totalNum += 1
+			start := time.Now()
+
+			docTree, err := processorFunc(d)
+			if errors.Is(err, process.ErrUnsupportedDocument) {
+				// counted and reported in the summary
+				return nil
+			}
+			if err != nil {
+				gotErr = true
+				return fmt.Errorf("unable to process doc: %v, format: %v, document: %v", err, d.Format, d.Type)
+			}
+
+			graphs, err := ingestorFunc(docTree)
+			if err != nil {
+				gotErr = true
+				if len(graphs) == 0 {
+					return fmt.Errorf("unable to ingest doc tree: %v", err)
+				}
+				logger.Errorf("partially ingesting doc tree: %v", err)
+			}
+
+			err = assemblerFunc(graphs)
+			if err != nil {
+				gotErr = true
+				return fmt.Errorf("unable to assemble graphs: %v", err)
+			}
+			t := time.Now()
+			elapsed := t.Sub(start)
+			logger.Infof("[%v] completed doc %+v", elapsed, d.SourceInformation)
+			return nil
+		}
+
+		// Collect
+		errHandler := func(err error) bool {
+			if err == nil {
+				logger.Info("collector ended gracefully")
+				return true
+			}
+			if errors.Is(err, collector.ErrCollectorTimeout) {
+				logger.Warnf("collector stopped: %v", err)
+				return true
+			}
+			if errors.Is(err, collector.ErrDocumentRead) {
+				gotErr = true
+				logger.Warnf("skipped document: %v", err)
+				return true
+			}
+			logger.Errorf("collector ended with error: %v", err)
+			return false
+		}
+		if err := collector.CollectWithTimeout(ctx, emit, errHandler, guacConfig.Collector.Timeout); err != nil {
+			logger.Fatal(err)
+		}
+
+		process.LogUnsupportedDocuments(ctx)
+		if gotErr {
+			logger.Fatalf("completed ingestion with errors")
+		} else {
+			logger.Infof("completed ingesting %v documents", totalNum)
+		}
+	},
+}
+
+func validateGitFlags(c config.Git, args []string) (gitOptions, error) {
+	var opts gitOptions
+	opts.branch = c.Branch
+
+	if c.PollInterval < 0 {
+		return opts, fmt.Errorf("git-poll-interval must not be negative")
+	}
+	opts.pollInterval = c.PollInterval
+
+	if err := file.ValidatePatterns(c.Patterns); err != nil {
+		return opts, err
+	}
+	opts.patterns = c.Patterns
+
+	if len(args) != 1 {
+		return opts, fmt.Errorf("expected positional argument for url")
+	}
+	opts.url = args[0]
+
+	opts.dir = c.Dir
+	if opts.dir == "" {
+		// a directory per repository, so that it is pulled on the next run
+		opts.dir = filepath.Join(os.TempDir(), "guac-git", fmt.Sprintf("%x", sha256.Sum256([]byte(opts.url)))[:16])
+	}
+
+	return opts, nil
+}
+
+func init() {
+	gitFlags := gitCmd.Flags()
+	gitFlags.String("git-dir", "", "directory to clone the repository to, defaults to a directory for the url under the temporary directory")
+	gitFlags.String("git-branch", "", "branch to track, defaults to the default branch of the repository")
+	gitFlags.StringSlice("git-pattern", git_collector.DefaultPatterns, "glob of the paths of the files to collect relative to the repository, can be repeated, `**` matches any number of directories")
+	gitFlags.Duration("git-poll-interval", 0, "interval to pull the new commits of the repository, 0 to collect once")
+	for _, name := range []string{"git-dir", "git-branch", "git-pattern", "git-poll-interval"} {
+		if err := viper.BindPFlag(name, gitFlags.Lookup(name)); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to bind flag: %v", err)
+			os.Exit(1)
+		}
+	}
+	rootCmd.AddCommand(gitCmd)
+}
@@ -0,0 +1,198 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/guacsec/guac/pkg/config"
+	"github.com/guacsec/guac/pkg/handler/collector"
+	"github.com/guacsec/guac/pkg/handler/collector/github"
+	"github.com/guacsec/guac/pkg/handler/processor"
+	"github.com/guacsec/guac/pkg/handler/processor/process"
+	"github.com/guacsec/guac/pkg/logging"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+type githubReleaseOptions struct {
+	// repositories to collect, as owner/repo
+	repos []string
+	// patterns of the names of the assets to collect
+	patterns []string
+	// only collect the latest release of each repository
+	latestOnly bool
+	// interval to poll the releases at, 0 to collect once
+	pollInterval time.Duration
+}
+
+var githubReleaseCmd = &cobra.Command{
+	Use:   "github-release [flags] owner/repo...",
+	Short: "takes the SBOMs and attestations published as GitHub release assets to add to GUAC graph",
+	Long: `takes the SBOMs and attestations published as GitHub release assets to add to GUAC graph.
+
+The assets of the releases whose name matches one of the --github-asset-pattern
+globs are collected, by default the usual SBOM and attestation file names such
+as *.spdx.json and *.intoto.jsonl. Set GITHUB_TOKEN to read private repositories
+and for a higher rate limit. With --github-poll-interval the releases are
+polled and only new assets are ingested.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := logging.WithLogger(context.Background())
+		logger := logging.FromContext(ctx)
+
+		opts, err := validateGitHubReleaseFlags(guacConfig.GitHubRelease, args)
+		if err != nil {
+			fmt.Printf("unable to validate flags: %v\n", err)
+			_ = cmd.Help()
+			os.Exit(1)
+		}
+
+		// Register collector
+		collectorOpts := []github.Option{}
+		if opts.latestOnly {
+			collectorOpts = append(collectorOpts, github.WithLatestOnly())
+		}
+		releaseCollector, err := github.NewReleaseCollector(ctx, opts.repos, opts.patterns, opts.pollInterval, collectorOpts...)
+		if err != nil {
+			logger.Errorf("error: %v", err)
+			os.Exit(1)
+		}
+		err = collector.RegisterDocumentCollector(releaseCollector, github.CollectorGitHubRelease)
+		if err != nil {
+			logger.Errorf("unable to register github release collector: %v", err)
+		}
+
+		// Get pipeline of components
+		processorFunc, err := getProcessor(ctx)
+		if err != nil {
+			logger.Errorf("error: %v", err)
+			os.Exit(1)
+		}
+		ingestorFunc, err := getIngestor(ctx)
+		if err != nil {
+			logger.Errorf("error: %v", err)
+			os.Exit(1)
+		}
+		assemblerFunc, err := getAssembler(ctx, guacConfig.GraphDB)
+		if err != nil {
+			logger.Errorf("error: %v", err)
+			os.Exit(1)
+		}
+
+		totalNum := 0
+		gotErr := false
+		// Set emit function to go through the entire pipeline
+		emit := func(d *processor.Document) error {
+			totalNum += 1
+			start := time.Now()
+
+			docTree, err := processorFunc(d)
+			if errors.Is(err, process.ErrUnsupportedDocument) {
+				// counted and reported in the summary
+				return nil
+			}
+			if err != nil {
+				gotErr = true
+				return fmt.Errorf("unable to process doc: %v, format: %v, document: %v", err, d.Format, d.Type)
+			}
+
+			graphs, err := ingestorFunc(docTree)
+			if err != nil {
+				gotErr = true
+				if len(graphs) == 0 {
+					return fmt.Errorf("unable to ingest doc tree: %v", err)
+				}
+				logger.Errorf("partially ingesting doc tree: %v", err)
+			}
+
+			err = assemblerFunc(graphs)
+			if err != nil {
+				gotErr = true
+				return fmt.Errorf("unable to assemble graphs: %v", err)
+			}
+			t := time.Now()
+			elapsed := t.Sub(start)
+			logger.Infof("[%v] completed doc %+v", elapsed, d.SourceInformation)
+			return nil
+		}
+
+		// Collect
+		errHandler := func(err error) bool {
+			if err == nil {
+				logger.Info("collector ended gracefully")
+				return true
+			}
+			if errors.Is(err, collector.ErrCollectorTimeout) {
+				logger.Warnf("collector stopped: %v", err)
+				return true
+			}
+			if errors.Is(err, collector.ErrDocumentRead) {
+				gotErr = true
+				logger.Warnf("skipped document: %v", err)
+				return true
+			}
+			logger.Errorf("collector ended with error: %v", err)
+			return false
+		}
+		if err := collector.CollectWithTimeout(ctx, emit, errHandler, guacConfig.Collector.Timeout); err != nil {
+			logger.Fatal(err)
+		}
+
+		process.LogUnsupportedDocuments(ctx)
+		if gotErr {
+			logger.Fatalf("completed ingestion with errors")
+		} else {
+			logger.Infof("completed ingesting %v documents", totalNum)
+		}
+	},
+}
+
+func validateGitHubReleaseFlags(c config.GitHubRelease, args []string) (githubReleaseOptions, error) {
+	var opts githubReleaseOptions
+	opts.patterns = c.AssetPatterns
+	opts.latestOnly = c.LatestOnly
+
+	if c.PollInterval < 0 {
+		return opts, fmt.Errorf("github-poll-interval must not be negative")
+	}
+	opts.pollInterval = c.PollInterval
+
+	if len(args) < 1 {
+		return opts, fmt.Errorf("expected positional arguments for repositories")
+	}
+	opts.repos = args
+
+	return opts, nil
+}
+
+func init() {
+	githubReleaseFlags := githubReleaseCmd.Flags()
+	githubReleaseFlags.StringSlice("github-asset-pattern", nil, "glob of the names of the release assets to collect, can be repeated, defaults to common SBOM and attestation names")
+	githubReleaseFlags.Bool("github-latest-only", false, "only collect the assets of the latest release of each repository")
+	githubReleaseFlags.Duration("github-poll-interval", 0, "interval to poll the releases for new assets, 0 to collect once")
+	for _, name := range []string{"github-asset-pattern", "github-latest-only", "github-poll-interval"} {
+		if err := viper.BindPFlag(name, githubReleaseFlags.Lookup(name)); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to bind flag: %v", err)
+			os.Exit(1)
+		}
+	}
+	rootCmd.AddCommand(githubReleaseCmd)
+}
@@ -0,0 +1,137 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/guacsec/guac/pkg/config"
+	"github.com/guacsec/guac/pkg/graphql"
+	"github.com/guacsec/guac/pkg/logging"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// graphQLPath is the path the GraphQL queries are served on
+const graphQLPath = "/query"
+
+type graphQLOptions struct {
+	// address to listen on
+	addr string
+}
+
+var graphQLCmd = &cobra.Command{
+	Use:   "graphql [flags]",
+	Short: "serves the GUAC graph over GraphQL",
+	Long: `serves the GUAC graph over GraphQL.
+
+The packages, artifacts, attestations, vulnerabilities, builders and
+identities of the graph are queried with GET or POST /query, filtered by purl,
+digest, predicate type or ID, and their edges are followed as fields:
+
+  curl -H "Content-Type: application/json" http://localhost:8080/query -d '{
+    "query": "{ packages(purl: \"pkg:golang/golang.org/x/text@v0.3.7\") { purl vulnerabilities { id } dependents { purl } } }"
+  }'
+
+The schema is returned by the usual introspection queries. Every list of
+nodes has a limit argument, 100 by default. The server runs until the process
+is interrupted.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, stop := signal.NotifyContext(logging.WithLogger(context.Background()), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		logger := logging.FromContext(ctx)
+		serveMetrics(ctx)
+		healthServer, _ := serveHealth(ctx)
+
+		opts, err := validateGraphQLFlags(guacConfig.GraphQL, args)
+		if err != nil {
+			fmt.Printf("unable to validate flags: %v\n", err)
+			_ = cmd.Help()
+			os.Exit(1)
+		}
+
+		backend, err := getBackend(ctx, guacConfig.GraphDB)
+		if err != nil {
+			logger.Fatalf("unable to connect to graph db: %v", err)
+		}
+		defer backend.Close()
+		if healthServer != nil {
+			healthServer.AddReadinessCheck("graphdb", func(ctx context.Context) error {
+				return backend.Ping()
+			})
+			healthServer.MarkStarted()
+		}
+
+		schema, err := graphql.NewSchema(backend)
+		if err != nil {
+			logger.Fatalf("invalid GraphQL schema: %v", err)
+		}
+		mux := http.NewServeMux()
+		mux.Handle(graphQLPath, graphql.Handler(ctx, schema))
+		logger.Infof("serving GraphQL on %s%s", opts.addr, graphQLPath)
+		if err := serveHTTP(ctx, opts.addr, mux); err != nil {
+			logger.Fatalf("GraphQL server failed: %v", err)
+		}
+	},
+}
+
+// serveHTTP serves handler on addr until ctx is cancelled
+func serveHTTP(ctx context.Context, addr string, handler http.Handler) error {
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func validateGraphQLFlags(c config.GraphQL, args []string) (graphQLOptions, error) {
+	var opts graphQLOptions
+	if len(args) > 0 {
+		return opts, fmt.Errorf("unexpected positional arguments: %v", args)
+	}
+	if c.Addr == "" {
+		return opts, errors.New("graphql-addr must be set")
+	}
+	opts.addr = c.Addr
+	return opts, nil
+}
+
+func init() {
+	graphQLFlags := graphQLCmd.Flags()
+	graphQLFlags.String("graphql-addr", ":8080", "address to serve the GraphQL queries on")
+	if err := viper.BindPFlag("graphql-addr", graphQLFlags.Lookup("graphql-addr")); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to bind flag: %v", err)
+		os.Exit(1)
+	}
+	rootCmd.AddCommand(graphQLCmd)
+}
@@ -0,0 +1,240 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/guacsec/guac/pkg/assembler"
+	"github.com/guacsec/guac/pkg/config"
+	"github.com/guacsec/guac/pkg/handler/collector"
+	"github.com/guacsec/guac/pkg/handler/collector/file"
+	"github.com/guacsec/guac/pkg/handler/processor/process"
+	"github.com/guacsec/guac/pkg/ingestor/verifier"
+	"github.com/guacsec/guac/pkg/ingestor/verifier/sigstore_verifier"
+	"github.com/guacsec/guac/pkg/logging"
+	"github.com/guacsec/guac/pkg/pipeline"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var ingestCmd = &cobra.Command{
+	Use:   "ingest [flags] path...",
+	Short: "collect, process, parse and store the documents of files and folders in one process, printing a summary",
+	Long: `collect, process, parse and store the documents of files and folders in one
+process, printing a summary of the documents and of the nodes and edges
+written to the graph db.
+
+Each path is a document or a folder walked for documents. The documents are
+processed --processors at a time and stored one at a time, passed from one
+stage to the next through channels, so that no broker is needed. A document
+that fails is logged and the others carry on; the command exits with a
+non-zero status if any failed. The nodes and edges written include those
+already in the graph db, which are merged.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if code := ingest(args); code != 0 {
+			os.Exit(code)
+		}
+	},
+}
+
+// ingest runs the ingest command and returns its exit status, so that the
+// deferred closes run before the process exits
+func ingest(args []string) int {
+	ctx, stop := signal.NotifyContext(logging.WithLogger(context.Background()), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	logger := logging.FromContext(ctx)
+	serveMetrics(ctx)
+	healthServer, watchdog := serveHealth(ctx)
+
+	for _, p := range args {
+		if _, err := os.Stat(p); err != nil {
+			fmt.Printf("unable to read path: %v\n", err)
+			return 1
+		}
+	}
+
+	if err := registerVerifierKeys(ctx); err != nil {
+		logger.Errorf("error: %v", err)
+		return 1
+	}
+	verifierOpts, err := sigstoreVerifierOptions()
+	if err != nil {
+		logger.Errorf("unable to load the verifier trust roots: %v", err)
+		return 1
+	}
+	sigstoreAndKeyVerifier := sigstore_verifier.NewSigstoreAndKeyVerifier(verifierOpts...)
+	if err := verifier.RegisterVerifier(sigstoreAndKeyVerifier, sigstoreAndKeyVerifier.Type()); err != nil {
+		logger.Errorf("unable to register key provider: %v", err)
+	}
+
+	fileCollector := file.NewFileCollectorWithPatterns(ctx, args, nil, nil, false, time.Second)
+	if err := collector.RegisterDocumentCollector(fileCollector, file.FileCollector); err != nil {
+		logger.Errorf("unable to register file collector: %v", err)
+	}
+
+	backend, err := getBackend(ctx, guacConfig.GraphDB)
+	if err != nil {
+		logger.Errorf("error: %v", err)
+		return 1
+	}
+	defer backend.Close()
+	if healthServer != nil {
+		healthServer.AddReadinessCheck("graphdb", func(ctx context.Context) error {
+			return backend.Ping()
+		})
+	}
+	if err := createIndices(backend); err != nil {
+		logger.Errorf("error: %v", err)
+		return 1
+	}
+	seenCache, err := getSeenCache(guacConfig.SeenCache)
+	if err != nil {
+		logger.Errorf("error: %v", err)
+		return 1
+	}
+
+	written := newGraphSummary()
+	assemble := func(ctx context.Context, gs []assembler.Graph) error {
+		defer watchdog.Watch("storing graphs")()
+		builder := assembler.NewConcurrentGraphBuilder()
+		builder.AppendGraph(gs...)
+		g, err := assembler.Supersede(backend, builder.Graph())
+		if err != nil {
+			return err
+		}
+		g = assembler.DeduplicateGraph(g)
+		if err := backend.StoreGraph(ctx, g); err != nil {
+			return err
+		}
+		written.add(g)
+		return nil
+	}
+	errHandler := func(err error) bool {
+		if err == nil {
+			logger.Info("collector ended gracefully")
+			return true
+		}
+		if errors.Is(err, collector.ErrCollectorTimeout) {
+			logger.Warnf("collector stopped: %v", err)
+			return true
+		}
+		if errors.Is(err, collector.ErrDocumentRead) {
+			logger.Warnf("skipped document: %v", err)
+			return true
+		}
+		logger.Errorf("collector ended with error: %v", err)
+		return false
+	}
+	collect := func(ctx context.Context, emit collector.Emitter) error {
+		return collector.CollectWithTimeout(ctx, emit, errHandler, guacConfig.Collector.Timeout)
+	}
+
+	if healthServer != nil {
+		healthServer.MarkStarted()
+	}
+	start := time.Now()
+	stats, err := pipeline.RunStandalone(ctx, pipeline.Config{
+		Processors: guacConfig.Processor.Workers,
+		Seen:       seenCache,
+	}, collect, assemble)
+	if path := guacConfig.SeenCache.File; path != "" {
+		if err := seenCache.Save(path); err != nil {
+			logger.Errorf("unable to persist seen document cache: %v", err)
+		}
+	}
+	process.LogUnsupportedDocuments(ctx)
+	if err != nil {
+		logger.Errorf("collector ended with error: %v", err)
+		return 1
+	}
+	printIngestSummary(os.Stdout, stats, written, time.Since(start))
+	if stats.Failed > 0 || ctx.Err() != nil {
+		return 1
+	}
+	return 0
+}
+
+// graphSummary counts the nodes and edges written to the graph db by type
+type graphSummary struct {
+	nodes map[string]int
+	edges map[string]int
+}
+
+func newGraphSummary() *graphSummary {
+	return &graphSummary{nodes: map[string]int{}, edges: map[string]int{}}
+}
+
+// add counts the nodes and edges of g, called by the single goroutine
+// storing the graphs
+func (s *graphSummary) add(g assembler.Graph) {
+	for _, n := range g.Nodes {
+		s.nodes[n.Type()]++
+	}
+	for _, e := range g.Edges {
+		s.edges[e.Type()]++
+	}
+}
+
+// printIngestSummary prints the documents of stats and the nodes and edges of
+// written, by type
+func printIngestSummary(out io.Writer, stats pipeline.Stats, written *graphSummary, elapsed time.Duration) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "documents collected\t%d\n", stats.Collected)
+	fmt.Fprintf(w, "  ingested\t%d\n", stats.Ingested)
+	fmt.Fprintf(w, "  skipped unchanged\t%d\n", stats.Skipped)
+	fmt.Fprintf(w, "  unsupported\t%d\n", stats.Unsupported)
+	fmt.Fprintf(w, "  failed\t%d\n", stats.Failed)
+	printCounts(w, "nodes written", written.nodes)
+	printCounts(w, "edges written", written.edges)
+	fmt.Fprintf(w, "elapsed\t%v\n", elapsed.Round(time.Millisecond))
+	_ = w.Flush()
+}
+
+// printCounts prints the total of counts, then each count by type
+func printCounts(w io.Writer, title string, counts map[string]int) {
+	types := make([]string, 0, len(counts))
+	total := 0
+	for t, n := range counts {
+		types = append(types, t)
+		total += n
+	}
+	sort.Strings(types)
+	fmt.Fprintf(w, "%s\t%d\n", title, total)
+	for _, t := range types {
+		fmt.Fprintf(w, "  %s\t%d\n", t, counts[t])
+	}
+}
+
+func init() {
+	ingestFlags := ingestCmd.Flags()
+	ingestFlags.Int("processors", 1, "number of documents processed concurrently")
+	if err := viper.BindPFlag(config.Key("processors"), ingestFlags.Lookup("processors")); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to bind flag: %v", err)
+		os.Exit(1)
+	}
+	rootCmd.AddCommand(ingestCmd)
+}
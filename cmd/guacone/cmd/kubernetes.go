@@ -0,0 +1,219 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/guacsec/guac/pkg/config"
+	"github.com/guacsec/guac/pkg/handler/collector"
+	"github.com/guacsec/guac/pkg/handler/collector/kubernetes"
+	"github.com/guacsec/guac/pkg/handler/processor"
+	"github.com/guacsec/guac/pkg/handler/processor/process"
+	"github.com/guacsec/guac/pkg/ingestor/verifier"
+	"github.com/guacsec/guac/pkg/ingestor/verifier/sigstore_verifier"
+	"github.com/guacsec/guac/pkg/logging"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+type kubernetesOptions struct {
+	cfg kubernetes.Config
+}
+
+var kubernetesCmd = &cobra.Command{
+	Use:   "kubernetes [flags]",
+	Short: "watches the pods of a Kubernetes cluster to add the SBOMs and attestations of the images they run to GUAC graph",
+	Long: `watches the pods of a Kubernetes cluster to add the SBOMs and attestations of the images they run to GUAC graph.
+
+The images of the pods are collected by the digest the container runtime
+reports, as they start running, from their registry like the image command does.
+The pods are watched until the process is interrupted.
+
+Run in the cluster, the API server and the service account of the pod are used,
+which needs to be allowed to list and watch pods. Outside of the cluster, set
+--k8s-server, e.g. to http://localhost:8001 behind kubectl proxy.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, stop := signal.NotifyContext(logging.WithLogger(context.Background()), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		logger := logging.FromContext(ctx)
+		serveMetrics(ctx)
+		healthServer, watchdog := serveHealth(ctx)
+
+		opts, err := validateKubernetesFlags(guacConfig.Kubernetes)
+		if err != nil {
+			fmt.Printf("unable to validate flags: %v\n", err)
+			_ = cmd.Help()
+			os.Exit(1)
+		}
+
+		// Register Verifier
+		verifierOpts, err := sigstoreVerifierOptions()
+		if err != nil {
+			logger.Errorf("unable to load the verifier trust roots: %v", err)
+			os.Exit(1)
+		}
+		sigstoreAndKeyVerifier := sigstore_verifier.NewSigstoreAndKeyVerifier(verifierOpts...)
+		err = verifier.RegisterVerifier(sigstoreAndKeyVerifier, sigstoreAndKeyVerifier.Type())
+		if err != nil {
+			logger.Errorf("unable to register key provider: %v", err)
+		}
+
+		// Register collector
+		kubernetesCollector, err := kubernetes.NewKubernetesCollector(ctx, opts.cfg)
+		if err != nil {
+			logger.Errorf("error: %v", err)
+			os.Exit(1)
+		}
+		err = collector.RegisterDocumentCollector(kubernetesCollector, kubernetes.CollectorKubernetes)
+		if err != nil {
+			logger.Errorf("unable to register kubernetes collector: %v", err)
+		}
+
+		// Get pipeline of components
+		processorFunc, err := getProcessor(ctx)
+		if err != nil {
+			logger.Errorf("error: %v", err)
+			os.Exit(1)
+		}
+		ingestorFunc, err := getIngestor(ctx)
+		if err != nil {
+			logger.Errorf("error: %v", err)
+			os.Exit(1)
+		}
+		backend, err := getBackend(ctx, guacConfig.GraphDB)
+		if err != nil {
+			logger.Errorf("error: %v", err)
+			os.Exit(1)
+		}
+		defer backend.Close()
+		if healthServer != nil {
+			healthServer.AddReadinessCheck("graphdb", func(ctx context.Context) error {
+				return backend.Ping()
+			})
+		}
+		assemblerFunc, err := newAssembler(ctx, backend)
+		if err != nil {
+			logger.Errorf("error: %v", err)
+			os.Exit(1)
+		}
+
+		totalNum := 0
+		gotErr := false
+		// Set emit function to go through the entire pipeline
+		emit := func(d *processor.Document) error {
+			totalNum += 1
+			start := time.Now()
+
+			docTree, err := processorFunc(d)
+			if errors.Is(err, process.ErrUnsupportedDocument) {
+				// counted and reported in the summary
+				return nil
+			}
+			if err != nil {
+				gotErr = true
+				return fmt.Errorf("unable to process doc: %v, format: %v, document: %v", err, d.Format, d.Type)
+			}
+
+			graphs, err := ingestorFunc(docTree)
+			if err != nil {
+				gotErr = true
+				if len(graphs) == 0 {
+					return fmt.Errorf("unable to ingest doc tree: %v", err)
+				}
+				logger.Errorf("partially ingesting doc tree: %v", err)
+			}
+
+			storeDone := watchdog.Watch("storing graphs")
+			err = assemblerFunc(graphs)
+			storeDone()
+			if err != nil {
+				gotErr = true
+				return fmt.Errorf("unable to assemble graphs: %v", err)
+			}
+			t := time.Now()
+			elapsed := t.Sub(start)
+			logger.Infof("[%v] completed doc %+v", elapsed, d.SourceInformation)
+			return nil
+		}
+
+		// Collect
+		errHandler := func(err error) bool {
+			if err == nil {
+				logger.Info("collector ended gracefully")
+				return true
+			}
+			logger.Errorf("collector ended with error: %v", err)
+			return false
+		}
+		if healthServer != nil {
+			healthServer.MarkStarted()
+		}
+		if err := collector.CollectWithTimeout(ctx, emit, errHandler, guacConfig.Collector.Timeout); err != nil {
+			logger.Fatal(err)
+		}
+
+		process.LogUnsupportedDocuments(ctx)
+		if gotErr {
+			logger.Fatalf("completed ingestion with errors")
+		} else {
+			logger.Infof("completed ingesting %v documents", totalNum)
+		}
+	},
+}
+
+func validateKubernetesFlags(c config.Kubernetes) (kubernetesOptions, error) {
+	var opts kubernetesOptions
+
+	if c.Server == "" {
+		cfg, err := kubernetes.InClusterConfig()
+		if err != nil {
+			return opts, fmt.Errorf("expected k8s-server outside of a cluster: %w", err)
+		}
+		opts.cfg = cfg
+	} else {
+		opts.cfg.Server = c.Server
+	}
+	if c.TokenFile != "" {
+		opts.cfg.TokenFile = c.TokenFile
+	}
+	if c.CAFile != "" {
+		opts.cfg.CAFile = c.CAFile
+	}
+	opts.cfg.Namespace = c.Namespace
+	return opts, nil
+}
+
+func init() {
+	kubernetesFlags := kubernetesCmd.Flags()
+	kubernetesFlags.String("k8s-server", "", "URL of the Kubernetes API server, the cluster the command runs in if empty")
+	kubernetesFlags.String("k8s-token-file", "", "file of the bearer token to authenticate to the API server, the service account token in the cluster")
+	kubernetesFlags.String("k8s-ca-file", "", "path to CA pem file to verify the API server certificate, the service account CA in the cluster")
+	kubernetesFlags.String("k8s-namespace", "", "namespace of the pods to watch, all namespaces if empty")
+	for _, name := range []string{"k8s-server", "k8s-token-file", "k8s-ca-file", "k8s-namespace"} {
+		if err := viper.BindPFlag(name, kubernetesFlags.Lookup(name)); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to bind flag: %v", err)
+			os.Exit(1)
+		}
+	}
+	rootCmd.AddCommand(kubernetesCmd)
+}
@@ -0,0 +1,131 @@
+//
+// Copyright 2022 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/guacsec/guac/pkg/handler/collector"
+	"github.com/guacsec/guac/pkg/handler/collector/artifactrepo"
+	"github.com/guacsec/guac/pkg/handler/collector/azblob"
+	"github.com/guacsec/guac/pkg/handler/collector/file"
+	"github.com/guacsec/guac/pkg/handler/collector/gcs"
+	git_collector "github.com/guacsec/guac/pkg/handler/collector/git"
+	"github.com/guacsec/guac/pkg/handler/collector/github"
+	"github.com/guacsec/guac/pkg/handler/collector/kubernetes"
+	"github.com/guacsec/guac/pkg/handler/collector/ndjson"
+	"github.com/guacsec/guac/pkg/handler/collector/oci"
+	"github.com/guacsec/guac/pkg/handler/collector/purl"
+	"github.com/guacsec/guac/pkg/handler/collector/push"
+	"github.com/guacsec/guac/pkg/handler/collector/rekor"
+	"github.com/guacsec/guac/pkg/handler/collector/s3"
+	"github.com/guacsec/guac/pkg/handler/processor"
+	"github.com/guacsec/guac/pkg/handler/processor/process"
+	"github.com/guacsec/guac/pkg/ingestor/parser"
+	"github.com/spf13/cobra"
+)
+
+// collectorCommands are the collectors registered by the commands of guacone.
+// Collectors need their configuration to be created, so each command
+// registers its collector only when it runs.
+var collectorCommands = []struct {
+	collectorType string
+	command       string
+}{
+	{collectorType: file.FileCollector, command: "files, snapshot, export"},
+	{collectorType: file.StdinCollector, command: "files -"},
+	{collectorType: ndjson.NDJSONCollector, command: "files --ndjson"},
+	{collectorType: gcs.CollectorGCS, command: "gcs"},
+	{collectorType: azblob.CollectorAzureBlob, command: "azblob"},
+	{collectorType: s3.CollectorS3, command: "s3"},
+	{collectorType: github.CollectorGitHubRelease, command: "github-release"},
+	{collectorType: artifactrepo.CollectorArtifactory, command: "artifact-repo"},
+	{collectorType: artifactrepo.CollectorNexus, command: "artifact-repo --repo-type nexus"},
+	{collectorType: git_collector.CollectorGitDocument, command: "git"},
+	{collectorType: oci.OCICollector, command: "image"},
+	{collectorType: kubernetes.CollectorKubernetes, command: "kubernetes"},
+	{collectorType: rekor.RekorCollector, command: "rekor"},
+	{collectorType: push.CollectorPush, command: "push"},
+	{collectorType: purl.CollectorPurl, command: "purl"},
+}
+
+var listCmd = &cobra.Command{
+	Use:   "list",
+	Short: "list the collectors and parsers supported by guacone",
+}
+
+var listCollectorsCmd = &cobra.Command{
+	Use:   "collectors",
+	Short: "list the collector types, their default name and the commands using them",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "TYPE\tNAME\tCOMMAND")
+		for _, c := range collectorCommands {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", c.collectorType, collector.DefaultCollectorName(c.collectorType), c.command)
+		}
+		_ = w.Flush()
+	},
+}
+
+var listParsersCmd = &cobra.Command{
+	Use:   "parsers",
+	Short: "list the document types with the processor and parser registered for them",
+	Long: `list the document types with the processor and parser registered for them.
+
+A document is only ingested if its type has both a processor, which validates
+and unpacks it, and a parser, which turns it into graph nodes and edges. A
+missing one is shown as "-".`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		processors := process.RegisteredDocumentProcessors()
+		parsers := parser.RegisteredDocumentParsers()
+
+		types := []processor.DocumentType{}
+		for t := range processors {
+			types = append(types, t)
+		}
+		for t := range parsers {
+			if _, ok := processors[t]; !ok {
+				types = append(types, t)
+			}
+		}
+		sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "DOCUMENT TYPE\tPROCESSOR\tPARSER")
+		for _, t := range types {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", t, orDash(processors[t]), orDash(parsers[t]))
+		}
+		_ = w.Flush()
+	},
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func init() {
+	rootCmd.AddCommand(listCmd)
+	listCmd.AddCommand(listCollectorsCmd)
+	listCmd.AddCommand(listParsersCmd)
+}
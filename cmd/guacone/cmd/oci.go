@@ -17,6 +17,7 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"strings"
@@ -25,25 +26,31 @@ import (
 	"github.com/guacsec/guac/pkg/handler/collector"
 	"github.com/guacsec/guac/pkg/handler/collector/oci"
 	"github.com/guacsec/guac/pkg/handler/processor"
+	"github.com/guacsec/guac/pkg/handler/processor/process"
 	"github.com/guacsec/guac/pkg/logging"
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
 )
 
+type ociOptions struct {
+	// map of image repo and tags
+	repoTags map[string][]string
+}
+
 var ociCmd = &cobra.Command{
 	Use:   "image [flags] image_path1 image_path2...",
 	Short: "takes images to download sbom and attestation stored in OCI to add to GUAC graph",
-	Args:  cobra.MinimumNArgs(1),
+	Long: `takes images to download sbom and attestation stored in OCI to add to GUAC graph.
+
+The SBOMs and attestations attached by cosign (.sbom and .att tags) and the
+artifacts listed by the OCI referrers API are collected, except signatures.
+An image without a tag collects all the tags of the repository. Registries are
+logged into with the docker config, including its credential helpers.`,
+	Args: cobra.MinimumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		ctx := logging.WithLogger(context.Background())
 		logger := logging.FromContext(ctx)
 
-		opts, err := validateOCIFlags(
-			viper.GetString("gdbuser"),
-			viper.GetString("gdbpass"),
-			viper.GetString("gdbaddr"),
-			viper.GetString("realm"),
-			args)
+		opts, err := validateOCIFlags(args)
 		if err != nil {
 			fmt.Printf("unable to validate flags: %v\n", err)
 			_ = cmd.Help()
@@ -68,7 +75,7 @@ var ociCmd = &cobra.Command{
 			logger.Errorf("error: %v", err)
 			os.Exit(1)
 		}
-		assemblerFunc, err := getAssembler(opts)
+		assemblerFunc, err := getAssembler(ctx, guacConfig.GraphDB)
 		if err != nil {
 			logger.Errorf("error: %v", err)
 			os.Exit(1)
@@ -82,9 +89,13 @@ var ociCmd = &cobra.Command{
 			start := time.Now()
 
 			docTree, err := processorFunc(d)
+			if errors.Is(err, process.ErrUnsupportedDocument) {
+				// counted and reported in the summary
+				return nil
+			}
 			if err != nil {
 				gotErr = true
-				return fmt.Errorf("unable to process doc: %v, fomat: %v, document: %v", err, d.Format, d.Type)
+				return fmt.Errorf("unable to process doc: %v, format: %v, document: %v", err, d.Format, d.Type)
 			}
 
 			graphs, err := ingestorFunc(docTree)
@@ -110,13 +121,23 @@ var ociCmd = &cobra.Command{
 				logger.Info("collector ended gracefully")
 				return true
 			}
+			if errors.Is(err, collector.ErrCollectorTimeout) {
+				logger.Warnf("collector stopped: %v", err)
+				return true
+			}
+			if errors.Is(err, collector.ErrDocumentRead) {
+				gotErr = true
+				logger.Warnf("skipped document: %v", err)
+				return true
+			}
 			logger.Errorf("collector ended with error: %v", err)
 			return false
 		}
-		if err := collector.Collect(ctx, emit, errHandler); err != nil {
+		if err := collector.CollectWithTimeout(ctx, emit, errHandler, guacConfig.Collector.Timeout); err != nil {
 			logger.Fatal(err)
 		}
 
+		process.LogUnsupportedDocuments(ctx)
 		if gotErr {
 			logger.Fatalf("completed ingestion with errors")
 		} else {
@@ -125,12 +146,8 @@ var ociCmd = &cobra.Command{
 	},
 }
 
-func validateOCIFlags(user string, pass string, dbAddr string, realm string, args []string) (options, error) {
-	var opts options
-	opts.user = user
-	opts.pass = pass
-	opts.dbAddr = dbAddr
-	opts.realm = realm
+func validateOCIFlags(args []string) (ociOptions, error) {
+	var opts ociOptions
 	opts.repoTags = map[string][]string{}
 
 	if len(args) < 1 {
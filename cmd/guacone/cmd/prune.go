@@ -0,0 +1,130 @@
+//
+// Copyright 2022 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/guacsec/guac/pkg/assembler"
+	"github.com/guacsec/guac/pkg/assembler/graphdb"
+	"github.com/guacsec/guac/pkg/config"
+	"github.com/guacsec/guac/pkg/logging"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+type pruneOptions struct {
+	// labels of the nodes to prune
+	labels []string
+	// number of nodes deleted per transaction
+	batchSize int
+	// only count the nodes that would be deleted
+	dryRun bool
+}
+
+var pruneCmd = &cobra.Command{
+	Use:   "prune [flags]",
+	Short: "delete the nodes of the GUAC graph that have no relationships",
+	Long: `delete the nodes of the GUAC graph that have no relationships.
+
+Orphaned nodes are left behind when the documents linking them are removed,
+mostly the packages and vulnerabilities referenced by VEX documents. Only the
+nodes with one of the --prune-labels are deleted, --prune-batch-size at a time.
+With --dry-run the nodes are counted but not deleted.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := logging.WithLogger(context.Background())
+		logger := logging.FromContext(ctx)
+
+		opts, err := validatePruneFlags(guacConfig.Prune, args)
+		if err != nil {
+			fmt.Printf("unable to validate flags: %v\n", err)
+			_ = cmd.Help()
+			os.Exit(1)
+		}
+
+		authToken := graphdb.CreateAuthTokenWithUsernameAndPassword(guacConfig.GraphDB.User, guacConfig.GraphDB.Pass, guacConfig.GraphDB.Realm)
+		client, err := getGraphClient(guacConfig.GraphDB, authToken)
+		if err != nil {
+			logger.Fatalf("unable to connect to graph db: %v", err)
+		}
+		defer client.Close()
+
+		var total int64
+		for _, label := range opts.labels {
+			if opts.dryRun {
+				count, err := assembler.CountOrphanNodes(client, label)
+				if err != nil {
+					logger.Fatalf("unable to count orphaned %s nodes: %v", label, err)
+				}
+				logger.Infof("would delete %d orphaned %s nodes", count, label)
+				total += count
+				continue
+			}
+			deleted, err := assembler.DeleteOrphanNodes(client, label, opts.batchSize)
+			total += deleted
+			if err != nil {
+				logger.Fatalf("unable to delete orphaned %s nodes after deleting %d: %v", label, deleted, err)
+			}
+			logger.Infof("deleted %d orphaned %s nodes", deleted, label)
+		}
+		if opts.dryRun {
+			logger.Infof("dry run: would delete %d orphaned nodes", total)
+		} else {
+			logger.Infof("deleted %d orphaned nodes", total)
+		}
+	},
+}
+
+func validatePruneFlags(c config.Prune, args []string) (pruneOptions, error) {
+	var opts pruneOptions
+	opts.dryRun = c.DryRun
+
+	if len(args) > 0 {
+		return opts, fmt.Errorf("unexpected positional arguments: %v", args)
+	}
+	if len(c.Labels) == 0 {
+		return opts, fmt.Errorf("expected at least one label in prune-labels")
+	}
+	for _, label := range c.Labels {
+		if err := assembler.ValidateLabel(label); err != nil {
+			return opts, err
+		}
+	}
+	opts.labels = c.Labels
+
+	if c.BatchSize <= 0 {
+		return opts, fmt.Errorf("prune-batch-size must be positive")
+	}
+	opts.batchSize = c.BatchSize
+
+	return opts, nil
+}
+
+func init() {
+	pruneFlags := pruneCmd.Flags()
+	pruneFlags.StringSlice("prune-labels", assembler.DefaultPruneLabels, "labels of the nodes to delete if they have no relationships, can be repeated")
+	pruneFlags.Int("prune-batch-size", 1000, "number of nodes to delete per transaction")
+	pruneFlags.Bool("dry-run", false, "count the nodes that would be deleted without deleting them")
+	for _, name := range []string{"prune-labels", "prune-batch-size", "dry-run"} {
+		if err := viper.BindPFlag(name, pruneFlags.Lookup(name)); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to bind flag: %v", err)
+			os.Exit(1)
+		}
+	}
+	rootCmd.AddCommand(pruneCmd)
+}
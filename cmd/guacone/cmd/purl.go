@@ -0,0 +1,195 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/guacsec/guac/pkg/config"
+	"github.com/guacsec/guac/pkg/handler/collector"
+	"github.com/guacsec/guac/pkg/handler/collector/purl"
+	"github.com/guacsec/guac/pkg/handler/processor"
+	"github.com/guacsec/guac/pkg/handler/processor/process"
+	"github.com/guacsec/guac/pkg/logging"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+type purlOptions struct {
+	// purls to collect
+	purls []string
+	// addr to serve the purls endpoint on, empty to collect once
+	addr string
+}
+
+var purlCmd = &cobra.Command{
+	Use:   "purl [flags] purl...",
+	Short: "takes the metadata of packages given by their purl to add to GUAC graph",
+	Long: `takes the metadata of packages given by their purl to add to GUAC graph.
+
+For each purl, the SBOMs and attestations attached to the image of a pkg:oci
+purl are fetched from its registry, and the deps.dev and OSV certifiers are run
+on the package, e.g.
+
+  guacone purl pkg:npm/lodash@4.17.21 'pkg:oci/guac@sha256:...?repository_url=ghcr.io/guacsec/guac'
+
+With --purl-addr, guacone keeps running and collects the purls posted to the
+/purls endpoint, e.g. POST /purls?purl=pkg:pypi/django@4.1, replying once they
+are collected with the number of documents found for each purl.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, stop := signal.NotifyContext(logging.WithLogger(context.Background()), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		logger := logging.FromContext(ctx)
+
+		opts, err := validatePurlFlags(guacConfig.Purl, args)
+		if err != nil {
+			fmt.Printf("unable to validate flags: %v\n", err)
+			_ = cmd.Help()
+			os.Exit(1)
+		}
+
+		// Register collector
+		collectorOpts := []purl.Option{}
+		if opts.addr != "" {
+			collectorOpts = append(collectorOpts, purl.WithAddr(opts.addr))
+		}
+		purlCollector, err := purl.NewPurlCollector(ctx, opts.purls, collectorOpts...)
+		if err != nil {
+			logger.Errorf("error: %v", err)
+			os.Exit(1)
+		}
+		err = collector.RegisterDocumentCollector(purlCollector, purl.CollectorPurl)
+		if err != nil {
+			logger.Errorf("unable to register purl collector: %v", err)
+		}
+
+		// Get pipeline of components
+		processorFunc, err := getProcessor(ctx)
+		if err != nil {
+			logger.Errorf("error: %v", err)
+			os.Exit(1)
+		}
+		ingestorFunc, err := getIngestor(ctx)
+		if err != nil {
+			logger.Errorf("error: %v", err)
+			os.Exit(1)
+		}
+		assemblerFunc, err := getAssembler(ctx, guacConfig.GraphDB)
+		if err != nil {
+			logger.Errorf("error: %v", err)
+			os.Exit(1)
+		}
+
+		totalNum := 0
+		gotErr := false
+		// Set emit function to go through the entire pipeline
+		emit := func(d *processor.Document) error {
+			totalNum += 1
+			start := time.Now()
+
+			docTree, err := processorFunc(d)
+			if errors.Is(err, process.ErrUnsupportedDocument) {
+				// counted and reported in the summary
+				return nil
+			}
+			if err != nil {
+				gotErr = true
+				return fmt.Errorf("unable to process doc: %v, format: %v, document: %v", err, d.Format, d.Type)
+			}
+
+			graphs, err := ingestorFunc(docTree)
+			if err != nil {
+				gotErr = true
+				if len(graphs) == 0 {
+					return fmt.Errorf("unable to ingest doc tree: %v", err)
+				}
+				logger.Errorf("partially ingesting doc tree: %v", err)
+			}
+
+			err = assemblerFunc(graphs)
+			if err != nil {
+				gotErr = true
+				return fmt.Errorf("unable to assemble graphs: %v", err)
+			}
+			t := time.Now()
+			elapsed := t.Sub(start)
+			logger.Infof("[%v] completed doc %+v", elapsed, d.SourceInformation)
+			return nil
+		}
+
+		// Collect
+		errHandler := func(err error) bool {
+			if err == nil {
+				logger.Info("collector ended gracefully")
+				return true
+			}
+			if errors.Is(err, collector.ErrCollectorTimeout) {
+				logger.Warnf("collector stopped: %v", err)
+				return true
+			}
+			if errors.Is(err, collector.ErrDocumentRead) {
+				gotErr = true
+				logger.Warnf("skipped document: %v", err)
+				return true
+			}
+			logger.Errorf("collector ended with error: %v", err)
+			return false
+		}
+		if err := collector.CollectWithTimeout(ctx, emit, errHandler, guacConfig.Collector.Timeout); err != nil {
+			logger.Fatal(err)
+		}
+
+		process.LogUnsupportedDocuments(ctx)
+		if gotErr {
+			logger.Fatalf("completed ingestion with errors")
+		} else {
+			logger.Infof("completed ingesting %v documents", totalNum)
+		}
+	},
+}
+
+func validatePurlFlags(c config.Purl, args []string) (purlOptions, error) {
+	var opts purlOptions
+	opts.addr = c.Addr
+
+	if len(args) == 0 && c.Addr == "" {
+		return opts, fmt.Errorf("expected positional arguments for purls or purl-addr")
+	}
+	for _, p := range args {
+		if err := purl.ValidatePurl(p); err != nil {
+			return opts, err
+		}
+	}
+	opts.purls = args
+
+	return opts, nil
+}
+
+func init() {
+	purlFlags := purlCmd.Flags()
+	purlFlags.String("purl-addr", "", "address to serve the purls endpoint on, e.g. localhost:8090, empty to only collect the given purls")
+	if err := viper.BindPFlag("purl-addr", purlFlags.Lookup("purl-addr")); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to bind flag: %v", err)
+		os.Exit(1)
+	}
+	rootCmd.AddCommand(purlCmd)
+}
@@ -0,0 +1,237 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/guacsec/guac/pkg/config"
+	"github.com/guacsec/guac/pkg/handler/collector"
+	"github.com/guacsec/guac/pkg/handler/collector/push"
+	"github.com/guacsec/guac/pkg/handler/processor"
+	"github.com/guacsec/guac/pkg/handler/processor/process"
+	"github.com/guacsec/guac/pkg/ingestor/verifier"
+	"github.com/guacsec/guac/pkg/ingestor/verifier/sigstore_verifier"
+	"github.com/guacsec/guac/pkg/logging"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+type pushOptions struct {
+	// address to listen on
+	addr string
+	// accepted bearer tokens
+	tokens []string
+	// size limit of a pushed document in bytes
+	maxSize int64
+	// TLS certificate and key, plain HTTP if empty
+	tlsCert string
+	tlsKey  string
+}
+
+var pushCmd = &cobra.Command{
+	Use:   "push [flags]",
+	Short: "serves an endpoint for CI pipelines to push documents to add to GUAC graph",
+	Long: `serves an endpoint for CI pipelines to push documents to add to GUAC graph.
+
+Documents are pushed with POST /documents, authenticated by a bearer token given
+with --push-token (or GUAC_PUSH_TOKEN) or --push-token-file, one token per line:
+
+  curl --data-binary @sbom.json -H "Authorization: Bearer $TOKEN" \
+    "https://guac.example.com:8080/documents?source=ci/my-repo/build-42"
+
+The optional source query parameter records where the document comes from.
+Documents larger than --push-max-size bytes are rejected. The endpoint is served
+until the process is interrupted.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, stop := signal.NotifyContext(logging.WithLogger(context.Background()), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		logger := logging.FromContext(ctx)
+
+		opts, err := validatePushFlags(guacConfig.Push)
+		if err != nil {
+			fmt.Printf("unable to validate flags: %v\n", err)
+			_ = cmd.Help()
+			os.Exit(1)
+		}
+
+		// Register Verifier
+		verifierOpts, err := sigstoreVerifierOptions()
+		if err != nil {
+			logger.Errorf("unable to load the verifier trust roots: %v", err)
+			os.Exit(1)
+		}
+		sigstoreAndKeyVerifier := sigstore_verifier.NewSigstoreAndKeyVerifier(verifierOpts...)
+		err = verifier.RegisterVerifier(sigstoreAndKeyVerifier, sigstoreAndKeyVerifier.Type())
+		if err != nil {
+			logger.Errorf("unable to register key provider: %v", err)
+		}
+
+		// Register collector
+		pushOpts := []push.Option{push.WithMaxSize(opts.maxSize)}
+		if opts.tlsCert != "" || opts.tlsKey != "" {
+			pushOpts = append(pushOpts, push.WithTLS(opts.tlsCert, opts.tlsKey))
+		}
+		pushCollector, err := push.NewPushCollector(ctx, opts.addr, opts.tokens, pushOpts...)
+		if err != nil {
+			logger.Errorf("error: %v", err)
+			os.Exit(1)
+		}
+		err = collector.RegisterDocumentCollector(pushCollector, push.CollectorPush)
+		if err != nil {
+			logger.Errorf("unable to register push collector: %v", err)
+		}
+
+		// Get pipeline of components
+		processorFunc, err := getProcessor(ctx)
+		if err != nil {
+			logger.Errorf("error: %v", err)
+			os.Exit(1)
+		}
+		ingestorFunc, err := getIngestor(ctx)
+		if err != nil {
+			logger.Errorf("error: %v", err)
+			os.Exit(1)
+		}
+		assemblerFunc, err := getAssembler(ctx, guacConfig.GraphDB)
+		if err != nil {
+			logger.Errorf("error: %v", err)
+			os.Exit(1)
+		}
+
+		totalNum := 0
+		gotErr := false
+		// Set emit function to go through the entire pipeline
+		emit := func(d *processor.Document) error {
+			totalNum += 1
+			start := time.Now()
+
+			docTree, err := processorFunc(d)
+			if errors.Is(err, process.ErrUnsupportedDocument) {
+				// counted and reported in the summary
+				return nil
+			}
+			if err != nil {
+				gotErr = true
+				return fmt.Errorf("unable to process doc: %v, format: %v, document: %v", err, d.Format, d.Type)
+			}
+
+			graphs, err := ingestorFunc(docTree)
+			if err != nil {
+				gotErr = true
+				if len(graphs) == 0 {
+					return fmt.Errorf("unable to ingest doc tree: %v", err)
+				}
+				logger.Errorf("partially ingesting doc tree: %v", err)
+			}
+
+			err = assemblerFunc(graphs)
+			if err != nil {
+				gotErr = true
+				return fmt.Errorf("unable to assemble graphs: %v", err)
+			}
+			t := time.Now()
+			elapsed := t.Sub(start)
+			logger.Infof("[%v] completed doc %+v", elapsed, d.SourceInformation)
+			return nil
+		}
+
+		// Collect
+		errHandler := func(err error) bool {
+			if err == nil {
+				logger.Info("collector ended gracefully")
+				return true
+			}
+			logger.Errorf("collector ended with error: %v", err)
+			return false
+		}
+		if err := collector.CollectWithTimeout(ctx, emit, errHandler, guacConfig.Collector.Timeout); err != nil {
+			logger.Fatal(err)
+		}
+
+		process.LogUnsupportedDocuments(ctx)
+		if gotErr {
+			logger.Fatalf("completed ingestion with errors")
+		} else {
+			logger.Infof("completed ingesting %v documents", totalNum)
+		}
+	},
+}
+
+func validatePushFlags(c config.Push) (pushOptions, error) {
+	var opts pushOptions
+	opts.addr = c.Addr
+	opts.maxSize = c.MaxSize
+	opts.tlsCert = c.TLSCert
+	opts.tlsKey = c.TLSKey
+
+	for _, token := range c.Tokens {
+		if token = strings.TrimSpace(token); token != "" {
+			opts.tokens = append(opts.tokens, token)
+		}
+	}
+	if c.TokenFile != "" {
+		f, err := os.Open(c.TokenFile)
+		if err != nil {
+			return opts, fmt.Errorf("failed to open push-token-file: %w", err)
+		}
+		defer f.Close()
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			if token := strings.TrimSpace(scanner.Text()); token != "" && !strings.HasPrefix(token, "#") {
+				opts.tokens = append(opts.tokens, token)
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return opts, fmt.Errorf("failed to read push-token-file: %w", err)
+		}
+	}
+	if len(opts.tokens) == 0 {
+		return opts, errors.New("expected push-token or push-token-file to authenticate the pushed documents")
+	}
+	if c.MaxSize <= 0 {
+		return opts, errors.New("push-max-size must be positive")
+	}
+	if (c.TLSCert == "") != (c.TLSKey == "") {
+		return opts, errors.New("expected both push-tls-cert and push-tls-key")
+	}
+	return opts, nil
+}
+
+func init() {
+	pushFlags := pushCmd.Flags()
+	pushFlags.String("push-addr", ":8080", "address to listen on for pushed documents")
+	pushFlags.StringSlice("push-token", nil, "bearer token accepted to push documents, can be repeated")
+	pushFlags.String("push-token-file", "", "file of the bearer tokens accepted to push documents, one per line")
+	pushFlags.Int64("push-max-size", push.DefaultMaxSize, "size limit of a pushed document in bytes")
+	pushFlags.String("push-tls-cert", "", "path to certificate pem file to serve the endpoint over TLS")
+	pushFlags.String("push-tls-key", "", "path to key pem file to serve the endpoint over TLS")
+	for _, name := range []string{"push-addr", "push-token", "push-token-file", "push-max-size", "push-tls-cert", "push-tls-key"} {
+		if err := viper.BindPFlag(name, pushFlags.Lookup(name)); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to bind flag: %v", err)
+			os.Exit(1)
+		}
+	}
+	rootCmd.AddCommand(pushCmd)
+}
@@ -0,0 +1,370 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/guacsec/guac/pkg/assembler"
+	"github.com/guacsec/guac/pkg/config"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const (
+	queryOutputTable = "table"
+	queryOutputJSON  = "json"
+)
+
+type queryOptions struct {
+	// output format, table or json
+	output string
+	// maximum number of nodes of a list
+	limit int
+	// maximum number of edges of a path
+	pathDepth int
+	// edges followed by a path
+	pathEdges []string
+}
+
+var queryCmd = &cobra.Command{
+	Use:   "query",
+	Short: "answers common questions about a package or an artifact of the GUAC graph",
+	Long: `answers common questions about a package or an artifact of the GUAC graph.
+
+The subject of a question is a package if it is a purl, and an artifact with
+that digest otherwise:
+
+  guacone query deps pkg:npm/lodash@4.17.21
+  guacone query vulns sha256:8b5e1a...
+  guacone query provenance sha256:8b5e1a... --query-output json
+  guacone query path sha256:8b5e1a... pkg:golang/golang.org/x/text@v0.3.7
+
+The evidence of an edge lists the documents asserting it, with the collector,
+source and time each was collected:
+
+  guacone query evidence pkg:oci/app pkg:golang/golang.org/x/text@v0.3.7 DependsOn
+
+A document is valid until it is superseded by a later document of the same
+source, e.g. a re-scan of an image. The edges of a subject believed at some
+time, RFC 3339 or a date, are the ones asserted by a document valid then:
+
+  guacone query beliefs sha256:8b5e1a... 2023-03-01
+
+The blast radius of a vulnerability, by ID or alias, lists the products to
+rebuild with the dependency chains from each to the affected packages:
+
+  guacone query blast-radius CVE-2022-32149`,
+}
+
+// queryAnswer returns the answer of a query subcommand to its arguments, and
+// writes it as a table to w
+type queryAnswer func(reader assembler.GraphReader, args []string, opts queryOptions) (interface{}, func(w io.Writer), error)
+
+// subjectAnswer returns the answer of a query subcommand about the packages
+// or artifacts named by its arguments, and writes it as a table to w
+type subjectAnswer func(reader assembler.GraphReader, subjects []assembler.StoredNode, opts queryOptions) (interface{}, func(w io.Writer), error)
+
+func newQuerySubcommand(use string, short string, answer queryAnswer) *cobra.Command {
+	return &cobra.Command{
+		Use:   use,
+		Short: short,
+		Args:  cobra.ExactArgs(strings.Count(use, "<")),
+		Run: func(cmd *cobra.Command, args []string) {
+			opts, err := validateQueryFlags(guacConfig.Query)
+			if err != nil {
+				fmt.Printf("unable to validate flags: %v\n", err)
+				_ = cmd.Help()
+				os.Exit(1)
+			}
+
+			backend, err := getBackend(context.Background(), guacConfig.GraphDB)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "unable to connect to graph db: %v\n", err)
+				os.Exit(1)
+			}
+			defer backend.Close()
+
+			if err := runQuery(os.Stdout, backend, args, opts, answer); err != nil {
+				fmt.Fprintf(os.Stderr, "query failed: %v\n", err)
+				os.Exit(1)
+			}
+		},
+	}
+}
+
+// aboutSubjects returns the answer about the packages or artifacts named by
+// the arguments
+func aboutSubjects(answer subjectAnswer) queryAnswer {
+	return func(reader assembler.GraphReader, args []string, opts queryOptions) (interface{}, func(w io.Writer), error) {
+		subjects, err := findSubjects(reader, args)
+		if err != nil {
+			return nil, nil, err
+		}
+		return answer(reader, subjects, opts)
+	}
+}
+
+// findSubjects returns the packages or artifacts named by keys
+func findSubjects(reader assembler.GraphReader, keys []string) ([]assembler.StoredNode, error) {
+	subjects := []assembler.StoredNode{}
+	for _, key := range keys {
+		n, err := assembler.FindSubject(reader, key)
+		if errors.Is(err, assembler.ErrNodeNotFound) {
+			return nil, fmt.Errorf("no package or artifact %s in the graph", key)
+		}
+		if err != nil {
+			return nil, err
+		}
+		subjects = append(subjects, n)
+	}
+	return subjects, nil
+}
+
+// runQuery answers the question asked by args, writing the answer to w in
+// the output format of opts
+func runQuery(w io.Writer, reader assembler.GraphReader, args []string, opts queryOptions, answer queryAnswer) error {
+	result, table, err := answer(reader, args, opts)
+	if err != nil {
+		return err
+	}
+	if opts.output == queryOutputJSON {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(result)
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	table(tw)
+	return tw.Flush()
+}
+
+func answerDependencies(reader assembler.GraphReader, subjects []assembler.StoredNode, opts queryOptions) (interface{}, func(w io.Writer), error) {
+	deps, err := assembler.Dependencies(reader, subjects[0], opts.limit)
+	return deps, nodesTable(deps), err
+}
+
+func answerDependents(reader assembler.GraphReader, subjects []assembler.StoredNode, opts queryOptions) (interface{}, func(w io.Writer), error) {
+	deps, err := assembler.Dependents(reader, subjects[0], opts.limit)
+	return deps, nodesTable(deps), err
+}
+
+func nodesTable(nodes []assembler.StoredNode) func(w io.Writer) {
+	return func(w io.Writer) {
+		fmt.Fprintln(w, "NODE")
+		for _, n := range nodes {
+			fmt.Fprintln(w, n)
+		}
+	}
+}
+
+func answerVulnerabilities(reader assembler.GraphReader, subjects []assembler.StoredNode, opts queryOptions) (interface{}, func(w io.Writer), error) {
+	vulns, err := assembler.NodeVulnerabilities(reader, subjects[0], opts.limit)
+	return vulns, func(w io.Writer) {
+		fmt.Fprintln(w, "VULNERABILITY\tALIASES\tSTATE\tSOURCE")
+		for _, v := range vulns {
+			state, source := orDash(propertyString(v.Edge["analysis_state"])), "VEX"
+			if v.Attestation != nil {
+				source = v.Attestation.String()
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", propertyString(v.Vulnerability.Properties["id"]),
+				orDash(propertyString(v.Vulnerability.Properties["aliases"])), state, source)
+		}
+	}, err
+}
+
+func answerProvenance(reader assembler.GraphReader, subjects []assembler.StoredNode, opts queryOptions) (interface{}, func(w io.Writer), error) {
+	provenances, err := assembler.NodeProvenance(reader, subjects[0], opts.limit)
+	return provenances, func(w io.Writer) {
+		fmt.Fprintln(w, "ATTESTATION\tPREDICATE TYPE\tBUILDERS\tSIGNERS")
+		for _, p := range provenances {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", propertyString(p.Attestation.Properties["digest"]),
+				propertyString(p.Attestation.Properties["attestation_type"]),
+				orDash(nodeProperties(p.Builders, "id")), orDash(nodeProperties(p.Signers, "id")))
+		}
+	}, err
+}
+
+func answerPaths(reader assembler.GraphReader, subjects []assembler.StoredNode, opts queryOptions) (interface{}, func(w io.Writer), error) {
+	paths, err := reader.Paths(subjects[0].ID, subjects[1].ID, opts.pathEdges, opts.pathDepth, opts.limit)
+	return paths, func(w io.Writer) {
+		fmt.Fprintln(w, "LENGTH\tPATH")
+		for _, p := range paths {
+			fmt.Fprintf(w, "%d\t%s\n", len(p.Edges), p)
+		}
+	}, err
+}
+
+func answerEvidence(reader assembler.GraphReader, args []string, opts queryOptions) (interface{}, func(w io.Writer), error) {
+	if err := assembler.ValidateLabel(args[2]); err != nil {
+		return nil, nil, err
+	}
+	subjects, err := findSubjects(reader, args[:2])
+	if err != nil {
+		return nil, nil, err
+	}
+	docs, err := assembler.EdgeEvidence(reader, subjects[0], subjects[1], args[2], opts.limit)
+	if errors.Is(err, assembler.ErrEdgeNotFound) {
+		return nil, nil, fmt.Errorf("no %s edge from %s to %s in the graph", args[2], args[0], args[1])
+	}
+	return docs, func(w io.Writer) {
+		fmt.Fprintln(w, "DOCUMENT\tCOLLECTOR\tSOURCE\tCOLLECTED AT")
+		for _, d := range docs {
+			source := d.Properties["source_uri"]
+			if source == nil {
+				source = d.Properties["source"]
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", propertyString(d.Properties["digest"]),
+				orDash(propertyString(d.Properties["collector"])), orDash(propertyString(source)),
+				orDash(propertyString(d.Properties["collected_at"])))
+		}
+	}, err
+}
+
+func answerBeliefs(reader assembler.GraphReader, args []string, opts queryOptions) (interface{}, func(w io.Writer), error) {
+	at, err := parseQueryTime(args[1])
+	if err != nil {
+		return nil, nil, err
+	}
+	subjects, err := findSubjects(reader, args[:1])
+	if err != nil {
+		return nil, nil, err
+	}
+	beliefs, err := assembler.BeliefsAt(reader, subjects[0], at, opts.limit)
+	return beliefs, func(w io.Writer) {
+		fmt.Fprintln(w, "EDGE\tNODE\tSTATE\tDOCUMENTS")
+		for _, b := range beliefs {
+			edge := "-" + b.Edge + "->"
+			if b.Direction == assembler.DirectionIn {
+				edge = "<-" + b.Edge + "-"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", edge, b.Node,
+				orDash(propertyString(b.EdgeProperties["analysis_state"])), nodeProperties(b.Documents, "digest"))
+		}
+	}, err
+}
+
+// parseQueryTime parses t as an RFC 3339 time, or as a date meaning the start
+// of the day in UTC
+func parseQueryTime(t string) (time.Time, error) {
+	if parsed, err := time.Parse(time.RFC3339, t); err == nil {
+		return parsed, nil
+	}
+	parsed, err := time.Parse("2006-01-02", t)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q, expected an RFC 3339 time or a date", t)
+	}
+	return parsed, nil
+}
+
+func answerBlastRadius(reader assembler.GraphReader, args []string, opts queryOptions) (interface{}, func(w io.Writer), error) {
+	radius, err := assembler.VulnerabilityBlastRadius(reader, args[0], opts.pathDepth, opts.limit)
+	if errors.Is(err, assembler.ErrNodeNotFound) {
+		return nil, nil, fmt.Errorf("no vulnerability %s in the graph", args[0])
+	}
+	return radius, func(w io.Writer) {
+		fmt.Fprintln(w, "AFFECTED")
+		for _, n := range radius.Affected {
+			fmt.Fprintln(w, n)
+		}
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "PRODUCT\tCHAIN")
+		for _, p := range radius.Products {
+			for _, chain := range p.Chains {
+				fmt.Fprintf(w, "%s\t%s\n", p.Product, chain)
+			}
+		}
+	}, err
+}
+
+// propertyString returns the property value v as a string, the values of a
+// list being separated by commas
+func propertyString(v interface{}) string {
+	switch value := v.(type) {
+	case nil:
+		return ""
+	case []interface{}:
+		values := make([]string, 0, len(value))
+		for _, e := range value {
+			values = append(values, fmt.Sprint(e))
+		}
+		return strings.Join(values, ",")
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+// nodeProperties returns the property key of nodes, separated by commas
+func nodeProperties(nodes []assembler.StoredNode, key string) string {
+	values := make([]string, 0, len(nodes))
+	for _, n := range nodes {
+		values = append(values, propertyString(n.Properties[key]))
+	}
+	return strings.Join(values, ",")
+}
+
+func validateQueryFlags(c config.Query) (queryOptions, error) {
+	var opts queryOptions
+	if c.Output != queryOutputTable && c.Output != queryOutputJSON {
+		return opts, fmt.Errorf("query-output must be %s or %s, got %q", queryOutputTable, queryOutputJSON, c.Output)
+	}
+	if c.Limit < 0 {
+		return opts, fmt.Errorf("query-limit must not be negative, got %d", c.Limit)
+	}
+	if c.PathDepth < 0 || c.PathDepth > assembler.MaxPathDepth {
+		return opts, fmt.Errorf("query-path-depth must be between 0 and %d, got %d", assembler.MaxPathDepth, c.PathDepth)
+	}
+	for _, edge := range c.PathEdges {
+		if err := assembler.ValidateLabel(edge); err != nil {
+			return opts, fmt.Errorf("invalid query-path-edges: %w", err)
+		}
+	}
+	opts.output = c.Output
+	opts.limit = c.Limit
+	opts.pathDepth = c.PathDepth
+	opts.pathEdges = c.PathEdges
+	return opts, nil
+}
+
+func init() {
+	queryFlags := queryCmd.PersistentFlags()
+	queryFlags.String("query-output", queryOutputTable, "output format of the answers, table or json")
+	queryFlags.Int("query-limit", assembler.DefaultQueryLimit, "maximum number of nodes returned in a list")
+	queryFlags.Int("query-path-depth", assembler.DefaultPathDepth, "maximum number of edges of a path")
+	queryFlags.StringSlice("query-path-edges", assembler.DependencyEdges, "edges followed by a path")
+	for _, name := range []string{"query-output", "query-limit", "query-path-depth", "query-path-edges"} {
+		if err := viper.BindPFlag(name, queryFlags.Lookup(name)); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to bind flag: %v", err)
+			os.Exit(1)
+		}
+	}
+	queryCmd.AddCommand(newQuerySubcommand("deps <purl|digest>", "lists the packages or artifacts the subject directly depends on", aboutSubjects(answerDependencies)))
+	queryCmd.AddCommand(newQuerySubcommand("dependents <purl|digest>", "lists the packages or artifacts directly depending on the subject", aboutSubjects(answerDependents)))
+	queryCmd.AddCommand(newQuerySubcommand("vulns <purl|digest>", "lists the vulnerabilities affecting the subject", aboutSubjects(answerVulnerabilities)))
+	queryCmd.AddCommand(newQuerySubcommand("provenance <purl|digest>", "lists the attestations about the subject with their builders and signers", aboutSubjects(answerProvenance)))
+	queryCmd.AddCommand(newQuerySubcommand("path <from> <to>", "lists the dependency paths from a package or artifact to another, shortest first", aboutSubjects(answerPaths)))
+	queryCmd.AddCommand(newQuerySubcommand("evidence <from> <to> <edge>", "lists the documents asserting the edge from a package or artifact to another", answerEvidence))
+	queryCmd.AddCommand(newQuerySubcommand("beliefs <purl|digest> <time>", "lists the edges of the subject asserted by the documents valid at a time", answerBeliefs))
+	queryCmd.AddCommand(newQuerySubcommand("blast-radius <vulnerability-id>", "lists the packages and artifacts affected by a vulnerability, and the products depending on them", answerBlastRadius))
+	rootCmd.AddCommand(queryCmd)
+}
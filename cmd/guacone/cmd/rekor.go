@@ -0,0 +1,271 @@
+//
+// Copyright 2022 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"time"
+
+	"github.com/guacsec/guac/pkg/assembler/graphdb"
+	"github.com/guacsec/guac/pkg/config"
+	"github.com/guacsec/guac/pkg/handler/collector"
+	"github.com/guacsec/guac/pkg/handler/collector/rekor"
+	"github.com/guacsec/guac/pkg/handler/processor"
+	"github.com/guacsec/guac/pkg/handler/processor/process"
+	"github.com/guacsec/guac/pkg/ingestor/verifier"
+	"github.com/guacsec/guac/pkg/ingestor/verifier/sigstore_verifier"
+	"github.com/guacsec/guac/pkg/logging"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+type rekorOptions struct {
+	// url of the rekor instance
+	url string
+	// entries to collect
+	query rekor.Query
+	// interval to poll rekor at, 0 to collect once
+	pollInterval time.Duration
+	// restrict the entries to the artifacts of the graph
+	graphSubjects bool
+}
+
+var rekorCmd = &cobra.Command{
+	Use:   "rekor [flags]",
+	Short: "takes the attestations of entries of a Rekor transparency log to add to GUAC graph",
+	Long: `takes the attestations of entries of a Rekor transparency log to add to GUAC graph.
+
+Entries are selected by subject digest with --rekor-digest, by the time they
+were integrated in the log with --rekor-since and --rekor-until (RFC 3339), or
+from now on with --rekor-tail. With --rekor-poll-interval, new entries are
+collected as they are added. Entries without an inline attestation are skipped.
+
+Entries of a time range or of the tail can be restricted to those with a subject
+name matching --rekor-subject, or with --rekor-graph-subjects to those about an
+artifact already in the graph. --rekor-kind selects the kinds of entries
+collected: intoto, dsse and hashedrekord, whose artifact digest is collected as
+an in-toto statement.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := logging.WithLogger(context.Background())
+		logger := logging.FromContext(ctx)
+
+		opts, err := validateRekorFlags(guacConfig.Rekor)
+		if err != nil {
+			fmt.Printf("unable to validate flags: %v\n", err)
+			_ = cmd.Help()
+			os.Exit(1)
+		}
+
+		// Register Verifier
+		verifierOpts, err := sigstoreVerifierOptions()
+		if err != nil {
+			logger.Errorf("unable to load the verifier trust roots: %v", err)
+			os.Exit(1)
+		}
+		sigstoreAndKeyVerifier := sigstore_verifier.NewSigstoreAndKeyVerifier(verifierOpts...)
+		err = verifier.RegisterVerifier(sigstoreAndKeyVerifier, sigstoreAndKeyVerifier.Type())
+		if err != nil {
+			logger.Errorf("unable to register key provider: %v", err)
+		}
+
+		if opts.graphSubjects {
+			authToken := graphdb.CreateAuthTokenWithUsernameAndPassword(guacConfig.GraphDB.User, guacConfig.GraphDB.Pass, guacConfig.GraphDB.Realm)
+			client, err := graphdb.NewGraphClient(guacConfig.GraphDB.Addr, authToken)
+			if err != nil {
+				logger.Errorf("error: %v", err)
+				os.Exit(1)
+			}
+			opts.query.KnownSubjects = func(ctx context.Context) ([]string, error) {
+				return getArtifactDigests(client)
+			}
+		}
+
+		// Register collector
+		rekorCollector, err := rekor.NewRekorCollector(ctx, opts.url, opts.query, opts.pollInterval > 0, opts.pollInterval)
+		if err != nil {
+			logger.Errorf("error: %v", err)
+			os.Exit(1)
+		}
+		err = collector.RegisterDocumentCollector(rekorCollector, rekor.RekorCollector)
+		if err != nil {
+			logger.Errorf("unable to register rekor collector: %v", err)
+		}
+
+		// Get pipeline of components
+		processorFunc, err := getProcessor(ctx)
+		if err != nil {
+			logger.Errorf("error: %v", err)
+			os.Exit(1)
+		}
+		ingestorFunc, err := getIngestor(ctx)
+		if err != nil {
+			logger.Errorf("error: %v", err)
+			os.Exit(1)
+		}
+		assemblerFunc, err := getAssembler(ctx, guacConfig.GraphDB)
+		if err != nil {
+			logger.Errorf("error: %v", err)
+			os.Exit(1)
+		}
+
+		totalNum := 0
+		gotErr := false
+		// Set emit function to go through the entire pipeline
+		emit := func(d *processor.Document) error {
+			totalNum += 1
+			start := time.Now()
+
+			docTree, err := processorFunc(d)
+			if errors.Is(err, process.ErrUnsupportedDocument) {
+				// counted and reported in the summary
+				return nil
+			}
+			if err != nil {
+				gotErr = true
+				return fmt.Errorf("unable to process doc: %v, format: %v, document: %v", err, d.Format, d.Type)
+			}
+
+			graphs, err := ingestorFunc(docTree)
+			if err != nil {
+				gotErr = true
+				if len(graphs) == 0 {
+					return fmt.Errorf("unable to ingest doc tree: %v", err)
+				}
+				logger.Errorf("partially ingesting doc tree: %v", err)
+			}
+
+			err = assemblerFunc(graphs)
+			if err != nil {
+				gotErr = true
+				return fmt.Errorf("unable to assemble graphs: %v", err)
+			}
+			t := time.Now()
+			elapsed := t.Sub(start)
+			logger.Infof("[%v] completed doc %+v", elapsed, d.SourceInformation)
+			return nil
+		}
+
+		// Collect
+		errHandler := func(err error) bool {
+			if err == nil {
+				logger.Info("collector ended gracefully")
+				return true
+			}
+			if errors.Is(err, collector.ErrCollectorTimeout) {
+				logger.Warnf("collector stopped: %v", err)
+				return true
+			}
+			if errors.Is(err, collector.ErrDocumentRead) {
+				gotErr = true
+				logger.Warnf("skipped document: %v", err)
+				return true
+			}
+			logger.Errorf("collector ended with error: %v", err)
+			return false
+		}
+		if err := collector.CollectWithTimeout(ctx, emit, errHandler, guacConfig.Collector.Timeout); err != nil {
+			logger.Fatal(err)
+		}
+
+		process.LogUnsupportedDocuments(ctx)
+		if gotErr {
+			logger.Fatalf("completed ingestion with errors")
+		} else {
+			logger.Infof("completed ingesting %v documents", totalNum)
+		}
+	},
+}
+
+func validateRekorFlags(c config.Rekor) (rekorOptions, error) {
+	var opts rekorOptions
+	opts.url = c.URL
+	opts.query.Digests = c.Digests
+	opts.query.Tail = c.Tail
+	opts.query.Kinds = c.Kinds
+	opts.graphSubjects = c.GraphSubjects
+
+	if len(c.Digests) == 0 && c.Since == "" && !c.Tail {
+		return opts, errors.New("expected rekor-digest, rekor-since or rekor-tail to select the entries")
+	}
+	if c.Subject != "" {
+		re, err := regexp.Compile(c.Subject)
+		if err != nil {
+			return opts, fmt.Errorf("invalid rekor-subject: %w", err)
+		}
+		opts.query.Subjects = re
+	}
+	if c.Since != "" {
+		t, err := time.Parse(time.RFC3339, c.Since)
+		if err != nil {
+			return opts, fmt.Errorf("invalid rekor-since: %w", err)
+		}
+		opts.query.Since = t
+	}
+	if c.Until != "" {
+		t, err := time.Parse(time.RFC3339, c.Until)
+		if err != nil {
+			return opts, fmt.Errorf("invalid rekor-until: %w", err)
+		}
+		opts.query.Until = t
+	}
+
+	if c.PollInterval < 0 {
+		return opts, fmt.Errorf("rekor-poll-interval must not be negative")
+	}
+	opts.pollInterval = c.PollInterval
+
+	return opts, nil
+}
+
+// getArtifactDigests returns the digests of the artifacts of the graph
+func getArtifactDigests(client graphdb.Client) ([]string, error) {
+	results, err := graphdb.ReadQuery(client, "MATCH (a:Artifact) RETURN a.digest", nil)
+	if err != nil {
+		return nil, err
+	}
+	digests := []string{}
+	for _, result := range results {
+		if digest, ok := result.(string); ok && digest != "" {
+			digests = append(digests, digest)
+		}
+	}
+	return digests, nil
+}
+
+func init() {
+	rekorFlags := rekorCmd.Flags()
+	rekorFlags.String("rekor-url", rekor.DefaultRekorURL, "url of the Rekor instance")
+	rekorFlags.StringSlice("rekor-digest", nil, "subject digests (e.g. sha256:...) to collect the entries of")
+	rekorFlags.String("rekor-since", "", "collect the entries integrated at or after this time (RFC 3339)")
+	rekorFlags.String("rekor-until", "", "collect the entries integrated up to this time (RFC 3339), the latest if empty")
+	rekorFlags.Bool("rekor-tail", false, "collect the entries integrated from now on, to use with rekor-poll-interval")
+	rekorFlags.StringSlice("rekor-kind", rekor.SupportedKinds, "kinds of entries to collect")
+	rekorFlags.String("rekor-subject", "", "regular expression the subject name of the entries of a time range or of the tail must match")
+	rekorFlags.Bool("rekor-graph-subjects", false, "collect the entries of a time range or of the tail about an artifact of the graph")
+	rekorFlags.Duration("rekor-poll-interval", 0, "interval to poll Rekor for new entries, 0 to collect once")
+	for _, name := range []string{"rekor-url", "rekor-digest", "rekor-since", "rekor-until", "rekor-tail", "rekor-kind", "rekor-subject",
+		"rekor-graph-subjects", "rekor-poll-interval"} {
+		if err := viper.BindPFlag(name, rekorFlags.Lookup(name)); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to bind flag: %v", err)
+			os.Exit(1)
+		}
+	}
+	rootCmd.AddCommand(rekorCmd)
+}
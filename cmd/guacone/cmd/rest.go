@@ -0,0 +1,126 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/guacsec/guac/pkg/assembler"
+	"github.com/guacsec/guac/pkg/config"
+	"github.com/guacsec/guac/pkg/logging"
+	"github.com/guacsec/guac/pkg/rest"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+type restOptions struct {
+	// address to listen on
+	addr string
+	// maximum number of nodes of a list
+	limit int
+}
+
+var restCmd = &cobra.Command{
+	Use:   "rest [flags]",
+	Short: "serves a REST API answering common questions about the GUAC graph",
+	Long: `serves a REST API answering common questions about the GUAC graph, for
+dashboards and CI gates that do not speak Cypher or GraphQL:
+
+  GET /artifacts/{digest}                   the artifact
+  GET /artifacts/{digest}/provenance        its attestations with builders and signers
+  GET /artifacts/{digest}/vulnerabilities   the vulnerabilities affecting it
+  GET /packages/{purl}                      the package
+  GET /packages/{purl}/vulnerabilities      the vulnerabilities affecting it
+  GET /packages/{purl}/dependencies         the packages it depends on
+  GET /packages/{purl}/dependents           the packages depending on it
+  GET /paths?from={purl|digest}&to={purl|digest}[&depth=N][&edges=E1,E2]
+                                            the dependency paths between two nodes,
+                                            shortest first
+  GET /vulnerabilities/{id}/blast-radius[?depth=N]
+                                            the nodes affected by a vulnerability, and the
+                                            products depending on them with the chains
+
+The responses are JSON, and unknown artifacts or packages return 404:
+
+  curl http://localhost:8081/packages/pkg:golang%2Fgolang.org%2Fx%2Ftext@v0.3.7/vulnerabilities
+
+The server runs until the process is interrupted.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, stop := signal.NotifyContext(logging.WithLogger(context.Background()), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		logger := logging.FromContext(ctx)
+		serveMetrics(ctx)
+		healthServer, _ := serveHealth(ctx)
+
+		opts, err := validateRESTFlags(guacConfig.REST, args)
+		if err != nil {
+			fmt.Printf("unable to validate flags: %v\n", err)
+			_ = cmd.Help()
+			os.Exit(1)
+		}
+
+		backend, err := getBackend(ctx, guacConfig.GraphDB)
+		if err != nil {
+			logger.Fatalf("unable to connect to graph db: %v", err)
+		}
+		defer backend.Close()
+		if healthServer != nil {
+			healthServer.AddReadinessCheck("graphdb", func(ctx context.Context) error {
+				return backend.Ping()
+			})
+			healthServer.MarkStarted()
+		}
+
+		logger.Infof("serving the REST API on %s", opts.addr)
+		if err := serveHTTP(ctx, opts.addr, rest.Handler(ctx, backend, opts.limit)); err != nil {
+			logger.Fatalf("REST server failed: %v", err)
+		}
+	},
+}
+
+func validateRESTFlags(c config.REST, args []string) (restOptions, error) {
+	var opts restOptions
+	if len(args) > 0 {
+		return opts, fmt.Errorf("unexpected positional arguments: %v", args)
+	}
+	if c.Addr == "" {
+		return opts, errors.New("rest-addr must be set")
+	}
+	if c.Limit < 0 {
+		return opts, fmt.Errorf("rest-limit must not be negative, got %d", c.Limit)
+	}
+	opts.addr = c.Addr
+	opts.limit = c.Limit
+	return opts, nil
+}
+
+func init() {
+	restFlags := restCmd.Flags()
+	restFlags.String("rest-addr", ":8081", "address to serve the REST API on")
+	restFlags.Int("rest-limit", assembler.DefaultQueryLimit, "maximum number of nodes returned in a list")
+	for _, name := range []string{"rest-addr", "rest-limit"} {
+		if err := viper.BindPFlag(name, restFlags.Lookup(name)); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to bind flag: %v", err)
+			os.Exit(1)
+		}
+	}
+	rootCmd.AddCommand(restCmd)
+}
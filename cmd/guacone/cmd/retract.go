@@ -0,0 +1,77 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"os"
+
+	"github.com/guacsec/guac/pkg/assembler"
+	"github.com/guacsec/guac/pkg/logging"
+	"github.com/spf13/cobra"
+)
+
+var retractCmd = &cobra.Command{
+	Use:   "retract [flags] <document-digest>...",
+	Short: "removes ingested documents and what only they asserted from the GUAC graph",
+	Long: `removes ingested documents and what only they asserted from the GUAC graph.
+
+Every ingested document is stored as a Document node identified by the sha256
+digest of its contents, and the digests of the documents asserting a node or
+an edge are kept in its documents property. Retracting a document deletes its
+Document node and the nodes and edges it was the only one to assert. The
+nodes and edges also asserted by other documents are kept, without the
+retracted document in their documents property. Nodes still linked by edges
+are kept as well. Nodes and edges stored before documents were recorded are
+left untouched, see "guacone prune" to delete the orphaned nodes.
+
+The digests of the documents asserting an edge are listed by
+"guacone query evidence".`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := logging.WithLogger(context.Background())
+		logger := logging.FromContext(ctx)
+
+		backend, err := getBackend(ctx, guacConfig.GraphDB)
+		if err != nil {
+			logger.Fatalf("unable to connect to graph db: %v", err)
+		}
+		defer backend.Close()
+
+		failed := false
+		for _, digest := range args {
+			retraction, err := backend.RetractDocument(ctx, digest)
+			if errors.Is(err, assembler.ErrNodeNotFound) {
+				logger.Errorf("document %s was not ingested", digest)
+				failed = true
+				continue
+			}
+			if err != nil {
+				logger.Fatalf("unable to retract document %s: %v", digest, err)
+			}
+			logger.Infof("retracted document %s: %v", digest, retraction)
+		}
+		if failed {
+			backend.Close()
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(retractCmd)
+}
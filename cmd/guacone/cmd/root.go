@@ -19,48 +19,91 @@ import (
 	"context"
 	"fmt"
 	"os"
-
+	"strings"
+	"time"
+
+	"github.com/guacsec/guac/pkg/assembler"
+	"github.com/guacsec/guac/pkg/config"
+	"github.com/guacsec/guac/pkg/handler/collector"
+	"github.com/guacsec/guac/pkg/handler/collector/retry"
+	"github.com/guacsec/guac/pkg/handler/processor/hashcache"
+	"github.com/guacsec/guac/pkg/handler/processor/process"
+	"github.com/guacsec/guac/pkg/health"
+	"github.com/guacsec/guac/pkg/ingestor/key/kms"
+	"github.com/guacsec/guac/pkg/ingestor/key/tuf"
+	"github.com/guacsec/guac/pkg/ingestor/parser/ite6"
 	"github.com/guacsec/guac/pkg/logging"
+	"github.com/guacsec/guac/pkg/metrics"
+	"github.com/guacsec/guac/pkg/tracing"
 
-	homedir "github.com/mitchellh/go-homedir"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
-var flags = struct {
-	dbAddr  string
-	gdbuser string
-	gdbpass string
-	realm   string
-
-	keyPath string
-	keyID   string
-
-	// collect-sub flags
-	collectSubAddr       string
-	collectSubListenPort int
-}{}
-
 var cfgFile string
 
+// guacConfig is the config shared by the commands, loaded from the config
+// file, the environment and the flags
+var guacConfig *config.Config
+
 func init() {
 	cobra.OnInitialize(initConfig)
 	persistentFlags := rootCmd.PersistentFlags()
-	persistentFlags.StringVar(&flags.dbAddr, "gdbaddr", "neo4j://localhost:7687", "address to neo4j db")
-	persistentFlags.StringVar(&flags.gdbuser, "gdbuser", "", "neo4j user credential to connect to graph db")
-	persistentFlags.StringVar(&flags.gdbpass, "gdbpass", "", "neo4j password credential to connect to graph db")
-	persistentFlags.StringVar(&flags.realm, "realm", "neo4j", "realm to connect to graph db")
-	persistentFlags.StringVar(&flags.keyPath, "verifier-keyPath", "", "path to pem file to verify dsse")
-	persistentFlags.StringVar(&flags.keyID, "verifier-keyID", "", "ID of the key to be stored")
-	persistentFlags.StringVar(&flags.collectSubAddr, "csub-addr", "localhost:2782", "address to connect to collect-sub service")
-	persistentFlags.IntVar(&flags.collectSubListenPort, "csub-listen-port", 2782, "port to listen to on collect-sub service")
-
-	flagNames := []string{"gdbaddr", "gdbuser", "gdbpass", "realm",
-		"verifier-keyPath", "verifier-keyID",
-		"csub-addr", "csub-listen-port"}
+	persistentFlags.StringVar(&cfgFile, "config", "", "config file, guac.yaml in the home or working directory if not set")
+	persistentFlags.String("gdb-backend", assembler.Neo4jBackend, fmt.Sprintf("backend storing the graph, one of: %s (the memory backend only lasts as long as the process)", strings.Join(assembler.RegisteredBackends(), ", ")))
+	persistentFlags.String("gdbaddr", "neo4j://localhost:7687", "address of the graph db, e.g. neo4j://localhost:7687, postgres://localhost:5432/guac for the postgres backend, or http://localhost:8529/guac for the arangodb backend")
+	persistentFlags.String("gdbuser", "", "neo4j user credential to connect to graph db")
+	persistentFlags.String("gdbpass", "", "neo4j password credential to connect to graph db")
+	persistentFlags.String("realm", "neo4j", "realm to connect to graph db")
+	persistentFlags.String("gdb-tls-cert", "", "path to client certificate pem file for mTLS to graph db (requires a bolt+s:// address)")
+	persistentFlags.String("gdb-tls-key", "", "path to client key pem file for mTLS to graph db")
+	persistentFlags.String("gdb-tls-ca", "", "path to CA pem file to verify the graph db server certificate, system roots if empty")
+	persistentFlags.Float64("gdb-write-rate", 0, "maximum write operations per second to the graph db, 0 for unlimited")
+	persistentFlags.Int("gdb-batch-size", assembler.DefaultWriteOptions.BatchSize, "maximum nodes and edges written to the graph db per transaction")
+	persistentFlags.String("verifier-keyPath", "", "path to pem file, or URI of a KMS key (awskms://, gcpkms://, azurekms:// or hashivault://), to verify dsse")
+	persistentFlags.String("verifier-keyID", "", "ID of the key to be stored")
+	persistentFlags.Duration("verifier-kms-refresh", kms.DefaultRefreshInterval, "interval to read the public key of a KMS key again, so that a rotated key is used")
+	persistentFlags.String("verifier-fulcio-roots", "", "path to pem file of the Fulcio root and intermediate certificates to verify keyless dsse signatures")
+	persistentFlags.String("verifier-rekor-keys", "", "path to pem file of the Rekor public keys to verify the transparency log entries of keyless dsse signatures")
+	persistentFlags.String("verifier-tuf-mirror", "", "URL of a TUF repository whose .pem targets are public keys to verify dsse, by target name without the .pem suffix")
+	persistentFlags.String("verifier-tuf-root", "", "path to the trusted root.json of the TUF repository given by verifier-tuf-mirror")
+	persistentFlags.Duration("verifier-tuf-refresh", tuf.DefaultRefreshInterval, "interval to update the keys of the TUF repository, so that added, rotated and revoked keys are used")
+	persistentFlags.String("verifier-trust-policy", "", "YAML file listing the trusted signing keys and identities, and the predicate types and subjects each may sign")
+	persistentFlags.Int("seen-cache-size", 10000, "number of ingested document hashes to remember to skip unchanged documents, 0 to disable")
+	persistentFlags.Duration("seen-cache-ttl", time.Hour, "duration to remember an ingested document hash, 0 to never expire")
+	persistentFlags.String("seen-cache-file", "", "file to persist ingested document hashes across runs")
+	persistentFlags.String("csub-addr", "localhost:2782", "address to connect to collect-sub service")
+	persistentFlags.Int("csub-listen-port", 2782, "port to listen to on collect-sub service")
+	persistentFlags.Duration("collector-timeout", 0, "maximum duration of a collection run, after which the collectors are aborted and the documents already collected are processed, 0 for no timeout")
+	persistentFlags.Int("collector-read-attempts", retry.DefaultPolicy.Attempts, "number of times collectors try to read a document before skipping it")
+	persistentFlags.Duration("collector-read-backoff", retry.DefaultPolicy.Backoff, "delay before retrying to read a document, doubled after each retry")
+	persistentFlags.String("collector-name", "", "name labelling the logs of the documents collected, defaults to the collector type, e.g. file")
+	persistentFlags.Duration("emit-interval", 0, "minimum time between two collected documents being emitted, e.g. 10ms for at most 100 documents per second, 0 for no limit")
+	persistentFlags.String("digest-algorithm", hashcache.DefaultDigestAlgorithm, "digest algorithm identifying documents to drop duplicates and skip unchanged ones: sha256 or sha512")
+	persistentFlags.String("otlp-endpoint", "", "OTLP/HTTP endpoint of an OpenTelemetry collector to export the traces of the documents to (e.g. http://localhost:4318), defaults to OTEL_EXPORTER_OTLP_ENDPOINT, tracing is disabled if empty")
+	persistentFlags.String("otlp-headers", "", "headers sent with each export of the traces, e.g. api-key=secret,tenant=guac, with URL-encoded values, defaults to OTEL_EXPORTER_OTLP_HEADERS")
+	persistentFlags.String("otlp-service-name", "", "service name the traces are exported under, defaults to OTEL_SERVICE_NAME, then guacone")
+	persistentFlags.String("metrics-addr", "", "address to serve the Prometheus /metrics endpoint on (e.g. :9090) while a long-running command runs, disabled if empty")
+	persistentFlags.String("health-addr", "", "address to serve the /healthz and /readyz endpoints on (e.g. :8080) while a long-running command runs, disabled if empty")
+	persistentFlags.Duration("health-stall-timeout", health.DefaultStallTimeout, "time a document may take to be published or stored before /healthz reports the process stalled, so that it is restarted")
+	persistentFlags.String("quarantine-dir", "", "directory to write documents of unsupported types or formats to for later inspection")
+	persistentFlags.String("log-level", "info", "log level: debug, info, warn or error")
+	persistentFlags.String("log-components", "", "per component log levels overriding log-level, e.g. collector=debug,assembler=warn")
+	persistentFlags.String("predicate-mapping", "", "YAML file mapping in-toto predicate types to the edges and attributes to ingest")
+
+	flagNames := []string{"gdb-backend", "gdbaddr", "gdbuser", "gdbpass", "realm", "gdb-write-rate", "gdb-batch-size",
+		"gdb-tls-cert", "gdb-tls-key", "gdb-tls-ca",
+		"verifier-keyPath", "verifier-keyID", "verifier-kms-refresh", "verifier-fulcio-roots", "verifier-rekor-keys",
+		"verifier-tuf-mirror", "verifier-tuf-root", "verifier-tuf-refresh", "verifier-trust-policy",
+		"seen-cache-size", "seen-cache-ttl", "seen-cache-file",
+		"csub-addr", "csub-listen-port",
+		"log-level", "log-components", "collector-timeout", "predicate-mapping",
+		"collector-read-attempts", "collector-read-backoff", "emit-interval", "collector-name",
+		"quarantine-dir", "digest-algorithm", "otlp-endpoint", "otlp-headers", "otlp-service-name",
+		"metrics-addr", "health-addr", "health-stall-timeout"}
 	for _, name := range flagNames {
 		if flag := persistentFlags.Lookup(name); flag != nil {
-			if err := viper.BindPFlag(name, flag); err != nil {
+			if err := viper.BindPFlag(config.Key(name), flag); err != nil {
 				fmt.Fprintf(os.Stderr, "failed to bind flag: %v", err)
 				os.Exit(1)
 			}
@@ -72,27 +115,96 @@ func initConfig() {
 	ctx := logging.WithLogger(context.Background())
 	logger := logging.FromContext(ctx)
 
-	if cfgFile != "" {
-		viper.SetConfigFile(cfgFile)
-	} else {
-		home, err := homedir.Dir()
+	configFile, err := config.Read(viper.GetViper(), cfgFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	guacConfig, err = config.Load(viper.GetViper())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid config: %v\n", err)
+		os.Exit(1)
+	}
+	if err := logging.Configure(guacConfig.Log.Level, guacConfig.Log.Components); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to configure logging: %v\n", err)
+		os.Exit(1)
+	}
+	if configFile != "" {
+		logger.Infof("Using config file: %s", configFile)
+	}
+	retryPolicy := retry.DefaultPolicy
+	retryPolicy.Attempts = guacConfig.Collector.ReadAttempts
+	retryPolicy.Backoff = guacConfig.Collector.ReadBackoff
+	if err := retry.SetPolicy(retryPolicy); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid collector read retry policy: %v\n", err)
+		os.Exit(1)
+	}
+	collector.SetCollectorName(guacConfig.Collector.Name)
+	if err := collector.SetEmitInterval(guacConfig.Collector.EmitInterval); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid emit interval: %v\n", err)
+		os.Exit(1)
+	}
+	if err := hashcache.SetDigestAlgorithm(guacConfig.Processor.DigestAlgorithm); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid digest algorithm: %v\n", err)
+		os.Exit(1)
+	}
+	// the flags and guac.yaml take precedence over the OTEL_* variables
+	otlpConfig, err := guacConfig.Tracing.OTLPConfig("guacone")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid tracing config: %v\n", err)
+		os.Exit(1)
+	}
+	if err := tracing.ConfigureOTLP(ctx, otlpConfig); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to configure tracing: %v\n", err)
+		os.Exit(1)
+	}
+	if err := process.SetQuarantineDir(guacConfig.Processor.QuarantineDir); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid quarantine directory: %v\n", err)
+		os.Exit(1)
+	}
+	if path := guacConfig.Ingestor.PredicateMapping; path != "" {
+		mapping, err := ite6.LoadPredicateMapping(path)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "failed to get user home directory: %v\n", err)
+			fmt.Fprintf(os.Stderr, "failed to load predicate mapping: %v\n", err)
 			os.Exit(1)
 		}
-
-		viper.AddConfigPath(home)
-		viper.AddConfigPath(".")
-		viper.SetConfigName("guac")
-		viper.SetConfigType("yaml")
+		ite6.SetPredicateMapping(mapping)
+		logger.Infof("Using predicate mapping: %s", path)
 	}
+}
 
-	viper.AutomaticEnv()
-	viper.SetEnvPrefix("guac")
+// serveMetrics serves the metrics on metrics-addr, if set, until ctx is done
+func serveMetrics(ctx context.Context) {
+	addr := guacConfig.Metrics.Addr
+	if addr == "" {
+		return
+	}
+	go func() {
+		if err := metrics.ListenAndServe(ctx, addr); err != nil {
+			logging.FromContext(ctx).Errorf("metrics server ended with error: %v", err)
+		}
+	}()
+}
 
-	if err := viper.ReadInConfig(); err == nil {
-		logger.Infof("Using config file: %s", viper.ConfigFileUsed())
+// serveHealth serves /healthz right away and /readyz once marked started on
+// health-addr, if set, until ctx is done. The liveness endpoint reports the
+// process stalled once a document is published or stored for longer than
+// health-stall-timeout, as watched by the returned watchdog. Both are nil if
+// health-addr is not set.
+func serveHealth(ctx context.Context) (*health.Server, *health.Watchdog) {
+	addr := guacConfig.Health.Addr
+	if addr == "" {
+		return nil, nil
 	}
+	healthServer := health.NewServer(addr)
+	watchdog := health.NewWatchdog(guacConfig.Health.StallTimeout)
+	healthServer.AddLivenessCheck("stall", watchdog.Check)
+	go func() {
+		if err := healthServer.ListenAndServe(ctx); err != nil {
+			logging.FromContext(ctx).Errorf("health server ended with error: %v", err)
+		}
+	}()
+	return healthServer, watchdog
 }
 
 var rootCmd = &cobra.Command{
@@ -101,7 +213,14 @@ var rootCmd = &cobra.Command{
 }
 
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
+	err := rootCmd.Execute()
+	// export the spans of the documents not exported yet
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if shutdownErr := tracing.Shutdown(shutdownCtx); shutdownErr != nil {
+		fmt.Fprintf(os.Stderr, "failed to export traces: %v\n", shutdownErr)
+	}
+	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
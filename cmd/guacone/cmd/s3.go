@@ -0,0 +1,203 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/guacsec/guac/pkg/config"
+	"github.com/guacsec/guac/pkg/handler/collector"
+	"github.com/guacsec/guac/pkg/handler/collector/s3"
+	"github.com/guacsec/guac/pkg/handler/processor"
+	"github.com/guacsec/guac/pkg/handler/processor/process"
+	"github.com/guacsec/guac/pkg/logging"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+type s3Options struct {
+	// bucket to collect documents from
+	bucket string
+	// prefix of the objects to collect
+	prefix string
+	// interval to poll the bucket at, 0 to collect once
+	pollInterval time.Duration
+	// endpoint of an S3 compatible store, e.g. MinIO
+	endpoint string
+	// region of the bucket
+	region string
+}
+
+var s3Cmd = &cobra.Command{
+	Use:   "s3 [flags] bucket",
+	Short: "takes the documents stored in an S3 bucket to add to GUAC graph",
+	Long: `takes the documents stored in an S3 bucket to add to GUAC graph.
+
+Authenticates with the default AWS credential chain, e.g. AWS_ACCESS_KEY_ID and
+AWS_SECRET_ACCESS_KEY. S3 compatible stores such as MinIO are read by setting
+their --s3-endpoint. With --s3-poll-interval the bucket is polled and only new
+or updated objects (by etag) are ingested.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := logging.WithLogger(context.Background())
+		logger := logging.FromContext(ctx)
+
+		opts, err := validateS3Flags(guacConfig.S3, args)
+		if err != nil {
+			fmt.Printf("unable to validate flags: %v\n", err)
+			_ = cmd.Help()
+			os.Exit(1)
+		}
+
+		// Register collector
+		collectorOpts := []s3.Option{}
+		if opts.endpoint != "" {
+			collectorOpts = append(collectorOpts, s3.WithEndpoint(opts.endpoint))
+		}
+		if opts.region != "" {
+			collectorOpts = append(collectorOpts, s3.WithRegion(opts.region))
+		}
+		s3Collector, err := s3.NewS3Collector(ctx, opts.bucket, opts.prefix, opts.pollInterval, collectorOpts...)
+		if err != nil {
+			logger.Errorf("error: %v", err)
+			os.Exit(1)
+		}
+		err = collector.RegisterDocumentCollector(s3Collector, s3.CollectorS3)
+		if err != nil {
+			logger.Errorf("unable to register s3 collector: %v", err)
+		}
+
+		// Get pipeline of components
+		processorFunc, err := getProcessor(ctx)
+		if err != nil {
+			logger.Errorf("error: %v", err)
+			os.Exit(1)
+		}
+		ingestorFunc, err := getIngestor(ctx)
+		if err != nil {
+			logger.Errorf("error: %v", err)
+			os.Exit(1)
+		}
+		assemblerFunc, err := getAssembler(ctx, guacConfig.GraphDB)
+		if err != nil {
+			logger.Errorf("error: %v", err)
+			os.Exit(1)
+		}
+
+		totalNum := 0
+		gotErr := false
+		// Set emit function to go through the entire pipeline
+		emit := func(d *processor.Document) error {
+			totalNum += 1
+			start := time.Now()
+
+			docTree, err := processorFunc(d)
+			if errors.Is(err, process.ErrUnsupportedDocument) {
+				// counted and reported in the summary
+				return nil
+			}
+			if err != nil {
+				gotErr = true
+				return fmt.Errorf("unable to process doc: %v, format: %v, document: %v", err, d.Format, d.Type)
+			}
+
+			graphs, err := ingestorFunc(docTree)
+			if err != nil {
+				gotErr = true
+				if len(graphs) == 0 {
+					return fmt.Errorf("unable to ingest doc tree: %v", err)
+				}
+				logger.Errorf("partially ingesting doc tree: %v", err)
+			}
+
+			err = assemblerFunc(graphs)
+			if err != nil {
+				gotErr = true
+				return fmt.Errorf("unable to assemble graphs: %v", err)
+			}
+			t := time.Now()
+			elapsed := t.Sub(start)
+			logger.Infof("[%v] completed doc %+v", elapsed, d.SourceInformation)
+			return nil
+		}
+
+		// Collect
+		errHandler := func(err error) bool {
+			if err == nil {
+				logger.Info("collector ended gracefully")
+				return true
+			}
+			if errors.Is(err, collector.ErrCollectorTimeout) {
+				logger.Warnf("collector stopped: %v", err)
+				return true
+			}
+			if errors.Is(err, collector.ErrDocumentRead) {
+				gotErr = true
+				logger.Warnf("skipped document: %v", err)
+				return true
+			}
+			logger.Errorf("collector ended with error: %v", err)
+			return false
+		}
+		if err := collector.CollectWithTimeout(ctx, emit, errHandler, guacConfig.Collector.Timeout); err != nil {
+			logger.Fatal(err)
+		}
+
+		process.LogUnsupportedDocuments(ctx)
+		if gotErr {
+			logger.Fatalf("completed ingestion with errors")
+		} else {
+			logger.Infof("completed ingesting %v documents", totalNum)
+		}
+	},
+}
+
+func validateS3Flags(c config.S3, args []string) (s3Options, error) {
+	var opts s3Options
+	opts.prefix = c.Prefix
+	opts.endpoint = c.Endpoint
+	opts.region = c.Region
+
+	if c.PollInterval < 0 {
+		return opts, fmt.Errorf("s3-poll-interval must not be negative")
+	}
+	opts.pollInterval = c.PollInterval
+
+	if len(args) != 1 {
+		return opts, fmt.Errorf("expected positional argument for bucket")
+	}
+	opts.bucket = args[0]
+
+	return opts, nil
+}
+
+func init() {
+	s3Flags := s3Cmd.Flags()
+	s3Flags.String("s3-prefix", "", "only collect the objects whose key starts with this prefix")
+	s3Flags.Duration("s3-poll-interval", 0, "interval to poll the bucket for new or updated objects, 0 to collect once")
+	s3Flags.String("s3-endpoint", "", "endpoint of an S3 compatible store such as MinIO, e.g. http://minio:9000")
+	s3Flags.String("s3-region", "", "region of the bucket, defaults to the region of the AWS config")
+	for _, name := range []string{"s3-prefix", "s3-poll-interval", "s3-endpoint", "s3-region"} {
+		if err := viper.BindPFlag(name, s3Flags.Lookup(name)); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to bind flag: %v", err)
+			os.Exit(1)
+		}
+	}
+	rootCmd.AddCommand(s3Cmd)
+}
@@ -0,0 +1,226 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/guacsec/guac/pkg/assembler"
+	"github.com/guacsec/guac/pkg/config"
+	"github.com/guacsec/guac/pkg/handler/collector"
+	"github.com/guacsec/guac/pkg/handler/collector/file"
+	"github.com/guacsec/guac/pkg/handler/processor"
+	"github.com/guacsec/guac/pkg/handler/processor/process"
+	"github.com/guacsec/guac/pkg/ingestor/verifier"
+	"github.com/guacsec/guac/pkg/ingestor/verifier/sigstore_verifier"
+	"github.com/guacsec/guac/pkg/logging"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+type snapshotOptions struct {
+	// path to folder with documents to collect
+	path string
+	// file to write the snapshot to, none if empty
+	output string
+	// previously captured snapshot to diff against, none if empty
+	compare string
+}
+
+var snapshotCmd = &cobra.Command{
+	Use:   "snapshot [flags] file_path",
+	Short: "capture the nodes and edges a folder of files would write to the GUAC graph and diff them against a previous snapshot",
+	Long: `capture the nodes and edges a folder of files would write to the GUAC graph
+and diff them against a previous snapshot.
+
+The snapshot is written as stable JSON with --snapshot-output (stdout if neither
+--snapshot-output nor --snapshot-compare is set). With --snapshot-compare, the
+added (+), removed (-) and changed (~) nodes and edges are printed and the
+command exits with 1 if the snapshots differ.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := logging.WithLogger(context.Background())
+		logger := logging.FromContext(ctx)
+
+		opts, err := validateSnapshotFlags(guacConfig.Snapshot, args)
+		if err != nil {
+			fmt.Printf("unable to validate flags: %v\n", err)
+			_ = cmd.Help()
+			os.Exit(1)
+		}
+
+		// read the previous snapshot first to fail before collecting
+		var previous assembler.Snapshot
+		if opts.compare != "" {
+			f, err := os.Open(opts.compare)
+			if err != nil {
+				logger.Fatalf("unable to open snapshot: %v", err)
+			}
+			previous, err = assembler.ReadSnapshot(f)
+			f.Close()
+			if err != nil {
+				logger.Fatalf("unable to read snapshot %s: %v", opts.compare, err)
+			}
+		}
+
+		// Register Verifier
+		verifierOpts, err := sigstoreVerifierOptions()
+		if err != nil {
+			logger.Errorf("unable to load the verifier trust roots: %v", err)
+			os.Exit(1)
+		}
+		sigstoreAndKeyVerifier := sigstore_verifier.NewSigstoreAndKeyVerifier(verifierOpts...)
+		err = verifier.RegisterVerifier(sigstoreAndKeyVerifier, sigstoreAndKeyVerifier.Type())
+		if err != nil {
+			logger.Errorf("unable to register key provider: %v", err)
+		}
+
+		// Register collector
+		fileCollector := file.NewFileCollector(ctx, opts.path, false, time.Second)
+		err = collector.RegisterDocumentCollector(fileCollector, file.FileCollector)
+		if err != nil {
+			logger.Errorf("unable to register file collector: %v", err)
+		}
+
+		// Get pipeline of components
+		processorFunc, err := getProcessor(ctx)
+		if err != nil {
+			logger.Errorf("error: %v", err)
+			os.Exit(1)
+		}
+		ingestorFunc, err := getIngestor(ctx)
+		if err != nil {
+			logger.Errorf("error: %v", err)
+			os.Exit(1)
+		}
+
+		combined := assembler.Graph{
+			Nodes: []assembler.GuacNode{},
+			Edges: []assembler.GuacEdge{},
+		}
+		gotErr := false
+		// Set emit function to process and parse documents into a single graph
+		emit := func(d *processor.Document) error {
+			docTree, err := processorFunc(d)
+			if errors.Is(err, process.ErrUnsupportedDocument) {
+				// counted and reported in the summary
+				return nil
+			}
+			if err != nil {
+				gotErr = true
+				return fmt.Errorf("unable to process doc: %v, format: %v, document: %v", err, d.Format, d.Type)
+			}
+
+			graphs, err := ingestorFunc(docTree)
+			for _, g := range graphs {
+				combined.AppendGraph(g)
+			}
+			if err != nil {
+				gotErr = true
+				return fmt.Errorf("unable to ingest doc tree: %v", err)
+			}
+			return nil
+		}
+
+		// Collect
+		errHandler := func(err error) bool {
+			if err == nil {
+				logger.Info("collector ended gracefully")
+				return true
+			}
+			if errors.Is(err, collector.ErrCollectorTimeout) {
+				gotErr = true
+				logger.Warnf("collector stopped: %v", err)
+				return true
+			}
+			if errors.Is(err, collector.ErrDocumentRead) {
+				gotErr = true
+				logger.Warnf("skipped document: %v", err)
+				return true
+			}
+			logger.Errorf("collector ended with error: %v", err)
+			return false
+		}
+		if err := collector.CollectWithTimeout(ctx, emit, errHandler, guacConfig.Collector.Timeout); err != nil {
+			logger.Fatal(err)
+		}
+
+		process.LogUnsupportedDocuments(ctx)
+		if gotErr {
+			logger.Fatalf("completed collection with errors, not capturing snapshot")
+		}
+
+		snapshot, err := assembler.NewSnapshot(combined)
+		if err != nil {
+			logger.Fatalf("unable to capture snapshot: %v", err)
+		}
+		if opts.output != "" || opts.compare == "" {
+			var out io.Writer = os.Stdout
+			if opts.output != "" {
+				f, err := os.Create(opts.output)
+				if err != nil {
+					logger.Fatalf("unable to create output file: %v", err)
+				}
+				defer f.Close()
+				out = f
+			}
+			if err := assembler.WriteSnapshot(out, snapshot); err != nil {
+				logger.Fatalf("unable to write snapshot: %v", err)
+			}
+			logger.Infof("captured %v nodes and %v edges", len(snapshot.Nodes), len(snapshot.Edges))
+		}
+
+		if opts.compare != "" {
+			diff := assembler.DiffSnapshots(previous, snapshot)
+			if err := assembler.WriteSnapshotDiff(os.Stdout, diff); err != nil {
+				logger.Fatalf("unable to write diff: %v", err)
+			}
+			if !diff.Empty() {
+				os.Exit(1)
+			}
+		}
+	},
+}
+
+func validateSnapshotFlags(c config.Snapshot, args []string) (snapshotOptions, error) {
+	var opts snapshotOptions
+	opts.output = c.Output
+	opts.compare = c.Compare
+
+	if len(args) != 1 {
+		return opts, fmt.Errorf("expected positional argument for file_path")
+	}
+	opts.path = args[0]
+
+	return opts, nil
+}
+
+func init() {
+	snapshotFlags := snapshotCmd.Flags()
+	snapshotFlags.String("snapshot-output", "", "file to write the snapshot to")
+	snapshotFlags.String("snapshot-compare", "", "previously captured snapshot file to diff the new snapshot against")
+	for _, name := range []string{"snapshot-output", "snapshot-compare"} {
+		if err := viper.BindPFlag(name, snapshotFlags.Lookup(name)); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to bind flag: %v", err)
+			os.Exit(1)
+		}
+	}
+	rootCmd.AddCommand(snapshotCmd)
+}
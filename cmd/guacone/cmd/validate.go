@@ -0,0 +1,129 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/guacsec/guac/pkg/assembler"
+	"github.com/guacsec/guac/pkg/handler/collector/file"
+	"github.com/guacsec/guac/pkg/handler/processor"
+	"github.com/guacsec/guac/pkg/handler/processor/process"
+	"github.com/guacsec/guac/pkg/ingestor/parser"
+	"github.com/guacsec/guac/pkg/ingestor/verifier"
+	"github.com/guacsec/guac/pkg/ingestor/verifier/sigstore_verifier"
+	"github.com/guacsec/guac/pkg/logging"
+	"github.com/spf13/cobra"
+)
+
+var validateCmd = &cobra.Command{
+	Use:   "validate [flags] file",
+	Short: "check that a document can be processed and parsed, without ingesting it",
+	Long: `check that a document can be processed and parsed, without ingesting it.
+
+The document goes through format and type detection, schema checks and parsing,
+and the resulting graph is checked for nodes that would be merged or dropped
+(e.g. packages without a purl) and malformed purls and digests. Each problem is
+printed with its location. Neither JetStream nor the graph db is used. Exits
+non-zero if an error is found, warnings alone do not fail.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := logging.WithLogger(context.Background())
+		logger := logging.FromContext(ctx)
+
+		// Register Verifier
+		verifierOpts, err := sigstoreVerifierOptions()
+		if err != nil {
+			logger.Errorf("unable to load the verifier trust roots: %v", err)
+			os.Exit(1)
+		}
+		sigstoreAndKeyVerifier := sigstore_verifier.NewSigstoreAndKeyVerifier(verifierOpts...)
+		err = verifier.RegisterVerifier(sigstoreAndKeyVerifier, sigstoreAndKeyVerifier.Type())
+		if err != nil {
+			logger.Errorf("unable to register key provider: %v", err)
+		}
+
+		problems, err := validateDocument(ctx, args[0])
+		if err != nil {
+			fmt.Printf("error: %s: %v\n", args[0], err)
+			os.Exit(1)
+		}
+
+		numErrors := 0
+		for _, p := range problems {
+			fmt.Println(p)
+			if p.Severity == assembler.SeverityError {
+				numErrors++
+			}
+		}
+		fmt.Printf("%s: %d errors, %d warnings\n", args[0], numErrors, len(problems)-numErrors)
+		if numErrors > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+// validateDocument runs the document at path through the processor and the
+// parsers and returns the documents failing to parse and the problems found in
+// the resulting graphs. Reading and processing failures are returned as errors.
+func validateDocument(ctx context.Context, path string) ([]assembler.Problem, error) {
+	blob, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	doc := &processor.Document{
+		Blob:   blob,
+		Type:   processor.DocumentUnknown,
+		Format: processor.FormatUnknown,
+		SourceInformation: processor.SourceInformation{
+			Collector: file.FileCollector,
+			Source:    fmt.Sprintf("file:///%s", path),
+			URI:       file.FileURI(path),
+		},
+	}
+
+	docTree, err := process.Process(ctx, doc)
+	if err != nil {
+		return nil, fmt.Errorf("unable to process document: %w", err)
+	}
+	problems := []assembler.Problem{}
+	graphs, err := parser.ParseDocumentTree(ctx, docTree)
+	if err != nil {
+		var treeErr *parser.TreeError
+		if !errors.As(err, &treeErr) {
+			return nil, fmt.Errorf("unable to parse %s document: %w", docTree.Document.Type, err)
+		}
+		// report each document failing to parse, and check the others
+		for _, f := range treeErr.Failures {
+			problems = append(problems, assembler.Problem{
+				Severity: assembler.SeverityError,
+				Location: fmt.Sprintf("%s document at %s", f.Type, f.Path),
+				Message:  f.Err.Error(),
+			})
+		}
+	}
+	for _, g := range graphs {
+		problems = append(problems, assembler.ValidateGraph(g)...)
+	}
+	return problems, nil
+}
+
+func init() {
+	rootCmd.AddCommand(validateCmd)
+}
@@ -0,0 +1,145 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"errors"
+	"github.com/guacsec/guac/pkg/ingestor/key"
+	"github.com/guacsec/guac/pkg/ingestor/key/inmemory"
+	"github.com/guacsec/guac/pkg/ingestor/key/kms"
+	"github.com/guacsec/guac/pkg/ingestor/key/tuf"
+	"github.com/guacsec/guac/pkg/ingestor/policy"
+	"github.com/guacsec/guac/pkg/ingestor/verifier/sigstore_verifier"
+	"github.com/guacsec/guac/pkg/logging"
+	"strings"
+)
+
+// registerVerifierKeys registers the key providers holding the keys that
+// verify dsse signatures: the key given by --verifier-keyPath and
+// --verifier-keyID, the keys of the TUF repository given by
+// --verifier-tuf-mirror, and the keys of the signers of the trust policy given
+// by --verifier-trust-policy, which is then enforced.
+func registerVerifierKeys(ctx context.Context) error {
+	logger := logging.FromContext(ctx)
+	keys := map[string]string{}
+	if keyPath, keyID := guacConfig.Verifier.KeyPath, guacConfig.Verifier.KeyID; keyPath != "" && keyID != "" {
+		if !strings.HasSuffix(keyPath, "pem") && !kms.IsReference(keyPath) {
+			return errors.New("key must be passed in as a pem file or a KMS key URI")
+		}
+		keys[keyID] = keyPath
+	}
+	if path := guacConfig.Verifier.TrustPolicy; path != "" {
+		trustPolicy, err := policy.LoadTrustPolicy(path)
+		if err != nil {
+			return err
+		}
+		for _, signer := range trustPolicy.Signers {
+			if signer.Key == "" {
+				continue
+			}
+			if other, ok := keys[signer.KeyID]; ok && other != signer.Key {
+				return fmt.Errorf("key ID %s is used for both %s and %s", signer.KeyID, other, signer.Key)
+			}
+			keys[signer.KeyID] = signer.Key
+		}
+		policy.SetTrustPolicy(trustPolicy)
+		logger.Infof("Using trust policy: %s", path)
+	}
+
+	if mirror := guacConfig.Verifier.TUFMirror; mirror != "" {
+		rootPath := guacConfig.Verifier.TUFRoot
+		if rootPath == "" {
+			return fmt.Errorf("verifier-tuf-root is required to verify the TUF repository %s", mirror)
+		}
+		rootJSON, err := os.ReadFile(rootPath)
+		if err != nil {
+			return err
+		}
+		tufProvider, err := tuf.NewTUFProvider(ctx, mirror, rootJSON, guacConfig.Verifier.TUFRefresh)
+		if err != nil {
+			return err
+		}
+		if err := key.RegisterKeyProvider(tufProvider, tufProvider.Type()); err != nil {
+			logger.Errorf("unable to register key provider: %v", err)
+		}
+		logger.Infof("Using the keys of TUF repository: %s", mirror)
+	}
+
+	inmemoryProvider := inmemory.NewInmemoryProvider()
+	if err := key.RegisterKeyProvider(inmemoryProvider, inmemoryProvider.Type()); err != nil {
+		logger.Errorf("unable to register key provider: %v", err)
+	}
+	// keys in a KMS are read from it when verifying, so that rotated keys
+	// are used
+	kmsProvider := kms.NewKMSProvider(guacConfig.Verifier.KMSRefresh)
+	kmsRegistered := false
+	for id, path := range keys {
+		if kms.IsReference(path) {
+			if !kmsRegistered {
+				if err := key.RegisterKeyProvider(kmsProvider, kmsProvider.Type()); err != nil {
+					logger.Errorf("unable to register key provider: %v", err)
+				}
+				kmsRegistered = true
+			}
+			if err := kmsProvider.AddReference(id, path); err != nil {
+				return err
+			}
+			continue
+		}
+		keyRaw, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if err := key.Store(ctx, id, keyRaw, inmemoryProvider.Type()); err != nil {
+			return fmt.Errorf("invalid key %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// sigstoreVerifierOptions loads the Fulcio certificates and the Rekor keys
+// verifying keyless signatures, given by --verifier-fulcio-roots and
+// --verifier-rekor-keys
+func sigstoreVerifierOptions() ([]sigstore_verifier.Option, error) {
+	var opts []sigstore_verifier.Option
+	if path := guacConfig.Verifier.FulcioRoots; path != "" {
+		pemBytes, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		certs, err := sigstore_verifier.ParseCertificates(pemBytes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Fulcio certificates %s: %w", path, err)
+		}
+		opts = append(opts, sigstore_verifier.WithFulcio(certs...))
+	}
+	if path := guacConfig.Verifier.RekorKeys; path != "" {
+		pemBytes, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		keys, err := sigstore_verifier.ParsePublicKeys(pemBytes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Rekor keys %s: %w", path, err)
+		}
+		opts = append(opts, sigstore_verifier.WithRekor(keys...))
+	}
+	return opts, nil
+}
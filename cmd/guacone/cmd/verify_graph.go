@@ -0,0 +1,133 @@
+//
+// Copyright 2022 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/guacsec/guac/pkg/assembler"
+	"github.com/guacsec/guac/pkg/assembler/graphdb"
+	"github.com/guacsec/guac/pkg/config"
+	"github.com/guacsec/guac/pkg/logging"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+type verifyGraphOptions struct {
+	// delete the dangling edges found
+	fix bool
+	// number of edges deleted per transaction
+	batchSize int
+}
+
+var verifyGraphCmd = &cobra.Command{
+	Use:   "verify-graph [flags]",
+	Short: "check the GUAC graph for consistency violations",
+	Long: `check the GUAC graph for consistency violations.
+
+The checks find the nodes without one of the properties they are merged on,
+the keys shared by more than one node despite the indices, and the dangling
+edges: relationships with an endpoint that is a node without labels or
+without its key. Each violation is reported and the command exits with a
+non-zero status if any is found.
+
+With --fix the dangling edges are deleted, --fix-batch-size at a time, and
+the graph is checked again. The other violations are only reported.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := logging.WithLogger(context.Background())
+		logger := logging.FromContext(ctx)
+
+		opts, err := validateVerifyGraphFlags(guacConfig.VerifyGraph, args)
+		if err != nil {
+			fmt.Printf("unable to validate flags: %v\n", err)
+			_ = cmd.Help()
+			os.Exit(1)
+		}
+
+		authToken := graphdb.CreateAuthTokenWithUsernameAndPassword(guacConfig.GraphDB.User, guacConfig.GraphDB.Pass, guacConfig.GraphDB.Realm)
+		client, err := getGraphClient(guacConfig.GraphDB, authToken)
+		if err != nil {
+			logger.Fatalf("unable to connect to graph db: %v", err)
+		}
+		defer client.Close()
+
+		violations, err := assembler.VerifyGraph(client)
+		if err != nil {
+			logger.Fatalf("unable to verify graph: %v", err)
+		}
+		if opts.fix && hasDanglingEdges(violations) {
+			deleted, err := assembler.DeleteDanglingEdges(client, opts.batchSize)
+			if err != nil {
+				logger.Fatalf("unable to delete dangling edges after deleting %d: %v", deleted, err)
+			}
+			logger.Infof("deleted %d dangling edges", deleted)
+			violations, err = assembler.VerifyGraph(client)
+			if err != nil {
+				logger.Fatalf("unable to verify graph: %v", err)
+			}
+		}
+
+		if len(violations) == 0 {
+			logger.Infof("graph verified, no violations found")
+			return
+		}
+		for _, v := range violations {
+			logger.Warnf("violation %v", v)
+		}
+		logger.Errorf("graph verification found %d violations", len(violations))
+		os.Exit(1)
+	},
+}
+
+// hasDanglingEdges reports whether the dangling edge check found violations
+func hasDanglingEdges(violations []assembler.Violation) bool {
+	for _, v := range violations {
+		if v.Check == assembler.CheckDanglingEdge {
+			return true
+		}
+	}
+	return false
+}
+
+func validateVerifyGraphFlags(c config.VerifyGraph, args []string) (verifyGraphOptions, error) {
+	var opts verifyGraphOptions
+	opts.fix = c.Fix
+
+	if len(args) > 0 {
+		return opts, fmt.Errorf("unexpected positional arguments: %v", args)
+	}
+	if c.FixBatchSize <= 0 {
+		return opts, fmt.Errorf("fix-batch-size must be positive")
+	}
+	opts.batchSize = c.FixBatchSize
+
+	return opts, nil
+}
+
+func init() {
+	verifyFlags := verifyGraphCmd.Flags()
+	verifyFlags.Bool("fix", false, "delete the dangling edges found")
+	verifyFlags.Int("fix-batch-size", 1000, "number of dangling edges to delete per transaction")
+	for _, name := range []string{"fix", "fix-batch-size"} {
+		if err := viper.BindPFlag(name, verifyFlags.Lookup(name)); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to bind flag: %v", err)
+			os.Exit(1)
+		}
+	}
+	rootCmd.AddCommand(verifyGraphCmd)
+}
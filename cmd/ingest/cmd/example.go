@@ -20,28 +20,12 @@ import (
 	"os"
 
 	"github.com/guacsec/guac/pkg/assembler"
-	"github.com/guacsec/guac/pkg/assembler/graphdb"
 	"github.com/guacsec/guac/pkg/handler/processor"
 	"github.com/guacsec/guac/pkg/ingestor/parser"
 	"github.com/guacsec/guac/pkg/logging"
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
 )
 
-var flags = struct {
-	dbAddr  string
-	gdbuser string
-	gdbpass string
-	realm   string
-}{}
-
-type options struct {
-	dbAddr string
-	user   string
-	pass   string
-	realm  string
-}
-
 var docs []processor.DocumentTree
 
 var exampleCmd = &cobra.Command{
@@ -51,25 +35,22 @@ var exampleCmd = &cobra.Command{
 		ctx := logging.WithLogger(context.Background())
 		logger := logging.FromContext(ctx)
 
-		opts, err := validateFlags(
-			viper.GetString("gdbuser"),
-			viper.GetString("gdbpass"),
-			viper.GetString("gdbaddr"),
-			viper.GetString("realm"),
-		)
-		if err != nil {
-			logger.Errorf("unable to validate flags: %v", err)
-			os.Exit(1)
-		}
-
 		// Access graphDB
 
-		authToken := graphdb.CreateAuthTokenWithUsernameAndPassword(opts.user, opts.pass, opts.realm)
-		client, err := graphdb.NewGraphClient(opts.dbAddr, authToken)
+		backend, err := assembler.NewBackend(ctx, guacConfig.GraphDB.Backend, assembler.BackendConfig{
+			Address:     guacConfig.GraphDB.Addr,
+			User:        guacConfig.GraphDB.User,
+			Password:    guacConfig.GraphDB.Pass,
+			Realm:       guacConfig.GraphDB.Realm,
+			TLSCertPath: guacConfig.GraphDB.TLSCert,
+			TLSKeyPath:  guacConfig.GraphDB.TLSKey,
+			TLSCAPath:   guacConfig.GraphDB.TLSCA,
+		})
 		if err != nil {
 			logger.Errorf("unable to initialize graph client: %v", err)
 			os.Exit(1)
 		}
+		defer backend.Close()
 
 		// Parse sample documents
 		g := assembler.Graph{
@@ -87,23 +68,13 @@ var exampleCmd = &cobra.Command{
 		}
 		logger.Infof("graph nodes: %v, edges: %v", len(g.Nodes), len(g.Edges))
 
-		if err := assembler.StoreGraph(g, client); err != nil {
+		if err := backend.StoreGraph(ctx, g); err != nil {
 			logger.Errorf("unable to store graph: %v", err)
 			os.Exit(1)
 		}
 	},
 }
 
-func validateFlags(user string, pass string, dbAddr string, realm string) (options, error) {
-	var opts options
-
-	opts.user = user
-	opts.pass = pass
-	opts.dbAddr = dbAddr
-
-	return opts, nil
-}
-
 func init() {
 	rootCmd.AddCommand(exampleCmd)
 }
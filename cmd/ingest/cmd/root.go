@@ -21,26 +21,34 @@ import (
 	"os"
 	"strings"
 
+	"github.com/guacsec/guac/pkg/assembler"
+	"github.com/guacsec/guac/pkg/config"
 	"github.com/guacsec/guac/pkg/logging"
 
-	homedir "github.com/mitchellh/go-homedir"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
 var cfgFile string
 
+// guacConfig is the config shared by the commands, loaded from the config
+// file, the environment and the flags
+var guacConfig *config.Config
+
 func init() {
 	cobra.OnInitialize(initConfig)
 	persistentFlags := rootCmd.PersistentFlags()
-	persistentFlags.StringVar(&flags.dbAddr, "gdbaddr", "neo4j://localhost:7687", "address to neo4j db")
-	persistentFlags.StringVar(&flags.gdbuser, "gdbuser", "", "neo4j user credential to connect to graph db")
-	persistentFlags.StringVar(&flags.gdbpass, "gdbpass", "", "neo4j password credential to connect to graph db")
-	persistentFlags.StringVar(&flags.realm, "realm", "neo4j", "realm to connect to graph db")
-	flagNames := []string{"gdbaddr", "gdbuser", "gdbpass", "realm"}
+	persistentFlags.StringVar(&cfgFile, "config", "", "config file, guac.yaml in the home or working directory if not set")
+	persistentFlags.String("gdb-backend", assembler.Neo4jBackend, fmt.Sprintf("backend storing the graph, one of: %s", strings.Join(assembler.RegisteredBackends(), ", ")))
+	persistentFlags.String("gdbaddr", "neo4j://localhost:7687", "address to neo4j db")
+	persistentFlags.String("gdbuser", "", "neo4j user credential to connect to graph db")
+	persistentFlags.String("gdbpass", "", "neo4j password credential to connect to graph db")
+	persistentFlags.String("realm", "neo4j", "realm to connect to graph db")
+	persistentFlags.String("log-level", "info", "log level: debug, info, warn or error")
+	flagNames := []string{"gdb-backend", "gdbaddr", "gdbuser", "gdbpass", "realm", "log-level"}
 	for _, name := range flagNames {
 		if flag := persistentFlags.Lookup(name); flag != nil {
-			if err := viper.BindPFlag(name, flag); err != nil {
+			if err := viper.BindPFlag(config.Key(name), flag); err != nil {
 				fmt.Fprintf(os.Stderr, "failed to bind flag: %v", err)
 				os.Exit(1)
 			}
@@ -52,30 +60,22 @@ func initConfig() {
 	ctx := logging.WithLogger(context.Background())
 	logger := logging.FromContext(ctx)
 
-	if cfgFile != "" {
-		viper.SetConfigFile(cfgFile)
-	} else {
-		home, err := homedir.Dir()
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "failed to get user home directory: %v\n", err)
-			os.Exit(1)
-		}
-
-		viper.AddConfigPath(home)
-		viper.AddConfigPath(".")
-		viper.SetConfigName("guac")
-		viper.SetConfigType("yaml")
+	configFile, err := config.Read(viper.GetViper(), cfgFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
 	}
-
-	viper.AutomaticEnv()
-	viper.SetEnvPrefix("guac")
-	// The following line is needed to replace - with _ in env variables
-	// e.g. GUAC_DB_ADDR will be read as GUAC_gdbaddr
-	// The POSIX standard does not allow - in env variables
-	viper.SetEnvKeyReplacer(strings.NewReplacer("-", "_"))
-
-	if err := viper.ReadInConfig(); err == nil {
-		logger.Infof("Using config file: %s", viper.ConfigFileUsed())
+	guacConfig, err = config.Load(viper.GetViper())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid config: %v\n", err)
+		os.Exit(1)
+	}
+	if err := logging.Configure(guacConfig.Log.Level, guacConfig.Log.Components); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to configure logging: %v\n", err)
+		os.Exit(1)
+	}
+	if configFile != "" {
+		logger.Infof("Using config file: %s", configFile)
 	}
 }
 
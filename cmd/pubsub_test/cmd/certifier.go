@@ -23,17 +23,14 @@ import (
 	"sync"
 
 	"github.com/guacsec/guac/pkg/assembler"
-	"github.com/guacsec/guac/pkg/assembler/graphdb"
 	"github.com/guacsec/guac/pkg/certifier"
 	"github.com/guacsec/guac/pkg/certifier/certify"
 	root_package "github.com/guacsec/guac/pkg/certifier/components"
 	"github.com/guacsec/guac/pkg/emitter"
 	"github.com/guacsec/guac/pkg/handler/processor"
 	"github.com/guacsec/guac/pkg/logging"
-	"github.com/nats-io/nats.go"
 	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
 )
 
 var certifierCmd = &cobra.Command{
@@ -43,41 +40,38 @@ var certifierCmd = &cobra.Command{
 		ctx := logging.WithLogger(context.Background())
 		logger := logging.FromContext(ctx)
 
-		opts, err := validateCertifierFlags(
-			viper.GetString("gdbuser"),
-			viper.GetString("gdbpass"),
-			viper.GetString("gdbaddr"),
-			viper.GetString("realm"),
-		)
-
+		backend, err := getBackend(ctx, guacConfig.GraphDB)
 		if err != nil {
-			fmt.Printf("unable to validate flags: %v\n", err)
-			_ = cmd.Help()
+			logger.Errorf("error: %v", err)
 			os.Exit(1)
 		}
-
-		authToken := graphdb.CreateAuthTokenWithUsernameAndPassword(opts.user, opts.pass, opts.realm)
-		client, err := graphdb.NewGraphClient(opts.dbAddr, authToken)
+		defer backend.Close()
+		// the packages to certify are found with Cypher queries
+		client, err := assembler.GraphClient(backend)
 		if err != nil {
 			logger.Errorf("error: %v", err)
 			os.Exit(1)
 		}
 
-		// initialize jetstream
-		// TODO: pass in credentials file for NATS secure login
-		jetStream := emitter.NewJetStream(nats.DefaultURL, "", "")
-		ctx, err = jetStream.JetStreamInit(ctx)
+		// initialize the broker
+		broker, err := getBroker()
 		if err != nil {
-			logger.Errorf("jetStream initialization failed with error: %v", err)
+			logger.Errorf("invalid broker config: %v", err)
 			os.Exit(1)
 		}
-		// recreate stream to remove any old lingering documents
-		// NOT TO BE USED IN PRODUCTION
-		err = jetStream.RecreateStream(ctx)
+		ctx, err = broker.Init(ctx)
 		if err != nil {
-			logger.Errorf("unexpected error recreating jetstream: %v", err)
+			logger.Errorf("broker initialization failed with error: %v", err)
+			os.Exit(1)
 		}
-		defer jetStream.Close()
+		emitter.ExportPendingMessages(ctx)
+		serveMetrics(ctx)
+		if guacConfig.Broker.NATS.RecreateStream {
+			// recreate stream to remove any old lingering documents
+			// NOT TO BE USED IN PRODUCTION
+			recreateStream(ctx, broker)
+		}
+		defer broker.Close()
 
 		certifierPubFunc, err := getCertifierPublish(ctx)
 		if err != nil {
@@ -85,13 +79,13 @@ var certifierCmd = &cobra.Command{
 			os.Exit(1)
 		}
 
-		assemblerFunc, err := getAssembler(opts)
+		assemblerFunc, err := getAssembler(ctx, backend)
 		if err != nil {
 			logger.Errorf("error: %v", err)
 			os.Exit(1)
 		}
 
-		processorTransportFunc := func(d processor.DocumentTree) error {
+		processorTransportFunc := func(ctx context.Context, d processor.DocumentTree) error {
 			docTreeBytes, err := json.Marshal(d)
 			if err != nil {
 				return fmt.Errorf("failed marshal of document: %w", err)
@@ -103,8 +97,8 @@ var certifierCmd = &cobra.Command{
 			return nil
 		}
 
-		ingestorTransportFunc := func(d []assembler.Graph) error {
-			err := assemblerFunc(d)
+		ingestorTransportFunc := func(ctx context.Context, d []assembler.Graph) error {
+			err := assemblerFunc(ctx, d)
 			if err != nil {
 				return err
 			}
@@ -116,7 +110,7 @@ var certifierCmd = &cobra.Command{
 			logger.Errorf("error: %v", err)
 			os.Exit(1)
 		}
-		ingestorFunc, err := getIngestor(ctx, ingestorTransportFunc)
+		ingestorFunc, err := getIngestor(ctx, ingestorTransportFunc, nil)
 		if err != nil {
 			logger.Errorf("error: %v", err)
 			os.Exit(1)
@@ -176,16 +170,6 @@ var certifierCmd = &cobra.Command{
 	},
 }
 
-func validateCertifierFlags(user string, pass string, dbAddr string, realm string) (options, error) {
-	var opts options
-	opts.user = user
-	opts.pass = pass
-	opts.dbAddr = dbAddr
-	opts.realm = realm
-
-	return opts, nil
-}
-
 func getCertifierPublish(ctx context.Context) (func(*processor.Document) error, error) {
 	return func(d *processor.Document) error {
 		return certify.Publish(ctx, d)
@@ -0,0 +1,201 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/guacsec/guac/pkg/config"
+	"github.com/guacsec/guac/pkg/emitter"
+	"github.com/guacsec/guac/pkg/handler/collector"
+	"github.com/guacsec/guac/pkg/handler/collector/daemon"
+	"github.com/guacsec/guac/pkg/handler/processor"
+	"github.com/guacsec/guac/pkg/logging"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var daemonCmd = &cobra.Command{
+	Use:   "daemon [--collectors-config guac-collectors.yaml]",
+	Short: "run the collectors of a config continuously, publishing the documents to the broker",
+	Long: `run the collectors of a config continuously, publishing the documents to
+the broker for the processors and ingestors, e.g. of files --mode=processor.
+
+The YAML config given with --collectors-config, or else the collectors of
+guac.yaml, declares the collectors, each with a unique name, a type and the
+fields of its type:
+
+  collectors:
+    - name: sboms
+      type: file
+      paths: [/var/lib/guac/sboms]
+      include: ["**/*.json"]
+      interval: 5m
+    - name: images
+      type: oci
+      images: [ghcr.io/guacsec/guac, ghcr.io/guacsec/guac-data:v0.1.0]
+      interval: 1h
+    - name: attestations
+      type: s3
+      bucket: attestations
+      prefix: slsa/
+      region: eu-west-1
+      interval: 10m
+
+file collectors take paths and include/exclude glob patterns, oci collectors
+images, all the tags of an image without a tag, and gcs and s3 collectors a
+bucket and prefix, s3 collectors also a region and endpoint. Each collector
+collects again every interval what is new or changed, or runs once without
+an interval. A collector ending with an error is run again after its
+interval, or a minute without one.
+
+On SIGHUP, the config, or guac.yaml, is read again: the collectors removed or
+changed are stopped, the new or changed ones started, and the others left
+running. A config that does not load is logged and the collectors are left
+as they are.
+Given --health-addr, /readyz reports the collectors that ended with an error
+and wait to be run again.
+The daemon runs until interrupted.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, stop := signal.NotifyContext(logging.WithLogger(context.Background()), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		logger := logging.FromContext(ctx)
+
+		configPath := guacConfig.Daemon.CollectorsConfig
+		if configPath == "" && len(guacConfig.Collectors) == 0 {
+			fmt.Println("unable to validate flags: no collectors given with --collectors-config nor in guac.yaml")
+			_ = cmd.Help()
+			os.Exit(1)
+		}
+		collectorsConfig, err := loadCollectorsConfig(configPath)
+		if err != nil {
+			logger.Errorf("error: %v", err)
+			os.Exit(1)
+		}
+
+		serveMetrics(ctx)
+		healthServer, watchdog := serveHealth(ctx)
+
+		broker, err := getBroker()
+		if err != nil {
+			logger.Errorf("invalid broker config: %v", err)
+			os.Exit(1)
+		}
+		ctx, err = broker.Init(ctx)
+		if err != nil {
+			logger.Errorf("broker initialization failed with error: %v", err)
+			os.Exit(1)
+		}
+		emitter.ExportPendingMessages(ctx)
+		defer broker.Close()
+		if healthServer != nil {
+			healthServer.AddReadinessCheck("broker", broker.Ping)
+		}
+		if guacConfig.Broker.Compress {
+			ctx = emitter.WithCompression(ctx)
+		}
+
+		collectorPubFunc, err := getCollectorPublish(ctx)
+		if err != nil {
+			logger.Errorf("error: %v", err)
+			os.Exit(1)
+		}
+		emit := func(d *processor.Document) error {
+			defer watchdog.Watch("publishing a collected document")()
+			if err := collectorPubFunc(d); err != nil {
+				// the document is collected again on the next run
+				logger.Errorf("failed to publish document %s: %v", d.SourceInformation.Source, err)
+			}
+			return nil
+		}
+		errHandler := func(err error) bool {
+			if err == nil || errors.Is(err, context.Canceled) {
+				return true
+			}
+			if errors.Is(err, collector.ErrDocumentRead) {
+				logger.Warnf("skipped document: %v", err)
+				return true
+			}
+			return false
+		}
+
+		d := daemon.New(emit, errHandler)
+		if err := d.Apply(ctx, collectorsConfig); err != nil {
+			logger.Errorf("unable to start the collectors: %v", err)
+			os.Exit(1)
+		}
+		if healthServer != nil {
+			// not ready while a collector fails, e.g. to reach its bucket
+			healthServer.AddReadinessCheck("collectors", d.Check)
+			healthServer.MarkStarted()
+		}
+		logger.Infof("running collectors %v", d.Running())
+
+		hup := make(chan os.Signal, 1)
+		signal.Notify(hup, syscall.SIGHUP)
+		defer signal.Stop(hup)
+		for {
+			select {
+			case <-ctx.Done():
+				d.Wait()
+				return
+			case <-hup:
+				collectorsConfig, err := loadCollectorsConfig(configPath)
+				if err != nil {
+					logger.Errorf("keeping the running collectors, unable to reload: %v", err)
+					continue
+				}
+				if err := d.Apply(ctx, collectorsConfig); err != nil {
+					logger.Errorf("keeping the running collectors, unable to reload: %v", err)
+					continue
+				}
+				logger.Infof("reloaded the config, running collectors %v", d.Running())
+			}
+		}
+	},
+}
+
+// loadCollectorsConfig reads the collectors config at path, or else the
+// collectors of guac.yaml, read again
+func loadCollectorsConfig(path string) (*daemon.Config, error) {
+	if path != "" {
+		return daemon.LoadConfig(path)
+	}
+	if _, err := config.Read(viper.GetViper(), cfgFile); err != nil {
+		return nil, err
+	}
+	c, err := config.Load(viper.GetViper())
+	if err != nil {
+		return nil, err
+	}
+	return &daemon.Config{Collectors: c.Collectors}, nil
+}
+
+func init() {
+	daemonFlags := daemonCmd.Flags()
+	daemonFlags.String("collectors-config", "", "YAML file declaring the collectors to run, read again on SIGHUP, the collectors of guac.yaml if not set")
+	if err := viper.BindPFlag("collectors-config", daemonFlags.Lookup("collectors-config")); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to bind flag: %v", err)
+		os.Exit(1)
+	}
+	rootCmd.AddCommand(daemonCmd)
+}
@@ -0,0 +1,169 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/guacsec/guac/pkg/emitter"
+	"github.com/guacsec/guac/pkg/logging"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var dlqCmd = &cobra.Command{
+	Use:   "dlq",
+	Short: "list, inspect and re-drive the documents on the dead-letter queue",
+	Long: `list, inspect and re-drive the documents on the dead-letter queue.
+
+The processor moves a document to the dead-letter queue when it does not
+process, and the ingestor a document tree when it does not parse or is out
+of attempts to store, see --ingest-max-attempts. Any other document failing
+--dead-letter-attempts times is moved there too. Each entry is identified by
+its sequence in the stream, and keeps the error of its last attempt.
+Re-driving an entry publishes the document again for the stage that failed
+on it and removes the entry.`,
+}
+
+var dlqListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "list the entries of the dead-letter queue, oldest first",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, closeJetStream := initQueueJetStream()
+		defer closeJetStream()
+		logger := logging.FromContext(ctx)
+
+		deadLetters, err := emitter.DeadLetters(ctx)
+		if err != nil {
+			logger.Errorf("unable to list the dead-letter queue: %v", err)
+			os.Exit(1)
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "SEQ\tFAILED AT\tCONSUMER\tSUBJECT\tATTEMPTS\tERROR")
+		for _, d := range deadLetters {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%d\t%s\n", d.Seq, d.FailedAt.Format(time.RFC3339), d.Consumer, d.Subject, d.Attempts, d.Error)
+		}
+		_ = w.Flush()
+	},
+}
+
+var dlqInspectCmd = &cobra.Command{
+	Use:   "inspect SEQ",
+	Short: "print an entry of the dead-letter queue with its document",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		seqs, err := parseSeqs(args)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		ctx, closeJetStream := initQueueJetStream()
+		defer closeJetStream()
+		logger := logging.FromContext(ctx)
+
+		d, err := emitter.GetDeadLetter(ctx, seqs[0])
+		if err != nil {
+			logger.Errorf("unable to get dead letter %d: %v", seqs[0], err)
+			os.Exit(1)
+		}
+		fmt.Printf("seq:       %d\n", d.Seq)
+		fmt.Printf("failed at: %s\n", d.FailedAt.Format(time.RFC3339))
+		fmt.Printf("consumer:  %s\n", d.Consumer)
+		fmt.Printf("subject:   %s\n", d.Subject)
+		fmt.Printf("attempts:  %d\n", d.Attempts)
+		fmt.Printf("error:     %s\n\n", d.Error)
+		doc, err := d.Document(ctx)
+		if err != nil {
+			logger.Errorf("unable to read the document of dead letter %d: %v", d.Seq, err)
+			os.Exit(1)
+		}
+		var indented bytes.Buffer
+		if json.Indent(&indented, doc, "", "  ") == nil {
+			doc = indented.Bytes()
+		}
+		fmt.Println(string(doc))
+	},
+}
+
+var dlqRedriveCmd = &cobra.Command{
+	Use:   "redrive [SEQ...]",
+	Short: "publish the documents of entries of the dead-letter queue again and remove the entries",
+	Run: func(cmd *cobra.Command, args []string) {
+		all := guacConfig.DeadLetter.RedriveAll
+		if all == (len(args) > 0) {
+			fmt.Fprintln(os.Stderr, "give either the sequences of the entries to re-drive or --all")
+			os.Exit(1)
+		}
+		seqs, err := parseSeqs(args)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		ctx, closeJetStream := initQueueJetStream()
+		defer closeJetStream()
+		logger := logging.FromContext(ctx)
+
+		if all {
+			deadLetters, err := emitter.DeadLetters(ctx)
+			if err != nil {
+				logger.Errorf("unable to list the dead-letter queue: %v", err)
+				os.Exit(1)
+			}
+			for _, d := range deadLetters {
+				seqs = append(seqs, d.Seq)
+			}
+		}
+		for i, seq := range seqs {
+			d, err := emitter.Redrive(ctx, seq)
+			if err != nil {
+				logger.Errorf("unable to re-drive dead letter %d after %d dead letters: %v", seq, i, err)
+				os.Exit(1)
+			}
+			logger.Infof("re-drove dead letter %d to %s", seq, d.Subject)
+		}
+		logger.Infof("re-drove %d dead letters", len(seqs))
+	},
+}
+
+// parseSeqs returns the stream sequences given on the command line
+func parseSeqs(args []string) ([]uint64, error) {
+	seqs := make([]uint64, 0, len(args))
+	for _, arg := range args {
+		seq, err := strconv.ParseUint(arg, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid sequence %q, see dlq list", arg)
+		}
+		seqs = append(seqs, seq)
+	}
+	return seqs, nil
+}
+
+func init() {
+	dlqRedriveCmd.Flags().Bool("all", false, "re-drive all the entries of the dead-letter queue")
+	if err := viper.BindPFlag("all", dlqRedriveCmd.Flags().Lookup("all")); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to bind flag: %v", err)
+		os.Exit(1)
+	}
+	dlqCmd.AddCommand(dlqListCmd, dlqInspectCmd, dlqRedriveCmd)
+	rootCmd.AddCommand(dlqCmd)
+}
@@ -21,206 +21,406 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"os/signal"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/guacsec/guac/pkg/assembler"
-	"github.com/guacsec/guac/pkg/assembler/graphdb"
+	"github.com/guacsec/guac/pkg/config"
 	"github.com/guacsec/guac/pkg/emitter"
 	"github.com/guacsec/guac/pkg/handler/collector"
 	"github.com/guacsec/guac/pkg/handler/collector/file"
 	"github.com/guacsec/guac/pkg/handler/processor"
+	"github.com/guacsec/guac/pkg/handler/processor/hashcache"
 	"github.com/guacsec/guac/pkg/handler/processor/process"
+	"github.com/guacsec/guac/pkg/health"
 	"github.com/guacsec/guac/pkg/ingestor/parser"
 	"github.com/guacsec/guac/pkg/logging"
+	"github.com/guacsec/guac/pkg/pipeline"
 	"github.com/nats-io/nats.go"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
-var flags = struct {
-	dbAddr  string
-	gdbuser string
-	gdbpass string
-	realm   string
-	keyPath string
-	keyID   string
-}{}
-
-type options struct {
-	dbAddr string
-	user   string
-	pass   string
-	realm  string
-	// path to the pem file
-	keyPath string
-	// ID related to the key being stored
-	keyID string
-	// path to folder with documents to collect
-	path string
+type filesOptions struct {
+	// paths to folders with documents to collect
+	paths []string
+	// glob patterns of the files to collect, relative to each path
+	include []string
+	exclude []string
+	// pipeline stage(s) to run
+	mode string
 }
 
+// run modes of the files command, "all" runs every stage in one process
+const (
+	modeAll       = "all"
+	modeCollector = "collector"
+	modeProcessor = "processor"
+	modeIngestor  = "ingestor"
+)
+
+// collectPathEnv is the environment variable giving the folder to collect
+// when neither file_path nor --path is set
+const collectPathEnv = "GUAC_COLLECT_PATH"
+
 var filesCmd = &cobra.Command{
-	Use:   "files [flags] file_path",
+	Use:   "files [flags] [file_path]",
 	Short: "take a folder of files and create a GUAC graph utilizing Nats pubsub",
+	Long: `take a folder of files and create a GUAC graph utilizing Nats pubsub.
+
+Without --pubsub-addr, the documents are collected, processed, parsed and
+stored in this process, passed from one stage to the next through channels,
+and the command ends once all of them are stored, so that small deployments
+need no broker. --processors documents are processed concurrently.
+
+With --pubsub-addr, the stages are connected by the broker at that address:
+NATS JetStream with a nats:// address, Kafka topics with a kafka:// address of
+a Kafka REST Proxy, or channels in this process with mem://, which only suits
+--mode=all. The subscribers then run until interrupted. With --mode, only one
+stage of the pipeline is run and connected to the broker, so that collectors,
+processors and ingestors can be deployed as separate processes. file_path is
+only needed for the collector stage.
+
+Folders are given as file_path and/or with the repeatable --path flag, or
+else with the GUAC_COLLECT_PATH environment variable. The
+--include and --exclude glob patterns are matched against the path of each
+file relative to its folder, e.g. "**/*.json" or "vendor/**".
+
+To backfill many documents without overwhelming the graph db, --max-pending
+holds the collector and processors back while the stages downstream have that
+many documents waiting, --publish-rate caps the documents published per
+second, --nats-max-in-flight the documents handed to the processors and
+ingestors at once, and --gdb-write-rate the writes to the graph db.
+
+To scale the processors and ingestors, run more replicas of them with the same
+--processor-durable and --ingestor-durable: each document goes to one of them.
+The documents of a source, e.g. the versions of an SBOM, may then be ingested
+out of order; with --partitions greater than 1 they are spread over that many
+partitions by source, and each partition is handled by one replica at a time
+in the order collected, so scale the replicas up to the number of partitions.
+
+Documents larger than the max payload of the broker, e.g. SPDX SBOMs of
+hundreds of MB, are written to the blob store given with --blob-store once
+larger than --blob-threshold, and the processors and ingestors read them back
+from it. All the stages must be given the same blob store.`,
 	Run: func(cmd *cobra.Command, args []string) {
 
-		opts, err := validateFlags(
-			viper.GetString("gdbuser"),
-			viper.GetString("gdbpass"),
-			viper.GetString("gdbaddr"),
-			viper.GetString("realm"),
-			viper.GetString("verifier-keyPath"),
-			viper.GetString("verifier-keyID"),
-			args)
+		opts, err := validateFlags(guacConfig.Files, args)
 		if err != nil {
 			fmt.Printf("unable to validate flags: %v\n", err)
 			_ = cmd.Help()
 			os.Exit(1)
 		}
 
-		ctx := logging.WithLogger(context.Background())
+		// interrupting ends the subscribers, so that the seen document cache
+		// is saved and the connections are closed
+		ctx, stop := signal.NotifyContext(logging.WithLogger(context.Background()), os.Interrupt, syscall.SIGTERM)
+		defer stop()
 		logger := logging.FromContext(ctx)
 
-		// Register collector
-		fileCollector := file.NewFileCollector(ctx, opts.path, false, time.Second)
-		err = collector.RegisterDocumentCollector(fileCollector, file.FileCollector)
-		if err != nil {
-			logger.Errorf("unable to register file collector: %v", err)
+		serveMetrics(ctx)
+		// serve liveness right away, readiness once all connections are up
+		healthServer, watchdog := serveHealth(ctx)
+
+		if guacConfig.Broker.Addr == "" {
+			if opts.mode != modeAll {
+				logger.Errorf("--mode=%s needs a broker given with --pubsub-addr", opts.mode)
+				os.Exit(1)
+			}
+			runStandalone(ctx, opts, healthServer, watchdog)
+			return
 		}
 
-		// initialize jetstream
-		// TODO: pass in credentials file for NATS secure login
-		jetStream := emitter.NewJetStream(nats.DefaultURL, "", "")
-		ctx, err = jetStream.JetStreamInit(ctx)
+		// initialize the broker
+		broker, err := getBroker()
 		if err != nil {
-			logger.Errorf("jetStream initialization failed with error: %v", err)
+			logger.Errorf("invalid broker config: %v", err)
 			os.Exit(1)
 		}
-		// recreate stream to remove any old lingering documents
-		// NOT TO BE USED IN PRODUCTION
-		err = jetStream.RecreateStream(ctx)
-		if err != nil {
-			logger.Errorf("unexpected error recreating jetstream: %v", err)
-		}
-		defer jetStream.Close()
-
-		// Get pipeline of components
-		collectorPubFunc, err := getCollectorPublish(ctx)
+		ctx, err = broker.Init(ctx)
 		if err != nil {
-			logger.Errorf("error: %v", err)
+			logger.Errorf("broker initialization failed with error: %v", err)
 			os.Exit(1)
 		}
+		emitter.ExportPendingMessages(ctx)
+		if healthServer != nil {
+			healthServer.AddReadinessCheck("broker", broker.Ping)
+		}
+		if guacConfig.Broker.Compress {
+			ctx = emitter.WithCompression(ctx)
+		}
+		if opts.mode == modeAll && guacConfig.Broker.NATS.RecreateStream {
+			// recreate stream to remove any old lingering documents
+			// NOT TO BE USED IN PRODUCTION
+			recreateStream(ctx, broker)
+		}
+		defer broker.Close()
+		if interval := guacConfig.Broker.PendingLogInterval; interval > 0 {
+			go logPending(ctx, interval)
+		}
 
-		assemblerFunc, err := getAssembler(opts)
-		if err != nil {
-			logger.Errorf("error: %v", err)
-			os.Exit(1)
+		var wg sync.WaitGroup
+
+		if opts.mode == modeAll || opts.mode == modeProcessor {
+			processorTransportFunc := func(ctx context.Context, d processor.DocumentTree) error {
+				defer watchdog.Watch("publishing a processed document")()
+				docTreeBytes, err := json.Marshal(d)
+				if err != nil {
+					return fmt.Errorf("failed marshal of document: %w", err)
+				}
+				err = emitter.Publish(ctx, emitter.SubjectNameDocProcessed, docTreeBytes)
+				if err != nil {
+					return err
+				}
+				return nil
+			}
+
+			processorFunc, err := getProcessor(ctx, processorTransportFunc)
+			if err != nil {
+				logger.Errorf("error: %v", err)
+				os.Exit(1)
+			}
+
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				err := processorFunc()
+				if err != nil && !errors.Is(err, context.Canceled) {
+					logger.Errorf("processor ended with error: %v", err)
+				}
+			}()
 		}
 
-		processorTransportFunc := func(d processor.DocumentTree) error {
-			docTreeBytes, err := json.Marshal(d)
+		if opts.mode == modeAll || opts.mode == modeIngestor {
+			// the hash of a document is only recorded once its graph is
+			// stored, so that documents failing to ingest are not skipped
+			seenCache, err := getSeenCache(guacConfig.SeenCache)
 			if err != nil {
-				return fmt.Errorf("failed marshal of document: %w", err)
+				logger.Errorf("error: %v", err)
+				os.Exit(1)
 			}
-			err = emitter.Publish(ctx, emitter.SubjectNameDocProcessed, docTreeBytes)
+			defer func() {
+				if path := guacConfig.SeenCache.File; path != "" {
+					if err := seenCache.Save(path); err != nil {
+						logger.Errorf("unable to persist seen document cache: %v", err)
+					}
+				}
+			}()
+
+			backend, err := getBackend(ctx, guacConfig.GraphDB)
 			if err != nil {
-				return err
+				logger.Errorf("error: %v", err)
+				os.Exit(1)
+			}
+			defer backend.Close()
+			if healthServer != nil {
+				healthServer.AddReadinessCheck("graphdb", func(ctx context.Context) error {
+					return backend.Ping()
+				})
 			}
-			return nil
-		}
 
-		ingestorTransportFunc := func(d []assembler.Graph) error {
-			err := assemblerFunc(d)
+			assemblerFunc, err := getAssembler(ctx, backend)
 			if err != nil {
-				return err
+				logger.Errorf("error: %v", err)
+				os.Exit(1)
 			}
-			return nil
-		}
 
-		processorFunc, err := getProcessor(ctx, processorTransportFunc)
-		if err != nil {
-			logger.Errorf("error: %v", err)
-			os.Exit(1)
-		}
-		ingestorFunc, err := getIngestor(ctx, ingestorTransportFunc)
-		if err != nil {
-			logger.Errorf("error: %v", err)
-			os.Exit(1)
-		}
+			ingestorTransportFunc := func(ctx context.Context, d []assembler.Graph) error {
+				defer watchdog.Watch("storing graphs")()
+				err := assemblerFunc(ctx, d)
+				if err != nil {
+					return err
+				}
+				return nil
+			}
 
-		// Set emit function to go through the entire pipeline
-		emit := func(d *processor.Document) error {
-			err = collectorPubFunc(d)
+			ingestorFunc, err := getIngestor(ctx, ingestorTransportFunc, seenCache)
 			if err != nil {
-				logger.Errorf("collector ended with error: %v", err)
+				logger.Errorf("error: %v", err)
 				os.Exit(1)
 			}
-			return nil
+
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				err := ingestorFunc()
+				if err != nil && !errors.Is(err, context.Canceled) {
+					logger.Errorf("parser ended with error: %v", err)
+				}
+			}()
 		}
 
-		// Collect
-		errHandler := func(err error) bool {
-			if err == nil {
-				logger.Info("collector ended gracefully")
-				return true
-			}
-			logger.Errorf("collector ended with error: %v", err)
-			return false
+		if healthServer != nil {
+			healthServer.MarkStarted()
 		}
 
-		// Assuming that publisher and consumer are different processes.
-		var wg sync.WaitGroup
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			err := processorFunc()
+		if opts.mode == modeAll || opts.mode == modeCollector {
+			// Register collector
+			fileCollector := file.NewFileCollectorWithPatterns(ctx, opts.paths, opts.include, opts.exclude, false, time.Second)
+			err = collector.RegisterDocumentCollector(fileCollector, file.FileCollector)
 			if err != nil {
-				logger.Errorf("processor ended with error: %v", err)
+				logger.Errorf("unable to register file collector: %v", err)
 			}
-		}()
 
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			err := ingestorFunc()
+			collectorPubFunc, err := getCollectorPublish(ctx)
 			if err != nil {
-				logger.Errorf("parser ended with error: %v", err)
+				logger.Errorf("error: %v", err)
+				os.Exit(1)
 			}
-		}()
 
-		if err := collector.Collect(ctx, emit, errHandler); err != nil {
-			logger.Fatal(err)
+			// Set emit function to publish documents for the processors
+			emit := func(d *processor.Document) error {
+				defer watchdog.Watch("publishing a collected document")()
+				err = collectorPubFunc(d)
+				if err != nil {
+					logger.Errorf("collector ended with error: %v", err)
+					os.Exit(1)
+				}
+				return nil
+			}
+
+			// Collect
+			errHandler := func(err error) bool {
+				if err == nil {
+					logger.Info("collector ended gracefully")
+					return true
+				}
+				if errors.Is(err, collector.ErrCollectorTimeout) {
+					logger.Warnf("collector stopped: %v", err)
+					return true
+				}
+				if errors.Is(err, collector.ErrDocumentRead) {
+					logger.Warnf("skipped document: %v", err)
+					return true
+				}
+				logger.Errorf("collector ended with error: %v", err)
+				return false
+			}
+
+			if err := collector.CollectWithTimeout(ctx, emit, errHandler, guacConfig.Collector.Timeout); err != nil {
+				logger.Errorf("collector ended with error: %v", err)
+				stop()
+			}
 		}
 
+		// the subscribers run until interrupted
 		wg.Wait()
 	},
 }
 
-func validateFlags(user string, pass string, dbAddr string, realm string, keyPath string, keyID string, args []string) (options, error) {
-	var opts options
-	opts.user = user
-	opts.pass = pass
-	opts.dbAddr = dbAddr
-	opts.realm = realm
-
-	if keyPath != "" {
-		if strings.HasSuffix(keyPath, "pem") {
-			opts.keyPath = keyPath
-		} else {
-			return opts, errors.New("key must be passed in as a pem file")
+// runStandalone collects, processes, parses and stores the documents of opts
+// in this process, without a broker, until all are stored or ctx is done
+func runStandalone(ctx context.Context, opts filesOptions, healthServer *health.Server, watchdog *health.Watchdog) {
+	logger := logging.FromContext(ctx)
+
+	seenCache, err := getSeenCache(guacConfig.SeenCache)
+	if err != nil {
+		logger.Errorf("error: %v", err)
+		os.Exit(1)
+	}
+	backend, err := getBackend(ctx, guacConfig.GraphDB)
+	if err != nil {
+		logger.Errorf("error: %v", err)
+		os.Exit(1)
+	}
+	defer backend.Close()
+	if healthServer != nil {
+		healthServer.AddReadinessCheck("graphdb", func(ctx context.Context) error {
+			return backend.Ping()
+		})
+	}
+	assemblerFunc, err := getAssembler(ctx, backend)
+	if err != nil {
+		logger.Errorf("error: %v", err)
+		os.Exit(1)
+	}
+
+	fileCollector := file.NewFileCollectorWithPatterns(ctx, opts.paths, opts.include, opts.exclude, false, time.Second)
+	if err := collector.RegisterDocumentCollector(fileCollector, file.FileCollector); err != nil {
+		logger.Errorf("unable to register file collector: %v", err)
+	}
+	errHandler := func(err error) bool {
+		if err == nil {
+			logger.Info("collector ended gracefully")
+			return true
+		}
+		if errors.Is(err, collector.ErrCollectorTimeout) {
+			logger.Warnf("collector stopped: %v", err)
+			return true
+		}
+		if errors.Is(err, collector.ErrDocumentRead) {
+			logger.Warnf("skipped document: %v", err)
+			return true
+		}
+		logger.Errorf("collector ended with error: %v", err)
+		return false
+	}
+	collect := func(ctx context.Context, emit collector.Emitter) error {
+		return collector.CollectWithTimeout(ctx, emit, errHandler, guacConfig.Collector.Timeout)
+	}
+	if healthServer != nil {
+		healthServer.MarkStarted()
+	}
+
+	stats, err := pipeline.RunStandalone(ctx, pipeline.Config{
+		Processors: guacConfig.Processor.Workers,
+		Seen:       seenCache,
+	}, collect, func(ctx context.Context, graphs []assembler.Graph) error {
+		defer watchdog.Watch("storing graphs")()
+		return assemblerFunc(ctx, graphs)
+	})
+	if path := guacConfig.SeenCache.File; path != "" {
+		if err := seenCache.Save(path); err != nil {
+			logger.Errorf("unable to persist seen document cache: %v", err)
 		}
 	}
-	if keyPath != "" {
-		opts.keyID = keyID
+	process.LogUnsupportedDocuments(ctx)
+	if err != nil {
+		logger.Errorf("collector ended with error: %v", err)
+		os.Exit(1)
+	}
+	logger.Infof("collected %d documents: %d ingested, %d skipped unchanged, %d unsupported, %d failed",
+		stats.Collected, stats.Ingested, stats.Skipped, stats.Unsupported, stats.Failed)
+	if stats.Failed > 0 {
+		os.Exit(1)
+	}
+}
+
+func validateFlags(files config.Files, args []string) (filesOptions, error) {
+	var opts filesOptions
+	switch files.Mode {
+	case modeAll, modeCollector, modeProcessor, modeIngestor:
+		opts.mode = files.Mode
+	default:
+		return opts, fmt.Errorf("invalid mode %q, expected one of %s, %s, %s or %s", files.Mode, modeAll, modeCollector, modeProcessor, modeIngestor)
+	}
+
+	if files.Mode == modeProcessor || files.Mode == modeIngestor {
+		if len(args) != 0 || len(files.Paths) != 0 {
+			return opts, fmt.Errorf("file_path is only used by the collector stage, not by %s mode", files.Mode)
+		}
+		return opts, nil
 	}
 
-	if len(args) != 1 {
-		return opts, fmt.Errorf("expected positional argument for file_path")
+	if len(args) > 1 {
+		return opts, fmt.Errorf("expected at most one positional argument for file_path")
+	}
+	opts.paths = append(append(opts.paths, args...), files.Paths...)
+	if len(opts.paths) == 0 && files.CollectPath != "" {
+		opts.paths = []string{files.CollectPath}
+	}
+	if len(opts.paths) == 0 {
+		return opts, fmt.Errorf("expected positional argument for file_path, --path or the %s environment variable", collectPathEnv)
 	}
 
-	opts.path = args[0]
+	if err := file.ValidatePatterns(append(append([]string{}, files.Include...), files.Exclude...)); err != nil {
+		return opts, err
+	}
+	opts.include = files.Include
+	opts.exclude = files.Exclude
 
 	return opts, nil
 }
@@ -231,48 +431,168 @@ func getCollectorPublish(ctx context.Context) (func(*processor.Document) error,
 	}, nil
 }
 
-func getProcessor(ctx context.Context, transportFunc func(processor.DocumentTree) error) (func() error, error) {
+// getBroker returns the broker of the config, not yet initialized
+func getBroker() (emitter.Broker, error) {
+	b := guacConfig.Broker
+	if b.Addr == "" {
+		return nil, fmt.Errorf("no broker given with --pubsub-addr, e.g. %s", nats.DefaultURL)
+	}
+	streamConfig, err := getStreamConfig(b.NATS)
+	if err != nil {
+		return nil, err
+	}
+	return emitter.NewBroker(b.Addr, emitter.BrokerConfig{
+		Stream: streamConfig,
+		NATS: emitter.NATSAuth{
+			Creds:       b.NATS.Creds,
+			NKeyFile:    b.NATS.NKey,
+			User:        b.NATS.User,
+			Password:    b.NATS.Pass,
+			TLSCertPath: b.NATS.TLSCert,
+			TLSKeyPath:  b.NATS.TLSKey,
+			TLSCAPath:   b.NATS.TLSCA,
+		},
+		Kafka: emitter.KafkaConfig{
+			User:        b.Kafka.User,
+			Password:    b.Kafka.Pass,
+			TopicPrefix: b.Kafka.TopicPrefix,
+		},
+	})
+}
+
+// recreateStream recreates the JetStream stream of broker to remove the
+// documents of previous runs. Other brokers are left as they are, the
+// consumer groups of Kafka only read the records they did not commit.
+func recreateStream(ctx context.Context, broker emitter.Broker) {
+	js, ok := broker.(interface {
+		RecreateStream(ctx context.Context) error
+	})
+	if !ok {
+		return
+	}
+	if err := js.RecreateStream(ctx); err != nil {
+		logging.FromContext(ctx).Errorf("unexpected error recreating jetstream: %v", err)
+	}
+}
+
+// getStreamConfig returns the storage and retention of the JetStream stream
+// of the nats config
+func getStreamConfig(c config.NATS) (emitter.StreamConfig, error) {
+	storage, err := emitter.ParseStorageType(c.Storage)
+	if err != nil {
+		return emitter.StreamConfig{}, err
+	}
+	return emitter.StreamConfig{
+		Storage:     storage,
+		MaxBytes:    c.MaxBytes,
+		MaxAge:      c.MaxAge,
+		Replicas:    c.Replicas,
+		Duplicates:  c.DedupWindow,
+		AckWait:     c.AckWait,
+		MaxDeliver:  c.MaxDeliver,
+		MaxInFlight: c.MaxInFlight,
+	}, nil
+}
+
+// logPending logs the number of messages waiting on each subject of the
+// pipeline every interval until ctx is done, for brokers counting them
+func logPending(ctx context.Context, interval time.Duration) {
+	logger := logging.FromContext(ctx)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		pending, err := emitter.PendingMessages(ctx)
+		if err != nil {
+			logger.Warnf("unable to count the pending messages: %v", err)
+			continue
+		}
+		if pending == nil {
+			logger.Infof("the broker does not count the pending messages")
+			return
+		}
+		counts := make([]string, 0, len(pending))
+		for _, subj := range emitter.PipelineSubjects {
+			counts = append(counts, fmt.Sprintf("%s=%d", subj, pending[subj]))
+		}
+		logger.Infof("pending messages: %s", strings.Join(counts, " "))
+	}
+}
+
+// getSeenCache returns the cache of ingested document hashes, loaded from
+// its file if it is set
+func getSeenCache(c config.SeenCache) (*hashcache.Cache, error) {
+	seenCache := hashcache.New(c.Size, c.TTL)
+	if c.File != "" {
+		if err := seenCache.Load(c.File); err != nil {
+			return nil, err
+		}
+	}
+	return seenCache, nil
+}
+
+func getProcessor(ctx context.Context, transportFunc func(context.Context, processor.DocumentTree) error) (func() error, error) {
 	return func() error {
-		return process.Subscribe(ctx, transportFunc)
+		return process.SubscribeDurable(ctx, guacConfig.Processor.Durable, transportFunc)
 	}, nil
 }
 
-func getIngestor(ctx context.Context, transportFunc func([]assembler.Graph) error) (func() error, error) {
+func getIngestor(ctx context.Context, transportFunc func(context.Context, []assembler.Graph) error, seenCache *hashcache.Cache) (func() error, error) {
 	return func() error {
-		err := parser.Subscribe(ctx, transportFunc)
-		if err != nil {
+		// the document trees that failed to store are retried alongside
+		retryErr := make(chan error, 1)
+		go func() {
+			retryErr <- parser.SubscribeRetries(ctx, transportFunc)
+		}()
+		err := parser.SubscribeWithCache(ctx, guacConfig.Ingestor.Durable, transportFunc, seenCache)
+		if err != nil && ctx.Err() == nil {
 			return err
 		}
-		return nil
+		// when interrupted, the retries end as well before the graph db
+		// client is closed
+		if rerr := <-retryErr; err == nil {
+			err = rerr
+		}
+		return err
 	}, nil
 }
 
-func getAssembler(opts options) (func([]assembler.Graph) error, error) {
-	authToken := graphdb.CreateAuthTokenWithUsernameAndPassword(
-		opts.user,
-		opts.pass,
-		opts.realm,
-	)
-
-	client, err := graphdb.NewGraphClient(opts.dbAddr, authToken)
-	if err != nil {
-		return nil, err
-	}
+// getBackend connects to the graph db of opts, limiting writes to its write
+// rate if set, in transactions of up to its batch size
+func getBackend(ctx context.Context, graphDB config.GraphDB) (assembler.Backend, error) {
+	return assembler.NewBackend(ctx, graphDB.Backend, assembler.BackendConfig{
+		Address:        graphDB.Addr,
+		User:           graphDB.User,
+		Password:       graphDB.Pass,
+		Realm:          graphDB.Realm,
+		TLSCertPath:    graphDB.TLSCert,
+		TLSKeyPath:     graphDB.TLSKey,
+		TLSCAPath:      graphDB.TLSCA,
+		WriteLimiter:   assembler.NewWriteLimiter(graphDB.WriteRate),
+		WriteBatchSize: graphDB.BatchSize,
+	})
+}
 
-	err = createIndices(client)
+// getAssembler returns a function storing graphs to backend, the documents of
+// the graphs superseding the earlier documents of their source
+func getAssembler(ctx context.Context, backend assembler.Backend) (func(context.Context, []assembler.Graph) error, error) {
+	err := createIndices(backend)
 	if err != nil {
 		return nil, err
 	}
 
-	return func(gs []assembler.Graph) error {
-		combined := assembler.Graph{
-			Nodes: []assembler.GuacNode{},
-			Edges: []assembler.GuacEdge{},
-		}
-		for _, g := range gs {
-			combined.AppendGraph(g)
+	return func(ctx context.Context, gs []assembler.Graph) error {
+		builder := assembler.NewConcurrentGraphBuilder()
+		builder.AppendGraph(gs...)
+		g, err := assembler.Supersede(backend, builder.Graph())
+		if err != nil {
+			return err
 		}
-		if err := assembler.StoreGraph(combined, client); err != nil {
+		if err := backend.StoreGraph(ctx, g); err != nil {
 			return err
 		}
 
@@ -280,18 +600,22 @@ func getAssembler(opts options) (func([]assembler.Graph) error, error) {
 	}, nil
 }
 
-func createIndices(client graphdb.Client) error {
+func createIndices(backend assembler.Backend) error {
 	indices := map[string][]string{
-		"Artifact":      {"digest", "name"},
-		"Package":       {"purl", "name"},
-		"Metadata":      {"id"},
-		"Attestation":   {"digest"},
-		"Vulnerability": {"id"},
+		"Artifact":          {"digest", "name", "digest_sha1", "digest_sha256", "digest_sha512"},
+		"Package":           {"purl", "name"},
+		"Metadata":          {"id"},
+		"Attestation":       {"digest", "collected_at", "statement_time"},
+		"Builder":           {"id"},
+		"Vulnerability":     {"id"},
+		"License":           {"id"},
+		"ExternalReference": {"url"},
+		"Document":          {"digest", "collected_at", "source_uri", "source"},
 	}
 
 	for label, attributes := range indices {
 		for _, attribute := range attributes {
-			err := assembler.CreateIndexOn(client, label, attribute)
+			err := backend.CreateIndexOn(label, attribute)
 			if err != nil {
 				return err
 			}
@@ -302,5 +626,22 @@ func createIndices(client graphdb.Client) error {
 }
 
 func init() {
+	filesFlags := filesCmd.Flags()
+	filesFlags.String("mode", modeAll, "pipeline stage to run: all, collector, processor or ingestor")
+	filesFlags.Int("processors", 1, "number of documents processed concurrently without a broker")
+	filesFlags.StringSlice("path", nil, "folder with documents to collect, can be repeated")
+	filesFlags.StringSlice("include", nil, "glob pattern of the files to collect, relative to each path, can be repeated")
+	filesFlags.StringSlice("exclude", nil, "glob pattern of the files or folders to skip, relative to each path, can be repeated")
+	for _, name := range []string{"mode", "processors", "path", "include", "exclude"} {
+		if err := viper.BindPFlag(config.Key(name), filesFlags.Lookup(name)); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to bind flag: %v", err)
+			os.Exit(1)
+		}
+	}
+	// the path can also be given by environment, e.g. when running in a container
+	if err := viper.BindEnv("collect-path", collectPathEnv); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to bind environment variable: %v", err)
+		os.Exit(1)
+	}
 	rootCmd.AddCommand(filesCmd)
 }
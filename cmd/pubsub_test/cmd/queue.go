@@ -0,0 +1,141 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/guacsec/guac/pkg/emitter"
+	"github.com/guacsec/guac/pkg/ingestor/parser"
+	"github.com/guacsec/guac/pkg/logging"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// queues the ingestor moves the document trees it failed to ingest to, by
+// the name given on the command line. The dead-letter queue is handled by the
+// dlq command.
+var failedQueues = map[string]struct {
+	subject string
+	durable string
+}{
+	"retry": {emitter.SubjectNameDocRetry, emitter.DurableRetry},
+}
+
+var queueCmd = &cobra.Command{
+	Use:   "queue",
+	Short: "inspect and drain the retry queue of the ingestor",
+	Long: `inspect and drain the retry queue of the ingestor.
+
+Document trees that failed to store are moved to the retry queue while they
+have attempts left, and to the dead-letter queue after that or when the
+failure is not worth retrying, e.g. a document that does not parse. See the
+dlq command for the dead-letter queue.`,
+}
+
+var queueListCmd = &cobra.Command{
+	Use:       "list [retry]",
+	Short:     "print the document trees waiting on a queue, without consuming them",
+	Args:      cobra.ExactValidArgs(1),
+	ValidArgs: []string{"retry"},
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, closeJetStream := initQueueJetStream()
+		defer closeJetStream()
+		logger := logging.FromContext(ctx)
+
+		messages, err := emitter.QueuedMessages(ctx, failedQueues[args[0]].subject)
+		if err != nil {
+			logger.Errorf("unable to list queue %s: %v", args[0], err)
+			os.Exit(1)
+		}
+		for _, m := range messages {
+			fmt.Println(string(m.Data))
+		}
+		logger.Infof("%d document trees on queue %s", len(messages), args[0])
+	},
+}
+
+var queueDrainCmd = &cobra.Command{
+	Use:       "drain [retry]",
+	Short:     "consume and print the document trees on a queue, optionally requeuing them for ingestion",
+	Args:      cobra.ExactValidArgs(1),
+	ValidArgs: []string{"retry"},
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, closeJetStream := initQueueJetStream()
+		defer closeJetStream()
+		logger := logging.FromContext(ctx)
+
+		requeue := guacConfig.Queue.Requeue
+		queue := failedQueues[args[0]]
+		drained, err := emitter.Drain(ctx, queue.subject, queue.durable, func(data []byte) error {
+			fmt.Println(string(data))
+			if !requeue {
+				return nil
+			}
+			var failed parser.FailedDocument
+			if err := json.Unmarshal(data, &failed); err != nil {
+				return fmt.Errorf("failed to unmarshal failed document: %w", err)
+			}
+			return emitter.Publish(ctx, emitter.SubjectNameDocProcessed, failed.DocumentTree)
+		})
+		if err != nil {
+			logger.Errorf("unable to drain queue %s after %d document trees: %v", args[0], drained, err)
+			os.Exit(1)
+		}
+		logger.Infof("drained %d document trees from queue %s", drained, args[0])
+	},
+}
+
+// initQueueJetStream connects to the JetStream of the pipeline, exiting on
+// failure or if the broker is not NATS JetStream. It returns the function
+// closing the connection.
+func initQueueJetStream() (context.Context, func()) {
+	ctx := logging.WithLogger(context.Background())
+	logger := logging.FromContext(ctx)
+
+	broker, err := getBroker()
+	if err != nil {
+		logger.Errorf("invalid broker config: %v", err)
+		os.Exit(1)
+	}
+	ctx, err = broker.Init(ctx)
+	if err != nil {
+		logger.Errorf("broker initialization failed with error: %v", err)
+		os.Exit(1)
+	}
+	if emitter.FromContext(ctx) == nil {
+		broker.Close()
+		logger.Errorf("the queues can only be inspected on NATS JetStream, not at %s", guacConfig.Broker.Addr)
+		os.Exit(1)
+	}
+	if guacConfig.Broker.Compress {
+		ctx = emitter.WithCompression(ctx)
+	}
+	return ctx, broker.Close
+}
+
+func init() {
+	queueDrainCmd.Flags().Bool("requeue", false, "publish the drained document trees again for the ingestor")
+	if err := viper.BindPFlag("requeue", queueDrainCmd.Flags().Lookup("requeue")); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to bind flag: %v", err)
+		os.Exit(1)
+	}
+	queueCmd.AddCommand(queueListCmd, queueDrainCmd)
+	rootCmd.AddCommand(queueCmd)
+}
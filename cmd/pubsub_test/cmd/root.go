@@ -19,29 +19,111 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"strings"
+	"time"
 
+	"github.com/guacsec/guac/pkg/assembler"
+	"github.com/guacsec/guac/pkg/blob"
+	"github.com/guacsec/guac/pkg/config"
+	"github.com/guacsec/guac/pkg/emitter"
+	"github.com/guacsec/guac/pkg/handler/collector"
+	"github.com/guacsec/guac/pkg/handler/collector/retry"
+	"github.com/guacsec/guac/pkg/handler/processor/hashcache"
+	"github.com/guacsec/guac/pkg/handler/processor/process"
+	"github.com/guacsec/guac/pkg/health"
+	"github.com/guacsec/guac/pkg/ingestor/parser"
+	"github.com/guacsec/guac/pkg/ingestor/parser/ite6"
 	"github.com/guacsec/guac/pkg/logging"
+	"github.com/guacsec/guac/pkg/metrics"
+	"github.com/guacsec/guac/pkg/tracing"
 
-	homedir "github.com/mitchellh/go-homedir"
+	"github.com/nats-io/nats.go"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
 var cfgFile string
 
+// guacConfig is the config shared by the commands, loaded from the config
+// file, the environment and the flags
+var guacConfig *config.Config
+
 func init() {
 	cobra.OnInitialize(initConfig)
 	persistentFlags := rootCmd.PersistentFlags()
-	persistentFlags.StringVar(&flags.dbAddr, "gdbaddr", "neo4j://localhost:7687", "address to neo4j db")
-	persistentFlags.StringVar(&flags.gdbuser, "gdbuser", "", "neo4j user credential to connect to graph db")
-	persistentFlags.StringVar(&flags.gdbpass, "gdbpass", "", "neo4j password credential to connect to graph db")
-	persistentFlags.StringVar(&flags.realm, "realm", "neo4j", "realm to connect to graph db")
-	persistentFlags.StringVar(&flags.keyPath, "verifier-keyPath", "", "path to pem file to verify dsse")
-	persistentFlags.StringVar(&flags.keyID, "verifier-keyID", "", "ID of the key to be stored")
-	flagNames := []string{"gdbaddr", "gdbuser", "gdbpass", "realm", "verifier-keyPath", "verifier-keyID"}
+	persistentFlags.StringVar(&cfgFile, "config", "", "config file, guac.yaml in the home or working directory if not set")
+	persistentFlags.String("gdb-backend", assembler.Neo4jBackend, fmt.Sprintf("backend storing the graph, one of: %s (the memory backend only lasts as long as the process)", strings.Join(assembler.RegisteredBackends(), ", ")))
+	persistentFlags.String("gdbaddr", "neo4j://localhost:7687", "address of the graph db, e.g. neo4j://localhost:7687, postgres://localhost:5432/guac for the postgres backend, or http://localhost:8529/guac for the arangodb backend")
+	persistentFlags.String("gdbuser", "", "neo4j user credential to connect to graph db")
+	persistentFlags.String("gdbpass", "", "neo4j password credential to connect to graph db")
+	persistentFlags.String("realm", "neo4j", "realm to connect to graph db")
+	persistentFlags.Float64("gdb-write-rate", 0, "maximum write operations per second to the graph db, 0 for unlimited")
+	persistentFlags.Int("gdb-batch-size", assembler.DefaultWriteOptions.BatchSize, "maximum nodes and edges written to the graph db per transaction")
+	persistentFlags.String("verifier-keyPath", "", "path to pem file to verify dsse")
+	persistentFlags.String("verifier-keyID", "", "ID of the key to be stored")
+	persistentFlags.Int("seen-cache-size", 10000, "number of ingested document hashes to remember to skip unchanged documents, 0 to disable")
+	persistentFlags.Duration("seen-cache-ttl", time.Hour, "duration to remember an ingested document hash, 0 to never expire")
+	persistentFlags.String("seen-cache-file", "", "file to persist ingested document hashes across runs")
+	persistentFlags.String("pubsub-addr", "", fmt.Sprintf("address of the message broker between the collectors, processors and ingestors, e.g. %s for NATS JetStream, kafka://localhost:8082 for Kafka through a Kafka REST Proxy, or mem:// to run all of them in this process. Schemes: %s. If empty, the files command runs all the stages in this process without a broker", nats.DefaultURL, strings.Join(emitter.RegisteredBrokers(), ", ")))
+	persistentFlags.String("nats-creds", "", "user credentials file authenticating to NATS")
+	persistentFlags.String("nats-nkey", "", "NKey seed file authenticating to NATS")
+	persistentFlags.String("nats-user", "", "user authenticating to NATS")
+	persistentFlags.String("nats-pass", "", "password authenticating to NATS")
+	persistentFlags.String("nats-tls-cert", "", "path to client certificate pem file for mTLS to NATS")
+	persistentFlags.String("nats-tls-key", "", "path to client key pem file for mTLS to NATS")
+	persistentFlags.String("nats-tls-ca", "", "path to CA pem file to verify the NATS server certificate, connecting over TLS even with a nats:// address")
+	persistentFlags.String("kafka-user", "", "user authenticating to the Kafka REST Proxy")
+	persistentFlags.String("kafka-pass", "", "password authenticating to the Kafka REST Proxy")
+	persistentFlags.String("kafka-topic-prefix", "", "prefix of the Kafka topics of the subjects and of the consumer groups, e.g. guac. for the topic guac.DOCUMENTS.collected")
+	persistentFlags.String("blob-store", "", "blob store the documents larger than blob-threshold are written to, only their reference being published: file:///dir, gs://bucket/prefix or s3://bucket/prefix?region=&endpoint=, the same for all the processes of a pipeline")
+	persistentFlags.Int("blob-threshold", emitter.DefaultBlobThreshold, "size in bytes of the documents, after compression, past which they are written to the blob store")
+	persistentFlags.Bool("nats-compress", false, "gzip the documents published to NATS JetStream or Kafka (subscribers decompress them regardless)")
+	persistentFlags.Duration("nats-dedup-window", emitter.DefaultDuplicateWindow, "window in which NATS JetStream drops documents published again with the same content")
+	persistentFlags.String("nats-storage", "file", "storage of the NATS JetStream stream: file, which survives a restart of the NATS server, or memory")
+	persistentFlags.Int64("nats-max-bytes", 0, "maximum size in bytes of the NATS JetStream stream, past which the oldest documents are discarded, 0 for no limit")
+	persistentFlags.Duration("nats-max-age", 0, "maximum age of the documents in the NATS JetStream stream, 0 for no limit")
+	persistentFlags.Int("nats-replicas", 1, "number of replicas of the NATS JetStream stream in a NATS cluster")
+	persistentFlags.Duration("nats-ack-wait", emitter.DefaultAckWait, "time a processor or ingestor has to handle a document before NATS JetStream delivers it again")
+	persistentFlags.Int("nats-max-deliver", emitter.DefaultMaxDeliver, "number of times NATS JetStream delivers a document that is not handled, 0 for no limit")
+	persistentFlags.Int("nats-max-in-flight", 0, "number of documents NATS JetStream delivers to the replicas of a processor or ingestor without them being handled yet, 0 for the default of the NATS server")
+	persistentFlags.Bool("nats-recreate-stream", false, "delete the documents left on the NATS JetStream stream by previous runs on start, instead of resuming them (not for production)")
+	persistentFlags.String("processor-durable", emitter.DurableProcessor, "durable consumer name shared by the processor replicas, each collected document goes to one of them")
+	persistentFlags.String("ingestor-durable", emitter.DurableIngestor, "durable consumer name shared by the ingestor replicas, each processed document goes to one of them")
+	persistentFlags.Int("ingest-max-attempts", parser.DefaultRetryPolicy.MaxAttempts, "number of times the ingestor tries to store a document tree before moving it to the dead-letter subject")
+	persistentFlags.Duration("ingest-retry-delay", parser.DefaultRetryPolicy.Delay, "delay before the ingestor tries again to store a document tree that failed to store")
+	persistentFlags.Int("dead-letter-attempts", emitter.DefaultDeadLetterAttempts, "number of times a document fails to be processed or ingested before it is moved to the dead-letter subject, at most nats-max-deliver")
+	persistentFlags.String("health-addr", "", "address to serve the /healthz and /readyz endpoints on (e.g. :8080) while a long-running command runs, disabled if empty")
+	persistentFlags.Duration("health-stall-timeout", health.DefaultStallTimeout, "time a document may take to be published or stored before /healthz reports the process stalled, so that it is restarted")
+	persistentFlags.Duration("collector-timeout", 0, "maximum duration of a collection run, after which the collectors are aborted and the documents already collected are processed, 0 for no timeout")
+	persistentFlags.Int("collector-read-attempts", retry.DefaultPolicy.Attempts, "number of times collectors try to read a document before skipping it")
+	persistentFlags.Duration("collector-read-backoff", retry.DefaultPolicy.Backoff, "delay before retrying to read a document, doubled after each retry")
+	persistentFlags.String("collector-name", "", "name labelling the logs of the documents collected, defaults to the collector type, e.g. file")
+	persistentFlags.Int("partitions", 1, "number of partitions the documents are spread over by source, each handled in order by one processor or ingestor replica at a time, the same for all the processes of a pipeline")
+	persistentFlags.Float64("publish-rate", 0, "maximum documents per second published by the collector, processor or ingestor, 0 for no limit")
+	persistentFlags.Uint64("max-pending", 0, "number of documents waiting for the processor or ingestor past which publishing to them waits for them to catch up, 0 for no limit (NATS JetStream and mem:// only)")
+	persistentFlags.Duration("pending-log-interval", 0, "interval at which to log the number of documents waiting on each subject, 0 to disable")
+	persistentFlags.Duration("emit-interval", 0, "minimum time between two collected documents being emitted, e.g. 10ms for at most 100 documents per second, 0 for no limit")
+	persistentFlags.String("digest-algorithm", hashcache.DefaultDigestAlgorithm, "digest algorithm identifying documents to drop duplicates and skip unchanged ones: sha256 or sha512")
+	persistentFlags.String("otlp-endpoint", "", "OTLP/HTTP endpoint of an OpenTelemetry collector to export the traces of the documents to (e.g. http://localhost:4318), defaults to OTEL_EXPORTER_OTLP_ENDPOINT, tracing is disabled if empty")
+	persistentFlags.String("otlp-headers", "", "headers sent with each export of the traces, e.g. api-key=secret,tenant=guac, with URL-encoded values, defaults to OTEL_EXPORTER_OTLP_HEADERS")
+	persistentFlags.String("otlp-service-name", "", "service name the traces are exported under, defaults to OTEL_SERVICE_NAME, then guac-pubsub")
+	persistentFlags.String("metrics-addr", "", "address to serve the Prometheus /metrics endpoint on (e.g. :9090) while a long-running command runs, disabled if empty")
+	persistentFlags.String("quarantine-dir", "", "directory to write documents of unsupported types or formats to for later inspection")
+	persistentFlags.String("log-level", "info", "log level: debug, info, warn or error")
+	persistentFlags.String("log-components", "", "per component log levels overriding log-level, e.g. collector=debug,assembler=warn")
+	persistentFlags.String("predicate-mapping", "", "YAML file mapping in-toto predicate types to the edges and attributes to ingest")
+	flagNames := []string{"gdb-backend", "gdbaddr", "gdbuser", "gdbpass", "realm", "gdb-write-rate", "gdb-batch-size", "verifier-keyPath", "verifier-keyID",
+		"seen-cache-size", "seen-cache-ttl", "seen-cache-file", "health-addr", "health-stall-timeout",
+		"pubsub-addr", "nats-creds", "nats-nkey", "nats-user", "nats-pass", "nats-tls-cert", "nats-tls-key", "nats-tls-ca", "kafka-user", "kafka-pass", "kafka-topic-prefix", "blob-store", "blob-threshold", "nats-compress", "nats-dedup-window",
+		"nats-storage", "nats-max-bytes", "nats-max-age", "nats-replicas", "nats-ack-wait", "nats-max-deliver", "nats-max-in-flight", "nats-recreate-stream",
+		"processor-durable", "ingestor-durable", "ingest-max-attempts", "ingest-retry-delay", "dead-letter-attempts",
+		"log-level", "log-components", "collector-timeout", "predicate-mapping",
+		"collector-read-attempts", "collector-read-backoff", "emit-interval", "partitions", "publish-rate", "max-pending", "pending-log-interval", "collector-name",
+		"quarantine-dir", "digest-algorithm", "otlp-endpoint", "otlp-headers", "otlp-service-name",
+		"metrics-addr"}
 	for _, name := range flagNames {
 		if flag := persistentFlags.Lookup(name); flag != nil {
-			if err := viper.BindPFlag(name, flag); err != nil {
+			if err := viper.BindPFlag(config.Key(name), flag); err != nil {
 				fmt.Fprintf(os.Stderr, "failed to bind flag: %v", err)
 				os.Exit(1)
 			}
@@ -53,27 +135,139 @@ func initConfig() {
 	ctx := logging.WithLogger(context.Background())
 	logger := logging.FromContext(ctx)
 
-	if cfgFile != "" {
-		viper.SetConfigFile(cfgFile)
-	} else {
-		home, err := homedir.Dir()
+	configFile, err := config.Read(viper.GetViper(), cfgFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	guacConfig, err = config.Load(viper.GetViper())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid config: %v\n", err)
+		os.Exit(1)
+	}
+	if err := logging.Configure(guacConfig.Log.Level, guacConfig.Log.Components); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to configure logging: %v\n", err)
+		os.Exit(1)
+	}
+	if configFile != "" {
+		logger.Infof("Using config file: %s", configFile)
+	}
+	retryPolicy := retry.DefaultPolicy
+	retryPolicy.Attempts = guacConfig.Collector.ReadAttempts
+	retryPolicy.Backoff = guacConfig.Collector.ReadBackoff
+	if err := retry.SetPolicy(retryPolicy); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid collector read retry policy: %v\n", err)
+		os.Exit(1)
+	}
+	collector.SetCollectorName(guacConfig.Collector.Name)
+	if err := collector.SetEmitInterval(guacConfig.Collector.EmitInterval); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid emit interval: %v\n", err)
+		os.Exit(1)
+	}
+	if err := emitter.SetFlowControl(emitter.FlowControl{
+		PublishRate: guacConfig.Broker.PublishRate,
+		MaxPending:  guacConfig.Broker.MaxPending,
+	}); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid flow control: %v\n", err)
+		os.Exit(1)
+	}
+	offload := emitter.BlobOffload{Threshold: guacConfig.Broker.Blob.Threshold}
+	if uri := guacConfig.Broker.Blob.Store; uri != "" {
+		store, err := blob.NewStore(ctx, uri)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "failed to get user home directory: %v\n", err)
+			fmt.Fprintf(os.Stderr, "invalid blob store: %v\n", err)
 			os.Exit(1)
 		}
-
-		viper.AddConfigPath(home)
-		viper.AddConfigPath(".")
-		viper.SetConfigName("guac")
-		viper.SetConfigType("yaml")
+		offload.Store = store
+	}
+	if err := emitter.SetBlobOffload(offload); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid blob offload: %v\n", err)
+		os.Exit(1)
+	}
+	if err := emitter.SetPartitions(guacConfig.Broker.Partitions); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid partitions: %v\n", err)
+		os.Exit(1)
+	}
+	if err := emitter.ValidateDurableName(guacConfig.Processor.Durable); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid processor durable name: %v\n", err)
+		os.Exit(1)
+	}
+	if err := emitter.ValidateDurableName(guacConfig.Ingestor.Durable); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid ingestor durable name: %v\n", err)
+		os.Exit(1)
 	}
+	if err := parser.SetRetryPolicy(parser.RetryPolicy{
+		MaxAttempts: guacConfig.Ingestor.MaxAttempts,
+		Delay:       guacConfig.Ingestor.RetryDelay,
+	}); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid ingest retry policy: %v\n", err)
+		os.Exit(1)
+	}
+	if err := emitter.SetDeadLetterAttempts(guacConfig.Broker.DeadLetterAttempts); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid dead-letter attempts: %v\n", err)
+		os.Exit(1)
+	}
+	if err := hashcache.SetDigestAlgorithm(guacConfig.Processor.DigestAlgorithm); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid digest algorithm: %v\n", err)
+		os.Exit(1)
+	}
+	// the flags and guac.yaml take precedence over the OTEL_* variables
+	otlpConfig, err := guacConfig.Tracing.OTLPConfig("guac-pubsub")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid tracing config: %v\n", err)
+		os.Exit(1)
+	}
+	if err := tracing.ConfigureOTLP(ctx, otlpConfig); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to configure tracing: %v\n", err)
+		os.Exit(1)
+	}
+	if err := process.SetQuarantineDir(guacConfig.Processor.QuarantineDir); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid quarantine directory: %v\n", err)
+		os.Exit(1)
+	}
+	if path := guacConfig.Ingestor.PredicateMapping; path != "" {
+		mapping, err := ite6.LoadPredicateMapping(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to load predicate mapping: %v\n", err)
+			os.Exit(1)
+		}
+		ite6.SetPredicateMapping(mapping)
+		logger.Infof("Using predicate mapping: %s", path)
+	}
+}
 
-	viper.AutomaticEnv()
-	viper.SetEnvPrefix("guac")
+// serveMetrics serves the metrics on metrics-addr, if set, until ctx is done
+func serveMetrics(ctx context.Context) {
+	addr := guacConfig.Metrics.Addr
+	if addr == "" {
+		return
+	}
+	go func() {
+		if err := metrics.ListenAndServe(ctx, addr); err != nil {
+			logging.FromContext(ctx).Errorf("metrics server ended with error: %v", err)
+		}
+	}()
+}
 
-	if err := viper.ReadInConfig(); err == nil {
-		logger.Infof("Using config file: %s", viper.ConfigFileUsed())
+// serveHealth serves /healthz right away and /readyz once marked started on
+// health-addr, if set, until ctx is done. The liveness endpoint reports the
+// process stalled once a document is published or stored for longer than
+// health-stall-timeout, as watched by the returned watchdog. Both are nil if
+// health-addr is not set.
+func serveHealth(ctx context.Context) (*health.Server, *health.Watchdog) {
+	addr := guacConfig.Health.Addr
+	if addr == "" {
+		return nil, nil
 	}
+	healthServer := health.NewServer(addr)
+	watchdog := health.NewWatchdog(guacConfig.Health.StallTimeout)
+	healthServer.AddLivenessCheck("stall", watchdog.Check)
+	go func() {
+		if err := healthServer.ListenAndServe(ctx); err != nil {
+			logging.FromContext(ctx).Errorf("health server ended with error: %v", err)
+		}
+	}()
+	return healthServer, watchdog
 }
 
 var rootCmd = &cobra.Command{
@@ -82,7 +276,14 @@ var rootCmd = &cobra.Command{
 }
 
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
+	err := rootCmd.Execute()
+	// export the spans of the documents not exported yet
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if shutdownErr := tracing.Shutdown(shutdownCtx); shutdownErr != nil {
+		fmt.Fprintf(os.Stderr, "failed to export traces: %v\n", shutdownErr)
+	}
+	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
@@ -17,6 +17,8 @@ package mockverifier
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 
 	"github.com/guacsec/guac/internal/testing/testdata"
 	"github.com/guacsec/guac/pkg/ingestor/key"
@@ -30,7 +32,16 @@ func NewMockSigstoreVerifier() *mockSigstoreVerifier {
 	return &mockSigstoreVerifier{}
 }
 
+// Verify returns the test identity, verified, for any envelope with a
+// signature, and fails for the others
 func (m *mockSigstoreVerifier) Verify(ctx context.Context, payloadBytes []byte) ([]verifier.Identity, error) {
+	envelope := dsse.Envelope{}
+	if err := json.Unmarshal(payloadBytes, &envelope); err != nil {
+		return nil, err
+	}
+	if len(envelope.Signatures) == 0 {
+		return nil, errors.New("no signature to verify")
+	}
 
 	keyHash, _ := dsse.SHA256KeyID(testdata.EcdsaPubKey)
 	return []verifier.Identity{
@@ -35,10 +35,12 @@ func NewNatsTestServer() *natsTestServer {
 	return &natsTestServer{}
 }
 
-func runServerOnPort(port int) (*server.Server, error) {
+func runServerOnPort(port int, user string, password string) (*server.Server, error) {
 	opts := natsserver.DefaultTestOptions
 	opts.Host = TEST_HOST
 	opts.Port = port
+	opts.Username = user
+	opts.Password = password
 	return runServerWithOptions(&opts), nil
 }
 
@@ -47,11 +49,17 @@ func runServerWithOptions(opts *server.Options) *server.Server {
 }
 
 func (n *natsTestServer) EnableJetStreamForTest() (string, error) {
+	return n.EnableJetStreamWithUserForTest("", "")
+}
+
+// EnableJetStreamWithUserForTest is like EnableJetStreamForTest, with the
+// clients authenticating as user with password if user is set
+func (n *natsTestServer) EnableJetStreamWithUserForTest(user string, password string) (string, error) {
 	port, err := getFreePort()
 	if err != nil {
 		return "", err
 	}
-	s, err := runServerOnPort(port)
+	s, err := runServerOnPort(port, user, password)
 	if err != nil {
 		return "", err
 	}
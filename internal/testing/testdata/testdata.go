@@ -20,6 +20,7 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"reflect"
+	"time"
 
 	"github.com/guacsec/guac/internal/testing/keyutil"
 	"github.com/guacsec/guac/pkg/assembler"
@@ -33,6 +34,12 @@ var (
 	//go:embed exampledata/small-spdx.json
 	SpdxExampleSmall []byte
 
+	//go:embed exampledata/small-spdx.spdx
+	SpdxExampleSmallTagValue []byte
+
+	//go:embed exampledata/small-spdx.yaml
+	SpdxExampleSmallYAML []byte
+
 	//go:embed exampledata/alpine-spdx.json
 	SpdxExampleBig []byte
 
@@ -60,6 +67,9 @@ var (
 	//go:embed exampledata/small-deps-cyclonedx.json
 	CycloneDXExampleSmallDeps []byte
 
+	//go:embed exampledata/small-deps-cyclonedx.xml
+	CycloneDXExampleSmallDepsXML []byte
+
 	//go:embed exampledata/invalid-cyclonedx.json
 	CycloneDXInvalidExample []byte
 
@@ -75,6 +85,28 @@ var (
 	//go:embed exampledata/npm-cyclonedx-dependencies-missing-depends-on.json
 	CycloneDXDependenciesMissingDependsOn []byte
 
+	//go:embed exampledata/cyclonedx-vex.json
+	CycloneDXVEXExample []byte
+
+	//go:embed exampledata/openvex.json
+	OpenVEXExample []byte
+
+	//go:embed exampledata/csaf-vex.json
+	CsafVEXExample []byte
+
+	//go:embed exampledata/osv-entry.json
+	OSVExample []byte
+
+	//go:embed exampledata/syft-alpine.json
+	SyftExample []byte
+
+	//go:embed exampledata/sarif.json
+	SARIFExample []byte
+
+	// based off https://docs.github.com/en/rest/dependency-graph/dependency-submission
+	//go:embed exampledata/github-dependency-snapshot.json
+	DependencySnapshotExample []byte
+
 	//go:embed exampledata/crev-review.json
 	ITE6CREVExample []byte
 
@@ -175,8 +207,9 @@ var (
 	}
 
 	att = assembler.AttestationNode{
-		FilePath: "TestSource",
-		Digest:   "sha256:cf194aa4315da360a262ff73ce63e2ff68a128c3a9ee7d97163c998fd1690cec",
+		FilePath:      "TestSource",
+		Digest:        "sha256:cf194aa4315da360a262ff73ce63e2ff68a128c3a9ee7d97163c998fd1690cec",
+		StatementTime: time.Date(2020, 8, 19, 8, 38, 0, 0, time.UTC),
 		NodeData: *assembler.NewObjectMetadata(
 			processor.SourceInformation{
 				Collector: "TestCollector",
@@ -208,8 +241,7 @@ var (
 	}
 
 	build = assembler.BuilderNode{
-		BuilderType: "https://github.com/Attestations/GitHubActionsWorkflow@v1",
-		BuilderId:   "https://github.com/Attestations/GitHubHostedActions@v1",
+		BuilderId: "https://github.com/Attestations/GitHubHostedActions@v1",
 		NodeData: *assembler.NewObjectMetadata(
 			processor.SourceInformation{
 				Collector: "TestCollector",
@@ -244,6 +276,11 @@ var (
 			IdentityNode:    Ident,
 			AttestationNode: att,
 		},
+		assembler.BuiltByEdge{
+			AttestationNode: att,
+			BuilderNode:     build,
+			BuildType:       "https://github.com/Attestations/GitHubActionsWorkflow@v1",
+		},
 		assembler.BuiltByEdge{
 			ArtifactNode: art,
 			BuilderNode:  build,
@@ -252,6 +289,9 @@ var (
 			AttestationNode: att,
 			ForArtifact:     art,
 		},
+		assembler.PackageOfDigestEdge{
+			ArtifactNode: art,
+		},
 		assembler.DependsOnEdge{
 			ArtifactNode:       art,
 			ArtifactDependency: mat1,
@@ -376,8 +416,61 @@ var (
 		),
 	}
 
-	SpdxNodes = []assembler.GuacNode{topLevelPack, baselayoutPack, baselayoutdataPack, rsaPubFile, keysPack, worldFile, rootFile, triggersFile}
+	gpl2License = assembler.LicenseNode{
+		ID: "GPL-2.0-only",
+		NodeData: *assembler.NewObjectMetadata(
+			processor.SourceInformation{
+				Collector: "TestCollector",
+				Source:    "TestSource",
+			},
+		),
+	}
+
+	mitLicense = assembler.LicenseNode{
+		ID: "MIT",
+		NodeData: *assembler.NewObjectMetadata(
+			processor.SourceInformation{
+				Collector: "TestCollector",
+				Source:    "TestSource",
+			},
+		),
+	}
+
+	spdxDocument = assembler.MetadataNode{
+		MetadataType: "document",
+		ID:           "https://anchore.com/syft/image/alpine-latest-c0cfbad1-8067-44d4-821e-0790f8d9d110",
+		Details: map[string]interface{}{
+			"format":      "spdx",
+			"specVersion": "2.2",
+			"source":      "TestSource",
+			"collector":   "TestCollector",
+		},
+	}
+
+	SpdxNodes = []assembler.GuacNode{spdxDocument, topLevelPack, baselayoutPack, baselayoutdataPack, rsaPubFile, keysPack, worldFile, rootFile, triggersFile, gpl2License, gpl2License, mitLicense}
 	SpdxEdges = []assembler.GuacEdge{
+		assembler.MetadataForEdge{
+			MetadataNode: spdxDocument,
+			ForPackage:   topLevelPack,
+		},
+		assembler.HasLicenseEdge{
+			PackageNode:  baselayoutPack,
+			LicenseNode:  gpl2License,
+			Expression:   "GPL-2.0-only",
+			Alternatives: []string{"GPL-2.0-only"},
+		},
+		assembler.HasLicenseEdge{
+			PackageNode:  baselayoutdataPack,
+			LicenseNode:  gpl2License,
+			Expression:   "GPL-2.0-only",
+			Alternatives: []string{"GPL-2.0-only"},
+		},
+		assembler.HasLicenseEdge{
+			PackageNode:  keysPack,
+			LicenseNode:  mitLicense,
+			Expression:   "MIT",
+			Alternatives: []string{"MIT"},
+		},
 		assembler.DependsOnEdge{
 			PackageNode:       topLevelPack,
 			PackageDependency: baselayoutPack,
@@ -486,8 +579,29 @@ var (
 		),
 	}
 
-	CycloneDXNodes = []assembler.GuacNode{cdxTopLevelPack, cdxBasefilesPack, cdxNetbasePack, cdxTzdataPack}
+	cdxDistrolessDocument = assembler.MetadataNode{
+		MetadataType: "document",
+		ID:           "urn:uuid:6a44e622-2983-4566-bf90-f87b6103ebaf",
+		Details: map[string]interface{}{
+			"format":      "cyclonedx",
+			"specVersion": "1.4",
+			"source":      "TestSource",
+			"collector":   "TestCollector",
+		},
+	}
+
+	CycloneDXNodes = []assembler.GuacNode{cdxDistrolessDocument, cdxTopLevelPack, cdxBasefilesPack, cdxNetbasePack, cdxTzdataPack, gpl2License}
 	CyloneDXEdges  = []assembler.GuacEdge{
+		assembler.MetadataForEdge{
+			MetadataNode: cdxDistrolessDocument,
+			ForPackage:   cdxTopLevelPack,
+		},
+		assembler.HasLicenseEdge{
+			PackageNode:  cdxNetbasePack,
+			LicenseNode:  gpl2License,
+			Expression:   "GPL-2.0-only",
+			Alternatives: []string{"GPL-2.0-only"},
+		},
 		assembler.DependsOnEdge{
 			PackageDependency: cdxBasefilesPack,
 			PackageNode:       cdxTopLevelPack,
@@ -518,8 +632,11 @@ var (
 	}
 
 	cdxResteasyPack = assembler.PackageNode{
-		Name:    "quarkus-resteasy-reactive",
-		Digest:  nil,
+		Name: "quarkus-resteasy-reactive",
+		Digest: []string{
+			"md5:bf39044af8c6ba66fc3beb034bc82ae8",
+			"sha3-512:615e56bdfeb591af8b5fdeadf019f8fa729643232d7e0768674411a7d959bb00e12e114280a6949f871514e1a86e01e0033372a0a826d15720050d7cffb80e69",
+		},
 		Version: "2.13.4.Final",
 		Purl:    "pkg:maven/io.quarkus/quarkus-resteasy-reactive@2.13.4.Final?type=jar",
 		CPEs:    nil,
@@ -533,7 +650,7 @@ var (
 
 	cdxReactiveCommonPack = assembler.PackageNode{
 		Name:    "quarkus-resteasy-reactive-common",
-		Digest:  nil,
+		Digest:  []string{"sha3-512:54ffa51cb2fb25e70871e4b69489814ebb3d23d4f958e83ef1f811c00a8753c6c30c5bbc1b48b6427357eb70e5c35c7b357f5252e246fbfa00b90ee22ad095e1"},
 		Version: "2.13.4.Final",
 		Purl:    "pkg:maven/io.quarkus/quarkus-resteasy-reactive-common@2.13.4.Final?type=jar",
 		CPEs:    nil,
@@ -545,8 +662,57 @@ var (
 		),
 	}
 
-	CycloneDXQuarkusNodes = []assembler.GuacNode{cdxTopQuarkusPack, cdxResteasyPack, cdxReactiveCommonPack}
-	CyloneDXQuarkusEdges  = []assembler.GuacEdge{
+	apacheLicense = assembler.LicenseNode{
+		ID: "Apache-2.0",
+		NodeData: *assembler.NewObjectMetadata(
+			processor.SourceInformation{
+				Collector: "TestCollector",
+				Source:    "TestSource",
+			},
+		),
+	}
+
+	cdxResteasyReferences = []assembler.ReferencesEdge{
+		quarkusReference(cdxResteasyPack, "distribution", "https://s01.oss.sonatype.org/service/local/staging/deploy/maven2/"),
+		quarkusReference(cdxResteasyPack, "issue-tracker", "https://github.com/quarkusio/quarkus/issues/"),
+		quarkusReference(cdxResteasyPack, "vcs", "https://github.com/quarkusio/quarkus"),
+		quarkusReference(cdxResteasyPack, "website", "http://www.jboss.org"),
+		quarkusReference(cdxResteasyPack, "mailing-list", "http://lists.jboss.org/pipermail/jboss-user/"),
+	}
+	cdxReactiveCommonReferences = []assembler.ReferencesEdge{
+		quarkusReference(cdxReactiveCommonPack, "mailing-list", "http://lists.jboss.org/pipermail/jboss-user/"),
+	}
+
+	cdxQuarkusDocument = assembler.MetadataNode{
+		MetadataType: "document",
+		ID:           "urn:uuid:0697952e-9848-4785-95bf-f81ff9731682",
+		Details: map[string]interface{}{
+			"format":      "cyclonedx",
+			"specVersion": "1.4",
+			"source":      "TestSource",
+			"collector":   "TestCollector",
+		},
+	}
+
+	CycloneDXQuarkusNodes = append([]assembler.GuacNode{cdxQuarkusDocument, cdxTopQuarkusPack, cdxResteasyPack, cdxReactiveCommonPack, apacheLicense, apacheLicense},
+		referenceNodes(cdxResteasyReferences, cdxReactiveCommonReferences)...)
+	CyloneDXQuarkusEdges = append([]assembler.GuacEdge{
+		assembler.MetadataForEdge{
+			MetadataNode: cdxQuarkusDocument,
+			ForPackage:   cdxTopQuarkusPack,
+		},
+		assembler.HasLicenseEdge{
+			PackageNode:  cdxResteasyPack,
+			LicenseNode:  apacheLicense,
+			Expression:   "Apache-2.0",
+			Alternatives: []string{"Apache-2.0"},
+		},
+		assembler.HasLicenseEdge{
+			PackageNode:  cdxReactiveCommonPack,
+			LicenseNode:  apacheLicense,
+			Expression:   "Apache-2.0",
+			Alternatives: []string{"Apache-2.0"},
+		},
 		assembler.DependsOnEdge{
 			PackageDependency: cdxResteasyPack,
 			PackageNode:       cdxTopQuarkusPack,
@@ -559,7 +725,7 @@ var (
 			PackageDependency: cdxReactiveCommonPack,
 			PackageNode:       cdxResteasyPack,
 		},
-	}
+	}, referenceEdges(cdxResteasyReferences, cdxReactiveCommonReferences)...)
 
 	cdxWebAppPackage = assembler.PackageNode{
 		Name:    "web-app",
@@ -589,17 +755,133 @@ var (
 		),
 	}
 
+	cdxWebAppDocument = assembler.MetadataNode{
+		MetadataType: "document",
+		ID:           "sha256:35363f03c80f26a88db6f2400771bdcc6624bb7b61b96da8503be0f757605fde",
+		Details: map[string]interface{}{
+			"format":      "cyclonedx",
+			"specVersion": "1.4",
+			"source":      "TestSource",
+			"collector":   "TestCollector",
+		},
+	}
+
 	NpmMissingDependsOnCycloneDXNodes = []assembler.GuacNode{
+		cdxWebAppDocument,
 		cdxWebAppPackage,
 		cdxBootstrapPackage,
 	}
 	NpmMissingDependsOnCycloneDXEdges = []assembler.GuacEdge{
+		assembler.MetadataForEdge{
+			MetadataNode: cdxWebAppDocument,
+			ForPackage:   cdxWebAppPackage,
+		},
 		assembler.DependsOnEdge{
 			PackageDependency: cdxBootstrapPackage,
 			PackageNode:       cdxWebAppPackage,
 		},
 	}
 
+	// CycloneDX VEX testdata
+	cdxAcmeAppPack = assembler.PackageNode{
+		Name:    "acme-app",
+		Version: "1.0.0",
+		Purl:    "pkg:maven/com.acme/acme-app@1.0.0?type=jar",
+		Tags:    []string{"application"},
+		NodeData: *assembler.NewObjectMetadata(
+			processor.SourceInformation{
+				Collector: "TestCollector",
+				Source:    "TestSource",
+			},
+		),
+	}
+	cdxLog4jPack = assembler.PackageNode{
+		Name:    "log4j-core",
+		Version: "2.14.1",
+		Purl:    "pkg:maven/org.apache.logging.log4j/log4j-core@2.14.1?type=jar",
+		NodeData: *assembler.NewObjectMetadata(
+			processor.SourceInformation{
+				Collector: "TestCollector",
+				Source:    "TestSource",
+			},
+		),
+	}
+	cdxJacksonPack = assembler.PackageNode{
+		Name:    "jackson-databind",
+		Version: "2.13.0",
+		Purl:    "pkg:maven/com.fasterxml.jackson.core/jackson-databind@2.13.0?type=jar",
+		NodeData: *assembler.NewObjectMetadata(
+			processor.SourceInformation{
+				Collector: "TestCollector",
+				Source:    "TestSource",
+			},
+		),
+	}
+	cdxLog4ShellVuln = assembler.VulnerabilityNode{
+		ID: "CVE-2021-44228",
+		NodeData: *assembler.NewObjectMetadata(
+			processor.SourceInformation{
+				Collector: "TestCollector",
+				Source:    "TestSource",
+			},
+		),
+	}
+	cdxJacksonVuln = assembler.VulnerabilityNode{
+		ID: "GHSA-57j2-w4cx-62h2",
+		NodeData: *assembler.NewObjectMetadata(
+			processor.SourceInformation{
+				Collector: "TestCollector",
+				Source:    "TestSource",
+			},
+		),
+	}
+
+	cdxVEXDocument = assembler.MetadataNode{
+		MetadataType: "document",
+		ID:           "urn:uuid:3e671687-395b-41f5-a30f-a58921a69b79",
+		Details: map[string]interface{}{
+			"format":      "cyclonedx",
+			"specVersion": "1.4",
+			"source":      "TestSource",
+			"collector":   "TestCollector",
+		},
+	}
+
+	CycloneDXVEXNodes = []assembler.GuacNode{cdxVEXDocument, cdxAcmeAppPack, cdxLog4jPack, cdxJacksonPack, cdxLog4ShellVuln, cdxJacksonVuln}
+	CycloneDXVEXEdges = []assembler.GuacEdge{
+		assembler.MetadataForEdge{
+			MetadataNode: cdxVEXDocument,
+			ForPackage:   cdxAcmeAppPack,
+		},
+		assembler.DependsOnEdge{
+			PackageDependency: cdxLog4jPack,
+			PackageNode:       cdxAcmeAppPack,
+		},
+		assembler.DependsOnEdge{
+			PackageDependency: cdxJacksonPack,
+			PackageNode:       cdxAcmeAppPack,
+		},
+		assembler.AffectsEdge{
+			VulnerabilityNode: cdxLog4ShellVuln,
+			PackageNode:       cdxLog4jPack,
+			AnalysisState:     "exploitable",
+			Score:             10.0,
+			ScoreMethod:       "CVSSv31",
+		},
+		assembler.AffectsEdge{
+			VulnerabilityNode: cdxJacksonVuln,
+			PackageNode:       cdxJacksonPack,
+			AnalysisState:     "not_affected",
+			Justification:     "code_not_reachable",
+		},
+		assembler.AffectsEdge{
+			VulnerabilityNode: cdxJacksonVuln,
+			PackageNode:       cdxAcmeAppPack,
+			AnalysisState:     "not_affected",
+			Justification:     "code_not_reachable",
+		},
+	}
+
 	// ceritifer testdata
 
 	Text4ShellVulAttestation = `{
@@ -798,6 +1080,44 @@ var (
 	}
 )
 
+// quarkusReference returns the edge from pkg to the external reference url
+// found in the quarkus CycloneDX document
+func quarkusReference(pkg assembler.PackageNode, refType string, url string) assembler.ReferencesEdge {
+	return assembler.ReferencesEdge{
+		PackageNode: pkg,
+		ExternalReferenceNode: assembler.ExternalReferenceNode{
+			URL: url,
+			NodeData: *assembler.NewObjectMetadata(
+				processor.SourceInformation{
+					Collector: "TestCollector",
+					Source:    "TestSource",
+				},
+			),
+		},
+		ReferenceType: refType,
+	}
+}
+
+func referenceNodes(refs ...[]assembler.ReferencesEdge) []assembler.GuacNode {
+	nodes := []assembler.GuacNode{}
+	for _, list := range refs {
+		for _, r := range list {
+			nodes = append(nodes, r.ExternalReferenceNode)
+		}
+	}
+	return nodes
+}
+
+func referenceEdges(refs ...[]assembler.ReferencesEdge) []assembler.GuacEdge {
+	edges := []assembler.GuacEdge{}
+	for _, list := range refs {
+		for _, r := range list {
+			edges = append(edges, r)
+		}
+	}
+	return edges
+}
+
 func GuacNodeSliceEqual(slice1, slice2 []assembler.GuacNode) bool {
 	if len(slice1) != len(slice2) {
 		return false
@@ -850,6 +1170,27 @@ func GuacNodeSliceEqual(slice1, slice2 []assembler.GuacNode) bool {
 						break
 					}
 				}
+			} else if node1.Type() == "License" && node2.Type() == "License" {
+				if node1.(assembler.LicenseNode).ID == node2.(assembler.LicenseNode).ID {
+					if reflect.DeepEqual(node1, node2) {
+						e = true
+						break
+					}
+				}
+			} else if node1.Type() == "Metadata" && node2.Type() == "Metadata" {
+				if node1.(assembler.MetadataNode).ID == node2.(assembler.MetadataNode).ID {
+					if reflect.DeepEqual(node1, node2) {
+						e = true
+						break
+					}
+				}
+			} else if node1.Type() == "ExternalReference" && node2.Type() == "ExternalReference" {
+				if node1.(assembler.ExternalReferenceNode).URL == node2.(assembler.ExternalReferenceNode).URL {
+					if reflect.DeepEqual(node1, node2) {
+						e = true
+						break
+					}
+				}
 			}
 		}
 		if !e {
@@ -899,6 +1240,31 @@ func GuacEdgeSliceEqual(slice1, slice2 []assembler.GuacEdge) bool {
 					e = true
 					break
 				}
+			} else if edge1.Type() == "Affects" && edge2.Type() == "Affects" {
+				if reflect.DeepEqual(edge1, edge2) {
+					e = true
+					break
+				}
+			} else if edge1.Type() == "HasLicense" && edge2.Type() == "HasLicense" {
+				if reflect.DeepEqual(edge1, edge2) {
+					e = true
+					break
+				}
+			} else if edge1.Type() == "MetadataFor" && edge2.Type() == "MetadataFor" {
+				if reflect.DeepEqual(edge1, edge2) {
+					e = true
+					break
+				}
+			} else if edge1.Type() == "References" && edge2.Type() == "References" {
+				if reflect.DeepEqual(edge1, edge2) {
+					e = true
+					break
+				}
+			} else if edge1.Type() == "PackageOf" && edge2.Type() == "PackageOf" {
+				if reflect.DeepEqual(edge1, edge2) {
+					e = true
+					break
+				}
 			}
 		}
 		if !e {
@@ -0,0 +1,937 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package assembler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/guacsec/guac/pkg/assembler/graphdb"
+	"github.com/guacsec/guac/pkg/logging"
+	"github.com/guacsec/guac/pkg/tracing"
+)
+
+// Collections of the graph in ArangoDB
+const (
+	arangoNodes = "nodes"
+	arangoEdges = "edges"
+)
+
+const (
+	// arangoBatchSize is the number of results read per request of a cursor
+	arangoBatchSize = 1000
+	// arangoDuplicateName is the error number of a collection created twice
+	arangoDuplicateName = 1207
+)
+
+// arangoIndexes index the nodes by nodeKey, label, digests of artifacts,
+// purl and digest of the other nodes such as documents, and the edges by
+// nodes and label as there is at most one edge of a label from a node to
+// another. Both are indexed by the documents asserting them.
+var arangoIndexes = []struct {
+	collection string
+	index      map[string]interface{}
+}{
+	{arangoNodes, map[string]interface{}{"type": "persistent", "name": "nodes_key", "fields": []string{"key"}, "unique": true}},
+	{arangoNodes, map[string]interface{}{"type": "persistent", "name": "nodes_label", "fields": []string{"label"}}},
+	{arangoNodes, map[string]interface{}{"type": "persistent", "name": "nodes_digests", "fields": []string{"digests[*]"}, "sparse": true}},
+	{arangoNodes, map[string]interface{}{"type": "persistent", "name": "nodes_purl", "fields": []string{"properties.purl"}, "sparse": true}},
+	{arangoNodes, map[string]interface{}{"type": "persistent", "name": "nodes_digest", "fields": []string{"properties.digest"}, "sparse": true}},
+	{arangoNodes, map[string]interface{}{"type": "persistent", "name": "nodes_documents", "fields": []string{"properties.documents[*]"}, "sparse": true}},
+	{arangoEdges, map[string]interface{}{"type": "persistent", "name": "edges_label", "fields": []string{"_from", "_to", "label"}, "unique": true}},
+	{arangoEdges, map[string]interface{}{"type": "persistent", "name": "edges_documents", "fields": []string{"properties.documents[*]"}, "sparse": true}},
+}
+
+// A node or edge written replaces the properties of the stored one, a null
+// value removing the property as in Neo4j, and adds its documents to the
+// stored ones. The nodes and edges of a batch are merged by one query each.
+const (
+	arangoMergeNodes = `FOR n IN @nodes
+UPSERT { key: n.key } INSERT n.insert
+UPDATE MERGE_RECURSIVE(n.update, { properties: { documents: LENGTH(n.documents) == 0 ? OLD.properties.documents : APPEND(OLD.properties.documents || [], n.documents, true) } }) IN nodes
+OPTIONS { keepNull: false, mergeObjects: true }
+RETURN { key: NEW.key, id: NEW._id }`
+	arangoMergeEdges = `FOR e IN @edges
+UPSERT { _from: e._from, _to: e._to, label: e.label } INSERT e.insert
+UPDATE MERGE_RECURSIVE(e.update, { properties: { documents: LENGTH(e.documents) == 0 ? OLD.properties.documents : APPEND(OLD.properties.documents || [], e.documents, true) } }) IN edges
+OPTIONS { keepNull: false, mergeObjects: true }`
+	// the documents of the nodes and edges asserted by the document @digest
+	// are updated first, then the ones left without documents are removed,
+	// then the endpoints of the removed edges kept without documents by an
+	// earlier retraction once unlinked, then the document along with its
+	// Supersedes edges
+	arangoFindDocument = `FOR n IN nodes
+FILTER n.label == "Document" AND n.properties.digest == @digest
+RETURN n._key`
+	arangoEdgeEndpoints = `FOR e IN edges
+FILTER e.properties.documents == [@digest]
+FOR id IN [e._from, e._to]
+RETURN DISTINCT PARSE_IDENTIFIER(id).key`
+	arangoRetractEdges = `FOR e IN edges
+FILTER @digest IN e.properties.documents
+LET documents = REMOVE_VALUE(e.properties.documents, @digest)
+UPDATE e WITH { properties: { documents: documents } } IN edges
+RETURN { key: e._key, retracted: LENGTH(documents) == 0 }`
+	arangoRemoveEdges = `FOR key IN @keys
+REMOVE key IN edges
+RETURN key`
+	arangoRetractNodes = `FOR n IN nodes
+FILTER @digest IN n.properties.documents
+LET documents = REMOVE_VALUE(n.properties.documents, @digest)
+UPDATE n WITH { properties: { documents: documents } } IN nodes
+RETURN { key: n._key, retracted: LENGTH(documents) == 0 }`
+	arangoRemoveNodes = `FOR key IN @keys
+LET id = CONCAT("nodes/", key)
+FILTER LENGTH(FOR e IN edges FILTER e._from == id OR e._to == id LIMIT 1 RETURN 1) == 0
+REMOVE key IN nodes
+RETURN key`
+	arangoRemoveUnlinked = `FOR key IN @keys
+LET n = DOCUMENT("nodes", key)
+FILTER n != null AND n.properties.documents == []
+FILTER LENGTH(FOR e IN edges FILTER e._from == n._id OR e._to == n._id LIMIT 1 RETURN 1) == 0
+REMOVE key IN nodes
+RETURN key`
+	arangoRemoveDocument = `FOR key IN @keys
+LET id = CONCAT("nodes/", key)
+LET removed = (FOR e IN edges FILTER e._from == id OR e._to == id REMOVE e IN edges)
+REMOVE key IN nodes`
+	arangoFindArtifacts = `FOR n IN nodes
+FILTER n.label == "Artifact" AND n.digests ANY IN @digests
+SORT TO_NUMBER(n._key)
+RETURN n.properties`
+)
+
+// arangoPathsQuery traverses the edges, with a label in @edges unless
+// empty, from the node @from without repeating an edge, and returns the
+// paths of at most @depth edges reaching the node @to, shortest first
+var arangoPathsQuery = `FOR v, e, p IN 1..@depth OUTBOUND @from edges
+FILTER LENGTH(@edges) == 0 OR p.edges[*].label ALL IN @edges
+FILTER v._id == @to
+SORT LENGTH(p.edges)
+LIMIT @limit
+LET nodes = (FOR n IN p.vertices RETURN ` + arangoStoredNode("n") + `)
+RETURN { nodes: nodes, edges: p.edges[*].label }`
+
+// arangoRootPathsQuery traverses the edges, with a label in @edges unless
+// empty, backwards from the node @id without repeating an edge, and returns
+// the paths of at most @depth edges from a node without such incoming edges,
+// shortest first
+var arangoRootPathsQuery = `FOR v, e, p IN 0..@depth INBOUND @id edges
+FILTER LENGTH(@edges) == 0 OR p.edges[*].label ALL IN @edges
+LET parents = (FOR x IN edges FILTER x._to == v._id AND (LENGTH(@edges) == 0 OR x.label IN @edges) LIMIT 1 RETURN 1)
+FILTER LENGTH(parents) == 0
+SORT LENGTH(p.edges)
+LIMIT @limit
+LET nodes = (FOR n IN p.vertices RETURN ` + arangoStoredNode("n") + `)
+RETURN { nodes: REVERSE(nodes), edges: REVERSE(p.edges[*].label) }`
+
+// arangoStoredNode returns the AQL expression of the StoredNode of the node
+// document v
+func arangoStoredNode(v string) string {
+	return fmt.Sprintf("{ id: TO_NUMBER(%[1]s._key), label: %[1]s.label, properties: %[1]s.properties }", v)
+}
+
+// arangoBackend stores the graph in ArangoDB, for ingest volumes needing its
+// horizontal scaling. Nodes are documents of the nodes collection and edges
+// documents of the edges edge collection, written with UPSERT and read with
+// AQL through the HTTP API. The keys of the nodes are created by the
+// traditional key generator, as increasing numbers in single servers and
+// clusters alike, and are the ids of the nodes.
+type arangoBackend struct {
+	client *http.Client
+	// endpoint is the URL of the database, e.g. http://localhost:8529/_db/guac
+	endpoint string
+	user     string
+	password string
+	opts     WriteOptions
+}
+
+// arangoError is an error returned by the ArangoDB HTTP API
+type arangoError struct {
+	Code    int    `json:"code"`
+	Num     int    `json:"errorNum"`
+	Message string `json:"errorMessage"`
+}
+
+func (e *arangoError) Error() string {
+	return fmt.Sprintf("arangodb error %d (HTTP %d): %s", e.Num, e.Code, e.Message)
+}
+
+type arangoCursor struct {
+	ID      string            `json:"id"`
+	HasMore bool              `json:"hasMore"`
+	Result  []json.RawMessage `json:"result"`
+}
+
+// newArangoBackend connects to the database at config.Address and creates
+// the collections and indexes of the graph if needed
+func newArangoBackend(ctx context.Context, config BackendConfig) (Backend, error) {
+	endpoint, err := arangoEndpoint(config.Address)
+	if err != nil {
+		return nil, err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if config.TLSCertPath != "" {
+		tlsConfig, err := graphdb.LoadClientTLSConfig(config.TLSCertPath, config.TLSKeyPath, config.TLSCAPath)
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = tlsConfig
+	}
+	b := &arangoBackend{
+		client:   &http.Client{Transport: transport},
+		endpoint: endpoint,
+		user:     config.User,
+		password: config.Password,
+		opts:     config.writeOptions(),
+	}
+	if err := b.createSchema(ctx); err != nil {
+		b.client.CloseIdleConnections()
+		return nil, err
+	}
+	return b, nil
+}
+
+// arangoEndpoint returns the URL of the API of the database at address, an
+// http:// or https:// URL whose path is the name of the database, _system if
+// empty
+func arangoEndpoint(address string) (string, error) {
+	u, err := url.Parse(address)
+	if err != nil {
+		return "", fmt.Errorf("invalid arangodb address: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", fmt.Errorf("the arangodb backend requires an http:// or https:// address, got %s://", u.Scheme)
+	}
+	database := strings.Trim(u.Path, "/")
+	if strings.Contains(database, "/") {
+		return "", fmt.Errorf("invalid arangodb database %q", database)
+	}
+	if database == "" {
+		database = "_system"
+	}
+	u.Path = "/_db/" + database
+	u.RawPath = ""
+	u.RawQuery = ""
+	u.Fragment = ""
+	return u.String(), nil
+}
+
+func (b *arangoBackend) createSchema(ctx context.Context) error {
+	for _, c := range []struct {
+		name string
+		kind int
+	}{{arangoNodes, 2}, {arangoEdges, 3}} {
+		err := b.request(ctx, http.MethodPost, "/_api/collection", "", map[string]interface{}{
+			"name":       c.name,
+			"type":       c.kind,
+			"keyOptions": map[string]interface{}{"type": "traditional"},
+		}, nil)
+		var aerr *arangoError
+		if err != nil && !(errors.As(err, &aerr) && aerr.Num == arangoDuplicateName) {
+			return fmt.Errorf("failed to create the %s collection: %w", c.name, err)
+		}
+	}
+	for _, index := range arangoIndexes {
+		if err := b.request(ctx, http.MethodPost, "/_api/index?collection="+index.collection, "", index.index, nil); err != nil {
+			return fmt.Errorf("failed to create the %s index: %w", index.index["name"], err)
+		}
+	}
+	return nil
+}
+
+// request sends body as JSON to the API at path, in the stream transaction
+// trx if not empty, and decodes the response to result if not nil
+func (b *arangoBackend) request(ctx context.Context, method string, path string, trx string, body interface{}, result interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, b.endpoint+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if b.user != "" {
+		req.SetBasicAuth(b.user, b.password)
+	}
+	if trx != "" {
+		req.Header.Set("x-arango-trx-id", trx)
+	}
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		aerr := &arangoError{}
+		if err := json.Unmarshal(data, aerr); err != nil || aerr.Message == "" {
+			aerr = &arangoError{Message: http.StatusText(resp.StatusCode)}
+		}
+		aerr.Code = resp.StatusCode
+		return aerr
+	}
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(data, result)
+}
+
+// query runs the AQL query with bindVars, in the stream transaction trx if
+// not empty, and returns every result, reading all the batches of the cursor
+func (b *arangoBackend) query(ctx context.Context, trx string, query string, bindVars map[string]interface{}) ([]json.RawMessage, error) {
+	var cursor arangoCursor
+	err := b.request(ctx, http.MethodPost, "/_api/cursor", trx, map[string]interface{}{
+		"query":     query,
+		"bindVars":  bindVars,
+		"batchSize": arangoBatchSize,
+	}, &cursor)
+	if err != nil {
+		return nil, err
+	}
+	results := cursor.Result
+	for cursor.HasMore {
+		id := cursor.ID
+		cursor = arangoCursor{}
+		if err := b.request(ctx, http.MethodPut, "/_api/cursor/"+url.PathEscape(id), trx, nil, &cursor); err != nil {
+			return nil, err
+		}
+		results = append(results, cursor.Result...)
+	}
+	return results, nil
+}
+
+// transaction runs work in a stream transaction writing the collections of
+// the graph, committed if work succeeds and aborted otherwise
+func (b *arangoBackend) transaction(ctx context.Context, work func(trx string) error) error {
+	var begin struct {
+		Result struct {
+			ID string `json:"id"`
+		} `json:"result"`
+	}
+	err := b.request(ctx, http.MethodPost, "/_api/transaction/begin", "", map[string]interface{}{
+		"collections": map[string]interface{}{"write": []string{arangoNodes, arangoEdges}},
+	}, &begin)
+	if err != nil {
+		return err
+	}
+	path := "/_api/transaction/" + url.PathEscape(begin.Result.ID)
+	if err := work(begin.Result.ID); err != nil {
+		// abort even if ctx is cancelled, rather than waiting for the
+		// transaction to time out
+		_ = b.request(context.Background(), http.MethodDelete, path, "", nil, nil)
+		return err
+	}
+	return b.request(ctx, http.MethodPut, path, "", nil, nil)
+}
+
+// RetractDocument removes the document with digest from the graph in a
+// single transaction, as RetractDocument does
+func (b *arangoBackend) RetractDocument(ctx context.Context, digest string) (Retraction, error) {
+	digest = strings.ToLower(digest)
+	var r Retraction
+	err := b.transaction(ctx, func(trx string) error {
+		docs, err := b.query(ctx, trx, arangoFindDocument, map[string]interface{}{"digest": digest})
+		if err != nil {
+			return err
+		}
+		if len(docs) == 0 {
+			return ErrNodeNotFound
+		}
+		endpoints, err := b.query(ctx, trx, arangoEdgeEndpoints, map[string]interface{}{"digest": digest})
+		if err != nil {
+			return err
+		}
+		if r.DeletedEdges, r.RetainedEdges, err = b.retract(ctx, trx, arangoRetractEdges, arangoRemoveEdges, digest); err != nil {
+			return err
+		}
+		if r.DeletedNodes, r.RetainedNodes, err = b.retract(ctx, trx, arangoRetractNodes, arangoRemoveNodes, digest); err != nil {
+			return err
+		}
+		if len(endpoints) > 0 {
+			unlinked, err := b.query(ctx, trx, arangoRemoveUnlinked, map[string]interface{}{"keys": endpoints})
+			if err != nil {
+				return err
+			}
+			r.DeletedNodes += int64(len(unlinked))
+		}
+		_, err = b.query(ctx, trx, arangoRemoveDocument, map[string]interface{}{"keys": docs})
+		return err
+	})
+	if err != nil {
+		return Retraction{}, err
+	}
+	return r, nil
+}
+
+// retract removes digest from the documents updated by the retract query,
+// then runs the remove query on the ones left without documents, and returns
+// the number of removed and retained documents
+func (b *arangoBackend) retract(ctx context.Context, trx string, retract string, remove string, digest string) (int64, int64, error) {
+	results, err := b.query(ctx, trx, retract, map[string]interface{}{"digest": digest})
+	if err != nil {
+		return 0, 0, err
+	}
+	keys := []string{}
+	for _, result := range results {
+		var updated struct {
+			Key       string `json:"key"`
+			Retracted bool   `json:"retracted"`
+		}
+		if err := json.Unmarshal(result, &updated); err != nil {
+			return 0, 0, err
+		}
+		if updated.Retracted {
+			keys = append(keys, updated.Key)
+		}
+	}
+	if len(keys) == 0 {
+		return 0, int64(len(results)), nil
+	}
+	removed, err := b.query(ctx, trx, remove, map[string]interface{}{"keys": keys})
+	if err != nil {
+		return 0, 0, err
+	}
+	return int64(len(removed)), int64(len(results) - len(removed)), nil
+}
+
+// StoreGraph merges g into the collections as StoreGraphWithOptions merges
+// it into Neo4j: artifacts are merged with the stored artifacts sharing a
+// digest, and the writes are split in transactions of the batch size of the
+// write options.
+func (b *arangoBackend) StoreGraph(ctx context.Context, g Graph) (err error) {
+	ctx, span := tracing.Start(ctx, "store")
+	span.SetAttribute("guac.nodes", len(g.Nodes))
+	span.SetAttribute("guac.edges", len(g.Edges))
+	start := time.Now()
+	defer func() {
+		span.RecordError(err)
+		span.End()
+		observeStore(ArangoBackend, start, err)
+	}()
+
+	ctx = logging.WithComponent(ctx, logging.ComponentAssembler)
+	g = DeduplicateGraph(g)
+	resolver, err := newArtifactResolver(ctx, g, func(digests []string) ([][]string, error) {
+		return b.findArtifacts(ctx, digests)
+	})
+	if err != nil {
+		return err
+	}
+
+	writes := make([]arangoWrite, 0, len(g.Documents)+len(g.Nodes)+len(g.Edges))
+	for _, doc := range g.Documents {
+		writes = append(writes, arangoWrite{node: doc})
+	}
+	for _, n := range g.Nodes {
+		// the documents asserting n are looked up before it is resolved
+		docs := g.Evidence[nodeKey(n)]
+		n = resolver.resolve(n)
+		if err := checkIdentifiable(n); err != nil {
+			return err
+		}
+		writes = append(writes, arangoWrite{node: n, docs: docs})
+	}
+	for _, e := range g.Edges {
+		docs := g.Evidence[edgeKey(e)]
+		a, u := e.Nodes()
+		a = resolver.resolve(a)
+		if err := checkIdentifiable(a); err != nil {
+			return err
+		}
+		_, matching := e.(MatchingEdge)
+		if !matching {
+			u = resolver.resolve(u)
+			if err := checkIdentifiable(u); err != nil {
+				return err
+			}
+		}
+		writes = append(writes, arangoWrite{edge: e, a: a, u: u, matching: matching, docs: docs})
+	}
+
+	batchSize := b.opts.batchSize()
+	for len(writes) > 0 {
+		batch := writes
+		if len(batch) > batchSize {
+			batch = batch[:batchSize]
+		}
+		writes = writes[len(batch):]
+		if b.opts.Limiter != nil {
+			if err := b.opts.Limiter.WaitN(ctx, len(batch)); err != nil {
+				return err
+			}
+		}
+		if err := b.transaction(ctx, func(trx string) error {
+			return b.writeBatch(ctx, trx, batch)
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// arangoWrite is a node, or an edge from the node a to the node u, asserted
+// by the documents with digests docs
+type arangoWrite struct {
+	node     GuacNode
+	edge     GuacEdge
+	a, u     GuacNode
+	matching bool
+	docs     []string
+}
+
+// arangoDocuments merges the writes of a batch to the same document in
+// order, as UPSERT does not see the documents inserted by the same query
+type arangoDocuments struct {
+	keys []string
+	docs map[string]map[string]interface{}
+}
+
+func (d *arangoDocuments) merge(key string, doc map[string]interface{}, props map[string]interface{}) {
+	if d.docs == nil {
+		d.docs = map[string]map[string]interface{}{}
+	}
+	stored, ok := d.docs[key]
+	if !ok {
+		d.keys = append(d.keys, key)
+		stored = doc
+		stored["properties"] = map[string]interface{}{}
+		d.docs[key] = stored
+	}
+	for k, v := range doc {
+		if k != "properties" {
+			stored[k] = v
+		}
+	}
+	for k, v := range propertyValues(props) {
+		stored["properties"].(map[string]interface{})[k] = v
+	}
+}
+
+// addDocuments adds the digests docs to the documents asserting the merged
+// document with key
+func (d *arangoDocuments) addDocuments(key string, docs []string) {
+	stored, _ := d.docs[key]["documents"].([]string)
+	merged := append([]string{}, stored...)
+	for _, doc := range docs {
+		if !containsString(merged, doc) {
+			merged = append(merged, doc)
+		}
+	}
+	d.docs[key]["documents"] = merged
+}
+
+// upserts returns the documents to UPSERT, with the attributes of lookup
+// identifying the stored document, the document to insert without the
+// removed properties, the changes to update and the documents to add
+func (d *arangoDocuments) upserts(lookup ...string) []map[string]interface{} {
+	upserts := make([]map[string]interface{}, 0, len(d.keys))
+	for _, key := range d.keys {
+		doc := d.docs[key]
+		insert := map[string]interface{}{}
+		update := map[string]interface{}{}
+		documents, _ := doc["documents"].([]string)
+		upsert := map[string]interface{}{"insert": insert, "update": update, "documents": append([]string{}, documents...)}
+		for k, v := range doc {
+			if k == "documents" {
+				continue
+			}
+			if k == "properties" {
+				props := map[string]interface{}{}
+				for pk, pv := range v.(map[string]interface{}) {
+					if pv != nil {
+						props[pk] = pv
+					}
+				}
+				if len(documents) > 0 {
+					props["documents"] = documents
+				}
+				insert[k] = props
+				update[k] = v
+				continue
+			}
+			insert[k] = v
+			update[k] = v
+		}
+		for _, k := range lookup {
+			upsert[k] = doc[k]
+		}
+		upserts = append(upserts, upsert)
+	}
+	return upserts
+}
+
+// nodeDocument returns the document of n, with the digests of an artifact
+// indexed to find it by any of them
+func nodeDocument(n GuacNode) map[string]interface{} {
+	doc := map[string]interface{}{"label": n.Type(), "key": nodeKey(n)}
+	if an, ok := n.(ArtifactNode); ok {
+		doc["digests"] = an.Digests()
+	}
+	return doc
+}
+
+// writeBatch merges the nodes of batch, including the nodes of its edges,
+// then its edges
+func (b *arangoBackend) writeBatch(ctx context.Context, trx string, batch []arangoWrite) error {
+	nodes := &arangoDocuments{}
+	addNode := func(n GuacNode) {
+		nodes.merge(nodeKey(n), nodeDocument(n), n.Properties())
+	}
+	for _, w := range batch {
+		if w.node != nil {
+			addNode(w.node)
+			nodes.addDocuments(nodeKey(w.node), w.docs)
+			continue
+		}
+		addNode(w.a)
+		if !w.matching {
+			addNode(w.u)
+		}
+	}
+	results, err := b.query(ctx, trx, arangoMergeNodes, map[string]interface{}{"nodes": nodes.upserts("key")})
+	if err != nil {
+		return err
+	}
+	ids := map[string]string{}
+	for _, r := range results {
+		var stored struct {
+			Key string `json:"key"`
+			ID  string `json:"id"`
+		}
+		if err := json.Unmarshal(r, &stored); err != nil {
+			return err
+		}
+		ids[stored.Key] = stored.ID
+	}
+
+	edges := &arangoDocuments{}
+	for _, w := range batch {
+		if w.edge == nil {
+			continue
+		}
+		from := ids[nodeKey(w.a)]
+		to := []string{ids[nodeKey(w.u)]}
+		if w.matching {
+			query, vars := matchingNodesAQL(w.u)
+			results, err := b.query(ctx, trx, query, vars)
+			if err != nil {
+				return err
+			}
+			to = make([]string, 0, len(results))
+			for _, r := range results {
+				var id string
+				if err := json.Unmarshal(r, &id); err != nil {
+					return err
+				}
+				to = append(to, id)
+			}
+		}
+		for _, id := range to {
+			doc := map[string]interface{}{"_from": from, "_to": id, "label": w.edge.Type()}
+			key := w.edge.Type() + "/" + from + "/" + id
+			edges.merge(key, doc, w.edge.Properties())
+			edges.addDocuments(key, w.docs)
+		}
+	}
+	if len(edges.keys) == 0 {
+		return nil
+	}
+	_, err = b.query(ctx, trx, arangoMergeEdges, map[string]interface{}{"edges": edges.upserts("_from", "_to", "label")})
+	return err
+}
+
+// matchingNodesAQL returns the query of the ids of the nodes matching the u
+// node of a MatchingEdge: the nodes of its type sharing a value with each of
+// its properties, a property being a value or a list of values
+func matchingNodesAQL(u GuacNode) (string, map[string]interface{}) {
+	props := propertyValues(u.Properties())
+	keys := make([]string, 0, len(props))
+	for k := range props {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	vars := map[string]interface{}{"label": u.Type()}
+	filters := []string{"n.label == @label"}
+	for i, k := range keys {
+		values := readProperties(map[string]interface{}{k: props[k]})[k]
+		list, ok := values.([]interface{})
+		if !ok {
+			list = []interface{}{}
+			if values != nil {
+				list = append(list, values)
+			}
+		}
+		vars[fmt.Sprintf("key%d", i)] = k
+		vars[fmt.Sprintf("values%d", i)] = list
+		filters = append(filters, fmt.Sprintf("LENGTH(INTERSECTION(TO_ARRAY(n.properties[@key%d]), @values%d)) > 0", i, i))
+	}
+	return "FOR n IN nodes FILTER " + strings.Join(filters, " AND ") + " SORT TO_NUMBER(n._key) RETURN n._id", vars
+}
+
+// findArtifacts returns the digests of the stored artifacts having any of
+// digests, each starting with the digest identifying the artifact
+func (b *arangoBackend) findArtifacts(ctx context.Context, digests []string) ([][]string, error) {
+	results, err := b.query(ctx, "", arangoFindArtifacts, map[string]interface{}{"digests": digests})
+	if err != nil {
+		return nil, err
+	}
+	stored := make([][]string, 0, len(results))
+	for _, r := range results {
+		props, err := decodeJSONProperties(r)
+		if err != nil {
+			return nil, err
+		}
+		stored = append(stored, storedDigests(props))
+	}
+	return stored, nil
+}
+
+// CreateIndexOn indexes the attribute of the nodes with label
+func (b *arangoBackend) CreateIndexOn(nodeLabel string, nodeAttribute string) error {
+	if err := ValidateLabel(nodeLabel); err != nil {
+		return err
+	}
+	if err := ValidateLabel(nodeAttribute); err != nil {
+		return err
+	}
+	return b.request(context.Background(), http.MethodPost, "/_api/index?collection="+arangoNodes, "", map[string]interface{}{
+		"type":   "persistent",
+		"name":   "nodes_" + nodeLabel + "_" + nodeAttribute,
+		"fields": []string{"label", "properties." + nodeAttribute},
+	}, nil)
+}
+
+func (b *arangoBackend) Ping() error {
+	return b.request(context.Background(), http.MethodGet, "/_api/version", "", nil, nil)
+}
+
+func (b *arangoBackend) Close() error {
+	b.client.CloseIdleConnections()
+	return nil
+}
+
+// findNodesAQL returns the query of the nodes selected by filter
+func findNodesAQL(filter NodeFilter) (string, map[string]interface{}, error) {
+	if err := ValidateLabel(filter.Label); err != nil {
+		return "", nil, err
+	}
+	vars := map[string]interface{}{"label": filter.Label, "limit": queryLimit(filter.Limit)}
+	filters := []string{"n.label == @label"}
+	if filter.Purl != "" {
+		vars["purl"] = filter.Purl
+		filters = append(filters, "n.properties.purl == @purl")
+	}
+	if filter.Digest != "" {
+		vars["digest"] = strings.ToLower(filter.Digest)
+		switch filter.Label {
+		case "Artifact":
+			filters = append(filters, "@digest IN n.digests")
+		case "Package":
+			filters = append(filters, "@digest IN TO_ARRAY(n.properties.digest)")
+		default:
+			filters = append(filters, "n.properties.digest == @digest")
+		}
+	}
+	if filter.PredicateType != "" {
+		vars["predicateType"] = filter.PredicateType
+		filters = append(filters, "n.properties.attestation_type == @predicateType")
+	}
+	if filter.ID != "" {
+		vars["id"] = filter.ID
+		if filter.Label == "Vulnerability" {
+			filters = append(filters, "(n.properties.id == @id OR @id IN TO_ARRAY(n.properties.aliases))")
+		} else {
+			filters = append(filters, "n.properties.id == @id")
+		}
+	}
+	if filter.Source != "" {
+		vars["source"] = filter.Source
+		filters = append(filters, "(n.properties.source_uri == @source OR n.properties.source == @source)")
+	}
+	query := "FOR n IN nodes FILTER " + strings.Join(filters, " AND ") +
+		" SORT TO_NUMBER(n._key) LIMIT @limit RETURN " + arangoStoredNode("n")
+	return query, vars, nil
+}
+
+// FindNodes returns the nodes selected by filter, as FindNodes does
+func (b *arangoBackend) FindNodes(filter NodeFilter) ([]StoredNode, error) {
+	query, vars, err := findNodesAQL(filter)
+	if err != nil {
+		return nil, err
+	}
+	results, err := b.query(context.Background(), "", query, vars)
+	if err != nil {
+		return nil, err
+	}
+	nodes := make([]StoredNode, 0, len(results))
+	for _, r := range results {
+		n, err := decodeArangoNode(r)
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, n)
+	}
+	return nodes, nil
+}
+
+// Neighbors returns the nodes linked to the node with id id, as Neighbors
+// does
+func (b *arangoBackend) Neighbors(id int64, edge string, dir Direction, label string, limit int) ([]Neighbor, error) {
+	if _, err := neighborsQuery(edge, dir, label); err != nil {
+		return nil, err
+	}
+	direction := "OUTBOUND"
+	if dir == DirectionIn {
+		direction = "INBOUND"
+	}
+	query := `FOR m, e IN 1..1 ` + direction + ` @start edges
+FILTER @edge == "" OR e.label == @edge
+FILTER @label == "" OR m.label == @label
+SORT TO_NUMBER(m._key), e._key
+LIMIT @limit
+RETURN { edge: e.label, edgeProperties: e.properties, node: ` + arangoStoredNode("m") + ` }`
+	results, err := b.query(context.Background(), "", query, map[string]interface{}{
+		"start": arangoNodeID(id),
+		"edge":  edge,
+		"label": label,
+		"limit": queryLimit(limit),
+	})
+	if err != nil {
+		return nil, err
+	}
+	neighbors := make([]Neighbor, 0, len(results))
+	for _, r := range results {
+		var stored struct {
+			Edge           string          `json:"edge"`
+			EdgeProperties json.RawMessage `json:"edgeProperties"`
+			Node           json.RawMessage `json:"node"`
+		}
+		if err := json.Unmarshal(r, &stored); err != nil {
+			return nil, err
+		}
+		n := Neighbor{Edge: stored.Edge}
+		if n.EdgeProperties, err = decodeJSONProperties(stored.EdgeProperties); err != nil {
+			return nil, err
+		}
+		if n.Node, err = decodeArangoNode(stored.Node); err != nil {
+			return nil, err
+		}
+		neighbors = append(neighbors, n)
+	}
+	return neighbors, nil
+}
+
+// Paths returns the paths from the node with id from to the node with id to,
+// as Paths does
+func (b *arangoBackend) Paths(from int64, to int64, edges []string, depth int, limit int) ([]Path, error) {
+	_, depth, err := pathEdges(edges, depth)
+	if err != nil {
+		return nil, err
+	}
+	return b.readPaths(arangoPathsQuery, map[string]interface{}{
+		"from":  arangoNodeID(from),
+		"to":    arangoNodeID(to),
+		"edges": append([]string{}, edges...),
+		"depth": depth,
+		"limit": queryLimit(limit),
+	})
+}
+
+// RootPaths returns the paths to the node with id id from the root nodes
+// reaching it, as RootPaths does
+func (b *arangoBackend) RootPaths(id int64, edges []string, depth int, limit int) ([]Path, error) {
+	_, depth, err := pathEdges(edges, depth)
+	if err != nil {
+		return nil, err
+	}
+	return b.readPaths(arangoRootPathsQuery, map[string]interface{}{
+		"id":    arangoNodeID(id),
+		"edges": append([]string{}, edges...),
+		"depth": depth,
+		"limit": queryLimit(limit),
+	})
+}
+
+func (b *arangoBackend) readPaths(query string, vars map[string]interface{}) ([]Path, error) {
+	results, err := b.query(context.Background(), "", query, vars)
+	if err != nil {
+		return nil, err
+	}
+	paths := make([]Path, 0, len(results))
+	for _, r := range results {
+		var stored struct {
+			Nodes []json.RawMessage `json:"nodes"`
+			Edges []string          `json:"edges"`
+		}
+		if err := json.Unmarshal(r, &stored); err != nil {
+			return nil, err
+		}
+		path := Path{Nodes: make([]StoredNode, 0, len(stored.Nodes)), Edges: stored.Edges}
+		if path.Edges == nil {
+			path.Edges = []string{}
+		}
+		for _, data := range stored.Nodes {
+			n, err := decodeArangoNode(data)
+			if err != nil {
+				return nil, err
+			}
+			path.Nodes = append(path.Nodes, n)
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+func arangoNodeID(id int64) string {
+	return arangoNodes + "/" + strconv.FormatInt(id, 10)
+}
+
+// decodeArangoNode decodes a StoredNode returned by arangoStoredNode
+func decodeArangoNode(data []byte) (StoredNode, error) {
+	var stored struct {
+		ID         int64           `json:"id"`
+		Label      string          `json:"label"`
+		Properties json.RawMessage `json:"properties"`
+	}
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return StoredNode{}, err
+	}
+	props, err := decodeJSONProperties(stored.Properties)
+	if err != nil {
+		return StoredNode{}, err
+	}
+	return StoredNode{ID: stored.ID, Label: stored.Label, Properties: props}, nil
+}
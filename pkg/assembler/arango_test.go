@@ -0,0 +1,235 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package assembler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_arangoEndpoint(t *testing.T) {
+	tests := []struct {
+		name    string
+		address string
+		want    string
+		wantErr bool
+	}{{
+		name:    "database",
+		address: "http://localhost:8529/guac",
+		want:    "http://localhost:8529/_db/guac",
+	}, {
+		name:    "default database",
+		address: "https://arango.example.com:8529",
+		want:    "https://arango.example.com:8529/_db/_system",
+	}, {
+		name:    "nested path",
+		address: "http://localhost:8529/guac/nodes",
+		wantErr: true,
+	}, {
+		name:    "neo4j address",
+		address: "neo4j://localhost:7687",
+		wantErr: true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := arangoEndpoint(tt.address)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("arangoEndpoint() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("arangoEndpoint() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_findNodesAQL(t *testing.T) {
+	const ret = " SORT TO_NUMBER(n._key) LIMIT @limit RETURN { id: TO_NUMBER(n._key), label: n.label, properties: n.properties }"
+	tests := []struct {
+		name      string
+		filter    NodeFilter
+		wantQuery string
+		wantVars  map[string]interface{}
+		wantErr   bool
+	}{{
+		name:      "package by purl",
+		filter:    NodeFilter{Label: "Package", Purl: "pkg:golang/golang.org/x/text@v0.3.7"},
+		wantQuery: "FOR n IN nodes FILTER n.label == @label AND n.properties.purl == @purl" + ret,
+		wantVars:  map[string]interface{}{"label": "Package", "purl": "pkg:golang/golang.org/x/text@v0.3.7", "limit": DefaultQueryLimit},
+	}, {
+		name:      "artifact by any digest",
+		filter:    NodeFilter{Label: "Artifact", Digest: "SHA256:1A", Limit: 5},
+		wantQuery: "FOR n IN nodes FILTER n.label == @label AND @digest IN n.digests" + ret,
+		wantVars:  map[string]interface{}{"label": "Artifact", "digest": "sha256:1a", "limit": 5},
+	}, {
+		name:      "package by digest",
+		filter:    NodeFilter{Label: "Package", Digest: "sha256:1a"},
+		wantQuery: "FOR n IN nodes FILTER n.label == @label AND @digest IN TO_ARRAY(n.properties.digest)" + ret,
+		wantVars:  map[string]interface{}{"label": "Package", "digest": "sha256:1a", "limit": DefaultQueryLimit},
+	}, {
+		name:      "vulnerability by alias",
+		filter:    NodeFilter{Label: "Vulnerability", ID: "CVE-2022-32149"},
+		wantQuery: "FOR n IN nodes FILTER n.label == @label AND (n.properties.id == @id OR @id IN TO_ARRAY(n.properties.aliases))" + ret,
+		wantVars:  map[string]interface{}{"label": "Vulnerability", "id": "CVE-2022-32149", "limit": DefaultQueryLimit},
+	}, {
+		name:      "documents by source",
+		filter:    NodeFilter{Label: "Document", Source: "oci://app"},
+		wantQuery: "FOR n IN nodes FILTER n.label == @label AND (n.properties.source_uri == @source OR n.properties.source == @source)" + ret,
+		wantVars:  map[string]interface{}{"label": "Document", "source": "oci://app", "limit": DefaultQueryLimit},
+	}, {
+		name:    "invalid label",
+		filter:  NodeFilter{Label: "Package\" OR true"},
+		wantErr: true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, vars, err := findNodesAQL(tt.filter)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("findNodesAQL() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if query != tt.wantQuery {
+				t.Errorf("findNodesAQL() query = %s, want %s", query, tt.wantQuery)
+			}
+			if !reflect.DeepEqual(vars, tt.wantVars) {
+				t.Errorf("findNodesAQL() vars = %v, want %v", vars, tt.wantVars)
+			}
+		})
+	}
+}
+
+// fakeArango answers the requests of an arangoBackend to the guac database
+// as ArangoDB would, recording the documents upserted
+type fakeArango struct {
+	t         *testing.T
+	nodes     []map[string]interface{}
+	edges     []map[string]interface{}
+	committed bool
+}
+
+func (f *fakeArango) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if user, password, _ := r.BasicAuth(); user != "guac" || password != "s3cret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte(`{"error":true,"code":401,"errorNum":11,"errorMessage":"not authorized to execute this request"}`))
+		return
+	}
+	var body struct {
+		Query    string                 `json:"query"`
+		BindVars map[string]interface{} `json:"bindVars"`
+		Name     string                 `json:"name"`
+	}
+	_ = json.NewDecoder(r.Body).Decode(&body)
+	path := strings.TrimPrefix(r.URL.Path, "/_db/guac")
+	switch {
+	case r.Method == http.MethodPost && path == "/_api/collection" && body.Name == arangoEdges:
+		w.WriteHeader(http.StatusConflict)
+		_, _ = w.Write([]byte(`{"error":true,"code":409,"errorNum":1207,"errorMessage":"duplicate name"}`))
+	case r.Method == http.MethodPost && (path == "/_api/collection" || path == "/_api/index"):
+		_, _ = w.Write([]byte(`{}`))
+	case r.Method == http.MethodPost && path == "/_api/transaction/begin":
+		_, _ = w.Write([]byte(`{"result":{"id":"42","status":"running"}}`))
+	case r.Method == http.MethodPut && path == "/_api/transaction/42":
+		f.committed = true
+		_, _ = w.Write([]byte(`{"result":{"id":"42","status":"committed"}}`))
+	case r.Method == http.MethodPost && path == "/_api/cursor" && body.Query == arangoFindArtifacts:
+		_, _ = w.Write([]byte(`{"result":[{"digest":"sha256:1a","digest_sha512":"sha512:2b"}],"hasMore":false}`))
+	case r.Method == http.MethodPost && path == "/_api/cursor" && body.Query == arangoMergeNodes:
+		if trx := r.Header.Get("x-arango-trx-id"); trx != "42" {
+			f.t.Errorf("nodes merged in transaction %q, want 42", trx)
+		}
+		results := []map[string]interface{}{}
+		for i, n := range body.BindVars["nodes"].([]interface{}) {
+			node := n.(map[string]interface{})
+			f.nodes = append(f.nodes, node)
+			results = append(results, map[string]interface{}{"key": node["key"], "id": fmt.Sprintf("nodes/%d", i+1)})
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"result": results, "hasMore": false})
+	case r.Method == http.MethodPost && path == "/_api/cursor" && body.Query == arangoMergeEdges:
+		for _, e := range body.BindVars["edges"].([]interface{}) {
+			f.edges = append(f.edges, e.(map[string]interface{}))
+		}
+		_, _ = w.Write([]byte(`{"result":[],"hasMore":false}`))
+	case r.Method == http.MethodPost && path == "/_api/cursor":
+		_, _ = w.Write([]byte(`{"id":"c1","result":[{"id":1,"label":"Package","properties":{"purl":"pkg:oci/app"}}],"hasMore":true}`))
+	case r.Method == http.MethodPut && path == "/_api/cursor/c1":
+		_, _ = w.Write([]byte(`{"id":"c1","result":[{"id":2,"label":"Package","properties":{"purl":"pkg:oci/app","digest":["sha256:1a"]}}],"hasMore":false}`))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"error":true,"code":404,"errorNum":1203,"errorMessage":"collection or view not found"}`))
+	}
+}
+
+func TestArangoBackend(t *testing.T) {
+	ctx := context.Background()
+	fake := &fakeArango{t: t}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	var aerr *arangoError
+	if _, err := NewBackend(ctx, ArangoBackend, BackendConfig{Address: server.URL + "/guac"}); !errors.As(err, &aerr) || aerr.Code != http.StatusUnauthorized {
+		t.Errorf("NewBackend() error = %v, want an unauthorized arangodb error", err)
+	}
+	b, err := NewBackend(ctx, ArangoBackend, BackendConfig{Address: server.URL + "/guac", User: "guac", Password: "s3cret"})
+	if err != nil {
+		t.Fatalf("NewBackend() error = %v", err)
+	}
+	defer b.Close()
+
+	image := ArtifactNode{Name: "app", Digest: "sha512:2b"}
+	app := PackageNode{Purl: "pkg:oci/app"}
+	err = b.StoreGraph(ctx, AssertedBy(Graph{
+		Nodes: []GuacNode{image, app},
+		Edges: []GuacEdge{PackageOfEdge{ArtifactNode: image, PackageNode: app}},
+	}, DocumentNode{Digest: "sha256:d0"}))
+	if err != nil {
+		t.Fatalf("StoreGraph() error = %v", err)
+	}
+	if !fake.committed {
+		t.Errorf("StoreGraph() did not commit its transaction")
+	}
+	if len(fake.nodes) != 3 {
+		t.Fatalf("StoreGraph() merged %d nodes, want the document, the artifact and the package once", len(fake.nodes))
+	}
+	if got, want := fake.nodes[1]["key"], nodeKey(ArtifactNode{Digest: "sha256:1a"}); got != want {
+		t.Errorf("StoreGraph() merged the artifact as %v, want the stored artifact %s", got, want)
+	}
+	insert := fake.nodes[1]["insert"].(map[string]interface{})
+	if !reflect.DeepEqual(insert["digests"], []interface{}{"sha256:1a", "sha512:2b"}) {
+		t.Errorf("StoreGraph() merged the artifact with digests %v, want both stored digests", insert["digests"])
+	}
+	if docs := insert["properties"].(map[string]interface{})["documents"]; !reflect.DeepEqual(docs, []interface{}{"sha256:d0"}) {
+		t.Errorf("StoreGraph() inserted the artifact with documents %v, want the document", docs)
+	}
+	if len(fake.edges) != 1 || fake.edges[0]["_from"] != "nodes/2" || fake.edges[0]["_to"] != "nodes/3" {
+		t.Errorf("StoreGraph() merged edges %v, want the package of the artifact", fake.edges)
+	} else if docs := fake.edges[0]["documents"]; !reflect.DeepEqual(docs, []interface{}{"sha256:d0"}) {
+		t.Errorf("StoreGraph() merged the edge with documents %v, want the document", docs)
+	}
+
+	nodes, err := b.FindNodes(NodeFilter{Label: "Package", Purl: "pkg:oci/app"})
+	if err != nil {
+		t.Fatalf("FindNodes() error = %v", err)
+	}
+	if len(nodes) != 2 || nodes[1].ID != 2 || !reflect.DeepEqual(nodes[1].Properties["digest"], []interface{}{"sha256:1a"}) {
+		t.Errorf("FindNodes() = %v, want the nodes of both batches of the cursor", nodes)
+	}
+}
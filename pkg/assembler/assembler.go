@@ -15,6 +15,8 @@
 
 package assembler
 
+import "strings"
+
 type assembler struct{} //nolint: unused
 
 // NOTE: `GuacNode` and `GuacEdge` interfaces are very experimental and might
@@ -120,22 +122,107 @@ type GuacEdge interface {
 	IdentifiablePropertyNames() []string
 }
 
+// MatchingEdge is a GuacEdge whose u node is not created when the edge is
+// stored but matched among the stored nodes of its type, e.g. the packages
+// having a digest. The edge links v to every matching node, possibly none.
+// Since its u node only describes the nodes to match, the edge is left out
+// of exports and snapshots of graphs.
+type MatchingEdge interface {
+	GuacEdge
+
+	// Match returns the condition on the stored node `u` matching it and
+	// the parameters of the condition
+	Match() (string, map[string]interface{})
+}
+
 // Graph represents a subgraph read from the database or written to it.
 // Note: this is experimental and might change. Please refer to source code for
 // more details about usage.
 type Graph struct {
 	Nodes []GuacNode
 	Edges []GuacEdge
+
+	// Documents are the documents asserting the nodes and edges of the
+	// graph, stored as Document nodes, see AssertedBy
+	Documents []DocumentNode
+	// Evidence maps the key of a node or an edge of the graph to the digests
+	// of the documents asserting it, which are stored in its `documents`
+	// property
+	Evidence map[string][]string
+
+	// artifacts indexes the artifacts of Nodes[:indexed] by digest, see
+	// appendNodes
+	artifacts map[string]int
+	indexed   int
 }
 
-// AppendGraph appends the graph g with additional graphs
+// AppendGraph appends the graph g with additional graphs. Artifact nodes
+// sharing a digest with an artifact of g are merged into it, along with the
+// documents asserting them. It is not safe for concurrent use, see
+// ConcurrentGraphBuilder.
 func (g *Graph) AppendGraph(gs ...Graph) {
 	for _, add := range gs {
-		g.Nodes = append(g.Nodes, add.Nodes...)
+		g.appendDocuments(add.Documents, add.Evidence)
+		g.appendNodes(add.Nodes)
 		g.Edges = append(g.Edges, add.Edges...)
 	}
 }
 
+// AssertedBy returns g asserted by the document doc: doc is added to the
+// documents of g and its digest to the evidence of every node and edge of g
+func AssertedBy(g Graph, doc DocumentNode) Graph {
+	asserted := Graph{Nodes: g.Nodes, Edges: g.Edges}
+	asserted.appendDocuments(g.Documents, g.Evidence)
+	asserted.appendDocuments([]DocumentNode{doc}, nil)
+	digest := []string{strings.ToLower(doc.Digest)}
+	for _, n := range g.Nodes {
+		asserted.addEvidence(nodeKey(n), digest)
+	}
+	for _, e := range g.Edges {
+		asserted.addEvidence(edgeKey(e), digest)
+	}
+	return asserted
+}
+
+// appendDocuments appends the documents not in g yet to g.Documents and
+// merges evidence into g.Evidence
+func (g *Graph) appendDocuments(docs []DocumentNode, evidence map[string][]string) {
+	for _, doc := range docs {
+		found := false
+		for _, other := range g.Documents {
+			if strings.EqualFold(other.Digest, doc.Digest) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			g.Documents = append(g.Documents, doc)
+		}
+	}
+	for key, digests := range evidence {
+		g.addEvidence(key, digests)
+	}
+}
+
+// addEvidence adds the digests of documents asserting the node or edge with
+// key to g.Evidence. The lists of g.Evidence are copied rather than appended
+// to, as they may be shared with other graphs.
+func (g *Graph) addEvidence(key string, digests []string) {
+	if len(digests) == 0 {
+		return
+	}
+	if g.Evidence == nil {
+		g.Evidence = map[string][]string{}
+	}
+	merged := append([]string{}, g.Evidence[key]...)
+	for _, d := range digests {
+		if !containsString(merged, d) {
+			merged = append(merged, d)
+		}
+	}
+	g.Evidence[key] = merged
+}
+
 // TODO(mihaimaruseac): Write queries to write/read subgraphs from DB?
 
 // AssemblerInput represents the inputs to add to the graph
@@ -0,0 +1,205 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package assembler
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/guacsec/guac/pkg/assembler/graphdb"
+	"github.com/guacsec/guac/pkg/metrics"
+	"golang.org/x/time/rate"
+)
+
+// Names of the backends registered by this package
+const (
+	Neo4jBackend    = "neo4j"
+	MemoryBackend   = "memory"
+	PostgresBackend = "postgres"
+	ArangoBackend   = "arangodb"
+)
+
+// Backend stores the GUAC graph: graphs are merged into it and it is read as
+// a GraphReader. Backends are registered by name with RegisterBackend so that
+// commands select one with NewBackend instead of depending on a database.
+type Backend interface {
+	GraphReader
+	// StoreGraph merges g into the stored graph
+	StoreGraph(ctx context.Context, g Graph) error
+	// RetractDocument removes the document with digest from the stored
+	// graph, deleting the nodes and edges only it asserted, see Retraction.
+	// It returns ErrNodeNotFound if the document is not stored.
+	RetractDocument(ctx context.Context, digest string) (Retraction, error)
+	// CreateIndexOn indexes the attribute of the nodes with label
+	CreateIndexOn(nodeLabel string, nodeAttribute string) error
+	// Ping returns an error if the backend cannot be reached
+	Ping() error
+	// Close releases the connections of the backend
+	Close() error
+}
+
+// BackendConfig configures the connection to a backend. Each backend uses
+// the fields it needs and ignores the others.
+type BackendConfig struct {
+	Address  string
+	User     string
+	Password string
+	Realm    string
+	// paths to the pem files for mTLS, no client certificate if empty
+	TLSCertPath string
+	TLSKeyPath  string
+	TLSCAPath   string
+	// WriteLimiter limits the write operations, see StoreGraphWithOptions,
+	// unlimited if nil
+	WriteLimiter *rate.Limiter
+	// WriteBatchSize is the maximum number of write operations of a
+	// transaction, DefaultWriteOptions.BatchSize if not positive
+	WriteBatchSize int
+}
+
+// writeOptions returns the DefaultWriteOptions with the limiter and batch
+// size of config
+func (config BackendConfig) writeOptions() WriteOptions {
+	opts := DefaultWriteOptions
+	opts.Limiter = config.WriteLimiter
+	if config.WriteBatchSize > 0 {
+		opts.BatchSize = config.WriteBatchSize
+	}
+	return opts
+}
+
+// BackendFactory connects to a backend configured by config
+type BackendFactory func(ctx context.Context, config BackendConfig) (Backend, error)
+
+var backends = map[string]BackendFactory{}
+
+var (
+	graphsStored = metrics.NewCounter("guac_assembler_graphs_stored_total",
+		"Graphs stored to the graph db, by backend.", "backend")
+	storeErrors = metrics.NewCounter("guac_assembler_store_errors_total",
+		"Graphs that failed to store to the graph db, by backend.", "backend")
+	storeDuration = metrics.NewHistogram("guac_assembler_store_duration_seconds",
+		"Time taken to store a graph to the graph db, by backend.", nil, "backend")
+	lastStored = metrics.NewGauge("guac_assembler_last_stored_timestamp_seconds",
+		"Unix time of the last graph stored to the graph db.")
+)
+
+// observeStore records the metrics of a graph stored to backend since start,
+// or failing to with err
+func observeStore(backend string, start time.Time, err error) {
+	storeDuration.ObserveSince(start, backend)
+	if err != nil {
+		storeErrors.Inc(backend)
+		return
+	}
+	graphsStored.Inc(backend)
+	lastStored.SetToCurrentTime()
+}
+
+func init() {
+	_ = RegisterBackend(Neo4jBackend, newNeo4jBackend)
+	_ = RegisterBackend(MemoryBackend, func(context.Context, BackendConfig) (Backend, error) {
+		return NewMemoryGraph(), nil
+	})
+	_ = RegisterBackend(PostgresBackend, newPostgresBackend)
+	_ = RegisterBackend(ArangoBackend, newArangoBackend)
+}
+
+// RegisterBackend registers the factory of the backend called name
+func RegisterBackend(name string, factory BackendFactory) error {
+	if _, ok := backends[name]; ok {
+		return fmt.Errorf("the backend is being overwritten: %s", name)
+	}
+	backends[name] = factory
+	return nil
+}
+
+// RegisteredBackends returns the sorted names of the registered backends
+func RegisteredBackends() []string {
+	names := make([]string, 0, len(backends))
+	for name := range backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewBackend connects to the backend called name
+func NewBackend(ctx context.Context, name string, config BackendConfig) (Backend, error) {
+	factory, ok := backends[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown backend %q, registered backends are %v", name, RegisteredBackends())
+	}
+	return factory(ctx, config)
+}
+
+// GraphClient returns the Neo4j client of b, for the Cypher queries of the
+// commands only supporting Neo4j
+func GraphClient(b Backend) (graphdb.Client, error) {
+	n, ok := b.(*neo4jBackend)
+	if !ok {
+		return nil, fmt.Errorf("the %T backend is not supported, a Neo4j backend is required", b)
+	}
+	return n.client, nil
+}
+
+// neo4jBackend stores the graph in Neo4j, with StoreGraphWithOptions and
+// CreateIndexOn, and reads it as NewGraphReader
+type neo4jBackend struct {
+	clientReader
+	opts WriteOptions
+}
+
+func newNeo4jBackend(_ context.Context, config BackendConfig) (Backend, error) {
+	authToken := graphdb.CreateAuthTokenWithUsernameAndPassword(config.User, config.Password, config.Realm)
+	var client graphdb.Client
+	var err error
+	if config.TLSCertPath == "" {
+		client, err = graphdb.NewGraphClient(config.Address, authToken)
+	} else {
+		tlsConfig, tlsErr := graphdb.LoadClientTLSConfig(config.TLSCertPath, config.TLSKeyPath, config.TLSCAPath)
+		if tlsErr != nil {
+			return nil, tlsErr
+		}
+		client, err = graphdb.NewGraphClientWithTLS(config.Address, authToken, tlsConfig)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &neo4jBackend{clientReader: clientReader{client: client}, opts: config.writeOptions()}, nil
+}
+
+func (b *neo4jBackend) StoreGraph(ctx context.Context, g Graph) error {
+	return StoreGraphWithOptions(ctx, g, b.client, b.opts)
+}
+
+func (b *neo4jBackend) RetractDocument(ctx context.Context, digest string) (Retraction, error) {
+	return RetractDocument(ctx, b.client, digest)
+}
+
+func (b *neo4jBackend) CreateIndexOn(nodeLabel string, nodeAttribute string) error {
+	return CreateIndexOn(b.client, nodeLabel, nodeAttribute)
+}
+
+func (b *neo4jBackend) Ping() error {
+	return b.client.VerifyConnectivity()
+}
+
+func (b *neo4jBackend) Close() error {
+	return b.client.Close()
+}
@@ -0,0 +1,76 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package assembler
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestNewBackend(t *testing.T) {
+	ctx := context.Background()
+	if got, want := RegisteredBackends(), []string{ArangoBackend, MemoryBackend, Neo4jBackend, PostgresBackend}; !reflect.DeepEqual(got, want) {
+		t.Errorf("RegisteredBackends() = %v, want %v", got, want)
+	}
+	if err := RegisterBackend(MemoryBackend, nil); err == nil {
+		t.Errorf("RegisterBackend() of a registered backend succeeded")
+	}
+	if _, err := NewBackend(ctx, "dgraph", BackendConfig{}); err == nil {
+		t.Errorf("NewBackend() of an unknown backend succeeded")
+	}
+
+	b, err := NewBackend(ctx, MemoryBackend, BackendConfig{})
+	if err != nil {
+		t.Fatalf("NewBackend() error = %v", err)
+	}
+	defer b.Close()
+	if err := b.StoreGraph(ctx, Graph{Nodes: []GuacNode{PackageNode{Purl: "pkg:golang/golang.org/x/text@v0.3.7"}}}); err != nil {
+		t.Fatalf("StoreGraph() error = %v", err)
+	}
+	if _, err := FindSubject(b, "pkg:golang/golang.org/x/text@v0.3.7"); err != nil {
+		t.Errorf("FindSubject() error = %v", err)
+	}
+	if _, err := GraphClient(b); err == nil {
+		t.Errorf("GraphClient() of the memory backend succeeded")
+	}
+}
+
+func TestObserveStore(t *testing.T) {
+	ctx := context.Background()
+	stored, failed := graphsStored.Value(MemoryBackend), storeErrors.Value(MemoryBackend)
+	timed := storeDuration.Count(MemoryBackend)
+
+	m := NewMemoryGraph()
+	if err := m.StoreGraph(ctx, Graph{Nodes: []GuacNode{PackageNode{Purl: "pkg:golang/golang.org/x/text@v0.3.7"}}}); err != nil {
+		t.Fatalf("StoreGraph() error = %v", err)
+	}
+	if err := m.StoreGraph(ctx, Graph{Nodes: []GuacNode{PackageNode{}}}); err == nil {
+		t.Fatalf("StoreGraph() of a node without identifiable properties succeeded")
+	}
+	if got := graphsStored.Value(MemoryBackend) - stored; got != 1 {
+		t.Errorf("graphs stored = %v, want 1", got)
+	}
+	if got := storeErrors.Value(MemoryBackend) - failed; got != 1 {
+		t.Errorf("store errors = %v, want 1", got)
+	}
+	if got := storeDuration.Count(MemoryBackend) - timed; got != 2 {
+		t.Errorf("store durations = %v, want 2", got)
+	}
+	if lastStored.Value() == 0 {
+		t.Errorf("last stored timestamp not set")
+	}
+}
@@ -0,0 +1,57 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package assembler
+
+import "sync"
+
+// ConcurrentGraphBuilder merges graphs appended from multiple goroutines,
+// such as parser workers, into a single Graph. The zero value is ready to
+// use.
+type ConcurrentGraphBuilder struct {
+	mu    sync.Mutex
+	graph Graph
+}
+
+// NewConcurrentGraphBuilder returns an empty ConcurrentGraphBuilder
+func NewConcurrentGraphBuilder() *ConcurrentGraphBuilder {
+	return &ConcurrentGraphBuilder{
+		graph: Graph{
+			Nodes: []GuacNode{},
+			Edges: []GuacEdge{},
+		},
+	}
+}
+
+// AppendGraph appends graphs to the graph being built, merging artifacts
+// as Graph.AppendGraph does. It is safe for concurrent use.
+func (b *ConcurrentGraphBuilder) AppendGraph(gs ...Graph) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.graph.AppendGraph(gs...)
+}
+
+// Graph returns the merged graph. Graphs appended afterwards are not part of
+// the returned graph.
+func (b *ConcurrentGraphBuilder) Graph() Graph {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	g := Graph{
+		Nodes: append([]GuacNode{}, b.graph.Nodes...),
+		Edges: append([]GuacEdge{}, b.graph.Edges...),
+	}
+	g.appendDocuments(b.graph.Documents, b.graph.Evidence)
+	return g
+}
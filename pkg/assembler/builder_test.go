@@ -0,0 +1,64 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package assembler
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestConcurrentGraphBuilder(t *testing.T) {
+	const workers = 32
+	const graphsPerWorker = 50
+
+	b := NewConcurrentGraphBuilder()
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < graphsPerWorker; i++ {
+				pkg := PackageNode{Purl: fmt.Sprintf("pkg:generic/w%d@%d", w, i)}
+				// every worker also appends the same artifact, which is merged
+				art := ArtifactNode{Name: "shared", Digest: "sha256:abc"}
+				b.AppendGraph(Graph{
+					Nodes: []GuacNode{pkg, art},
+					Edges: []GuacEdge{ContainsEdge{PackageNode: pkg, ContainedArtifact: art}},
+				})
+				// reading while others write must be safe too
+				_ = b.Graph()
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	g := b.Graph()
+	if want := workers*graphsPerWorker + 1; len(g.Nodes) != want {
+		t.Errorf("got %d nodes, want %d", len(g.Nodes), want)
+	}
+	if want := workers * graphsPerWorker; len(g.Edges) != want {
+		t.Errorf("got %d edges, want %d", len(g.Edges), want)
+	}
+}
+
+func TestConcurrentGraphBuilder_zeroValue(t *testing.T) {
+	var b ConcurrentGraphBuilder
+	b.AppendGraph(Graph{Nodes: []GuacNode{PackageNode{Purl: "pkg:generic/a@1"}}})
+	if g := b.Graph(); len(g.Nodes) != 1 || len(g.Edges) != 0 {
+		t.Errorf("unexpected graph %+v", g)
+	}
+}
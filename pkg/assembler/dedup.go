@@ -0,0 +1,71 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package assembler
+
+import (
+	"reflect"
+)
+
+// DeduplicateGraph returns g without the nodes and edges identical to an
+// earlier node or edge of g, e.g. a package listed by every component of an
+// SBOM depending on it. Since nodes and edges are merged on their identity,
+// the graph stored is the same, with fewer writes. Nodes sharing an identity
+// with different properties are kept, as each replaces the properties written
+// before it.
+func DeduplicateGraph(g Graph) Graph {
+	deduped := Graph{
+		Nodes: make([]GuacNode, 0, len(g.Nodes)),
+		Edges: make([]GuacEdge, 0, len(g.Edges)),
+	}
+	deduped.appendDocuments(g.Documents, g.Evidence)
+	nodes := map[string][]GuacNode{}
+	for _, n := range g.Nodes {
+		key := nodeKey(n)
+		if containsIdentical(nodes[key], n) {
+			continue
+		}
+		nodes[key] = append(nodes[key], n)
+		deduped.Nodes = append(deduped.Nodes, n)
+	}
+	edges := map[string][]GuacEdge{}
+	for _, e := range g.Edges {
+		key := edgeKey(e)
+		if containsIdentical(edges[key], e) {
+			continue
+		}
+		edges[key] = append(edges[key], e)
+		deduped.Edges = append(deduped.Edges, e)
+	}
+	return deduped
+}
+
+// edgeKey returns the key of e: its type and the keys of its nodes. Edges
+// are merged on their type and nodes, so edges with the same key are stored
+// as one edge.
+func edgeKey(e GuacEdge) string {
+	a, b := e.Nodes()
+	return e.Type() + "/" + nodeKey(a) + "/" + nodeKey(b)
+}
+
+// containsIdentical returns whether v is deeply equal to one of values
+func containsIdentical[T any](values []T, v T) bool {
+	for _, other := range values {
+		if reflect.DeepEqual(other, v) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,93 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package assembler
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestDeduplicateGraph(t *testing.T) {
+	app := PackageNode{Purl: "pkg:oci/app", Digest: []string{"sha256:1a"}}
+	text := PackageNode{Purl: "pkg:golang/golang.org/x/text@v0.3.7", Tags: []string{"golang"}}
+	textVersion := PackageNode{Purl: "pkg:golang/golang.org/x/text@v0.3.7", Version: "v0.3.7"}
+	image := ArtifactNode{Name: "app", Digest: "sha256:1a"}
+	direct := DependsOnEdge{PackageNode: app, PackageDependency: text, Relationship: "direct"}
+	transitive := DependsOnEdge{PackageNode: app, PackageDependency: text, Relationship: "transitive"}
+	tests := []struct {
+		name string
+		g    Graph
+		want Graph
+	}{{
+		name: "identical nodes and edges",
+		g: Graph{
+			Nodes: []GuacNode{app, text, image, PackageNode{Purl: "pkg:golang/golang.org/x/text@v0.3.7", Tags: []string{"golang"}}, app},
+			Edges: []GuacEdge{direct, PackageOfDigestEdge{ArtifactNode: image}, direct, PackageOfDigestEdge{ArtifactNode: image}},
+		},
+		want: Graph{
+			Nodes: []GuacNode{app, text, image},
+			Edges: []GuacEdge{direct, PackageOfDigestEdge{ArtifactNode: image}},
+		},
+	}, {
+		name: "same identity with other properties",
+		g: Graph{
+			Nodes: []GuacNode{text, textVersion, text},
+			Edges: []GuacEdge{direct, transitive},
+		},
+		want: Graph{
+			Nodes: []GuacNode{text, textVersion},
+			Edges: []GuacEdge{direct, transitive},
+		},
+	}, {
+		name: "empty graph",
+		want: Graph{Nodes: []GuacNode{}, Edges: []GuacEdge{}},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DeduplicateGraph(tt.g); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DeduplicateGraph() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStoreGraphIdempotent(t *testing.T) {
+	ctx := context.Background()
+	image := ArtifactNode{Name: "app", Digest: "sha256:1a", AlternateDigests: []string{"sha512:2b"}}
+	app := PackageNode{Purl: "pkg:oci/app", Digest: []string{"sha256:1a"}}
+	text := PackageNode{Purl: "pkg:golang/golang.org/x/text@v0.3.7"}
+	att := AttestationNode{Digest: "sha256:3c", AttestationType: "https://slsa.dev/provenance/v0.2"}
+	sbom := Graph{
+		Nodes: []GuacNode{image, app, text, text, att},
+		Edges: []GuacEdge{
+			DependsOnEdge{PackageNode: app, PackageDependency: text},
+			DependsOnEdge{PackageNode: app, PackageDependency: text},
+			PackageOfDigestEdge{ArtifactNode: image},
+			AttestationForEdge{AttestationNode: att, ForArtifact: image},
+		},
+	}
+
+	m := NewMemoryGraph()
+	for i := 0; i < 2; i++ {
+		if err := m.StoreGraph(ctx, sbom); err != nil {
+			t.Fatalf("StoreGraph() error = %v", err)
+		}
+	}
+	if len(m.nodes) != 4 || len(m.edges) != 3 {
+		t.Errorf("storing the graph twice stored %d nodes and %d edges, want 4 and 3", len(m.nodes), len(m.edges))
+	}
+}
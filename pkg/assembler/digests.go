@@ -0,0 +1,173 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package assembler
+
+import (
+	"strings"
+)
+
+// digestPropertyPrefix prefixes the per algorithm digest properties of
+// artifacts, e.g. `digest_sha256`
+const digestPropertyPrefix = "digest_"
+
+// Digests returns the lower cased digests of the artifact, Digest first,
+// without duplicates
+func (an ArtifactNode) Digests() []string {
+	digests := []string{}
+	for _, d := range append([]string{an.Digest}, an.AlternateDigests...) {
+		d = strings.ToLower(d)
+		if d != "" && !containsString(digests, d) {
+			digests = append(digests, d)
+		}
+	}
+	return digests
+}
+
+// digestPropertyName returns the artifact property holding digest d, based
+// on its algorithm. It returns an empty string if d has no algorithm.
+func digestPropertyName(d string) string {
+	algorithm := digestAlgorithm(d)
+	if algorithm == "" {
+		return ""
+	}
+	// property names are written to queries as is, keep them safe
+	name := strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, algorithm)
+	return digestPropertyPrefix + name
+}
+
+func digestAlgorithm(d string) string {
+	algorithm, _, found := strings.Cut(strings.ToLower(d), ":")
+	if !found {
+		return ""
+	}
+	return algorithm
+}
+
+// compareDigests reports whether the digest lists a and b have a digest in
+// common, and whether they conflict by having different digests for the same
+// algorithm. Artifacts whose digests conflict are never merged, as this
+// points to an integrity issue rather than to the same artifact.
+func compareDigests(a []string, b []string) (shared bool, conflict bool) {
+	byAlgorithm := map[string]string{}
+	for _, d := range a {
+		byAlgorithm[digestAlgorithm(d)] = d
+	}
+	for _, d := range b {
+		other, ok := byAlgorithm[digestAlgorithm(d)]
+		switch {
+		case !ok:
+		case other == d:
+			shared = true
+		default:
+			conflict = true
+		}
+	}
+	return shared, conflict
+}
+
+// mergeArtifacts returns an with the digests and tags of other added. The
+// Digest of an is kept so that the artifact stays identified by it.
+func mergeArtifacts(an ArtifactNode, other ArtifactNode) ArtifactNode {
+	merged := an
+	merged.AlternateDigests = nil
+	for _, d := range append(an.Digests(), other.Digests()...) {
+		if d != strings.ToLower(an.Digest) && !containsString(merged.AlternateDigests, d) {
+			merged.AlternateDigests = append(merged.AlternateDigests, d)
+		}
+	}
+	if merged.Name == "" {
+		merged.Name = other.Name
+	}
+	for _, t := range other.Tags {
+		if !containsString(merged.Tags, t) {
+			merged.Tags = append(append([]string{}, merged.Tags...), t)
+		}
+	}
+	return merged
+}
+
+// appendNodes appends nodes to g.Nodes, merging artifacts with an artifact
+// already in g if they share a digest and none of their digests conflict.
+// Edges are not rewritten, their artifact endpoints are resolved to the
+// merged node by digest when stored.
+//
+// The artifacts of g are indexed by digest as they are appended, nodes added
+// to g.Nodes directly are indexed on the next call.
+func (g *Graph) appendNodes(nodes []GuacNode) {
+	if g.artifacts == nil || g.indexed > len(g.Nodes) {
+		g.artifacts = map[string]int{}
+		g.indexed = 0
+	}
+	for ; g.indexed < len(g.Nodes); g.indexed++ {
+		g.indexArtifact(g.indexed)
+	}
+
+	for _, n := range nodes {
+		an, ok := n.(ArtifactNode)
+		if !ok {
+			g.Nodes = append(g.Nodes, n)
+			continue
+		}
+		merged := false
+		for _, d := range an.Digests() {
+			i, found := g.artifacts[d]
+			if !found || i >= len(g.Nodes) {
+				continue
+			}
+			existing, ok := g.Nodes[i].(ArtifactNode)
+			if !ok || !containsString(existing.Digests(), d) {
+				// the index is stale, e.g. shared with a copy of g
+				continue
+			}
+			if _, conflict := compareDigests(existing.Digests(), an.Digests()); conflict {
+				continue
+			}
+			g.Nodes[i] = mergeArtifacts(existing, an)
+			// the documents asserting an assert the artifact it is merged
+			// into, which keeps the key of existing
+			g.addEvidence(nodeKey(existing), g.Evidence[nodeKey(an)])
+			for _, d := range an.Digests() {
+				g.artifacts[d] = i
+			}
+			merged = true
+			break
+		}
+		if !merged {
+			g.Nodes = append(g.Nodes, an)
+			g.indexArtifact(len(g.Nodes) - 1)
+		}
+	}
+	g.indexed = len(g.Nodes)
+}
+
+// indexArtifact indexes the digests of g.Nodes[i] if it is an artifact,
+// keeping the artifact already indexed for a digest
+func (g *Graph) indexArtifact(i int) {
+	an, ok := g.Nodes[i].(ArtifactNode)
+	if !ok {
+		return
+	}
+	for _, d := range an.Digests() {
+		if _, found := g.artifacts[d]; !found {
+			g.artifacts[d] = i
+		}
+	}
+}
@@ -0,0 +1,130 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package assembler
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_compareDigests(t *testing.T) {
+	tests := []struct {
+		name         string
+		a            []string
+		b            []string
+		wantShared   bool
+		wantConflict bool
+	}{{
+		name: "disjoint algorithms",
+		a:    []string{"sha256:abc"},
+		b:    []string{"sha512:def"},
+	}, {
+		name:       "shared",
+		a:          []string{"sha256:abc"},
+		b:          []string{"sha512:def", "sha256:abc"},
+		wantShared: true,
+	}, {
+		name:         "conflict",
+		a:            []string{"sha256:abc", "sha512:def"},
+		b:            []string{"sha256:abc", "sha512:123"},
+		wantShared:   true,
+		wantConflict: true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shared, conflict := compareDigests(tt.a, tt.b)
+			if shared != tt.wantShared || conflict != tt.wantConflict {
+				t.Errorf("compareDigests() = %v, %v, want %v, %v", shared, conflict, tt.wantShared, tt.wantConflict)
+			}
+		})
+	}
+}
+
+func TestArtifactNode_Properties_digests(t *testing.T) {
+	an := ArtifactNode{Name: "app", Digest: "SHA256:ABC", AlternateDigests: []string{"sha512:def", "sha256:abc", "nodigest"}}
+	if got, want := an.Digests(), []string{"sha256:abc", "sha512:def", "nodigest"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ArtifactNode.Digests() = %v, want %v", got, want)
+	}
+	props := an.Properties()
+	if props["digest_sha256"] != "sha256:abc" || props["digest_sha512"] != "sha512:def" {
+		t.Errorf("ArtifactNode.Properties() = %v, want per algorithm digests", props)
+	}
+	for _, name := range an.PropertyNames() {
+		if _, ok := props[name]; !ok && !containsString(an.NodeData.getProperties(), name) {
+			t.Errorf("ArtifactNode.PropertyNames() has %q missing from Properties()", name)
+		}
+	}
+}
+
+func TestGraph_AppendGraph_mergesArtifacts(t *testing.T) {
+	sha256Art := ArtifactNode{Name: "app", Digest: "sha256:abc", Tags: []string{"a"}}
+	sha512Art := ArtifactNode{Digest: "sha512:def", AlternateDigests: []string{"sha256:abc"}, Tags: []string{"b"}}
+	conflictArt := ArtifactNode{Name: "evil", Digest: "sha512:123", AlternateDigests: []string{"sha256:abc"}}
+	otherArt := ArtifactNode{Name: "other", Digest: "sha256:fff"}
+	pkg := PackageNode{Purl: "pkg:generic/app"}
+
+	g := Graph{}
+	g.AppendGraph(Graph{Nodes: []GuacNode{sha256Art, pkg}})
+	g.AppendGraph(Graph{Nodes: []GuacNode{sha512Art, otherArt}}, Graph{Nodes: []GuacNode{conflictArt, sha256Art}})
+
+	want := []GuacNode{
+		ArtifactNode{Name: "app", Digest: "sha256:abc", AlternateDigests: []string{"sha512:def"}, Tags: []string{"a", "b"}},
+		pkg,
+		otherArt,
+		conflictArt,
+	}
+	if !reflect.DeepEqual(g.Nodes, want) {
+		t.Errorf("Graph.AppendGraph() nodes = %v, want %v", g.Nodes, want)
+	}
+}
+
+func TestGraph_AppendGraph_indexesNodesAppendedDirectly(t *testing.T) {
+	art := ArtifactNode{Name: "app", Digest: "sha256:abc"}
+	g := Graph{}
+	g.AppendGraph(Graph{Nodes: []GuacNode{PackageNode{Purl: "pkg:generic/app"}}})
+	g.Nodes = append(g.Nodes, art)
+	g.AppendGraph(Graph{Nodes: []GuacNode{ArtifactNode{Digest: "sha512:def", AlternateDigests: []string{"sha256:abc"}}}})
+
+	want := []GuacNode{
+		PackageNode{Purl: "pkg:generic/app"},
+		ArtifactNode{Name: "app", Digest: "sha256:abc", AlternateDigests: []string{"sha512:def"}},
+	}
+	if !reflect.DeepEqual(g.Nodes, want) {
+		t.Errorf("Graph.AppendGraph() nodes = %v, want %v", g.Nodes, want)
+	}
+}
+
+func TestGraph_AppendGraph_mergesEvidence(t *testing.T) {
+	art := ArtifactNode{Name: "app", Digest: "sha256:abc"}
+	alt := ArtifactNode{Digest: "sha512:def", AlternateDigests: []string{"sha256:abc"}}
+	pkg := PackageNode{Purl: "pkg:generic/app"}
+	spdx := DocumentNode{Digest: "sha256:5b"}
+	slsa := DocumentNode{Digest: "sha256:5a"}
+
+	g := Graph{}
+	g.AppendGraph(AssertedBy(Graph{Nodes: []GuacNode{art, pkg}}, spdx))
+	g.AppendGraph(AssertedBy(Graph{Nodes: []GuacNode{alt}}, slsa), AssertedBy(Graph{Nodes: []GuacNode{pkg}}, spdx))
+
+	if want := []DocumentNode{spdx, slsa}; !reflect.DeepEqual(g.Documents, want) {
+		t.Errorf("Graph.AppendGraph() documents = %v, want %v", g.Documents, want)
+	}
+	if got, want := g.Evidence[nodeKey(art)], []string{"sha256:5b", "sha256:5a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Graph.AppendGraph() evidence of the merged artifact = %v, want %v", got, want)
+	}
+	if got, want := g.Evidence[nodeKey(pkg)], []string{"sha256:5b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Graph.AppendGraph() evidence of the package = %v, want %v", got, want)
+	}
+}
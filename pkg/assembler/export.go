@@ -0,0 +1,339 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package assembler
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ExportFormat is the serialization format used by ExportGraph
+type ExportFormat string
+
+const (
+	// ExportFormatGraphML writes the graph as GraphML XML
+	ExportFormatGraphML ExportFormat = "graphml"
+	// ExportFormatJSON writes the graph in node-link JSON form
+	ExportFormatJSON ExportFormat = "json"
+	// ExportFormatDOT writes the graph in Graphviz DOT syntax
+	ExportFormatDOT ExportFormat = "dot"
+)
+
+// ExportFormats lists the formats supported by ExportGraph
+var ExportFormats = []ExportFormat{ExportFormatGraphML, ExportFormatJSON, ExportFormatDOT}
+
+// exportNode is a GuacNode with the stable identifier used by exporters
+type exportNode struct {
+	id   string
+	node GuacNode
+}
+
+// exportEdge is a GuacEdge with its endpoints resolved to node identifiers
+type exportEdge struct {
+	source string
+	target string
+	edge   GuacEdge
+}
+
+// ExportGraph writes the graph g to w in the given format. Nodes are
+// deduplicated by their type and identifiable properties, so nodes that
+// would be merged in the graph database are also merged in the output.
+func ExportGraph(w io.Writer, g Graph, format ExportFormat) error {
+	nodes, edges := exportElements(g)
+	switch format {
+	case ExportFormatGraphML:
+		return exportGraphML(w, nodes, edges)
+	case ExportFormatJSON:
+		return exportJSON(w, nodes, edges)
+	case ExportFormatDOT:
+		return exportDOT(w, nodes, edges)
+	default:
+		return fmt.Errorf("unsupported export format: %q", format)
+	}
+}
+
+// exportElements assigns identifiers to the nodes of g (including edge
+// endpoints missing from g.Nodes) in order of first appearance.
+func exportElements(g Graph) ([]exportNode, []exportEdge) {
+	nodes := []exportNode{}
+	seen := map[string]bool{}
+	add := func(n GuacNode) string {
+		id := nodeKey(n)
+		if !seen[id] {
+			seen[id] = true
+			nodes = append(nodes, exportNode{id: id, node: n})
+		}
+		return id
+	}
+
+	for _, n := range g.Nodes {
+		add(n)
+	}
+	edges := []exportEdge{}
+	for _, e := range g.Edges {
+		if _, ok := e.(MatchingEdge); ok {
+			continue
+		}
+		v, u := e.Nodes()
+		edges = append(edges, exportEdge{source: add(v), target: add(u), edge: e})
+	}
+	return nodes, edges
+}
+
+// nodeKey returns an identifier for n built from its type and identifiable
+// properties, e.g. `Package{purl=pkg:golang/foo@v1}`.
+func nodeKey(n GuacNode) string {
+	props := n.Properties()
+	parts := []string{}
+	for _, name := range n.IdentifiablePropertyNames() {
+		parts = append(parts, name+"="+propertyString(props[name]))
+	}
+	return n.Type() + "{" + strings.Join(parts, ",") + "}"
+}
+
+// nodeLabel returns a human readable label for n, derived from the values of
+// its identifiable properties (purl, digest, ...).
+func nodeLabel(n GuacNode) string {
+	props := n.Properties()
+	values := []string{}
+	for _, name := range n.IdentifiablePropertyNames() {
+		if v := propertyString(props[name]); v != "" {
+			values = append(values, v)
+		}
+	}
+	if len(values) == 0 {
+		return n.Type()
+	}
+	return n.Type() + "\n" + strings.Join(values, "\n")
+}
+
+// propertyString converts a property value to a string. Strings are kept as
+// is, other values are JSON encoded.
+func propertyString(v interface{}) string {
+	switch value := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	default:
+		b, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Sprintf("%v", value)
+		}
+		return string(b)
+	}
+}
+
+// sortedKeys returns the sorted keys of the properties of all elements
+func sortedKeys(props []map[string]interface{}) []string {
+	set := map[string]bool{}
+	for _, p := range props {
+		for k := range p {
+			set[k] = true
+		}
+	}
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortedProperties(p map[string]interface{}) []string {
+	return sortedKeys([]map[string]interface{}{p})
+}
+
+type graphMLKey struct {
+	ID       string `xml:"id,attr"`
+	For      string `xml:"for,attr"`
+	AttrName string `xml:"attr.name,attr"`
+	AttrType string `xml:"attr.type,attr"`
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+type graphMLNode struct {
+	ID   string        `xml:"id,attr"`
+	Data []graphMLData `xml:"data"`
+}
+
+type graphMLEdge struct {
+	ID     string        `xml:"id,attr"`
+	Source string        `xml:"source,attr"`
+	Target string        `xml:"target,attr"`
+	Data   []graphMLData `xml:"data"`
+}
+
+type graphMLGraph struct {
+	ID          string        `xml:"id,attr"`
+	EdgeDefault string        `xml:"edgedefault,attr"`
+	Nodes       []graphMLNode `xml:"node"`
+	Edges       []graphMLEdge `xml:"edge"`
+}
+
+type graphMLDocument struct {
+	XMLName xml.Name     `xml:"graphml"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	Keys    []graphMLKey `xml:"key"`
+	Graph   graphMLGraph `xml:"graph"`
+}
+
+// graphMLType returns the GraphML type of the property k, which is the type of
+// its values if they all have the same one. Integers and floats make a
+// double, other mixes of types and lists are written as strings.
+func graphMLType(props []map[string]interface{}, k string) string {
+	attrType := ""
+	for _, p := range props {
+		t := propertyType(p[k])
+		switch {
+		case t == "" || t == attrType:
+		case attrType == "":
+			attrType = t
+		case isNumericType(attrType) && isNumericType(t):
+			attrType = "double"
+		default:
+			return "string"
+		}
+	}
+	if attrType == "" || attrType == "list" {
+		return "string"
+	}
+	return attrType
+}
+
+func exportGraphML(w io.Writer, nodes []exportNode, edges []exportEdge) error {
+	nodeProps := []map[string]interface{}{}
+	for _, n := range nodes {
+		nodeProps = append(nodeProps, propertyValues(n.node.Properties()))
+	}
+	edgeProps := []map[string]interface{}{}
+	for _, e := range edges {
+		edgeProps = append(edgeProps, propertyValues(e.edge.Properties()))
+	}
+
+	doc := graphMLDocument{
+		XMLNS: "http://graphml.graphdrawing.org/xmlns",
+		Keys: []graphMLKey{
+			{ID: "labels", For: "node", AttrName: "labels", AttrType: "string"},
+			{ID: "label", For: "edge", AttrName: "label", AttrType: "string"},
+		},
+		Graph: graphMLGraph{ID: "G", EdgeDefault: "directed"},
+	}
+	nodeKeyIDs := map[string]string{}
+	for i, k := range sortedKeys(nodeProps) {
+		id := "dn" + strconv.Itoa(i)
+		nodeKeyIDs[k] = id
+		doc.Keys = append(doc.Keys, graphMLKey{ID: id, For: "node", AttrName: k, AttrType: graphMLType(nodeProps, k)})
+	}
+	edgeKeyIDs := map[string]string{}
+	for i, k := range sortedKeys(edgeProps) {
+		id := "de" + strconv.Itoa(i)
+		edgeKeyIDs[k] = id
+		doc.Keys = append(doc.Keys, graphMLKey{ID: id, For: "edge", AttrName: k, AttrType: graphMLType(edgeProps, k)})
+	}
+
+	for i, n := range nodes {
+		gn := graphMLNode{ID: n.id, Data: []graphMLData{{Key: "labels", Value: n.node.Type()}}}
+		for _, k := range sortedProperties(nodeProps[i]) {
+			gn.Data = append(gn.Data, graphMLData{Key: nodeKeyIDs[k], Value: propertyString(nodeProps[i][k])})
+		}
+		doc.Graph.Nodes = append(doc.Graph.Nodes, gn)
+	}
+	for i, e := range edges {
+		ge := graphMLEdge{
+			ID:     "e" + strconv.Itoa(i),
+			Source: e.source,
+			Target: e.target,
+			Data:   []graphMLData{{Key: "label", Value: e.edge.Type()}},
+		}
+		for _, k := range sortedProperties(edgeProps[i]) {
+			ge.Data = append(ge.Data, graphMLData{Key: edgeKeyIDs[k], Value: propertyString(edgeProps[i][k])})
+		}
+		doc.Graph.Edges = append(doc.Graph.Edges, ge)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+type nodeLinkNode struct {
+	ID         string                 `json:"id"`
+	Type       string                 `json:"type"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+type nodeLinkEdge struct {
+	Source     string                 `json:"source"`
+	Target     string                 `json:"target"`
+	Type       string                 `json:"type"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+type nodeLinkGraph struct {
+	Directed   bool           `json:"directed"`
+	Multigraph bool           `json:"multigraph"`
+	Nodes      []nodeLinkNode `json:"nodes"`
+	Links      []nodeLinkEdge `json:"links"`
+}
+
+func exportJSON(w io.Writer, nodes []exportNode, edges []exportEdge) error {
+	g := nodeLinkGraph{
+		Directed:   true,
+		Multigraph: true,
+		Nodes:      []nodeLinkNode{},
+		Links:      []nodeLinkEdge{},
+	}
+	for _, n := range nodes {
+		g.Nodes = append(g.Nodes, nodeLinkNode{ID: n.id, Type: n.node.Type(), Properties: n.node.Properties()})
+	}
+	for _, e := range edges {
+		g.Links = append(g.Links, nodeLinkEdge{Source: e.source, Target: e.target, Type: e.edge.Type(), Properties: e.edge.Properties()})
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(g)
+}
+
+func exportDOT(w io.Writer, nodes []exportNode, edges []exportEdge) error {
+	var b strings.Builder
+	b.WriteString("digraph guac {\n")
+	for _, n := range nodes {
+		fmt.Fprintf(&b, "  %s [label=%s];\n", strconv.Quote(n.id), strconv.Quote(nodeLabel(n.node)))
+	}
+	for _, e := range edges {
+		fmt.Fprintf(&b, "  %s -> %s [label=%s];\n", strconv.Quote(e.source), strconv.Quote(e.target), strconv.Quote(e.edge.Type()))
+	}
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,120 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package assembler
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+var (
+	exportArt = ArtifactNode{Name: "app", Digest: "sha256:ABC"}
+	exportPkg = PackageNode{Name: "app", Purl: "pkg:golang/app@v1"}
+	exportDep = PackageNode{Name: "lib", Purl: "pkg:golang/lib@v2"}
+
+	exportGraph = Graph{
+		Nodes: []GuacNode{exportArt, exportPkg, exportPkg},
+		Edges: []GuacEdge{
+			DependsOnEdge{PackageNode: exportPkg, PackageDependency: exportDep},
+			IdentityForEdge{IdentityNode: IdentityNode{ID: "id", Digest: "abc"}, AttestationNode: AttestationNode{FilePath: "att.json", Digest: "def"}},
+		},
+	}
+)
+
+func TestExportGraph_JSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := ExportGraph(&buf, exportGraph, ExportFormatJSON); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var got nodeLinkGraph
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("output is not valid json: %v", err)
+	}
+	wantIDs := []string{
+		"Artifact{digest=sha256:abc}",
+		"Package{purl=pkg:golang/app@v1}",
+		"Package{purl=pkg:golang/lib@v2}",
+		"Identity{digest=abc}",
+		"Attestation{digest=def}",
+	}
+	if len(got.Nodes) != len(wantIDs) {
+		t.Fatalf("got %d nodes, want %d", len(got.Nodes), len(wantIDs))
+	}
+	for i, id := range wantIDs {
+		if got.Nodes[i].ID != id {
+			t.Errorf("node %d: got id %q, want %q", i, got.Nodes[i].ID, id)
+		}
+	}
+	if len(got.Links) != 2 {
+		t.Fatalf("got %d links, want 2", len(got.Links))
+	}
+	if l := got.Links[0]; l.Source != wantIDs[1] || l.Target != wantIDs[2] || l.Type != "DependsOn" {
+		t.Errorf("unexpected link: %+v", l)
+	}
+}
+
+func TestExportGraph_GraphML(t *testing.T) {
+	var buf bytes.Buffer
+	if err := ExportGraph(&buf, exportGraph, ExportFormatGraphML); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var got graphMLDocument
+	if err := xml.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("output is not valid xml: %v", err)
+	}
+	if len(got.Graph.Nodes) != 5 || len(got.Graph.Edges) != 2 {
+		t.Fatalf("got %d nodes and %d edges, want 5 and 2", len(got.Graph.Nodes), len(got.Graph.Edges))
+	}
+	keys := map[string]bool{}
+	for _, k := range got.Keys {
+		keys[k.ID] = true
+	}
+	for _, n := range got.Graph.Nodes {
+		for _, d := range n.Data {
+			if !keys[d.Key] {
+				t.Errorf("node %q references undeclared key %q", n.ID, d.Key)
+			}
+		}
+	}
+}
+
+func TestExportGraph_DOT(t *testing.T) {
+	var buf bytes.Buffer
+	if err := ExportGraph(&buf, exportGraph, ExportFormatDOT); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got := buf.String()
+	for _, want := range []string{
+		"digraph guac {",
+		`"Package{purl=pkg:golang/app@v1}" [label="Package\npkg:golang/app@v1"];`,
+		`"Artifact{digest=sha256:abc}" [label="Artifact\nsha256:abc"];`,
+		`"Package{purl=pkg:golang/app@v1}" -> "Package{purl=pkg:golang/lib@v2}" [label="DependsOn"];`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output missing %q, got:\n%s", want, got)
+		}
+	}
+}
+
+func TestExportGraph_UnknownFormat(t *testing.T) {
+	var buf bytes.Buffer
+	if err := ExportGraph(&buf, exportGraph, "svg"); err == nil {
+		t.Error("expected error for unsupported format")
+	}
+}
@@ -16,79 +16,596 @@
 package assembler
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"math"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/guacsec/guac/pkg/assembler/graphdb"
+	"github.com/guacsec/guac/pkg/logging"
+	"github.com/guacsec/guac/pkg/tracing"
 	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+	"go.uber.org/zap"
+	"golang.org/x/time/rate"
 )
 
 // Note: This module is experimental and might change often!
 
+// NewWriteLimiter returns a token bucket limiter allowing opsPerSecond write
+// operations to the graph database, with bursts of up to one second worth of
+// operations. A non positive rate means unlimited and returns nil.
+func NewWriteLimiter(opsPerSecond float64) *rate.Limiter {
+	if opsPerSecond <= 0 {
+		return nil
+	}
+	return rate.NewLimiter(rate.Limit(opsPerSecond), int(math.Max(1, opsPerSecond)))
+}
+
+// WriteOptions configures how graphs are written to the graph database
+type WriteOptions struct {
+	// Limiter limits the write operations (one per node and edge),
+	// unlimited if nil
+	Limiter *rate.Limiter
+	// BatchSize is the maximum number of write operations committed by a
+	// transaction, DefaultWriteOptions.BatchSize if not positive. It is
+	// lowered to the burst size of Limiter so that a low rate never keeps a
+	// transaction open.
+	BatchSize int
+	// DeadlockRetries is the number of times a batch is written again after
+	// failing on a deadlock, once the retries of the driver are exhausted
+	DeadlockRetries int
+	// Backoff is the delay before the first retry, doubled after each retry
+	Backoff time.Duration
+}
+
+// DefaultWriteOptions writes up to 1000 nodes and edges per transaction,
+// retrying a deadlocked batch up to 3 times, after 100ms, 200ms and 400ms
+var DefaultWriteOptions = WriteOptions{
+	BatchSize:       1000,
+	DeadlockRetries: 3,
+	Backoff:         100 * time.Millisecond,
+}
+
+// batchSize returns the number of write operations of a transaction
+func (o WriteOptions) batchSize() int {
+	size := o.BatchSize
+	if size <= 0 {
+		size = DefaultWriteOptions.BatchSize
+	}
+	if o.Limiter != nil && o.Limiter.Burst() < size {
+		size = o.Limiter.Burst()
+	}
+	if size < 1 {
+		size = 1
+	}
+	return size
+}
+
 // StoreSubgraph stores a Graph to the graph database given by Client
 func StoreGraph(g Graph, client graphdb.Client) error {
+	return StoreGraphWithLimiter(context.Background(), g, client, nil)
+}
+
+// StoreGraphWithLimiter stores a Graph to the graph database given by Client
+// with the DefaultWriteOptions, waiting on limiter before each batch of
+// writes. A nil limiter does not limit writes.
+func StoreGraphWithLimiter(ctx context.Context, g Graph, client graphdb.Client, limiter *rate.Limiter) error {
+	opts := DefaultWriteOptions
+	opts.Limiter = limiter
+	return StoreGraphWithOptions(ctx, g, client, opts)
+}
+
+// StoreGraphWithOptions stores a Graph to the graph database given by
+// Client. The writes (one per node and edge) are split in batches of
+// opts.batchSize(), each committed in its own transaction once opts.Limiter
+// allows the batch. Identical nodes and edges are written once, see
+// DeduplicateGraph, and the writes of a batch merging the same node or edge
+// are collapsed into one. The writes of a batch sharing a statement, e.g.
+// merging packages, are run as a single UNWIND query over their rows. Nodes
+// are merged on their identifiable properties (the digest of artifacts and
+// attestations, the purl of packages...) and edges on their type and nodes,
+// so storing a graph again does not duplicate it. A batch failing
+// on a deadlock is written again, its writes being idempotent, and the
+// batches already committed are kept if a batch fails.
+//
+// Artifacts are merged with a stored artifact sharing any of their digests,
+// unless the digests of both conflict (different digests for the same
+// algorithm). Conflicts are logged as a possible integrity issue and the
+// artifact is stored as a distinct node.
+//
+// Properties are stored with their native type, as converted by
+// propertyValue, the last value written replacing the stored one.
+func StoreGraphWithOptions(ctx context.Context, g Graph, client graphdb.Client, opts WriteOptions) (err error) {
+	ctx, span := tracing.Start(ctx, "store")
+	span.SetAttribute("guac.nodes", len(g.Nodes))
+	span.SetAttribute("guac.edges", len(g.Edges))
+	start := time.Now()
+	defer func() {
+		span.RecordError(err)
+		span.End()
+		observeStore(Neo4jBackend, start, err)
+	}()
+
+	ctx = logging.WithComponent(ctx, logging.ComponentAssembler)
+	logger := logging.FromContext(ctx)
+	g = DeduplicateGraph(g)
 	session := client.NewSession(neo4j.SessionConfig{})
 	defer session.Close()
 
-	node_queries := make([]string, len(g.Nodes))
-	node_dicts := make([]map[string]interface{}, len(g.Nodes))
-	for i, n := range g.Nodes {
-		var sb strings.Builder
-		if err := queryPartForMergeNode(&sb, n, "n"); err != nil {
-			return err
-		}
-		queryPartForNodeAttributes(&sb, true, n, "n")
-		queryPartForNodeAttributes(&sb, false, n, "n")
-		node_queries[i] = sb.String()
-		node_dicts[i] = map[string]interface{}{}
-		for k, v := range n.Properties() {
-			node_dicts[i]["n_"+k] = v
+	resolver, err := newArtifactResolver(ctx, g, func(digests []string) ([][]string, error) {
+		stored, err := session.ReadTransaction(
+			func(tx graphdb.Transaction) (interface{}, error) {
+				return findArtifacts(tx, digests)
+			})
+		if err != nil {
+			return nil, err
 		}
+		return stored.([][]string), nil
+	})
+	if err != nil {
+		return err
+	}
+	writes, err := graphWrites(g, resolver)
+	if err != nil {
+		return err
 	}
 
-	edge_queries := make([]string, len(g.Edges))
-	edge_dicts := make([]map[string]interface{}, len(g.Edges))
-	for i, e := range g.Edges {
-		a, b := e.Nodes()
-		var sb strings.Builder
-		if err := queryPartForMergeNode(&sb, a, "a"); err != nil {
-			return err
+	batchSize := opts.batchSize()
+	for len(writes) > 0 {
+		batch := writes
+		if len(batch) > batchSize {
+			batch = batch[:batchSize]
+		}
+		writes = writes[len(batch):]
+		if opts.Limiter != nil {
+			if err := opts.Limiter.WaitN(ctx, len(batch)); err != nil {
+				return err
+			}
+		}
+		queries := bulkQueries(batch)
+		backoff := opts.Backoff
+		for retry := 0; ; retry++ {
+			_, err = session.WriteTransaction(
+				func(tx graphdb.Transaction) (interface{}, error) {
+					for _, q := range queries {
+						result, err := tx.Run(q.query, q.params)
+						if err != nil {
+							return nil, err
+						}
+						if _, err := result.Consume(); err != nil {
+							return nil, err
+						}
+					}
+					return nil, nil
+				})
+			if err == nil || !isDeadlock(err) || retry >= opts.DeadlockRetries {
+				break
+			}
+			logger.Warnf("deadlock writing a batch of %d nodes and edges, retrying in %v: %v", len(batch), backoff, err)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(backoff):
+			}
+			backoff *= 2
 		}
-		if err := queryPartForMergeNode(&sb, b, "b"); err != nil {
+		if err != nil {
 			return err
 		}
-		queryPartForEdgeConnection(&sb, e)
-		edge_queries[i] = sb.String()
-		edge_dicts[i] = map[string]interface{}{}
-		for k, v := range a.Properties() {
-			edge_dicts[i]["a_"+k] = v
+	}
+	return nil
+}
+
+// isDeadlock returns whether err is a deadlock detected by Neo4j, possibly
+// after the retries of the driver
+func isDeadlock(err error) bool {
+	var limit *neo4j.TransactionExecutionLimit
+	if errors.As(err, &limit) && len(limit.Errors) > 0 {
+		err = limit.Errors[len(limit.Errors)-1]
+	}
+	var neo4jErr *neo4j.Neo4jError
+	return errors.As(err, &neo4jErr) && neo4jErr.Code == "Neo.TransientError.Transaction.DeadlockDetected"
+}
+
+// graphWrite is the write of a node or edge: a statement merging the
+// parameters of row, the row of the UNWIND query it is run in. Writes with
+// the same key merge the same node or edge.
+type graphWrite struct {
+	statement string
+	key       string
+	row       map[string]interface{}
+}
+
+// graphQuery is a write query and its parameters
+type graphQuery struct {
+	query  string
+	params map[string]interface{}
+}
+
+// graphWrites returns the writes merging the documents, the nodes then the
+// edges of g, with their artifacts resolved to the stored ones. The
+// documents asserting a node or an edge are looked up before it is resolved,
+// as g.Evidence is keyed by the nodes of g.
+func graphWrites(g Graph, resolver *artifactResolver) ([]graphWrite, error) {
+	writes := make([]graphWrite, 0, len(g.Documents)+len(g.Nodes)+len(g.Edges))
+	for _, doc := range g.Documents {
+		w, err := nodeWrite(doc, nil)
+		if err != nil {
+			return nil, err
+		}
+		writes = append(writes, w)
+	}
+	for _, n := range g.Nodes {
+		w, err := nodeWrite(resolver.resolve(n), g.Evidence[nodeKey(n)])
+		if err != nil {
+			return nil, err
+		}
+		writes = append(writes, w)
+	}
+	for _, e := range g.Edges {
+		a, b := e.Nodes()
+		docs := g.Evidence[edgeKey(e)]
+		var w graphWrite
+		var err error
+		if me, ok := e.(MatchingEdge); ok {
+			w, err = matchingEdgeWrite(me, resolver.resolve(a), docs)
+		} else {
+			w, err = edgeWrite(e, resolver.resolve(a), resolver.resolve(b), docs)
 		}
-		for k, v := range b.Properties() {
-			edge_dicts[i]["b_"+k] = v
+		if err != nil {
+			return nil, err
 		}
-		for k, v := range e.Properties() {
-			edge_dicts[i]["e_"+k] = v
+		writes = append(writes, w)
+	}
+	return writes, nil
+}
+
+// bulkQueries returns the queries running writes: one UNWIND query per
+// statement over the rows of its writes, in order. The queries are in the
+// order their statement first appears, so the nodes of a batch are merged
+// before its edges. The rows of the writes with the same key are collapsed
+// into the first one, the properties written last replacing the others as
+// they would if written in order, and the documents of all being added.
+func bulkQueries(writes []graphWrite) []graphQuery {
+	statements := []string{}
+	rows := map[string][]interface{}{}
+	written := map[string]map[string]interface{}{}
+	for _, w := range writes {
+		if row, ok := written[w.key]; ok {
+			mergeRow(row, w.row)
+			continue
 		}
+		if _, ok := rows[w.statement]; !ok {
+			statements = append(statements, w.statement)
+		}
+		row := make(map[string]interface{}, len(w.row))
+		mergeRow(row, w.row)
+		written[w.key] = row
+		rows[w.statement] = append(rows[w.statement], row)
 	}
+	queries := make([]graphQuery, 0, len(statements))
+	for _, statement := range statements {
+		queries = append(queries, graphQuery{
+			query:  "UNWIND $rows AS row\n" + statement,
+			params: map[string]interface{}{"rows": rows[statement]},
+		})
+	}
+	return queries
+}
 
-	queries := append(node_queries, edge_queries...)
-	params := append(node_dicts, edge_dicts...)
-	_, err := session.WriteTransaction(
-		func(tx graphdb.Transaction) (interface{}, error) {
-			for i, query := range queries {
-				result, err := tx.Run(query, params[i])
-				if err != nil {
-					return nil, err
+// mergeRow sets the parameters of row into merged, merging the property maps
+// and the lists of documents of both without changing the ones of row
+func mergeRow(merged map[string]interface{}, row map[string]interface{}) {
+	for k, v := range row {
+		if docs, ok := v.([]string); ok {
+			stored, _ := merged[k].([]string)
+			mergedDocs := append([]string{}, stored...)
+			for _, d := range docs {
+				if !containsString(mergedDocs, d) {
+					mergedDocs = append(mergedDocs, d)
 				}
-				_, err = result.Consume()
-				if err != nil {
-					return nil, err
+			}
+			merged[k] = mergedDocs
+			continue
+		}
+		props, ok := v.(map[string]interface{})
+		if !ok {
+			merged[k] = v
+			continue
+		}
+		stored, _ := merged[k].(map[string]interface{})
+		mergedProps := make(map[string]interface{}, len(stored)+len(props))
+		for pk, pv := range stored {
+			mergedProps[pk] = pv
+		}
+		for pk, pv := range props {
+			mergedProps[pk] = pv
+		}
+		merged[k] = mergedProps
+	}
+}
+
+// nodeWrite returns the write merging node n, asserted by the documents
+// with digests docs
+func nodeWrite(n GuacNode, docs []string) (graphWrite, error) {
+	var sb strings.Builder
+	if err := queryPartForMergeNode(&sb, n, "n"); err != nil {
+		return graphWrite{}, err
+	}
+	sb.WriteString("SET n += row.n\n")
+	queryPartForDocuments(&sb, "n")
+	return graphWrite{
+		statement: sb.String(),
+		key:       nodeKey(n),
+		row: map[string]interface{}{
+			"n":    propertyValues(n.Properties()),
+			"docs": append([]string{}, docs...),
+		},
+	}, nil
+}
+
+// edgeWrite returns the write merging edge e between nodes a and b, asserted
+// by the documents with digests docs
+func edgeWrite(e GuacEdge, a GuacNode, b GuacNode, docs []string) (graphWrite, error) {
+	var sb strings.Builder
+	if err := queryPartForMergeNode(&sb, a, "a"); err != nil {
+		return graphWrite{}, err
+	}
+	if err := queryPartForMergeNode(&sb, b, "b"); err != nil {
+		return graphWrite{}, err
+	}
+	queryPartForEdgeConnection(&sb, e, "b")
+	return graphWrite{
+		statement: sb.String(),
+		key:       e.Type() + "/" + nodeKey(a) + "/" + nodeKey(b),
+		row: map[string]interface{}{
+			"a":    identifiableValues(a),
+			"b":    identifiableValues(b),
+			"e":    propertyValues(e.Properties()),
+			"docs": append([]string{}, docs...),
+		},
+	}, nil
+}
+
+// matchingEdgeWrite returns the write merging edge e between node a and the
+// stored nodes it matches, asserted by the documents with digests docs
+func matchingEdgeWrite(e MatchingEdge, a GuacNode, docs []string) (graphWrite, error) {
+	_, u := e.Nodes()
+	condition, conditionParams := e.Match()
+	// the parameters of the condition are read from the row
+	names := make([]string, 0, len(conditionParams))
+	for name := range conditionParams {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return len(names[i]) > len(names[j]) })
+	for _, name := range names {
+		condition = strings.ReplaceAll(condition, "$"+name, "row.m."+name)
+	}
+
+	var sb strings.Builder
+	if err := queryPartForMergeNode(&sb, a, "a"); err != nil {
+		return graphWrite{}, err
+	}
+	sb.WriteString("WITH a, row\nMATCH (u:")
+	sb.WriteString(u.Type()) // not user controlled
+	sb.WriteString(")\nWHERE ")
+	sb.WriteString(condition) // not user controlled
+	sb.WriteString("\n")
+	queryPartForEdgeConnection(&sb, e, "u")
+	return graphWrite{
+		statement: sb.String(),
+		// the nodes matched depend on the parameters of the condition
+		key: fmt.Sprintf("%s/%s/%#v", e.Type(), nodeKey(a), conditionParams),
+		row: map[string]interface{}{
+			"a":    identifiableValues(a),
+			"e":    propertyValues(e.Properties()),
+			"m":    conditionParams,
+			"docs": append([]string{}, docs...),
+		},
+	}, nil
+}
+
+// identifiableValues returns the values of the identifiable properties of n,
+// which are the ones its node is merged on
+func identifiableValues(n GuacNode) map[string]interface{} {
+	props := n.Properties()
+	values := map[string]interface{}{}
+	for _, key := range n.IdentifiablePropertyNames() {
+		values[key] = propertyValue(props[key])
+	}
+	return values
+}
+
+// artifactResolver finds the stored artifact an artifact is merged with.
+// The stored artifacts sharing a digest with the artifacts of a graph are
+// read at once, and the artifacts resolved are added to them as they would
+// be once written.
+type artifactResolver struct {
+	logger *zap.SugaredLogger
+	// stored are the digests of the artifacts, each starting with the
+	// digest identifying the artifact
+	stored   [][]string
+	byDigest map[string][]int
+	resolved map[string]ArtifactNode
+}
+
+// newArtifactResolver returns the resolver of the artifacts of g, given the
+// digests of the stored artifacts having any of digests, as returned by find
+func newArtifactResolver(ctx context.Context, g Graph, find func(digests []string) ([][]string, error)) (*artifactResolver, error) {
+	r := &artifactResolver{
+		logger:   logging.FromContext(ctx),
+		byDigest: map[string][]int{},
+		resolved: map[string]ArtifactNode{},
+	}
+	digests := []string{}
+	seen := map[string]bool{}
+	for _, n := range g.Nodes {
+		if an, ok := n.(ArtifactNode); ok {
+			for _, d := range an.Digests() {
+				if !seen[d] {
+					seen[d] = true
+					digests = append(digests, d)
 				}
 			}
-			return nil, nil
-		})
+		}
+	}
+	if len(digests) == 0 {
+		return r, nil
+	}
+	stored, err := find(digests)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range stored {
+		r.add(-1, s)
+	}
+	return r, nil
+}
 
-	return err
+// add records the digests of a stored artifact, replacing the ones at index i
+// if it is not negative
+func (r *artifactResolver) add(i int, digests []string) {
+	if i < 0 {
+		i = len(r.stored)
+		r.stored = append(r.stored, nil)
+	}
+	for _, d := range digests {
+		if !containsString(r.stored[i], d) {
+			r.byDigest[d] = append(r.byDigest[d], i)
+		}
+	}
+	r.stored[i] = digests
+}
+
+// resolve returns n with its Digest replaced by the one identifying the
+// stored artifact sharing a digest with n. Other nodes are returned as is.
+func (r *artifactResolver) resolve(n GuacNode) GuacNode {
+	an, ok := n.(ArtifactNode)
+	if !ok {
+		return n
+	}
+	digests := an.Digests()
+	key := strings.Join(digests, ",")
+	if resolved, ok := r.resolved[key]; ok {
+		return resolved
+	}
+
+	resolved := an
+	merged := -1
+	var conflicting []string
+	for _, i := range r.candidates(digests) {
+		storedDigests := r.stored[i]
+		shared, conflict := compareDigests(storedDigests, digests)
+		if conflict {
+			r.logger.Warnf("possible integrity issue: artifact %q with digests %v conflicts with stored artifact with digests %v, storing it as a distinct artifact",
+				an.Name, digests, storedDigests)
+			conflicting = append(conflicting, storedDigests...)
+			continue
+		}
+		if shared {
+			resolved = withPrimaryDigest(an, storedDigests[0])
+			merged = i
+			conflicting = nil
+			break
+		}
+	}
+	// a conflicting artifact may have the same primary digest, which would
+	// merge them, so identify this one by a digest the other does not have
+	if len(conflicting) > 0 && containsString(conflicting, strings.ToLower(resolved.Digest)) {
+		for _, d := range digests {
+			if !containsString(conflicting, d) {
+				resolved = withPrimaryDigest(an, d)
+				break
+			}
+		}
+	}
+	r.resolved[key] = resolved
+
+	// the artifact is written with its digests, merged into the stored one
+	resolvedDigests := resolved.Digests()
+	if merged >= 0 {
+		for _, d := range r.stored[merged] {
+			if !containsString(resolvedDigests, d) {
+				resolvedDigests = append(resolvedDigests, d)
+			}
+		}
+	}
+	r.add(merged, resolvedDigests)
+	return resolved
+}
+
+// candidates returns the index of the stored artifacts having any of
+// digests, in the order they were stored
+func (r *artifactResolver) candidates(digests []string) []int {
+	found := map[int]bool{}
+	for _, d := range digests {
+		for _, i := range r.byDigest[d] {
+			found[i] = true
+		}
+	}
+	candidates := make([]int, 0, len(found))
+	for i := range found {
+		candidates = append(candidates, i)
+	}
+	sort.Ints(candidates)
+	return candidates
+}
+
+// findArtifacts returns the digests of the stored artifacts having any of
+// digests, each starting with the digest identifying the artifact
+func findArtifacts(tx graphdb.Transaction, digests []string) ([][]string, error) {
+	var sb strings.Builder
+	sb.WriteString("UNWIND $digests AS d\nMATCH (n:Artifact) WHERE n.digest = d")
+	names := map[string]bool{}
+	for _, d := range digests {
+		name := digestPropertyName(d)
+		if name == "" || names[name] {
+			continue
+		}
+		names[name] = true
+		sb.WriteString(" OR n.")
+		sb.WriteString(name) // not user controlled
+		sb.WriteString(" = d")
+	}
+	sb.WriteString("\nRETURN DISTINCT properties(n)")
+
+	result, err := tx.Run(sb.String(), map[string]interface{}{"digests": digests})
+	if err != nil {
+		return nil, err
+	}
+	stored := [][]string{}
+	for result.Next() {
+		props, ok := result.Record().Values[0].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		primary, _ := props["digest"].(string)
+		found := []string{primary}
+		for k, v := range props {
+			if d, ok := v.(string); ok && strings.HasPrefix(k, digestPropertyPrefix) && d != primary {
+				found = append(found, d)
+			}
+		}
+		stored = append(stored, found)
+	}
+	return stored, result.Err()
+}
+
+// withPrimaryDigest returns an identified by digest d, keeping its other
+// digests as alternates
+func withPrimaryDigest(an ArtifactNode, d string) ArtifactNode {
+	resolved := an
+	resolved.Digest = d
+	resolved.AlternateDigests = nil
+	for _, other := range an.Digests() {
+		if other != d {
+			resolved.AlternateDigests = append(resolved.AlternateDigests, other)
+		}
+	}
+	return resolved
 }
 
 // CreateIndexOn creates database indixes in the graph database given by Client
@@ -111,7 +628,7 @@ func CreateIndexOn(client graphdb.Client, nodeLabel string, nodeAttribute string
 	return err
 }
 
-// Creates the "MERGE (n:${NODE_TYPE} {${ATTR}:${VALUE}, ...})" part of the query
+// Creates the "MERGE (n:${NODE_TYPE} {${ATTR}:row.n.${ATTR}, ...})" part of the query
 func queryPartForMergeNode(sb *strings.Builder, n GuacNode, label string) error {
 	node_data := n.Properties()
 	sb.WriteString("MERGE (")
@@ -121,7 +638,7 @@ func queryPartForMergeNode(sb *strings.Builder, n GuacNode, label string) error
 	sb.WriteString(" {")
 	for ix, key := range n.IdentifiablePropertyNames() {
 		if _, ok := node_data[key]; ok {
-			writeKeyValToQuery(sb, key, label, false, ix == 0)
+			writeKeyValToQuery(sb, key, label, ix == 0)
 		} else {
 			return fmt.Errorf("Node %v has no value for property %v", n, key)
 		}
@@ -131,56 +648,40 @@ func queryPartForMergeNode(sb *strings.Builder, n GuacNode, label string) error
 	return nil
 }
 
-// Creates the "ON CREATE SET ${ATTR}=${VALUE}, ..." part of the query
-// Creates the "ON MATCH SET ${ATTR}=${VALUE}, ..." part of the query
-func queryPartForNodeAttributes(sb *strings.Builder, onCreate bool, n GuacNode, label string) {
-	node_data := n.Properties()
-	if onCreate {
-		sb.WriteString("ON CREATE SET ")
-	} else {
-		sb.WriteString("ON MATCH SET ")
-	}
-	first := true
-	for key := range node_data {
-		writeKeyValToQuery(sb, key, label, true, first)
-		first = false
-	}
-	sb.WriteString("\n")
+// Creates the "MERGE (a) -[e:${EDGE_TYPE}]-> (${TO})" part of the query and
+// sets the edge attributes from the row
+func queryPartForEdgeConnection(sb *strings.Builder, e GuacEdge, to string) {
+	sb.WriteString("MERGE (a) -[e:")
+	sb.WriteString(e.Type()) // not user controlled, or validated for MaterialEdge
+	sb.WriteString("]-> (")
+	sb.WriteString(to) // not user controlled
+	sb.WriteString(")\nSET e += row.e\n")
+	queryPartForDocuments(sb, "e")
 }
 
-// Creates the "(a) -[e:${EDGE_TYPE}] -> (b)" part of the query and sets the edge attributes
-func queryPartForEdgeConnection(sb *strings.Builder, e GuacEdge) {
-	sb.WriteString("MERGE (a) -[e:")
-	sb.WriteString(e.Type()) // not user controlled
-	sb.WriteString("]-> (b)")
-	if edge_data := e.Properties(); len(edge_data) > 0 {
-		sb.WriteString("\nSET ")
-		first := true
-		for key := range edge_data {
-			writeKeyValToQuery(sb, key, "e", true, first)
-			first = false
-		}
-	}
-	sb.WriteString("\n")
+// Creates the part of the query adding the documents of the row missing from
+// the `documents` property of ${LABEL}, leaving it unset without documents
+func queryPartForDocuments(sb *strings.Builder, label string) {
+	sb.WriteString("SET ")
+	sb.WriteString(label) // not user controlled
+	sb.WriteString(".documents = CASE WHEN size(row.docs) = 0 THEN ")
+	sb.WriteString(label)
+	sb.WriteString(".documents ELSE coalesce(")
+	sb.WriteString(label)
+	sb.WriteString(".documents, []) + [d IN row.docs WHERE NOT d IN coalesce(")
+	sb.WriteString(label)
+	sb.WriteString(".documents, [])] END\n")
 }
 
-// Creates either the "${ATTR}:${VALUE}" part (set=false) or the "n.${ATTR}=${VALUE}" one (set=true).
+// Creates the "${ATTR}:row.${LABEL}.${ATTR}" part.
 // Uses first to determine if we need to add comma from what comes before
-func writeKeyValToQuery(sb *strings.Builder, key string, label string, set bool, first bool) {
+func writeKeyValToQuery(sb *strings.Builder, key string, label string, first bool) {
 	if !first {
 		sb.WriteString(", ")
 	}
-	if set {
-		sb.WriteString(label) // not user controlled
-		sb.WriteString(".")
-	}
 	sb.WriteString(key) // not user controlled
-	if set {
-		sb.WriteString("=$")
-	} else {
-		sb.WriteString(":$")
-	}
+	sb.WriteString(":row.")
 	sb.WriteString(label) // not user controlled
-	sb.WriteString("_")
-	sb.WriteString(key) // not user controlled, will be as a prepared statement parameter
+	sb.WriteString(".")
+	sb.WriteString(key) // not user controlled
 }
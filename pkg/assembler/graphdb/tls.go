@@ -0,0 +1,225 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graphdb
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+)
+
+// tlsDialTimeout bounds connecting to the graph database and completing the
+// TLS handshake, so that a server ignoring the client does not hang us
+const tlsDialTimeout = 10 * time.Second
+
+// boltHandshake is the Bolt magic preamble followed by the protocol versions
+// supported by the driver (4.4, 4.3, 4.2, 3.0)
+var boltHandshake = []byte{
+	0x60, 0x60, 0xb0, 0x17,
+	0x00, 0x00, 0x04, 0x04,
+	0x00, 0x00, 0x03, 0x04,
+	0x00, 0x00, 0x02, 0x04,
+	0x00, 0x00, 0x00, 0x03,
+}
+
+// LoadClientTLSConfig returns a TLS configuration presenting the client
+// certificate in certPath/keyPath. If caPath is set, the server certificate
+// is verified against the CAs in that file instead of the system roots.
+func LoadClientTLSConfig(certPath string, keyPath string, caPath string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load client certificate: %w", err)
+	}
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if caPath != "" {
+		caPEM, err := os.ReadFile(caPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA certificate: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("no certificates found in CA file %s", caPath)
+		}
+		config.RootCAs = pool
+	}
+	return config, nil
+}
+
+// NewGraphClientWithTLS returns a client that connects to the graph database
+// at uri over mutual TLS using tlsConfig.
+//
+// The v4 Neo4j driver cannot present client certificates, so connections are
+// tunneled: the driver talks plain Bolt over a private unix socket and every
+// connection on it is forwarded to the server over TLS. Only direct
+// connections (`bolt+s://`) are supported, as routing would make the driver
+// dial cluster members directly.
+func NewGraphClientWithTLS(uri string, authToken AuthToken, tlsConfig *tls.Config) (Client, error) {
+	parsed, err := url.Parse(uri)
+	if err != nil {
+		return nil, err
+	}
+	if parsed.Scheme != "bolt+s" {
+		return nil, fmt.Errorf("client certificate authentication requires a bolt+s:// address, got %s://", parsed.Scheme)
+	}
+	address := parsed.Host
+	if parsed.Port() == "" {
+		address += ":7687"
+	}
+	config := tlsConfig.Clone()
+	if config.ServerName == "" {
+		config.ServerName = parsed.Hostname()
+	}
+
+	// Fail early with a clear error if the server refuses the certificate,
+	// rather than surfacing a closed connection from within the driver.
+	if err := probeTLS(address, config); err != nil {
+		return nil, err
+	}
+
+	t, err := newTLSTunnel(address, config)
+	if err != nil {
+		return nil, err
+	}
+	driver, err := neo4j.NewDriver("bolt+unix://"+t.socket, authToken)
+	if err != nil {
+		t.close()
+		return nil, err
+	}
+	if err = driver.VerifyConnectivity(); err != nil {
+		driver.Close()
+		t.close()
+		return nil, err
+	}
+	return &tunneledClient{Driver: driver, tunnel: t}, nil
+}
+
+// probeTLS connects to address and starts a Bolt handshake. With TLS 1.3 a
+// rejected client certificate is only reported once the client reads from
+// the connection, hence the handshake.
+func probeTLS(address string, config *tls.Config) error {
+	conn, err := tls.DialWithDialer(&net.Dialer{Timeout: tlsDialTimeout}, "tcp", address, config)
+	if err != nil {
+		return fmt.Errorf("TLS connection to graph database at %s failed: %w", address, err)
+	}
+	defer conn.Close()
+
+	if err := conn.SetDeadline(time.Now().Add(tlsDialTimeout)); err != nil {
+		return err
+	}
+	if _, err := conn.Write(boltHandshake); err != nil {
+		return fmt.Errorf("graph database at %s rejected the TLS connection, check the client certificate: %w", address, err)
+	}
+	version := make([]byte, 4)
+	if _, err := io.ReadFull(conn, version); err != nil {
+		return fmt.Errorf("graph database at %s rejected the TLS connection, check the client certificate: %w", address, err)
+	}
+	return nil
+}
+
+// tlsTunnel forwards connections accepted on a unix socket to a TLS server
+type tlsTunnel struct {
+	address  string
+	config   *tls.Config
+	dir      string
+	socket   string
+	listener net.Listener
+	wg       sync.WaitGroup
+}
+
+func newTLSTunnel(address string, config *tls.Config) (*tlsTunnel, error) {
+	dir, err := os.MkdirTemp("", "guac-graphdb-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create tunnel directory: %w", err)
+	}
+	socket := filepath.Join(dir, "bolt.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("failed to listen on tunnel socket: %w", err)
+	}
+	t := &tlsTunnel{
+		address:  address,
+		config:   config,
+		dir:      dir,
+		socket:   socket,
+		listener: listener,
+	}
+	t.wg.Add(1)
+	go t.serve()
+	return t, nil
+}
+
+func (t *tlsTunnel) serve() {
+	defer t.wg.Done()
+	for {
+		local, err := t.listener.Accept()
+		if err != nil {
+			return
+		}
+		go t.forward(local)
+	}
+}
+
+func (t *tlsTunnel) forward(local net.Conn) {
+	defer local.Close()
+	remote, err := tls.DialWithDialer(&net.Dialer{Timeout: tlsDialTimeout}, "tcp", t.address, t.config)
+	if err != nil {
+		return
+	}
+	defer remote.Close()
+
+	done := make(chan struct{}, 2)
+	go func() {
+		_, _ = io.Copy(remote, local)
+		done <- struct{}{}
+	}()
+	go func() {
+		_, _ = io.Copy(local, remote)
+		done <- struct{}{}
+	}()
+	// closing both ends on the first finished direction unblocks the other
+	<-done
+}
+
+func (t *tlsTunnel) close() {
+	t.listener.Close()
+	t.wg.Wait()
+	os.RemoveAll(t.dir)
+}
+
+// tunneledClient closes its tunnel together with the driver
+type tunneledClient struct {
+	neo4j.Driver
+	tunnel *tlsTunnel
+}
+
+func (c *tunneledClient) Close() error {
+	err := c.Driver.Close()
+	c.tunnel.close()
+	return err
+}
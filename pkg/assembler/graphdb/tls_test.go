@@ -0,0 +1,213 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graphdb
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	der  []byte
+}
+
+func newTestCert(t *testing.T, name string, parent *testCert, isCA bool) *testCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  isCA,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	signer, signerKey := tmpl, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCert{cert: cert, key: key, der: der}
+}
+
+// write stores the certificate and key as PEM files in dir
+func (c *testCert) write(t *testing.T, dir, name string) (string, string) {
+	t.Helper()
+	certPath := filepath.Join(dir, name+".crt")
+	keyPath := filepath.Join(dir, name+".key")
+	keyDER, err := x509.MarshalECPrivateKey(c.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return certPath, keyPath
+}
+
+// startBoltTLSServer starts a server that requires client certificates
+// signed by ca and answers the Bolt handshake with version 4.4
+func startBoltTLSServer(t *testing.T, ca *testCert, server *testCert) string {
+	t.Helper()
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	config := &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{server.der}, PrivateKey: server.key}},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    pool,
+	}
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				buf := make([]byte, len(boltHandshake))
+				if _, err := io.ReadFull(conn, buf); err != nil {
+					return
+				}
+				_, _ = conn.Write([]byte{0x00, 0x00, 0x04, 0x04})
+			}()
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func Test_probeTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCert(t, "ca", nil, true)
+	server := newTestCert(t, "server", ca, false)
+	trusted := newTestCert(t, "client", ca, false)
+	untrusted := newTestCert(t, "other", nil, true)
+
+	caPath, _ := ca.write(t, dir, "ca")
+	trustedCert, trustedKey := trusted.write(t, dir, "trusted")
+	untrustedCert, untrustedKey := untrusted.write(t, dir, "untrusted")
+
+	address := startBoltTLSServer(t, ca, server)
+
+	tests := []struct {
+		name     string
+		certPath string
+		keyPath  string
+		wantErr  bool
+	}{{
+		name:     "trusted client certificate",
+		certPath: trustedCert,
+		keyPath:  trustedKey,
+	}, {
+		name:     "untrusted client certificate",
+		certPath: untrustedCert,
+		keyPath:  untrustedKey,
+		wantErr:  true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := LoadClientTLSConfig(tt.certPath, tt.keyPath, caPath)
+			if err != nil {
+				t.Fatalf("LoadClientTLSConfig() unexpected error: %v", err)
+			}
+			config.ServerName = "127.0.0.1"
+			err = probeTLS(address, config)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("probeTLS() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !strings.Contains(err.Error(), "check the client certificate") {
+				t.Errorf("probeTLS() error = %v, want a client certificate hint", err)
+			}
+		})
+	}
+}
+
+func Test_LoadClientTLSConfig(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCert(t, "ca", nil, true)
+	certPath, keyPath := newTestCert(t, "client", ca, false).write(t, dir, "client")
+	caPath, _ := ca.write(t, dir, "ca")
+	notPEM := filepath.Join(dir, "empty.pem")
+	if err := os.WriteFile(notPEM, []byte("not a certificate"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		certPath string
+		keyPath  string
+		caPath   string
+		wantErr  bool
+	}{
+		{name: "with CA", certPath: certPath, keyPath: keyPath, caPath: caPath},
+		{name: "system roots", certPath: certPath, keyPath: keyPath},
+		{name: "missing key", certPath: certPath, keyPath: filepath.Join(dir, "missing"), wantErr: true},
+		{name: "invalid CA", certPath: certPath, keyPath: keyPath, caPath: notPEM, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := LoadClientTLSConfig(tt.certPath, tt.keyPath, tt.caPath)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadClientTLSConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && (len(config.Certificates) != 1 || (tt.caPath != "") != (config.RootCAs != nil)) {
+				t.Errorf("LoadClientTLSConfig() returned unexpected config")
+			}
+		})
+	}
+}
+
+func Test_NewGraphClientWithTLS_scheme(t *testing.T) {
+	_, err := NewGraphClientWithTLS("neo4j://localhost:7687", CreateAuthTokenWithUsernameAndPassword("", "", ""), &tls.Config{})
+	if err == nil || !strings.Contains(err.Error(), "bolt+s://") {
+		t.Errorf("NewGraphClientWithTLS() error = %v, want error about bolt+s://", err)
+	}
+}
@@ -0,0 +1,268 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package assembler
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+	"golang.org/x/time/rate"
+)
+
+func TestNewWriteLimiter(t *testing.T) {
+	tests := []struct {
+		name         string
+		opsPerSecond float64
+		wantNil      bool
+		wantLimit    rate.Limit
+		wantBurst    int
+	}{{
+		name:    "unlimited",
+		wantNil: true,
+	}, {
+		name:         "negative is unlimited",
+		opsPerSecond: -1,
+		wantNil:      true,
+	}, {
+		name:         "fractional rate",
+		opsPerSecond: 0.5,
+		wantLimit:    0.5,
+		wantBurst:    1,
+	}, {
+		name:         "burst of one second",
+		opsPerSecond: 100,
+		wantLimit:    100,
+		wantBurst:    100,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewWriteLimiter(tt.opsPerSecond)
+			if (l == nil) != tt.wantNil {
+				t.Fatalf("NewWriteLimiter() = %v, wantNil %v", l, tt.wantNil)
+			}
+			if l == nil {
+				return
+			}
+			if l.Limit() != tt.wantLimit || l.Burst() != tt.wantBurst {
+				t.Errorf("NewWriteLimiter() limit = %v, burst = %v, want %v, %v", l.Limit(), l.Burst(), tt.wantLimit, tt.wantBurst)
+			}
+		})
+	}
+}
+
+func Test_matchingEdgeWrite(t *testing.T) {
+	art := ArtifactNode{Name: "left-pad.tgz", Digest: "sha256:1234"}
+	e := PackageOfDigestEdge{ArtifactNode: art}
+	w, err := matchingEdgeWrite(e, art, []string{"sha256:d0c"})
+	if err != nil {
+		t.Fatalf("matchingEdgeWrite() error = %v", err)
+	}
+	wantStatement := "MERGE (a:Artifact {digest:row.a.digest})\n" +
+		"WITH a, row\n" +
+		"MATCH (u:Package)\n" +
+		"WHERE any(d IN u.digest WHERE d IN row.m.digests)\n" +
+		"MERGE (a) -[e:PackageOf]-> (u)\n" +
+		"SET e += row.e\n" +
+		"SET e.documents = CASE WHEN size(row.docs) = 0 THEN e.documents ELSE coalesce(e.documents, []) + [d IN row.docs WHERE NOT d IN coalesce(e.documents, [])] END\n"
+	if w.statement != wantStatement {
+		t.Errorf("matchingEdgeWrite() statement = %q, want %q", w.statement, wantStatement)
+	}
+	wantRow := map[string]interface{}{
+		"a":    map[string]interface{}{"digest": "sha256:1234"},
+		"e":    map[string]interface{}{},
+		"m":    map[string]interface{}{"digests": []string{"sha256:1234"}},
+		"docs": []string{"sha256:d0c"},
+	}
+	if !reflect.DeepEqual(w.row, wantRow) {
+		t.Errorf("matchingEdgeWrite() row = %v, want %v", w.row, wantRow)
+	}
+}
+
+func Test_bulkQueries(t *testing.T) {
+	text := PackageNode{Purl: "pkg:golang/golang.org/x/text@v0.3.7"}
+	net := PackageNode{Purl: "pkg:golang/golang.org/x/net@v0.7.0", Version: "v0.7.0"}
+	app := ArtifactNode{Name: "app", Digest: "sha256:1a"}
+	writes, err := graphWrites(Graph{
+		Nodes: []GuacNode{text, app, net},
+		Edges: []GuacEdge{
+			DependsOnEdge{ArtifactNode: app, PackageDependency: text},
+			DependsOnEdge{ArtifactNode: app, PackageDependency: net},
+		},
+	}, &artifactResolver{byDigest: map[string][]int{}, resolved: map[string]ArtifactNode{}})
+	if err != nil {
+		t.Fatalf("graphWrites() error = %v", err)
+	}
+	queries := bulkQueries(writes)
+	nodeDocs := "SET n.documents = CASE WHEN size(row.docs) = 0 THEN n.documents ELSE coalesce(n.documents, []) + [d IN row.docs WHERE NOT d IN coalesce(n.documents, [])] END\n"
+	edgeDocs := strings.ReplaceAll(nodeDocs, "n.", "e.")
+	wantQueries := []string{
+		"UNWIND $rows AS row\nMERGE (n:Package {purl:row.n.purl})\nSET n += row.n\n" + nodeDocs,
+		"UNWIND $rows AS row\nMERGE (n:Artifact {digest:row.n.digest})\nSET n += row.n\n" + nodeDocs,
+		"UNWIND $rows AS row\nMERGE (a:Artifact {digest:row.a.digest})\nMERGE (b:Package {purl:row.b.purl})\n" +
+			"MERGE (a) -[e:DependsOn]-> (b)\nSET e += row.e\n" + edgeDocs,
+	}
+	if len(queries) != len(wantQueries) {
+		t.Fatalf("bulkQueries() returned %d queries, want %d", len(queries), len(wantQueries))
+	}
+	for i, q := range queries {
+		if q.query != wantQueries[i] {
+			t.Errorf("bulkQueries() query %d = %q, want %q", i, q.query, wantQueries[i])
+		}
+	}
+	packages := queries[0].params["rows"].([]interface{})
+	if len(packages) != 2 || packages[1].(map[string]interface{})["n"].(map[string]interface{})["version"] != "v0.7.0" {
+		t.Errorf("bulkQueries() package rows = %v, want x/text then x/net", packages)
+	}
+	if edges := queries[2].params["rows"].([]interface{}); len(edges) != 2 {
+		t.Errorf("bulkQueries() edge rows = %v, want both dependencies", edges)
+	}
+}
+
+func TestWriteOptions_batchSize(t *testing.T) {
+	tests := []struct {
+		name string
+		opts WriteOptions
+		want int
+	}{{
+		name: "default",
+		want: DefaultWriteOptions.BatchSize,
+	}, {
+		name: "configured",
+		opts: WriteOptions{BatchSize: 50},
+		want: 50,
+	}, {
+		name: "lowered to the burst of the limiter",
+		opts: WriteOptions{BatchSize: 50, Limiter: NewWriteLimiter(10)},
+		want: 10,
+	}, {
+		name: "fractional rate",
+		opts: WriteOptions{Limiter: NewWriteLimiter(0.5)},
+		want: 1,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.opts.batchSize(); got != tt.want {
+				t.Errorf("batchSize() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_isDeadlock(t *testing.T) {
+	deadlock := &neo4j.Neo4jError{Code: "Neo.TransientError.Transaction.DeadlockDetected", Msg: "ForsetiClient can't acquire ExclusiveLock"}
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{{
+		name: "deadlock",
+		err:  deadlock,
+		want: true,
+	}, {
+		name: "deadlock after the retries of the driver",
+		err:  &neo4j.TransactionExecutionLimit{Errors: []error{deadlock, deadlock}, Causes: []string{"timeout"}},
+		want: true,
+	}, {
+		name: "wrapped deadlock",
+		err:  fmt.Errorf("write failed: %w", deadlock),
+		want: true,
+	}, {
+		name: "constraint violation",
+		err:  &neo4j.Neo4jError{Code: "Neo.ClientError.Schema.ConstraintValidationFailed"},
+	}, {
+		name: "other error",
+		err:  errors.New("connection reset"),
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isDeadlock(tt.err); got != tt.want {
+				t.Errorf("isDeadlock() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_bulkQueriesCollapsesWrites(t *testing.T) {
+	text := PackageNode{Purl: "pkg:golang/golang.org/x/text@v0.3.7", Name: "text"}
+	textVersion := PackageNode{Purl: "pkg:golang/golang.org/x/text@v0.3.7", Version: "v0.3.7"}
+	app := PackageNode{Purl: "pkg:oci/app"}
+	writes, err := graphWrites(Graph{
+		Nodes: []GuacNode{text, app, textVersion},
+		Edges: []GuacEdge{
+			DependsOnEdge{PackageNode: app, PackageDependency: text, Relationship: "direct"},
+			DependsOnEdge{PackageNode: app, PackageDependency: textVersion, Relationship: "transitive"},
+		},
+	}, &artifactResolver{byDigest: map[string][]int{}, resolved: map[string]ArtifactNode{}})
+	if err != nil {
+		t.Fatalf("graphWrites() error = %v", err)
+	}
+	queries := bulkQueries(writes)
+	if len(queries) != 2 {
+		t.Fatalf("bulkQueries() returned %d queries, want the packages and the dependencies", len(queries))
+	}
+	packages := queries[0].params["rows"].([]interface{})
+	wantText := map[string]interface{}{"purl": "pkg:golang/golang.org/x/text@v0.3.7", "name": "text", "version": "v0.3.7"}
+	if len(packages) != 2 || !reflect.DeepEqual(packages[0].(map[string]interface{})["n"], wantText) {
+		t.Errorf("bulkQueries() package rows = %v, want x/text once with %v", packages, wantText)
+	}
+	deps := queries[1].params["rows"].([]interface{})
+	if len(deps) != 1 || deps[0].(map[string]interface{})["e"].(map[string]interface{})["relationship"] != "transitive" {
+		t.Errorf("bulkQueries() dependency rows = %v, want the last relationship once", deps)
+	}
+	if writes[0].row["n"].(map[string]interface{})["version"] != nil {
+		t.Errorf("bulkQueries() changed the row of a write")
+	}
+}
+
+func Test_graphWritesDocuments(t *testing.T) {
+	text := PackageNode{Purl: "pkg:golang/golang.org/x/text@v0.3.7"}
+	app := PackageNode{Purl: "pkg:oci/app"}
+	sbom := Graph{
+		Nodes: []GuacNode{app, text},
+		Edges: []GuacEdge{DependsOnEdge{PackageNode: app, PackageDependency: text}},
+	}
+	spdx := DocumentNode{Digest: "sha256:5b"}
+	cdx := DocumentNode{Digest: "sha256:c0"}
+	g := Graph{}
+	g.AppendGraph(AssertedBy(sbom, spdx), AssertedBy(Graph{Nodes: []GuacNode{text}}, cdx))
+
+	writes, err := graphWrites(g, &artifactResolver{byDigest: map[string][]int{}, resolved: map[string]ArtifactNode{}})
+	if err != nil {
+		t.Fatalf("graphWrites() error = %v", err)
+	}
+	queries := bulkQueries(writes)
+	if len(queries) != 3 || !strings.Contains(queries[0].query, "MERGE (n:Document {digest:row.n.digest})") {
+		t.Fatalf("bulkQueries() = %v, want the documents, the packages and the dependency", queries)
+	}
+	if docs := queries[0].params["rows"].([]interface{}); len(docs) != 2 {
+		t.Errorf("bulkQueries() document rows = %v, want both documents", docs)
+	}
+	packages := queries[1].params["rows"].([]interface{})
+	wantDocs := [][]string{{"sha256:5b"}, {"sha256:5b", "sha256:c0"}}
+	for i, row := range packages {
+		if got := row.(map[string]interface{})["docs"]; !reflect.DeepEqual(got, wantDocs[i]) {
+			t.Errorf("bulkQueries() documents of package %d = %v, want %v", i, got, wantDocs[i])
+		}
+	}
+	dep := queries[2].params["rows"].([]interface{})[0].(map[string]interface{})
+	if !reflect.DeepEqual(dep["docs"], []string{"sha256:5b"}) {
+		t.Errorf("bulkQueries() documents of the dependency = %v, want the spdx document", dep["docs"])
+	}
+}
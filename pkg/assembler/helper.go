@@ -16,6 +16,8 @@
 package assembler
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"reflect"
 	"strings"
 
@@ -28,13 +30,42 @@ type objectMetadata struct {
 	sourceInfo string
 	// collectorInfo is the collector from which the file that created the node came from
 	collectorInfo string
+	// sourceURI is the URI of the file from which the node was created
+	sourceURI string
+	// collectedAt is the time at which the file was collected, in TimeFormat
+	collectedAt string
 }
 
 // NewObjectMetadata creates a new instance to add metadata to nodes
 func NewObjectMetadata(s processor.SourceInformation) *objectMetadata {
-	return &objectMetadata{
+	o := &objectMetadata{
 		sourceInfo:    s.Source,
 		collectorInfo: s.Collector,
+		sourceURI:     s.URI,
+	}
+	if !s.CollectedAt.IsZero() {
+		o.collectedAt = formatTime(s.CollectedAt)
+	}
+	return o
+}
+
+// SourceProperties returns the source properties that nodes created from a
+// document with the source information s have
+func SourceProperties(s processor.SourceInformation) map[string]interface{} {
+	prop := map[string]interface{}{}
+	NewObjectMetadata(s).addProperties(prop)
+	return prop
+}
+
+// NewDocumentNode returns the node of the document with the blob, collected
+// with the source information s, identified by the sha256 digest of the blob.
+// Its assertions are valid from the time it was collected.
+func NewDocumentNode(blob []byte, s processor.SourceInformation) DocumentNode {
+	sum := sha256.Sum256(blob)
+	return DocumentNode{
+		Digest:    "sha256:" + hex.EncodeToString(sum[:]),
+		ValidFrom: s.CollectedAt,
+		NodeData:  *NewObjectMetadata(s),
 	}
 }
 
@@ -45,10 +76,16 @@ func (o *objectMetadata) addProperties(prop map[string]interface{}) {
 	if len(o.collectorInfo) > 0 {
 		prop["collector"] = o.collectorInfo
 	}
+	if len(o.sourceURI) > 0 {
+		prop["source_uri"] = o.sourceURI
+	}
+	if len(o.collectedAt) > 0 {
+		prop["collected_at"] = o.collectedAt
+	}
 }
 
 func (o *objectMetadata) getProperties() []string {
-	return []string{"source", "collector"}
+	return []string{"source", "collector", "source_uri", "collected_at"}
 }
 
 func isDefined(v interface{}) bool {
@@ -62,3 +99,12 @@ func toLower(v ...string) []string {
 	}
 	return lowerVals
 }
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
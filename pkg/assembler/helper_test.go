@@ -18,6 +18,7 @@ package assembler
 import (
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/guacsec/guac/pkg/handler/processor"
 )
@@ -43,6 +44,24 @@ func TestNewObjectMetadata(t *testing.T) {
 	}
 }
 
+func TestSourceProperties(t *testing.T) {
+	s := processor.SourceInformation{
+		Collector:   "collector",
+		Source:      "source",
+		URI:         "gs://bucket/sbom.json",
+		CollectedAt: time.Date(2023, 2, 1, 10, 30, 0, 0, time.FixedZone("CET", 3600)),
+	}
+	want := map[string]interface{}{
+		"source":       "source",
+		"collector":    "collector",
+		"source_uri":   "gs://bucket/sbom.json",
+		"collected_at": "2023-02-01T09:30:00.000000000Z",
+	}
+	if got := SourceProperties(s); !reflect.DeepEqual(got, want) {
+		t.Errorf("SourceProperties() = %v, want %v", got, want)
+	}
+}
+
 func Test_objectMetadata_addProperties(t *testing.T) {
 	tests := []struct {
 		name string
@@ -128,7 +147,7 @@ func Test_objectMetadata_getProperties(t *testing.T) {
 	}{
 		name: "test",
 		o:    &objectMetadata{},
-		want: []string{"source", "collector"},
+		want: []string{"source", "collector", "source_uri", "collected_at"},
 	}
 	if got := test.o.getProperties(); !reflect.DeepEqual(got, test.want) {
 		t.Errorf("getProperties() = %v, want %v", got, test.want)
@@ -0,0 +1,699 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package assembler
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/guacsec/guac/pkg/logging"
+)
+
+// MemoryGraph is a graph kept in memory, for tests, demos and local analysis
+// without a graph database. Graphs are merged into it as StoreGraph merges
+// them into Neo4j, and it is read as a GraphReader. It is the "memory"
+// Backend, and is safe for concurrent use.
+type MemoryGraph struct {
+	mu     sync.RWMutex
+	nextID int64
+	nodes  map[int64]*memoryNode
+	// byKey indexes the nodes by nodeKey
+	byKey map[string]int64
+	edges map[int64]*memoryEdge
+	// edgeKeys indexes the edges by type and nodes, as there is at most one
+	// edge of a type from a node to another
+	edgeKeys map[string]int64
+	out      map[int64][]int64
+	in       map[int64][]int64
+	indexes  map[string]bool
+}
+
+type memoryNode struct {
+	id    int64
+	key   string
+	label string
+	props map[string]interface{}
+}
+
+type memoryEdge struct {
+	id       int64
+	label    string
+	from, to int64
+	props    map[string]interface{}
+}
+
+// NewMemoryGraph returns an empty MemoryGraph
+func NewMemoryGraph() *MemoryGraph {
+	return &MemoryGraph{
+		nodes:    map[int64]*memoryNode{},
+		byKey:    map[string]int64{},
+		edges:    map[int64]*memoryEdge{},
+		edgeKeys: map[string]int64{},
+		out:      map[int64][]int64{},
+		in:       map[int64][]int64{},
+		indexes:  map[string]bool{},
+	}
+}
+
+// StoreGraph merges g into the graph: a node replaces the properties of the
+// stored node with the same type and identifiable properties, artifacts are
+// merged with the stored artifacts sharing a digest, and an edge replaces the
+// properties of the stored edge of the same type between the same nodes. The
+// graph is left unchanged if g has a node without identifiable properties.
+func (m *MemoryGraph) StoreGraph(ctx context.Context, g Graph) (err error) {
+	start := time.Now()
+	defer func() {
+		observeStore(MemoryBackend, start, err)
+	}()
+	ctx = logging.WithComponent(ctx, logging.ComponentAssembler)
+	g = DeduplicateGraph(g)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	resolver, err := newArtifactResolver(ctx, g, func(digests []string) ([][]string, error) {
+		return m.findArtifacts(digests), nil
+	})
+	if err != nil {
+		return err
+	}
+	// resolve every node first, as resolving depends on the order of the
+	// nodes, and check that they can be stored before changing the graph.
+	// The documents asserting them are looked up by the unresolved nodes.
+	type assertedNode struct {
+		n    GuacNode
+		docs []string
+	}
+	nodes := make([]assertedNode, 0, len(g.Nodes))
+	for _, n := range g.Nodes {
+		docs := g.Evidence[nodeKey(n)]
+		n = resolver.resolve(n)
+		if err := checkIdentifiable(n); err != nil {
+			return err
+		}
+		nodes = append(nodes, assertedNode{n, docs})
+	}
+	type edgeNodes struct {
+		e    GuacEdge
+		a, b GuacNode
+		docs []string
+	}
+	edges := make([]edgeNodes, 0, len(g.Edges))
+	for _, e := range g.Edges {
+		docs := g.Evidence[edgeKey(e)]
+		a, b := e.Nodes()
+		a = resolver.resolve(a)
+		if err := checkIdentifiable(a); err != nil {
+			return err
+		}
+		if _, ok := e.(MatchingEdge); !ok {
+			b = resolver.resolve(b)
+			if err := checkIdentifiable(b); err != nil {
+				return err
+			}
+		}
+		edges = append(edges, edgeNodes{e, a, b, docs})
+	}
+
+	for _, doc := range g.Documents {
+		m.mergeNode(doc)
+	}
+	for _, n := range nodes {
+		addDocuments(m.nodes[m.mergeNode(n.n)].props, n.docs)
+	}
+	for _, e := range edges {
+		a := m.mergeNode(e.a)
+		if _, ok := e.e.(MatchingEdge); ok {
+			for _, u := range m.matchingNodes(e.b) {
+				m.mergeEdge(e.e, a, u, e.docs)
+			}
+			continue
+		}
+		m.mergeEdge(e.e, a, m.mergeNode(e.b), e.docs)
+	}
+	return nil
+}
+
+// RetractDocument removes the document with digest from the graph as
+// RetractDocument does
+func (m *MemoryGraph) RetractDocument(_ context.Context, digest string) (Retraction, error) {
+	digest = strings.ToLower(digest)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	doc, ok := m.byKey[nodeKey(DocumentNode{Digest: digest})]
+	if !ok {
+		return Retraction{}, ErrNodeNotFound
+	}
+	var r Retraction
+	// the endpoints of the deleted edges kept without documents by an
+	// earlier retraction are deleted once unlinked
+	endpoints := map[int64]bool{}
+	for _, e := range m.sortedEdges() {
+		asserted, retracted := removeDocument(e.props, digest)
+		switch {
+		case retracted:
+			m.deleteEdge(e)
+			endpoints[e.from] = true
+			endpoints[e.to] = true
+			r.DeletedEdges++
+		case asserted:
+			r.RetainedEdges++
+		}
+	}
+	for _, n := range m.sortedNodes() {
+		asserted, retracted := removeDocument(n.props, digest)
+		if !retracted && endpoints[n.id] {
+			retracted = unasserted(n.props)
+		}
+		switch {
+		case retracted && len(m.out[n.id]) == 0 && len(m.in[n.id]) == 0:
+			m.deleteNode(n)
+			r.DeletedNodes++
+		case asserted:
+			r.RetainedNodes++
+		}
+	}
+	m.deleteNode(m.nodes[doc])
+	return r, nil
+}
+
+// removeDocument removes digest from the `documents` property of a stored
+// node or edge, returning whether it was asserted by the document and
+// whether it was only asserted by it
+func removeDocument(stored map[string]interface{}, digest string) (bool, bool) {
+	documents, _ := stored["documents"].([]string)
+	if !containsString(documents, digest) {
+		return false, false
+	}
+	remaining := []string{}
+	for _, d := range documents {
+		if d != digest {
+			remaining = append(remaining, d)
+		}
+	}
+	stored["documents"] = remaining
+	return true, len(remaining) == 0
+}
+
+// unasserted returns whether the `documents` property of a stored node or
+// edge was left empty by retracting its documents
+func unasserted(stored map[string]interface{}) bool {
+	documents, ok := stored["documents"].([]string)
+	return ok && len(documents) == 0
+}
+
+// deleteNode deletes the node n along with its edges
+func (m *MemoryGraph) deleteNode(n *memoryNode) {
+	for _, ids := range [][]int64{m.out[n.id], m.in[n.id]} {
+		for _, id := range append([]int64{}, ids...) {
+			if e, ok := m.edges[id]; ok {
+				m.deleteEdge(e)
+			}
+		}
+	}
+	delete(m.nodes, n.id)
+	delete(m.byKey, n.key)
+	delete(m.out, n.id)
+	delete(m.in, n.id)
+}
+
+// deleteEdge deletes the edge e
+func (m *MemoryGraph) deleteEdge(e *memoryEdge) {
+	delete(m.edges, e.id)
+	delete(m.edgeKeys, fmt.Sprintf("%s/%d/%d", e.label, e.from, e.to))
+	m.out[e.from] = removeID(m.out[e.from], e.id)
+	m.in[e.to] = removeID(m.in[e.to], e.id)
+}
+
+// removeID returns ids without id
+func removeID(ids []int64, id int64) []int64 {
+	kept := make([]int64, 0, len(ids))
+	for _, other := range ids {
+		if other != id {
+			kept = append(kept, other)
+		}
+	}
+	return kept
+}
+
+// CreateIndexOn records an index on the attribute of the nodes with label.
+// Nodes are found by scanning them, so indexes do not change the queries.
+func (m *MemoryGraph) CreateIndexOn(nodeLabel string, nodeAttribute string) error {
+	if err := ValidateLabel(nodeLabel); err != nil {
+		return err
+	}
+	if err := ValidateLabel(nodeAttribute); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.indexes[nodeLabel+"."+nodeAttribute] = true
+	return nil
+}
+
+// Ping always succeeds, the graph being in memory
+func (m *MemoryGraph) Ping() error {
+	return nil
+}
+
+// Close does nothing, the graph is released with the MemoryGraph
+func (m *MemoryGraph) Close() error {
+	return nil
+}
+
+func checkIdentifiable(n GuacNode) error {
+	props := n.Properties()
+	for _, key := range n.IdentifiablePropertyNames() {
+		if _, ok := props[key]; !ok {
+			return fmt.Errorf("Node %v has no value for property %v", n, key)
+		}
+	}
+	return nil
+}
+
+// mergeNode stores n and returns its id
+func (m *MemoryGraph) mergeNode(n GuacNode) int64 {
+	key := nodeKey(n)
+	id, ok := m.byKey[key]
+	if !ok {
+		id = m.newID()
+		m.byKey[key] = id
+		m.nodes[id] = &memoryNode{id: id, key: key, label: n.Type(), props: map[string]interface{}{}}
+	}
+	setProperties(m.nodes[id].props, n.Properties())
+	return id
+}
+
+// mergeEdge stores e from the node a to the node b, asserted by the
+// documents with digests docs
+func (m *MemoryGraph) mergeEdge(e GuacEdge, a int64, b int64, docs []string) {
+	key := fmt.Sprintf("%s/%d/%d", e.Type(), a, b)
+	id, ok := m.edgeKeys[key]
+	if !ok {
+		id = m.newID()
+		m.edgeKeys[key] = id
+		m.edges[id] = &memoryEdge{id: id, label: e.Type(), from: a, to: b, props: map[string]interface{}{}}
+		m.out[a] = append(m.out[a], id)
+		m.in[b] = append(m.in[b], id)
+	}
+	setProperties(m.edges[id].props, e.Properties())
+	addDocuments(m.edges[id].props, docs)
+}
+
+func (m *MemoryGraph) newID() int64 {
+	id := m.nextID
+	m.nextID++
+	return id
+}
+
+// setProperties sets the properties of a stored node or edge, a nil value
+// removing the property as in Neo4j
+func setProperties(stored map[string]interface{}, props map[string]interface{}) {
+	for k, v := range propertyValues(props) {
+		if v == nil {
+			delete(stored, k)
+		} else {
+			stored[k] = v
+		}
+	}
+}
+
+// addDocuments adds the digests docs missing from the `documents` property
+// of a stored node or edge, leaving it unset without documents
+func addDocuments(stored map[string]interface{}, docs []string) {
+	if len(docs) == 0 {
+		return
+	}
+	documents, _ := stored["documents"].([]string)
+	merged := append([]string{}, documents...)
+	for _, d := range docs {
+		if !containsString(merged, d) {
+			merged = append(merged, d)
+		}
+	}
+	stored["documents"] = merged
+}
+
+// matchingNodes returns the stored nodes matching the u node of a
+// MatchingEdge: the nodes of its type sharing a value with each of its
+// properties
+func (m *MemoryGraph) matchingNodes(u GuacNode) []int64 {
+	props := propertyValues(u.Properties())
+	ids := []int64{}
+	for _, n := range m.sortedNodes() {
+		if n.label != u.Type() {
+			continue
+		}
+		matches := true
+		for k, v := range props {
+			if !sharesValue(n.props[k], v) {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			ids = append(ids, n.id)
+		}
+	}
+	return ids
+}
+
+// findArtifacts returns the digests of the stored artifacts having any of
+// digests, each starting with the digest identifying the artifact
+func (m *MemoryGraph) findArtifacts(digests []string) [][]string {
+	stored := [][]string{}
+	for _, n := range m.sortedNodes() {
+		if n.label != "Artifact" {
+			continue
+		}
+		found := storedDigests(n.props)
+		for _, d := range found {
+			if containsString(digests, d) {
+				stored = append(stored, found)
+				break
+			}
+		}
+	}
+	return stored
+}
+
+// storedDigests returns the digests of the properties of a stored artifact,
+// starting with the digest identifying it
+func storedDigests(props map[string]interface{}) []string {
+	primary, _ := props["digest"].(string)
+	digests := []string{primary}
+	keys := make([]string, 0, len(props))
+	for k := range props {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if d, ok := props[k].(string); ok && strings.HasPrefix(k, digestPropertyPrefix) && d != primary {
+			digests = append(digests, d)
+		}
+	}
+	return digests
+}
+
+func (m *MemoryGraph) sortedEdges() []*memoryEdge {
+	edges := make([]*memoryEdge, 0, len(m.edges))
+	for _, e := range m.edges {
+		edges = append(edges, e)
+	}
+	sort.Slice(edges, func(i, j int) bool { return edges[i].id < edges[j].id })
+	return edges
+}
+
+func (m *MemoryGraph) sortedNodes() []*memoryNode {
+	nodes := make([]*memoryNode, 0, len(m.nodes))
+	for _, n := range m.nodes {
+		nodes = append(nodes, n)
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].id < nodes[j].id })
+	return nodes
+}
+
+// FindNodes returns the nodes selected by filter, as FindNodes does
+func (m *MemoryGraph) FindNodes(filter NodeFilter) ([]StoredNode, error) {
+	if err := ValidateLabel(filter.Label); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	nodes := []StoredNode{}
+	for _, n := range m.sortedNodes() {
+		if len(nodes) == queryLimit(filter.Limit) {
+			break
+		}
+		if n.label == filter.Label && matchesFilter(n, filter) {
+			nodes = append(nodes, n.stored())
+		}
+	}
+	return nodes, nil
+}
+
+func matchesFilter(n *memoryNode, filter NodeFilter) bool {
+	if filter.Purl != "" && n.props["purl"] != filter.Purl {
+		return false
+	}
+	if filter.Digest != "" {
+		d := strings.ToLower(filter.Digest)
+		switch filter.Label {
+		case "Artifact":
+			name := digestPropertyName(d)
+			if n.props["digest"] != d && (name == "" || n.props[name] != d) {
+				return false
+			}
+		case "Package":
+			if !sharesValue(n.props["digest"], d) {
+				return false
+			}
+		default:
+			if n.props["digest"] != d {
+				return false
+			}
+		}
+	}
+	if filter.PredicateType != "" && n.props["attestation_type"] != filter.PredicateType {
+		return false
+	}
+	if filter.ID != "" && n.props["id"] != filter.ID &&
+		(filter.Label != "Vulnerability" || !sharesValue(n.props["aliases"], filter.ID)) {
+		return false
+	}
+	if filter.Source != "" && n.props["source_uri"] != filter.Source && n.props["source"] != filter.Source {
+		return false
+	}
+	return true
+}
+
+// Neighbors returns the nodes linked to the node with id id, as Neighbors
+// does
+func (m *MemoryGraph) Neighbors(id int64, edge string, dir Direction, label string, limit int) ([]Neighbor, error) {
+	if _, err := neighborsQuery(edge, dir, label); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	edges := m.out[id]
+	if dir == DirectionIn {
+		edges = m.in[id]
+	}
+	neighbors := []Neighbor{}
+	for _, eid := range edges {
+		e := m.edges[eid]
+		other := m.nodes[e.to]
+		if dir == DirectionIn {
+			other = m.nodes[e.from]
+		}
+		if (edge == "" || e.label == edge) && (label == "" || other.label == label) {
+			neighbors = append(neighbors, Neighbor{Edge: e.label, EdgeProperties: readProperties(e.props), Node: other.stored()})
+		}
+	}
+	sort.SliceStable(neighbors, func(i, j int) bool { return neighbors[i].Node.ID < neighbors[j].Node.ID })
+	if len(neighbors) > queryLimit(limit) {
+		neighbors = neighbors[:queryLimit(limit)]
+	}
+	return neighbors, nil
+}
+
+// Paths returns the paths from the node with id from to the node with id to,
+// as Paths does
+func (m *MemoryGraph) Paths(from int64, to int64, edges []string, depth int, limit int) ([]Path, error) {
+	_, depth, err := pathEdges(edges, depth)
+	if err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	paths := []memoryPath{}
+	var walk func(p memoryPath)
+	walk = func(p memoryPath) {
+		last := p.nodes[len(p.nodes)-1]
+		if last == to && len(p.edges) > 0 {
+			paths = append(paths, p)
+		}
+		if len(p.edges) == depth {
+			return
+		}
+		for _, eid := range m.out[last] {
+			e := m.edges[eid]
+			if (len(edges) == 0 || containsString(edges, e.label)) && !p.hasEdge(eid) {
+				walk(p.append(eid, e.to))
+			}
+		}
+	}
+	if _, ok := m.nodes[from]; ok {
+		walk(memoryPath{nodes: []int64{from}})
+	}
+	return m.storedPaths(paths, limit), nil
+}
+
+// RootPaths returns the paths to the node with id id from the root nodes
+// reaching it, as RootPaths does
+func (m *MemoryGraph) RootPaths(id int64, edges []string, depth int, limit int) ([]Path, error) {
+	_, depth, err := pathEdges(edges, depth)
+	if err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	follows := func(eid int64) bool {
+		return len(edges) == 0 || containsString(edges, m.edges[eid].label)
+	}
+	paths := []memoryPath{}
+	// the path is walked backwards from id
+	var walk func(p memoryPath)
+	walk = func(p memoryPath) {
+		first := p.nodes[len(p.nodes)-1]
+		parents := []int64{}
+		root := true
+		for _, eid := range m.in[first] {
+			if follows(eid) {
+				root = false
+				if !p.hasEdge(eid) {
+					parents = append(parents, eid)
+				}
+			}
+		}
+		if root {
+			paths = append(paths, p.reverse())
+			return
+		}
+		if len(p.edges) == depth {
+			return
+		}
+		for _, eid := range parents {
+			walk(p.append(eid, m.edges[eid].from))
+		}
+	}
+	if _, ok := m.nodes[id]; ok {
+		walk(memoryPath{nodes: []int64{id}})
+	}
+	return m.storedPaths(paths, limit), nil
+}
+
+// memoryPath is a path of node and edge ids
+type memoryPath struct {
+	nodes []int64
+	edges []int64
+}
+
+func (p memoryPath) hasEdge(id int64) bool {
+	for _, e := range p.edges {
+		if e == id {
+			return true
+		}
+	}
+	return false
+}
+
+func (p memoryPath) append(edge int64, node int64) memoryPath {
+	return memoryPath{
+		nodes: append(append([]int64{}, p.nodes...), node),
+		edges: append(append([]int64{}, p.edges...), edge),
+	}
+}
+
+func (p memoryPath) reverse() memoryPath {
+	r := memoryPath{nodes: make([]int64, len(p.nodes)), edges: make([]int64, len(p.edges))}
+	for i, n := range p.nodes {
+		r.nodes[len(p.nodes)-1-i] = n
+	}
+	for i, e := range p.edges {
+		r.edges[len(p.edges)-1-i] = e
+	}
+	return r
+}
+
+// storedPaths returns the shortest paths first, at most limit of them
+func (m *MemoryGraph) storedPaths(paths []memoryPath, limit int) []Path {
+	sort.SliceStable(paths, func(i, j int) bool { return len(paths[i].edges) < len(paths[j].edges) })
+	if len(paths) > queryLimit(limit) {
+		paths = paths[:queryLimit(limit)]
+	}
+	stored := make([]Path, 0, len(paths))
+	for _, p := range paths {
+		path := Path{Nodes: make([]StoredNode, 0, len(p.nodes)), Edges: make([]string, 0, len(p.edges))}
+		for _, n := range p.nodes {
+			path.Nodes = append(path.Nodes, m.nodes[n].stored())
+		}
+		for _, e := range p.edges {
+			path.Edges = append(path.Edges, m.edges[e].label)
+		}
+		stored = append(stored, path)
+	}
+	return stored
+}
+
+func (n *memoryNode) stored() StoredNode {
+	return StoredNode{ID: n.id, Label: n.label, Properties: readProperties(n.props)}
+}
+
+// readProperties returns a copy of stored properties, with lists read as
+// []interface{} as from Neo4j
+func readProperties(props map[string]interface{}) map[string]interface{} {
+	read := make(map[string]interface{}, len(props))
+	for k, v := range props {
+		rv := reflect.ValueOf(v)
+		if rv.Kind() != reflect.Slice {
+			read[k] = v
+			continue
+		}
+		list := make([]interface{}, rv.Len())
+		for i := range list {
+			list[i] = rv.Index(i).Interface()
+		}
+		read[k] = list
+	}
+	return read
+}
+
+// sharesValue returns whether the stored property value v and the value
+// matched, each a scalar or a list, have a value in common
+func sharesValue(v interface{}, matched interface{}) bool {
+	values := func(x interface{}) []interface{} {
+		rv := reflect.ValueOf(x)
+		if x == nil {
+			return nil
+		}
+		if rv.Kind() != reflect.Slice {
+			return []interface{}{x}
+		}
+		list := make([]interface{}, rv.Len())
+		for i := range list {
+			list[i] = rv.Index(i).Interface()
+		}
+		return list
+	}
+	for _, a := range values(v) {
+		for _, b := range values(matched) {
+			if a == b {
+				return true
+			}
+		}
+	}
+	return false
+}
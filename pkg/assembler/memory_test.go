@@ -0,0 +1,268 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package assembler
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/guacsec/guac/pkg/handler/processor"
+)
+
+func TestMemoryGraph(t *testing.T) {
+	ctx := context.Background()
+	image := ArtifactNode{Name: "app", Digest: "sha256:1a", AlternateDigests: []string{"sha512:2b"}}
+	app := PackageNode{Purl: "pkg:oci/app", Digest: []string{"sha256:1a"}}
+	text := PackageNode{Purl: "pkg:golang/golang.org/x/text@v0.3.7"}
+	vuln := VulnerabilityNode{ID: "GHSA-69ch-w2m2-3vjp", Aliases: []string{"CVE-2022-32149"}}
+
+	m := NewMemoryGraph()
+	if err := m.StoreGraph(ctx, Graph{
+		Nodes: []GuacNode{app, text, vuln},
+		Edges: []GuacEdge{
+			DependsOnEdge{PackageNode: app, PackageDependency: text, Relationship: "direct"},
+			AffectsEdge{VulnerabilityNode: vuln, PackageNode: text, AnalysisState: "affected"},
+		},
+	}); err != nil {
+		t.Fatalf("StoreGraph() error = %v", err)
+	}
+	// the image is merged into the artifact stored first with its alternate
+	// digest, which identifies it, and linked to the package with its digest
+	if err := m.StoreGraph(ctx, Graph{
+		Nodes: []GuacNode{ArtifactNode{Name: "app:v1", Digest: "sha512:2b"}, image},
+		Edges: []GuacEdge{
+			PackageOfDigestEdge{ArtifactNode: image},
+			DependsOnEdge{PackageNode: app, PackageDependency: text, Relationship: "transitive"},
+		},
+	}); err != nil {
+		t.Fatalf("StoreGraph() error = %v", err)
+	}
+	if err := m.StoreGraph(ctx, Graph{Nodes: []GuacNode{PackageNode{Name: "nameless"}, PackageNode{Purl: "pkg:golang/other@v1"}}}); err == nil {
+		t.Errorf("StoreGraph() of a package without purl succeeded")
+	}
+	if err := m.CreateIndexOn("Package", "purl"); err != nil {
+		t.Errorf("CreateIndexOn() error = %v", err)
+	}
+	if err := m.CreateIndexOn("Package) DETACH DELETE n //", "purl"); err == nil {
+		t.Errorf("CreateIndexOn() of an invalid label succeeded")
+	}
+
+	artifacts, err := m.FindNodes(NodeFilter{Label: "Artifact", Digest: "SHA512:2B"})
+	if err != nil || len(artifacts) != 1 {
+		t.Fatalf("FindNodes() = %v, %v, want the image", artifacts, err)
+	}
+	if got := artifacts[0].Properties["digest"]; got != "sha512:2b" {
+		t.Errorf("image digest = %v, want sha512:2b", got)
+	}
+	if got := artifacts[0].Properties["digest_sha256"]; got != "sha256:1a" {
+		t.Errorf("image digest_sha256 = %v, want sha256:1a", got)
+	}
+	packages, err := m.FindNodes(NodeFilter{Label: "Package"})
+	if err != nil || len(packages) != 2 {
+		t.Fatalf("FindNodes() = %v, %v, want 2 packages", packages, err)
+	}
+	if got := packages[0].Properties["digest"]; !reflect.DeepEqual(got, []interface{}{"sha256:1a"}) {
+		t.Errorf("package digest = %#v, want a list", got)
+	}
+	vulns, err := m.FindNodes(NodeFilter{Label: "Vulnerability", ID: "CVE-2022-32149"})
+	if err != nil || len(vulns) != 1 {
+		t.Fatalf("FindNodes() by alias = %v, %v, want the vulnerability", vulns, err)
+	}
+
+	deps, err := m.Neighbors(packages[0].ID, "DependsOn", DirectionOut, "Package", 0)
+	if err != nil || len(deps) != 1 {
+		t.Fatalf("Neighbors() = %v, %v, want x/text", deps, err)
+	}
+	if got := deps[0].EdgeProperties["relationship"]; got != "transitive" {
+		t.Errorf("DependsOn relationship = %v, want the last one stored", got)
+	}
+
+	paths, err := m.Paths(artifacts[0].ID, packages[1].ID, DependencyEdges, 0, 0)
+	if err != nil || len(paths) != 1 {
+		t.Fatalf("Paths() = %v, %v, want one path", paths, err)
+	}
+	want := "Artifact sha512:2b -PackageOf-> Package pkg:oci/app -DependsOn-> Package pkg:golang/golang.org/x/text@v0.3.7"
+	if got := paths[0].String(); got != want {
+		t.Errorf("Paths() = %s, want %s", got, want)
+	}
+	if paths, _ := m.Paths(artifacts[0].ID, packages[1].ID, DependencyEdges, 1, 0); len(paths) != 0 {
+		t.Errorf("Paths() of depth 1 = %v, want none", paths)
+	}
+
+	radius, err := VulnerabilityBlastRadius(m, "CVE-2022-32149", 0, 0)
+	if err != nil {
+		t.Fatalf("VulnerabilityBlastRadius() error = %v", err)
+	}
+	if len(radius.Products) != 1 || radius.Products[0].Product.ID != artifacts[0].ID {
+		t.Fatalf("VulnerabilityBlastRadius() products = %v, want the image", radius.Products)
+	}
+	if got := radius.Products[0].Chains[0].String(); got != want {
+		t.Errorf("VulnerabilityBlastRadius() chain = %s, want %s", got, want)
+	}
+	if len(radius.Artifacts) != 1 || radius.Artifacts[0].Properties["name"] != "app" {
+		t.Errorf("VulnerabilityBlastRadius() artifacts = %v, want the image", radius.Artifacts)
+	}
+}
+
+func TestMemoryGraph_evidence(t *testing.T) {
+	ctx := context.Background()
+	app := PackageNode{Purl: "pkg:oci/app"}
+	text := PackageNode{Purl: "pkg:golang/golang.org/x/text@v0.3.7"}
+	sbom := Graph{
+		Nodes: []GuacNode{app, text},
+		Edges: []GuacEdge{DependsOnEdge{PackageNode: app, PackageDependency: text}},
+	}
+	spdx := NewDocumentNode([]byte(`{"spdxVersion":"SPDX-2.3"}`), processor.SourceInformation{Collector: "file", Source: "sbom.spdx.json"})
+	cdx := NewDocumentNode([]byte(`{"bomFormat":"CycloneDX"}`), processor.SourceInformation{Collector: "oci", URI: "oci://app"})
+
+	m := NewMemoryGraph()
+	for _, g := range []Graph{AssertedBy(sbom, spdx), AssertedBy(sbom, cdx), AssertedBy(sbom, spdx)} {
+		if err := m.StoreGraph(ctx, g); err != nil {
+			t.Fatalf("StoreGraph() error = %v", err)
+		}
+	}
+	from, err := FindSubject(m, "pkg:oci/app")
+	if err != nil {
+		t.Fatalf("FindSubject() error = %v", err)
+	}
+	if got, want := from.Properties["documents"], []interface{}{spdx.Digest, cdx.Digest}; !reflect.DeepEqual(got, want) {
+		t.Errorf("documents of the package = %v, want %v", got, want)
+	}
+	to, err := FindSubject(m, "pkg:golang/golang.org/x/text@v0.3.7")
+	if err != nil {
+		t.Fatalf("FindSubject() error = %v", err)
+	}
+
+	docs, err := EdgeEvidence(m, from, to, "DependsOn", 0)
+	if err != nil {
+		t.Fatalf("EdgeEvidence() error = %v", err)
+	}
+	if len(docs) != 2 || docs[0].Properties["source"] != "sbom.spdx.json" || docs[1].Properties["source_uri"] != "oci://app" {
+		t.Errorf("EdgeEvidence() = %v, want the spdx and the cyclonedx documents", docs)
+	}
+	if docs, err := EdgeEvidence(m, from, to, "DependsOn", 1); err != nil || len(docs) != 1 {
+		t.Errorf("EdgeEvidence() with limit 1 = %v, %v, want the spdx document", docs, err)
+	}
+	if _, err := EdgeEvidence(m, to, from, "DependsOn", 0); !errors.Is(err, ErrEdgeNotFound) {
+		t.Errorf("EdgeEvidence() of a missing edge error = %v, want ErrEdgeNotFound", err)
+	}
+}
+
+func TestMemoryGraph_RetractDocument(t *testing.T) {
+	ctx := context.Background()
+	app := PackageNode{Purl: "pkg:oci/app"}
+	text := PackageNode{Purl: "pkg:golang/golang.org/x/text@v0.3.7"}
+	net := PackageNode{Purl: "pkg:golang/golang.org/x/net@v0.7.0"}
+	spdx := NewDocumentNode([]byte(`{"spdxVersion":"SPDX-2.3"}`), processor.SourceInformation{Collector: "file", Source: "sbom.spdx.json"})
+	cdx := NewDocumentNode([]byte(`{"bomFormat":"CycloneDX"}`), processor.SourceInformation{Collector: "oci", URI: "oci://app"})
+
+	m := NewMemoryGraph()
+	for _, g := range []Graph{AssertedBy(Graph{
+		Nodes: []GuacNode{app, text, net},
+		Edges: []GuacEdge{
+			DependsOnEdge{PackageNode: app, PackageDependency: text},
+			DependsOnEdge{PackageNode: app, PackageDependency: net},
+		},
+	}, spdx), AssertedBy(Graph{
+		Nodes: []GuacNode{app, text},
+		Edges: []GuacEdge{DependsOnEdge{PackageNode: app, PackageDependency: text}},
+	}, cdx)} {
+		if err := m.StoreGraph(ctx, g); err != nil {
+			t.Fatalf("StoreGraph() error = %v", err)
+		}
+	}
+
+	got, err := m.RetractDocument(ctx, strings.ToUpper(spdx.Digest))
+	if err != nil {
+		t.Fatalf("RetractDocument() error = %v", err)
+	}
+	if want := (Retraction{DeletedNodes: 1, DeletedEdges: 1, RetainedNodes: 2, RetainedEdges: 1}); got != want {
+		t.Errorf("RetractDocument() = %v, want %v", got, want)
+	}
+	if _, err := FindSubject(m, "pkg:golang/golang.org/x/net@v0.7.0"); err == nil {
+		t.Errorf("x/net was only asserted by the retracted document but is still stored")
+	}
+	from, err := FindSubject(m, "pkg:oci/app")
+	if err != nil {
+		t.Fatalf("FindSubject() error = %v", err)
+	}
+	if got, want := from.Properties["documents"], []interface{}{cdx.Digest}; !reflect.DeepEqual(got, want) {
+		t.Errorf("documents of the package = %v, want %v", got, want)
+	}
+	to, err := FindSubject(m, "pkg:golang/golang.org/x/text@v0.3.7")
+	if err != nil {
+		t.Fatalf("FindSubject() error = %v", err)
+	}
+	if docs, err := EdgeEvidence(m, from, to, "DependsOn", 0); err != nil || len(docs) != 1 || docs[0].Properties["source_uri"] != "oci://app" {
+		t.Errorf("EdgeEvidence() = %v, %v, want the cyclonedx document", docs, err)
+	}
+	if documents, err := m.FindNodes(NodeFilter{Label: "Document"}); err != nil || len(documents) != 1 {
+		t.Errorf("FindNodes() of documents = %v, %v, want the cyclonedx document", documents, err)
+	}
+	if _, err := m.RetractDocument(ctx, spdx.Digest); !errors.Is(err, ErrNodeNotFound) {
+		t.Errorf("RetractDocument() of a retracted document error = %v, want ErrNodeNotFound", err)
+	}
+}
+
+func TestMemoryGraph_RetractDocument_unlinkedNode(t *testing.T) {
+	ctx := context.Background()
+	app := PackageNode{Purl: "pkg:oci/app"}
+	net := PackageNode{Purl: "pkg:golang/golang.org/x/net@v0.7.0"}
+	a := NewDocumentNode([]byte(`{"spdxVersion":"SPDX-2.3"}`), processor.SourceInformation{Collector: "file", Source: "net.spdx.json"})
+	b := NewDocumentNode([]byte(`{"bomFormat":"CycloneDX"}`), processor.SourceInformation{Collector: "file", Source: "app.cdx.json"})
+
+	// x/net is only asserted by a, and only linked by the edge asserted by b
+	m := NewMemoryGraph()
+	for _, g := range []Graph{
+		AssertedBy(Graph{Nodes: []GuacNode{net}}, a),
+		AssertedBy(Graph{
+			Nodes: []GuacNode{app},
+			Edges: []GuacEdge{DependsOnEdge{PackageNode: app, PackageDependency: net}},
+		}, b),
+	} {
+		if err := m.StoreGraph(ctx, g); err != nil {
+			t.Fatalf("StoreGraph() error = %v", err)
+		}
+	}
+
+	got, err := m.RetractDocument(ctx, a.Digest)
+	if err != nil {
+		t.Fatalf("RetractDocument() error = %v", err)
+	}
+	if want := (Retraction{RetainedNodes: 1}); got != want {
+		t.Errorf("RetractDocument() of a = %v, want %v", got, want)
+	}
+	if _, err := FindSubject(m, net.Purl); err != nil {
+		t.Errorf("x/net is still linked by the edge of b but was deleted: %v", err)
+	}
+
+	got, err = m.RetractDocument(ctx, b.Digest)
+	if err != nil {
+		t.Fatalf("RetractDocument() error = %v", err)
+	}
+	if want := (Retraction{DeletedNodes: 2, DeletedEdges: 1}); got != want {
+		t.Errorf("RetractDocument() of b = %v, want %v", got, want)
+	}
+	if _, err := FindSubject(m, net.Purl); err == nil {
+		t.Errorf("x/net is no longer asserted nor linked but is still stored")
+	}
+	if packages, err := m.FindNodes(NodeFilter{Label: "Package"}); err != nil || len(packages) != 0 {
+		t.Errorf("FindNodes() of packages = %v, %v, want none", packages, err)
+	}
+}
@@ -15,31 +15,49 @@
 
 package assembler
 
-import "strings"
+import (
+	"strings"
+	"time"
+)
 
 // ArtifactNode is a node that represents an artifact
 type ArtifactNode struct {
-	Name     string
-	Digest   string
-	Tags     []string
-	NodeData objectMetadata
+	Name   string
+	Digest string
+	// AlternateDigests are digests of the same artifact computed with other
+	// algorithms than Digest. Artifacts sharing any digest are merged.
+	AlternateDigests []string
+	Tags             []string
+	NodeData         objectMetadata
 }
 
 func (an ArtifactNode) Type() string {
 	return "Artifact"
 }
 
+// Properties also contains each digest under its per algorithm property
+// (e.g. `digest_sha512`), which is used to find the artifact by any of them
 func (an ArtifactNode) Properties() map[string]interface{} {
 	properties := make(map[string]interface{})
 	properties["name"] = an.Name
 	properties["digest"] = strings.ToLower(an.Digest)
 	properties["tags"] = an.Tags
+	for _, d := range an.Digests() {
+		if name := digestPropertyName(d); name != "" {
+			properties[name] = d
+		}
+	}
 	an.NodeData.addProperties(properties)
 	return properties
 }
 
 func (an ArtifactNode) PropertyNames() []string {
 	fields := []string{"name", "digest", "tags"}
+	for _, d := range an.Digests() {
+		if name := digestPropertyName(d); name != "" {
+			fields = append(fields, name)
+		}
+	}
 	fields = append(fields, an.NodeData.getProperties()...)
 	return fields
 }
@@ -51,13 +69,16 @@ func (an ArtifactNode) IdentifiablePropertyNames() []string {
 
 // PackageNode is a node that represents an artifact
 type PackageNode struct {
-	Name     string
-	Digest   []string
-	Version  string
-	Purl     string
-	CPEs     []string
-	Tags     []string
-	NodeData objectMetadata
+	Name    string
+	Digest  []string
+	Version string
+	Purl    string
+	CPEs    []string
+	Tags    []string
+	// KeySource is how the purl was derived when the document did not give
+	// one, empty if it did
+	KeySource string
+	NodeData  objectMetadata
 }
 
 func (pn PackageNode) Type() string {
@@ -84,12 +105,15 @@ func (pn PackageNode) Properties() map[string]interface{} {
 	if len(pn.Tags) > 0 {
 		properties["tags"] = pn.Tags
 	}
+	if len(pn.KeySource) > 0 {
+		properties["key_source"] = pn.KeySource
+	}
 	pn.NodeData.addProperties(properties)
 	return properties
 }
 
 func (pn PackageNode) PropertyNames() []string {
-	fields := []string{"name", "digest", "purl", "cpes", "tags", "version"}
+	fields := []string{"name", "digest", "purl", "cpes", "tags", "version", "key_source"}
 	fields = append(fields, pn.NodeData.getProperties()...)
 	return fields
 }
@@ -98,15 +122,19 @@ func (pn PackageNode) IdentifiablePropertyNames() []string {
 	return []string{"purl"}
 }
 
-// IdentityNode is a node that represents an identity
+// IdentityNode is a node that represents an identity. Issuer and
+// SubjectAlternativeName are the OIDC issuer and the identity of the signer
+// certified by the Fulcio certificate of a keyless signature.
 type IdentityNode struct {
 	ID     string
 	Digest string
 	// base64 encoded
-	Key       string
-	KeyType   string
-	KeyScheme string
-	NodeData  objectMetadata
+	Key                    string
+	KeyType                string
+	KeyScheme              string
+	Issuer                 string
+	SubjectAlternativeName string
+	NodeData               objectMetadata
 }
 
 func (in IdentityNode) Type() string {
@@ -120,12 +148,14 @@ func (in IdentityNode) Properties() map[string]interface{} {
 	properties["key"] = in.Key
 	properties["keyType"] = in.KeyType
 	properties["keyScheme"] = in.KeyScheme
+	properties["issuer"] = in.Issuer
+	properties["subjectAlternativeName"] = in.SubjectAlternativeName
 	in.NodeData.addProperties(properties)
 	return properties
 }
 
 func (in IdentityNode) PropertyNames() []string {
-	fields := []string{"id", "digest", "key", "keyType", "keyScheme"}
+	fields := []string{"id", "digest", "key", "keyType", "keyScheme", "issuer", "subjectAlternativeName"}
 	fields = append(fields, in.NodeData.getProperties()...)
 	return fields
 }
@@ -135,14 +165,19 @@ func (in IdentityNode) IdentifiablePropertyNames() []string {
 	return []string{"digest"}
 }
 
-// AttestationNode is a node that represents an attestation
+// AttestationNode is a node that represents an attestation. Its
+// `collected_at` source property and `statement_time` are stored in
+// TimeFormat, so that the attestations of a subject can be ordered by time.
 type AttestationNode struct {
 	// TODO(mihaimaruseac): Unsure what fields to store here
 	FilePath        string
 	Digest          string
 	AttestationType string
-	Payload         map[string]interface{}
-	NodeData        objectMetadata
+	// StatementTime is the time given by the statement itself, e.g. when
+	// the build or the scan it attests finished, zero if it has none
+	StatementTime time.Time
+	Payload       map[string]interface{}
+	NodeData      objectMetadata
 }
 
 func (an AttestationNode) Type() string {
@@ -154,6 +189,9 @@ func (an AttestationNode) Properties() map[string]interface{} {
 	properties["filepath"] = an.FilePath
 	properties["digest"] = strings.ToLower(an.Digest)
 	properties["attestation_type"] = an.AttestationType
+	if !an.StatementTime.IsZero() {
+		properties["statement_time"] = formatTime(an.StatementTime)
+	}
 	for k, v := range an.Payload {
 		properties[k] = v
 	}
@@ -163,7 +201,7 @@ func (an AttestationNode) Properties() map[string]interface{} {
 }
 
 func (an AttestationNode) PropertyNames() []string {
-	fields := []string{"filepath", "digest", "attestation_type"}
+	fields := []string{"filepath", "digest", "attestation_type", "statement_time"}
 	for k := range an.Payload {
 		fields = append(fields, k)
 	}
@@ -175,11 +213,12 @@ func (an AttestationNode) IdentifiablePropertyNames() []string {
 	return []string{"digest"}
 }
 
-// BuilderNode is a node that represents a builder for an artifact
+// BuilderNode is a node that represents a builder for an artifact, identified
+// by the builder id of the provenance. The type and parameters of each build
+// are on its `BuiltByEdge`, as a builder runs many kinds of builds.
 type BuilderNode struct {
-	BuilderType string
-	BuilderId   string
-	NodeData    objectMetadata
+	BuilderId string
+	NodeData  objectMetadata
 }
 
 func (bn BuilderNode) Type() string {
@@ -188,21 +227,19 @@ func (bn BuilderNode) Type() string {
 
 func (bn BuilderNode) Properties() map[string]interface{} {
 	properties := make(map[string]interface{})
-	properties["type"] = bn.BuilderType
 	properties["id"] = bn.BuilderId
 	bn.NodeData.addProperties(properties)
 	return properties
 }
 
 func (bn BuilderNode) PropertyNames() []string {
-	fields := []string{"type", "id"}
+	fields := []string{"id"}
 	fields = append(fields, bn.NodeData.getProperties()...)
 	return fields
 }
 
 func (bn BuilderNode) IdentifiablePropertyNames() []string {
-	// A builder needs both type and id to be identified
-	return []string{"type", "id"}
+	return []string{"id"}
 }
 
 // MetadataNode is a node that represents metadata about an artifact/package
@@ -241,9 +278,12 @@ func (mn MetadataNode) IdentifiablePropertyNames() []string {
 	return []string{"metadata_type", "id"}
 }
 
-// VulnerabilityNode is a node that represents a vulnerability associated with the certifier attestation
+// VulnerabilityNode is a node that represents a vulnerability associated with the certifier attestation.
+// Aliases are the other identifiers of the vulnerability, e.g. the CVE and
+// GHSA ids of an OSV entry.
 type VulnerabilityNode struct {
 	ID       string
+	Aliases  []string
 	NodeData objectMetadata
 }
 
@@ -254,12 +294,15 @@ func (vn VulnerabilityNode) Type() string {
 func (vn VulnerabilityNode) Properties() map[string]interface{} {
 	properties := make(map[string]interface{})
 	properties["id"] = vn.ID
+	if len(vn.Aliases) > 0 {
+		properties["aliases"] = vn.Aliases
+	}
 	vn.NodeData.addProperties(properties)
 	return properties
 }
 
 func (vn VulnerabilityNode) PropertyNames() []string {
-	fields := []string{"id"}
+	fields := []string{"id", "aliases"}
 	fields = append(fields, vn.NodeData.getProperties()...)
 	return fields
 }
@@ -269,6 +312,114 @@ func (vn VulnerabilityNode) IdentifiablePropertyNames() []string {
 	return []string{"id"}
 }
 
+// LicenseNode is a node that represents a license, keyed by its SPDX license
+// identifier (including custom `LicenseRef-` identifiers). License text that
+// is not a valid SPDX license expression (e.g. `NOASSERTION`) is stored as is
+// in a single node with Raw set.
+type LicenseNode struct {
+	ID       string
+	Raw      bool
+	NodeData objectMetadata
+}
+
+func (ln LicenseNode) Type() string {
+	return "License"
+}
+
+func (ln LicenseNode) Properties() map[string]interface{} {
+	properties := make(map[string]interface{})
+	properties["id"] = ln.ID
+	properties["raw"] = ln.Raw
+	ln.NodeData.addProperties(properties)
+	return properties
+}
+
+func (ln LicenseNode) PropertyNames() []string {
+	fields := []string{"id", "raw"}
+	fields = append(fields, ln.NodeData.getProperties()...)
+	return fields
+}
+
+func (ln LicenseNode) IdentifiablePropertyNames() []string {
+	return []string{"id"}
+}
+
+// ExternalReferenceNode is a node that represents a resource an SBOM refers
+// to for a package, such as its source repository, website or a security
+// advisory, keyed by its URL. The kind of reference is kept on the
+// `ReferencesEdge`, as the same URL can be referenced in different ways.
+type ExternalReferenceNode struct {
+	URL      string
+	NodeData objectMetadata
+}
+
+func (rn ExternalReferenceNode) Type() string {
+	return "ExternalReference"
+}
+
+func (rn ExternalReferenceNode) Properties() map[string]interface{} {
+	properties := make(map[string]interface{})
+	properties["url"] = rn.URL
+	rn.NodeData.addProperties(properties)
+	return properties
+}
+
+func (rn ExternalReferenceNode) PropertyNames() []string {
+	fields := []string{"url"}
+	fields = append(fields, rn.NodeData.getProperties()...)
+	return fields
+}
+
+func (rn ExternalReferenceNode) IdentifiablePropertyNames() []string {
+	return []string{"url"}
+}
+
+// DocumentNode is a node that represents a document ingested into the graph,
+// such as an SBOM or an attestation, identified by the digest of its blob.
+// Its source properties record the collector, the source and the URI it came
+// from and when it was collected. The nodes and edges it asserts list its
+// digest in their `documents` property, see AssertedBy.
+//
+// The assertions of a document are valid from its `valid_from` time, stored
+// in TimeFormat, until the earliest time of the documents superseding it, see
+// SupersedesEdge and Supersede.
+type DocumentNode struct {
+	Digest string
+	// DocumentType is the type of the document, e.g. `SPDX`
+	DocumentType string
+	// ValidFrom is the time the assertions of the document are valid from,
+	// zero if unknown
+	ValidFrom time.Time
+	NodeData  objectMetadata
+}
+
+func (dn DocumentNode) Type() string {
+	return "Document"
+}
+
+func (dn DocumentNode) Properties() map[string]interface{} {
+	properties := make(map[string]interface{})
+	properties["digest"] = strings.ToLower(dn.Digest)
+	if len(dn.DocumentType) > 0 {
+		properties["document_type"] = dn.DocumentType
+	}
+	if !dn.ValidFrom.IsZero() {
+		properties["valid_from"] = formatTime(dn.ValidFrom)
+	}
+	dn.NodeData.addProperties(properties)
+	return properties
+}
+
+func (dn DocumentNode) PropertyNames() []string {
+	fields := []string{"digest", "document_type", "valid_from"}
+	fields = append(fields, dn.NodeData.getProperties()...)
+	return fields
+}
+
+func (dn DocumentNode) IdentifiablePropertyNames() []string {
+	return []string{"digest"}
+}
+
 // IdentityForEdge is an edge that represents the fact that an
 // `IdentityNode` is an identity for an `AttestationNode`.
 type IdentityForEdge struct {
@@ -338,10 +489,16 @@ func (e AttestationForEdge) IdentifiablePropertyNames() []string {
 }
 
 // BuiltByEdge is an edge that represents the fact that an
-// `ArtifactNode` has been built by a `BuilderNode`
+// `ArtifactNode` has been built by a `BuilderNode`, or that the build
+// described by an `AttestationNode` ran on it. Only one of the artifact and
+// attestation should be defined. BuildType and Parameters, the JSON encoded
+// invocation parameters, describe the build of the attestation.
 type BuiltByEdge struct {
-	ArtifactNode ArtifactNode
-	BuilderNode  BuilderNode
+	ArtifactNode    ArtifactNode
+	AttestationNode AttestationNode
+	BuilderNode     BuilderNode
+	BuildType       string
+	Parameters      string
 }
 
 func (e BuiltByEdge) Type() string {
@@ -349,21 +506,97 @@ func (e BuiltByEdge) Type() string {
 }
 
 func (e BuiltByEdge) Nodes() (v, u GuacNode) {
-	return e.ArtifactNode, e.BuilderNode
+	vA, vT := isDefined(e.ArtifactNode), isDefined(e.AttestationNode)
+	if vA == vT {
+		panic("only one of artifact and attestation node defined for BuiltBy relationship")
+	}
+
+	if vA {
+		v = e.ArtifactNode
+	} else {
+		v = e.AttestationNode
+	}
+	return v, e.BuilderNode
 }
 
 func (e BuiltByEdge) Properties() map[string]interface{} {
-	return map[string]interface{}{}
+	properties := make(map[string]interface{})
+	if e.BuildType != "" {
+		properties["build_type"] = e.BuildType
+	}
+	if e.Parameters != "" {
+		properties["parameters"] = e.Parameters
+	}
+	return properties
 }
 
 func (e BuiltByEdge) PropertyNames() []string {
-	return []string{}
+	return []string{"build_type", "parameters"}
 }
 
 func (e BuiltByEdge) IdentifiablePropertyNames() []string {
 	return []string{}
 }
 
+// PackageOfEdge is an edge that represents the fact that an `ArtifactNode`
+// is the artifact of a `PackageNode`, e.g. the subject of a build
+// provenance and the package it was published as.
+type PackageOfEdge struct {
+	ArtifactNode ArtifactNode
+	PackageNode  PackageNode
+}
+
+func (e PackageOfEdge) Type() string {
+	return "PackageOf"
+}
+
+func (e PackageOfEdge) Nodes() (v, u GuacNode) {
+	return e.ArtifactNode, e.PackageNode
+}
+
+func (e PackageOfEdge) Properties() map[string]interface{} {
+	return map[string]interface{}{}
+}
+
+func (e PackageOfEdge) PropertyNames() []string {
+	return []string{}
+}
+
+func (e PackageOfEdge) IdentifiablePropertyNames() []string {
+	return []string{}
+}
+
+// PackageOfDigestEdge is a PackageOfEdge linking the `ArtifactNode` to the
+// stored packages having any of its digests, whatever their purl. It is a
+// MatchingEdge: no package is created for it.
+type PackageOfDigestEdge struct {
+	ArtifactNode ArtifactNode
+}
+
+func (e PackageOfDigestEdge) Type() string {
+	return "PackageOf"
+}
+
+func (e PackageOfDigestEdge) Nodes() (v, u GuacNode) {
+	return e.ArtifactNode, PackageNode{Digest: e.ArtifactNode.Digests()}
+}
+
+func (e PackageOfDigestEdge) Properties() map[string]interface{} {
+	return map[string]interface{}{}
+}
+
+func (e PackageOfDigestEdge) PropertyNames() []string {
+	return []string{}
+}
+
+func (e PackageOfDigestEdge) IdentifiablePropertyNames() []string {
+	return []string{}
+}
+
+func (e PackageOfDigestEdge) Match() (string, map[string]interface{}) {
+	return "any(d IN u.digest WHERE d IN $digests)", map[string]interface{}{"digests": e.ArtifactNode.Digests()}
+}
+
 // DependsOnEdge is an edge that represents the fact that an
 // `ArtifactNode/PackageNode` depends on another `ArtifactNode/PackageNode`
 // Only one of each side of the edge should be defined.
@@ -372,6 +605,12 @@ type DependsOnEdge struct {
 	PackageNode        PackageNode
 	ArtifactDependency ArtifactNode
 	PackageDependency  PackageNode
+	// Manifest is the path of the manifest file the dependency was
+	// resolved from, if known
+	Manifest string
+	// Relationship is whether the dependency is direct or indirect, if
+	// known
+	Relationship string
 }
 
 func (e DependsOnEdge) Type() string {
@@ -405,17 +644,54 @@ func (e DependsOnEdge) Nodes() (v, u GuacNode) {
 }
 
 func (e DependsOnEdge) Properties() map[string]interface{} {
-	return map[string]interface{}{}
+	properties := make(map[string]interface{})
+	if e.Manifest != "" {
+		properties["manifest"] = e.Manifest
+	}
+	if e.Relationship != "" {
+		properties["relationship"] = e.Relationship
+	}
+	return properties
 }
 
 func (e DependsOnEdge) PropertyNames() []string {
-	return []string{}
+	return []string{"manifest", "relationship"}
 }
 
 func (e DependsOnEdge) IdentifiablePropertyNames() []string {
 	return []string{}
 }
 
+// MaterialEdge is an edge from the `ArtifactNode` subject of an attestation to
+// one of its materials, labeled as configured for the attestation predicate
+// type. Label must be a valid label (see ValidateLabel), as it is used as is
+// in queries.
+type MaterialEdge struct {
+	ArtifactNode     ArtifactNode
+	MaterialArtifact ArtifactNode
+	Label            string
+}
+
+func (e MaterialEdge) Type() string {
+	return e.Label
+}
+
+func (e MaterialEdge) Nodes() (v, u GuacNode) {
+	return e.ArtifactNode, e.MaterialArtifact
+}
+
+func (e MaterialEdge) Properties() map[string]interface{} {
+	return map[string]interface{}{}
+}
+
+func (e MaterialEdge) PropertyNames() []string {
+	return []string{}
+}
+
+func (e MaterialEdge) IdentifiablePropertyNames() []string {
+	return []string{}
+}
+
 // Contains is an edge that represents the fact that an
 // `PackageNode` contains a `ArtifactNode`
 type ContainsEdge struct {
@@ -513,3 +789,176 @@ func (e VulnerableEdge) PropertyNames() []string {
 func (e VulnerableEdge) IdentifiablePropertyNames() []string {
 	return []string{}
 }
+
+// AffectsEdge is an edge that represents the fact that a
+// `VulnerabilityNode` affects a `PackageNode/ArtifactNode`, as asserted by a
+// VEX statement. Only one of the package and artifact should be defined.
+// The analysis state (the VEX status, e.g. `not_affected`) and score of the
+// vulnerability are kept on the edge, as well as the version ranges of the
+// package that are affected, e.g. `SEMVER >=1.0.0 <1.2.3`, for an advisory
+// about all versions of a package.
+type AffectsEdge struct {
+	VulnerabilityNode VulnerabilityNode
+	PackageNode       PackageNode
+	ArtifactNode      ArtifactNode
+	AnalysisState     string
+	Justification     string
+	Score             float64
+	ScoreMethod       string
+	AffectedRanges    []string
+}
+
+func (e AffectsEdge) Type() string {
+	return "Affects"
+}
+
+func (e AffectsEdge) Nodes() (v, u GuacNode) {
+	uA, uP := isDefined(e.ArtifactNode), isDefined(e.PackageNode)
+	if uA == uP {
+		panic("only one of package or artifact node must be defined for Affects relationship")
+	}
+	if uA {
+		return e.VulnerabilityNode, e.ArtifactNode
+	}
+	return e.VulnerabilityNode, e.PackageNode
+}
+
+func (e AffectsEdge) Properties() map[string]interface{} {
+	properties := make(map[string]interface{})
+	if len(e.AnalysisState) > 0 {
+		properties["analysis_state"] = e.AnalysisState
+	}
+	if len(e.Justification) > 0 {
+		properties["justification"] = e.Justification
+	}
+	if len(e.ScoreMethod) > 0 {
+		properties["score"] = e.Score
+		properties["score_method"] = e.ScoreMethod
+	}
+	if len(e.AffectedRanges) > 0 {
+		properties["affected_ranges"] = e.AffectedRanges
+	}
+	return properties
+}
+
+func (e AffectsEdge) PropertyNames() []string {
+	return []string{"analysis_state", "justification", "score", "score_method", "affected_ranges"}
+}
+
+func (e AffectsEdge) IdentifiablePropertyNames() []string {
+	return []string{}
+}
+
+// HasLicenseEdge is an edge that represents the fact that a `PackageNode` is
+// licensed under a `LicenseNode`. The license expression it was taken from is
+// kept on the edge, along with the AND groups of the expression (in
+// disjunctive normal form) that contain the license: a package with
+// `(MIT OR Apache-2.0) AND BSD-3-Clause` has the alternatives
+// `MIT AND BSD-3-Clause` and `Apache-2.0 AND BSD-3-Clause`.
+type HasLicenseEdge struct {
+	PackageNode  PackageNode
+	LicenseNode  LicenseNode
+	Expression   string
+	Alternatives []string
+	Exception    string
+}
+
+func (e HasLicenseEdge) Type() string {
+	return "HasLicense"
+}
+
+func (e HasLicenseEdge) Nodes() (v, u GuacNode) {
+	return e.PackageNode, e.LicenseNode
+}
+
+func (e HasLicenseEdge) Properties() map[string]interface{} {
+	properties := make(map[string]interface{})
+	properties["expression"] = e.Expression
+	if len(e.Alternatives) > 0 {
+		properties["alternatives"] = e.Alternatives
+	}
+	if len(e.Exception) > 0 {
+		properties["exception"] = e.Exception
+	}
+	return properties
+}
+
+func (e HasLicenseEdge) PropertyNames() []string {
+	return []string{"expression", "alternatives", "exception"}
+}
+
+func (e HasLicenseEdge) IdentifiablePropertyNames() []string {
+	return []string{}
+}
+
+// ReferencesEdge is an edge that represents the fact that a
+// `PackageNode/VulnerabilityNode` refers to an `ExternalReferenceNode`, with
+// the type of reference as given by the SBOM (e.g. `vcs`, `website` or
+// `advisories`). Only one of the package and vulnerability should be defined.
+type ReferencesEdge struct {
+	PackageNode           PackageNode
+	VulnerabilityNode     VulnerabilityNode
+	ExternalReferenceNode ExternalReferenceNode
+	ReferenceType         string
+}
+
+func (e ReferencesEdge) Type() string {
+	return "References"
+}
+
+func (e ReferencesEdge) Nodes() (v, u GuacNode) {
+	vP, vV := isDefined(e.PackageNode), isDefined(e.VulnerabilityNode)
+	if vP == vV {
+		panic("only one of package and vulnerability node defined for References relationship")
+	}
+
+	if vP {
+		v = e.PackageNode
+	} else {
+		v = e.VulnerabilityNode
+	}
+	return v, e.ExternalReferenceNode
+}
+
+func (e ReferencesEdge) Properties() map[string]interface{} {
+	properties := make(map[string]interface{})
+	properties["reference_type"] = e.ReferenceType
+	return properties
+}
+
+func (e ReferencesEdge) PropertyNames() []string {
+	return []string{"reference_type"}
+}
+
+func (e ReferencesEdge) IdentifiablePropertyNames() []string {
+	return []string{}
+}
+
+// SupersedesEdge is an edge that represents the fact that a `DocumentNode`
+// supersedes an earlier document of the same source, e.g. a re-scan of an
+// image: the assertions of the superseded document are no longer valid from
+// the time the other one is valid.
+type SupersedesEdge struct {
+	DocumentNode   DocumentNode
+	SupersededNode DocumentNode
+}
+
+func (e SupersedesEdge) Type() string {
+	return "Supersedes"
+}
+
+func (e SupersedesEdge) Nodes() (v, u GuacNode) {
+	return e.DocumentNode, e.SupersededNode
+}
+
+func (e SupersedesEdge) Properties() map[string]interface{} {
+	return map[string]interface{}{}
+}
+
+func (e SupersedesEdge) PropertyNames() []string {
+	return []string{}
+}
+
+func (e SupersedesEdge) IdentifiablePropertyNames() []string {
+	return []string{}
+}
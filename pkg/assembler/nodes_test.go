@@ -170,7 +170,7 @@ func Test_MockNodes(t *testing.T) {
 	edge_id := 0
 	e1 := MockEdge{n1, n2, &edge_id}
 	e2 := MockEdge{n2, n3, nil}
-	graph := Graph{[]GuacNode{n1, n2, n3}, []GuacEdge{e1, e2}}
+	graph := Graph{Nodes: []GuacNode{n1, n2, n3}, Edges: []GuacEdge{e1, e2}}
 
 	err = StoreGraph(graph, client)
 	if err != nil {
@@ -181,6 +181,54 @@ func Test_MockNodes(t *testing.T) {
 
 }
 
+func Test_PackageOfDigestEdge(t *testing.T) {
+	client, err := graphdb.EmptyClientForTesting(dbUri)
+	if err != nil {
+		t.Fatalf("Could not obtain testing database: %v", err)
+	}
+	defer client.Close()
+
+	// the package is stored first, e.g. from an SBOM
+	pkg := PackageNode{
+		Purl:   "pkg:npm/left-pad@1.3.0",
+		Digest: []string{"sha256:1234", "sha512:abcd"},
+	}
+	if err := StoreGraph(Graph{Nodes: []GuacNode{pkg}}, client); err != nil {
+		t.Fatalf("Could not store package: %v", err)
+	}
+
+	// then the attestation of an artifact having one of its digests
+	art := ArtifactNode{Name: "left-pad.tgz", Digest: "sha256:1234"}
+	other := ArtifactNode{Name: "right-pad.tgz", Digest: "sha256:5678"}
+	graph := Graph{
+		Nodes: []GuacNode{art, other},
+		Edges: []GuacEdge{
+			PackageOfDigestEdge{ArtifactNode: art},
+			PackageOfDigestEdge{ArtifactNode: other},
+		},
+	}
+	if err := StoreGraph(graph, client); err != nil {
+		t.Fatalf("Could not store graph: %v", err)
+	}
+
+	result, err := graphdb.ReadQueryForTesting(client,
+		"MATCH (a:Artifact)-[:PackageOf]->(p:Package) RETURN a.digest, p.purl", nil)
+	if err != nil {
+		t.Fatalf("Could not retrieve edges: %v", err)
+	}
+	if len(result) != 1 || result[0][0] != "sha256:1234" || result[0][1] != pkg.Purl {
+		t.Errorf("PackageOf edges = %v, want [[sha256:1234 %s]]", result, pkg.Purl)
+	}
+
+	result, err = graphdb.ReadQueryForTesting(client, "MATCH (p:Package) RETURN p.purl", nil)
+	if err != nil {
+		t.Fatalf("Could not retrieve packages: %v", err)
+	}
+	if len(result) != 1 {
+		t.Errorf("got %d packages, want only the stored one: %v", len(result), result)
+	}
+}
+
 func Test_SLSASubgraph(t *testing.T) {
 	tests := []func() Graph{
 		func() Graph {
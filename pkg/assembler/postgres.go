@@ -0,0 +1,788 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package assembler
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/guacsec/guac/pkg/logging"
+	"github.com/guacsec/guac/pkg/tracing"
+	"github.com/lib/pq"
+)
+
+// postgresSchema creates the tables of the graph. Every node is a row of
+// nodes, identified by its nodeKey, with its properties as JSON. Packages,
+// artifacts and attestations also have a row in their own table indexing
+// the values identifying them, an artifact having a row of artifact_digests
+// per digest. Other nodes, such as documents, are indexed by their digest
+// property. There is at most one edge of a label from a node to another.
+// Nodes and edges are indexed by the documents asserting them.
+var postgresSchema = []string{
+	`CREATE TABLE IF NOT EXISTS nodes (
+		id BIGSERIAL PRIMARY KEY,
+		label TEXT NOT NULL,
+		key TEXT NOT NULL UNIQUE,
+		properties JSONB NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS nodes_label ON nodes (label)`,
+	`CREATE INDEX IF NOT EXISTS nodes_digest ON nodes (label, (properties->>'digest'))`,
+	`CREATE INDEX IF NOT EXISTS nodes_documents ON nodes USING GIN ((properties->'documents'))`,
+	`CREATE TABLE IF NOT EXISTS packages (
+		node_id BIGINT PRIMARY KEY REFERENCES nodes (id) ON DELETE CASCADE,
+		purl TEXT NOT NULL UNIQUE,
+		name TEXT,
+		version TEXT
+	)`,
+	`CREATE INDEX IF NOT EXISTS packages_name ON packages (name)`,
+	`CREATE TABLE IF NOT EXISTS artifacts (
+		node_id BIGINT PRIMARY KEY REFERENCES nodes (id) ON DELETE CASCADE,
+		digest TEXT NOT NULL,
+		name TEXT
+	)`,
+	`CREATE INDEX IF NOT EXISTS artifacts_name ON artifacts (name)`,
+	`CREATE TABLE IF NOT EXISTS artifact_digests (
+		digest TEXT NOT NULL,
+		node_id BIGINT NOT NULL REFERENCES nodes (id) ON DELETE CASCADE,
+		PRIMARY KEY (digest, node_id)
+	)`,
+	`CREATE TABLE IF NOT EXISTS attestations (
+		node_id BIGINT PRIMARY KEY REFERENCES nodes (id) ON DELETE CASCADE,
+		digest TEXT NOT NULL,
+		attestation_type TEXT,
+		statement_time TEXT
+	)`,
+	`CREATE INDEX IF NOT EXISTS attestations_digest ON attestations (digest)`,
+	`CREATE INDEX IF NOT EXISTS attestations_type ON attestations (attestation_type, statement_time)`,
+	`CREATE TABLE IF NOT EXISTS edges (
+		id BIGSERIAL PRIMARY KEY,
+		label TEXT NOT NULL,
+		from_id BIGINT NOT NULL REFERENCES nodes (id) ON DELETE CASCADE,
+		to_id BIGINT NOT NULL REFERENCES nodes (id) ON DELETE CASCADE,
+		properties JSONB NOT NULL,
+		UNIQUE (label, from_id, to_id)
+	)`,
+	`CREATE INDEX IF NOT EXISTS edges_from ON edges (from_id, label)`,
+	`CREATE INDEX IF NOT EXISTS edges_to ON edges (to_id, label)`,
+	`CREATE INDEX IF NOT EXISTS edges_documents ON edges USING GIN ((properties->'documents'))`,
+}
+
+// postgresTables update the rows of the node $1 in the tables of its label
+// from its properties
+var postgresTables = map[string][]string{
+	"Package": {
+		`INSERT INTO packages (node_id, purl, name, version)
+		SELECT id, properties->>'purl', properties->>'name', properties->>'version' FROM nodes WHERE id = $1
+		ON CONFLICT (node_id) DO UPDATE SET name = EXCLUDED.name, version = EXCLUDED.version`,
+	},
+	"Artifact": {
+		`INSERT INTO artifacts (node_id, digest, name)
+		SELECT id, properties->>'digest', properties->>'name' FROM nodes WHERE id = $1
+		ON CONFLICT (node_id) DO UPDATE SET name = EXCLUDED.name`,
+		`INSERT INTO artifact_digests (digest, node_id)
+		SELECT value, id FROM nodes, jsonb_each_text(properties)
+		WHERE id = $1 AND (key = 'digest' OR key LIKE 'digest\_%')
+		ON CONFLICT DO NOTHING`,
+	},
+	"Attestation": {
+		`INSERT INTO attestations (node_id, digest, attestation_type, statement_time)
+		SELECT id, properties->>'digest', properties->>'attestation_type', properties->>'statement_time' FROM nodes WHERE id = $1
+		ON CONFLICT (node_id) DO UPDATE SET attestation_type = EXCLUDED.attestation_type, statement_time = EXCLUDED.statement_time`,
+	},
+}
+
+// A node or edge written replaces the properties of the stored one, a null
+// value removing the property as in Neo4j
+const (
+	postgresMergeNode = `INSERT INTO nodes (label, key, properties) VALUES ($1, $2, jsonb_strip_nulls($3::jsonb))
+ON CONFLICT (key) DO UPDATE SET properties = jsonb_strip_nulls(nodes.properties || $3::jsonb)
+RETURNING id`
+	postgresMergeEdge = `INSERT INTO edges (label, from_id, to_id, properties) VALUES ($1, $2, $3, jsonb_strip_nulls($4::jsonb))
+ON CONFLICT (label, from_id, to_id) DO UPDATE SET properties = jsonb_strip_nulls(edges.properties || $4::jsonb)`
+	// the documents are added to the ones stored, as the properties of the
+	// merge statements replace them
+	postgresAddNodeDocuments = `UPDATE nodes SET properties = jsonb_set(properties, '{documents}', (
+	SELECT jsonb_agg(DISTINCT d) FROM jsonb_array_elements_text(COALESCE(properties->'documents', '[]'::jsonb) || $2::jsonb) d))
+WHERE id = $1`
+	postgresAddEdgeDocuments = `UPDATE edges SET properties = jsonb_set(properties, '{documents}', (
+	SELECT jsonb_agg(DISTINCT d) FROM jsonb_array_elements_text(COALESCE(properties->'documents', '[]'::jsonb) || $4::jsonb) d))
+WHERE label = $1 AND from_id = $2 AND to_id = $3`
+	// the documents of the nodes and edges asserted by the document $1 are
+	// updated first, then the ones left without documents are deleted, then
+	// the endpoints of the deleted edges kept without documents by an earlier
+	// retraction once unlinked
+	postgresFindDocument  = `SELECT id FROM nodes WHERE label = 'Document' AND properties->>'digest' = $1`
+	postgresEdgeEndpoints = `SELECT from_id FROM edges WHERE properties->'documents' = jsonb_build_array($1::text)
+UNION SELECT to_id FROM edges WHERE properties->'documents' = jsonb_build_array($1::text)`
+	postgresRetractEdges = `UPDATE edges SET properties = jsonb_set(properties, '{documents}', (properties->'documents') - $1::text)
+WHERE properties->'documents' ? $1 RETURNING id`
+	postgresDeleteEdges  = `DELETE FROM edges WHERE id = ANY($1) AND properties->'documents' = '[]'::jsonb RETURNING id`
+	postgresRetractNodes = `UPDATE nodes SET properties = jsonb_set(properties, '{documents}', (properties->'documents') - $1::text)
+WHERE properties->'documents' ? $1 RETURNING id`
+	postgresDeleteNodes = `DELETE FROM nodes n WHERE n.id = ANY($1) AND n.properties->'documents' = '[]'::jsonb
+AND NOT EXISTS (SELECT 1 FROM edges e WHERE e.from_id = n.id OR e.to_id = n.id) RETURNING n.id`
+	postgresDeleteDocument = `DELETE FROM nodes WHERE id = ANY($1)`
+	postgresFindArtifacts  = `SELECT properties FROM nodes
+WHERE label = 'Artifact' AND id IN (SELECT node_id FROM artifact_digests WHERE digest = ANY($1))
+ORDER BY id`
+)
+
+// postgresPathsQuery walks the edges, with a label in $4 unless empty, from
+// the node $1 without repeating an edge, and returns the walks of at most $3
+// edges reaching the node $2, shortest first
+const postgresPathsQuery = `WITH RECURSIVE walk (node, nodes, edges) AS (
+	SELECT id, ARRAY[id], ARRAY[]::BIGINT[] FROM nodes WHERE id = $1
+	UNION ALL
+	SELECT e.to_id, w.nodes || e.to_id, w.edges || e.id
+	FROM walk w JOIN edges e ON e.from_id = w.node
+	WHERE cardinality(w.edges) < $3
+	AND (cardinality($4::TEXT[]) = 0 OR e.label = ANY($4::TEXT[]))
+	AND NOT e.id = ANY(w.edges)
+)
+SELECT nodes, edges FROM walk WHERE node = $2 AND cardinality(edges) > 0
+ORDER BY cardinality(edges), edges LIMIT $5`
+
+// postgresRootPathsQuery walks the edges, with a label in $3 unless empty,
+// backwards from the node $1 without repeating an edge, and returns the walks
+// of at most $2 edges from a node without such incoming edges, shortest first
+const postgresRootPathsQuery = `WITH RECURSIVE walk (node, nodes, edges) AS (
+	SELECT id, ARRAY[id], ARRAY[]::BIGINT[] FROM nodes WHERE id = $1
+	UNION ALL
+	SELECT e.from_id, e.from_id || w.nodes, e.id || w.edges
+	FROM walk w JOIN edges e ON e.to_id = w.node
+	WHERE cardinality(w.edges) < $2
+	AND (cardinality($3::TEXT[]) = 0 OR e.label = ANY($3::TEXT[]))
+	AND NOT e.id = ANY(w.edges)
+)
+SELECT nodes, edges FROM walk w
+WHERE NOT EXISTS (
+	SELECT 1 FROM edges e WHERE e.to_id = w.node
+	AND (cardinality($3::TEXT[]) = 0 OR e.label = ANY($3::TEXT[]))
+)
+ORDER BY cardinality(edges), edges LIMIT $4`
+
+// postgresBackend stores the graph in the tables of postgresSchema, for
+// teams not operating Neo4j, and answers the queries of GraphReader with SQL
+type postgresBackend struct {
+	db   *sql.DB
+	opts WriteOptions
+}
+
+// newPostgresBackend connects to the Postgres database at the postgres://
+// URL config.Address and creates the tables of the graph if needed
+func newPostgresBackend(ctx context.Context, config BackendConfig) (Backend, error) {
+	dsn, err := postgresDSN(config)
+	if err != nil {
+		return nil, err
+	}
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, err
+	}
+	for _, statement := range postgresSchema {
+		if _, err := db.ExecContext(ctx, statement); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to create the graph tables: %w", err)
+		}
+	}
+	return &postgresBackend{db: db, opts: config.writeOptions()}, nil
+}
+
+// postgresDSN returns the URL of the database at config.Address, with the
+// credentials of config if set, and verifying the server certificate while
+// presenting the client certificate if one is configured
+func postgresDSN(config BackendConfig) (string, error) {
+	u, err := url.Parse(config.Address)
+	if err != nil {
+		return "", fmt.Errorf("invalid postgres address: %w", err)
+	}
+	if u.Scheme != "postgres" && u.Scheme != "postgresql" {
+		return "", fmt.Errorf("the postgres backend requires a postgres:// address, got %s://", u.Scheme)
+	}
+	if config.User != "" {
+		u.User = url.UserPassword(config.User, config.Password)
+	}
+	if config.TLSCertPath != "" {
+		query := u.Query()
+		query.Set("sslmode", "verify-full")
+		query.Set("sslcert", config.TLSCertPath)
+		query.Set("sslkey", config.TLSKeyPath)
+		if config.TLSCAPath != "" {
+			query.Set("sslrootcert", config.TLSCAPath)
+		}
+		u.RawQuery = query.Encode()
+	}
+	return u.String(), nil
+}
+
+// StoreGraph merges g into the tables as StoreGraphWithOptions merges it into
+// Neo4j: artifacts are merged with the stored artifacts sharing a digest, and
+// the writes are split in transactions of the batch size of the write
+// options.
+func (b *postgresBackend) StoreGraph(ctx context.Context, g Graph) (err error) {
+	ctx, span := tracing.Start(ctx, "store")
+	span.SetAttribute("guac.nodes", len(g.Nodes))
+	span.SetAttribute("guac.edges", len(g.Edges))
+	start := time.Now()
+	defer func() {
+		span.RecordError(err)
+		span.End()
+		observeStore(PostgresBackend, start, err)
+	}()
+
+	ctx = logging.WithComponent(ctx, logging.ComponentAssembler)
+	g = DeduplicateGraph(g)
+	resolver, err := newArtifactResolver(ctx, g, func(digests []string) ([][]string, error) {
+		return b.findArtifacts(ctx, digests)
+	})
+	if err != nil {
+		return err
+	}
+
+	writes := make([]func(tx *sql.Tx) error, 0, len(g.Documents)+len(g.Nodes)+len(g.Edges))
+	for _, doc := range g.Documents {
+		doc := doc
+		writes = append(writes, func(tx *sql.Tx) error {
+			_, err := mergePostgresNode(ctx, tx, doc, nil)
+			return err
+		})
+	}
+	for _, n := range g.Nodes {
+		// the documents asserting n are looked up before it is resolved
+		docs := g.Evidence[nodeKey(n)]
+		n := resolver.resolve(n)
+		if err := checkIdentifiable(n); err != nil {
+			return err
+		}
+		writes = append(writes, func(tx *sql.Tx) error {
+			_, err := mergePostgresNode(ctx, tx, n, docs)
+			return err
+		})
+	}
+	for _, e := range g.Edges {
+		e := e
+		docs := g.Evidence[edgeKey(e)]
+		a, u := e.Nodes()
+		a = resolver.resolve(a)
+		if err := checkIdentifiable(a); err != nil {
+			return err
+		}
+		_, matching := e.(MatchingEdge)
+		if !matching {
+			u = resolver.resolve(u)
+			if err := checkIdentifiable(u); err != nil {
+				return err
+			}
+		}
+		writes = append(writes, func(tx *sql.Tx) error {
+			from, err := mergePostgresNode(ctx, tx, a, nil)
+			if err != nil {
+				return err
+			}
+			if !matching {
+				to, err := mergePostgresNode(ctx, tx, u, nil)
+				if err != nil {
+					return err
+				}
+				return mergePostgresEdge(ctx, tx, e, from, to, docs)
+			}
+			query, args, err := matchingNodesSQL(u)
+			if err != nil {
+				return err
+			}
+			ids, err := queryIDs(ctx, tx, query, args...)
+			if err != nil {
+				return err
+			}
+			for _, to := range ids {
+				if err := mergePostgresEdge(ctx, tx, e, from, to, docs); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+
+	batchSize := b.opts.batchSize()
+	for len(writes) > 0 {
+		batch := writes
+		if len(batch) > batchSize {
+			batch = batch[:batchSize]
+		}
+		writes = writes[len(batch):]
+		if b.opts.Limiter != nil {
+			if err := b.opts.Limiter.WaitN(ctx, len(batch)); err != nil {
+				return err
+			}
+		}
+		if err := b.transaction(ctx, batch); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *postgresBackend) transaction(ctx context.Context, writes []func(tx *sql.Tx) error) error {
+	tx, err := b.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	for _, write := range writes {
+		if err := write(tx); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// RetractDocument removes the document with digest from the graph in a
+// single transaction, as RetractDocument does
+func (b *postgresBackend) RetractDocument(ctx context.Context, digest string) (Retraction, error) {
+	digest = strings.ToLower(digest)
+	var r Retraction
+	err := b.transaction(ctx, []func(tx *sql.Tx) error{func(tx *sql.Tx) error {
+		docs, err := queryIDs(ctx, tx, postgresFindDocument, digest)
+		if err != nil {
+			return err
+		}
+		if len(docs) == 0 {
+			return ErrNodeNotFound
+		}
+		endpoints, err := queryIDs(ctx, tx, postgresEdgeEndpoints, digest)
+		if err != nil {
+			return err
+		}
+		if r.DeletedEdges, r.RetainedEdges, err = retractPostgres(ctx, tx, postgresRetractEdges, postgresDeleteEdges, digest); err != nil {
+			return err
+		}
+		if r.DeletedNodes, r.RetainedNodes, err = retractPostgres(ctx, tx, postgresRetractNodes, postgresDeleteNodes, digest); err != nil {
+			return err
+		}
+		unlinked, err := queryIDs(ctx, tx, postgresDeleteNodes, pq.Array(endpoints))
+		if err != nil {
+			return err
+		}
+		r.DeletedNodes += int64(len(unlinked))
+		_, err = tx.ExecContext(ctx, postgresDeleteDocument, pq.Array(docs))
+		return err
+	}})
+	if err != nil {
+		return Retraction{}, err
+	}
+	return r, nil
+}
+
+// retractPostgres removes digest from the rows updated by the retract
+// statement, then runs the delete statement on them, and returns the number
+// of deleted and retained rows
+func retractPostgres(ctx context.Context, tx *sql.Tx, retract string, del string, digest string) (int64, int64, error) {
+	updated, err := queryIDs(ctx, tx, retract, digest)
+	if err != nil {
+		return 0, 0, err
+	}
+	deleted, err := queryIDs(ctx, tx, del, pq.Array(updated))
+	if err != nil {
+		return 0, 0, err
+	}
+	return int64(len(deleted)), int64(len(updated) - len(deleted)), nil
+}
+
+// findArtifacts returns the digests of the stored artifacts having any of
+// digests, each starting with the digest identifying the artifact
+func (b *postgresBackend) findArtifacts(ctx context.Context, digests []string) ([][]string, error) {
+	rows, err := b.db.QueryContext(ctx, postgresFindArtifacts, pq.Array(digests))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	stored := [][]string{}
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		props, err := decodeJSONProperties(data)
+		if err != nil {
+			return nil, err
+		}
+		stored = append(stored, storedDigests(props))
+	}
+	return stored, rows.Err()
+}
+
+// mergePostgresNode stores n, asserted by the documents with digests docs,
+// and returns its id
+func mergePostgresNode(ctx context.Context, tx *sql.Tx, n GuacNode, docs []string) (int64, error) {
+	props, err := json.Marshal(propertyValues(n.Properties()))
+	if err != nil {
+		return 0, err
+	}
+	var id int64
+	if err := tx.QueryRowContext(ctx, postgresMergeNode, n.Type(), nodeKey(n), string(props)).Scan(&id); err != nil {
+		return 0, err
+	}
+	for _, statement := range postgresTables[n.Type()] {
+		if _, err := tx.ExecContext(ctx, statement, id); err != nil {
+			return 0, err
+		}
+	}
+	if len(docs) > 0 {
+		documents, err := json.Marshal(docs)
+		if err != nil {
+			return 0, err
+		}
+		if _, err := tx.ExecContext(ctx, postgresAddNodeDocuments, id, string(documents)); err != nil {
+			return 0, err
+		}
+	}
+	return id, nil
+}
+
+// mergePostgresEdge stores e from the node from to the node to, asserted by
+// the documents with digests docs
+func mergePostgresEdge(ctx context.Context, tx *sql.Tx, e GuacEdge, from int64, to int64, docs []string) error {
+	props, err := json.Marshal(propertyValues(e.Properties()))
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, postgresMergeEdge, e.Type(), from, to, string(props)); err != nil {
+		return err
+	}
+	if len(docs) == 0 {
+		return nil
+	}
+	documents, err := json.Marshal(docs)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, postgresAddEdgeDocuments, e.Type(), from, to, string(documents))
+	return err
+}
+
+// matchingNodesSQL returns the query of the ids of the nodes matching the u
+// node of a MatchingEdge: the nodes of its type sharing a value with each of
+// its properties, a property being a value or a list of values
+func matchingNodesSQL(u GuacNode) (string, []interface{}, error) {
+	props := propertyValues(u.Properties())
+	keys := make([]string, 0, len(props))
+	for k := range props {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	args := []interface{}{u.Type()}
+	conditions := []string{"n.label = $1"}
+	for _, k := range keys {
+		values := readProperties(map[string]interface{}{k: props[k]})[k]
+		list, ok := values.([]interface{})
+		if !ok {
+			list = []interface{}{}
+			if values != nil {
+				list = append(list, values)
+			}
+		}
+		matched, err := json.Marshal(list)
+		if err != nil {
+			return "", nil, err
+		}
+		args = append(args, k, string(matched))
+		key := fmt.Sprintf("n.properties->$%d", len(args)-1)
+		conditions = append(conditions, fmt.Sprintf(
+			"EXISTS (SELECT 1 FROM jsonb_array_elements(CASE jsonb_typeof(%s) WHEN 'array' THEN %s ELSE jsonb_build_array(%s) END) s "+
+				"JOIN jsonb_array_elements($%d::jsonb) m ON s.value = m.value)",
+			key, key, key, len(args)))
+	}
+	return "SELECT n.id FROM nodes n WHERE " + strings.Join(conditions, " AND ") + " ORDER BY n.id", args, nil
+}
+
+func queryIDs(ctx context.Context, tx *sql.Tx, query string, args ...interface{}) ([]int64, error) {
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	ids := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// CreateIndexOn indexes the attribute of the nodes with label
+func (b *postgresBackend) CreateIndexOn(nodeLabel string, nodeAttribute string) error {
+	if err := ValidateLabel(nodeLabel); err != nil {
+		return err
+	}
+	if err := ValidateLabel(nodeAttribute); err != nil {
+		return err
+	}
+	// label and attribute are validated, and quoted as the index name
+	_, err := b.db.Exec(fmt.Sprintf(`CREATE INDEX IF NOT EXISTS "nodes_%s_%s" ON nodes ((properties->>'%s')) WHERE label = '%s'`,
+		nodeLabel, nodeAttribute, nodeAttribute, nodeLabel))
+	return err
+}
+
+func (b *postgresBackend) Ping() error {
+	return b.db.Ping()
+}
+
+func (b *postgresBackend) Close() error {
+	return b.db.Close()
+}
+
+// findNodesSQL returns the query of the nodes selected by filter, using the
+// tables of packages, artifacts and attestations to find them by the values
+// identifying them
+func findNodesSQL(filter NodeFilter) (string, []interface{}, error) {
+	if err := ValidateLabel(filter.Label); err != nil {
+		return "", nil, err
+	}
+	args := []interface{}{filter.Label}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	conditions := []string{"n.label = $1"}
+	if filter.Purl != "" {
+		if filter.Label == "Package" {
+			conditions = append(conditions, "n.id IN (SELECT node_id FROM packages WHERE purl = "+arg(filter.Purl)+")")
+		} else {
+			conditions = append(conditions, "n.properties->>'purl' = "+arg(filter.Purl))
+		}
+	}
+	if filter.Digest != "" {
+		d := strings.ToLower(filter.Digest)
+		switch filter.Label {
+		case "Artifact":
+			conditions = append(conditions, "n.id IN (SELECT node_id FROM artifact_digests WHERE digest = "+arg(d)+")")
+		case "Attestation":
+			conditions = append(conditions, "n.id IN (SELECT node_id FROM attestations WHERE digest = "+arg(d)+")")
+		case "Package":
+			conditions = append(conditions, "n.properties->'digest' @> to_jsonb("+arg(d)+"::text)")
+		default:
+			conditions = append(conditions, "n.properties->>'digest' = "+arg(d))
+		}
+	}
+	if filter.PredicateType != "" {
+		if filter.Label == "Attestation" {
+			conditions = append(conditions, "n.id IN (SELECT node_id FROM attestations WHERE attestation_type = "+arg(filter.PredicateType)+")")
+		} else {
+			conditions = append(conditions, "n.properties->>'attestation_type' = "+arg(filter.PredicateType))
+		}
+	}
+	if filter.ID != "" {
+		id := arg(filter.ID)
+		if filter.Label == "Vulnerability" {
+			conditions = append(conditions, "(n.properties->>'id' = "+id+" OR n.properties->'aliases' @> to_jsonb("+id+"::text))")
+		} else {
+			conditions = append(conditions, "n.properties->>'id' = "+id)
+		}
+	}
+	if filter.Source != "" {
+		source := arg(filter.Source)
+		conditions = append(conditions, "(n.properties->>'source_uri' = "+source+" OR n.properties->>'source' = "+source+")")
+	}
+	query := "SELECT n.id, n.label, n.properties FROM nodes n WHERE " + strings.Join(conditions, " AND ") +
+		" ORDER BY n.id LIMIT " + arg(queryLimit(filter.Limit))
+	return query, args, nil
+}
+
+// FindNodes returns the nodes selected by filter, as FindNodes does
+func (b *postgresBackend) FindNodes(filter NodeFilter) ([]StoredNode, error) {
+	query, args, err := findNodesSQL(filter)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := b.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	nodes := []StoredNode{}
+	for rows.Next() {
+		n, err := scanPostgresNode(rows)
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, n)
+	}
+	return nodes, rows.Err()
+}
+
+// Neighbors returns the nodes linked to the node with id id, as Neighbors
+// does
+func (b *postgresBackend) Neighbors(id int64, edge string, dir Direction, label string, limit int) ([]Neighbor, error) {
+	if _, err := neighborsQuery(edge, dir, label); err != nil {
+		return nil, err
+	}
+	node, other := "from_id", "to_id"
+	if dir == DirectionIn {
+		node, other = other, node
+	}
+	query := fmt.Sprintf(`SELECT e.label, e.properties, n.id, n.label, n.properties
+FROM edges e JOIN nodes n ON n.id = e.%s
+WHERE e.%s = $1 AND ($2::TEXT = '' OR e.label = $2::TEXT) AND ($3::TEXT = '' OR n.label = $3::TEXT)
+ORDER BY n.id, e.id LIMIT $4`, other, node)
+	rows, err := b.db.Query(query, id, edge, label, queryLimit(limit))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	neighbors := []Neighbor{}
+	for rows.Next() {
+		var n Neighbor
+		var edgeProps, nodeProps []byte
+		if err := rows.Scan(&n.Edge, &edgeProps, &n.Node.ID, &n.Node.Label, &nodeProps); err != nil {
+			return nil, err
+		}
+		if n.EdgeProperties, err = decodeJSONProperties(edgeProps); err != nil {
+			return nil, err
+		}
+		if n.Node.Properties, err = decodeJSONProperties(nodeProps); err != nil {
+			return nil, err
+		}
+		neighbors = append(neighbors, n)
+	}
+	return neighbors, rows.Err()
+}
+
+// Paths returns the paths from the node with id from to the node with id to,
+// as Paths does
+func (b *postgresBackend) Paths(from int64, to int64, edges []string, depth int, limit int) ([]Path, error) {
+	_, depth, err := pathEdges(edges, depth)
+	if err != nil {
+		return nil, err
+	}
+	// an empty array follows every edge, as opposed to NULL
+	return b.readPaths(postgresPathsQuery, from, to, depth, pq.Array(append([]string{}, edges...)), queryLimit(limit))
+}
+
+// RootPaths returns the paths to the node with id id from the root nodes
+// reaching it, as RootPaths does
+func (b *postgresBackend) RootPaths(id int64, edges []string, depth int, limit int) ([]Path, error) {
+	_, depth, err := pathEdges(edges, depth)
+	if err != nil {
+		return nil, err
+	}
+	return b.readPaths(postgresRootPathsQuery, id, depth, pq.Array(append([]string{}, edges...)), queryLimit(limit))
+}
+
+// readPaths returns the paths of the node and edge ids returned by query
+func (b *postgresBackend) readPaths(query string, args ...interface{}) ([]Path, error) {
+	rows, err := b.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	type idPath struct {
+		nodes []int64
+		edges []int64
+	}
+	idPaths := []idPath{}
+	nodeIDs := []int64{}
+	edgeIDs := []int64{}
+	for rows.Next() {
+		var p idPath
+		if err := rows.Scan(pq.Array(&p.nodes), pq.Array(&p.edges)); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		idPaths = append(idPaths, p)
+		nodeIDs = append(nodeIDs, p.nodes...)
+		edgeIDs = append(edgeIDs, p.edges...)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(idPaths) == 0 {
+		return []Path{}, nil
+	}
+
+	nodes := map[int64]StoredNode{}
+	rows, err = b.db.Query("SELECT n.id, n.label, n.properties FROM nodes n WHERE n.id = ANY($1)", pq.Array(nodeIDs))
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		n, err := scanPostgresNode(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		nodes[n.ID] = n
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	labels := map[int64]string{}
+	rows, err = b.db.Query("SELECT id, label FROM edges WHERE id = ANY($1)", pq.Array(edgeIDs))
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var id int64
+		var label string
+		if err := rows.Scan(&id, &label); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		labels[id] = label
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	paths := make([]Path, 0, len(idPaths))
+	for _, p := range idPaths {
+		path := Path{Nodes: make([]StoredNode, 0, len(p.nodes)), Edges: make([]string, 0, len(p.edges))}
+		for _, id := range p.nodes {
+			path.Nodes = append(path.Nodes, nodes[id])
+		}
+		for _, id := range p.edges {
+			path.Edges = append(path.Edges, labels[id])
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+func scanPostgresNode(rows *sql.Rows) (StoredNode, error) {
+	var n StoredNode
+	var props []byte
+	if err := rows.Scan(&n.ID, &n.Label, &props); err != nil {
+		return n, err
+	}
+	var err error
+	n.Properties, err = decodeJSONProperties(props)
+	return n, err
+}
@@ -0,0 +1,218 @@
+//
+// Copyright 2022 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package assembler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// TimeFormat is the format of the times stored as properties, e.g.
+// `collected_at`: RFC 3339 in UTC with a fixed number of fractional digits, so
+// that ordering the strings orders the times, e.g.
+// `ORDER BY a.statement_time DESC`. Neo4j's datetime() parses it as well.
+const TimeFormat = "2006-01-02T15:04:05.000000000Z"
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(TimeFormat)
+}
+
+// Property values are stored with their native type so that they can be
+// compared in queries, e.g. `WHERE n.score > 7.0`. The supported types are
+// string, int64, float64 and bool, and lists of one of them. propertyValue
+// converts the values of GuacNode and GuacEdge properties to these types:
+//
+//   - other integer types are converted to int64 (or float64 if they do not
+//     fit), float32 to float64 and json.Number to int64 or float64
+//   - times are converted to strings in TimeFormat
+//   - lists mixing integers and floats are converted to lists of float64
+//   - other values (maps, structs, lists of mixed types, ...) are JSON encoded
+//
+// A property always has the type of the last value written, so a node
+// merged from documents giving a property values of different types ends up
+// with the type of the last one stored.
+func propertyValue(v interface{}) interface{} {
+	switch value := v.(type) {
+	case nil, string, bool, int64, float64:
+		return value
+	case json.Number:
+		if i, err := value.Int64(); err == nil {
+			return i
+		}
+		if f, err := value.Float64(); err == nil {
+			return f
+		}
+		return value.String()
+	case float32:
+		// keep the shortest decimal representation, e.g. 7.1 rather than
+		// 7.099999904632568
+		f, _ := strconv.ParseFloat(strconv.FormatFloat(float64(value), 'g', -1, 32), 64)
+		return f
+	case time.Time:
+		return formatTime(value)
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if u := rv.Uint(); u <= math.MaxInt64 {
+			return int64(u)
+		}
+		return float64(rv.Uint())
+	case reflect.Float32:
+		return propertyValue(float32(rv.Float()))
+	case reflect.Float64:
+		return rv.Float()
+	case reflect.Bool:
+		return rv.Bool()
+	case reflect.String:
+		return rv.String()
+	case reflect.Slice, reflect.Array:
+		if rv.Kind() == reflect.Slice && rv.IsNil() {
+			return nil
+		}
+		if list, ok := propertyList(rv); ok {
+			return list
+		}
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return string(b)
+}
+
+// propertyList converts a list of scalars of the same type to a list of
+// that property type, returning false for other lists
+func propertyList(rv reflect.Value) (interface{}, bool) {
+	values := make([]interface{}, rv.Len())
+	elemType := ""
+	for i := range values {
+		values[i] = propertyValue(rv.Index(i).Interface())
+		t := propertyType(values[i])
+		switch {
+		case t == "" || t == "list":
+			return nil, false
+		case elemType == "" || elemType == t:
+			elemType = t
+		case isNumericType(elemType) && isNumericType(t):
+			elemType = "double"
+		default:
+			return nil, false
+		}
+	}
+
+	switch elemType {
+	case "", "string":
+		list := make([]string, len(values))
+		for i, v := range values {
+			list[i] = v.(string)
+		}
+		return list, true
+	case "long":
+		list := make([]int64, len(values))
+		for i, v := range values {
+			list[i] = v.(int64)
+		}
+		return list, true
+	case "double":
+		list := make([]float64, len(values))
+		for i, v := range values {
+			if n, ok := v.(int64); ok {
+				list[i] = float64(n)
+			} else {
+				list[i] = v.(float64)
+			}
+		}
+		return list, true
+	case "boolean":
+		list := make([]bool, len(values))
+		for i, v := range values {
+			list[i] = v.(bool)
+		}
+		return list, true
+	}
+	return nil, false
+}
+
+// propertyType returns the type of a value returned by propertyValue, named
+// as in GraphML: `string`, `long`, `double`, `boolean`, or `list` for lists
+// and an empty string for nil
+func propertyType(v interface{}) string {
+	switch v.(type) {
+	case string:
+		return "string"
+	case int64:
+		return "long"
+	case float64:
+		return "double"
+	case bool:
+		return "boolean"
+	case []string, []int64, []float64, []bool:
+		return "list"
+	}
+	return ""
+}
+
+func isNumericType(t string) bool {
+	return t == "long" || t == "double"
+}
+
+// propertyValues returns the properties with their values converted by
+// propertyValue
+func propertyValues(props map[string]interface{}) map[string]interface{} {
+	values := make(map[string]interface{}, len(props))
+	for k, v := range props {
+		values[k] = propertyValue(v)
+	}
+	return values
+}
+
+// decodeJSONProperties decodes the properties of a node or edge stored as
+// JSON as they are read from Neo4j: lists as []interface{} and numbers as
+// int64, or float64 if they have a fraction or exponent
+func decodeJSONProperties(data []byte) (map[string]interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	props := map[string]interface{}{}
+	if err := decoder.Decode(&props); err != nil {
+		return nil, fmt.Errorf("invalid stored properties: %w", err)
+	}
+	for k, v := range props {
+		props[k] = decodeJSONValue(v)
+	}
+	return props, nil
+}
+
+func decodeJSONValue(v interface{}) interface{} {
+	switch value := v.(type) {
+	case json.Number:
+		return propertyValue(value)
+	case []interface{}:
+		for i := range value {
+			value[i] = decodeJSONValue(value[i])
+		}
+		return value
+	}
+	return v
+}
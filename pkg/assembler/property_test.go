@@ -0,0 +1,158 @@
+//
+// Copyright 2022 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package assembler
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPropertyValue(t *testing.T) {
+	type score float64
+	tests := []struct {
+		name  string
+		value interface{}
+		want  interface{}
+	}{
+		{name: "nil", value: nil, want: nil},
+		{name: "string", value: "a", want: "a"},
+		{name: "bool", value: true, want: true},
+		{name: "int", value: 7, want: int64(7)},
+		{name: "uint8", value: uint8(7), want: int64(7)},
+		{name: "large uint64", value: uint64(math.MaxUint64), want: float64(math.MaxUint64)},
+		{name: "float32", value: float32(7.1), want: 7.1},
+		{name: "named float", value: score(9.8), want: 9.8},
+		{name: "json integer", value: json.Number("3"), want: int64(3)},
+		{name: "json float", value: json.Number("3.5"), want: 3.5},
+		{name: "time", value: time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC), want: "2023-01-02T03:04:05.000000000Z"},
+		{name: "string list", value: []interface{}{"a", "b"}, want: []string{"a", "b"}},
+		{name: "int list", value: []int{1, 2}, want: []int64{1, 2}},
+		{name: "mixed numbers list", value: []interface{}{1, 2.5}, want: []float64{1, 2.5}},
+		{name: "empty list", value: []string{}, want: []string{}},
+		{name: "mixed list", value: []interface{}{"a", 1}, want: `["a",1]`},
+		{name: "nested list", value: [][]string{{"a"}}, want: `[["a"]]`},
+		{name: "map", value: map[string]interface{}{"b": 1, "a": "x"}, want: `{"a":"x","b":1}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := propertyValue(tt.value); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("propertyValue() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNodeWriteTypedParams(t *testing.T) {
+	n := MetadataNode{
+		MetadataType: "scorecard",
+		ID:           "repo:commit",
+		Details: map[string]interface{}{
+			"score":      float32(7.5),
+			"Maintained": 10,
+			"checks":     map[string]int{"Maintained": 10},
+		},
+	}
+	w, err := nodeWrite(n, nil)
+	if err != nil {
+		t.Fatalf("nodeWrite() error = %v", err)
+	}
+	want := map[string]interface{}{
+		"metadata_type": "scorecard",
+		"id":            "repo:commit",
+		"score":         7.5,
+		"Maintained":    int64(10),
+		"checks":        `{"Maintained":10}`,
+	}
+	if !reflect.DeepEqual(w.row["n"], want) {
+		t.Errorf("nodeWrite() row = %v, want %v", w.row["n"], want)
+	}
+}
+
+func TestGraphMLType(t *testing.T) {
+	props := []map[string]interface{}{
+		{"name": "a", "score": int64(7), "ok": true, "mixed": "x", "tags": []string{"a"}},
+		{"name": "b", "score": 7.5, "ok": false, "mixed": int64(1)},
+		{"name": "c"},
+	}
+	for k, want := range map[string]string{
+		"name":    "string",
+		"score":   "double",
+		"ok":      "boolean",
+		"mixed":   "string",
+		"tags":    "string",
+		"missing": "string",
+	} {
+		if got := graphMLType(props, k); got != want {
+			t.Errorf("graphMLType(%q) = %q, want %q", k, got, want)
+		}
+	}
+}
+
+func TestFormatTimeOrder(t *testing.T) {
+	// in increasing order, with and without fractional seconds and in
+	// different zones
+	times := []time.Time{
+		time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC),
+		time.Date(2023, 1, 2, 3, 4, 5, 500000000, time.UTC),
+		time.Date(2023, 1, 2, 4, 4, 6, 0, time.FixedZone("CET", 3600)),
+		time.Date(2023, 1, 2, 3, 4, 6, 1, time.UTC),
+	}
+	for i := 1; i < len(times); i++ {
+		before, after := formatTime(times[i-1]), formatTime(times[i])
+		if before >= after {
+			t.Errorf("formatTime() %q should sort before %q", before, after)
+		}
+		parsed, err := time.Parse(time.RFC3339Nano, after)
+		if err != nil || !parsed.Equal(times[i]) {
+			t.Errorf("time.Parse(%q) = %v, %v, want %v", after, parsed, err, times[i])
+		}
+	}
+}
+
+func TestAttestationNodeStatementTime(t *testing.T) {
+	an := AttestationNode{Digest: "sha256:abc"}
+	if _, ok := an.Properties()["statement_time"]; ok {
+		t.Errorf("Properties() has a statement_time without a statement time")
+	}
+	an.StatementTime = time.Date(2023, 1, 2, 4, 4, 5, 0, time.FixedZone("CET", 3600))
+	if got := an.Properties()["statement_time"]; got != "2023-01-02T03:04:05.000000000Z" {
+		t.Errorf("Properties()[statement_time] = %v, want 2023-01-02T03:04:05.000000000Z", got)
+	}
+}
+
+func Test_decodeJSONProperties(t *testing.T) {
+	got, err := decodeJSONProperties([]byte(`{"name":"text","score":7.5,"count":3,"cpes":["a","b"],"sizes":[1,2.5],"fixed":true}`))
+	if err != nil {
+		t.Fatalf("decodeJSONProperties() error = %v", err)
+	}
+	want := map[string]interface{}{
+		"name":  "text",
+		"score": 7.5,
+		"count": int64(3),
+		"cpes":  []interface{}{"a", "b"},
+		"sizes": []interface{}{int64(1), 2.5},
+		"fixed": true,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("decodeJSONProperties() = %#v, want %#v", got, want)
+	}
+	if _, err := decodeJSONProperties([]byte(`[1]`)); err == nil {
+		t.Errorf("decodeJSONProperties() of a list succeeded")
+	}
+}
@@ -0,0 +1,127 @@
+//
+// Copyright 2022 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package assembler
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/guacsec/guac/pkg/assembler/graphdb"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+)
+
+// DefaultPruneLabels are the labels of the nodes pruned by default. These
+// are mostly stub nodes, such as the packages and vulnerabilities referenced
+// by VEX documents, left behind when the documents linking them are removed.
+var DefaultPruneLabels = []string{"Package", "Vulnerability"}
+
+// labelRegex matches the labels and names that can be safely used in a query
+var labelRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// ValidateLabel returns an error if label cannot be used as a node label,
+// relationship type or property name in a query
+func ValidateLabel(label string) error {
+	if !labelRegex.MatchString(label) {
+		return fmt.Errorf("invalid label %q", label)
+	}
+	return nil
+}
+
+// CountOrphanNodes returns the number of nodes with the given label that
+// have no relationships
+func CountOrphanNodes(client graphdb.Client, label string) (int64, error) {
+	query, err := orphanCountQuery(label)
+	if err != nil {
+		return 0, err
+	}
+	session := client.NewSession(neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
+	defer session.Close()
+
+	count, err := session.ReadTransaction(
+		func(tx graphdb.Transaction) (interface{}, error) {
+			return singleCount(tx, query, nil)
+		})
+	if err != nil {
+		return 0, err
+	}
+	return count.(int64), nil
+}
+
+// DeleteOrphanNodes deletes the nodes with the given label that have no
+// relationships and returns the number of deleted nodes. Nodes are deleted
+// batchSize at a time, each batch in its own transaction, so that pruning a
+// large graph does not build up a single huge transaction.
+func DeleteOrphanNodes(client graphdb.Client, label string, batchSize int) (int64, error) {
+	if batchSize <= 0 {
+		return 0, fmt.Errorf("batch size must be positive, got %d", batchSize)
+	}
+	query, err := orphanDeleteQuery(label)
+	if err != nil {
+		return 0, err
+	}
+	session := client.NewSession(neo4j.SessionConfig{})
+	defer session.Close()
+
+	var total int64
+	for {
+		deleted, err := session.WriteTransaction(
+			func(tx graphdb.Transaction) (interface{}, error) {
+				return singleCount(tx, query, map[string]interface{}{"limit": batchSize})
+			})
+		if err != nil {
+			return total, err
+		}
+		total += deleted.(int64)
+		if deleted.(int64) < int64(batchSize) {
+			return total, nil
+		}
+	}
+}
+
+// orphanCountQuery returns the query counting the nodes with label that have
+// no relationships
+func orphanCountQuery(label string) (string, error) {
+	if err := ValidateLabel(label); err != nil {
+		return "", err
+	}
+	return "MATCH (n:" + label + ") WHERE NOT (n)--() RETURN count(n)", nil
+}
+
+// orphanDeleteQuery returns the query deleting up to $limit nodes with label
+// that have no relationships, returning the number of deleted nodes
+func orphanDeleteQuery(label string) (string, error) {
+	if err := ValidateLabel(label); err != nil {
+		return "", err
+	}
+	return "MATCH (n:" + label + ") WHERE NOT (n)--() WITH n LIMIT $limit DELETE n RETURN count(n)", nil
+}
+
+// singleCount runs query and returns the count it returns
+func singleCount(tx graphdb.Transaction, query string, params map[string]interface{}) (int64, error) {
+	result, err := tx.Run(query, params)
+	if err != nil {
+		return 0, err
+	}
+	record, err := result.Single()
+	if err != nil {
+		return 0, err
+	}
+	count, ok := record.Values[0].(int64)
+	if !ok {
+		return 0, fmt.Errorf("unexpected count %v", record.Values[0])
+	}
+	return count, nil
+}
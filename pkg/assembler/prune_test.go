@@ -0,0 +1,59 @@
+//
+// Copyright 2022 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package assembler
+
+import "testing"
+
+func Test_orphanQueries(t *testing.T) {
+	tests := []struct {
+		name       string
+		label      string
+		wantCount  string
+		wantDelete string
+		wantErr    bool
+	}{{
+		name:       "package",
+		label:      "Package",
+		wantCount:  "MATCH (n:Package) WHERE NOT (n)--() RETURN count(n)",
+		wantDelete: "MATCH (n:Package) WHERE NOT (n)--() WITH n LIMIT $limit DELETE n RETURN count(n)",
+	}, {
+		name:    "empty",
+		label:   "",
+		wantErr: true,
+	}, {
+		name:    "injection",
+		label:   "Package) DETACH DELETE n //",
+		wantErr: true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			count, err := orphanCountQuery(tt.label)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("orphanCountQuery() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if count != tt.wantCount {
+				t.Errorf("orphanCountQuery() = %q, want %q", count, tt.wantCount)
+			}
+			del, err := orphanDeleteQuery(tt.label)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("orphanDeleteQuery() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if del != tt.wantDelete {
+				t.Errorf("orphanDeleteQuery() = %q, want %q", del, tt.wantDelete)
+			}
+		})
+	}
+}
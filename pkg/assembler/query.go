@@ -0,0 +1,423 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package assembler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/guacsec/guac/pkg/assembler/graphdb"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+)
+
+// DefaultQueryLimit is the number of nodes returned by a query without limit
+const DefaultQueryLimit = 100
+
+const (
+	// DefaultPathDepth is the number of edges of the longest path searched
+	// by a path query without depth
+	DefaultPathDepth = 10
+	// MaxPathDepth bounds the depth of a path query, as the number of paths
+	// grows exponentially with it
+	MaxPathDepth = 25
+)
+
+// DependencyEdges are the edges followed from a node to the nodes it depends
+// on: its dependencies, the package of an artifact and the artifacts
+// contained in a package
+var DependencyEdges = []string{"DependsOn", "PackageOf", "Contains"}
+
+// StoredNode is a node read from the graph database
+type StoredNode struct {
+	// ID is the database id of the node, which may be reused once the node
+	// is deleted
+	ID         int64                  `json:"id"`
+	Label      string                 `json:"label"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+// Neighbor is a node linked to another one by an edge
+type Neighbor struct {
+	Edge           string
+	EdgeProperties map[string]interface{}
+	Node           StoredNode
+}
+
+// Path is a path of the graph, Edges[i] being the label of the edge from
+// Nodes[i] to Nodes[i+1]
+type Path struct {
+	Nodes []StoredNode `json:"nodes"`
+	Edges []string     `json:"edges"`
+}
+
+// Direction is the direction of the edges followed from a node
+type Direction string
+
+const (
+	// DirectionOut follows the edges starting at the node
+	DirectionOut Direction = "out"
+	// DirectionIn follows the edges ending at the node
+	DirectionIn Direction = "in"
+)
+
+// NodeFilter selects nodes by label and by the values identifying them. Empty
+// fields match any node.
+type NodeFilter struct {
+	Label string
+	// Purl is the purl of a package
+	Purl string
+	// Digest is any of the digests of an artifact or a package, or the
+	// digest of an attestation or an identity
+	Digest string
+	// PredicateType is the type of an attestation
+	PredicateType string
+	// ID is the ID of a builder, or the ID or any of the aliases of a
+	// vulnerability
+	ID string
+	// Source is the source URI or the source of a document
+	Source string
+	// Limit is the maximum number of nodes returned, DefaultQueryLimit if
+	// not positive
+	Limit int
+}
+
+// GraphReader reads the nodes of the graph and follows their edges, see
+// FindNodes, Neighbors, Paths and RootPaths
+type GraphReader interface {
+	FindNodes(filter NodeFilter) ([]StoredNode, error)
+	Neighbors(id int64, edge string, dir Direction, label string, limit int) ([]Neighbor, error)
+	Paths(from int64, to int64, edges []string, depth int, limit int) ([]Path, error)
+	RootPaths(id int64, edges []string, depth int, limit int) ([]Path, error)
+}
+
+type clientReader struct {
+	client graphdb.Client
+}
+
+// NewGraphReader returns a GraphReader of the graph database of client
+func NewGraphReader(client graphdb.Client) GraphReader {
+	return clientReader{client: client}
+}
+
+func (r clientReader) FindNodes(filter NodeFilter) ([]StoredNode, error) {
+	return FindNodes(r.client, filter)
+}
+
+func (r clientReader) Neighbors(id int64, edge string, dir Direction, label string, limit int) ([]Neighbor, error) {
+	return Neighbors(r.client, id, edge, dir, label, limit)
+}
+
+func (r clientReader) Paths(from int64, to int64, edges []string, depth int, limit int) ([]Path, error) {
+	return Paths(r.client, from, to, edges, depth, limit)
+}
+
+func (r clientReader) RootPaths(id int64, edges []string, depth int, limit int) ([]Path, error) {
+	return RootPaths(r.client, id, edges, depth, limit)
+}
+
+// FindNodes returns the nodes selected by filter
+func FindNodes(client graphdb.Client, filter NodeFilter) ([]StoredNode, error) {
+	query, params, err := findNodesQuery(filter)
+	if err != nil {
+		return nil, err
+	}
+	session := client.NewSession(neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
+	defer session.Close()
+
+	nodes, err := session.ReadTransaction(
+		func(tx graphdb.Transaction) (interface{}, error) {
+			result, err := tx.Run(query, params)
+			if err != nil {
+				return nil, err
+			}
+			nodes := []StoredNode{}
+			for result.Next() {
+				if n, ok := result.Record().Values[0].(neo4j.Node); ok {
+					nodes = append(nodes, storedNode(n))
+				}
+			}
+			return nodes, result.Err()
+		})
+	if err != nil {
+		return nil, err
+	}
+	return nodes.([]StoredNode), nil
+}
+
+// Neighbors returns the nodes linked to the node with database id id by the
+// edges labeled edge in direction dir, limited to the nodes labeled label.
+// Empty labels match any edge or node.
+func Neighbors(client graphdb.Client, id int64, edge string, dir Direction, label string, limit int) ([]Neighbor, error) {
+	query, err := neighborsQuery(edge, dir, label)
+	if err != nil {
+		return nil, err
+	}
+	session := client.NewSession(neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
+	defer session.Close()
+
+	neighbors, err := session.ReadTransaction(
+		func(tx graphdb.Transaction) (interface{}, error) {
+			result, err := tx.Run(query, map[string]interface{}{"id": id, "limit": queryLimit(limit)})
+			if err != nil {
+				return nil, err
+			}
+			neighbors := []Neighbor{}
+			for result.Next() {
+				r, ok := result.Record().Values[0].(neo4j.Relationship)
+				if !ok {
+					continue
+				}
+				n, ok := result.Record().Values[1].(neo4j.Node)
+				if !ok {
+					continue
+				}
+				neighbors = append(neighbors, Neighbor{Edge: r.Type, EdgeProperties: r.Props, Node: storedNode(n)})
+			}
+			return neighbors, result.Err()
+		})
+	if err != nil {
+		return nil, err
+	}
+	return neighbors.([]Neighbor), nil
+}
+
+// Paths returns the shortest paths first from the node with database id from
+// to the node with database id to, following the edges labeled edges in their
+// direction, of at most depth edges. The traversal runs in the database, and
+// never follows an edge twice in a path. Empty edges match any edge, and
+// depth defaults to DefaultPathDepth if not positive.
+func Paths(client graphdb.Client, from int64, to int64, edges []string, depth int, limit int) ([]Path, error) {
+	query, err := pathsQuery(edges, depth)
+	if err != nil {
+		return nil, err
+	}
+	return readPaths(client, query, map[string]interface{}{"from": from, "to": to, "limit": queryLimit(limit)})
+}
+
+// RootPaths returns the shortest paths first to the node with database id id
+// from the root nodes reaching it, following the edges labeled edges in their
+// direction. A root node is the end of none of the edges, e.g. a product that
+// nothing depends on along DependencyEdges, and is only found if it is at
+// most depth edges away. Empty edges match any edge, and depth defaults to
+// DefaultPathDepth if not positive.
+func RootPaths(client graphdb.Client, id int64, edges []string, depth int, limit int) ([]Path, error) {
+	query, err := rootPathsQuery(edges, depth)
+	if err != nil {
+		return nil, err
+	}
+	return readPaths(client, query, map[string]interface{}{"id": id, "limit": queryLimit(limit)})
+}
+
+func readPaths(client graphdb.Client, query string, params map[string]interface{}) ([]Path, error) {
+	session := client.NewSession(neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
+	defer session.Close()
+
+	paths, err := session.ReadTransaction(
+		func(tx graphdb.Transaction) (interface{}, error) {
+			result, err := tx.Run(query, params)
+			if err != nil {
+				return nil, err
+			}
+			paths := []Path{}
+			for result.Next() {
+				if p, ok := result.Record().Values[0].(neo4j.Path); ok {
+					paths = append(paths, storedPath(p))
+				}
+			}
+			return paths, result.Err()
+		})
+	if err != nil {
+		return nil, err
+	}
+	return paths.([]Path), nil
+}
+
+func findNodesQuery(filter NodeFilter) (string, map[string]interface{}, error) {
+	if err := ValidateLabel(filter.Label); err != nil {
+		return "", nil, err
+	}
+	conditions := []string{}
+	params := map[string]interface{}{"limit": queryLimit(filter.Limit)}
+	if filter.Purl != "" {
+		conditions = append(conditions, "n.purl = $purl")
+		params["purl"] = filter.Purl
+	}
+	if filter.Digest != "" {
+		d := strings.ToLower(filter.Digest)
+		params["digest"] = d
+		switch filter.Label {
+		case "Artifact":
+			// alternate digests are kept under their algorithm
+			if name := digestPropertyName(d); name != "" {
+				conditions = append(conditions, "(n.digest = $digest OR n."+name+" = $digest)")
+			} else {
+				conditions = append(conditions, "n.digest = $digest")
+			}
+		case "Package":
+			conditions = append(conditions, "$digest IN n.digest")
+		default:
+			conditions = append(conditions, "n.digest = $digest")
+		}
+	}
+	if filter.PredicateType != "" {
+		conditions = append(conditions, "n.attestation_type = $predicate_type")
+		params["predicate_type"] = filter.PredicateType
+	}
+	if filter.ID != "" {
+		if filter.Label == "Vulnerability" {
+			conditions = append(conditions, "(n.id = $id OR $id IN n.aliases)")
+		} else {
+			conditions = append(conditions, "n.id = $id")
+		}
+		params["id"] = filter.ID
+	}
+	if filter.Source != "" {
+		conditions = append(conditions, "(n.source_uri = $source OR n.source = $source)")
+		params["source"] = filter.Source
+	}
+
+	var sb strings.Builder
+	sb.WriteString("MATCH (n:")
+	sb.WriteString(filter.Label)
+	sb.WriteString(")")
+	if len(conditions) > 0 {
+		sb.WriteString(" WHERE ")
+		sb.WriteString(strings.Join(conditions, " AND "))
+	}
+	sb.WriteString(" RETURN n ORDER BY id(n) LIMIT $limit")
+	return sb.String(), params, nil
+}
+
+func neighborsQuery(edge string, dir Direction, label string) (string, error) {
+	edgePart := "[r]"
+	if edge != "" {
+		if err := ValidateLabel(edge); err != nil {
+			return "", err
+		}
+		edgePart = "[r:" + edge + "]"
+	}
+	nodePart := "(m)"
+	if label != "" {
+		if err := ValidateLabel(label); err != nil {
+			return "", err
+		}
+		nodePart = "(m:" + label + ")"
+	}
+	var pattern string
+	switch dir {
+	case DirectionOut:
+		pattern = "(n)-" + edgePart + "->" + nodePart
+	case DirectionIn:
+		pattern = "(n)<-" + edgePart + "-" + nodePart
+	default:
+		return "", fmt.Errorf("invalid direction %q", dir)
+	}
+	return "MATCH " + pattern + " WHERE id(n) = $id RETURN r, m ORDER BY id(m) LIMIT $limit", nil
+}
+
+func pathsQuery(edges []string, depth int) (string, error) {
+	edgeTypes, depth, err := pathEdges(edges, depth)
+	if err != nil {
+		return "", err
+	}
+	return "MATCH p = (a)-[" + edgeTypes + "*1.." + strconv.Itoa(depth) + "]->(b) WHERE id(a) = $from AND id(b) = $to RETURN p ORDER BY length(p) LIMIT $limit", nil
+}
+
+func rootPathsQuery(edges []string, depth int) (string, error) {
+	edgeTypes, depth, err := pathEdges(edges, depth)
+	if err != nil {
+		return "", err
+	}
+	return "MATCH p = (root)-[" + edgeTypes + "*0.." + strconv.Itoa(depth) + "]->(n) WHERE id(n) = $id AND NOT ()-[" + edgeTypes + "]->(root) RETURN p ORDER BY length(p) LIMIT $limit", nil
+}
+
+// pathEdges returns the edge types pattern of edges, and the depth of a path
+// query
+func pathEdges(edges []string, depth int) (string, int, error) {
+	for _, e := range edges {
+		if err := ValidateLabel(e); err != nil {
+			return "", 0, err
+		}
+	}
+	if depth <= 0 {
+		depth = DefaultPathDepth
+	}
+	if depth > MaxPathDepth {
+		return "", 0, fmt.Errorf("path depth %d is larger than %d", depth, MaxPathDepth)
+	}
+	if len(edges) == 0 {
+		return "", depth, nil
+	}
+	return ":" + strings.Join(edges, "|"), depth, nil
+}
+
+func queryLimit(limit int) int {
+	if limit <= 0 {
+		return DefaultQueryLimit
+	}
+	return limit
+}
+
+func storedNode(n neo4j.Node) StoredNode {
+	label := ""
+	if len(n.Labels) > 0 {
+		label = n.Labels[0]
+	}
+	return StoredNode{ID: n.Id, Label: label, Properties: n.Props}
+}
+
+func storedPath(p neo4j.Path) Path {
+	path := Path{Nodes: make([]StoredNode, 0, len(p.Nodes)), Edges: make([]string, 0, len(p.Relationships))}
+	for _, n := range p.Nodes {
+		path.Nodes = append(path.Nodes, storedNode(n))
+	}
+	for _, r := range p.Relationships {
+		path.Edges = append(path.Edges, r.Type)
+	}
+	return path
+}
+
+// String returns the nodes and edges of the path, e.g.
+// `Artifact sha256:ab -PackageOf-> Package pkg:oci/app -DependsOn-> Package pkg:golang/foo@v1`
+func (p Path) String() string {
+	var sb strings.Builder
+	for i, n := range p.Nodes {
+		if i > 0 && i <= len(p.Edges) {
+			sb.WriteString(" -" + p.Edges[i-1] + "-> ")
+		}
+		sb.WriteString(n.String())
+	}
+	return sb.String()
+}
+
+// String returns the label and the identifying values of the node, e.g.
+// `Package pkg:golang/foo@v1`
+func (n StoredNode) String() string {
+	for _, key := range []string{"purl", "digest", "id"} {
+		switch v := n.Properties[key].(type) {
+		case string:
+			if v != "" {
+				return n.Label + " " + v
+			}
+		case []interface{}:
+			if len(v) > 0 {
+				return n.Label + " " + fmt.Sprint(v[0])
+			}
+		}
+	}
+	return n.Label + " " + strconv.FormatInt(n.ID, 10)
+}
@@ -0,0 +1,205 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package assembler
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_findNodesQuery(t *testing.T) {
+	tests := []struct {
+		name       string
+		filter     NodeFilter
+		wantQuery  string
+		wantParams map[string]interface{}
+		wantErr    bool
+	}{{
+		name:       "all nodes of a label",
+		filter:     NodeFilter{Label: "Vulnerability"},
+		wantQuery:  "MATCH (n:Vulnerability) RETURN n ORDER BY id(n) LIMIT $limit",
+		wantParams: map[string]interface{}{"limit": DefaultQueryLimit},
+	}, {
+		name:       "package by purl and digest",
+		filter:     NodeFilter{Label: "Package", Purl: "pkg:golang/foo@v1", Digest: "SHA256:AB", Limit: 5},
+		wantQuery:  "MATCH (n:Package) WHERE n.purl = $purl AND $digest IN n.digest RETURN n ORDER BY id(n) LIMIT $limit",
+		wantParams: map[string]interface{}{"limit": 5, "purl": "pkg:golang/foo@v1", "digest": "sha256:ab"},
+	}, {
+		name:       "artifact by alternate digest",
+		filter:     NodeFilter{Label: "Artifact", Digest: "sha512:cd"},
+		wantQuery:  "MATCH (n:Artifact) WHERE (n.digest = $digest OR n.digest_sha512 = $digest) RETURN n ORDER BY id(n) LIMIT $limit",
+		wantParams: map[string]interface{}{"limit": DefaultQueryLimit, "digest": "sha512:cd"},
+	}, {
+		name:       "attestation by predicate type",
+		filter:     NodeFilter{Label: "Attestation", PredicateType: "https://slsa.dev/provenance/v0.2"},
+		wantQuery:  "MATCH (n:Attestation) WHERE n.attestation_type = $predicate_type RETURN n ORDER BY id(n) LIMIT $limit",
+		wantParams: map[string]interface{}{"limit": DefaultQueryLimit, "predicate_type": "https://slsa.dev/provenance/v0.2"},
+	}, {
+		name:       "vulnerability by id or alias",
+		filter:     NodeFilter{Label: "Vulnerability", ID: "CVE-2022-32149"},
+		wantQuery:  "MATCH (n:Vulnerability) WHERE (n.id = $id OR $id IN n.aliases) RETURN n ORDER BY id(n) LIMIT $limit",
+		wantParams: map[string]interface{}{"limit": DefaultQueryLimit, "id": "CVE-2022-32149"},
+	}, {
+		name:       "documents by source",
+		filter:     NodeFilter{Label: "Document", Source: "oci://app"},
+		wantQuery:  "MATCH (n:Document) WHERE (n.source_uri = $source OR n.source = $source) RETURN n ORDER BY id(n) LIMIT $limit",
+		wantParams: map[string]interface{}{"limit": DefaultQueryLimit, "source": "oci://app"},
+	}, {
+		name:    "injection in label",
+		filter:  NodeFilter{Label: "Package) DETACH DELETE n //"},
+		wantErr: true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, params, err := findNodesQuery(tt.filter)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("findNodesQuery() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if query != tt.wantQuery {
+				t.Errorf("findNodesQuery() query = %q, want %q", query, tt.wantQuery)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(params, tt.wantParams) {
+				t.Errorf("findNodesQuery() params = %v, want %v", params, tt.wantParams)
+			}
+		})
+	}
+}
+
+func Test_neighborsQuery(t *testing.T) {
+	tests := []struct {
+		name    string
+		edge    string
+		dir     Direction
+		label   string
+		want    string
+		wantErr bool
+	}{{
+		name: "any edge out",
+		dir:  DirectionOut,
+		want: "MATCH (n)-[r]->(m) WHERE id(n) = $id RETURN r, m ORDER BY id(m) LIMIT $limit",
+	}, {
+		name:  "labeled edge in",
+		edge:  "PackageOf",
+		dir:   DirectionIn,
+		label: "Artifact",
+		want:  "MATCH (n)<-[r:PackageOf]-(m:Artifact) WHERE id(n) = $id RETURN r, m ORDER BY id(m) LIMIT $limit",
+	}, {
+		name:    "invalid direction",
+		dir:     "both",
+		wantErr: true,
+	}, {
+		name:    "injection in edge",
+		edge:    "DependsOn]-() DETACH DELETE n //",
+		dir:     DirectionOut,
+		wantErr: true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := neighborsQuery(tt.edge, tt.dir, tt.label)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("neighborsQuery() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("neighborsQuery() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_pathsQuery(t *testing.T) {
+	tests := []struct {
+		name    string
+		edges   []string
+		depth   int
+		want    string
+		wantErr bool
+	}{{
+		name: "any edge with default depth",
+		want: "MATCH p = (a)-[*1..10]->(b) WHERE id(a) = $from AND id(b) = $to RETURN p ORDER BY length(p) LIMIT $limit",
+	}, {
+		name:  "dependency edges",
+		edges: DependencyEdges,
+		depth: 3,
+		want:  "MATCH p = (a)-[:DependsOn|PackageOf|Contains*1..3]->(b) WHERE id(a) = $from AND id(b) = $to RETURN p ORDER BY length(p) LIMIT $limit",
+	}, {
+		name:    "too deep",
+		depth:   MaxPathDepth + 1,
+		wantErr: true,
+	}, {
+		name:    "injection in edge",
+		edges:   []string{"DependsOn*]->() DETACH DELETE a //"},
+		wantErr: true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := pathsQuery(tt.edges, tt.depth)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("pathsQuery() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("pathsQuery() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_rootPathsQuery(t *testing.T) {
+	tests := []struct {
+		name    string
+		edges   []string
+		depth   int
+		want    string
+		wantErr bool
+	}{{
+		name: "any edge with default depth",
+		want: "MATCH p = (root)-[*0..10]->(n) WHERE id(n) = $id AND NOT ()-[]->(root) RETURN p ORDER BY length(p) LIMIT $limit",
+	}, {
+		name:  "dependency edges",
+		edges: DependencyEdges,
+		depth: 5,
+		want:  "MATCH p = (root)-[:DependsOn|PackageOf|Contains*0..5]->(n) WHERE id(n) = $id AND NOT ()-[:DependsOn|PackageOf|Contains]->(root) RETURN p ORDER BY length(p) LIMIT $limit",
+	}, {
+		name:    "too deep",
+		depth:   MaxPathDepth + 1,
+		wantErr: true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := rootPathsQuery(tt.edges, tt.depth)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("rootPathsQuery() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("rootPathsQuery() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPath_String(t *testing.T) {
+	p := Path{
+		Nodes: []StoredNode{
+			{ID: 1, Label: "Artifact", Properties: map[string]interface{}{"digest": "sha256:ab"}},
+			{ID: 2, Label: "Package", Properties: map[string]interface{}{"purl": "pkg:oci/app"}},
+			{ID: 3, Label: "Package", Properties: map[string]interface{}{"purl": "pkg:golang/foo@v1"}},
+		},
+		Edges: []string{"PackageOf", "DependsOn"},
+	}
+	want := "Artifact sha256:ab -PackageOf-> Package pkg:oci/app -DependsOn-> Package pkg:golang/foo@v1"
+	if got := p.String(); got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,288 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package assembler
+
+import (
+	"errors"
+	"strings"
+)
+
+// ErrNodeNotFound is returned when the node asked about is not in the graph
+var ErrNodeNotFound = errors.New("node not found")
+
+// ErrEdgeNotFound is returned when the edge asked about is not in the graph
+var ErrEdgeNotFound = errors.New("edge not found")
+
+// evidenceScanLimit bounds the edges of a node read to find the edge whose
+// evidence is asked about
+const evidenceScanLimit = 10000
+
+// Provenance is an attestation about a node, with the builders of the build
+// it describes and the identities that signed it
+type Provenance struct {
+	Attestation StoredNode   `json:"attestation"`
+	Builders    []StoredNode `json:"builders"`
+	Signers     []StoredNode `json:"signers"`
+}
+
+// AffectingVulnerability is a vulnerability affecting a node. Edge holds the
+// properties of the VEX statement it was found in, if any, and Attestation
+// is the scan that found it otherwise.
+type AffectingVulnerability struct {
+	Vulnerability StoredNode             `json:"vulnerability"`
+	Edge          map[string]interface{} `json:"edge,omitempty"`
+	Attestation   *StoredNode            `json:"attestation,omitempty"`
+}
+
+// FindSubject returns the package with purl key, or the artifact with digest
+// key if key is not a purl. It returns ErrNodeNotFound if there is none.
+func FindSubject(reader GraphReader, key string) (StoredNode, error) {
+	filter := NodeFilter{Label: "Artifact", Digest: key, Limit: 1}
+	if strings.HasPrefix(key, "pkg:") {
+		filter = NodeFilter{Label: "Package", Purl: key, Limit: 1}
+	}
+	nodes, err := reader.FindNodes(filter)
+	if err != nil {
+		return StoredNode{}, err
+	}
+	if len(nodes) == 0 {
+		return StoredNode{}, ErrNodeNotFound
+	}
+	return nodes[0], nil
+}
+
+// NeighborNodes returns the nodes with label linked to the node id by edge,
+// in direction dir
+func NeighborNodes(reader GraphReader, id int64, edge string, dir Direction, label string, limit int) ([]StoredNode, error) {
+	neighbors, err := reader.Neighbors(id, edge, dir, label, limit)
+	if err != nil {
+		return nil, err
+	}
+	nodes := make([]StoredNode, 0, len(neighbors))
+	for _, m := range neighbors {
+		nodes = append(nodes, m.Node)
+	}
+	return nodes, nil
+}
+
+// Dependencies returns the packages, or the artifacts, n directly depends on
+func Dependencies(reader GraphReader, n StoredNode, limit int) ([]StoredNode, error) {
+	return NeighborNodes(reader, n.ID, "DependsOn", DirectionOut, n.Label, limit)
+}
+
+// Dependents returns the packages, or the artifacts, directly depending on n
+func Dependents(reader GraphReader, n StoredNode, limit int) ([]StoredNode, error) {
+	return NeighborNodes(reader, n.ID, "DependsOn", DirectionIn, n.Label, limit)
+}
+
+// NodeProvenance returns the attestations about n, with the builders and
+// signers of each
+func NodeProvenance(reader GraphReader, n StoredNode, limit int) ([]Provenance, error) {
+	attestations, err := NeighborNodes(reader, n.ID, "Attestation", DirectionIn, "Attestation", limit)
+	if err != nil {
+		return nil, err
+	}
+	provenances := []Provenance{}
+	for _, a := range attestations {
+		p := Provenance{Attestation: a}
+		if p.Builders, err = NeighborNodes(reader, a.ID, "BuiltBy", DirectionOut, "Builder", limit); err != nil {
+			return nil, err
+		}
+		if p.Signers, err = NeighborNodes(reader, a.ID, "Identity", DirectionIn, "Identity", limit); err != nil {
+			return nil, err
+		}
+		provenances = append(provenances, p)
+	}
+	return provenances, nil
+}
+
+// NodeVulnerabilities returns the vulnerabilities affecting n according to
+// VEX statements, and the ones found by the attestations about it
+func NodeVulnerabilities(reader GraphReader, n StoredNode, limit int) ([]AffectingVulnerability, error) {
+	vulns := []AffectingVulnerability{}
+	affects, err := reader.Neighbors(n.ID, "Affects", DirectionIn, "Vulnerability", limit)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range affects {
+		vulns = append(vulns, AffectingVulnerability{Vulnerability: v.Node, Edge: v.EdgeProperties})
+	}
+	attestations, err := NeighborNodes(reader, n.ID, "Attestation", DirectionIn, "Attestation", limit)
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range attestations {
+		a := a
+		found, err := NeighborNodes(reader, a.ID, "Vulnerable", DirectionOut, "Vulnerability", limit)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range found {
+			vulns = append(vulns, AffectingVulnerability{Vulnerability: v, Attestation: &a})
+		}
+	}
+	return vulns, nil
+}
+
+// unaffectedStates are the analysis states of the VEX statements that a
+// package or an artifact is not, or no longer, affected by a vulnerability
+var unaffectedStates = map[string]bool{
+	"not_affected":           true,
+	"false_positive":         true,
+	"fixed":                  true,
+	"resolved":               true,
+	"resolved_with_pedigree": true,
+}
+
+// BlastRadius is what a vulnerability affects, directly or through the
+// dependencies of the nodes of the graph
+type BlastRadius struct {
+	// Vulnerabilities are the vulnerabilities with the ID or the alias asked
+	// about
+	Vulnerabilities []StoredNode `json:"vulnerabilities"`
+	// Affected are the packages and artifacts the vulnerabilities affect
+	// according to advisories, VEX statements or scans
+	Affected []StoredNode `json:"affected"`
+	// Artifacts are the affected artifacts, and the artifacts depending on
+	// the affected nodes
+	Artifacts []StoredNode `json:"artifacts"`
+	// Products are the root nodes depending on the affected nodes
+	Products []AffectedProduct `json:"products"`
+}
+
+// AffectedProduct is a product depending on nodes affected by a
+// vulnerability, through the dependency chains from the product to each
+type AffectedProduct struct {
+	Product StoredNode `json:"product"`
+	Chains  []Path     `json:"chains"`
+}
+
+// VulnerabilityBlastRadius returns the packages and artifacts affected by the
+// vulnerability with ID or alias id, and the products and artifacts depending
+// on them along DependencyEdges, at most depth edges away. It returns
+// ErrNodeNotFound if there is no such vulnerability.
+func VulnerabilityBlastRadius(reader GraphReader, id string, depth int, limit int) (BlastRadius, error) {
+	radius := BlastRadius{Affected: []StoredNode{}, Artifacts: []StoredNode{}, Products: []AffectedProduct{}}
+	var err error
+	radius.Vulnerabilities, err = reader.FindNodes(NodeFilter{Label: "Vulnerability", ID: id, Limit: limit})
+	if err != nil {
+		return radius, err
+	}
+	if len(radius.Vulnerabilities) == 0 {
+		return radius, ErrNodeNotFound
+	}
+
+	seen := map[int64]bool{}
+	addAffected := func(n StoredNode) {
+		if !seen[n.ID] {
+			seen[n.ID] = true
+			radius.Affected = append(radius.Affected, n)
+		}
+	}
+	for _, v := range radius.Vulnerabilities {
+		affects, err := reader.Neighbors(v.ID, "Affects", DirectionOut, "", limit)
+		if err != nil {
+			return radius, err
+		}
+		for _, a := range affects {
+			if state, _ := a.EdgeProperties["analysis_state"].(string); !unaffectedStates[state] {
+				addAffected(a.Node)
+			}
+		}
+		scans, err := NeighborNodes(reader, v.ID, "Vulnerable", DirectionIn, "Attestation", limit)
+		if err != nil {
+			return radius, err
+		}
+		for _, scan := range scans {
+			subjects, err := NeighborNodes(reader, scan.ID, "Attestation", DirectionOut, "", limit)
+			if err != nil {
+				return radius, err
+			}
+			for _, s := range subjects {
+				addAffected(s)
+			}
+		}
+	}
+
+	artifacts := map[int64]bool{}
+	addArtifact := func(n StoredNode) {
+		if n.Label == "Artifact" && !artifacts[n.ID] {
+			artifacts[n.ID] = true
+			radius.Artifacts = append(radius.Artifacts, n)
+		}
+	}
+	products := map[int64]int{}
+	for _, n := range radius.Affected {
+		addArtifact(n)
+		paths, err := reader.RootPaths(n.ID, DependencyEdges, depth, limit)
+		if err != nil {
+			return radius, err
+		}
+		for _, p := range paths {
+			for _, m := range p.Nodes {
+				addArtifact(m)
+			}
+			root := p.Nodes[0]
+			i, ok := products[root.ID]
+			if !ok {
+				i = len(radius.Products)
+				products[root.ID] = i
+				radius.Products = append(radius.Products, AffectedProduct{Product: root})
+			}
+			radius.Products[i].Chains = append(radius.Products[i].Chains, p)
+		}
+	}
+	return radius, nil
+}
+
+// EdgeEvidence returns the documents asserting the edge with label edge from
+// the node from to the node to, as recorded in its `documents` property,
+// with at most limit documents. Documents recorded without a Document node
+// are skipped. It returns ErrEdgeNotFound if there is no such edge.
+func EdgeEvidence(reader GraphReader, from StoredNode, to StoredNode, edge string, limit int) ([]StoredNode, error) {
+	neighbors, err := reader.Neighbors(from.ID, edge, DirectionOut, to.Label, evidenceScanLimit)
+	if err != nil {
+		return nil, err
+	}
+	var digests []interface{}
+	found := false
+	for _, m := range neighbors {
+		if m.Node.ID == to.ID {
+			digests, _ = m.EdgeProperties["documents"].([]interface{})
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil, ErrEdgeNotFound
+	}
+	docs := []StoredNode{}
+	for _, d := range digests {
+		if len(docs) >= queryLimit(limit) {
+			break
+		}
+		digest, ok := d.(string)
+		if !ok {
+			continue
+		}
+		nodes, err := reader.FindNodes(NodeFilter{Label: "Document", Digest: digest, Limit: 1})
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, nodes...)
+	}
+	return docs, nil
+}
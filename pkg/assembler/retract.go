@@ -0,0 +1,134 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package assembler
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/guacsec/guac/pkg/assembler/graphdb"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+)
+
+// Retraction counts the nodes and edges changed by retracting a document.
+// The nodes and edges only asserted by the document are deleted, a node
+// being kept while edges still link it, until retracting the documents of
+// these edges deletes them. The document is removed from the `documents`
+// property of the others, which stay asserted by other documents. Nodes and
+// edges stored before documents were recorded are left untouched.
+type Retraction struct {
+	DeletedNodes  int64 `json:"deleted_nodes"`
+	DeletedEdges  int64 `json:"deleted_edges"`
+	RetainedNodes int64 `json:"retained_nodes"`
+	RetainedEdges int64 `json:"retained_edges"`
+}
+
+func (r Retraction) String() string {
+	return fmt.Sprintf("deleted %d nodes and %d edges, retained %d nodes and %d edges",
+		r.DeletedNodes, r.DeletedEdges, r.RetainedNodes, r.RetainedEdges)
+}
+
+// The edges are retracted before the nodes, so that the nodes only linked
+// by retracted edges are deleted. Each query returns the number of deleted
+// and retained nodes or edges, the edge query also returning the endpoints
+// of the deleted edges, so that the ones kept without documents by an
+// earlier retraction are deleted once unlinked.
+const (
+	neo4jCountDocument = "MATCH (d:Document {digest: $digest}) RETURN count(d)"
+	neo4jRetractEdges  = `MATCH (a)-[e]->(b) WHERE $digest IN e.documents
+SET e.documents = [d IN e.documents WHERE d <> $digest]
+WITH a, b, e, size(e.documents) = 0 AS retracted
+FOREACH (_ IN CASE WHEN retracted THEN [1] ELSE [] END | DELETE e)
+RETURN count(CASE WHEN retracted THEN 1 END), count(CASE WHEN NOT retracted THEN 1 END),
+collect(CASE WHEN retracted THEN id(a) END) + collect(CASE WHEN retracted THEN id(b) END)`
+	neo4jRetractNodes = `MATCH (n) WHERE $digest IN n.documents
+SET n.documents = [d IN n.documents WHERE d <> $digest]
+WITH n, size(n.documents) = 0 AND size((n)--()) = 0 AS retracted
+FOREACH (_ IN CASE WHEN retracted THEN [1] ELSE [] END | DELETE n)
+RETURN count(CASE WHEN retracted THEN 1 END), count(CASE WHEN NOT retracted THEN 1 END)`
+	neo4jDeleteOrphans = `MATCH (n) WHERE id(n) IN $endpoints AND size(n.documents) = 0 AND size((n)--()) = 0
+DELETE n
+RETURN count(n)`
+	neo4jDeleteDocument = "MATCH (d:Document {digest: $digest}) DETACH DELETE d"
+)
+
+// RetractDocument retracts the document with digest from the graph of
+// client in a single transaction, see Retraction. It returns ErrNodeNotFound
+// if the document was not ingested.
+func RetractDocument(_ context.Context, client graphdb.Client, digest string) (Retraction, error) {
+	params := map[string]interface{}{"digest": strings.ToLower(digest)}
+	session := client.NewSession(neo4j.SessionConfig{})
+	defer session.Close()
+
+	retraction, err := session.WriteTransaction(
+		func(tx graphdb.Transaction) (interface{}, error) {
+			var r Retraction
+			count, err := singleCount(tx, neo4jCountDocument, params)
+			if err != nil {
+				return nil, err
+			}
+			if count == 0 {
+				return nil, ErrNodeNotFound
+			}
+			var endpoints []interface{}
+			if r.DeletedEdges, r.RetainedEdges, endpoints, err = retractCounts(tx, neo4jRetractEdges, params); err != nil {
+				return nil, err
+			}
+			if r.DeletedNodes, r.RetainedNodes, _, err = retractCounts(tx, neo4jRetractNodes, params); err != nil {
+				return nil, err
+			}
+			if len(endpoints) > 0 {
+				orphans, err := singleCount(tx, neo4jDeleteOrphans, map[string]interface{}{"endpoints": endpoints[0]})
+				if err != nil {
+					return nil, err
+				}
+				r.DeletedNodes += orphans
+			}
+			result, err := tx.Run(neo4jDeleteDocument, params)
+			if err != nil {
+				return nil, err
+			}
+			_, err = result.Consume()
+			return r, err
+		})
+	if err != nil {
+		return Retraction{}, err
+	}
+	return retraction.(Retraction), nil
+}
+
+// retractCounts runs a retraction query and returns the deleted and
+// retained counts it returns, followed by its other values
+func retractCounts(tx graphdb.Transaction, query string, params map[string]interface{}) (int64, int64, []interface{}, error) {
+	result, err := tx.Run(query, params)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	record, err := result.Single()
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	deleted, ok := record.Values[0].(int64)
+	if !ok {
+		return 0, 0, nil, fmt.Errorf("unexpected count %v", record.Values[0])
+	}
+	retained, ok := record.Values[1].(int64)
+	if !ok {
+		return 0, 0, nil, fmt.Errorf("unexpected count %v", record.Values[1])
+	}
+	return deleted, retained, record.Values[2:], nil
+}
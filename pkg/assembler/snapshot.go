@@ -0,0 +1,296 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package assembler
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// SnapshotVersion is the version of the snapshot format written by
+// WriteSnapshot
+const SnapshotVersion = 1
+
+// Snapshot is the set of nodes and edges a graph writes to the graph
+// database. Elements are keyed and sorted so that the JSON encoding of a
+// snapshot is stable and can be kept under version control.
+type Snapshot struct {
+	Version int            `json:"version"`
+	Nodes   []SnapshotNode `json:"nodes"`
+	Edges   []SnapshotEdge `json:"edges"`
+}
+
+// SnapshotNode is a node of a Snapshot, keyed by its type and identifiable
+// properties (e.g. `Package{purl=pkg:golang/foo@v1}`)
+type SnapshotNode struct {
+	Key        string                 `json:"key"`
+	Type       string                 `json:"type"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+// SnapshotEdge is an edge of a Snapshot, keyed by its type and the keys of
+// its endpoints, matching how edges are merged in the graph database
+type SnapshotEdge struct {
+	Key        string                 `json:"key"`
+	Type       string                 `json:"type"`
+	Source     string                 `json:"source"`
+	Target     string                 `json:"target"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+// NewSnapshot returns the snapshot of g. Nodes and edges written more than
+// once are merged, with the properties written last taking precedence as
+// they do in the graph database.
+func NewSnapshot(g Graph) (Snapshot, error) {
+	nodes := map[string]SnapshotNode{}
+	addNode := func(n GuacNode) (string, error) {
+		key := nodeKey(n)
+		props, err := normalizeProperties(n.Properties())
+		if err != nil {
+			return "", fmt.Errorf("unable to snapshot %s: %w", key, err)
+		}
+		if existing, ok := nodes[key]; ok {
+			for k, v := range props {
+				existing.Properties[k] = v
+			}
+			return key, nil
+		}
+		nodes[key] = SnapshotNode{Key: key, Type: n.Type(), Properties: props}
+		return key, nil
+	}
+
+	for _, n := range g.Nodes {
+		if _, err := addNode(n); err != nil {
+			return Snapshot{}, err
+		}
+	}
+	edges := map[string]SnapshotEdge{}
+	for _, e := range g.Edges {
+		if _, ok := e.(MatchingEdge); ok {
+			continue
+		}
+		v, u := e.Nodes()
+		source, err := addNode(v)
+		if err != nil {
+			return Snapshot{}, err
+		}
+		target, err := addNode(u)
+		if err != nil {
+			return Snapshot{}, err
+		}
+		key := source + " -" + e.Type() + "-> " + target
+		props, err := normalizeProperties(e.Properties())
+		if err != nil {
+			return Snapshot{}, fmt.Errorf("unable to snapshot %s: %w", key, err)
+		}
+		if existing, ok := edges[key]; ok {
+			for k, v := range props {
+				existing.Properties[k] = v
+			}
+			continue
+		}
+		edges[key] = SnapshotEdge{Key: key, Type: e.Type(), Source: source, Target: target, Properties: props}
+	}
+
+	s := Snapshot{Version: SnapshotVersion, Nodes: []SnapshotNode{}, Edges: []SnapshotEdge{}}
+	for _, n := range nodes {
+		s.Nodes = append(s.Nodes, n)
+	}
+	for _, e := range edges {
+		s.Edges = append(s.Edges, e)
+	}
+	sort.Slice(s.Nodes, func(i, j int) bool { return s.Nodes[i].Key < s.Nodes[j].Key })
+	sort.Slice(s.Edges, func(i, j int) bool { return s.Edges[i].Key < s.Edges[j].Key })
+	return s, nil
+}
+
+// normalizeProperties returns props as they are decoded from JSON, so that
+// properties of new snapshots compare equal to those read back from a file
+func normalizeProperties(props map[string]interface{}) (map[string]interface{}, error) {
+	b, err := json.Marshal(props)
+	if err != nil {
+		return nil, err
+	}
+	normalized := map[string]interface{}{}
+	if err := json.Unmarshal(b, &normalized); err != nil {
+		return nil, err
+	}
+	return normalized, nil
+}
+
+// WriteSnapshot writes s to w as indented JSON
+func WriteSnapshot(w io.Writer, s Snapshot) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(s)
+}
+
+// ReadSnapshot reads a snapshot written by WriteSnapshot
+func ReadSnapshot(r io.Reader) (Snapshot, error) {
+	var s Snapshot
+	if err := json.NewDecoder(r).Decode(&s); err != nil {
+		return Snapshot{}, fmt.Errorf("unable to decode snapshot: %w", err)
+	}
+	if s.Version != SnapshotVersion {
+		return Snapshot{}, fmt.Errorf("unsupported snapshot version %d, expected %d", s.Version, SnapshotVersion)
+	}
+	return s, nil
+}
+
+// SnapshotChange is an element whose properties differ between two
+// snapshots
+type SnapshotChange struct {
+	Key    string                 `json:"key"`
+	Type   string                 `json:"type"`
+	Before map[string]interface{} `json:"before"`
+	After  map[string]interface{} `json:"after"`
+}
+
+// SnapshotDiff lists the elements added, removed and changed between two
+// snapshots
+type SnapshotDiff struct {
+	AddedNodes   []SnapshotNode   `json:"addedNodes"`
+	RemovedNodes []SnapshotNode   `json:"removedNodes"`
+	ChangedNodes []SnapshotChange `json:"changedNodes"`
+	AddedEdges   []SnapshotEdge   `json:"addedEdges"`
+	RemovedEdges []SnapshotEdge   `json:"removedEdges"`
+	ChangedEdges []SnapshotChange `json:"changedEdges"`
+}
+
+// Empty reports whether the snapshots compared are the same
+func (d SnapshotDiff) Empty() bool {
+	return len(d.AddedNodes) == 0 && len(d.RemovedNodes) == 0 && len(d.ChangedNodes) == 0 &&
+		len(d.AddedEdges) == 0 && len(d.RemovedEdges) == 0 && len(d.ChangedEdges) == 0
+}
+
+// DiffSnapshots returns the changes from snapshot before to snapshot after
+func DiffSnapshots(before Snapshot, after Snapshot) SnapshotDiff {
+	d := SnapshotDiff{
+		AddedNodes:   []SnapshotNode{},
+		RemovedNodes: []SnapshotNode{},
+		ChangedNodes: []SnapshotChange{},
+		AddedEdges:   []SnapshotEdge{},
+		RemovedEdges: []SnapshotEdge{},
+		ChangedEdges: []SnapshotChange{},
+	}
+
+	beforeNodes := map[string]SnapshotNode{}
+	for _, n := range before.Nodes {
+		beforeNodes[n.Key] = n
+	}
+	for _, n := range after.Nodes {
+		old, ok := beforeNodes[n.Key]
+		if !ok {
+			d.AddedNodes = append(d.AddedNodes, n)
+			continue
+		}
+		delete(beforeNodes, n.Key)
+		if !reflect.DeepEqual(old.Properties, n.Properties) {
+			d.ChangedNodes = append(d.ChangedNodes, SnapshotChange{Key: n.Key, Type: n.Type, Before: old.Properties, After: n.Properties})
+		}
+	}
+	for _, n := range before.Nodes {
+		if _, ok := beforeNodes[n.Key]; ok {
+			d.RemovedNodes = append(d.RemovedNodes, n)
+		}
+	}
+
+	beforeEdges := map[string]SnapshotEdge{}
+	for _, e := range before.Edges {
+		beforeEdges[e.Key] = e
+	}
+	for _, e := range after.Edges {
+		old, ok := beforeEdges[e.Key]
+		if !ok {
+			d.AddedEdges = append(d.AddedEdges, e)
+			continue
+		}
+		delete(beforeEdges, e.Key)
+		if !reflect.DeepEqual(old.Properties, e.Properties) {
+			d.ChangedEdges = append(d.ChangedEdges, SnapshotChange{Key: e.Key, Type: e.Type, Before: old.Properties, After: e.Properties})
+		}
+	}
+	for _, e := range before.Edges {
+		if _, ok := beforeEdges[e.Key]; ok {
+			d.RemovedEdges = append(d.RemovedEdges, e)
+		}
+	}
+	return d
+}
+
+// WriteSnapshotDiff writes a human readable summary of d to w, with a line
+// per element prefixed by `+` (added), `-` (removed) or `~` (changed)
+func WriteSnapshotDiff(w io.Writer, d SnapshotDiff) error {
+	lines := []string{}
+	for _, n := range d.AddedNodes {
+		lines = append(lines, "+ node "+n.Key)
+	}
+	for _, n := range d.RemovedNodes {
+		lines = append(lines, "- node "+n.Key)
+	}
+	for _, c := range d.ChangedNodes {
+		lines = append(lines, "~ node "+c.Key+" "+propertyChanges(c))
+	}
+	for _, e := range d.AddedEdges {
+		lines = append(lines, "+ edge "+e.Key)
+	}
+	for _, e := range d.RemovedEdges {
+		lines = append(lines, "- edge "+e.Key)
+	}
+	for _, c := range d.ChangedEdges {
+		lines = append(lines, "~ edge "+c.Key+" "+propertyChanges(c))
+	}
+	for _, l := range lines {
+		if _, err := fmt.Fprintln(w, l); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(w, "nodes: %d added, %d removed, %d changed; edges: %d added, %d removed, %d changed\n",
+		len(d.AddedNodes), len(d.RemovedNodes), len(d.ChangedNodes),
+		len(d.AddedEdges), len(d.RemovedEdges), len(d.ChangedEdges))
+	return err
+}
+
+// propertyChanges describes the properties that differ in c, e.g.
+// `{version: "1.0" -> "1.1"}`
+func propertyChanges(c SnapshotChange) string {
+	changes := []string{}
+	for _, k := range sortedKeys([]map[string]interface{}{c.Before, c.After}) {
+		before, inBefore := c.Before[k]
+		after, inAfter := c.After[k]
+		switch {
+		case !inBefore:
+			changes = append(changes, fmt.Sprintf("%s: +%s", k, jsonString(after)))
+		case !inAfter:
+			changes = append(changes, fmt.Sprintf("%s: -%s", k, jsonString(before)))
+		case !reflect.DeepEqual(before, after):
+			changes = append(changes, fmt.Sprintf("%s: %s -> %s", k, jsonString(before), jsonString(after)))
+		}
+	}
+	return "{" + strings.Join(changes, ", ") + "}"
+}
+
+func jsonString(v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return string(b)
+}
@@ -0,0 +1,134 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package assembler
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNewSnapshot(t *testing.T) {
+	s, err := NewSnapshot(exportGraph)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	wantNodes := []string{
+		"Artifact{digest=sha256:abc}",
+		"Attestation{digest=def}",
+		"Identity{digest=abc}",
+		"Package{purl=pkg:golang/app@v1}",
+		"Package{purl=pkg:golang/lib@v2}",
+	}
+	gotNodes := []string{}
+	for _, n := range s.Nodes {
+		gotNodes = append(gotNodes, n.Key)
+	}
+	if !reflect.DeepEqual(gotNodes, wantNodes) {
+		t.Errorf("got nodes %v, want %v", gotNodes, wantNodes)
+	}
+	if len(s.Edges) != 2 {
+		t.Fatalf("got %d edges, want 2", len(s.Edges))
+	}
+	if e := s.Edges[1]; e.Key != "Package{purl=pkg:golang/app@v1} -DependsOn-> Package{purl=pkg:golang/lib@v2}" {
+		t.Errorf("unexpected edge key %q", e.Key)
+	}
+
+	// the encoding is stable and round trips
+	var first, second bytes.Buffer
+	if err := WriteSnapshot(&first, s); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	again, err := NewSnapshot(exportGraph)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := WriteSnapshot(&second, again); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if first.String() != second.String() {
+		t.Errorf("snapshot encoding is not stable:\n%s\n%s", first.String(), second.String())
+	}
+	read, err := ReadSnapshot(&first)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if d := DiffSnapshots(read, s); !d.Empty() {
+		t.Errorf("snapshot read back differs: %+v", d)
+	}
+}
+
+func TestReadSnapshot_version(t *testing.T) {
+	if _, err := ReadSnapshot(strings.NewReader(`{"version": 2, "nodes": [], "edges": []}`)); err == nil {
+		t.Errorf("expected an error for an unsupported version")
+	}
+}
+
+func TestDiffSnapshots(t *testing.T) {
+	before, err := NewSnapshot(exportGraph)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	changedPkg := PackageNode{Name: "app", Purl: "pkg:golang/app@v1", Version: "v1"}
+	newDep := PackageNode{Name: "lib", Purl: "pkg:golang/lib@v3"}
+	after, err := NewSnapshot(Graph{
+		Nodes: []GuacNode{exportArt, changedPkg},
+		Edges: []GuacEdge{
+			DependsOnEdge{PackageNode: changedPkg, PackageDependency: newDep},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	d := DiffSnapshots(before, after)
+	keys := func(nodes []SnapshotNode) []string {
+		k := []string{}
+		for _, n := range nodes {
+			k = append(k, n.Key)
+		}
+		return k
+	}
+	if got, want := keys(d.AddedNodes), []string{"Package{purl=pkg:golang/lib@v3}"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("added nodes = %v, want %v", got, want)
+	}
+	if got, want := keys(d.RemovedNodes), []string{"Attestation{digest=def}", "Identity{digest=abc}", "Package{purl=pkg:golang/lib@v2}"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("removed nodes = %v, want %v", got, want)
+	}
+	if len(d.ChangedNodes) != 1 || d.ChangedNodes[0].Key != "Package{purl=pkg:golang/app@v1}" || d.ChangedNodes[0].After["version"] != "v1" {
+		t.Errorf("unexpected changed nodes %+v", d.ChangedNodes)
+	}
+	if len(d.AddedEdges) != 1 || len(d.RemovedEdges) != 2 || len(d.ChangedEdges) != 0 {
+		t.Errorf("got %d added, %d removed and %d changed edges, want 1, 2 and 0", len(d.AddedEdges), len(d.RemovedEdges), len(d.ChangedEdges))
+	}
+
+	var buf bytes.Buffer
+	if err := WriteSnapshotDiff(&buf, d); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		"+ node Package{purl=pkg:golang/lib@v3}\n",
+		"- node Identity{digest=abc}\n",
+		`~ node Package{purl=pkg:golang/app@v1} {version: +"v1"}` + "\n",
+		"nodes: 1 added, 3 removed, 1 changed; edges: 1 added, 2 removed, 0 changed\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("diff output missing %q:\n%s", want, out)
+		}
+	}
+}
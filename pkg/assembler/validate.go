@@ -0,0 +1,165 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package assembler
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Severity is the severity of a Problem found by ValidateGraph
+type Severity string
+
+const (
+	// SeverityError marks problems resulting in wrong or merged nodes
+	SeverityError Severity = "error"
+	// SeverityWarning marks problems that are ingested but likely unintended
+	SeverityWarning Severity = "warning"
+)
+
+// Problem is an issue with a node or edge of a graph. Location describes the
+// element, e.g. `Package{purl=pkg:golang/foo@v1}` for a node or
+// `DependsOn Package{...} -> Package{...}` for an edge.
+type Problem struct {
+	Severity Severity
+	Location string
+	Message  string
+}
+
+func (p Problem) String() string {
+	return fmt.Sprintf("%s: %s: %s", p.Severity, p.Location, p.Message)
+}
+
+// ValidateGraph checks that the nodes of g, including the edge endpoints, can
+// be stored without being merged with unrelated nodes, and that package URLs
+// and digests are well formed. Each problem is only reported once.
+func ValidateGraph(g Graph) []Problem {
+	problems := []Problem{}
+	seen := map[Problem]bool{}
+	report := func(p Problem) {
+		if !seen[p] {
+			seen[p] = true
+			problems = append(problems, p)
+		}
+	}
+
+	for _, n := range g.Nodes {
+		for _, p := range validateNode(n) {
+			report(p)
+		}
+	}
+	for _, e := range g.Edges {
+		v, u := e.Nodes()
+		location := e.Type() + " " + nodeLocation(v) + " -> " + nodeLocation(u)
+		endpoints := []GuacNode{v, u}
+		if _, ok := e.(MatchingEdge); ok {
+			// u is matched, not created
+			endpoints = endpoints[:1]
+		}
+		for _, n := range endpoints {
+			if name := missingIdentifier(n); name != "" {
+				report(Problem{
+					Severity: SeverityError,
+					Location: location,
+					Message:  fmt.Sprintf("%s endpoint has no value for %q", n.Type(), name),
+				})
+			}
+		}
+	}
+	return problems
+}
+
+func validateNode(n GuacNode) []Problem {
+	location := nodeLocation(n)
+	if name := missingIdentifier(n); name != "" {
+		return []Problem{{
+			Severity: SeverityError,
+			Location: location,
+			Message:  fmt.Sprintf("no value for identifying property %q, node would be merged with others missing it", name),
+		}}
+	}
+
+	problems := []Problem{}
+	switch node := n.(type) {
+	case PackageNode:
+		if msg, severity := checkPurl(node.Purl); msg != "" {
+			problems = append(problems, Problem{Severity: severity, Location: location, Message: msg})
+		}
+		for _, d := range node.Digest {
+			if !isDigest(d) {
+				problems = append(problems, Problem{Severity: SeverityWarning, Location: location, Message: fmt.Sprintf("digest %q is not of the form algorithm:value", d)})
+			}
+		}
+	case ArtifactNode:
+		if !isDigest(node.Digest) {
+			problems = append(problems, Problem{Severity: SeverityError, Location: location, Message: fmt.Sprintf("digest %q is not of the form algorithm:value", node.Digest)})
+		}
+	}
+	return problems
+}
+
+// nodeLocation returns nodeKey(n), followed by the name of n if it has one so
+// that nodes missing their identifier can still be found in the document
+func nodeLocation(n GuacNode) string {
+	if name := propertyString(n.Properties()["name"]); name != "" {
+		for _, id := range n.IdentifiablePropertyNames() {
+			if id == "name" {
+				return nodeKey(n)
+			}
+		}
+		return nodeKey(n) + " (name " + name + ")"
+	}
+	return nodeKey(n)
+}
+
+// missingIdentifier returns the first identifiable property of n without a
+// value, or an empty string if there is none
+func missingIdentifier(n GuacNode) string {
+	props := n.Properties()
+	for _, name := range n.IdentifiablePropertyNames() {
+		if propertyString(props[name]) == "" {
+			return name
+		}
+	}
+	return ""
+}
+
+// checkPurl checks that purl has the `pkg:type/name` structure of a package
+// URL, and that the type is normalized to lower case
+func checkPurl(purl string) (string, Severity) {
+	rest := strings.TrimPrefix(purl, "pkg:")
+	if rest == purl {
+		return fmt.Sprintf("purl %q does not start with pkg:", purl), SeverityError
+	}
+	// qualifiers and subpath are not checked
+	if i := strings.IndexAny(rest, "?#"); i >= 0 {
+		rest = rest[:i]
+	}
+	rest = strings.TrimLeft(rest, "/")
+	purlType, name, found := strings.Cut(rest, "/")
+	if !found || purlType == "" || strings.Trim(name, "/") == "" {
+		return fmt.Sprintf("purl %q has no type or name", purl), SeverityError
+	}
+	if purlType != strings.ToLower(purlType) {
+		return fmt.Sprintf("purl %q type should be lower case", purl), SeverityWarning
+	}
+	return "", ""
+}
+
+func isDigest(d string) bool {
+	algorithm, value, found := strings.Cut(d, ":")
+	return found && algorithm != "" && value != ""
+}
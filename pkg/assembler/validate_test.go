@@ -0,0 +1,92 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package assembler
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestValidateGraph(t *testing.T) {
+	pkg := PackageNode{Name: "foo", Purl: "pkg:golang/foo@v1"}
+	dep := PackageNode{Name: "bar", Purl: "pkg:golang/bar@v2", Digest: []string{"sha256:abc"}}
+	tests := []struct {
+		name string
+		g    Graph
+		want []Problem
+	}{{
+		name: "valid",
+		g: Graph{
+			Nodes: []GuacNode{pkg, dep, ArtifactNode{Digest: "sha256:def"}},
+			Edges: []GuacEdge{DependsOnEdge{PackageNode: pkg, PackageDependency: dep}},
+		},
+		want: []Problem{},
+	}, {
+		name: "missing purl",
+		g: Graph{
+			Nodes: []GuacNode{PackageNode{Name: "baz"}},
+			Edges: []GuacEdge{DependsOnEdge{PackageNode: pkg, PackageDependency: PackageNode{Name: "baz"}}},
+		},
+		want: []Problem{{
+			Severity: SeverityError,
+			Location: "Package{purl=} (name baz)",
+			Message:  `no value for identifying property "purl", node would be merged with others missing it`,
+		}, {
+			Severity: SeverityError,
+			Location: "DependsOn Package{purl=pkg:golang/foo@v1} (name foo) -> Package{purl=} (name baz)",
+			Message:  `Package endpoint has no value for "purl"`,
+		}},
+	}, {
+		name: "malformed purls and digests",
+		g: Graph{
+			Nodes: []GuacNode{
+				PackageNode{Purl: "golang/foo"},
+				PackageNode{Purl: "pkg:golang"},
+				PackageNode{Purl: "pkg:Golang/foo", Digest: []string{"abc"}},
+				ArtifactNode{Digest: "def"},
+				ArtifactNode{Digest: "def"},
+			},
+		},
+		want: []Problem{{
+			Severity: SeverityError,
+			Location: "Package{purl=golang/foo}",
+			Message:  `purl "golang/foo" does not start with pkg:`,
+		}, {
+			Severity: SeverityError,
+			Location: "Package{purl=pkg:golang}",
+			Message:  `purl "pkg:golang" has no type or name`,
+		}, {
+			Severity: SeverityWarning,
+			Location: "Package{purl=pkg:Golang/foo}",
+			Message:  `purl "pkg:Golang/foo" type should be lower case`,
+		}, {
+			Severity: SeverityWarning,
+			Location: "Package{purl=pkg:Golang/foo}",
+			Message:  `digest "abc" is not of the form algorithm:value`,
+		}, {
+			Severity: SeverityError,
+			Location: "Artifact{digest=def}",
+			Message:  `digest "def" is not of the form algorithm:value`,
+		}},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ValidateGraph(tt.g); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ValidateGraph() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,254 @@
+//
+// Copyright 2022 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package assembler
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/guacsec/guac/pkg/assembler/graphdb"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+)
+
+// Checks run by VerifyGraph
+const (
+	// CheckMissingKey finds the nodes without one of the properties they are
+	// merged on
+	CheckMissingKey = "missing-key"
+	// CheckDuplicateKey finds the keys shared by more than one node, which
+	// should have been merged into one
+	CheckDuplicateKey = "duplicate-key"
+	// CheckDanglingEdge finds the relationships with an endpoint that is not
+	// a node GUAC can identify: a node without labels or without its key
+	CheckDanglingEdge = "dangling-edge"
+)
+
+// identifiableNodes are the nodes whose identifiable properties are checked
+var identifiableNodes = []GuacNode{
+	ArtifactNode{},
+	PackageNode{},
+	IdentityNode{},
+	AttestationNode{},
+	BuilderNode{},
+	MetadataNode{},
+	VulnerabilityNode{},
+	LicenseNode{},
+	ExternalReferenceNode{},
+	DocumentNode{},
+}
+
+// Violation is the number of nodes, keys or relationships with the label that
+// failed a check
+type Violation struct {
+	Check string
+	Label string
+	Count int64
+}
+
+func (v Violation) String() string {
+	if v.Label == "" {
+		return fmt.Sprintf("%s: %d", v.Check, v.Count)
+	}
+	return fmt.Sprintf("%s: %d %s", v.Check, v.Count, v.Label)
+}
+
+// verifyQuery is the query counting the violations of a check on a label
+type verifyQuery struct {
+	check string
+	label string
+	query string
+}
+
+// IdentityKeys returns the properties that identify the nodes of each label,
+// the properties the nodes are merged on when stored
+func IdentityKeys() map[string][]string {
+	keys := map[string][]string{}
+	for _, n := range identifiableNodes {
+		keys[n.Type()] = n.IdentifiablePropertyNames()
+	}
+	return keys
+}
+
+// VerifyGraph runs the consistency checks against the graph and returns the
+// violations found, an empty list if the graph is consistent
+func VerifyGraph(client graphdb.Client) ([]Violation, error) {
+	keys := IdentityKeys()
+	labels := make([]string, 0, len(keys))
+	for label := range keys {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+
+	queries := []verifyQuery{{CheckDanglingEdge, "", unlabelledEdgeCountQuery}}
+	for _, label := range labels {
+		missing, err := missingKeyCountQuery(label, keys[label])
+		if err != nil {
+			return nil, err
+		}
+		duplicate, err := duplicateKeyCountQuery(label, keys[label])
+		if err != nil {
+			return nil, err
+		}
+		dangling, err := danglingEdgeCountQuery(label, keys[label])
+		if err != nil {
+			return nil, err
+		}
+		queries = append(queries,
+			verifyQuery{CheckMissingKey, label, missing},
+			verifyQuery{CheckDuplicateKey, label, duplicate},
+			verifyQuery{CheckDanglingEdge, label, dangling})
+	}
+
+	session := client.NewSession(neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
+	defer session.Close()
+
+	violations := []Violation{}
+	for _, q := range queries {
+		count, err := session.ReadTransaction(
+			func(tx graphdb.Transaction) (interface{}, error) {
+				return singleCount(tx, q.query, nil)
+			})
+		if err != nil {
+			return nil, fmt.Errorf("%s check on %q failed: %w", q.check, q.label, err)
+		}
+		if count.(int64) > 0 {
+			violations = append(violations, Violation{Check: q.check, Label: q.label, Count: count.(int64)})
+		}
+	}
+	return violations, nil
+}
+
+// DeleteDanglingEdges deletes the relationships found by the dangling edge
+// check and returns the number of deleted relationships. The nodes are left
+// in place, pruning the ones left without relationships is up to the caller.
+// Relationships are deleted batchSize at a time, as in DeleteOrphanNodes.
+func DeleteDanglingEdges(client graphdb.Client, batchSize int) (int64, error) {
+	if batchSize <= 0 {
+		return 0, fmt.Errorf("batch size must be positive, got %d", batchSize)
+	}
+	keys := IdentityKeys()
+	queries := []string{unlabelledEdgeDeleteQuery}
+	for label, k := range keys {
+		query, err := danglingEdgeDeleteQuery(label, k)
+		if err != nil {
+			return 0, err
+		}
+		queries = append(queries, query)
+	}
+
+	session := client.NewSession(neo4j.SessionConfig{})
+	defer session.Close()
+
+	var total int64
+	for _, query := range queries {
+		for {
+			deleted, err := session.WriteTransaction(
+				func(tx graphdb.Transaction) (interface{}, error) {
+					return singleCount(tx, query, map[string]interface{}{"limit": batchSize})
+				})
+			if err != nil {
+				return total, err
+			}
+			total += deleted.(int64)
+			if deleted.(int64) < int64(batchSize) {
+				break
+			}
+		}
+	}
+	return total, nil
+}
+
+const (
+	// unlabelledEdgeCountQuery counts the relationships of the nodes
+	// without labels
+	unlabelledEdgeCountQuery = "MATCH (n)-[r]-() WHERE size(labels(n)) = 0 RETURN count(DISTINCT r)"
+	// unlabelledEdgeDeleteQuery deletes up to $limit relationships of the
+	// nodes without labels
+	unlabelledEdgeDeleteQuery = "MATCH (n)-[r]-() WHERE size(labels(n)) = 0 WITH DISTINCT r LIMIT $limit DELETE r RETURN count(r)"
+)
+
+// keyCondition returns the condition matching the nodes n with any of the
+// keys null, or with none of them null if notNull is set
+func keyCondition(label string, keys []string, notNull bool) (string, error) {
+	if err := ValidateLabel(label); err != nil {
+		return "", err
+	}
+	if len(keys) == 0 {
+		return "", fmt.Errorf("no identity keys for label %q", label)
+	}
+	conditions := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if err := ValidateLabel(key); err != nil {
+			return "", err
+		}
+		if notNull {
+			conditions = append(conditions, "n."+key+" IS NOT NULL")
+		} else {
+			conditions = append(conditions, "n."+key+" IS NULL")
+		}
+	}
+	if notNull {
+		return strings.Join(conditions, " AND "), nil
+	}
+	return strings.Join(conditions, " OR "), nil
+}
+
+// missingKeyCountQuery returns the query counting the nodes with label that
+// have one of the keys null
+func missingKeyCountQuery(label string, keys []string) (string, error) {
+	condition, err := keyCondition(label, keys, false)
+	if err != nil {
+		return "", err
+	}
+	return "MATCH (n:" + label + ") WHERE " + condition + " RETURN count(n)", nil
+}
+
+// duplicateKeyCountQuery returns the query counting the keys shared by more
+// than one node with label
+func duplicateKeyCountQuery(label string, keys []string) (string, error) {
+	condition, err := keyCondition(label, keys, true)
+	if err != nil {
+		return "", err
+	}
+	projections := make([]string, 0, len(keys))
+	for i, key := range keys {
+		projections = append(projections, fmt.Sprintf("n.%s AS k%d", key, i))
+	}
+	return "MATCH (n:" + label + ") WHERE " + condition +
+		" WITH " + strings.Join(projections, ", ") + ", count(n) AS c WHERE c > 1 RETURN count(*)", nil
+}
+
+// danglingEdgeCountQuery returns the query counting the relationships of the
+// nodes with label that have one of the keys null
+func danglingEdgeCountQuery(label string, keys []string) (string, error) {
+	condition, err := keyCondition(label, keys, false)
+	if err != nil {
+		return "", err
+	}
+	return "MATCH (n:" + label + ")-[r]-() WHERE " + condition + " RETURN count(DISTINCT r)", nil
+}
+
+// danglingEdgeDeleteQuery returns the query deleting up to $limit
+// relationships of the nodes with label that have one of the keys null,
+// returning the number of deleted relationships
+func danglingEdgeDeleteQuery(label string, keys []string) (string, error) {
+	condition, err := keyCondition(label, keys, false)
+	if err != nil {
+		return "", err
+	}
+	return "MATCH (n:" + label + ")-[r]-() WHERE " + condition + " WITH DISTINCT r LIMIT $limit DELETE r RETURN count(r)", nil
+}
@@ -0,0 +1,96 @@
+//
+// Copyright 2022 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package assembler
+
+import "testing"
+
+func Test_verifyQueries(t *testing.T) {
+	tests := []struct {
+		name          string
+		label         string
+		keys          []string
+		wantMissing   string
+		wantDuplicate string
+		wantDangling  string
+		wantDelete    string
+		wantErr       bool
+	}{{
+		name:          "single key",
+		label:         "Package",
+		keys:          []string{"purl"},
+		wantMissing:   "MATCH (n:Package) WHERE n.purl IS NULL RETURN count(n)",
+		wantDuplicate: "MATCH (n:Package) WHERE n.purl IS NOT NULL WITH n.purl AS k0, count(n) AS c WHERE c > 1 RETURN count(*)",
+		wantDangling:  "MATCH (n:Package)-[r]-() WHERE n.purl IS NULL RETURN count(DISTINCT r)",
+		wantDelete:    "MATCH (n:Package)-[r]-() WHERE n.purl IS NULL WITH DISTINCT r LIMIT $limit DELETE r RETURN count(r)",
+	}, {
+		name:          "composite key",
+		label:         "Metadata",
+		keys:          []string{"metadata_type", "id"},
+		wantMissing:   "MATCH (n:Metadata) WHERE n.metadata_type IS NULL OR n.id IS NULL RETURN count(n)",
+		wantDuplicate: "MATCH (n:Metadata) WHERE n.metadata_type IS NOT NULL AND n.id IS NOT NULL WITH n.metadata_type AS k0, n.id AS k1, count(n) AS c WHERE c > 1 RETURN count(*)",
+		wantDangling:  "MATCH (n:Metadata)-[r]-() WHERE n.metadata_type IS NULL OR n.id IS NULL RETURN count(DISTINCT r)",
+		wantDelete:    "MATCH (n:Metadata)-[r]-() WHERE n.metadata_type IS NULL OR n.id IS NULL WITH DISTINCT r LIMIT $limit DELETE r RETURN count(r)",
+	}, {
+		name:    "no keys",
+		label:   "Package",
+		wantErr: true,
+	}, {
+		name:    "injection in label",
+		label:   "Package) DETACH DELETE n //",
+		keys:    []string{"purl"},
+		wantErr: true,
+	}, {
+		name:    "injection in key",
+		label:   "Package",
+		keys:    []string{"purl IS NULL OR true"},
+		wantErr: true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queries := []struct {
+				name  string
+				build func(string, []string) (string, error)
+				want  string
+			}{
+				{"missingKeyCountQuery", missingKeyCountQuery, tt.wantMissing},
+				{"duplicateKeyCountQuery", duplicateKeyCountQuery, tt.wantDuplicate},
+				{"danglingEdgeCountQuery", danglingEdgeCountQuery, tt.wantDangling},
+				{"danglingEdgeDeleteQuery", danglingEdgeDeleteQuery, tt.wantDelete},
+			}
+			for _, q := range queries {
+				got, err := q.build(tt.label, tt.keys)
+				if (err != nil) != tt.wantErr {
+					t.Fatalf("%s() error = %v, wantErr %v", q.name, err, tt.wantErr)
+				}
+				if got != q.want {
+					t.Errorf("%s() = %q, want %q", q.name, got, q.want)
+				}
+			}
+		})
+	}
+}
+
+func TestIdentityKeys(t *testing.T) {
+	keys := IdentityKeys()
+	for label, k := range keys {
+		if len(k) == 0 {
+			t.Errorf("IdentityKeys() has no keys for %s", label)
+		}
+	}
+	if got := keys["Metadata"]; len(got) != 2 || got[0] != "metadata_type" || got[1] != "id" {
+		t.Errorf("IdentityKeys()[Metadata] = %v, want [metadata_type id]", got)
+	}
+}
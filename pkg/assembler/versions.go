@@ -0,0 +1,238 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package assembler
+
+import (
+	"strings"
+	"time"
+)
+
+// The assertions of a graph are versioned by the documents asserting them: a
+// document is valid from its `valid_from` time, or the time it was collected
+// if it has none, until the earliest `valid_from` time of the documents
+// superseding it. The end of the validity of a document is not stored but
+// read from its Supersedes edges, so that ingesting the documents of a source
+// out of order or retracting one of them leaves the others valid for the
+// right time.
+
+// supersedeScanLimit bounds the stored documents of a source read to find
+// the documents a document supersedes
+const supersedeScanLimit = 10000
+
+// documentVersion is a document of a lineage, see documentLineage
+type documentVersion struct {
+	digest    string
+	validFrom string
+}
+
+// Supersede returns g with the Supersedes edges from its documents to the
+// documents they supersede, and from the documents superseding them. A
+// document supersedes the latest document of its lineage valid before it,
+// stored or in g: the documents of the same type collected by the same
+// collector from the same source URI, or the same source if they have no
+// URI. Documents without a source or a `valid_from` time supersede none.
+func Supersede(reader GraphReader, g Graph) (Graph, error) {
+	superseding := Graph{Nodes: g.Nodes, Edges: append([]GuacEdge{}, g.Edges...)}
+	superseding.appendDocuments(g.Documents, g.Evidence)
+
+	added := map[string]bool{}
+	addEdge := func(doc, superseded string) {
+		if key := doc + "/" + superseded; !added[key] {
+			added[key] = true
+			superseding.Edges = append(superseding.Edges, SupersedesEdge{
+				DocumentNode:   DocumentNode{Digest: doc},
+				SupersededNode: DocumentNode{Digest: superseded},
+			})
+		}
+	}
+	for _, doc := range g.Documents {
+		props := doc.Properties()
+		lineage, source := documentLineage(props)
+		validFrom := documentValidFrom(props)
+		if source == "" || validFrom == "" {
+			continue
+		}
+		stored, err := reader.FindNodes(NodeFilter{Label: "Document", Source: source, Limit: supersedeScanLimit})
+		if err != nil {
+			return Graph{}, err
+		}
+		versions := make([]documentVersion, 0, len(stored)+len(g.Documents))
+		for _, n := range stored {
+			if l, _ := documentLineage(n.Properties); l == lineage {
+				versions = append(versions, documentVersion{digest: stringProperty(n.Properties["digest"]), validFrom: documentValidFrom(n.Properties)})
+			}
+		}
+		for _, other := range g.Documents {
+			otherProps := other.Properties()
+			if l, _ := documentLineage(otherProps); l == lineage {
+				versions = append(versions, documentVersion{digest: stringProperty(otherProps["digest"]), validFrom: documentValidFrom(otherProps)})
+			}
+		}
+
+		digest := strings.ToLower(doc.Digest)
+		var previous, next documentVersion
+		for _, v := range versions {
+			if v.digest == digest || v.validFrom == "" {
+				continue
+			}
+			if v.validFrom < validFrom && v.validFrom > previous.validFrom {
+				previous = v
+			}
+			if v.validFrom > validFrom && (next.validFrom == "" || v.validFrom < next.validFrom) {
+				next = v
+			}
+		}
+		if previous.digest != "" {
+			addEdge(digest, previous.digest)
+		}
+		if next.digest != "" {
+			addEdge(next.digest, digest)
+		}
+	}
+	return superseding, nil
+}
+
+// documentLineage returns the key of the lineage of the document with the
+// stored properties props, see Supersede, and its source URI or source
+func documentLineage(props map[string]interface{}) (string, string) {
+	source := stringProperty(props["source_uri"])
+	if source == "" {
+		source = stringProperty(props["source"])
+	}
+	return strings.Join([]string{stringProperty(props["collector"]), stringProperty(props["document_type"]), source}, "\x00"), source
+}
+
+// documentValidFrom returns the time the document with the stored properties
+// props is valid from, in TimeFormat, or "" if unknown
+func documentValidFrom(props map[string]interface{}) string {
+	if validFrom := stringProperty(props["valid_from"]); validFrom != "" {
+		return validFrom
+	}
+	return stringProperty(props["collected_at"])
+}
+
+// stringProperty returns the stored property value v if it is a string, ""
+// otherwise
+func stringProperty(v interface{}) string {
+	s, _ := v.(string)
+	return s
+}
+
+// Belief is an edge of a node believed at some time, with the node at its
+// other end and the documents asserting it that were valid then
+type Belief struct {
+	Direction      Direction              `json:"direction"`
+	Edge           string                 `json:"edge"`
+	EdgeProperties map[string]interface{} `json:"edge_properties,omitempty"`
+	Node           StoredNode             `json:"node"`
+	Documents      []StoredNode           `json:"documents"`
+}
+
+// BeliefsAt returns the edges of the node n believed at time at, with at most
+// limit edges: the edges asserted by a document valid at that time. The
+// documents are returned with their `valid_until` time, if superseded. The
+// properties of an edge are the last ones stored, and the edges stored before
+// documents were recorded are left out.
+func BeliefsAt(reader GraphReader, n StoredNode, at time.Time, limit int) ([]Belief, error) {
+	validity := documentValidity{reader: reader, docs: map[string]*StoredNode{}}
+	when := formatTime(at)
+	beliefs := []Belief{}
+	for _, dir := range []Direction{DirectionOut, DirectionIn} {
+		neighbors, err := reader.Neighbors(n.ID, "", dir, "", evidenceScanLimit)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range neighbors {
+			if len(beliefs) >= queryLimit(limit) {
+				return beliefs, nil
+			}
+			digests, _ := m.EdgeProperties["documents"].([]interface{})
+			var docs []StoredNode
+			for _, d := range digests {
+				doc, err := validity.validAt(stringProperty(d), when)
+				if err != nil {
+					return nil, err
+				}
+				if doc != nil {
+					docs = append(docs, *doc)
+				}
+			}
+			if len(docs) > 0 {
+				beliefs = append(beliefs, Belief{Direction: dir, Edge: m.Edge, EdgeProperties: m.EdgeProperties, Node: m.Node, Documents: docs})
+			}
+		}
+	}
+	return beliefs, nil
+}
+
+// documentValidity reads the validity of the documents, read once each
+type documentValidity struct {
+	reader GraphReader
+	// docs maps the digest of a document to its node with its
+	// `valid_until` time, nil if it is not stored
+	docs map[string]*StoredNode
+}
+
+// validAt returns the document with digest if it is valid at the time when,
+// in TimeFormat, nil otherwise
+func (v documentValidity) validAt(digest string, when string) (*StoredNode, error) {
+	doc, err := v.document(digest)
+	if err != nil || doc == nil {
+		return nil, err
+	}
+	validFrom, validUntil := documentValidFrom(doc.Properties), stringProperty(doc.Properties["valid_until"])
+	if (validFrom != "" && when < validFrom) || (validUntil != "" && when >= validUntil) {
+		return nil, nil
+	}
+	return doc, nil
+}
+
+// document returns the document with digest and its `valid_until` time, the
+// earliest time of the documents superseding it
+func (v documentValidity) document(digest string) (*StoredNode, error) {
+	if doc, ok := v.docs[digest]; ok {
+		return doc, nil
+	}
+	nodes, err := v.reader.FindNodes(NodeFilter{Label: "Document", Digest: digest, Limit: 1})
+	if err != nil {
+		return nil, err
+	}
+	if len(nodes) == 0 {
+		v.docs[digest] = nil
+		return nil, nil
+	}
+	doc := nodes[0]
+	superseding, err := v.reader.Neighbors(doc.ID, "Supersedes", DirectionIn, "Document", supersedeScanLimit)
+	if err != nil {
+		return nil, err
+	}
+	validUntil := ""
+	for _, s := range superseding {
+		if t := documentValidFrom(s.Node.Properties); t != "" && (validUntil == "" || t < validUntil) {
+			validUntil = t
+		}
+	}
+	if validUntil != "" {
+		props := make(map[string]interface{}, len(doc.Properties)+1)
+		for k, val := range doc.Properties {
+			props[k] = val
+		}
+		props["valid_until"] = validUntil
+		doc.Properties = props
+	}
+	v.docs[digest] = &doc
+	return &doc, nil
+}
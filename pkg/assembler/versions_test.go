@@ -0,0 +1,168 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package assembler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/guacsec/guac/pkg/handler/processor"
+)
+
+func TestBeliefsAt(t *testing.T) {
+	ctx := context.Background()
+	app := PackageNode{Purl: "pkg:oci/app"}
+	text := PackageNode{Purl: "pkg:golang/golang.org/x/text@v0.3.7"}
+	vuln := VulnerabilityNode{ID: "GHSA-69ch-w2m2-3vjp"}
+	scan := func(day int, state string) Graph {
+		doc := NewDocumentNode([]byte(state), processor.SourceInformation{
+			Collector:   "oci",
+			URI:         "oci://app",
+			CollectedAt: time.Date(2023, 3, day, 0, 0, 0, 0, time.UTC),
+		})
+		doc.DocumentType = "VEX"
+		g := Graph{Nodes: []GuacNode{app, text}, Edges: []GuacEdge{DependsOnEdge{PackageNode: app, PackageDependency: text}}}
+		if state != "" {
+			g.Nodes = append(g.Nodes, vuln)
+			g.Edges = append(g.Edges, AffectsEdge{VulnerabilityNode: vuln, PackageNode: app, AnalysisState: state})
+		}
+		return AssertedBy(g, doc)
+	}
+	// the rescan of March 20 is ingested before the one of March 10
+	march1, march10, march20 := scan(1, "affected"), scan(10, "under_investigation"), scan(20, "")
+	other := AssertedBy(Graph{Nodes: []GuacNode{app}}, NewDocumentNode([]byte("other"), processor.SourceInformation{
+		Collector:   "file",
+		Source:      "sbom.json",
+		CollectedAt: time.Date(2023, 2, 1, 0, 0, 0, 0, time.UTC),
+	}))
+
+	m := NewMemoryGraph()
+	for _, g := range []Graph{other, march1, march20, march10} {
+		superseding, err := Supersede(m, g)
+		if err != nil {
+			t.Fatalf("Supersede() error = %v", err)
+		}
+		if err := m.StoreGraph(ctx, superseding); err != nil {
+			t.Fatalf("StoreGraph() error = %v", err)
+		}
+	}
+	subject, err := FindSubject(m, "pkg:oci/app")
+	if err != nil {
+		t.Fatalf("FindSubject() error = %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		at        time.Time
+		wantEdges []string
+		wantUntil string
+	}{{
+		name: "before the first scan",
+		at:   time.Date(2023, 2, 15, 0, 0, 0, 0, time.UTC),
+	}, {
+		name:      "first scan",
+		at:        time.Date(2023, 3, 5, 0, 0, 0, 0, time.UTC),
+		wantEdges: []string{"DependsOn", "Affects"},
+		wantUntil: "2023-03-10T00:00:00.000000000Z",
+	}, {
+		name:      "scan ingested last",
+		at:        time.Date(2023, 3, 10, 0, 0, 0, 0, time.UTC),
+		wantEdges: []string{"DependsOn", "Affects"},
+		wantUntil: "2023-03-20T00:00:00.000000000Z",
+	}, {
+		name:      "fixed",
+		at:        time.Date(2023, 4, 1, 0, 0, 0, 0, time.UTC),
+		wantEdges: []string{"DependsOn"},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			beliefs, err := BeliefsAt(m, subject, tt.at, 0)
+			if err != nil {
+				t.Fatalf("BeliefsAt() error = %v", err)
+			}
+			var edges []string
+			for _, b := range beliefs {
+				edges = append(edges, b.Edge)
+				if len(b.Documents) != 1 || stringProperty(b.Documents[0].Properties["valid_until"]) != tt.wantUntil {
+					t.Errorf("BeliefsAt() documents of %s = %v, want one valid until %q", b.Edge, b.Documents, tt.wantUntil)
+				}
+			}
+			if len(edges) != len(tt.wantEdges) {
+				t.Fatalf("BeliefsAt() edges = %v, want %v", edges, tt.wantEdges)
+			}
+			for i := range edges {
+				if edges[i] != tt.wantEdges[i] {
+					t.Errorf("BeliefsAt() edges = %v, want %v", edges, tt.wantEdges)
+				}
+			}
+		})
+	}
+
+	// retracting the scan of March 10 makes the first one valid until the
+	// scan of March 20
+	if _, err := m.RetractDocument(ctx, march10.Documents[0].Digest); err != nil {
+		t.Fatalf("RetractDocument() error = %v", err)
+	}
+	beliefs, err := BeliefsAt(m, subject, time.Date(2023, 3, 15, 0, 0, 0, 0, time.UTC), 0)
+	if err != nil {
+		t.Fatalf("BeliefsAt() error = %v", err)
+	}
+	if len(beliefs) != 2 || beliefs[1].EdgeProperties["analysis_state"] == nil ||
+		beliefs[1].Documents[0].Properties["valid_until"] != "2023-03-20T00:00:00.000000000Z" {
+		t.Errorf("BeliefsAt() after retracting a scan = %v, want the edges of the first scan", beliefs)
+	}
+}
+
+func TestSupersede(t *testing.T) {
+	source := func(uri string, day int) DocumentNode {
+		doc := NewDocumentNode([]byte(uri+string(rune('a'+day))), processor.SourceInformation{
+			Collector:   "oci",
+			URI:         uri,
+			CollectedAt: time.Date(2023, 3, day, 0, 0, 0, 0, time.UTC),
+		})
+		doc.DocumentType = "SPDX"
+		return doc
+	}
+	first, second, third := source("oci://app", 1), source("oci://app", 2), source("oci://app", 3)
+	unrelated := source("oci://db", 2)
+	slsa := source("oci://app", 4)
+	slsa.DocumentType = "SLSA"
+
+	m := NewMemoryGraph()
+	if err := m.StoreGraph(context.Background(), Graph{Documents: []DocumentNode{first, unrelated}}); err != nil {
+		t.Fatalf("StoreGraph() error = %v", err)
+	}
+	got, err := Supersede(m, Graph{Documents: []DocumentNode{third, second, slsa}})
+	if err != nil {
+		t.Fatalf("Supersede() error = %v", err)
+	}
+	want := []string{
+		edgeKey(SupersedesEdge{DocumentNode: DocumentNode{Digest: third.Digest}, SupersededNode: DocumentNode{Digest: second.Digest}}),
+		edgeKey(SupersedesEdge{DocumentNode: DocumentNode{Digest: second.Digest}, SupersededNode: DocumentNode{Digest: first.Digest}}),
+	}
+	if len(got.Edges) != len(want) {
+		t.Fatalf("Supersede() edges = %v, want %v", got.Edges, want)
+	}
+	for i, e := range got.Edges {
+		if edgeKey(e) != want[i] {
+			t.Errorf("Supersede() edge %d = %s, want %s", i, edgeKey(e), want[i])
+		}
+	}
+	if len(got.Documents) != 3 {
+		t.Errorf("Supersede() documents = %v, want the documents of the graph", got.Documents)
+	}
+}
@@ -0,0 +1,78 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package blob stores the documents too large to be published on the broker,
+// see emitter.SetBlobOffload
+package blob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+const (
+	// SchemeFile is the scheme of the stores in a directory, e.g.
+	// file:///var/lib/guac/blobs, shared by the processes of the pipeline
+	SchemeFile = "file"
+	// SchemeGCS is the scheme of the stores in a GCS bucket, e.g.
+	// gs://bucket/prefix
+	SchemeGCS = "gs"
+	// SchemeS3 is the scheme of the stores in an S3 bucket, e.g.
+	// s3://bucket/prefix?region=eu-west-1, or an S3 compatible store with
+	// the endpoint query parameter, e.g. s3://bucket?endpoint=http://minio:9000
+	SchemeS3 = "s3"
+)
+
+// ErrNotFound is returned by Get for a key not stored
+var ErrNotFound = errors.New("blob not found")
+
+// Store stores blobs by key
+type Store interface {
+	// Put stores data under key, overwriting it if already stored
+	Put(ctx context.Context, key string, data []byte) error
+	// Get returns the data stored under key, ErrNotFound if none
+	Get(ctx context.Context, key string) ([]byte, error)
+}
+
+// NewStore returns the store at uri, selected by its scheme
+func NewStore(ctx context.Context, uri string) (Store, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, fmt.Errorf("invalid blob store uri %q: %w", uri, err)
+	}
+	switch u.Scheme {
+	case SchemeFile:
+		return NewFileStore(u.Path)
+	case SchemeGCS:
+		return NewGCSStore(ctx, u.Host, prefix(u))
+	case SchemeS3:
+		return NewS3Store(u.Host, prefix(u), u.Query().Get("region"), u.Query().Get("endpoint"))
+	default:
+		return nil, fmt.Errorf("unknown blob store scheme in %q, supported schemes are %s, %s and %s", uri, SchemeFile, SchemeGCS, SchemeS3)
+	}
+}
+
+// prefix returns the prefix of the objects in the bucket of u, ending with a
+// slash unless empty
+func prefix(u *url.URL) string {
+	p := strings.Trim(u.Path, "/")
+	if p == "" {
+		return ""
+	}
+	return p + "/"
+}
@@ -0,0 +1,58 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blob
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestFileStore(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileStore() error = %v", err)
+	}
+	if _, err := store.Get(ctx, "sha256/abc"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() error = %v, want ErrNotFound", err)
+	}
+	if err := store.Put(ctx, "sha256/abc", []byte("document")); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	data, err := store.Get(ctx, "sha256/abc")
+	if err != nil || string(data) != "document" {
+		t.Errorf("Get() = %s, %v, want document", data, err)
+	}
+	for _, key := range []string{"", "../abc", "sha256/../../abc"} {
+		if err := store.Put(ctx, key, []byte("document")); err == nil {
+			t.Errorf("Put(%q) expected error for a key out of the directory", key)
+		}
+	}
+}
+
+func TestNewStore(t *testing.T) {
+	ctx := context.Background()
+	if _, err := NewStore(ctx, "file://"+t.TempDir()); err != nil {
+		t.Errorf("NewStore() error = %v", err)
+	}
+	if _, err := NewStore(ctx, "ftp://host/blobs"); err == nil {
+		t.Errorf("NewStore() expected error for an unknown scheme")
+	}
+	if _, err := NewStore(ctx, "s3:///prefix"); err == nil {
+		t.Errorf("NewStore() expected error for a missing bucket")
+	}
+}
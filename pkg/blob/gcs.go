@@ -0,0 +1,76 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"cloud.google.com/go/storage"
+)
+
+type gcsStore struct {
+	client *storage.Client
+	bucket string
+	// prefix of the objects of the blobs
+	prefix string
+}
+
+// NewGCSStore returns a store keeping each blob in an object of bucket, named
+// by prefix and its key, authenticating with the application default
+// credentials
+func NewGCSStore(ctx context.Context, bucket string, prefix string) (*gcsStore, error) {
+	if bucket == "" {
+		return nil, errors.New("gcs bucket not specified")
+	}
+	client, err := storage.NewClient(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create gcs client with application default credentials: %w", err)
+	}
+	return &gcsStore{client: client, bucket: bucket, prefix: prefix}, nil
+}
+
+// Put writes data to the object of key
+func (g *gcsStore) Put(ctx context.Context, key string, data []byte) error {
+	w := g.client.Bucket(g.bucket).Object(g.prefix + key).NewWriter(ctx)
+	if _, err := w.Write(data); err != nil {
+		_ = w.Close()
+		return fmt.Errorf("failed to write blob %s to bucket %s: %w", key, g.bucket, err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to write blob %s to bucket %s: %w", key, g.bucket, err)
+	}
+	return nil
+}
+
+// Get reads the object of key
+func (g *gcsStore) Get(ctx context.Context, key string) ([]byte, error) {
+	r, err := g.client.Bucket(g.bucket).Object(g.prefix + key).NewReader(ctx)
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, key)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read blob %s from bucket %s: %w", key, g.bucket, err)
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read blob %s from bucket %s: %w", key, g.bucket, err)
+	}
+	return data, nil
+}
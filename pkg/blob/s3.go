@@ -0,0 +1,103 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blob
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	awss3 "github.com/aws/aws-sdk-go/service/s3"
+)
+
+// defaultEndpointRegion is the region used with a custom endpoint when none
+// is configured, as S3 compatible stores such as MinIO accept any
+const defaultEndpointRegion = "us-east-1"
+
+type s3Store struct {
+	client *awss3.S3
+	bucket string
+	// prefix of the keys of the objects of the blobs
+	prefix string
+}
+
+// NewS3Store returns a store keeping each blob in an object of bucket, keyed
+// by prefix and its key, authenticating with the default AWS credential
+// chain. region overrides the region of the environment and shared config,
+// and endpoint, if set, is that of an S3 compatible store, e.g.
+// http://minio:9000, addressing the buckets by path.
+func NewS3Store(bucket string, prefix string, region string, endpoint string) (*s3Store, error) {
+	if bucket == "" {
+		return nil, errors.New("s3 bucket not specified")
+	}
+	cfg := aws.NewConfig()
+	if region != "" {
+		cfg = cfg.WithRegion(region)
+	}
+	if endpoint != "" {
+		cfg = cfg.WithEndpoint(endpoint).WithS3ForcePathStyle(true)
+	}
+	sess, err := session.NewSessionWithOptions(session.Options{
+		Config:            *cfg,
+		SharedConfigState: session.SharedConfigEnable,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create s3 session: %w", err)
+	}
+	if endpoint != "" && aws.StringValue(sess.Config.Region) == "" {
+		sess.Config.Region = aws.String(defaultEndpointRegion)
+	}
+	return &s3Store{client: awss3.New(sess), bucket: bucket, prefix: prefix}, nil
+}
+
+// Put writes data to the object of key
+func (s *s3Store) Put(ctx context.Context, key string, data []byte) error {
+	_, err := s.client.PutObjectWithContext(ctx, &awss3.PutObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.prefix + key),
+		Body:   bytes.NewReader(data),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write blob %s to bucket %s: %w", key, s.bucket, err)
+	}
+	return nil
+}
+
+// Get reads the object of key
+func (s *s3Store) Get(ctx context.Context, key string) ([]byte, error) {
+	out, err := s.client.GetObjectWithContext(ctx, &awss3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.prefix + key),
+	})
+	var aerr awserr.Error
+	if errors.As(err, &aerr) && aerr.Code() == awss3.ErrCodeNoSuchKey {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, key)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read blob %s from bucket %s: %w", key, s.bucket, err)
+	}
+	defer out.Body.Close()
+	data, err := io.ReadAll(out.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read blob %s from bucket %s: %w", key, s.bucket, err)
+	}
+	return data, nil
+}
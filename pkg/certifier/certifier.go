@@ -45,7 +45,14 @@ type ErrHandler func(error) bool
 type CertifierType string
 
 const (
-	CertifierOSV CertifierType = "OSV"
+	CertifierOSV     CertifierType = "OSV"
+	CertifierDepsDev CertifierType = "DEPS_DEV"
+	// CertifierOSVFeed attaches the OSV entries of the vulnerabilities of
+	// the packages
+	CertifierOSVFeed CertifierType = "OSV_FEED"
+	// CertifierScorecard attaches the OpenSSF Scorecard results of the
+	// source repositories of the packages
+	CertifierScorecard CertifierType = "SCORECARD"
 )
 
 // Component represents the top level package node and its dependencies
@@ -19,11 +19,13 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"time"
 
 	"github.com/guacsec/guac/pkg/certifier"
 	"github.com/guacsec/guac/pkg/certifier/osv"
 	"github.com/guacsec/guac/pkg/emitter"
 	"github.com/guacsec/guac/pkg/handler/processor"
+	"github.com/guacsec/guac/pkg/handler/processor/hashcache"
 	"github.com/guacsec/guac/pkg/logging"
 )
 
@@ -53,6 +55,7 @@ func RegisterCertifier(c func() certifier.Certifier, certifierType certifier.Cer
 // it scans and generate vulnerability attestation for each package. Aggregating the results to the
 // top/root level package
 func Certify(ctx context.Context, query certifier.QueryComponents, emitter certifier.Emitter, handleErr certifier.ErrHandler) error {
+	ctx = logging.WithComponent(ctx, logging.ComponentCertifier)
 
 	// docChan to collect artifacts
 	compChan := make(chan *certifier.Component, BufferChannelSize)
@@ -89,6 +92,25 @@ func Certify(ctx context.Context, query certifier.QueryComponents, emitter certi
 	return nil
 }
 
+// CertifyPeriodically runs Certify every interval until the context is done,
+// so that the packages added to the graph since the last run are certified
+// and the results of the certifiers are kept up to date. It returns nil when
+// the context is done, or the first error of Certify.
+func CertifyPeriodically(ctx context.Context, query certifier.QueryComponents, interval time.Duration, emitter certifier.Emitter, handleErr certifier.ErrHandler) error {
+	logger := logging.FromContext(ctx)
+	for {
+		if err := Certify(ctx, query, emitter, handleErr); err != nil {
+			return err
+		}
+		logger.Infof("certified the packages of the graph, next run in %v", interval)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(interval):
+		}
+	}
+}
+
 // generateDocuments runs CertifyVulns as a goroutine to scan and generate a vulnerability certification that
 // are emitted as processor documents to be ingested
 func generateDocuments(ctx context.Context, collectedComponent *certifier.Component, emitter certifier.Emitter, handleErr certifier.ErrHandler) error {
@@ -138,7 +160,10 @@ func Publish(ctx context.Context, d *processor.Document) error {
 	if err != nil {
 		return fmt.Errorf("failed marshal of document: %w", err)
 	}
-	err = emitter.Publish(ctx, emitter.SubjectNameDocCollected, docByte)
+	// keyed on the content digest, so that JetStream drops documents
+	// collected again within its duplicate window, and ordered by source, so
+	// that the versions of a document are ingested in the order collected
+	err = emitter.PublishWithMsgID(emitter.WithOrderingKey(ctx, d.SourceInformation.Source), emitter.SubjectNameDocCollected, hashcache.HashDocument(d), docByte)
 	if err != nil {
 		return err
 	}
@@ -130,6 +130,49 @@ func TestCertify(t *testing.T) {
 	}
 }
 
+type countingQuery struct {
+	runs   int
+	cancel context.CancelFunc
+}
+
+// GetComponents cancels the context on the second run, and returns no
+// components so that no certifier is run
+func (q *countingQuery) GetComponents(ctx context.Context, compChan chan<- *certifier.Component) error {
+	q.runs++
+	if q.runs == 2 {
+		q.cancel()
+	}
+	return nil
+}
+
+func TestCertifyPeriodically(t *testing.T) {
+	ctx, cancel := context.WithCancel(logging.WithLogger(context.Background()))
+	defer cancel()
+	query := &countingQuery{cancel: cancel}
+	emit := func(d *processor.Document) error {
+		return nil
+	}
+	errHandler := func(err error) bool {
+		return err == nil
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- CertifyPeriodically(ctx, query, time.Millisecond, emit, errHandler)
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("CertifyPeriodically() error = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("CertifyPeriodically() did not return when the context was done")
+	}
+	if query.runs != 2 {
+		t.Errorf("got %d runs, want 2", query.runs)
+	}
+}
+
 func Test_Publish(t *testing.T) {
 	expectedDocTree := dochelper.DocNode(&testdata.Ite6SLSADoc)
 
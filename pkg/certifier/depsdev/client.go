@@ -0,0 +1,243 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package depsdev
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultBaseURL is the URL of the deps.dev API
+const DefaultBaseURL = "https://api.deps.dev"
+
+// errNotFound is returned for the packages, versions and projects deps.dev
+// does not know about
+var errNotFound = errors.New("not found on deps.dev")
+
+// versionKey identifies a package version on deps.dev
+type versionKey struct {
+	System  string `json:"system"`
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// versionResponse is the part of the GetVersion response used
+type versionResponse struct {
+	VersionKey      versionKey       `json:"versionKey"`
+	Licenses        []string         `json:"licenses"`
+	RelatedProjects []relatedProject `json:"relatedProjects"`
+}
+
+type relatedProject struct {
+	ProjectKey struct {
+		ID string `json:"id"`
+	} `json:"projectKey"`
+	RelationType string `json:"relationType"`
+}
+
+// dependenciesResponse is the resolved dependency graph of a version, the
+// first node being the version itself
+type dependenciesResponse struct {
+	Nodes []struct {
+		VersionKey versionKey `json:"versionKey"`
+		Relation   string     `json:"relation"`
+	} `json:"nodes"`
+	Edges []struct {
+		FromNode int `json:"fromNode"`
+		ToNode   int `json:"toNode"`
+	} `json:"edges"`
+}
+
+// projectResponse is the part of the GetProject response used
+type projectResponse struct {
+	Scorecard *projectScorecard `json:"scorecard"`
+}
+
+type projectScorecard struct {
+	Date       string `json:"date"`
+	Repository struct {
+		Name   string `json:"name"`
+		Commit string `json:"commit"`
+	} `json:"repository"`
+	Scorecard struct {
+		Version string `json:"version"`
+		Commit  string `json:"commit"`
+	} `json:"scorecard"`
+	Checks []struct {
+		Name          string `json:"name"`
+		Documentation struct {
+			ShortDescription string `json:"shortDescription"`
+			URL              string `json:"url"`
+		} `json:"documentation"`
+		Score   int      `json:"score"`
+		Reason  string   `json:"reason"`
+		Details []string `json:"details"`
+	} `json:"checks"`
+	OverallScore float64 `json:"overallScore"`
+}
+
+type client struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+func newClient(baseURL string) *client {
+	return &client{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+func (c *client) get(ctx context.Context, path string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return errNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("deps.dev request %s failed with status %s: %s", path, resp.Status, strings.TrimSpace(string(body)))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed to decode deps.dev response of %s: %w", path, err)
+	}
+	return nil
+}
+
+func versionPath(key versionKey) string {
+	return fmt.Sprintf("/v3/systems/%s/packages/%s/versions/%s",
+		url.PathEscape(strings.ToLower(key.System)), url.PathEscape(key.Name), url.PathEscape(key.Version))
+}
+
+func projectPath(id string) string {
+	return "/v3/projects/" + url.PathEscape(id)
+}
+
+func (c *client) getVersion(ctx context.Context, key versionKey) (*versionResponse, error) {
+	var v versionResponse
+	if err := c.get(ctx, versionPath(key), &v); err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+func (c *client) getDependencies(ctx context.Context, key versionKey) (*dependenciesResponse, error) {
+	var d dependenciesResponse
+	if err := c.get(ctx, versionPath(key)+":dependencies", &d); err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+func (c *client) getProject(ctx context.Context, id string) (*projectResponse, error) {
+	var p projectResponse
+	if err := c.get(ctx, projectPath(id), &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// purl types of the package systems of deps.dev
+var purlSystems = map[string]string{
+	"npm":    "NPM",
+	"golang": "GO",
+	"maven":  "MAVEN",
+	"pypi":   "PYPI",
+	"cargo":  "CARGO",
+	"nuget":  "NUGET",
+}
+
+// versionKeyFromPurl returns the deps.dev key of the package version of
+// purl, and false if deps.dev does not know about its type or it has no
+// version
+func versionKeyFromPurl(purl string) (versionKey, bool) {
+	if !strings.HasPrefix(purl, "pkg:") {
+		return versionKey{}, false
+	}
+	rest := strings.TrimPrefix(purl, "pkg:")
+	rest, _, _ = strings.Cut(rest, "#")
+	rest, _, _ = strings.Cut(rest, "?")
+	typ, path, ok := strings.Cut(rest, "/")
+	if !ok {
+		return versionKey{}, false
+	}
+	system, ok := purlSystems[strings.ToLower(typ)]
+	if !ok {
+		return versionKey{}, false
+	}
+	at := strings.LastIndex(path, "@")
+	if at < 0 || at == len(path)-1 {
+		return versionKey{}, false
+	}
+	version, err := url.PathUnescape(path[at+1:])
+	if err != nil {
+		return versionKey{}, false
+	}
+	segments := strings.Split(strings.Trim(path[:at], "/"), "/")
+	for i, s := range segments {
+		if segments[i], err = url.PathUnescape(s); err != nil {
+			return versionKey{}, false
+		}
+	}
+	name := strings.Join(segments, "/")
+	if system == "MAVEN" {
+		if len(segments) != 2 {
+			return versionKey{}, false
+		}
+		name = segments[0] + ":" + segments[1]
+	}
+	return versionKey{System: system, Name: name, Version: version}, true
+}
+
+// purl returns the purl of the package version
+func (k versionKey) purl() string {
+	var typ, path string
+	switch k.System {
+	case "NPM":
+		typ = "npm"
+		if scope, name, ok := strings.Cut(k.Name, "/"); ok {
+			// the @ of npm scopes is percent-encoded in purls
+			path = strings.Replace(url.PathEscape(scope), "@", "%40", 1) + "/" + url.PathEscape(name)
+		} else {
+			path = url.PathEscape(k.Name)
+		}
+	case "GO":
+		typ = "golang"
+		path = k.Name
+	case "MAVEN":
+		typ = "maven"
+		group, artifact, _ := strings.Cut(k.Name, ":")
+		path = url.PathEscape(group) + "/" + url.PathEscape(artifact)
+	default:
+		typ = strings.ToLower(k.System)
+		path = url.PathEscape(k.Name)
+	}
+	return fmt.Sprintf("pkg:%s/%s@%s", typ, path, url.PathEscape(k.Version))
+}
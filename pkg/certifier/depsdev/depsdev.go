@@ -0,0 +1,264 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package depsdev
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	cdx "github.com/CycloneDX/cyclonedx-go"
+	"github.com/guacsec/guac/pkg/certifier"
+	"github.com/guacsec/guac/pkg/handler/processor"
+	"github.com/guacsec/guac/pkg/logging"
+)
+
+const (
+	// DepsDevCollector is the collector and source of the documents
+	// synthesized from deps.dev data
+	DepsDevCollector string = "deps.dev"
+)
+
+type depsDevCertifier struct {
+	client *client
+}
+
+// NewDepsDevCertifier initializes a certifier enriching the packages of the
+// graph with the dependencies, licenses and scorecards deps.dev has for them
+func NewDepsDevCertifier() certifier.Certifier {
+	return newDepsDevCertifier(DefaultBaseURL)
+}
+
+func newDepsDevCertifier(baseURL string) *depsDevCertifier {
+	return &depsDevCertifier{client: newClient(baseURL)}
+}
+
+// CertifyComponent walks the root component and its dependencies and, for
+// each package version known to deps.dev, emits a CycloneDX document with
+// its resolved dependency graph and licenses and a scorecard document for
+// its source repository. Packages that cannot be looked up are skipped.
+func (d *depsDevCertifier) CertifyComponent(ctx context.Context, rootComponent *certifier.Component, docChannel chan<- *processor.Document) error {
+	logger := logging.FromContext(ctx)
+	visited := map[string]bool{}
+	projects := map[string]bool{}
+
+	var walk func(c *certifier.Component) error
+	walk = func(c *certifier.Component) error {
+		if visited[c.Package.Purl] {
+			return nil
+		}
+		visited[c.Package.Purl] = true
+		if err := d.certifyPackage(ctx, c.Package.Purl, projects, docChannel); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			logger.Errorf("failed to get deps.dev data of %s: %v", c.Package.Purl, err)
+		}
+		for _, dep := range c.DepPackages {
+			if err := walk(dep); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return walk(rootComponent)
+}
+
+func (d *depsDevCertifier) certifyPackage(ctx context.Context, purl string, projects map[string]bool, docChannel chan<- *processor.Document) error {
+	key, ok := versionKeyFromPurl(purl)
+	if !ok {
+		return nil
+	}
+	version, err := d.client.getVersion(ctx, key)
+	if errors.Is(err, errNotFound) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	deps, err := d.client.getDependencies(ctx, key)
+	if err != nil && !errors.Is(err, errNotFound) {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := cdx.NewBOMEncoder(&buf, cdx.BOMFileFormatJSON).Encode(generateBOM(purl, version, deps)); err != nil {
+		return fmt.Errorf("failed to encode CycloneDX BOM: %w", err)
+	}
+	docChannel <- generateDocument(buf.Bytes(), processor.DocumentCycloneDX, d.client.baseURL+versionPath(key))
+
+	for _, p := range version.RelatedProjects {
+		id := p.ProjectKey.ID
+		if p.RelationType != "SOURCE_REPO" || projects[id] {
+			continue
+		}
+		projects[id] = true
+		project, err := d.client.getProject(ctx, id)
+		if errors.Is(err, errNotFound) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		if project.Scorecard == nil {
+			continue
+		}
+		payload, err := json.Marshal(generateScorecard(project.Scorecard))
+		if err != nil {
+			return err
+		}
+		docChannel <- generateDocument(payload, processor.DocumentScorecard, d.client.baseURL+projectPath(id))
+	}
+	return nil
+}
+
+// generateBOM returns a CycloneDX BOM describing the package version of
+// purl, with its resolved dependencies as components
+func generateBOM(purl string, version *versionResponse, deps *dependenciesResponse) *cdx.BOM {
+	bom := cdx.NewBOM()
+	root := cdx.Component{
+		BOMRef:     purl,
+		Type:       cdx.ComponentTypeLibrary,
+		Name:       version.VersionKey.Name,
+		Version:    version.VersionKey.Version,
+		PackageURL: purl,
+	}
+	if len(version.Licenses) > 0 {
+		root.Licenses = &cdx.Licenses{cdx.LicenseChoice{Expression: strings.Join(version.Licenses, " AND ")}}
+	}
+	var refs []cdx.ExternalReference
+	for _, p := range version.RelatedProjects {
+		if p.RelationType == "SOURCE_REPO" {
+			refs = append(refs, cdx.ExternalReference{Type: cdx.ERTypeVCS, URL: "https://" + p.ProjectKey.ID})
+		}
+	}
+	if len(refs) > 0 {
+		root.ExternalReferences = &refs
+	}
+	bom.Metadata = &cdx.Metadata{
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+		Tools:     &[]cdx.Tool{{Name: DepsDevCollector}},
+		Component: &root,
+	}
+
+	components := []cdx.Component{}
+	dependencies := []cdx.Dependency{}
+	if deps != nil {
+		nodeRefs := make([]string, len(deps.Nodes))
+		for i, n := range deps.Nodes {
+			if n.Relation == "SELF" {
+				nodeRefs[i] = purl
+				continue
+			}
+			nodeRefs[i] = n.VersionKey.purl()
+			components = append(components, cdx.Component{
+				BOMRef:     nodeRefs[i],
+				Type:       cdx.ComponentTypeLibrary,
+				Name:       n.VersionKey.Name,
+				Version:    n.VersionKey.Version,
+				PackageURL: nodeRefs[i],
+			})
+		}
+		dependsOn := map[string][]string{}
+		var order []string
+		for _, e := range deps.Edges {
+			if e.FromNode < 0 || e.FromNode >= len(nodeRefs) || e.ToNode < 0 || e.ToNode >= len(nodeRefs) {
+				continue
+			}
+			from := nodeRefs[e.FromNode]
+			if _, ok := dependsOn[from]; !ok {
+				order = append(order, from)
+			}
+			dependsOn[from] = append(dependsOn[from], nodeRefs[e.ToNode])
+		}
+		for _, ref := range order {
+			refs := dependsOn[ref]
+			dependencies = append(dependencies, cdx.Dependency{Ref: ref, Dependencies: &refs})
+		}
+	}
+	bom.Components = &components
+	bom.Dependencies = &dependencies
+	return bom
+}
+
+// scorecardResult mirrors the JSON format of scorecard results the
+// scorecard parser ingests
+type scorecardResult struct {
+	Date string `json:"date"`
+	Repo struct {
+		Name   string `json:"name"`
+		Commit string `json:"commit"`
+	} `json:"repo"`
+	Scorecard struct {
+		Version string `json:"version"`
+		Commit  string `json:"commit"`
+	} `json:"scorecard"`
+	Score    float64                `json:"score"`
+	Checks   []scorecardCheckResult `json:"checks"`
+	Metadata []string               `json:"metadata"`
+}
+
+type scorecardCheckResult struct {
+	Details       []string `json:"details"`
+	Score         int      `json:"score"`
+	Reason        string   `json:"reason"`
+	Name          string   `json:"name"`
+	Documentation struct {
+		URL   string `json:"url"`
+		Short string `json:"short"`
+	} `json:"documentation"`
+}
+
+func generateScorecard(s *projectScorecard) *scorecardResult {
+	result := &scorecardResult{
+		Date:   s.Date,
+		Score:  s.OverallScore,
+		Checks: []scorecardCheckResult{},
+	}
+	result.Repo.Name = s.Repository.Name
+	result.Repo.Commit = s.Repository.Commit
+	result.Scorecard.Version = s.Scorecard.Version
+	result.Scorecard.Commit = s.Scorecard.Commit
+	for _, c := range s.Checks {
+		check := scorecardCheckResult{
+			Details: c.Details,
+			Score:   c.Score,
+			Reason:  c.Reason,
+			Name:    c.Name,
+		}
+		check.Documentation.URL = c.Documentation.URL
+		check.Documentation.Short = c.Documentation.ShortDescription
+		result.Checks = append(result.Checks, check)
+	}
+	return result
+}
+
+// generateDocument returns the document of the payload read from the deps.dev
+// API at the uri
+func generateDocument(payload []byte, docType processor.DocumentType, uri string) *processor.Document {
+	return &processor.Document{
+		Blob:   payload,
+		Type:   docType,
+		Format: processor.FormatJSON,
+		SourceInformation: processor.SourceInformation{
+			Collector: DepsDevCollector,
+			Source:    DepsDevCollector,
+			URI:       uri,
+		},
+	}
+}
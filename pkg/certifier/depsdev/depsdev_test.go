@@ -0,0 +1,216 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package depsdev
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/guacsec/guac/pkg/assembler"
+	"github.com/guacsec/guac/pkg/certifier"
+	"github.com/guacsec/guac/pkg/handler/processor"
+)
+
+func Test_versionKeyFromPurl(t *testing.T) {
+	tests := []struct {
+		purl   string
+		want   versionKey
+		wantOk bool
+	}{{
+		purl:   "pkg:npm/%40babel/core@7.20.12",
+		want:   versionKey{System: "NPM", Name: "@babel/core", Version: "7.20.12"},
+		wantOk: true,
+	}, {
+		purl:   "pkg:golang/github.com/sirupsen/logrus@v1.9.0",
+		want:   versionKey{System: "GO", Name: "github.com/sirupsen/logrus", Version: "v1.9.0"},
+		wantOk: true,
+	}, {
+		purl:   "pkg:maven/org.apache.logging.log4j/log4j-core@2.17.1?type=jar",
+		want:   versionKey{System: "MAVEN", Name: "org.apache.logging.log4j:log4j-core", Version: "2.17.1"},
+		wantOk: true,
+	}, {
+		purl:   "pkg:pypi/django@4.1.5",
+		want:   versionKey{System: "PYPI", Name: "django", Version: "4.1.5"},
+		wantOk: true,
+	}, {
+		purl: "pkg:deb/debian/curl@7.74.0",
+	}, {
+		purl: "pkg:npm/lodash",
+	}}
+	for _, tt := range tests {
+		t.Run(tt.purl, func(t *testing.T) {
+			got, ok := versionKeyFromPurl(tt.purl)
+			if ok != tt.wantOk {
+				t.Fatalf("versionKeyFromPurl() ok = %v, want %v", ok, tt.wantOk)
+			}
+			if got != tt.want {
+				t.Errorf("versionKeyFromPurl() = %v, want %v", got, tt.want)
+			}
+			if ok && tt.want.System != "MAVEN" && got.purl() != tt.purl {
+				t.Errorf("purl() = %v, want %v", got.purl(), tt.purl)
+			}
+		})
+	}
+}
+
+func newTestServer(t *testing.T) *httptest.Server {
+	responses := map[string]string{
+		"/v3/systems/npm/packages/a/versions/1.0.0": `{
+			"versionKey": {"system": "NPM", "name": "a", "version": "1.0.0"},
+			"licenses": ["MIT"],
+			"relatedProjects": [{"projectKey": {"id": "github.com/example/a"}, "relationType": "SOURCE_REPO"}]
+		}`,
+		"/v3/systems/npm/packages/a/versions/1.0.0:dependencies": `{
+			"nodes": [
+				{"versionKey": {"system": "NPM", "name": "a", "version": "1.0.0"}, "relation": "SELF"},
+				{"versionKey": {"system": "NPM", "name": "@scope/b", "version": "2.0.0"}, "relation": "DIRECT"},
+				{"versionKey": {"system": "NPM", "name": "c", "version": "3.0.0"}, "relation": "INDIRECT"}
+			],
+			"edges": [{"fromNode": 0, "toNode": 1}, {"fromNode": 1, "toNode": 2}]
+		}`,
+		"/v3/systems/npm/packages/d/versions/1.0.0": `{
+			"versionKey": {"system": "NPM", "name": "d", "version": "1.0.0"},
+			"relatedProjects": [{"projectKey": {"id": "github.com/example/a"}, "relationType": "SOURCE_REPO"}]
+		}`,
+		"/v3/projects/github.com%2Fexample%2Fa": `{
+			"scorecard": {
+				"date": "2023-01-02T00:00:00Z",
+				"repository": {"name": "github.com/example/a", "commit": "0123456789012345678901234567890123456789"},
+				"scorecard": {"version": "v4.10.2", "commit": "9876543210987654321098765432109876543210"},
+				"checks": [{"name": "Code-Review", "documentation": {"shortDescription": "short", "url": "https://example.com"}, "score": 8, "reason": "reason", "details": []}],
+				"overallScore": 7.5
+			}
+		}`,
+	}
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := responses[r.URL.EscapedPath()]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func Test_depsDevCertifier_CertifyComponent(t *testing.T) {
+	s := newTestServer(t)
+	d := newDepsDevCertifier(s.URL)
+
+	root := &certifier.Component{
+		Package: assembler.PackageNode{Purl: "pkg:npm/a@1.0.0"},
+		DepPackages: []*certifier.Component{
+			{Package: assembler.PackageNode{Purl: "pkg:npm/d@1.0.0"}},
+			{Package: assembler.PackageNode{Purl: "pkg:npm/unknown@1.0.0"}},
+			{Package: assembler.PackageNode{Purl: "pkg:deb/debian/curl@7.74.0"}},
+			{Package: assembler.PackageNode{Purl: "pkg:npm/a@1.0.0"}},
+		},
+	}
+	docChannel := make(chan *processor.Document, 10)
+	if err := d.CertifyComponent(context.Background(), root, docChannel); err != nil {
+		t.Fatalf("CertifyComponent() error = %v", err)
+	}
+	close(docChannel)
+	var docs []*processor.Document
+	for doc := range docChannel {
+		docs = append(docs, doc)
+	}
+
+	var gotTypes []processor.DocumentType
+	var gotURIs []string
+	for _, doc := range docs {
+		gotTypes = append(gotTypes, doc.Type)
+		gotURIs = append(gotURIs, doc.SourceInformation.URI)
+		if doc.Format != processor.FormatJSON || doc.SourceInformation.Source != DepsDevCollector {
+			t.Errorf("unexpected document %+v", doc)
+		}
+	}
+	wantTypes := []processor.DocumentType{processor.DocumentCycloneDX, processor.DocumentScorecard, processor.DocumentCycloneDX}
+	if !reflect.DeepEqual(gotTypes, wantTypes) {
+		t.Fatalf("got document types %v, want %v", gotTypes, wantTypes)
+	}
+	wantURIs := []string{
+		s.URL + "/v3/systems/npm/packages/a/versions/1.0.0",
+		s.URL + "/v3/projects/github.com%2Fexample%2Fa",
+		s.URL + "/v3/systems/npm/packages/d/versions/1.0.0",
+	}
+	if !reflect.DeepEqual(gotURIs, wantURIs) {
+		t.Errorf("got document URIs %v, want %v", gotURIs, wantURIs)
+	}
+
+	var bom struct {
+		Metadata struct {
+			Component struct {
+				BOMRef   string `json:"bom-ref"`
+				Licenses []struct {
+					Expression string `json:"expression"`
+				} `json:"licenses"`
+			} `json:"component"`
+		} `json:"metadata"`
+		Components []struct {
+			PackageURL string `json:"purl"`
+		} `json:"components"`
+		Dependencies []struct {
+			Ref       string   `json:"ref"`
+			DependsOn []string `json:"dependsOn"`
+		} `json:"dependencies"`
+	}
+	if err := json.Unmarshal(docs[0].Blob, &bom); err != nil {
+		t.Fatalf("failed to unmarshal BOM: %v", err)
+	}
+	if bom.Metadata.Component.BOMRef != "pkg:npm/a@1.0.0" || len(bom.Metadata.Component.Licenses) != 1 ||
+		bom.Metadata.Component.Licenses[0].Expression != "MIT" {
+		t.Errorf("unexpected BOM metadata component %+v", bom.Metadata.Component)
+	}
+	if len(bom.Components) != 2 || bom.Components[0].PackageURL != "pkg:npm/%40scope/b@2.0.0" ||
+		bom.Components[1].PackageURL != "pkg:npm/c@3.0.0" {
+		t.Errorf("unexpected BOM components %+v", bom.Components)
+	}
+	wantDeps := map[string][]string{
+		"pkg:npm/a@1.0.0":          {"pkg:npm/%40scope/b@2.0.0"},
+		"pkg:npm/%40scope/b@2.0.0": {"pkg:npm/c@3.0.0"},
+	}
+	gotDeps := map[string][]string{}
+	for _, dep := range bom.Dependencies {
+		gotDeps[dep.Ref] = dep.DependsOn
+	}
+	if !reflect.DeepEqual(gotDeps, wantDeps) {
+		t.Errorf("got BOM dependencies %v, want %v", gotDeps, wantDeps)
+	}
+
+	var scorecard struct {
+		Repo struct {
+			Name string `json:"name"`
+		} `json:"repo"`
+		Score  float64 `json:"score"`
+		Checks []struct {
+			Name  string `json:"name"`
+			Score int    `json:"score"`
+		} `json:"checks"`
+	}
+	if err := json.Unmarshal(docs[1].Blob, &scorecard); err != nil {
+		t.Fatalf("failed to unmarshal scorecard: %v", err)
+	}
+	if scorecard.Repo.Name != "github.com/example/a" || scorecard.Score != 7.5 ||
+		len(scorecard.Checks) != 1 || scorecard.Checks[0].Name != "Code-Review" || scorecard.Checks[0].Score != 8 {
+		t.Errorf("unexpected scorecard %+v", scorecard)
+	}
+}
@@ -0,0 +1,206 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package osv
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+
+	"github.com/guacsec/guac/pkg/certifier"
+	"github.com/guacsec/guac/pkg/handler/processor"
+	"github.com/guacsec/guac/pkg/logging"
+)
+
+const (
+	// osvAPI is the OSV.dev API the feed certifier queries
+	osvAPI = "https://api.osv.dev"
+	// maxQueriesPerBatch is the limit of queries of a querybatch request
+	maxQueriesPerBatch = 1000
+)
+
+// feedEntries are the modification times of the OSV entries emitted by the
+// feed certifiers of the process, keyed by id, so that an entry is emitted
+// again only when it changes
+var feedEntries = struct {
+	sync.Mutex
+	modified map[string]string
+}{modified: map[string]string{}}
+
+type osvFeedCertifier struct {
+	client *http.Client
+	apiURL string
+}
+
+// NewOSVFeedCertifier initializes the certifier that queries OSV.dev for the
+// vulnerabilities of every package of the component and emits their OSV
+// entries, from which the vulnerabilities, their aliases and the affected
+// versions of the packages are ingested
+func NewOSVFeedCertifier() certifier.Certifier {
+	return &osvFeedCertifier{
+		client: http.DefaultClient,
+		apiURL: osvAPI,
+	}
+}
+
+type feedQuery struct {
+	Package struct {
+		Purl string `json:"purl"`
+	} `json:"package"`
+}
+
+type feedBatchResponse struct {
+	Results []struct {
+		Vulns []struct {
+			ID       string `json:"id"`
+			Modified string `json:"modified"`
+		} `json:"vulns"`
+	} `json:"results"`
+}
+
+// CertifyComponent queries the vulnerabilities of the root component and
+// its dependencies and emits the OSV entries that are new or have changed
+// since they were last emitted
+func (o *osvFeedCertifier) CertifyComponent(ctx context.Context, rootComponent *certifier.Component, docChannel chan<- *processor.Document) error {
+	logger := logging.FromContext(ctx)
+	purls := componentPurls(rootComponent, []string{}, map[string]bool{})
+	for start := 0; start < len(purls); start += maxQueriesPerBatch {
+		end := start + maxQueriesPerBatch
+		if end > len(purls) {
+			end = len(purls)
+		}
+		resp, err := o.queryBatch(ctx, purls[start:end])
+		if err != nil {
+			return fmt.Errorf("OSV query failed: %w", err)
+		}
+		for _, result := range resp.Results {
+			for _, vuln := range result.Vulns {
+				if !entryChanged(vuln.ID, vuln.Modified) {
+					continue
+				}
+				doc, err := o.getEntry(ctx, vuln.ID)
+				if err != nil {
+					logger.Warnf("unable to get OSV entry %s: %v", vuln.ID, err)
+					continue
+				}
+				docChannel <- doc
+				markEntry(vuln.ID, vuln.Modified)
+			}
+		}
+	}
+	return nil
+}
+
+// componentPurls returns the purls of the component and its dependencies,
+// each once
+func componentPurls(c *certifier.Component, purls []string, visited map[string]bool) []string {
+	if c == nil || visited[c.Package.Purl] {
+		return purls
+	}
+	visited[c.Package.Purl] = true
+	if c.Package.Purl != "" {
+		purls = append(purls, c.Package.Purl)
+	}
+	for _, dep := range c.DepPackages {
+		purls = componentPurls(dep, purls, visited)
+	}
+	return purls
+}
+
+func entryChanged(id string, modified string) bool {
+	feedEntries.Lock()
+	defer feedEntries.Unlock()
+	last, ok := feedEntries.modified[id]
+	return !ok || last != modified
+}
+
+func markEntry(id string, modified string) {
+	feedEntries.Lock()
+	defer feedEntries.Unlock()
+	feedEntries.modified[id] = modified
+}
+
+func (o *osvFeedCertifier) queryBatch(ctx context.Context, purls []string) (*feedBatchResponse, error) {
+	queries := make([]feedQuery, len(purls))
+	for i, purl := range purls {
+		queries[i].Package.Purl = purl
+	}
+	body, err := json.Marshal(map[string]interface{}{"queries": queries})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.apiURL+"/v1/querybatch", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	blob, err := o.do(req)
+	if err != nil {
+		return nil, err
+	}
+	var resp feedBatchResponse
+	if err := json.Unmarshal(blob, &resp); err != nil {
+		return nil, fmt.Errorf("unable to decode querybatch response: %w", err)
+	}
+	if len(resp.Results) != len(purls) {
+		return nil, fmt.Errorf("got %d querybatch results for %d queries", len(resp.Results), len(purls))
+	}
+	return &resp, nil
+}
+
+// getEntry returns the document of the OSV entry with the given id
+func (o *osvFeedCertifier) getEntry(ctx context.Context, id string) (*processor.Document, error) {
+	entryURL := o.apiURL + "/v1/vulns/" + url.PathEscape(id)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, entryURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	blob, err := o.do(req)
+	if err != nil {
+		return nil, err
+	}
+	return &processor.Document{
+		Blob:   blob,
+		Type:   processor.DocumentOSV,
+		Format: processor.FormatJSON,
+		SourceInformation: processor.SourceInformation{
+			Collector: INVOC_URI,
+			Source:    entryURL,
+			URI:       entryURL,
+		},
+	}, nil
+}
+
+func (o *osvFeedCertifier) do(req *http.Request) ([]byte, error) {
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	blob, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s %s: %s", req.Method, req.URL, resp.Status)
+	}
+	return blob, nil
+}
@@ -0,0 +1,120 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package osv
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/guacsec/guac/internal/testing/testdata"
+	"github.com/guacsec/guac/pkg/assembler"
+	"github.com/guacsec/guac/pkg/certifier"
+	"github.com/guacsec/guac/pkg/handler/processor"
+	"github.com/guacsec/guac/pkg/logging"
+)
+
+func TestOSVFeedCertifier_CertifyComponent(t *testing.T) {
+	ctx := logging.WithLogger(context.Background())
+	modified := "2023-01-11T05:04:33.611295Z"
+	var queried [][]string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/querybatch", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Queries []feedQuery `json:"queries"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		purls := []string{}
+		results := []interface{}{}
+		for _, q := range req.Queries {
+			purls = append(purls, q.Package.Purl)
+			vulns := []interface{}{}
+			if q.Package.Purl == "pkg:maven/org.apache.commons/commons-text@1.9" {
+				vulns = append(vulns, map[string]string{"id": "GHSA-599f-7c49-w659", "modified": modified})
+			}
+			results = append(results, map[string]interface{}{"vulns": vulns})
+		}
+		queried = append(queried, purls)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"results": results})
+	})
+	mux.HandleFunc("/v1/vulns/GHSA-599f-7c49-w659", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(testdata.OSVExample)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	feedEntries.modified = map[string]string{}
+	root := &certifier.Component{
+		Package: assembler.PackageNode{Purl: "pkg:maven/org.example/app@1.0"},
+		DepPackages: []*certifier.Component{
+			{Package: assembler.PackageNode{Purl: "pkg:maven/org.apache.commons/commons-text@1.9"}},
+			{Package: assembler.PackageNode{Purl: "pkg:maven/org.example/lib@2.0"}, DepPackages: []*certifier.Component{
+				{Package: assembler.PackageNode{Purl: "pkg:maven/org.apache.commons/commons-text@1.9"}},
+			}},
+		},
+	}
+	o := &osvFeedCertifier{client: server.Client(), apiURL: server.URL}
+
+	certify := func() []*processor.Document {
+		docChan := make(chan *processor.Document, 10)
+		if err := o.CertifyComponent(ctx, root, docChan); err != nil {
+			t.Fatalf("CertifyComponent() error = %v", err)
+		}
+		close(docChan)
+		docs := []*processor.Document{}
+		for d := range docChan {
+			docs = append(docs, d)
+		}
+		return docs
+	}
+
+	want := []*processor.Document{{
+		Blob:   testdata.OSVExample,
+		Type:   processor.DocumentOSV,
+		Format: processor.FormatJSON,
+		SourceInformation: processor.SourceInformation{
+			Collector: INVOC_URI,
+			Source:    server.URL + "/v1/vulns/GHSA-599f-7c49-w659",
+			URI:       server.URL + "/v1/vulns/GHSA-599f-7c49-w659",
+		},
+	}}
+	if docs := certify(); !reflect.DeepEqual(docs, want) {
+		t.Errorf("CertifyComponent() = %v, want %v", docs, want)
+	}
+	wantQueried := []string{
+		"pkg:maven/org.example/app@1.0",
+		"pkg:maven/org.apache.commons/commons-text@1.9",
+		"pkg:maven/org.example/lib@2.0",
+	}
+	if !reflect.DeepEqual(queried, [][]string{wantQueried}) {
+		t.Errorf("queried %v, want %v", queried, wantQueried)
+	}
+
+	// an unchanged entry is not emitted again
+	if docs := certify(); len(docs) != 0 {
+		t.Errorf("CertifyComponent() emitted %d unchanged entries", len(docs))
+	}
+	modified = "2023-02-01T00:00:00Z"
+	if docs := certify(); len(docs) != 1 {
+		t.Errorf("CertifyComponent() emitted %d documents for the modified entry, want 1", len(docs))
+	}
+}
@@ -52,6 +52,13 @@ func NewOSVCertificationParser() certifier.Certifier {
 // to generate vulnerability attestations
 func (o *osvCertifier) CertifyComponent(ctx context.Context, rootComponent *certifier.Component, docChannel chan<- *processor.Document) error {
 	o.rootComponents = rootComponent
+	if len(rootComponent.DepPackages) == 0 {
+		// a single package, e.g. looked up on demand, is scanned itself
+		// rather than aggregating the vulnerabilities of its dependencies
+		query, _ := getQuery(0, []assembler.PackageNode{rootComponent.Package})
+		_, err := getVulnerabilities(query, docChannel)
+		return err
+	}
 	m := make(map[string]bool)
 	_, err := o.certifyHelper(ctx, rootComponent, docChannel, m)
 	if err != nil {
@@ -165,6 +172,7 @@ func generateDocument(purl string, digest []string, vulns []osv_scanner.Entry) (
 		SourceInformation: processor.SourceInformation{
 			Collector: INVOC_URI,
 			Source:    INVOC_URI,
+			URI:       osv_query.QueryEndpoint,
 		},
 	}
 	return doc, nil
@@ -24,6 +24,7 @@ import (
 	"github.com/guacsec/guac/pkg/assembler"
 
 	attestation_vuln "github.com/guacsec/guac/pkg/certifier/attestation"
+	"github.com/guacsec/guac/pkg/certifier/osv/internal/osv_query"
 	intoto "github.com/in-toto/in-toto-golang/in_toto"
 	slsa "github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/v0.2"
 	osv_scanner "golang.org/x/vuln/osv"
@@ -54,6 +55,7 @@ func TestOSVCertifier_CertifyVulns(t *testing.T) {
 				SourceInformation: processor.SourceInformation{
 					Collector: INVOC_URI,
 					Source:    INVOC_URI,
+					URI:       osv_query.QueryEndpoint,
 				},
 			},
 			{
@@ -63,6 +65,7 @@ func TestOSVCertifier_CertifyVulns(t *testing.T) {
 				SourceInformation: processor.SourceInformation{
 					Collector: INVOC_URI,
 					Source:    INVOC_URI,
+					URI:       osv_query.QueryEndpoint,
 				},
 			},
 			{
@@ -72,6 +75,7 @@ func TestOSVCertifier_CertifyVulns(t *testing.T) {
 				SourceInformation: processor.SourceInformation{
 					Collector: INVOC_URI,
 					Source:    INVOC_URI,
+					URI:       osv_query.QueryEndpoint,
 				},
 			},
 			{
@@ -81,6 +85,7 @@ func TestOSVCertifier_CertifyVulns(t *testing.T) {
 				SourceInformation: processor.SourceInformation{
 					Collector: INVOC_URI,
 					Source:    INVOC_URI,
+					URI:       osv_query.QueryEndpoint,
 				},
 			},
 		},
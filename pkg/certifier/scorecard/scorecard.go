@@ -0,0 +1,337 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scorecard
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/guacsec/guac/pkg/certifier"
+	"github.com/guacsec/guac/pkg/handler/processor"
+	scorecard_processor "github.com/guacsec/guac/pkg/handler/processor/scorecard"
+	"github.com/guacsec/guac/pkg/logging"
+)
+
+const (
+	// scorecardAPI is the OpenSSF Scorecard API the certifier queries the
+	// weekly scorecard results of repositories from
+	scorecardAPI = "https://api.securityscorecards.dev"
+	INVOC_URI    = "guac"
+)
+
+// errNotScored is returned when there is no scorecard result for a
+// repository
+var errNotScored = errors.New("repository not scored")
+
+// scoredRepos are the results emitted by the scorecard certifiers of the
+// process, the commit of the repository and the version of scorecard that
+// evaluated it keyed by package purl, so that a result is emitted again
+// only when the repository is scored anew
+var scoredRepos = struct {
+	sync.Mutex
+	evaluated map[string]string
+}{evaluated: map[string]string{}}
+
+type scorecardCertifier struct {
+	// score returns the JSON scorecard result of the repository, e.g.
+	// github.com/ossf/scorecard
+	score func(ctx context.Context, repo string) ([]byte, error)
+	// source describes where the results come from, and uri locates them
+	source func(repo string) string
+	uri    func(repo string) string
+}
+
+// NewScorecardCertifier initializes the certifier that queries the OpenSSF
+// Scorecard API for the results of the source repositories of the packages
+func NewScorecardCertifier() certifier.Certifier {
+	return newAPICertifier(http.DefaultClient, scorecardAPI)
+}
+
+// NewScorecardRunnerCertifier initializes the certifier that runs the
+// scorecard binary at the given path against the source repositories of the
+// packages. The binary authenticates with the GITHUB_AUTH_TOKEN of the
+// environment.
+func NewScorecardRunnerCertifier(binary string) certifier.Certifier {
+	return &scorecardCertifier{
+		score: func(ctx context.Context, repo string) ([]byte, error) {
+			cmd := exec.CommandContext(ctx, binary, "--repo="+repo, "--format=json", "--show-details")
+			var stderr strings.Builder
+			cmd.Stderr = &stderr
+			out, err := cmd.Output()
+			if err != nil {
+				return nil, fmt.Errorf("scorecard failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+			}
+			return out, nil
+		},
+		source: func(repo string) string {
+			return repo
+		},
+		uri: func(repo string) string {
+			return "https://" + repo
+		},
+	}
+}
+
+func newAPICertifier(client *http.Client, apiURL string) *scorecardCertifier {
+	return &scorecardCertifier{
+		score: func(ctx context.Context, repo string) ([]byte, error) {
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, apiURL+"/projects/"+repo, nil)
+			if err != nil {
+				return nil, err
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				return nil, err
+			}
+			defer resp.Body.Close()
+			blob, err := io.ReadAll(resp.Body)
+			if err != nil {
+				return nil, err
+			}
+			switch resp.StatusCode {
+			case http.StatusOK:
+				return blob, nil
+			case http.StatusNotFound:
+				return nil, errNotScored
+			}
+			return nil, fmt.Errorf("%s %s: %s", req.Method, req.URL, resp.Status)
+		},
+		source: func(repo string) string {
+			return apiURL + "/projects/" + repo
+		},
+		uri: func(repo string) string {
+			return apiURL + "/projects/" + repo
+		},
+	}
+}
+
+// CertifyComponent scores the source repositories of the root component and
+// its dependencies and emits the scorecard results of the repositories that
+// were scored anew since they were last emitted, naming the packages built
+// from them in the metadata of the results
+func (s *scorecardCertifier) CertifyComponent(ctx context.Context, rootComponent *certifier.Component, docChannel chan<- *processor.Document) error {
+	logger := logging.FromContext(ctx)
+	repos := []string{}
+	repoPurls := map[string][]string{}
+	for _, purl := range componentPurls(rootComponent, []string{}, map[string]bool{}) {
+		repo, ok := SourceRepo(purl)
+		if !ok {
+			continue
+		}
+		if _, ok := repoPurls[repo]; !ok {
+			repos = append(repos, repo)
+		}
+		repoPurls[repo] = append(repoPurls[repo], purl)
+	}
+
+	for _, repo := range repos {
+		blob, err := s.score(ctx, repo)
+		if errors.Is(err, errNotScored) {
+			logger.Debugf("no scorecard result for %s", repo)
+			continue
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			logger.Warnf("unable to score %s: %v", repo, err)
+			continue
+		}
+		doc, evaluated, err := s.generateDocument(repo, blob, repoPurls[repo])
+		if err != nil {
+			logger.Warnf("unable to read the scorecard result of %s: %v", repo, err)
+			continue
+		}
+		if !resultChanged(repoPurls[repo], evaluated) {
+			continue
+		}
+		docChannel <- doc
+		markResult(repoPurls[repo], evaluated)
+	}
+	return nil
+}
+
+// generateDocument returns the document of the scorecard result with the
+// purls added to its metadata, and the commit and scorecard version the
+// result was evaluated at
+func (s *scorecardCertifier) generateDocument(repo string, blob []byte, purls []string) (*processor.Document, string, error) {
+	var result map[string]interface{}
+	if err := json.Unmarshal(blob, &result); err != nil {
+		return nil, "", err
+	}
+	var evaluated struct {
+		Repo struct {
+			Commit string `json:"commit"`
+		} `json:"repo"`
+		Scorecard struct {
+			Version string `json:"version"`
+		} `json:"scorecard"`
+	}
+	if err := json.Unmarshal(blob, &evaluated); err != nil {
+		return nil, "", err
+	}
+	if evaluated.Repo.Commit == "" {
+		return nil, "", errors.New("missing the commit of the repository")
+	}
+
+	metadata := []interface{}{}
+	if m, ok := result["metadata"].([]interface{}); ok {
+		metadata = m
+	}
+	for _, purl := range purls {
+		metadata = append(metadata, scorecard_processor.PackageMetadataPrefix+purl)
+	}
+	result["metadata"] = metadata
+	payload, err := json.Marshal(result)
+	if err != nil {
+		return nil, "", err
+	}
+	return &processor.Document{
+		Blob:   payload,
+		Type:   processor.DocumentScorecard,
+		Format: processor.FormatJSON,
+		SourceInformation: processor.SourceInformation{
+			Collector: INVOC_URI,
+			Source:    s.source(repo),
+			URI:       s.uri(repo),
+		},
+	}, evaluated.Repo.Commit + "@" + evaluated.Scorecard.Version, nil
+}
+
+// componentPurls returns the purls of the component and its dependencies,
+// each once
+func componentPurls(c *certifier.Component, purls []string, visited map[string]bool) []string {
+	if c == nil || visited[c.Package.Purl] {
+		return purls
+	}
+	visited[c.Package.Purl] = true
+	if c.Package.Purl != "" {
+		purls = append(purls, c.Package.Purl)
+	}
+	for _, dep := range c.DepPackages {
+		purls = componentPurls(dep, purls, visited)
+	}
+	return purls
+}
+
+// resultChanged returns whether the repository of any of the purls was not
+// yet emitted at the evaluation
+func resultChanged(purls []string, evaluated string) bool {
+	scoredRepos.Lock()
+	defer scoredRepos.Unlock()
+	for _, purl := range purls {
+		if scoredRepos.evaluated[purl] != evaluated {
+			return true
+		}
+	}
+	return false
+}
+
+func markResult(purls []string, evaluated string) {
+	scoredRepos.Lock()
+	defer scoredRepos.Unlock()
+	for _, purl := range purls {
+		scoredRepos.evaluated[purl] = evaluated
+	}
+}
+
+// SourceRepo returns the repository scorecard can score the package of the
+// purl was built from, e.g. github.com/ossf/scorecard: the repository of its
+// vcs_url qualifier, of a github or gitlab purl, or of a go module hosted on
+// GitHub or GitLab
+func SourceRepo(purl string) (string, bool) {
+	if !strings.HasPrefix(purl, "pkg:") {
+		return "", false
+	}
+	p := strings.TrimPrefix(purl, "pkg:")
+	if i := strings.Index(p, "#"); i >= 0 {
+		p = p[:i]
+	}
+	if i := strings.Index(p, "?"); i >= 0 {
+		// qualifier values are percent-encoded, a "+" is not a space
+		for _, q := range strings.Split(p[i+1:], "&") {
+			key, value, _ := strings.Cut(q, "=")
+			if key != "vcs_url" {
+				continue
+			}
+			if vcsURL, err := url.PathUnescape(value); err == nil {
+				if repo, ok := repoFromURL(vcsURL); ok {
+					return repo, true
+				}
+			}
+		}
+		p = p[:i]
+	}
+	if i := strings.LastIndex(p, "@"); i > strings.LastIndex(p, "/") {
+		p = p[:i]
+	}
+	purlType, path, ok := strings.Cut(p, "/")
+	if !ok {
+		return "", false
+	}
+	path, err := url.PathUnescape(path)
+	if err != nil {
+		return "", false
+	}
+	switch strings.ToLower(purlType) {
+	case "github":
+		return repoFromPath("github.com", path)
+	case "gitlab":
+		return repoFromPath("gitlab.com", path)
+	case "golang":
+		host, modPath, _ := strings.Cut(path, "/")
+		if host == "github.com" || host == "gitlab.com" {
+			return repoFromPath(host, modPath)
+		}
+	}
+	return "", false
+}
+
+// repoFromURL returns the repository of a git URL on GitHub or GitLab
+func repoFromURL(vcsURL string) (string, bool) {
+	vcsURL = strings.TrimPrefix(vcsURL, "git+")
+	if strings.HasPrefix(vcsURL, "git@") {
+		// scp-like syntax, git@github.com:owner/repo.git
+		vcsURL = "ssh://" + strings.Replace(vcsURL, ":", "/", 1)
+	}
+	u, err := url.Parse(vcsURL)
+	if err != nil {
+		return "", false
+	}
+	host := strings.ToLower(u.Hostname())
+	if host != "github.com" && host != "gitlab.com" {
+		return "", false
+	}
+	return repoFromPath(host, strings.TrimPrefix(u.Path, "/"))
+}
+
+// repoFromPath returns the owner/repo repository at the start of the path
+func repoFromPath(host string, path string) (string, bool) {
+	parts := strings.SplitN(path, "/", 3)
+	if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+		return "", false
+	}
+	repo := strings.TrimSuffix(parts[1], ".git")
+	return host + "/" + strings.ToLower(parts[0]) + "/" + strings.ToLower(repo), true
+}
@@ -0,0 +1,163 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scorecard
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/guacsec/guac/internal/testing/testdata"
+	"github.com/guacsec/guac/pkg/assembler"
+	"github.com/guacsec/guac/pkg/certifier"
+	"github.com/guacsec/guac/pkg/handler/processor"
+	"github.com/guacsec/guac/pkg/logging"
+)
+
+func TestScorecardCertifier_CertifyComponent(t *testing.T) {
+	ctx := logging.WithLogger(context.Background())
+	var scorecard map[string]interface{}
+	if err := json.Unmarshal(testdata.ScorecardExample, &scorecard); err != nil {
+		t.Fatal(err)
+	}
+	var requested []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/projects/", func(w http.ResponseWriter, r *http.Request) {
+		requested = append(requested, r.URL.Path)
+		if r.URL.Path != "/projects/github.com/kubernetes/kubernetes" {
+			http.NotFound(w, r)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(scorecard)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	scoredRepos.evaluated = map[string]string{}
+	root := &certifier.Component{
+		Package: assembler.PackageNode{Purl: "pkg:golang/github.com/kubernetes/kubernetes@v1.26.0"},
+		DepPackages: []*certifier.Component{
+			{Package: assembler.PackageNode{Purl: "pkg:golang/k8s.io/kubectl@v0.26.0?vcs_url=git%2Bhttps://github.com/kubernetes/kubernetes.git"}},
+			{Package: assembler.PackageNode{Purl: "pkg:github/guacsec/guac@v0.1.0"}},
+			{Package: assembler.PackageNode{Purl: "pkg:maven/org.apache.commons/commons-text@1.9"}},
+		},
+	}
+	s := newAPICertifier(server.Client(), server.URL)
+
+	certify := func() []*processor.Document {
+		docChan := make(chan *processor.Document, 10)
+		if err := s.CertifyComponent(ctx, root, docChan); err != nil {
+			t.Fatalf("CertifyComponent() error = %v", err)
+		}
+		close(docChan)
+		docs := []*processor.Document{}
+		for d := range docChan {
+			docs = append(docs, d)
+		}
+		return docs
+	}
+
+	docs := certify()
+	if len(docs) != 1 {
+		t.Fatalf("CertifyComponent() emitted %d documents, want 1", len(docs))
+	}
+	wantSource := processor.SourceInformation{
+		Collector: INVOC_URI,
+		Source:    server.URL + "/projects/github.com/kubernetes/kubernetes",
+		URI:       server.URL + "/projects/github.com/kubernetes/kubernetes",
+	}
+	if docs[0].Type != processor.DocumentScorecard || docs[0].SourceInformation != wantSource {
+		t.Errorf("CertifyComponent() = %+v, want a scorecard document from %+v", docs[0], wantSource)
+	}
+	var got struct {
+		Metadata []string `json:"metadata"`
+	}
+	if err := json.Unmarshal(docs[0].Blob, &got); err != nil {
+		t.Fatal(err)
+	}
+	wantMetadata := []string{
+		"package=pkg:golang/github.com/kubernetes/kubernetes@v1.26.0",
+		"package=pkg:golang/k8s.io/kubectl@v0.26.0?vcs_url=git%2Bhttps://github.com/kubernetes/kubernetes.git",
+	}
+	if !reflect.DeepEqual(got.Metadata, wantMetadata) {
+		t.Errorf("metadata = %v, want %v", got.Metadata, wantMetadata)
+	}
+	wantRequested := []string{"/projects/github.com/kubernetes/kubernetes", "/projects/github.com/guacsec/guac"}
+	if !reflect.DeepEqual(requested, wantRequested) {
+		t.Errorf("requested %v, want %v", requested, wantRequested)
+	}
+
+	// a repository that was not scored anew is not emitted again
+	if docs := certify(); len(docs) != 0 {
+		t.Errorf("CertifyComponent() emitted %d unchanged results", len(docs))
+	}
+	scorecard["repo"].(map[string]interface{})["commit"] = "0123456789abcdef0123456789abcdef01234567"
+	if docs := certify(); len(docs) != 1 {
+		t.Errorf("CertifyComponent() emitted %d documents for the new commit, want 1", len(docs))
+	}
+}
+
+func TestSourceRepo(t *testing.T) {
+	tests := []struct {
+		purl   string
+		want   string
+		wantOk bool
+	}{{
+		purl:   "pkg:github/ossf/Scorecard@v4.8.0",
+		want:   "github.com/ossf/scorecard",
+		wantOk: true,
+	}, {
+		purl:   "pkg:gitlab/gitlab-org/gitlab-runner",
+		want:   "gitlab.com/gitlab-org/gitlab-runner",
+		wantOk: true,
+	}, {
+		purl:   "pkg:golang/github.com/ossf/scorecard/v4@v4.8.0#pkg",
+		want:   "github.com/ossf/scorecard",
+		wantOk: true,
+	}, {
+		purl:   "pkg:npm/%40angular/core@15.0.0?vcs_url=git%2Bssh://git@github.com/angular/angular.git",
+		want:   "github.com/angular/angular",
+		wantOk: true,
+	}, {
+		purl:   "pkg:pypi/requests@2.28.0?vcs_url=git@github.com:psf/requests.git",
+		want:   "github.com/psf/requests",
+		wantOk: true,
+	}, {
+		purl:   "pkg:cargo/rand@0.8.5?arch=x86_64&vcs_url=git+https://github.com/rust-random/rand",
+		want:   "github.com/rust-random/rand",
+		wantOk: true,
+	}, {
+		purl:   "pkg:golang/golang.org/x/text@v0.6.0",
+		wantOk: false,
+	}, {
+		purl:   "pkg:github/ossf",
+		wantOk: false,
+	}, {
+		purl:   "github.com/ossf/scorecard",
+		wantOk: false,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.purl, func(t *testing.T) {
+			got, ok := SourceRepo(tt.purl)
+			if got != tt.want || ok != tt.wantOk {
+				t.Errorf("SourceRepo() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
@@ -0,0 +1,220 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import "time"
+
+// Commands are the settings of a single command, top-level keys named after
+// their flag, e.g. gcs-prefix
+type Commands struct {
+	Files         Files         `mapstructure:",squash"`
+	GCS           GCS           `mapstructure:",squash"`
+	S3            S3            `mapstructure:",squash"`
+	AzureBlob     AzureBlob     `mapstructure:",squash"`
+	Git           Git           `mapstructure:",squash"`
+	GitHubRelease GitHubRelease `mapstructure:",squash"`
+	ArtifactRepo  ArtifactRepo  `mapstructure:",squash"`
+	Kubernetes    Kubernetes    `mapstructure:",squash"`
+	Rekor         Rekor         `mapstructure:",squash"`
+	Push          Push          `mapstructure:",squash"`
+	Purl          Purl          `mapstructure:",squash"`
+	Certifier     Certifier     `mapstructure:",squash"`
+	Export        Export        `mapstructure:",squash"`
+	Snapshot      Snapshot      `mapstructure:",squash"`
+	Query         Query         `mapstructure:",squash"`
+	Prune         Prune         `mapstructure:",squash"`
+	VerifyGraph   VerifyGraph   `mapstructure:",squash"`
+	REST          REST          `mapstructure:",squash"`
+	GraphQL       GraphQL       `mapstructure:",squash"`
+	Daemon        Daemon        `mapstructure:",squash"`
+	DeadLetter    DeadLetter    `mapstructure:",squash"`
+	Queue         Queue         `mapstructure:",squash"`
+}
+
+// Files is the settings of the files command
+type Files struct {
+	// Mode is the pipeline stage run by the files command of guac-pubsub
+	Mode string `mapstructure:"mode"`
+	// Paths are the folders to collect, and Include and Exclude the glob
+	// patterns of the files to collect and skip, relative to each path
+	Paths   []string `mapstructure:"path"`
+	Include []string `mapstructure:"include"`
+	Exclude []string `mapstructure:"exclude"`
+	// CollectPath is the folder collected when no path is given, e.g. by
+	// the GUAC_COLLECT_PATH environment variable of a container
+	CollectPath string `mapstructure:"collect-path"`
+	// FileList is a file listing the files to collect instead of the paths
+	FileList string `mapstructure:"file-list"`
+	// NDJSON collects each line of the paths as a document
+	NDJSON bool `mapstructure:"ndjson"`
+	// Watch collects the files added to the folders once left unchanged for
+	// WatchSettle
+	Watch       bool          `mapstructure:"watch"`
+	WatchSettle time.Duration `mapstructure:"watch-settle"`
+}
+
+// GCS is the settings of the gcs command
+type GCS struct {
+	Prefix       string        `mapstructure:"gcs-prefix"`
+	PollInterval time.Duration `mapstructure:"gcs-poll-interval"`
+	UserProject  string        `mapstructure:"gcs-user-project"`
+	Subscription string        `mapstructure:"gcs-subscription"`
+}
+
+// S3 is the settings of the s3 command
+type S3 struct {
+	Prefix       string        `mapstructure:"s3-prefix"`
+	PollInterval time.Duration `mapstructure:"s3-poll-interval"`
+	Endpoint     string        `mapstructure:"s3-endpoint"`
+	Region       string        `mapstructure:"s3-region"`
+}
+
+// AzureBlob is the settings of the azblob command
+type AzureBlob struct {
+	Prefix       string        `mapstructure:"azblob-prefix"`
+	PollInterval time.Duration `mapstructure:"azblob-poll-interval"`
+}
+
+// Git is the settings of the git command
+type Git struct {
+	Dir          string        `mapstructure:"git-dir"`
+	Branch       string        `mapstructure:"git-branch"`
+	Patterns     []string      `mapstructure:"git-pattern"`
+	PollInterval time.Duration `mapstructure:"git-poll-interval"`
+}
+
+// GitHubRelease is the settings of the github-release command
+type GitHubRelease struct {
+	AssetPatterns []string      `mapstructure:"github-asset-pattern"`
+	LatestOnly    bool          `mapstructure:"github-latest-only"`
+	PollInterval  time.Duration `mapstructure:"github-poll-interval"`
+}
+
+// ArtifactRepo is the settings of the artifact-repo command
+type ArtifactRepo struct {
+	Type         string        `mapstructure:"repo-type"`
+	FilePatterns []string      `mapstructure:"repo-file-pattern"`
+	Properties   []string      `mapstructure:"repo-property"`
+	PollInterval time.Duration `mapstructure:"repo-poll-interval"`
+}
+
+// Kubernetes is the settings of the kubernetes command
+type Kubernetes struct {
+	Server    string `mapstructure:"k8s-server"`
+	TokenFile string `mapstructure:"k8s-token-file"`
+	CAFile    string `mapstructure:"k8s-ca-file"`
+	Namespace string `mapstructure:"k8s-namespace"`
+}
+
+// Rekor is the settings of the rekor command
+type Rekor struct {
+	URL           string        `mapstructure:"rekor-url"`
+	Digests       []string      `mapstructure:"rekor-digest"`
+	Since         string        `mapstructure:"rekor-since"`
+	Until         string        `mapstructure:"rekor-until"`
+	Tail          bool          `mapstructure:"rekor-tail"`
+	Kinds         []string      `mapstructure:"rekor-kind"`
+	Subject       string        `mapstructure:"rekor-subject"`
+	GraphSubjects bool          `mapstructure:"rekor-graph-subjects"`
+	PollInterval  time.Duration `mapstructure:"rekor-poll-interval"`
+}
+
+// Push is the settings of the push command
+type Push struct {
+	Addr      string   `mapstructure:"push-addr"`
+	Tokens    []string `mapstructure:"push-token"`
+	TokenFile string   `mapstructure:"push-token-file"`
+	MaxSize   int64    `mapstructure:"push-max-size"`
+	TLSCert   string   `mapstructure:"push-tls-cert"`
+	TLSKey    string   `mapstructure:"push-tls-key"`
+}
+
+// Purl is the settings of the purl command
+type Purl struct {
+	Addr string `mapstructure:"purl-addr"`
+}
+
+// Certifier is the settings of the certifier command
+type Certifier struct {
+	DepsDev         bool          `mapstructure:"depsdev"`
+	OSVFeed         bool          `mapstructure:"osv-feed"`
+	Scorecard       bool          `mapstructure:"scorecard"`
+	ScorecardBinary string        `mapstructure:"scorecard-binary"`
+	Interval        time.Duration `mapstructure:"certifier-interval"`
+}
+
+// Export is the settings of the export command
+type Export struct {
+	Format string `mapstructure:"format"`
+	Output string `mapstructure:"output"`
+}
+
+// Snapshot is the settings of the snapshot command
+type Snapshot struct {
+	Output  string `mapstructure:"snapshot-output"`
+	Compare string `mapstructure:"snapshot-compare"`
+}
+
+// Query is the settings of the query command
+type Query struct {
+	Output    string   `mapstructure:"query-output"`
+	Limit     int      `mapstructure:"query-limit"`
+	PathDepth int      `mapstructure:"query-path-depth"`
+	PathEdges []string `mapstructure:"query-path-edges"`
+}
+
+// Prune is the settings of the prune command
+type Prune struct {
+	Labels    []string `mapstructure:"prune-labels"`
+	BatchSize int      `mapstructure:"prune-batch-size"`
+	DryRun    bool     `mapstructure:"dry-run"`
+}
+
+// VerifyGraph is the settings of the verify-graph command
+type VerifyGraph struct {
+	Fix          bool `mapstructure:"fix"`
+	FixBatchSize int  `mapstructure:"fix-batch-size"`
+}
+
+// REST is the settings of the rest command
+type REST struct {
+	Addr  string `mapstructure:"rest-addr"`
+	Limit int    `mapstructure:"rest-limit"`
+}
+
+// GraphQL is the settings of the graphql command
+type GraphQL struct {
+	Addr string `mapstructure:"graphql-addr"`
+}
+
+// Daemon is the settings of the daemon command
+type Daemon struct {
+	// CollectorsConfig is a YAML file declaring the collectors, read
+	// instead of the collectors of the config
+	CollectorsConfig string `mapstructure:"collectors-config"`
+}
+
+// DeadLetter is the settings of the dlq redrive command
+type DeadLetter struct {
+	// RedriveAll re-drives all the entries of the dead-letter queue
+	RedriveAll bool `mapstructure:"all"`
+}
+
+// Queue is the settings of the queue drain command
+type Queue struct {
+	// Requeue publishes the drained document trees again
+	Requeue bool `mapstructure:"requeue"`
+}
@@ -0,0 +1,508 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/guacsec/guac/pkg/handler/collector/daemon"
+	"github.com/guacsec/guac/pkg/tracing"
+	homedir "github.com/mitchellh/go-homedir"
+	"github.com/spf13/viper"
+)
+
+const (
+	// FileName is the name of the config file without its .yaml extension,
+	// looked up in the home directory, then in the working directory
+	FileName = "guac"
+	// EnvPrefix prefixes the environment variables overriding the config,
+	// e.g. GUAC_GRAPHDB_ADDR for graphdb.addr
+	EnvPrefix = "guac"
+)
+
+// Config is the configuration shared by the guac commands, read from
+// guac.yaml, e.g.
+//
+//	log:
+//	  level: debug
+//	graphdb:
+//	  addr: neo4j://localhost:7687
+//	  user: neo4j
+//	broker:
+//	  addr: nats://localhost:4222
+//	  nats:
+//	    storage: file
+//	verifier:
+//	  key-path: /etc/guac/key.pem
+//	  key-id: release
+//	tracing:
+//	  endpoint: http://localhost:4318
+//	collector:
+//	  timeout: 10m
+//	health:
+//	  addr: :8080
+//	collectors:
+//	  - name: sboms
+//	    type: file
+//	    paths: [/var/lib/guac/sboms]
+//	    interval: 5m
+//
+// Each setting is overridden by its environment variable, the key in upper
+// case prefixed by GUAC_ with the dots and dashes replaced by underscores,
+// e.g. GUAC_GRAPHDB_PASS, and by its flag, e.g. --gdbpass, see Key. The
+// settings of a single command, e.g. gcs-prefix, are top-level keys named
+// after their flag, see Commands.
+type Config struct {
+	Log        Log        `mapstructure:"log"`
+	GraphDB    GraphDB    `mapstructure:"graphdb"`
+	Broker     Broker     `mapstructure:"broker"`
+	Verifier   Verifier   `mapstructure:"verifier"`
+	SeenCache  SeenCache  `mapstructure:"seen-cache"`
+	Tracing    Tracing    `mapstructure:"tracing"`
+	Collector  Collector  `mapstructure:"collector"`
+	Processor  Processor  `mapstructure:"processor"`
+	Ingestor   Ingestor   `mapstructure:"ingestor"`
+	Metrics    Metrics    `mapstructure:"metrics"`
+	Health     Health     `mapstructure:"health"`
+	CollectSub CollectSub `mapstructure:"collect-sub"`
+	// Collectors are the collectors run by the collector daemon, see
+	// daemon.Config
+	Collectors []daemon.CollectorConfig `mapstructure:"collectors"`
+
+	Commands `mapstructure:",squash"`
+}
+
+// Log is the logging config, see logging.Configure
+type Log struct {
+	// Level is the log level: debug, info, warn or error
+	Level string `mapstructure:"level"`
+	// Components are the per component log levels overriding Level, e.g.
+	// collector=debug,assembler=warn
+	Components string `mapstructure:"components"`
+}
+
+// GraphDB is the graph db the graphs are stored to
+type GraphDB struct {
+	// Backend is the backend storing the graph, see assembler.NewBackend
+	Backend string `mapstructure:"backend"`
+	Addr    string `mapstructure:"addr"`
+	User    string `mapstructure:"user"`
+	Pass    string `mapstructure:"pass"`
+	Realm   string `mapstructure:"realm"`
+	// TLSCert, TLSKey and TLSCA are the paths to the pem files for mTLS to
+	// the graph db
+	TLSCert string `mapstructure:"tls-cert"`
+	TLSKey  string `mapstructure:"tls-key"`
+	TLSCA   string `mapstructure:"tls-ca"`
+	// WriteRate is the maximum write operations per second, 0 for unlimited
+	WriteRate float64 `mapstructure:"write-rate"`
+	// BatchSize is the maximum nodes and edges written per transaction
+	BatchSize int `mapstructure:"batch-size"`
+}
+
+// Broker is the message broker between the stages of the pipeline, see
+// emitter.NewBroker
+type Broker struct {
+	// Addr is the address of the broker, empty to run the stages in a
+	// single process without a broker
+	Addr string `mapstructure:"addr"`
+	// Compress gzips the documents published
+	Compress bool  `mapstructure:"compress"`
+	NATS     NATS  `mapstructure:"nats"`
+	Kafka    Kafka `mapstructure:"kafka"`
+	Blob     Blob  `mapstructure:"blob"`
+	// Partitions is the number of partitions the documents are spread over
+	// by source, the same for all the processes of a pipeline
+	Partitions int `mapstructure:"partitions"`
+	// PublishRate is the maximum documents published per second and
+	// MaxPending the number of documents waiting past which publishing
+	// waits, 0 for no limit, see emitter.FlowControl
+	PublishRate float64 `mapstructure:"publish-rate"`
+	MaxPending  uint64  `mapstructure:"max-pending"`
+	// PendingLogInterval is the interval at which the number of documents
+	// waiting on each subject is logged, 0 to disable
+	PendingLogInterval time.Duration `mapstructure:"pending-log-interval"`
+	// DeadLetterAttempts is the number of times a document fails before it
+	// is moved to the dead-letter subject, at most NATS.MaxDeliver
+	DeadLetterAttempts int `mapstructure:"dead-letter-attempts"`
+}
+
+// Validate checks that the documents are moved to the dead-letter subject
+// before NATS JetStream drops them
+func (b Broker) Validate() error {
+	if maxDeliver := b.NATS.MaxDeliver; maxDeliver > 0 && b.DeadLetterAttempts > maxDeliver {
+		return fmt.Errorf("dead-letter attempts must not exceed the max deliveries %d, past which NATS JetStream drops the documents", maxDeliver)
+	}
+	return nil
+}
+
+// NATS is the authentication to NATS and the config of the JetStream stream
+type NATS struct {
+	Creds   string `mapstructure:"creds"`
+	NKey    string `mapstructure:"nkey"`
+	User    string `mapstructure:"user"`
+	Pass    string `mapstructure:"pass"`
+	TLSCert string `mapstructure:"tls-cert"`
+	TLSKey  string `mapstructure:"tls-key"`
+	TLSCA   string `mapstructure:"tls-ca"`
+
+	Storage     string        `mapstructure:"storage"`
+	MaxBytes    int64         `mapstructure:"max-bytes"`
+	MaxAge      time.Duration `mapstructure:"max-age"`
+	Replicas    int           `mapstructure:"replicas"`
+	DedupWindow time.Duration `mapstructure:"dedup-window"`
+	AckWait     time.Duration `mapstructure:"ack-wait"`
+	MaxDeliver  int           `mapstructure:"max-deliver"`
+	MaxInFlight int           `mapstructure:"max-in-flight"`
+	// RecreateStream deletes the documents left on the stream on start
+	RecreateStream bool `mapstructure:"recreate-stream"`
+}
+
+// Kafka is the authentication to the Kafka REST Proxy and the naming of the
+// topics
+type Kafka struct {
+	User        string `mapstructure:"user"`
+	Pass        string `mapstructure:"pass"`
+	TopicPrefix string `mapstructure:"topic-prefix"`
+}
+
+// Blob is the blob store the large documents are written to, only their
+// reference being published, see emitter.BlobOffload
+type Blob struct {
+	// Store is the URI of the blob store, see blob.NewStore, empty to
+	// publish the documents whatever their size
+	Store string `mapstructure:"store"`
+	// Threshold is the size in bytes past which a document is offloaded
+	Threshold int `mapstructure:"threshold"`
+}
+
+// Verifier is the keys and identities verifying the dsse envelopes
+type Verifier struct {
+	// KeyPath is the path to a pem file, or the URI of a KMS key, and KeyID
+	// the ID it is stored under
+	KeyPath     string        `mapstructure:"key-path"`
+	KeyID       string        `mapstructure:"key-id"`
+	KMSRefresh  time.Duration `mapstructure:"kms-refresh"`
+	FulcioRoots string        `mapstructure:"fulcio-roots"`
+	RekorKeys   string        `mapstructure:"rekor-keys"`
+	TUFMirror   string        `mapstructure:"tuf-mirror"`
+	TUFRoot     string        `mapstructure:"tuf-root"`
+	TUFRefresh  time.Duration `mapstructure:"tuf-refresh"`
+	TrustPolicy string        `mapstructure:"trust-policy"`
+}
+
+// SeenCache is the cache of the hashes of the ingested documents, to skip
+// unchanged documents
+type SeenCache struct {
+	// Size is the number of hashes remembered, 0 to disable
+	Size int `mapstructure:"size"`
+	// TTL is the duration a hash is remembered, 0 to never expire
+	TTL time.Duration `mapstructure:"ttl"`
+	// File is the file the hashes are persisted to across runs
+	File string `mapstructure:"file"`
+}
+
+// Tracing is the OTLP collector the traces of the documents are exported to,
+// each setting falling back to its standard OpenTelemetry environment
+// variable, see tracing.OTLPConfig
+type Tracing struct {
+	// Endpoint is the URL of the collector, empty to disable tracing
+	Endpoint string `mapstructure:"endpoint"`
+	// Headers are sent with each export, e.g. api-key=secret,tenant=guac,
+	// see tracing.ParseHeaders
+	Headers     string `mapstructure:"headers"`
+	ServiceName string `mapstructure:"service-name"`
+}
+
+// Collector is the config shared by the collectors
+type Collector struct {
+	// Timeout is the maximum duration of a collection run, after which the
+	// collectors are aborted, 0 for no timeout
+	Timeout time.Duration `mapstructure:"timeout"`
+	// ReadAttempts and ReadBackoff are the retries of reading a document,
+	// see retry.Policy
+	ReadAttempts int           `mapstructure:"read-attempts"`
+	ReadBackoff  time.Duration `mapstructure:"read-backoff"`
+	// Name labels the logs of the documents collected, the collector type if
+	// empty
+	Name string `mapstructure:"name"`
+	// EmitInterval is the minimum time between two documents emitted
+	EmitInterval time.Duration `mapstructure:"emit-interval"`
+}
+
+// Processor is the config of the processors
+type Processor struct {
+	// Durable is the durable consumer name shared by the processor replicas
+	Durable string `mapstructure:"durable"`
+	// Workers is the number of documents processed concurrently without a
+	// broker
+	Workers int `mapstructure:"workers"`
+	// DigestAlgorithm identifies the documents, see
+	// hashcache.SetDigestAlgorithm
+	DigestAlgorithm string `mapstructure:"digest-algorithm"`
+	// QuarantineDir is the directory the documents of unsupported types or
+	// formats are written to
+	QuarantineDir string `mapstructure:"quarantine-dir"`
+}
+
+// Ingestor is the config of the ingestors
+type Ingestor struct {
+	// Durable is the durable consumer name shared by the ingestor replicas
+	Durable string `mapstructure:"durable"`
+	// MaxAttempts and RetryDelay are the retries of storing a document tree,
+	// see parser.RetryPolicy
+	MaxAttempts int           `mapstructure:"max-attempts"`
+	RetryDelay  time.Duration `mapstructure:"retry-delay"`
+	// PredicateMapping is the YAML file mapping the in-toto predicate types
+	// to the edges and attributes to ingest
+	PredicateMapping string `mapstructure:"predicate-mapping"`
+}
+
+// Metrics is the endpoint the Prometheus metrics are served on
+type Metrics struct {
+	// Addr is the address of the /metrics endpoint, empty to disable it
+	Addr string `mapstructure:"addr"`
+}
+
+// Health is the endpoints the health of a long-running command is served on
+type Health struct {
+	// Addr is the address of the /healthz and /readyz endpoints, empty to
+	// disable them
+	Addr string `mapstructure:"addr"`
+	// StallTimeout is the time a document may take to be published or
+	// stored before the process is reported stalled
+	StallTimeout time.Duration `mapstructure:"stall-timeout"`
+}
+
+// CollectSub is the collect-sub service
+type CollectSub struct {
+	// Addr is the address the clients connect to and ListenPort the port
+	// the service listens on
+	Addr       string `mapstructure:"addr"`
+	ListenPort int    `mapstructure:"listen-port"`
+}
+
+// OTLPConfig returns the config of the export of the traces, filled in from
+// the OpenTelemetry environment variables and defaulting to serviceName
+func (t Tracing) OTLPConfig(serviceName string) (tracing.OTLPConfig, error) {
+	headers, err := tracing.ParseHeaders(t.Headers)
+	if err != nil {
+		return tracing.OTLPConfig{}, fmt.Errorf("invalid tracing.headers: %w", err)
+	}
+	return tracing.OTLPConfig{Endpoint: t.Endpoint, ServiceName: t.ServiceName, Headers: headers}.WithEnv(serviceName)
+}
+
+// flagKeys are the keys of the config set by the flags shared by the commands
+var flagKeys = map[string]string{
+	"log-level":      "log.level",
+	"log-components": "log.components",
+
+	"gdb-backend":    "graphdb.backend",
+	"gdbaddr":        "graphdb.addr",
+	"gdbuser":        "graphdb.user",
+	"gdbpass":        "graphdb.pass",
+	"realm":          "graphdb.realm",
+	"gdb-tls-cert":   "graphdb.tls-cert",
+	"gdb-tls-key":    "graphdb.tls-key",
+	"gdb-tls-ca":     "graphdb.tls-ca",
+	"gdb-write-rate": "graphdb.write-rate",
+	"gdb-batch-size": "graphdb.batch-size",
+
+	"pubsub-addr":          "broker.addr",
+	"nats-compress":        "broker.compress",
+	"nats-creds":           "broker.nats.creds",
+	"nats-nkey":            "broker.nats.nkey",
+	"nats-user":            "broker.nats.user",
+	"nats-pass":            "broker.nats.pass",
+	"nats-tls-cert":        "broker.nats.tls-cert",
+	"nats-tls-key":         "broker.nats.tls-key",
+	"nats-tls-ca":          "broker.nats.tls-ca",
+	"nats-storage":         "broker.nats.storage",
+	"nats-max-bytes":       "broker.nats.max-bytes",
+	"nats-max-age":         "broker.nats.max-age",
+	"nats-replicas":        "broker.nats.replicas",
+	"nats-dedup-window":    "broker.nats.dedup-window",
+	"nats-ack-wait":        "broker.nats.ack-wait",
+	"nats-max-deliver":     "broker.nats.max-deliver",
+	"nats-max-in-flight":   "broker.nats.max-in-flight",
+	"nats-recreate-stream": "broker.nats.recreate-stream",
+	"kafka-user":           "broker.kafka.user",
+	"kafka-pass":           "broker.kafka.pass",
+	"kafka-topic-prefix":   "broker.kafka.topic-prefix",
+
+	"verifier-keyPath":      "verifier.key-path",
+	"verifier-keyID":        "verifier.key-id",
+	"verifier-kms-refresh":  "verifier.kms-refresh",
+	"verifier-fulcio-roots": "verifier.fulcio-roots",
+	"verifier-rekor-keys":   "verifier.rekor-keys",
+	"verifier-tuf-mirror":   "verifier.tuf-mirror",
+	"verifier-tuf-root":     "verifier.tuf-root",
+	"verifier-tuf-refresh":  "verifier.tuf-refresh",
+	"verifier-trust-policy": "verifier.trust-policy",
+
+	"seen-cache-size": "seen-cache.size",
+	"seen-cache-ttl":  "seen-cache.ttl",
+	"seen-cache-file": "seen-cache.file",
+
+	"otlp-endpoint":     "tracing.endpoint",
+	"otlp-headers":      "tracing.headers",
+	"otlp-service-name": "tracing.service-name",
+
+	"collector-timeout":       "collector.timeout",
+	"collector-read-attempts": "collector.read-attempts",
+	"collector-read-backoff":  "collector.read-backoff",
+	"collector-name":          "collector.name",
+	"emit-interval":           "collector.emit-interval",
+
+	"processor-durable": "processor.durable",
+	"processors":        "processor.workers",
+	"digest-algorithm":  "processor.digest-algorithm",
+	"quarantine-dir":    "processor.quarantine-dir",
+
+	"ingestor-durable":    "ingestor.durable",
+	"ingest-max-attempts": "ingestor.max-attempts",
+	"ingest-retry-delay":  "ingestor.retry-delay",
+	"predicate-mapping":   "ingestor.predicate-mapping",
+
+	"blob-store":           "broker.blob.store",
+	"blob-threshold":       "broker.blob.threshold",
+	"partitions":           "broker.partitions",
+	"publish-rate":         "broker.publish-rate",
+	"max-pending":          "broker.max-pending",
+	"pending-log-interval": "broker.pending-log-interval",
+	"dead-letter-attempts": "broker.dead-letter-attempts",
+
+	"metrics-addr":         "metrics.addr",
+	"health-addr":          "health.addr",
+	"health-stall-timeout": "health.stall-timeout",
+
+	"csub-addr":        "collect-sub.addr",
+	"csub-listen-port": "collect-sub.listen-port",
+}
+
+// Key returns the key of the config set by the flag name, e.g. graphdb.addr
+// for gdbaddr. The flags of a single command are top-level keys named after
+// the flag.
+func Key(flag string) string {
+	if key, ok := flagKeys[flag]; ok {
+		return key
+	}
+	return flag
+}
+
+// envKeyReplacer turns a key into the suffix of its environment variable
+var envKeyReplacer = strings.NewReplacer("-", "_", ".", "_")
+
+// Read sets up v to read the settings from the environment and from the
+// config file at path, or else guac.yaml in the home or working directory if
+// any, then reads the config file. It returns the config file read, empty if
+// none was found.
+//
+// The environment variables and top-level keys named after the flags, e.g.
+// GUAC_GDBADDR and gdbaddr, from before the settings were grouped, are still
+// read, after GUAC_GRAPHDB_ADDR and graphdb.addr.
+func Read(v *viper.Viper, path string) (string, error) {
+	if path != "" {
+		v.SetConfigFile(path)
+	} else {
+		home, err := homedir.Dir()
+		if err != nil {
+			return "", fmt.Errorf("failed to get user home directory: %w", err)
+		}
+		v.AddConfigPath(home)
+		v.AddConfigPath(".")
+		v.SetConfigName(FileName)
+		v.SetConfigType("yaml")
+	}
+
+	v.SetEnvPrefix(EnvPrefix)
+	// the POSIX standard does not allow - nor . in environment variables,
+	// e.g. GUAC_GRAPHDB_ADDR is read as graphdb.addr
+	v.SetEnvKeyReplacer(envKeyReplacer)
+	v.AutomaticEnv()
+	for flag, key := range flagKeys {
+		if err := v.BindEnv(key, envName(key), envName(flag)); err != nil {
+			return "", fmt.Errorf("failed to bind environment variable: %w", err)
+		}
+	}
+
+	if err := v.ReadInConfig(); err != nil {
+		var notFound viper.ConfigFileNotFoundError
+		if path == "" && errors.As(err, &notFound) {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to read config file: %w", err)
+	}
+	for flag, key := range flagKeys {
+		if v.InConfig(flag) && !v.InConfig(key) {
+			// below the flags and environment variables, as the key would be
+			v.SetDefault(key, v.Get(flag))
+		}
+	}
+	return v.ConfigFileUsed(), nil
+}
+
+// envName returns the environment variable of key
+func envName(key string) string {
+	return strings.ToUpper(EnvPrefix + "_" + envKeyReplacer.Replace(key))
+}
+
+// Load returns the validated config of v, once set up by Read and with the
+// flags bound to their keys, see Key
+func Load(v *viper.Viper) (*Config, error) {
+	c := &Config{}
+	if err := v.Unmarshal(c); err != nil {
+		return nil, fmt.Errorf("failed to decode config: %w", err)
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Validate checks the settings that do not depend on the command
+func (c *Config) Validate() error {
+	if err := c.GraphDB.Validate(); err != nil {
+		return err
+	}
+	if err := c.Broker.Validate(); err != nil {
+		return err
+	}
+	if c.SeenCache.Size < 0 {
+		return fmt.Errorf("seen-cache.size must not be negative, got %d", c.SeenCache.Size)
+	}
+	if _, err := tracing.ParseHeaders(c.Tracing.Headers); err != nil {
+		return fmt.Errorf("invalid tracing.headers: %w", err)
+	}
+	if err := (&daemon.Config{Collectors: c.Collectors}).Validate(); err != nil {
+		return fmt.Errorf("collectors: %w", err)
+	}
+	return nil
+}
+
+// Validate checks that the files for mTLS to the graph db come in pairs
+func (g GraphDB) Validate() error {
+	if (g.TLSCert == "") != (g.TLSKey == "") {
+		return errors.New("both gdb-tls-cert and gdb-tls-key must be set for mTLS")
+	}
+	if g.TLSCA != "" && g.TLSCert == "" {
+		return errors.New("gdb-tls-ca requires gdb-tls-cert and gdb-tls-key")
+	}
+	return nil
+}
@@ -0,0 +1,236 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/guacsec/guac/pkg/handler/collector/daemon"
+	"github.com/guacsec/guac/pkg/tracing"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const testConfig = `
+log:
+  level: debug
+graphdb:
+  addr: neo4j://graphdb:7687
+  user: neo4j
+broker:
+  addr: nats://nats:4222
+  nats:
+    ack-wait: 2m
+verifier:
+  key-path: /keys/release.pem
+seen-cache:
+  ttl: 30m
+tracing:
+  endpoint: http://otel:4318
+  headers: api-key=secret
+collector:
+  timeout: 10m
+health:
+  addr: :8080
+collectors:
+  - name: sboms
+    type: file
+    paths: [/sboms]
+    interval: 5m
+`
+
+// newViper returns a viper reading the config file with the content, and
+// with flags bound to their keys like the commands
+func newViper(t *testing.T, content string) (*viper.Viper, *cobra.Command) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "guac.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cmd := &cobra.Command{}
+	cmd.Flags().String("gdbaddr", "neo4j://localhost:7687", "")
+	cmd.Flags().String("gdbpass", "", "")
+	cmd.Flags().String("log-level", "info", "")
+	cmd.Flags().Int("seen-cache-size", 10000, "")
+	cmd.Flags().String("gcs-prefix", "", "")
+	v := viper.New()
+	for _, name := range []string{"gdbaddr", "gdbpass", "log-level", "seen-cache-size", "gcs-prefix"} {
+		if err := v.BindPFlag(Key(name), cmd.Flags().Lookup(name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if used, err := Read(v, path); err != nil {
+		t.Fatalf("Read() error = %v", err)
+	} else if used != path {
+		t.Errorf("Read() = %v, want %v", used, path)
+	}
+	return v, cmd
+}
+
+func TestLoad(t *testing.T) {
+	v, _ := newViper(t, testConfig)
+	got, err := Load(v)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	want := &Config{
+		Log: Log{Level: "debug"},
+		GraphDB: GraphDB{
+			Addr: "neo4j://graphdb:7687",
+			User: "neo4j",
+		},
+		Broker: Broker{
+			Addr: "nats://nats:4222",
+			NATS: NATS{AckWait: 2 * time.Minute},
+		},
+		Verifier: Verifier{KeyPath: "/keys/release.pem"},
+		SeenCache: SeenCache{
+			// the default of the flag
+			Size: 10000,
+			TTL:  30 * time.Minute,
+		},
+		Tracing: Tracing{
+			Endpoint: "http://otel:4318",
+			Headers:  "api-key=secret",
+		},
+		Collector: Collector{Timeout: 10 * time.Minute},
+		Health:    Health{Addr: ":8080"},
+		Collectors: []daemon.CollectorConfig{{
+			Name:     "sboms",
+			Type:     daemon.TypeFile,
+			Paths:    []string{"/sboms"},
+			Interval: 5 * time.Minute,
+		}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Load() = %+v, want %+v", got, want)
+	}
+}
+
+func TestLoad_Overrides(t *testing.T) {
+	t.Setenv("GUAC_GRAPHDB_USER", "guac")
+	t.Setenv("GUAC_GDBPASS", "s3cr3t")
+	t.Setenv("GUAC_BROKER_NATS_ACK_WAIT", "1m")
+	t.Setenv("GUAC_METRICS_ADDR", ":9090")
+	t.Setenv("GUAC_GCS_PREFIX", "sboms/")
+	v, cmd := newViper(t, testConfig)
+	if err := cmd.Flags().Set("gdbaddr", "neo4j://flag:7687"); err != nil {
+		t.Fatal(err)
+	}
+	got, err := Load(v)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if got.GraphDB.Addr != "neo4j://flag:7687" {
+		t.Errorf("graphdb.addr = %q, want the flag", got.GraphDB.Addr)
+	}
+	if got.GraphDB.User != "guac" {
+		t.Errorf("graphdb.user = %q, want the environment variable", got.GraphDB.User)
+	}
+	if got.GraphDB.Pass != "s3cr3t" {
+		t.Errorf("graphdb.pass = %q, want the environment variable named after the flag", got.GraphDB.Pass)
+	}
+	if got.Broker.NATS.AckWait != time.Minute {
+		t.Errorf("broker.nats.ack-wait = %v, want the environment variable", got.Broker.NATS.AckWait)
+	}
+	if got.Metrics.Addr != ":9090" {
+		t.Errorf("metrics.addr = %q, want the environment variable", got.Metrics.Addr)
+	}
+	if got.GCS.Prefix != "sboms/" {
+		t.Errorf("gcs-prefix = %q, want the environment variable", got.GCS.Prefix)
+	}
+}
+
+func TestLoad_FlatKeys(t *testing.T) {
+	v, _ := newViper(t, "gdbaddr: neo4j://flat:7687\nlog-level: warn\ncollector-timeout: 5m\ngcs-prefix: sboms/\n")
+	got, err := Load(v)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if got.GraphDB.Addr != "neo4j://flat:7687" {
+		t.Errorf("graphdb.addr = %q, want the flat key", got.GraphDB.Addr)
+	}
+	if got.Log.Level != "warn" {
+		t.Errorf("log.level = %q, want the flat key", got.Log.Level)
+	}
+	if got.Collector.Timeout != 5*time.Minute {
+		t.Errorf("collector.timeout = %v, want the flat key", got.Collector.Timeout)
+	}
+	if got.GCS.Prefix != "sboms/" {
+		t.Errorf("gcs-prefix = %q, want the top-level key", got.GCS.Prefix)
+	}
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{{
+		name:    "tls key without cert",
+		content: "graphdb:\n  tls-key: /tls/key.pem\n",
+		wantErr: "gdb-tls-cert and gdb-tls-key",
+	}, {
+		name:    "invalid collector",
+		content: "collectors:\n  - name: sboms\n    type: ftp\n",
+		wantErr: "unknown collector type",
+	}, {
+		name:    "invalid tracing headers",
+		content: "tracing:\n  headers: api-key\n",
+		wantErr: "invalid tracing.headers",
+	}, {
+		name:    "dead-letter attempts past max deliveries",
+		content: "broker:\n  dead-letter-attempts: 10\n  nats:\n    max-deliver: 5\n",
+		wantErr: "must not exceed the max deliveries 5",
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, _ := newViper(t, tt.content)
+			if _, err := Load(v); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Load() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRead_NoConfigFile(t *testing.T) {
+	v := viper.New()
+	if _, err := Read(v, filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Errorf("Read() expected error for a missing config file given by path")
+	}
+}
+
+func TestTracing_OTLPConfig(t *testing.T) {
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "http://env:4318")
+	t.Setenv("OTEL_SERVICE_NAME", "")
+	got, err := Tracing{Headers: "api-key=secret"}.OTLPConfig("guacone")
+	if err != nil {
+		t.Fatalf("OTLPConfig() error = %v", err)
+	}
+	want := tracing.OTLPConfig{
+		Endpoint:    "http://env:4318",
+		ServiceName: "guacone",
+		Headers:     map[string]string{"api-key": "secret"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("OTLPConfig() = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,118 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package emitter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/guacsec/guac/pkg/handler/processor/hashcache"
+)
+
+const (
+	// HeaderBlobRef is the message header carrying the key in the blob store
+	// of a payload published by reference, see SetBlobOffload
+	HeaderBlobRef string = "Guac-Blob-Ref"
+	// DefaultBlobThreshold is the size of the payloads past which they are
+	// published by reference, below the 1MB default max payload of NATS
+	DefaultBlobThreshold int = 768 * 1024
+)
+
+// BlobStore stores the payloads too large to be published on the broker, see
+// pkg/blob for the stores on the filesystem, GCS and S3
+type BlobStore interface {
+	// Put stores data under key, overwriting it if already stored
+	Put(ctx context.Context, key string, data []byte) error
+	// Get returns the data stored under key
+	Get(ctx context.Context, key string) ([]byte, error)
+}
+
+// BlobOffload is how the payloads too large for the broker are published
+type BlobOffload struct {
+	// Store is where the payloads larger than Threshold are written, nil to
+	// publish all payloads on the broker
+	Store BlobStore
+	// Threshold is the size in bytes of the payloads, after compression,
+	// past which they are written to Store and only their key is published
+	Threshold int
+}
+
+var (
+	blobOffloadLock sync.RWMutex
+	blobOffload     = BlobOffload{Threshold: DefaultBlobThreshold}
+)
+
+// SetBlobOffload sets the blob store the payloads larger than its threshold
+// are written to by Publish and PublishWithMsgID, the message only carrying
+// their key, and read back from by the subscribers. The publishers and
+// subscribers of a pipeline must use the same store. The blobs are keyed by
+// their digest and are not deleted once consumed, so the store should expire
+// them, e.g. with a lifecycle rule of the bucket, after longer than the
+// documents stay on the stream.
+func SetBlobOffload(offload BlobOffload) error {
+	if offload.Threshold < 0 {
+		return fmt.Errorf("blob threshold must not be negative, got %d", offload.Threshold)
+	}
+	blobOffloadLock.Lock()
+	defer blobOffloadLock.Unlock()
+	blobOffload = offload
+	return nil
+}
+
+// GetBlobOffload returns the blob store the large payloads are written to
+func GetBlobOffload() BlobOffload {
+	blobOffloadLock.RLock()
+	defer blobOffloadLock.RUnlock()
+	return blobOffload
+}
+
+// offloadBlob writes the payload of msg to the blob store if larger than the
+// threshold, replacing it with its key
+func offloadBlob(ctx context.Context, msg *Message) error {
+	offload := GetBlobOffload()
+	if offload.Store == nil || len(msg.Data) <= offload.Threshold {
+		return nil
+	}
+	// sha256/44136f..., so that the blobs are spread over the prefixes
+	key := strings.Replace(hashcache.Digest(msg.Data), ":", "/", 1)
+	if err := offload.Store.Put(ctx, key, msg.Data); err != nil {
+		return fmt.Errorf("failed to write payload of %d bytes to the blob store: %w", len(msg.Data), err)
+	}
+	msg.Header.Set(HeaderBlobRef, key)
+	msg.Data = nil
+	return nil
+}
+
+// messageDocument returns the payload of msg, read from the blob store if
+// published by reference, and decompressed if needed
+func messageDocument(ctx context.Context, msg *Message) ([]byte, error) {
+	key := msg.Header.Get(HeaderBlobRef)
+	if key == "" {
+		return messageData(msg)
+	}
+	store := GetBlobOffload().Store
+	if store == nil {
+		return nil, errors.New("payload published by reference but no blob store is configured")
+	}
+	data, err := store.Get(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read payload %s from the blob store: %w", key, err)
+	}
+	return messageData(&Message{Header: msg.Header, Data: data})
+}
@@ -0,0 +1,118 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package emitter
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+type mapBlobStore struct {
+	mu    sync.Mutex
+	blobs map[string][]byte
+}
+
+func (m *mapBlobStore) Put(_ context.Context, key string, data []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.blobs[key] = data
+	return nil
+}
+
+func (m *mapBlobStore) Get(_ context.Context, key string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	data, ok := m.blobs[key]
+	if !ok {
+		return nil, fmt.Errorf("no blob %s", key)
+	}
+	return data, nil
+}
+
+func TestSetBlobOffload(t *testing.T) {
+	defer func() {
+		_ = SetBlobOffload(BlobOffload{Threshold: DefaultBlobThreshold})
+	}()
+	if err := SetBlobOffload(BlobOffload{Threshold: -1}); err == nil {
+		t.Errorf("SetBlobOffload() expected error for a negative threshold")
+	}
+	store := &mapBlobStore{blobs: map[string][]byte{}}
+	if err := SetBlobOffload(BlobOffload{Store: store, Threshold: 10}); err != nil {
+		t.Fatal(err)
+	}
+	if got := GetBlobOffload(); got.Store != store || got.Threshold != 10 {
+		t.Errorf("GetBlobOffload() = %v, want the store with threshold 10", got)
+	}
+}
+
+func TestPubSub_BlobOffload(t *testing.T) {
+	store := &mapBlobStore{blobs: map[string][]byte{}}
+	if err := SetBlobOffload(BlobOffload{Store: store, Threshold: 10}); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = SetBlobOffload(BlobOffload{Threshold: DefaultBlobThreshold})
+	}()
+	b := NewMemoryBroker()
+	ctx, err := b.Init(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error initializing the memory broker: %v", err)
+	}
+	large := bytes.Repeat([]byte("spdx"), 100)
+	for _, data := range [][]byte{[]byte("small"), large} {
+		if err := Publish(ctx, SubjectNameDocCollected, data); err != nil {
+			t.Fatalf("unexpected error on publish: %v", err)
+		}
+	}
+	if len(store.blobs) != 1 {
+		t.Errorf("blob store has %d blobs, want the large document only", len(store.blobs))
+	}
+	for _, msg := range b.subjects[SubjectNameDocCollected].messages {
+		if ref := msg.Header.Get(HeaderBlobRef); (ref != "") != (msg.Data == nil) {
+			t.Errorf("message with blob ref %q has %d bytes of data", ref, len(msg.Data))
+		}
+	}
+
+	subCtx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	psub, err := NewPubSub(subCtx, "processor", SubjectNameDocCollected, DurableProcessor, BackOffTimer)
+	if err != nil {
+		t.Fatalf("unexpected error on subscribe: %v", err)
+	}
+	var got [][]byte
+	_ = psub.GetDataFromNats(subCtx, func(d []byte) error {
+		got = append(got, d)
+		if len(got) == 2 {
+			cancel()
+		}
+		return nil
+	})
+	if len(got) != 2 || string(got[0]) != "small" || !bytes.Equal(got[1], large) {
+		t.Errorf("GetDataFromNats() got %d documents, want the small and large documents", len(got))
+	}
+
+	// the subscribers of a pipeline without the store cannot read the blobs
+	if err := SetBlobOffload(BlobOffload{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := messageDocument(ctx, b.subjects[SubjectNameDocCollected].messages[1]); err == nil {
+		t.Errorf("messageDocument() expected error without a blob store")
+	}
+}
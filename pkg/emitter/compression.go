@@ -0,0 +1,87 @@
+//
+// Copyright 2022 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package emitter
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+)
+
+const (
+	// HeaderContentEncoding is the message header marking compressed payloads
+	HeaderContentEncoding string = "Content-Encoding"
+	// ContentEncodingGzip marks payloads compressed with gzip
+	ContentEncodingGzip string = "gzip"
+)
+
+type compressionKey struct{}
+
+// WithCompression returns a context in which Publish gzips the payloads.
+// Subscribers decompress payloads based on their header, so compressed and
+// uncompressed messages can be mixed on the stream.
+func WithCompression(ctx context.Context) context.Context {
+	return context.WithValue(ctx, compressionKey{}, true)
+}
+
+func compressionFromContext(ctx context.Context) bool {
+	compress, _ := ctx.Value(compressionKey{}).(bool)
+	return compress
+}
+
+// newMessage returns the message carrying data on subj, gzipped if compress
+// is set
+func newMessage(subj string, data []byte, compress bool) (*Message, error) {
+	msg := &Message{Subject: subj, Header: Header{}}
+	if !compress {
+		msg.Data = data
+		return msg, nil
+	}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return nil, fmt.Errorf("failed to compress payload: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to compress payload: %w", err)
+	}
+	msg.Data = buf.Bytes()
+	msg.Header.Set(HeaderContentEncoding, ContentEncodingGzip)
+	return msg, nil
+}
+
+// messageData returns the payload of msg, decompressed if needed
+func messageData(msg *Message) ([]byte, error) {
+	switch encoding := msg.Header.Get(HeaderContentEncoding); encoding {
+	case "":
+		return msg.Data, nil
+	case ContentEncodingGzip:
+		zr, err := gzip.NewReader(bytes.NewReader(msg.Data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress payload: %w", err)
+		}
+		defer zr.Close()
+		data, err := io.ReadAll(zr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress payload: %w", err)
+		}
+		return data, nil
+	default:
+		return nil, fmt.Errorf("unsupported payload encoding %q", encoding)
+	}
+}
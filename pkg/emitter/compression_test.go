@@ -0,0 +1,82 @@
+//
+// Copyright 2022 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package emitter
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+func TestCompression(t *testing.T) {
+	data := bytes.Repeat([]byte(`{"document": "tree"}`), 100)
+
+	tests := []struct {
+		name         string
+		compress     bool
+		wantEncoding string
+	}{{
+		name: "uncompressed",
+	}, {
+		name:         "gzip",
+		compress:     true,
+		wantEncoding: ContentEncodingGzip,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg, err := newMessage(SubjectNameDocProcessed, data, tt.compress)
+			if err != nil {
+				t.Fatalf("newMessage() error = %v", err)
+			}
+			if got := msg.Header.Get(HeaderContentEncoding); got != tt.wantEncoding {
+				t.Errorf("got encoding %q, want %q", got, tt.wantEncoding)
+			}
+			if tt.compress && len(msg.Data) >= len(data) {
+				t.Errorf("compressed payload is %d bytes, not smaller than %d", len(msg.Data), len(data))
+			}
+			got, err := messageData(msg)
+			if err != nil {
+				t.Fatalf("messageData() error = %v", err)
+			}
+			if !bytes.Equal(got, data) {
+				t.Errorf("messageData() = %s, want %s", got, data)
+			}
+		})
+	}
+}
+
+func TestMessageData_errors(t *testing.T) {
+	msg, _ := newMessage(SubjectNameDocProcessed, []byte("data"), false)
+	msg.Header.Set(HeaderContentEncoding, "br")
+	if _, err := messageData(msg); err == nil {
+		t.Errorf("expected an error for an unsupported encoding")
+	}
+
+	msg.Header.Set(HeaderContentEncoding, ContentEncodingGzip)
+	if _, err := messageData(msg); err == nil {
+		t.Errorf("expected an error for a corrupt gzip payload")
+	}
+}
+
+func TestWithCompression(t *testing.T) {
+	ctx := context.Background()
+	if compressionFromContext(ctx) {
+		t.Errorf("compression should be off by default")
+	}
+	if !compressionFromContext(WithCompression(ctx)) {
+		t.Errorf("compression should be on with WithCompression")
+	}
+}
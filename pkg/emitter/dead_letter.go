@@ -0,0 +1,167 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package emitter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+// DefaultDeadLetterAttempts is the number of times a subscriber fails on a
+// message before moving it to the dead-letter subject
+const DefaultDeadLetterAttempts int = DefaultMaxDeliver
+
+var (
+	deadLetterAttemptsLock sync.RWMutex
+	deadLetterAttempts     = DefaultDeadLetterAttempts
+)
+
+// SetDeadLetterAttempts sets the number of times a subscriber fails on a
+// message before moving it to the dead-letter subject, at least 1. On NATS
+// JetStream it must not exceed the max deliveries of the consumers, past
+// which the message is dropped instead.
+func SetDeadLetterAttempts(attempts int) error {
+	if attempts < 1 {
+		return fmt.Errorf("dead-letter attempts must be at least 1, got %d", attempts)
+	}
+	deadLetterAttemptsLock.Lock()
+	defer deadLetterAttemptsLock.Unlock()
+	deadLetterAttempts = attempts
+	return nil
+}
+
+// GetDeadLetterAttempts returns the number of times a subscriber fails on a
+// message before moving it to the dead-letter subject
+func GetDeadLetterAttempts() int {
+	deadLetterAttemptsLock.RLock()
+	defer deadLetterAttemptsLock.RUnlock()
+	return deadLetterAttempts
+}
+
+// DeadLetter is a message given up on by a stage of the pipeline, as
+// published on the dead-letter subject
+type DeadLetter struct {
+	// Subject is the subject the message was consumed from, which it is
+	// published on again when re-driven
+	Subject string `json:"subject"`
+	// Consumer is the durable consumer that failed on the message
+	Consumer string `json:"consumer"`
+	// Attempts is the number of times the message failed
+	Attempts int `json:"attempts"`
+	// Error is the error of the last attempt
+	Error string `json:"error"`
+	// FailedAt is when the message was given up on
+	FailedAt time.Time `json:"failedAt"`
+	// Header and Data are those of the message, the data possibly
+	// compressed or in the blob store, see Document
+	Header Header `json:"header,omitempty"`
+	Data   []byte `json:"data"`
+}
+
+// Document returns the data of the message, read from the blob store if
+// published by reference, and decompressed if needed
+func (d *DeadLetter) Document(ctx context.Context) ([]byte, error) {
+	return messageDocument(ctx, &Message{Header: d.Header, Data: d.Data})
+}
+
+// PublishDeadLetter publishes d on the dead-letter subject
+func PublishDeadLetter(ctx context.Context, d DeadLetter) error {
+	data, err := json.Marshal(d)
+	if err != nil {
+		return fmt.Errorf("failed to marshal dead letter: %w", err)
+	}
+	return Publish(ctx, SubjectNameDocDeadLetter, data)
+}
+
+// QueuedDeadLetter is a dead letter waiting on the dead-letter subject
+type QueuedDeadLetter struct {
+	// Seq is the sequence of the dead letter in the stream, identifying it
+	Seq uint64
+	DeadLetter
+}
+
+// DeadLetters returns the dead letters waiting on the dead-letter subject,
+// oldest first, without consuming them
+func DeadLetters(ctx context.Context) ([]QueuedDeadLetter, error) {
+	messages, err := QueuedMessages(ctx, SubjectNameDocDeadLetter)
+	if err != nil {
+		return nil, err
+	}
+	deadLetters := make([]QueuedDeadLetter, 0, len(messages))
+	for _, m := range messages {
+		d, err := unmarshalDeadLetter(m)
+		if err != nil {
+			return nil, err
+		}
+		deadLetters = append(deadLetters, *d)
+	}
+	return deadLetters, nil
+}
+
+// GetDeadLetter returns the dead letter with sequence seq
+func GetDeadLetter(ctx context.Context, seq uint64) (*QueuedDeadLetter, error) {
+	m, err := GetQueuedMessage(ctx, SubjectNameDocDeadLetter, seq)
+	if err != nil {
+		return nil, err
+	}
+	return unmarshalDeadLetter(*m)
+}
+
+// Redrive publishes the message of the dead letter with sequence seq again on
+// its subject, on the partition of its ordering key, and removes the dead
+// letter. The message is published with a new message ID, so that it is not
+// dropped as a duplicate of the message that failed.
+func Redrive(ctx context.Context, seq uint64) (*QueuedDeadLetter, error) {
+	b := BrokerFromContext(ctx)
+	if b == nil {
+		return nil, errors.New("broker not found from context")
+	}
+	d, err := GetDeadLetter(ctx, seq)
+	if err != nil {
+		return nil, err
+	}
+	header := Header{}
+	for key, value := range d.Header {
+		header.Set(key, value)
+	}
+	msg := &Message{ID: uuid.NewV4().String(), Header: header, Data: d.Data}
+	key := header.Get(OrderingKeyHeader)
+	if key == "" {
+		key = msg.ID
+	}
+	msg.Subject = partitionSubject(d.Subject, key, GetPartitions())
+	if err := b.Publish(ctx, msg); err != nil {
+		return nil, fmt.Errorf("failed to publish dead letter %d on %s: %w", seq, d.Subject, err)
+	}
+	if err := DeleteQueuedMessage(ctx, seq); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func unmarshalDeadLetter(m QueuedMessage) (*QueuedDeadLetter, error) {
+	d := QueuedDeadLetter{Seq: m.Seq}
+	if err := json.Unmarshal(m.Data, &d.DeadLetter); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal dead letter %d: %w", m.Seq, err)
+	}
+	return &d, nil
+}
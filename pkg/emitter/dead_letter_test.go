@@ -0,0 +1,124 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package emitter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSetDeadLetterAttempts(t *testing.T) {
+	defer func() {
+		_ = SetDeadLetterAttempts(DefaultDeadLetterAttempts)
+	}()
+	if err := SetDeadLetterAttempts(0); err == nil {
+		t.Errorf("SetDeadLetterAttempts() expected error for 0 attempts")
+	}
+	if err := SetDeadLetterAttempts(2); err != nil {
+		t.Fatal(err)
+	}
+	if got := GetDeadLetterAttempts(); got != 2 {
+		t.Errorf("GetDeadLetterAttempts() = %d, want 2", got)
+	}
+}
+
+func TestPubSub_DeadLetter(t *testing.T) {
+	if err := SetDeadLetterAttempts(2); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = SetDeadLetterAttempts(DefaultDeadLetterAttempts)
+	}()
+	b := NewMemoryBroker()
+	ctx, err := b.Init(WithCompression(context.Background()))
+	if err != nil {
+		t.Fatalf("unexpected error initializing the memory broker: %v", err)
+	}
+	for _, data := range []string{"poison", "document"} {
+		if err := Publish(ctx, SubjectNameDocCollected, []byte(data)); err != nil {
+			t.Fatalf("unexpected error on publish: %v", err)
+		}
+	}
+	// a message that does not decode is given up on at once
+	if err := b.Publish(ctx, &Message{Subject: SubjectNameDocCollected, Header: Header{HeaderContentEncoding: "br"}, Data: []byte("garbled")}); err != nil {
+		t.Fatalf("unexpected error on publish: %v", err)
+	}
+
+	failed := errors.New("processor failed")
+	var handled []string
+	dataFunc := func(d []byte) error {
+		if string(d) == "poison" {
+			return failed
+		}
+		handled = append(handled, string(d))
+		return nil
+	}
+	// the first attempt fails and stops the processor
+	crashCtx, crash := context.WithCancel(ctx)
+	psub, err := NewPubSub(crashCtx, "first", SubjectNameDocCollected, DurableProcessor, BackOffTimer)
+	if err != nil {
+		t.Fatalf("unexpected error on subscribe: %v", err)
+	}
+	if err := psub.GetDataFromNats(crashCtx, dataFunc); !errors.Is(err, failed) {
+		t.Fatalf("GetDataFromNats() error = %v, want %v", err, failed)
+	}
+	crash()
+	// the second is the last one, the processor carries on
+	subCtx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	psub, err = NewPubSub(subCtx, "second", SubjectNameDocCollected, DurableProcessor, BackOffTimer)
+	if err != nil {
+		t.Fatalf("unexpected error on subscribe: %v", err)
+	}
+	if err := psub.GetDataFromNats(subCtx, dataFunc); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("GetDataFromNats() error = %v, want context.DeadlineExceeded", err)
+	}
+	if len(handled) != 1 || handled[0] != "document" {
+		t.Errorf("handled %v, want [document]", handled)
+	}
+
+	dlqCtx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	psub, err = NewPubSub(dlqCtx, "dlq", SubjectNameDocDeadLetter, DurableDeadLetter, BackOffTimer)
+	if err != nil {
+		t.Fatalf("unexpected error on subscribe: %v", err)
+	}
+	var deadLetters []DeadLetter
+	_ = psub.GetDataFromNats(dlqCtx, func(d []byte) error {
+		var deadLetter DeadLetter
+		if err := json.Unmarshal(d, &deadLetter); err != nil {
+			t.Errorf("unexpected error unmarshalling dead letter: %v", err)
+		}
+		deadLetters = append(deadLetters, deadLetter)
+		return nil
+	})
+	if len(deadLetters) != 2 {
+		t.Fatalf("got %d dead letters, want 2", len(deadLetters))
+	}
+	poison, garbled := deadLetters[0], deadLetters[1]
+	if poison.Attempts != 2 || poison.Error != failed.Error() || poison.Subject != SubjectNameDocCollected || poison.Consumer != DurableProcessor {
+		t.Errorf("unexpected dead letter %+v", poison)
+	}
+	if data, err := poison.Document(ctx); err != nil || string(data) != "poison" {
+		t.Errorf("Document() = %q, %v, want the failed message", data, err)
+	}
+	if garbled.Attempts != 1 || string(garbled.Data) != "garbled" || garbled.Header.Get(HeaderContentEncoding) != "br" {
+		t.Errorf("unexpected dead letter %+v", garbled)
+	}
+}
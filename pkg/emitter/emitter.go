@@ -0,0 +1,183 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package emitter
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"time"
+)
+
+// URI schemes of the brokers registered by this package
+const (
+	// SchemeNATS is NATS JetStream, e.g. nats://localhost:4222
+	SchemeNATS = "nats"
+	// SchemeNATSTLS is NATS JetStream over TLS, e.g. tls://localhost:4222
+	SchemeNATSTLS = "tls"
+	// SchemeKafka is Kafka through the Kafka REST Proxy at the http URL of
+	// the same host and path, e.g. kafka://localhost:8082
+	SchemeKafka = "kafka"
+	// SchemeKafkaHTTPS is Kafka through a Kafka REST Proxy served over https
+	SchemeKafkaHTTPS = "kafka+https"
+	// SchemeMemory is a broker in the memory of the process, e.g. mem://
+	SchemeMemory = "mem"
+)
+
+// Broker is the message broker the documents are published on and consumed
+// from by durable consumers. Brokers are registered by URI scheme with
+// RegisterBroker so that commands select one with NewBroker instead of
+// depending on NATS. Init returns a context carrying the broker, which
+// Publish, PublishWithMsgID and NewPubSub use.
+type Broker interface {
+	// Init connects to the broker and returns ctx with the broker, see
+	// WithBroker
+	Init(ctx context.Context) (context.Context, error)
+	// Ping returns an error if the broker cannot be reached
+	Ping(ctx context.Context) error
+	// Close closes the connections to the broker
+	Close()
+	// Publish publishes msg on its subject. Brokers deduplicating messages
+	// drop it if a message with the same ID was published recently.
+	Publish(ctx context.Context, msg *Message) error
+	// Subscribe consumes the messages of subj with the durable consumer,
+	// shared by all the subscribers with the same durable name, until ctx
+	// is done. Brokers polling for messages wait backOffTimer whenever
+	// there is none. The error ending the subscription is sent on the error
+	// channel. The messages are acked or nacked by the subscriber once
+	// handled, see Message.
+	Subscribe(ctx context.Context, id string, subj string, durable string, backOffTimer time.Duration) (<-chan *Message, <-chan error, error)
+}
+
+// Message is a message published on a subject of a broker
+type Message struct {
+	Subject string
+	// ID identifies the message for the brokers dropping duplicates
+	ID     string
+	Header Header
+	Data   []byte
+	// Delivered is the number of times the message of a subscription was
+	// delivered, counting this one, 0 if the broker does not count them
+	Delivered int
+	// Ack tells the broker the message of a subscription was handled, so
+	// that it is not delivered again, and Nak that it failed to be, so that
+	// it is delivered again. They are nil for published messages.
+	Ack func() error
+	Nak func() error
+}
+
+// Header is the metadata of a message, e.g. the encoding of its data and its
+// trace context
+type Header map[string]string
+
+// Get returns the value of key, "" if not set
+func (h Header) Get(key string) string {
+	return h[key]
+}
+
+// Set sets key to value
+func (h Header) Set(key string, value string) {
+	h[key] = value
+}
+
+// BrokerConfig configures the connection to a broker, beyond its URI. Each
+// broker uses the fields it needs and ignores the others.
+type BrokerConfig struct {
+	// Stream is the storage and retention of the JetStream stream
+	Stream StreamConfig
+	// NATS is the authentication and TLS of the connection to NATS
+	NATS NATSAuth
+	// Kafka is the configuration of the Kafka REST Proxy, its URL is given
+	// by the URI
+	Kafka KafkaConfig
+}
+
+// BrokerFactory returns the broker at uri configured by config, not yet
+// initialized
+type BrokerFactory func(uri *url.URL, config BrokerConfig) (Broker, error)
+
+var brokers = map[string]BrokerFactory{}
+
+func init() {
+	natsFactory := func(uri *url.URL, config BrokerConfig) (Broker, error) {
+		return NewJetStreamWithAuth(uri.String(), config.NATS, config.Stream), nil
+	}
+	_ = RegisterBroker(SchemeNATS, natsFactory)
+	_ = RegisterBroker(SchemeNATSTLS, natsFactory)
+	kafkaFactory := func(uri *url.URL, config BrokerConfig) (Broker, error) {
+		proxy := *uri
+		proxy.Scheme = "http"
+		if uri.Scheme == SchemeKafkaHTTPS {
+			proxy.Scheme = "https"
+		}
+		kafka := config.Kafka
+		kafka.URL = proxy.String()
+		return NewKafka(kafka), nil
+	}
+	_ = RegisterBroker(SchemeKafka, kafkaFactory)
+	_ = RegisterBroker(SchemeKafkaHTTPS, kafkaFactory)
+	_ = RegisterBroker(SchemeMemory, func(*url.URL, BrokerConfig) (Broker, error) {
+		return NewMemoryBroker(), nil
+	})
+}
+
+// RegisterBroker registers the factory of the brokers with URIs of scheme
+func RegisterBroker(scheme string, factory BrokerFactory) error {
+	if _, ok := brokers[scheme]; ok {
+		return fmt.Errorf("the broker is being overwritten: %s", scheme)
+	}
+	brokers[scheme] = factory
+	return nil
+}
+
+// RegisteredBrokers returns the sorted URI schemes of the registered brokers
+func RegisteredBrokers() []string {
+	schemes := make([]string, 0, len(brokers))
+	for scheme := range brokers {
+		schemes = append(schemes, scheme)
+	}
+	sort.Strings(schemes)
+	return schemes
+}
+
+// NewBroker returns the broker at uri, selected by its scheme, not yet
+// initialized
+func NewBroker(uri string, config BrokerConfig) (Broker, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, fmt.Errorf("invalid broker uri %q: %w", uri, err)
+	}
+	factory, ok := brokers[u.Scheme]
+	if !ok {
+		return nil, fmt.Errorf("unknown broker scheme in %q, registered schemes are %v", uri, RegisteredBrokers())
+	}
+	return factory(u, config)
+}
+
+type brokerKey struct{}
+
+// WithBroker returns ctx carrying the broker b, returned by the Init method
+// of the brokers
+func WithBroker(ctx context.Context, b Broker) context.Context {
+	return context.WithValue(ctx, brokerKey{}, b)
+}
+
+// BrokerFromContext returns the broker initialized in ctx, nil if none
+func BrokerFromContext(ctx context.Context) Broker {
+	b, _ := ctx.Value(brokerKey{}).(Broker)
+	return b
+}
@@ -0,0 +1,95 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package emitter
+
+import (
+	"fmt"
+	"net/url"
+	"testing"
+)
+
+func TestNewBroker(t *testing.T) {
+	config := BrokerConfig{Kafka: KafkaConfig{TopicPrefix: "guac."}}
+	tests := []struct {
+		name     string
+		uri      string
+		wantType string
+		wantURL  string
+		wantErr  bool
+	}{{
+		name:     "nats",
+		uri:      "nats://localhost:4222",
+		wantType: "*emitter.jetStream",
+		wantURL:  "nats://localhost:4222",
+	}, {
+		name:     "nats over tls",
+		uri:      "tls://nats.example.com:4222",
+		wantType: "*emitter.jetStream",
+		wantURL:  "tls://nats.example.com:4222",
+	}, {
+		name:     "kafka",
+		uri:      "kafka://localhost:8082",
+		wantType: "*emitter.kafka",
+		wantURL:  "http://localhost:8082",
+	}, {
+		name:     "kafka over https",
+		uri:      "kafka+https://kafka.example.com/rest",
+		wantType: "*emitter.kafka",
+		wantURL:  "https://kafka.example.com/rest",
+	}, {
+		name:     "memory",
+		uri:      "mem://",
+		wantType: "*emitter.memoryBroker",
+	}, {
+		name:    "unknown scheme",
+		uri:     "amqp://localhost:5672",
+		wantErr: true,
+	}, {
+		name:    "invalid uri",
+		uri:     "nats://local host:4222",
+		wantErr: true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := NewBroker(tt.uri, config)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewBroker() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := fmt.Sprintf("%T", b); got != tt.wantType {
+				t.Errorf("NewBroker() = %s, want %s", got, tt.wantType)
+			}
+			switch b := b.(type) {
+			case *jetStream:
+				if b.url != tt.wantURL {
+					t.Errorf("NewBroker() url = %s, want %s", b.url, tt.wantURL)
+				}
+			case *kafka:
+				if b.config.URL != tt.wantURL || b.config.TopicPrefix != "guac." {
+					t.Errorf("NewBroker() config = %+v, want the url %s and the topic prefix", b.config, tt.wantURL)
+				}
+			}
+		})
+	}
+}
+
+func TestRegisterBroker(t *testing.T) {
+	if err := RegisterBroker(SchemeMemory, func(*url.URL, BrokerConfig) (Broker, error) { return nil, nil }); err == nil {
+		t.Errorf("RegisterBroker() expected error overwriting a broker")
+	}
+}
@@ -0,0 +1,199 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package emitter
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/guacsec/guac/pkg/logging"
+	"github.com/guacsec/guac/pkg/metrics"
+	"golang.org/x/time/rate"
+)
+
+// PendingCounter is implemented by the brokers counting the messages waiting
+// on a subject
+type PendingCounter interface {
+	// Pending returns the number of messages published on subj and not yet
+	// acked by its consumers
+	Pending(ctx context.Context, subj string) (uint64, error)
+}
+
+// PipelineSubjects are the subjects the documents go through, from the
+// collectors to the ingestors
+var PipelineSubjects = []string{SubjectNameDocCollected, SubjectNameDocProcessed, SubjectNameDocRetry, SubjectNameDocDeadLetter}
+
+// throttledSubjects are the subjects whose publishers wait for the consumers
+// to catch up, see FlowControl.MaxPending. The retry and dead-letter subjects
+// are published on by the consumers themselves, which must not wait on them.
+var throttledSubjects = map[string]bool{SubjectNameDocCollected: true, SubjectNameDocProcessed: true}
+
+// PendingMessages returns the number of messages waiting on each subject of
+// the pipeline, nil if the broker of ctx does not count them
+func PendingMessages(ctx context.Context) (map[string]uint64, error) {
+	counter, ok := BrokerFromContext(ctx).(PendingCounter)
+	if !ok {
+		return nil, nil
+	}
+	pending := make(map[string]uint64, len(PipelineSubjects))
+	for _, subj := range PipelineSubjects {
+		n, err := counter.Pending(ctx, subj)
+		if err != nil {
+			return nil, fmt.Errorf("failed to count the messages pending on %s: %w", subj, err)
+		}
+		pending[subj] = n
+	}
+	return pending, nil
+}
+
+// pendingCountTimeout bounds the counting of the pending messages when the
+// metrics are served
+const pendingCountTimeout = 5 * time.Second
+
+// pendingMessages is the queue depth of each subject of the pipeline, see
+// ExportPendingMessages
+var pendingMessages = metrics.NewGaugeFunc("guac_queue_pending_messages",
+	"Messages waiting on each subject of the pipeline.", "subject")
+
+// ExportPendingMessages exports the number of messages waiting on each
+// subject of the pipeline as a metric, counted on the broker of ctx whenever
+// the metrics are served. Brokers that do not count them export none.
+func ExportPendingMessages(ctx context.Context) {
+	pendingMessages.SetFunc(func() map[string]float64 {
+		countCtx, cancel := context.WithTimeout(ctx, pendingCountTimeout)
+		defer cancel()
+		pending, err := PendingMessages(countCtx)
+		if err != nil {
+			logging.FromContext(ctx).Warnf("unable to count the pending messages: %v", err)
+			return nil
+		}
+		values := make(map[string]float64, len(pending))
+		for subj, n := range pending {
+			values[subj] = float64(n)
+		}
+		return values
+	})
+}
+
+// FlowControl is how fast the publishers of a process publish, so that a
+// bulk backfill does not overwhelm the stages downstream and the graph db
+type FlowControl struct {
+	// PublishRate caps the messages published per second, 0 for no cap
+	PublishRate float64
+	// MaxPending is the number of messages waiting on the collected or
+	// processed subject past which publishing on it waits for its consumers
+	// to catch up, 0 for no limit. Only the brokers implementing
+	// PendingCounter are waited for.
+	MaxPending uint64
+}
+
+var (
+	flowControlLock sync.RWMutex
+	flowControl     FlowControl
+	publishLimiter  *rate.Limiter
+	gate            = newPendingGate()
+)
+
+// SetFlowControl sets the flow control of Publish and PublishWithMsgID
+func SetFlowControl(fc FlowControl) error {
+	if fc.PublishRate < 0 {
+		return fmt.Errorf("publish rate must not be negative, got %v", fc.PublishRate)
+	}
+	flowControlLock.Lock()
+	defer flowControlLock.Unlock()
+	flowControl = fc
+	publishLimiter = nil
+	if fc.PublishRate > 0 {
+		publishLimiter = rate.NewLimiter(rate.Limit(fc.PublishRate), int(math.Max(1, fc.PublishRate)))
+	}
+	gate = newPendingGate()
+	return nil
+}
+
+// GetFlowControl returns the flow control of Publish and PublishWithMsgID
+func GetFlowControl() FlowControl {
+	flowControlLock.RLock()
+	defer flowControlLock.RUnlock()
+	return flowControl
+}
+
+// waitFlowControl waits until a message can be published on subj with b
+func waitFlowControl(ctx context.Context, b Broker, subj string) error {
+	flowControlLock.RLock()
+	fc, limiter, g := flowControl, publishLimiter, gate
+	flowControlLock.RUnlock()
+	if limiter != nil {
+		if err := limiter.Wait(ctx); err != nil {
+			return err
+		}
+	}
+	counter, ok := b.(PendingCounter)
+	if fc.MaxPending == 0 || !ok || !throttledSubjects[subj] {
+		return nil
+	}
+	return g.wait(ctx, counter, subj, fc.MaxPending)
+}
+
+// pendingGate holds the publishers back while too many messages are pending.
+// Counting the pending messages is a round trip to the broker, so it keeps an
+// upper bound of the messages pending on each subject: the count last seen
+// plus the messages published since, and only counts them again once the
+// bound reaches the limit.
+type pendingGate struct {
+	mu        sync.Mutex
+	estimates map[string]uint64
+}
+
+func newPendingGate() *pendingGate {
+	return &pendingGate{estimates: map[string]uint64{}}
+}
+
+// wait returns once fewer than max messages are pending on subj, counting
+// the message about to be published
+func (g *pendingGate) wait(ctx context.Context, counter PendingCounter, subj string, max uint64) error {
+	logger := logging.FromContext(logging.WithComponent(ctx, logging.ComponentEmitter))
+	for waited := false; ; waited = true {
+		g.mu.Lock()
+		if estimate, ok := g.estimates[subj]; ok && estimate < max {
+			g.estimates[subj] = estimate + 1
+			g.mu.Unlock()
+			return nil
+		}
+		g.mu.Unlock()
+
+		pending, err := counter.Pending(ctx, subj)
+		if err != nil {
+			return fmt.Errorf("failed to count the messages pending on %s: %w", subj, err)
+		}
+		g.mu.Lock()
+		g.estimates[subj] = pending
+		g.mu.Unlock()
+		if pending < max {
+			continue
+		}
+		if !waited {
+			logger.Infof("%d messages pending on %s, waiting for the consumers to catch up", pending, subj)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(BackOffTimer):
+		}
+	}
+}
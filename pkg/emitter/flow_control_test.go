@@ -0,0 +1,161 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package emitter
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/guacsec/guac/pkg/metrics"
+)
+
+func TestSetFlowControl(t *testing.T) {
+	defer func() {
+		_ = SetFlowControl(FlowControl{})
+	}()
+	if err := SetFlowControl(FlowControl{PublishRate: -1}); err == nil {
+		t.Errorf("SetFlowControl() expected error for a negative publish rate")
+	}
+	want := FlowControl{PublishRate: 10, MaxPending: 100}
+	if err := SetFlowControl(want); err != nil {
+		t.Fatal(err)
+	}
+	if got := GetFlowControl(); got != want {
+		t.Errorf("GetFlowControl() = %v, want %v", got, want)
+	}
+}
+
+func TestPublish_PublishRate(t *testing.T) {
+	if err := SetFlowControl(FlowControl{PublishRate: 20}); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = SetFlowControl(FlowControl{})
+	}()
+	ctx, err := NewMemoryBroker().Init(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error initializing the memory broker: %v", err)
+	}
+	start := time.Now()
+	// a burst of 20, then one every 50ms
+	for i := 0; i < 25; i++ {
+		if err := Publish(ctx, SubjectNameDocCollected, []byte(fmt.Sprint(i))); err != nil {
+			t.Fatalf("unexpected error on publish: %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("published 25 messages in %v, want at least 200ms at 20 per second", elapsed)
+	}
+}
+
+func TestPublish_MaxPending(t *testing.T) {
+	if err := SetFlowControl(FlowControl{MaxPending: 2}); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = SetFlowControl(FlowControl{})
+	}()
+	ctx, err := NewMemoryBroker().Init(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error initializing the memory broker: %v", err)
+	}
+	for _, data := range []string{"a", "b"} {
+		if err := Publish(ctx, SubjectNameDocCollected, []byte(data)); err != nil {
+			t.Fatalf("unexpected error on publish: %v", err)
+		}
+	}
+	// the subjects the consumers publish on are not held back
+	for _, data := range []string{"a", "b", "c"} {
+		if err := Publish(ctx, SubjectNameDocDeadLetter, []byte(data)); err != nil {
+			t.Fatalf("unexpected error on publish: %v", err)
+		}
+	}
+	pending, err := PendingMessages(ctx)
+	if err != nil {
+		t.Fatalf("PendingMessages() error = %v", err)
+	}
+	if pending[SubjectNameDocCollected] != 2 || pending[SubjectNameDocDeadLetter] != 3 || pending[SubjectNameDocProcessed] != 0 {
+		t.Errorf("PendingMessages() = %v, want 2 collected and 3 dead letters", pending)
+	}
+
+	published := make(chan error, 1)
+	go func() {
+		published <- Publish(ctx, SubjectNameDocCollected, []byte("c"))
+	}()
+	select {
+	case err := <-published:
+		t.Fatalf("Publish() = %v, want it to wait for the consumers", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	subCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	psub, err := NewPubSub(subCtx, "processor", SubjectNameDocCollected, DurableProcessor, BackOffTimer)
+	if err != nil {
+		t.Fatalf("unexpected error on subscribe: %v", err)
+	}
+	go func() {
+		_ = psub.GetDataFromNats(subCtx, func([]byte) error { return nil })
+	}()
+	select {
+	case err := <-published:
+		if err != nil {
+			t.Errorf("Publish() error = %v", err)
+		}
+	case <-time.After(3 * BackOffTimer):
+		t.Errorf("Publish() still waiting after the consumer caught up")
+	}
+
+	// waiting ends with ctx
+	if err := SetFlowControl(FlowControl{MaxPending: 1}); err != nil {
+		t.Fatal(err)
+	}
+	pubCtx, cancelPub := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancelPub()
+	if err := Publish(pubCtx, SubjectNameDocProcessed, []byte("a")); err != nil {
+		t.Fatalf("unexpected error on publish: %v", err)
+	}
+	if err := Publish(pubCtx, SubjectNameDocProcessed, []byte("b")); err == nil {
+		t.Errorf("Publish() expected error once ctx is done")
+	}
+}
+
+func TestExportPendingMessages(t *testing.T) {
+	defer pendingMessages.SetFunc(nil)
+	ctx, err := NewMemoryBroker().Init(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error initializing the memory broker: %v", err)
+	}
+	if err := Publish(ctx, SubjectNameDocProcessed, []byte("a")); err != nil {
+		t.Fatalf("unexpected error on publish: %v", err)
+	}
+	ExportPendingMessages(ctx)
+	var b strings.Builder
+	if err := metrics.Write(&b); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`guac_queue_pending_messages{subject="` + SubjectNameDocProcessed + `"} 1`,
+		`guac_queue_pending_messages{subject="` + SubjectNameDocCollected + `"} 0`,
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("missing %q in metrics:\n%s", want, b.String())
+		}
+	}
+}
@@ -0,0 +1,317 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package emitter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/guacsec/guac/pkg/logging"
+)
+
+const (
+	// kafkaContentType is the content type of the records, embedded as JSON
+	kafkaContentType = "application/vnd.kafka.json.v2+json"
+	// kafkaV2ContentType is the content type of the other requests
+	kafkaV2ContentType = "application/vnd.kafka.v2+json"
+	// kafkaPollTimeout is how long the REST Proxy waits for records before
+	// answering a poll with none
+	kafkaPollTimeout = time.Second
+	// kafkaCloseTimeout bounds the deletion of the consumer instances
+	kafkaCloseTimeout = 5 * time.Second
+)
+
+// KafkaConfig is the connection to Kafka through a Kafka REST Proxy
+type KafkaConfig struct {
+	// URL is the URL of the REST Proxy, e.g. http://localhost:8082
+	URL string
+	// User and Password authenticate to the REST Proxy with HTTP basic
+	// authentication if User is set
+	User     string
+	Password string
+	// TopicPrefix prefixes the topics of the subjects and the consumer
+	// groups of the durable consumers, e.g. `guac.` for the topic
+	// `guac.DOCUMENTS.collected`, so that GUAC deployments can share a
+	// cluster
+	TopicPrefix string
+}
+
+// kafka emits the documents on Kafka through the v2 API of a Kafka REST Proxy,
+// so that GUAC needs no Kafka client. Each subject is a topic, which is
+// created by the cluster or its operators, and each durable consumer is a
+// consumer group in which every subscriber gets a consumer instance. The
+// records carry the messages in a JSON envelope, as the v2 API has no record
+// headers. Kafka does not drop duplicates, so the message ID is the key of
+// the record to keep the duplicates of a document on the same partition.
+type kafka struct {
+	config KafkaConfig
+	client *http.Client
+
+	mu sync.Mutex
+	// consumers are the URIs of the consumer instances, deleted on Close
+	consumers []string
+}
+
+// kafkaEnvelope is the value of a record
+type kafkaEnvelope struct {
+	Header Header `json:"header,omitempty"`
+	Data   []byte `json:"data"`
+}
+
+// kafkaRecord is a record read from a topic
+type kafkaRecord struct {
+	Topic     string        `json:"topic"`
+	Partition int           `json:"partition"`
+	Offset    int64         `json:"offset"`
+	Key       string        `json:"key"`
+	Value     kafkaEnvelope `json:"value"`
+}
+
+// kafkaError is an error returned by the REST Proxy
+type kafkaError struct {
+	Status  int    `json:"-"`
+	Code    int    `json:"error_code"`
+	Message string `json:"message"`
+}
+
+func (e *kafkaError) Error() string {
+	return fmt.Sprintf("kafka rest proxy error %d (HTTP %d): %s", e.Code, e.Status, e.Message)
+}
+
+// NewKafka initializes the Kafka emitter connecting to the REST Proxy of
+// config
+func NewKafka(config KafkaConfig) *kafka {
+	return &kafka{
+		config: config,
+		client: &http.Client{Transport: http.DefaultTransport.(*http.Transport).Clone()},
+	}
+}
+
+// Init checks that the REST Proxy responds
+func (k *kafka) Init(ctx context.Context) (context.Context, error) {
+	u, err := url.Parse(k.config.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ctx, fmt.Errorf("invalid kafka rest proxy url %q: must be an http or https url", k.config.URL)
+	}
+	if err := k.Ping(ctx); err != nil {
+		return ctx, fmt.Errorf("unable to connect to kafka rest proxy: %w", err)
+	}
+	return WithBroker(ctx, k), nil
+}
+
+// Ping checks that the REST Proxy responds by listing the topics
+func (k *kafka) Ping(ctx context.Context) error {
+	return k.request(ctx, http.MethodGet, k.endpoint("/topics"), "", nil, nil)
+}
+
+// Close deletes the consumer instances, so that their partitions go to the
+// other members of their groups right away
+func (k *kafka) Close() {
+	k.mu.Lock()
+	consumers := k.consumers
+	k.consumers = nil
+	k.mu.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), kafkaCloseTimeout)
+	defer cancel()
+	for _, consumer := range consumers {
+		_ = k.request(ctx, http.MethodDelete, consumer, kafkaV2ContentType, nil, nil)
+	}
+	k.client.CloseIdleConnections()
+}
+
+// topic returns the topic of subj
+func (k *kafka) topic(subj string) string {
+	return k.config.TopicPrefix + subj
+}
+
+// endpoint returns the URL of path on the REST Proxy
+func (k *kafka) endpoint(path string) string {
+	return strings.TrimSuffix(k.config.URL, "/") + path
+}
+
+// Publish produces msg on the topic of its subject, keyed by its ID
+func (k *kafka) Publish(ctx context.Context, msg *Message) error {
+	body := map[string]interface{}{
+		"records": []map[string]interface{}{{
+			"key":   msg.ID,
+			"value": kafkaEnvelope{Header: msg.Header, Data: msg.Data},
+		}},
+	}
+	var produced struct {
+		Offsets []struct {
+			Code    *int   `json:"error_code"`
+			Message string `json:"error"`
+		} `json:"offsets"`
+	}
+	if err := k.request(ctx, http.MethodPost, k.endpoint("/topics/"+url.PathEscape(k.topic(msg.Subject))), kafkaContentType, body, &produced); err != nil {
+		return err
+	}
+	for _, o := range produced.Offsets {
+		if o.Code != nil {
+			return &kafkaError{Status: http.StatusOK, Code: *o.Code, Message: o.Message}
+		}
+	}
+	return nil
+}
+
+// Subscribe joins the consumer group of durable with a new consumer
+// instance subscribed to the topic of subj, and polls its records,
+// committing their offsets once they are acked
+func (k *kafka) Subscribe(ctx context.Context, id string, subj string, durable string, backOffTimer time.Duration) (<-chan *Message, <-chan error, error) {
+	logger := logging.FromContext(logging.WithComponent(ctx, logging.ComponentEmitter))
+	if err := ValidateDurableName(durable); err != nil {
+		return nil, nil, err
+	}
+	var instance struct {
+		ID      string `json:"instance_id"`
+		BaseURI string `json:"base_uri"`
+	}
+	group := k.config.TopicPrefix + durable
+	err := k.request(ctx, http.MethodPost, k.endpoint("/consumers/"+url.PathEscape(group)), kafkaV2ContentType, map[string]interface{}{
+		"format":             "json",
+		"auto.offset.reset":  "earliest",
+		"auto.commit.enable": "false",
+	}, &instance)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s consumer creation failed: %w", durable, err)
+	}
+	k.mu.Lock()
+	k.consumers = append(k.consumers, instance.BaseURI)
+	k.mu.Unlock()
+	if err := k.request(ctx, http.MethodPost, instance.BaseURI+"/subscription", kafkaV2ContentType,
+		map[string]interface{}{"topics": []string{k.topic(subj)}}, nil); err != nil {
+		return nil, nil, fmt.Errorf("%s subscribe failed: %w", durable, err)
+	}
+
+	dataChan := make(chan *Message, BufferChannelSize)
+	errChan := make(chan error, 1)
+	records := instance.BaseURI + "/records?timeout=" + fmt.Sprint(kafkaPollTimeout.Milliseconds())
+	go func() {
+		for {
+			if ctx.Err() != nil {
+				errChan <- ctx.Err()
+				return
+			}
+			var polled []kafkaRecord
+			if err := k.request(ctx, http.MethodGet, records, kafkaContentType, nil, &polled); err != nil {
+				errChan <- fmt.Errorf("[%s: %s] unexpected kafka poll error: %w", durable, id, err)
+				return
+			}
+			if len(polled) == 0 {
+				logger.Infof("[%s: %s] nothing to consume, backing off for %s", durable, id, backOffTimer.String())
+				select {
+				case <-ctx.Done():
+				case <-time.After(backOffTimer):
+				}
+				continue
+			}
+			// the offsets are committed once all the records polled are
+			// acked, and the records from the last commit delivered again
+			// to the consumer group if one is nacked
+			handled := make(chan bool, len(polled))
+			for _, r := range polled {
+				msg := &Message{Subject: subj, ID: r.Key, Header: r.Value.Header, Data: r.Value.Data}
+				msg.Ack = func() error {
+					handled <- true
+					return nil
+				}
+				msg.Nak = func() error {
+					handled <- false
+					return nil
+				}
+				dataChan <- msg
+			}
+			for range polled {
+				select {
+				case <-ctx.Done():
+					errChan <- ctx.Err()
+					return
+				case acked := <-handled:
+					if !acked {
+						errChan <- fmt.Errorf("[%s: %s] record nacked, the records polled since the last commit are delivered again", durable, id)
+						return
+					}
+				}
+			}
+			// an empty commit commits the offsets of the records polled
+			if err := k.request(ctx, http.MethodPost, instance.BaseURI+"/offsets", kafkaV2ContentType, nil, nil); err != nil {
+				fmtErr := fmt.Errorf("[%s: %v] unable to commit offsets: %w", durable, id, err)
+				logger.Error(fmtErr)
+				errChan <- fmtErr
+				return
+			}
+		}
+	}()
+	return dataChan, errChan, nil
+}
+
+// request sends a request with the JSON body, if not nil, to the REST Proxy
+// and decodes the JSON response into result, if not nil. The content type
+// of the body and of the response is contentType.
+func (k *kafka) request(ctx context.Context, method string, endpoint string, contentType string, body interface{}, result interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, reader)
+	if err != nil {
+		return err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+		req.Header.Set("Accept", contentType)
+	}
+	if k.config.User != "" {
+		req.SetBasicAuth(k.config.User, k.config.Password)
+	}
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		kerr := &kafkaError{}
+		if err := json.Unmarshal(data, kerr); err != nil || kerr.Message == "" {
+			kerr = &kafkaError{Message: http.StatusText(resp.StatusCode)}
+		}
+		kerr.Status = resp.StatusCode
+		return kerr
+	}
+	if result == nil || len(data) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(data, result); err != nil {
+		return errors.New("invalid kafka rest proxy response: " + err.Error())
+	}
+	return nil
+}
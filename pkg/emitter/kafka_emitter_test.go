@@ -0,0 +1,224 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package emitter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/guacsec/guac/internal/testing/dochelper"
+	"github.com/guacsec/guac/pkg/handler/processor"
+)
+
+// fakeKafka is a Kafka REST Proxy keeping the records of each topic in
+// memory, and the offset committed by each consumer group
+type fakeKafka struct {
+	mu sync.Mutex
+	// records of each topic
+	records map[string][]kafkaRecord
+	// committed offsets and subscribed topic of each consumer group
+	committed map[string]int
+	polled    map[string]int
+	topics    map[string]string
+	deleted   int
+	server    *httptest.Server
+}
+
+func newFakeKafka(t *testing.T) *fakeKafka {
+	f := &fakeKafka{
+		records:   map[string][]kafkaRecord{},
+		committed: map[string]int{},
+		polled:    map[string]int{},
+		topics:    map[string]string{},
+	}
+	f.server = httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(f.server.Close)
+	return f
+}
+
+func (f *fakeKafka) serve(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	path := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/topics":
+		_ = json.NewEncoder(w).Encode([]string{})
+	case r.Method == http.MethodPost && len(path) == 2 && path[0] == "topics":
+		if r.Header.Get("Content-Type") != kafkaContentType {
+			http.Error(w, `{"error_code":415,"message":"unsupported content type"}`, http.StatusUnsupportedMediaType)
+			return
+		}
+		var body struct {
+			Records []struct {
+				Key   string        `json:"key"`
+				Value kafkaEnvelope `json:"value"`
+			} `json:"records"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		for _, rec := range body.Records {
+			f.records[path[1]] = append(f.records[path[1]], kafkaRecord{Topic: path[1], Offset: int64(len(f.records[path[1]])), Key: rec.Key, Value: rec.Value})
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"offsets": []map[string]interface{}{{"partition": 0, "offset": 0}}})
+	case r.Method == http.MethodPost && len(path) == 2 && path[0] == "consumers":
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"instance_id": "instance",
+			"base_uri":    fmt.Sprintf("%s/consumers/%s/instances/instance", f.server.URL, path[1]),
+		})
+	case len(path) >= 4 && path[0] == "consumers":
+		group := path[1]
+		switch {
+		case r.Method == http.MethodDelete && len(path) == 4:
+			f.deleted++
+			w.WriteHeader(http.StatusNoContent)
+		case r.Method == http.MethodPost && path[4] == "subscription":
+			var body struct {
+				Topics []string `json:"topics"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil || len(body.Topics) != 1 {
+				http.Error(w, "invalid subscription", http.StatusBadRequest)
+				return
+			}
+			f.topics[group] = body.Topics[0]
+			w.WriteHeader(http.StatusNoContent)
+		case r.Method == http.MethodGet && path[4] == "records":
+			records := f.records[f.topics[group]][f.committed[group]:]
+			f.polled[group] = f.committed[group] + len(records)
+			_ = json.NewEncoder(w).Encode(records)
+		case r.Method == http.MethodPost && path[4] == "offsets":
+			f.committed[group] = f.polled[group]
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.NotFound(w, r)
+		}
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestKafkaEmitter_PublishSubscribe(t *testing.T) {
+	fake := newFakeKafka(t)
+	expectedDocTree := dochelper.DocNode(&ite6SLSADoc)
+
+	k := NewKafka(KafkaConfig{URL: fake.server.URL, TopicPrefix: "guac."})
+	ctx, err := k.Init(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error initializing kafka: %v", err)
+	}
+	ctx = WithCompression(ctx)
+	if err := testPublish(ctx, &ite6SLSADoc); err != nil {
+		t.Fatalf("unexpected error on emit: %v", err)
+	}
+	fake.mu.Lock()
+	topic := "guac." + SubjectNameDocCollected
+	if len(fake.records[topic]) != 1 || fake.records[topic][0].Key == "" {
+		t.Errorf("topic %s has records %v, want one keyed by its digest", topic, fake.records[topic])
+	}
+	fake.mu.Unlock()
+
+	subCtx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	var got []processor.DocumentTree
+	err = testSubscribe(subCtx, func(d processor.DocumentTree) error {
+		got = append(got, d)
+		return nil
+	})
+	if err != nil && !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("kafka emitter Subscribe test errored = %v", err)
+	}
+	if len(got) != 1 || !dochelper.DocTreeEqual(got[0], expectedDocTree) {
+		t.Errorf("subscribed to %d documents, want the document published", len(got))
+	}
+
+	fake.mu.Lock()
+	if committed := fake.committed["guac."+DurableProcessor]; committed != 1 {
+		t.Errorf("consumer group committed offset %d, want 1", committed)
+	}
+	fake.mu.Unlock()
+	k.Close()
+	if fake.deleted != 1 {
+		t.Errorf("Close() deleted %d consumer instances, want 1", fake.deleted)
+	}
+}
+
+func TestKafkaEmitter_Init(t *testing.T) {
+	fake := newFakeKafka(t)
+	tests := []struct {
+		name    string
+		url     string
+		wantErr bool
+	}{{
+		name: "rest proxy",
+		url:  fake.server.URL,
+	}, {
+		name:    "invalid url",
+		url:     "localhost:8082",
+		wantErr: true,
+	}, {
+		name:    "not a rest proxy",
+		url:     fake.server.URL + "/missing",
+		wantErr: true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, err := NewKafka(KafkaConfig{URL: tt.url}).Init(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Init() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && BrokerFromContext(ctx) == nil {
+				t.Errorf("Init() context has no broker")
+			}
+		})
+	}
+}
+
+func TestKafkaEmitter_Nak(t *testing.T) {
+	fake := newFakeKafka(t)
+	k := NewKafka(KafkaConfig{URL: fake.server.URL})
+	ctx, err := k.Init(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error initializing kafka: %v", err)
+	}
+	defer k.Close()
+	if err := Publish(ctx, SubjectNameDocProcessed, []byte("document")); err != nil {
+		t.Fatalf("unexpected error on publish: %v", err)
+	}
+
+	failed := errors.New("ingestor crashed")
+	psub, err := NewPubSub(ctx, "crashing", SubjectNameDocProcessed, DurableIngestor, BackOffTimer)
+	if err != nil {
+		t.Fatalf("unexpected error on subscribe: %v", err)
+	}
+	if err := psub.GetDataFromNats(ctx, func([]byte) error { return failed }); !errors.Is(err, failed) {
+		t.Fatalf("GetDataFromNats() error = %v, want %v", err, failed)
+	}
+	time.Sleep(100 * time.Millisecond)
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	if committed := fake.committed[DurableIngestor]; committed != 0 {
+		t.Errorf("consumer group committed offset %d after a nack, want 0", committed)
+	}
+}
@@ -0,0 +1,265 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package emitter
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// errMemoryBrokerClosed is returned by a memory broker once closed
+var errMemoryBrokerClosed = errors.New("memory broker is closed")
+
+// memoryBroker is a broker in the memory of the process, connecting the
+// stages of a pipeline run in a single process through channels. A subject
+// keeps the messages published on it for the life of the broker, and each
+// durable consumer reads them from the first one, so that the messages
+// published before a stage subscribes are not lost. Nacked messages are
+// delivered again to the durable consumer. The messages of a partition are
+// handed one at a time, the next once the previous is acked or nacked.
+// Messages published again with the ID of a message of the subject are
+// dropped.
+type memoryBroker struct {
+	mu       sync.Mutex
+	subjects map[string]*memorySubject
+	// published is closed and replaced whenever a message is published or
+	// nacked, to wake up the subscribers waiting for one
+	published chan struct{}
+	closed    bool
+}
+
+// memorySubject is the messages of a subject of a memory broker
+type memorySubject struct {
+	messages []*Message
+	ids      map[string]bool
+	// offsets are the index of the next message of each durable consumer
+	offsets map[string]int
+	// nacked are the messages to deliver again to each durable consumer
+	nacked map[string][]*Message
+	// inFlight are the durable consumers of a partition handed a message
+	// not yet acked or nacked
+	inFlight map[string]bool
+}
+
+// NewMemoryBroker initializes a broker in the memory of the process
+func NewMemoryBroker() *memoryBroker {
+	return &memoryBroker{
+		subjects:  map[string]*memorySubject{},
+		published: make(chan struct{}),
+	}
+}
+
+// Init returns ctx with the broker
+func (b *memoryBroker) Init(ctx context.Context) (context.Context, error) {
+	return WithBroker(ctx, b), nil
+}
+
+// Ping returns an error once the broker is closed
+func (b *memoryBroker) Ping(context.Context) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return errMemoryBrokerClosed
+	}
+	return nil
+}
+
+// Close ends the subscriptions and drops the messages
+func (b *memoryBroker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.closed {
+		b.closed = true
+		b.subjects = map[string]*memorySubject{}
+		close(b.published)
+	}
+}
+
+// Publish appends msg to the messages of its subject
+func (b *memoryBroker) Publish(_ context.Context, msg *Message) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return errMemoryBrokerClosed
+	}
+	s := b.subject(msg.Subject)
+	if msg.ID != "" {
+		if s.ids[msg.ID] {
+			return nil
+		}
+		s.ids[msg.ID] = true
+	}
+	s.messages = append(s.messages, msg)
+	b.wake()
+	return nil
+}
+
+// Pending returns the number of messages of subj and its partitions not yet
+// handed to the durable consumer furthest behind, all of them if none
+// subscribed
+func (b *memoryBroker) Pending(_ context.Context, subj string) (uint64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return 0, errMemoryBrokerClosed
+	}
+	var pending uint64
+	for name, s := range b.subjects {
+		if baseSubject(name) == subj {
+			pending += s.pending()
+		}
+	}
+	return pending, nil
+}
+
+// pending returns the number of messages of s not yet handed to the durable
+// consumer furthest behind, with b.mu held
+func (s *memorySubject) pending() uint64 {
+	if len(s.offsets) == 0 {
+		return uint64(len(s.messages))
+	}
+	var pending int
+	for durable, offset := range s.offsets {
+		if n := len(s.messages) - offset + len(s.nacked[durable]); n > pending {
+			pending = n
+		}
+	}
+	return uint64(pending)
+}
+
+// Subscribe hands the messages of subj to the subscribers of the durable
+// consumer, each message to one of them, waiting for more once all were
+// handed. The messages are handed one at a time, so that a subscriber
+// stopping does not take away the messages of the others.
+func (b *memoryBroker) Subscribe(ctx context.Context, _ string, subj string, durable string, _ time.Duration) (<-chan *Message, <-chan error, error) {
+	if err := ValidateDurableName(durable); err != nil {
+		return nil, nil, err
+	}
+	if err := b.Ping(ctx); err != nil {
+		return nil, nil, err
+	}
+	dataChan := make(chan *Message)
+	errChan := make(chan error, 1)
+	go func() {
+		for {
+			if ctx.Err() != nil {
+				errChan <- ctx.Err()
+				return
+			}
+			msg, published, err := b.next(subj, durable)
+			if err != nil {
+				errChan <- err
+				return
+			}
+			if msg == nil {
+				select {
+				case <-ctx.Done():
+				case <-published:
+				}
+				continue
+			}
+			select {
+			case <-ctx.Done():
+				// not handed, so delivered to the next subscriber as if
+				// it was not delivered yet
+				msg.Delivered--
+				_ = msg.Nak()
+			case dataChan <- msg:
+			}
+		}
+	}()
+	return dataChan, errChan, nil
+}
+
+// next returns the next message of subj for the durable consumer, or else
+// the channel closed once a message is published or nacked
+func (b *memoryBroker) next(subj string, durable string) (*Message, <-chan struct{}, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return nil, nil, errMemoryBrokerClosed
+	}
+	s := b.subject(subj)
+	if s.inFlight[durable] {
+		return nil, b.published, nil
+	}
+	var msg Message
+	if nacked := s.nacked[durable]; len(nacked) > 0 {
+		msg, s.nacked[durable] = *nacked[0], nacked[1:]
+	} else if offset := s.offsets[durable]; offset < len(s.messages) {
+		msg = *s.messages[offset]
+		s.offsets[durable] = offset + 1
+	} else {
+		return nil, b.published, nil
+	}
+	msg.Delivered++
+	msg.Nak = func() error {
+		return b.nack(subj, durable, &msg)
+	}
+	if isPartitionSubject(subj) {
+		s.inFlight[durable] = true
+		msg.Ack = func() error {
+			return b.ack(subj, durable)
+		}
+	}
+	return &msg, nil, nil
+}
+
+// ack lets the durable consumer of the partition subj be handed its next
+// message
+func (b *memoryBroker) ack(subj string, durable string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return errMemoryBrokerClosed
+	}
+	delete(b.subject(subj).inFlight, durable)
+	b.wake()
+	return nil
+}
+
+// nack delivers msg again to the durable consumer of subj, counting the
+// deliveries so far
+func (b *memoryBroker) nack(subj string, durable string, msg *Message) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return errMemoryBrokerClosed
+	}
+	s := b.subject(subj)
+	s.nacked[durable] = append(s.nacked[durable], msg)
+	delete(s.inFlight, durable)
+	b.wake()
+	return nil
+}
+
+// wake wakes up the subscribers waiting for a message, with b.mu held
+func (b *memoryBroker) wake() {
+	close(b.published)
+	b.published = make(chan struct{})
+}
+
+// subject returns the messages of subj, with b.mu held
+func (b *memoryBroker) subject(subj string) *memorySubject {
+	s, ok := b.subjects[subj]
+	if !ok {
+		s = &memorySubject{ids: map[string]bool{}, offsets: map[string]int{}, nacked: map[string][]*Message{}, inFlight: map[string]bool{}}
+		b.subjects[subj] = s
+	}
+	return s
+}
@@ -0,0 +1,134 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package emitter
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMemoryBroker(t *testing.T) {
+	b := NewMemoryBroker()
+	ctx, err := b.Init(WithCompression(context.Background()))
+	if err != nil {
+		t.Fatalf("unexpected error initializing the memory broker: %v", err)
+	}
+	// published before anyone subscribes, twice
+	for _, data := range []string{"a", "b", "a"} {
+		if err := Publish(ctx, SubjectNameDocCollected, []byte(data)); err != nil {
+			t.Fatalf("unexpected error on publish: %v", err)
+		}
+	}
+
+	subCtx, cancel := context.WithCancel(ctx)
+	var mu sync.Mutex
+	got := map[string][]string{}
+	var wg sync.WaitGroup
+	// two replicas of the processor split the documents, and the ingestor
+	// gets all of them
+	for i, durable := range []string{DurableProcessor, DurableProcessor, DurableIngestor} {
+		psub, err := NewPubSub(subCtx, string(rune('0'+i)), SubjectNameDocCollected, durable, BackOffTimer)
+		if err != nil {
+			t.Fatalf("unexpected error on subscribe: %v", err)
+		}
+		wg.Add(1)
+		go func(durable string) {
+			defer wg.Done()
+			err := psub.GetDataFromNats(subCtx, func(d []byte) error {
+				mu.Lock()
+				defer mu.Unlock()
+				got[durable] = append(got[durable], string(d))
+				return nil
+			})
+			if !errors.Is(err, context.Canceled) {
+				t.Errorf("GetDataFromNats() error = %v, want context.Canceled", err)
+			}
+		}(durable)
+	}
+	if err := Publish(ctx, SubjectNameDocCollected, []byte("c")); err != nil {
+		t.Fatalf("unexpected error on publish: %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+	wg.Wait()
+
+	want := []string{"a", "b", "c"}
+	for _, durable := range []string{DurableProcessor, DurableIngestor} {
+		sort.Strings(got[durable])
+		if len(got[durable]) != len(want) {
+			t.Fatalf("%s got %v, want %v", durable, got[durable], want)
+		}
+		for i := range want {
+			if got[durable][i] != want[i] {
+				t.Errorf("%s got %v, want %v", durable, got[durable], want)
+			}
+		}
+	}
+
+	b.Close()
+	if err := b.Ping(ctx); err == nil {
+		t.Errorf("Ping() expected error after the broker was closed")
+	}
+	if err := Publish(ctx, SubjectNameDocCollected, []byte("d")); err == nil {
+		t.Errorf("Publish() expected error after the broker was closed")
+	}
+}
+
+func TestMemoryBroker_Nak(t *testing.T) {
+	ctx, err := NewMemoryBroker().Init(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error initializing the memory broker: %v", err)
+	}
+	for _, data := range []string{"a", "b"} {
+		if err := Publish(ctx, SubjectNameDocProcessed, []byte(data)); err != nil {
+			t.Fatalf("unexpected error on publish: %v", err)
+		}
+	}
+	failed := errors.New("ingestor crashed")
+	crashCtx, crash := context.WithCancel(ctx)
+	psub, err := NewPubSub(crashCtx, "crashing", SubjectNameDocProcessed, DurableIngestor, BackOffTimer)
+	if err != nil {
+		t.Fatalf("unexpected error on subscribe: %v", err)
+	}
+	if err := psub.GetDataFromNats(crashCtx, func([]byte) error { return failed }); !errors.Is(err, failed) {
+		t.Fatalf("GetDataFromNats() error = %v, want %v", err, failed)
+	}
+	crash()
+
+	// the message nacked is delivered again to the durable consumer
+	subCtx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	psub, err = NewPubSub(subCtx, "restarted", SubjectNameDocProcessed, DurableIngestor, BackOffTimer)
+	if err != nil {
+		t.Fatalf("unexpected error on subscribe: %v", err)
+	}
+	var got []string
+	err = psub.GetDataFromNats(subCtx, func(d []byte) error {
+		got = append(got, string(d))
+		return nil
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("GetDataFromNats() error = %v, want context.DeadlineExceeded", err)
+	}
+	sort.Strings(got)
+	if len(got) != 2 || got[0] != "a" || got[1] != "b" {
+		t.Errorf("GetDataFromNats() got %v, want [a b]", got)
+	}
+}
@@ -17,25 +17,53 @@ package emitter
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"time"
+
+	"github.com/guacsec/guac/pkg/logging"
+	"github.com/guacsec/guac/pkg/tracing"
 )
 
 // DataFunc determines how the data return from NATS is transformed based on implementation per module
 type DataFunc func([]byte) error
 
+// ContextDataFunc is a DataFunc also given the context of the message, which
+// carries the trace context the message was published with
+type ContextDataFunc func(context.Context, []byte) error
+
 type pubSub struct {
-	dataChan <-chan []byte
+	id       string
+	durable  string
+	dataChan <-chan *Message
 	errChan  <-chan error
 }
 
-// NewPubSub initializes the subscriber via the valid subject and durable string. Returning a dataChan and errChan to fetch
-// data on the stream
+// NewPubSub initializes the subscriber of the broker of ctx via the valid subject and durable string. Returning a dataChan and errChan to fetch
+// data on the stream. The subscribers of all processes using the same durable
+// name share the consumer, a consumer group on Kafka, and the broker hands
+// each message to only one of them, so replicas of the processor or ingestor
+// split the documents between them. Partitioned subjects are subscribed to
+// partition by partition, see SetPartitions.
 func NewPubSub(ctx context.Context, id string, subj string, durable string, backOffTimer time.Duration) (*pubSub, error) {
-	dataChan, errchan, err := createSubscriber(ctx, id, subj, durable, backOffTimer)
+	b := BrokerFromContext(ctx)
+	if b == nil {
+		return nil, errors.New("broker not found from context")
+	}
+	var dataChan <-chan *Message
+	var errchan <-chan error
+	var err error
+	if partitions := GetPartitions(); partitions > 1 && isPartitioned(subj) {
+		dataChan, errchan, err = subscribePartitions(ctx, b, id, subj, durable, backOffTimer, partitions)
+	} else {
+		dataChan, errchan, err = b.Subscribe(ctx, id, subj, durable, backOffTimer)
+	}
 	if err != nil {
 		return nil, err
 	}
 	return &pubSub{
+		id:       id,
+		durable:  durable,
 		dataChan: dataChan,
 		errChan:  errchan,
 	}, nil
@@ -43,16 +71,27 @@ func NewPubSub(ctx context.Context, id string, subj string, durable string, back
 
 // GetDataFromNats retrieves the data from the channels and transforms it via the DataFunc defined per module
 func (psub *pubSub) GetDataFromNats(ctx context.Context, dataFunc DataFunc) error {
+	return psub.GetDataFromNatsWithContext(ctx, func(_ context.Context, d []byte) error {
+		return dataFunc(d)
+	})
+}
+
+// GetDataFromNatsWithContext is like GetDataFromNats, passing dataFunc ctx
+// with the trace context and ordering key of each message, so that the spans
+// of the document continue the trace of the stage that published it. Messages whose data
+// cannot be decoded, or that dataFunc failed on GetDeadLetterAttempts times,
+// are moved to the dead-letter subject and the subscriber carries on with the
+// next message.
+func (psub *pubSub) GetDataFromNatsWithContext(ctx context.Context, dataFunc ContextDataFunc) error {
 	for {
 		select {
-		case d := <-psub.dataChan:
-			if err := dataFunc(d); err != nil {
+		case m := <-psub.dataChan:
+			if err := psub.handle(ctx, m, dataFunc); err != nil {
 				return err
 			}
 		case err := <-psub.errChan:
 			for len(psub.dataChan) > 0 {
-				d := <-psub.dataChan
-				if err := dataFunc(d); err != nil {
+				if err := psub.handle(ctx, <-psub.dataChan, dataFunc); err != nil {
 					return err
 				}
 			}
@@ -60,3 +99,73 @@ func (psub *pubSub) GetDataFromNats(ctx context.Context, dataFunc DataFunc) erro
 		}
 	}
 }
+
+// handle passes the data of m to dataFunc, acking m once handled. If dataFunc
+// fails, m is nacked so that it is delivered again, or moved to the
+// dead-letter subject once out of attempts. Brokers not counting the
+// deliveries deliver m again until handled.
+func (psub *pubSub) handle(ctx context.Context, m *Message, dataFunc ContextDataFunc) error {
+	data, err := messageDocument(ctx, m)
+	if err != nil {
+		return psub.deadLetter(ctx, m, err)
+	}
+	msgCtx := tracing.Extract(ctx, m.Header.Get(tracing.TraceparentHeader))
+	if key := m.Header.Get(OrderingKeyHeader); key != "" {
+		msgCtx = WithOrderingKey(msgCtx, key)
+	}
+	if err := dataFunc(msgCtx, data); err != nil {
+		// a subscriber stopping is not a failure of the message
+		if ctx.Err() == nil && m.Delivered > 0 && m.Delivered >= GetDeadLetterAttempts() {
+			return psub.deadLetter(ctx, m, err)
+		}
+		psub.nak(ctx, m)
+		return err
+	}
+	return psub.ack(m)
+}
+
+// deadLetter moves m, which failed with err, to the dead-letter subject. m is
+// nacked if it cannot be moved.
+func (psub *pubSub) deadLetter(ctx context.Context, m *Message, err error) error {
+	logger := logging.FromContext(logging.WithComponent(ctx, logging.ComponentEmitter))
+	attempts := m.Delivered
+	if attempts == 0 {
+		attempts = 1
+	}
+	d := DeadLetter{
+		Subject:  baseSubject(m.Subject),
+		Consumer: psub.durable,
+		Attempts: attempts,
+		Error:    err.Error(),
+		FailedAt: time.Now().UTC(),
+		Header:   m.Header,
+		Data:     m.Data,
+	}
+	if pubErr := PublishDeadLetter(ctx, d); pubErr != nil {
+		psub.nak(ctx, m)
+		return fmt.Errorf("[%s: %v] unable to move message to the dead-letter subject: %w", psub.durable, psub.id, pubErr)
+	}
+	logger.Warnf("[%s: %v] moved message to %s after %d failed attempts: %v", psub.durable, psub.id, SubjectNameDocDeadLetter, attempts, err)
+	return psub.ack(m)
+}
+
+// nak nacks m, logging if it fails
+func (psub *pubSub) nak(ctx context.Context, m *Message) {
+	if m.Nak == nil {
+		return
+	}
+	if err := m.Nak(); err != nil {
+		logging.FromContext(logging.WithComponent(ctx, logging.ComponentEmitter)).Errorf("[%s: %v] unable to Nak: %v", psub.durable, psub.id, err)
+	}
+}
+
+// ack acks m
+func (psub *pubSub) ack(m *Message) error {
+	if m.Ack == nil {
+		return nil
+	}
+	if err := m.Ack(); err != nil {
+		return fmt.Errorf("[%s: %v] unable to Ack: %w", psub.durable, psub.id, err)
+	}
+	return nil
+}
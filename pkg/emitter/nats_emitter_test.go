@@ -20,6 +20,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"sync"
 	"testing"
 	"time"
 
@@ -245,6 +246,32 @@ func TestNatsEmitter_RecreateStream(t *testing.T) {
 	}
 }
 
+func TestNatsEmitter_Ping(t *testing.T) {
+	natsTest := nats_test.NewNatsTestServer()
+	url, err := natsTest.EnableJetStreamForTest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer natsTest.Shutdown()
+
+	ctx := context.Background()
+	jetStream := NewJetStream(url, "", "")
+	if err := jetStream.Ping(ctx); err == nil {
+		t.Errorf("Ping() expected error before initialization")
+	}
+	ctx, err = jetStream.JetStreamInit(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error initializing jetstream: %v", err)
+	}
+	if err := jetStream.Ping(ctx); err != nil {
+		t.Errorf("Ping() unexpected error = %v", err)
+	}
+	jetStream.Close()
+	if err := jetStream.Ping(ctx); err == nil {
+		t.Errorf("Ping() expected error after the connection was closed")
+	}
+}
+
 func testPublish(ctx context.Context, d *processor.Document) error {
 	logger := logging.FromContext(ctx)
 	docByte, err := json.Marshal(d)
@@ -299,3 +326,377 @@ func testSubscribe(ctx context.Context, transportFunc func(processor.DocumentTre
 	}
 	return nil
 }
+
+func TestPublishWithMsgID_DuplicateWindow(t *testing.T) {
+	natsTest := nats_test.NewNatsTestServer()
+	url, err := natsTest.EnableJetStreamForTest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer natsTest.Shutdown()
+
+	ctx := context.Background()
+	jetStream := NewJetStreamWithDuplicateWindow(url, "", "", time.Minute)
+	ctx, err = jetStream.JetStreamInit(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error initializing jetstream: %v", err)
+	}
+	defer jetStream.Close()
+
+	js := FromContext(ctx)
+	info, err := js.StreamInfo(StreamName)
+	if err != nil {
+		t.Fatalf("unexpected error getting stream info: %v", err)
+	}
+	if info.Config.Duplicates != time.Minute {
+		t.Errorf("stream duplicate window = %v, want %v", info.Config.Duplicates, time.Minute)
+	}
+
+	// the same document collected from two sources has the same digest
+	publishes := []struct {
+		msgID string
+		data  string
+	}{
+		{msgID: "digest-a", data: "document a from source 1"},
+		{msgID: "digest-a", data: "document a from source 2"},
+		{msgID: "digest-b", data: "document b"},
+	}
+	for _, p := range publishes {
+		if err := PublishWithMsgID(ctx, SubjectNameDocCollected, p.msgID, []byte(p.data)); err != nil {
+			t.Fatalf("unexpected error on publish: %v", err)
+		}
+	}
+
+	info, err = js.StreamInfo(StreamName)
+	if err != nil {
+		t.Fatalf("unexpected error getting stream info: %v", err)
+	}
+	if info.State.Msgs != 2 {
+		t.Errorf("stream has %d messages, want 2", info.State.Msgs)
+	}
+}
+
+func TestJetStreamInit_StreamConfig(t *testing.T) {
+	natsTest := nats_test.NewNatsTestServer()
+	url, err := natsTest.EnableJetStreamForTest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer natsTest.Shutdown()
+
+	ctx := context.Background()
+	config := DefaultStreamConfig()
+	config.Storage = nats.MemoryStorage
+	config.MaxBytes = 1 << 20
+	config.MaxAge = time.Hour
+	jetStream := NewJetStreamWithConfig(url, "", "", config)
+	ctx, err = jetStream.JetStreamInit(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error initializing jetstream: %v", err)
+	}
+	defer jetStream.Close()
+
+	js := FromContext(ctx)
+	info, err := js.StreamInfo(StreamName)
+	if err != nil {
+		t.Fatalf("unexpected error getting stream info: %v", err)
+	}
+	if info.Config.Storage != nats.MemoryStorage || info.Config.MaxBytes != 1<<20 || info.Config.MaxAge != time.Hour {
+		t.Errorf("stream storage %v, max bytes %d, max age %v, want memory, %d, %v",
+			info.Config.Storage, info.Config.MaxBytes, info.Config.MaxAge, 1<<20, time.Hour)
+	}
+
+	// the caps of an existing stream are updated in place
+	if err := createStreamOrExists(ctx, js, DefaultStreamConfig()); err != nil {
+		t.Fatalf("unexpected error updating stream: %v", err)
+	}
+	info, err = js.StreamInfo(StreamName)
+	if err != nil {
+		t.Fatalf("unexpected error getting stream info: %v", err)
+	}
+	if info.Config.MaxBytes != -1 || info.Config.MaxAge != 0 {
+		t.Errorf("stream max bytes %d, max age %v, want no caps", info.Config.MaxBytes, info.Config.MaxAge)
+	}
+
+	invalid := DefaultStreamConfig()
+	invalid.Replicas = 0
+	if _, err := NewJetStreamWithConfig(url, "", "", invalid).JetStreamInit(context.Background()); err == nil {
+		t.Errorf("expected error initializing jetstream with 0 replicas")
+	}
+}
+
+func TestParseStorageType(t *testing.T) {
+	for name, want := range map[string]nats.StorageType{"file": nats.FileStorage, "Memory": nats.MemoryStorage} {
+		if got, err := ParseStorageType(name); err != nil || got != want {
+			t.Errorf("ParseStorageType(%q) = %v, %v, want %v", name, got, err, want)
+		}
+	}
+	if _, err := ParseStorageType("disk"); err == nil {
+		t.Errorf("ParseStorageType() expected error for an unknown storage type")
+	}
+}
+
+func TestNATSAuth_validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		auth    NATSAuth
+		wantErr bool
+	}{{
+		name: "none",
+	}, {
+		name: "credentials file",
+		auth: NATSAuth{Creds: "user.creds"},
+	}, {
+		name: "user and password with mutual tls",
+		auth: NATSAuth{User: "guac", Password: "secret", TLSCertPath: "client.pem", TLSKeyPath: "client-key.pem", TLSCAPath: "ca.pem"},
+	}, {
+		name:    "credentials file and nkey",
+		auth:    NATSAuth{Creds: "user.creds", NKeyFile: "user.nk"},
+		wantErr: true,
+	}, {
+		name:    "password without user",
+		auth:    NATSAuth{Password: "secret"},
+		wantErr: true,
+	}, {
+		name:    "certificate without key",
+		auth:    NATSAuth{TLSCertPath: "client.pem"},
+		wantErr: true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.auth.validate(); (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNatsEmitter_UserInfo(t *testing.T) {
+	natsTest := nats_test.NewNatsTestServer()
+	url, err := natsTest.EnableJetStreamWithUserForTest("guac", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer natsTest.Shutdown()
+
+	tests := []struct {
+		name    string
+		auth    NATSAuth
+		wantErr bool
+	}{{
+		name: "user and password",
+		auth: NATSAuth{User: "guac", Password: "secret"},
+	}, {
+		name:    "wrong password",
+		auth:    NATSAuth{User: "guac", Password: "guess"},
+		wantErr: true,
+	}, {
+		name:    "anonymous",
+		wantErr: true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jetStream := NewJetStreamWithAuth(url, tt.auth, DefaultStreamConfig())
+			_, err := jetStream.JetStreamInit(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Errorf("JetStreamInit() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil {
+				jetStream.Close()
+			}
+		})
+	}
+}
+
+func TestNatsEmitter_Redelivery(t *testing.T) {
+	natsTest := nats_test.NewNatsTestServer()
+	url, err := natsTest.EnableJetStreamForTest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer natsTest.Shutdown()
+
+	ctx := context.Background()
+	config := DefaultStreamConfig()
+	config.AckWait = 500 * time.Millisecond
+	jetStream := NewJetStreamWithConfig(url, "", "", config)
+	ctx, err = jetStream.JetStreamInit(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error initializing jetstream: %v", err)
+	}
+	defer jetStream.Close()
+	if err := Publish(ctx, SubjectNameDocProcessed, []byte("document")); err != nil {
+		t.Fatalf("unexpected error on publish: %v", err)
+	}
+
+	// the ingestor fails on the document and stops
+	failed := errors.New("ingestor crashed")
+	crashCtx, crash := context.WithCancel(ctx)
+	psub, err := NewPubSub(crashCtx, uuid.NewV4().String(), SubjectNameDocProcessed, DurableIngestor, BackOffTimer)
+	if err != nil {
+		t.Fatalf("unexpected error on subscribe: %v", err)
+	}
+	if err := psub.GetDataFromNats(crashCtx, func([]byte) error { return failed }); !errors.Is(err, failed) {
+		t.Fatalf("GetDataFromNats() error = %v, want %v", err, failed)
+	}
+	crash()
+
+	// the restarted ingestor gets it again
+	subCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+	psub, err = NewPubSub(subCtx, uuid.NewV4().String(), SubjectNameDocProcessed, DurableIngestor, BackOffTimer)
+	if err != nil {
+		t.Fatalf("unexpected error on subscribe: %v", err)
+	}
+	var got []string
+	err = psub.GetDataFromNats(subCtx, func(d []byte) error {
+		got = append(got, string(d))
+		cancel()
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("GetDataFromNats() error = %v, want context.Canceled", err)
+	}
+	if len(got) != 1 || got[0] != "document" {
+		t.Errorf("GetDataFromNats() got %v, want the document delivered again", got)
+	}
+
+	info, err := FromContext(ctx).ConsumerInfo(StreamName, DurableIngestor)
+	if err != nil {
+		t.Fatalf("unexpected error getting consumer info: %v", err)
+	}
+	if info.Config.AckPolicy != nats.AckExplicitPolicy || info.Config.AckWait != config.AckWait || info.Config.MaxDeliver != DefaultMaxDeliver {
+		t.Errorf("consumer ack policy %v, ack wait %v, max deliver %d, want explicit, %v, %d",
+			info.Config.AckPolicy, info.Config.AckWait, info.Config.MaxDeliver, config.AckWait, DefaultMaxDeliver)
+	}
+
+	// the redelivery of an existing consumer is updated in place
+	config.MaxDeliver = 2
+	updated := NewJetStreamWithConfig(url, "", "", config)
+	updatedCtx, err := updated.JetStreamInit(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error initializing jetstream: %v", err)
+	}
+	defer updated.Close()
+	if err := updated.ensureConsumer(updatedCtx, SubjectNameDocProcessed, DurableIngestor); err != nil {
+		t.Fatalf("ensureConsumer() error = %v", err)
+	}
+	info, err = FromContext(updatedCtx).ConsumerInfo(StreamName, DurableIngestor)
+	if err != nil {
+		t.Fatalf("unexpected error getting consumer info: %v", err)
+	}
+	if info.Config.MaxDeliver != 2 {
+		t.Errorf("consumer max deliver %d, want 2", info.Config.MaxDeliver)
+	}
+}
+
+func TestNatsEmitter_FlowControl(t *testing.T) {
+	natsTest := nats_test.NewNatsTestServer()
+	url, err := natsTest.EnableJetStreamForTest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer natsTest.Shutdown()
+
+	config := DefaultStreamConfig()
+	config.MaxInFlight = 2
+	jetStream := NewJetStreamWithConfig(url, "", "", config)
+	ctx, err := jetStream.JetStreamInit(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error initializing jetstream: %v", err)
+	}
+	defer jetStream.Close()
+	for _, data := range []string{"a", "b", "c"} {
+		if err := Publish(ctx, SubjectNameDocCollected, []byte(data)); err != nil {
+			t.Fatalf("unexpected error on publish: %v", err)
+		}
+	}
+	pending, err := PendingMessages(ctx)
+	if err != nil {
+		t.Fatalf("PendingMessages() error = %v", err)
+	}
+	if pending[SubjectNameDocCollected] != 3 || pending[SubjectNameDocProcessed] != 0 {
+		t.Errorf("PendingMessages() = %v, want 3 collected", pending)
+	}
+
+	if err := jetStream.ensureConsumer(ctx, SubjectNameDocCollected, DurableProcessor); err != nil {
+		t.Fatalf("ensureConsumer() error = %v", err)
+	}
+	info, err := FromContext(ctx).ConsumerInfo(StreamName, DurableProcessor)
+	if err != nil {
+		t.Fatalf("unexpected error getting consumer info: %v", err)
+	}
+	if info.Config.MaxAckPending != 2 {
+		t.Errorf("consumer max ack pending %d, want 2", info.Config.MaxAckPending)
+	}
+}
+
+func TestNatsEmitter_SharedDurable(t *testing.T) {
+	natsTest := nats_test.NewNatsTestServer()
+	url, err := natsTest.EnableJetStreamForTest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer natsTest.Shutdown()
+
+	ctx := context.Background()
+	jetStream := NewJetStream(url, "", "")
+	ctx, err = jetStream.JetStreamInit(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error initializing jetstream: %v", err)
+	}
+	defer jetStream.Close()
+
+	if _, err := NewPubSub(ctx, uuid.NewV4().String(), SubjectNameDocProcessed, "ingestor.replica", BackOffTimer); err == nil {
+		t.Errorf("NewPubSub() expected error for a durable name with a '.'")
+	}
+
+	const numMessages = 20
+	for i := 0; i < numMessages; i++ {
+		if err := Publish(ctx, SubjectNameDocProcessed, []byte(fmt.Sprintf("document %d", i))); err != nil {
+			t.Fatalf("unexpected error on publish: %v", err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	// two replicas of the ingestor
+	var mu sync.Mutex
+	received := map[string]int{}
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		psub, err := NewPubSub(ctx, uuid.NewV4().String(), SubjectNameDocProcessed, "ingestor-replicas", BackOffTimer)
+		if err != nil {
+			t.Fatalf("unexpected error subscribing: %v", err)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = psub.GetDataFromNats(ctx, func(d []byte) error {
+				mu.Lock()
+				defer mu.Unlock()
+				received[string(d)]++
+				return nil
+			})
+		}()
+	}
+	wg.Wait()
+
+	if len(received) != numMessages {
+		t.Errorf("received %d distinct messages, want %d", len(received), numMessages)
+	}
+	for d, n := range received {
+		if n != 1 {
+			t.Errorf("message %q delivered %d times, want 1", d, n)
+		}
+	}
+
+	js := FromContext(ctx)
+	if _, err := js.ConsumerInfo(StreamName, "ingestor-replicas"); err != nil {
+		t.Errorf("durable consumer not found: %v", err)
+	}
+	if _, err := js.ConsumerInfo(StreamName, DurableIngestor); !errors.Is(err, nats.ErrConsumerNotFound) {
+		t.Errorf("default durable consumer should not be created, got %v", err)
+	}
+}
@@ -0,0 +1,151 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package emitter
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OrderingKeyHeader is the message header carrying the ordering key of a
+// document, see WithOrderingKey
+const OrderingKeyHeader = "Guac-Ordering-Key"
+
+// partitionedSubjects are the subjects split into partitions, see
+// SetPartitions
+var partitionedSubjects = []string{SubjectNameDocCollected, SubjectNameDocProcessed}
+
+var (
+	partitionsLock sync.RWMutex
+	partitions     = 1
+)
+
+// SetPartitions sets the number of partitions of the collected and processed
+// subjects, 1 for none. A document is published on the partition of its
+// ordering key, e.g. DOCUMENTS.collected.3, and the subscribers consume each
+// partition with a durable consumer of its own, e.g. processor-3, which hands
+// out one document at a time. The documents with the same ordering key are
+// so handled in the order they were published, while the partitions are
+// shared by the replicas of the processor and ingestor. All the processes of
+// a pipeline must use the same number of partitions.
+func SetPartitions(n int) error {
+	if n < 1 {
+		return fmt.Errorf("partitions must be at least 1, got %d", n)
+	}
+	partitionsLock.Lock()
+	defer partitionsLock.Unlock()
+	partitions = n
+	return nil
+}
+
+// GetPartitions returns the number of partitions of the collected and
+// processed subjects
+func GetPartitions() int {
+	partitionsLock.RLock()
+	defer partitionsLock.RUnlock()
+	return partitions
+}
+
+type orderingKeyKey struct{}
+
+// WithOrderingKey returns ctx in which Publish and PublishWithMsgID publish
+// with key as the ordering key, e.g. the source of the document, see
+// SetPartitions. The subscribers pass the ordering key of each message on to
+// their dataFunc in ctx, so that the documents published while handling it
+// keep its key. Documents without an ordering key are spread over the
+// partitions by message ID.
+func WithOrderingKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, orderingKeyKey{}, key)
+}
+
+func orderingKeyFromContext(ctx context.Context) string {
+	key, _ := ctx.Value(orderingKeyKey{}).(string)
+	return key
+}
+
+// isPartitioned reports whether subj is split into partitions
+func isPartitioned(subj string) bool {
+	for _, s := range partitionedSubjects {
+		if subj == s {
+			return true
+		}
+	}
+	return false
+}
+
+// isPartitionSubject reports whether subj is a partition of a partitioned
+// subject, whose consumer hands out one message at a time
+func isPartitionSubject(subj string) bool {
+	return baseSubject(subj) != subj
+}
+
+// baseSubject returns the subject subj is a partition of, subj if it is not
+// a partition
+func baseSubject(subj string) string {
+	for _, s := range partitionedSubjects {
+		if strings.HasPrefix(subj, s+".") {
+			return s
+		}
+	}
+	return subj
+}
+
+// partitionSubject returns the partition of subj the document with key is
+// published on, subj if it is not partitioned
+func partitionSubject(subj string, key string, partitions int) string {
+	if partitions <= 1 || !isPartitioned(subj) {
+		return subj
+	}
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	return fmt.Sprintf("%s.%d", subj, h.Sum32()%uint32(partitions))
+}
+
+// subscribePartitions subscribes to every partition of subj with b, with the
+// durable consumer of each partition, and merges their messages
+func subscribePartitions(ctx context.Context, b Broker, id string, subj string, durable string, backOffTimer time.Duration, partitions int) (<-chan *Message, <-chan error, error) {
+	dataChan := make(chan *Message)
+	errChan := make(chan error, partitions)
+	for p := 0; p < partitions; p++ {
+		partitionData, partitionErr, err := b.Subscribe(ctx, id, fmt.Sprintf("%s.%d", subj, p), fmt.Sprintf("%s-%d", durable, p), backOffTimer)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to subscribe to partition %d of %s: %w", p, subj, err)
+		}
+		go func() {
+			for {
+				select {
+				case m := <-partitionData:
+					select {
+					case dataChan <- m:
+					case <-ctx.Done():
+						// not handed, so delivered again
+						if m.Nak != nil {
+							_ = m.Nak()
+						}
+					}
+				case err := <-partitionErr:
+					errChan <- err
+					return
+				}
+			}
+		}()
+	}
+	return dataChan, errChan, nil
+}
@@ -0,0 +1,161 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package emitter
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSetPartitions(t *testing.T) {
+	defer func() {
+		_ = SetPartitions(1)
+	}()
+	if err := SetPartitions(0); err == nil {
+		t.Errorf("SetPartitions() expected error for 0 partitions")
+	}
+	if err := SetPartitions(4); err != nil {
+		t.Fatal(err)
+	}
+	if got := GetPartitions(); got != 4 {
+		t.Errorf("GetPartitions() = %d, want 4", got)
+	}
+}
+
+func TestPartitionSubject(t *testing.T) {
+	tests := []struct {
+		name       string
+		subj       string
+		partitions int
+		want       string
+	}{{
+		name:       "not partitioned",
+		subj:       SubjectNameDocCollected,
+		partitions: 1,
+		want:       SubjectNameDocCollected,
+	}, {
+		name:       "subject not partitioned",
+		subj:       SubjectNameDocRetry,
+		partitions: 4,
+		want:       SubjectNameDocRetry,
+	}, {
+		name:       "partitioned",
+		subj:       SubjectNameDocProcessed,
+		partitions: 4,
+		want:       SubjectNameDocProcessed + ".",
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := partitionSubject(tt.subj, "file:///sbom.json", tt.partitions)
+			if !strings.HasPrefix(got, tt.want) {
+				t.Errorf("partitionSubject() = %v, want %v", got, tt.want)
+			}
+			if again := partitionSubject(tt.subj, "file:///sbom.json", tt.partitions); again != got {
+				t.Errorf("partitionSubject() = %v then %v, want the same partition for the same key", got, again)
+			}
+			if base := baseSubject(got); base != tt.subj {
+				t.Errorf("baseSubject(%v) = %v, want %v", got, base, tt.subj)
+			}
+		})
+	}
+}
+
+func TestPubSub_Partitions(t *testing.T) {
+	if err := SetPartitions(4); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = SetPartitions(1)
+	}()
+	ctx, err := NewMemoryBroker().Init(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error initializing the memory broker: %v", err)
+	}
+	sources := []string{"a", "b", "c", "d", "e", "f"}
+	const versions = 5
+	for v := 0; v < versions; v++ {
+		for _, source := range sources {
+			if err := Publish(WithOrderingKey(ctx, source), SubjectNameDocCollected, []byte(fmt.Sprintf("%s %d", source, v))); err != nil {
+				t.Fatalf("unexpected error on publish: %v", err)
+			}
+		}
+	}
+	pending, err := PendingMessages(ctx)
+	if err != nil {
+		t.Fatalf("PendingMessages() error = %v", err)
+	}
+	if want := uint64(len(sources) * versions); pending[SubjectNameDocCollected] != want {
+		t.Errorf("PendingMessages() = %v, want %d collected", pending, want)
+	}
+
+	// replicas sharing the partitions
+	var mu sync.Mutex
+	got := map[string][]int{}
+	done := make(chan struct{})
+	subCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	for r := 0; r < 3; r++ {
+		psub, err := NewPubSub(subCtx, fmt.Sprintf("processor-%d", r), SubjectNameDocCollected, DurableProcessor, BackOffTimer)
+		if err != nil {
+			t.Fatalf("unexpected error on subscribe: %v", err)
+		}
+		go func() {
+			_ = psub.GetDataFromNatsWithContext(subCtx, func(ctx context.Context, d []byte) error {
+				var source string
+				var v int
+				if _, err := fmt.Sscan(string(d), &source, &v); err != nil {
+					return err
+				}
+				if key := orderingKeyFromContext(ctx); key != source {
+					t.Errorf("ordering key = %q, want %q", key, source)
+				}
+				// give the other replicas the chance to take a later version
+				time.Sleep(time.Millisecond)
+				mu.Lock()
+				defer mu.Unlock()
+				got[source] = append(got[source], v)
+				if len(got) == len(sources) {
+					for _, vs := range got {
+						if len(vs) < versions {
+							return nil
+						}
+					}
+					close(done)
+				}
+				return nil
+			})
+		}()
+	}
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for the documents")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	for source, vs := range got {
+		for i, v := range vs {
+			if v != i {
+				t.Errorf("versions of %s handled in order %v, want them in the order published", source, vs)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,160 @@
+//
+// Copyright 2022 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package emitter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// drainBatchSize is the number of messages fetched at once by Drain
+const drainBatchSize = 100
+
+// drainWait is how long Drain waits for more messages before deciding the
+// subject is drained
+const drainWait = time.Second
+
+// QueuedMessage is a message waiting on a subject of the stream
+type QueuedMessage struct {
+	// Seq is the sequence of the message in the stream
+	Seq  uint64
+	Data []byte
+}
+
+// QueuedMessages returns the messages waiting on subj, oldest first, without
+// consuming them. The messages are looked up one stream sequence at a time,
+// so this is meant for inspecting small queues such as the retry and
+// dead-letter subjects.
+func QueuedMessages(ctx context.Context, subj string) ([]QueuedMessage, error) {
+	js := FromContext(ctx)
+	if js == nil {
+		return nil, errors.New("jetstream not found from context")
+	}
+	info, err := js.StreamInfo(StreamName, &nats.StreamInfoRequest{SubjectsFilter: subj}, nats.Context(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stream info: %w", err)
+	}
+	queued := info.State.Subjects[subj]
+	messages := []QueuedMessage{}
+	for seq := info.State.FirstSeq; seq <= info.State.LastSeq && uint64(len(messages)) < queued; seq++ {
+		m, err := getQueuedMessage(ctx, js, subj, seq)
+		if err != nil {
+			return nil, err
+		}
+		if m != nil {
+			messages = append(messages, *m)
+		}
+	}
+	return messages, nil
+}
+
+// GetQueuedMessage returns the message with sequence seq waiting on subj,
+// without consuming it
+func GetQueuedMessage(ctx context.Context, subj string, seq uint64) (*QueuedMessage, error) {
+	js := FromContext(ctx)
+	if js == nil {
+		return nil, errors.New("jetstream not found from context")
+	}
+	m, err := getQueuedMessage(ctx, js, subj, seq)
+	if err != nil {
+		return nil, err
+	}
+	if m == nil {
+		return nil, fmt.Errorf("no message %d on %s", seq, subj)
+	}
+	return m, nil
+}
+
+// getQueuedMessage returns the message with sequence seq, nil if it was
+// consumed already or is not on subj
+func getQueuedMessage(ctx context.Context, js nats.JetStreamContext, subj string, seq uint64) (*QueuedMessage, error) {
+	raw, err := js.GetMsg(StreamName, seq, nats.Context(ctx))
+	if errors.Is(err, nats.ErrMsgNotFound) {
+		// consumed already
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get message %d: %w", seq, err)
+	}
+	if raw.Subject != subj {
+		return nil, nil
+	}
+	data, err := messageDocument(ctx, fromNatsMsg(&nats.Msg{Subject: raw.Subject, Header: raw.Header, Data: raw.Data}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read message %d: %w", seq, err)
+	}
+	return &QueuedMessage{Seq: seq, Data: data}, nil
+}
+
+// DeleteQueuedMessage removes the message with sequence seq from the stream
+func DeleteQueuedMessage(ctx context.Context, seq uint64) error {
+	js := FromContext(ctx)
+	if js == nil {
+		return errors.New("jetstream not found from context")
+	}
+	if err := js.DeleteMsg(StreamName, seq, nats.Context(ctx)); err != nil {
+		return fmt.Errorf("failed to delete message %d: %w", seq, err)
+	}
+	return nil
+}
+
+// Drain consumes the messages waiting on subj with the durable consumer,
+// passing the data of each to dataFunc, until no message is left. A message
+// is acked once dataFunc returns, and left on the subject if it fails, in
+// which case Drain returns the error. It returns the number of messages
+// drained.
+func Drain(ctx context.Context, subj string, durable string, dataFunc DataFunc) (int, error) {
+	js := FromContext(ctx)
+	if js == nil {
+		return 0, errors.New("jetstream not found from context")
+	}
+	sub, err := js.PullSubscribe(subj, durable)
+	if err != nil {
+		return 0, fmt.Errorf("%s subscribe failed: %w", durable, err)
+	}
+	defer func() {
+		_ = sub.Unsubscribe()
+	}()
+
+	drained := 0
+	for {
+		msgs, err := sub.Fetch(drainBatchSize, nats.MaxWait(drainWait))
+		if errors.Is(err, nats.ErrTimeout) {
+			return drained, nil
+		}
+		if err != nil {
+			return drained, fmt.Errorf("[%s] unexpected NATS fetch error: %w", durable, err)
+		}
+		for _, msg := range msgs {
+			data, err := messageDocument(ctx, fromNatsMsg(msg))
+			if err == nil {
+				err = dataFunc(data)
+			}
+			if err != nil {
+				_ = msg.Nak()
+				return drained, err
+			}
+			if err := msg.Ack(nats.Context(ctx)); err != nil {
+				return drained, fmt.Errorf("[%s] unable to Ack: %w", durable, err)
+			}
+			drained++
+		}
+	}
+}
@@ -0,0 +1,258 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graphql
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	gql "github.com/graphql-go/graphql"
+	"github.com/guacsec/guac/pkg/assembler"
+)
+
+// nodeType is a GraphQL object for the nodes with a label
+type nodeType struct {
+	label string
+	// fields are the GraphQL fields and the string properties they return
+	fields map[string]string
+	// listFields are the GraphQL fields and the list properties they return
+	listFields map[string]string
+	edges      []edgeField
+}
+
+// edgeField is a GraphQL field returning the nodes linked by an edge
+type edgeField struct {
+	name        string
+	edge        string
+	dir         assembler.Direction
+	label       string
+	description string
+}
+
+var nodeTypes = []nodeType{{
+	label:      "Package",
+	fields:     map[string]string{"purl": "purl", "name": "name", "version": "version"},
+	listFields: map[string]string{"digests": "digest", "cpes": "cpes", "tags": "tags"},
+	edges: []edgeField{
+		{"artifacts", "PackageOf", assembler.DirectionIn, "Artifact", "artifacts of the package"},
+		{"contains", "Contains", assembler.DirectionOut, "Artifact", "artifacts contained in the package"},
+		{"dependencies", "DependsOn", assembler.DirectionOut, "Package", "packages the package depends on"},
+		{"dependents", "DependsOn", assembler.DirectionIn, "Package", "packages depending on the package"},
+		{"vulnerabilities", "Affects", assembler.DirectionIn, "Vulnerability", "vulnerabilities affecting the package"},
+		{"attestations", "Attestation", assembler.DirectionIn, "Attestation", "attestations about the package"},
+	},
+}, {
+	label:      "Artifact",
+	fields:     map[string]string{"digest": "digest", "name": "name"},
+	listFields: map[string]string{"tags": "tags"},
+	edges: []edgeField{
+		{"packages", "PackageOf", assembler.DirectionOut, "Package", "packages of the artifact"},
+		{"containedIn", "Contains", assembler.DirectionIn, "Package", "packages containing the artifact"},
+		{"dependencies", "DependsOn", assembler.DirectionOut, "Artifact", "artifacts the artifact depends on"},
+		{"dependents", "DependsOn", assembler.DirectionIn, "Artifact", "artifacts depending on the artifact"},
+		{"builtBy", "BuiltBy", assembler.DirectionOut, "Builder", "builders of the artifact"},
+		{"vulnerabilities", "Affects", assembler.DirectionIn, "Vulnerability", "vulnerabilities affecting the artifact"},
+		{"attestations", "Attestation", assembler.DirectionIn, "Attestation", "attestations about the artifact"},
+	},
+}, {
+	label: "Attestation",
+	fields: map[string]string{"digest": "digest", "predicateType": "attestation_type",
+		"filePath": "filepath", "statementTime": "statement_time"},
+	edges: []edgeField{
+		{"subjectArtifacts", "Attestation", assembler.DirectionOut, "Artifact", "artifacts the attestation is about"},
+		{"subjectPackages", "Attestation", assembler.DirectionOut, "Package", "packages the attestation is about"},
+		{"signers", "Identity", assembler.DirectionIn, "Identity", "identities that signed the attestation"},
+		{"builtBy", "BuiltBy", assembler.DirectionOut, "Builder", "builders of the build the attestation describes"},
+		{"vulnerabilities", "Vulnerable", assembler.DirectionOut, "Vulnerability", "vulnerabilities found by the attestation"},
+	},
+}, {
+	label:      "Vulnerability",
+	fields:     map[string]string{"id": "id"},
+	listFields: map[string]string{"aliases": "aliases"},
+	edges: []edgeField{
+		{"packages", "Affects", assembler.DirectionOut, "Package", "packages affected by the vulnerability"},
+		{"artifacts", "Affects", assembler.DirectionOut, "Artifact", "artifacts affected by the vulnerability"},
+		{"attestations", "Vulnerable", assembler.DirectionIn, "Attestation", "attestations that found the vulnerability"},
+	},
+}, {
+	label:  "Builder",
+	fields: map[string]string{"id": "id"},
+	edges: []edgeField{
+		{"artifacts", "BuiltBy", assembler.DirectionIn, "Artifact", "artifacts built by the builder"},
+		{"attestations", "BuiltBy", assembler.DirectionIn, "Attestation", "attestations of the builds that ran on the builder"},
+	},
+}, {
+	label: "Identity",
+	fields: map[string]string{"keyId": "id", "digest": "digest", "keyType": "keyType", "keyScheme": "keyScheme",
+		"issuer": "issuer", "subjectAlternativeName": "subjectAlternativeName"},
+	edges: []edgeField{
+		{"attestations", "Identity", assembler.DirectionOut, "Attestation", "attestations signed by the identity"},
+	},
+}}
+
+// queryField is a field of the query root listing the nodes of a label
+type queryField struct {
+	name  string
+	label string
+	// filters are the arguments of the field, by NodeFilter field
+	filters []string
+}
+
+var queryFields = []queryField{
+	{"packages", "Package", []string{"purl", "digest"}},
+	{"artifacts", "Artifact", []string{"digest"}},
+	{"attestations", "Attestation", []string{"digest", "predicateType"}},
+	{"vulnerabilities", "Vulnerability", []string{"id"}},
+	{"builders", "Builder", []string{"id"}},
+	{"identities", "Identity", []string{"digest"}},
+}
+
+// NewSchema returns the GraphQL schema of the graph read by reader
+func NewSchema(reader assembler.GraphReader) (gql.Schema, error) {
+	objects := map[string]*gql.Object{}
+	for _, t := range nodeTypes {
+		t := t
+		objects[t.label] = gql.NewObject(gql.ObjectConfig{
+			Name:        t.label,
+			Description: fmt.Sprintf("a %s node of the graph", t.label),
+			// edge fields refer to the other objects
+			Fields: gql.FieldsThunk(func() gql.Fields {
+				return nodeFields(reader, t, objects)
+			}),
+		})
+	}
+
+	root := gql.Fields{}
+	for _, q := range queryFields {
+		q := q
+		args := gql.FieldConfigArgument{
+			"limit": &gql.ArgumentConfig{Type: gql.Int, Description: "maximum number of nodes returned"},
+		}
+		for _, f := range q.filters {
+			args[f] = &gql.ArgumentConfig{Type: gql.String}
+		}
+		root[q.name] = &gql.Field{
+			Type:        gql.NewList(objects[q.label]),
+			Args:        args,
+			Description: fmt.Sprintf("%s nodes, filtered by %v", q.label, q.filters),
+			Resolve: func(p gql.ResolveParams) (interface{}, error) {
+				filter := assembler.NodeFilter{Label: q.label}
+				filter.Purl, _ = p.Args["purl"].(string)
+				filter.Digest, _ = p.Args["digest"].(string)
+				filter.PredicateType, _ = p.Args["predicateType"].(string)
+				filter.ID, _ = p.Args["id"].(string)
+				filter.Limit, _ = p.Args["limit"].(int)
+				return reader.FindNodes(filter)
+			},
+		}
+	}
+	return gql.NewSchema(gql.SchemaConfig{
+		Query: gql.NewObject(gql.ObjectConfig{Name: "Query", Fields: root}),
+	})
+}
+
+func nodeFields(reader assembler.GraphReader, t nodeType, objects map[string]*gql.Object) gql.Fields {
+	fields := gql.Fields{
+		"nodeId": &gql.Field{
+			Type:        gql.NewNonNull(gql.ID),
+			Description: "database id of the node",
+			Resolve: func(p gql.ResolveParams) (interface{}, error) {
+				return strconv.FormatInt(p.Source.(assembler.StoredNode).ID, 10), nil
+			},
+		},
+		"properties": &gql.Field{
+			Type:        gql.String,
+			Description: "JSON object of all the properties of the node",
+			Resolve: func(p gql.ResolveParams) (interface{}, error) {
+				b, err := json.Marshal(p.Source.(assembler.StoredNode).Properties)
+				return string(b), err
+			},
+		},
+	}
+	for name, property := range t.fields {
+		property := property
+		fields[name] = &gql.Field{
+			Type: gql.String,
+			Resolve: func(p gql.ResolveParams) (interface{}, error) {
+				return stringProperty(p.Source.(assembler.StoredNode).Properties[property]), nil
+			},
+		}
+	}
+	for name, property := range t.listFields {
+		property := property
+		fields[name] = &gql.Field{
+			Type: gql.NewList(gql.String),
+			Resolve: func(p gql.ResolveParams) (interface{}, error) {
+				return listProperty(p.Source.(assembler.StoredNode).Properties[property]), nil
+			},
+		}
+	}
+	for _, e := range t.edges {
+		e := e
+		fields[e.name] = &gql.Field{
+			Type:        gql.NewList(objects[e.label]),
+			Description: e.description,
+			Args: gql.FieldConfigArgument{
+				"limit": &gql.ArgumentConfig{Type: gql.Int, Description: "maximum number of nodes returned"},
+			},
+			Resolve: func(p gql.ResolveParams) (interface{}, error) {
+				limit, _ := p.Args["limit"].(int)
+				neighbors, err := reader.Neighbors(p.Source.(assembler.StoredNode).ID, e.edge, e.dir, e.label, limit)
+				if err != nil {
+					return nil, err
+				}
+				nodes := make([]assembler.StoredNode, 0, len(neighbors))
+				for _, n := range neighbors {
+					nodes = append(nodes, n.Node)
+				}
+				return nodes, nil
+			},
+		}
+	}
+	return fields
+}
+
+// stringProperty returns the property value v as a string, nil if unset
+func stringProperty(v interface{}) interface{} {
+	switch value := v.(type) {
+	case nil:
+		return nil
+	case string:
+		return value
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+// listProperty returns the list property value v as strings, a single value
+// being a list of one
+func listProperty(v interface{}) []string {
+	switch value := v.(type) {
+	case nil:
+		return nil
+	case []string:
+		return value
+	case []interface{}:
+		list := make([]string, 0, len(value))
+		for _, e := range value {
+			list = append(list, fmt.Sprint(e))
+		}
+		return list
+	default:
+		return []string{fmt.Sprint(value)}
+	}
+}
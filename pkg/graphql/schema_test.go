@@ -0,0 +1,153 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/guacsec/guac/pkg/assembler"
+)
+
+type fakeEdge struct {
+	label    string
+	from, to int64
+}
+
+// fakeReader is a graph of a package, its artifact, an attestation signed by
+// an identity and a vulnerability affecting the package
+type fakeReader struct {
+	nodes   []assembler.StoredNode
+	edges   []fakeEdge
+	filters []assembler.NodeFilter
+}
+
+func newFakeReader() *fakeReader {
+	return &fakeReader{
+		nodes: []assembler.StoredNode{
+			{ID: 1, Label: "Package", Properties: map[string]interface{}{"purl": "pkg:golang/foo@v1", "digest": []interface{}{"sha256:ab"}}},
+			{ID: 2, Label: "Artifact", Properties: map[string]interface{}{"digest": "sha256:ab", "name": "foo"}},
+			{ID: 3, Label: "Attestation", Properties: map[string]interface{}{"digest": "sha256:cd", "attestation_type": "https://slsa.dev/provenance/v0.2"}},
+			{ID: 4, Label: "Identity", Properties: map[string]interface{}{"id": "release", "digest": "sha256:ef"}},
+			{ID: 5, Label: "Vulnerability", Properties: map[string]interface{}{"id": "GHSA-1234", "aliases": []interface{}{"CVE-2023-1"}}},
+		},
+		edges: []fakeEdge{
+			{"PackageOf", 2, 1},
+			{"Attestation", 3, 2},
+			{"Identity", 4, 3},
+			{"Affects", 5, 1},
+		},
+	}
+}
+
+func (f *fakeReader) FindNodes(filter assembler.NodeFilter) ([]assembler.StoredNode, error) {
+	f.filters = append(f.filters, filter)
+	nodes := []assembler.StoredNode{}
+	for _, n := range f.nodes {
+		if n.Label == filter.Label && (filter.Purl == "" || n.Properties["purl"] == filter.Purl) {
+			nodes = append(nodes, n)
+		}
+	}
+	return nodes, nil
+}
+
+func (f *fakeReader) Neighbors(id int64, edge string, dir assembler.Direction, label string, limit int) ([]assembler.Neighbor, error) {
+	neighbors := []assembler.Neighbor{}
+	for _, e := range f.edges {
+		other := int64(0)
+		switch {
+		case dir == assembler.DirectionOut && e.from == id:
+			other = e.to
+		case dir == assembler.DirectionIn && e.to == id:
+			other = e.from
+		}
+		if other == 0 || e.label != edge {
+			continue
+		}
+		for _, n := range f.nodes {
+			if n.ID == other && n.Label == label {
+				neighbors = append(neighbors, assembler.Neighbor{Edge: e.label, Node: n})
+			}
+		}
+	}
+	return neighbors, nil
+}
+
+func (f *fakeReader) Paths(from int64, to int64, edges []string, depth int, limit int) ([]assembler.Path, error) {
+	return nil, nil
+}
+
+func (f *fakeReader) RootPaths(id int64, edges []string, depth int, limit int) ([]assembler.Path, error) {
+	return nil, nil
+}
+
+func TestSchema(t *testing.T) {
+	reader := newFakeReader()
+	schema, err := NewSchema(reader)
+	if err != nil {
+		t.Fatalf("NewSchema() error = %v", err)
+	}
+	handler := Handler(context.Background(), schema)
+
+	query := `query($purl: String) {
+  packages(purl: $purl, limit: 10) {
+    purl
+    digests
+    vulnerabilities { id aliases }
+    artifacts {
+      digest
+      attestations { predicateType signers { keyId } }
+    }
+  }
+}`
+	body, _ := json.Marshal(map[string]interface{}{"query": query, "variables": map[string]interface{}{"purl": "pkg:golang/foo@v1"}})
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/query", strings.NewReader(string(body))))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("POST status = %d: %s", rec.Code, rec.Body)
+	}
+	want := `{"data":{"packages":[{"artifacts":[{"attestations":[{"predicateType":"https://slsa.dev/provenance/v0.2","signers":[{"keyId":"release"}]}],"digest":"sha256:ab"}],"digests":["sha256:ab"],"purl":"pkg:golang/foo@v1","vulnerabilities":[{"aliases":["CVE-2023-1"],"id":"GHSA-1234"}]}]}}`
+	if got := strings.TrimSpace(rec.Body.String()); got != want {
+		t.Errorf("POST response = %s, want %s", got, want)
+	}
+	if got := reader.filters[0]; got.Label != "Package" || got.Purl != "pkg:golang/foo@v1" || got.Limit != 10 {
+		t.Errorf("FindNodes() filter = %+v", got)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/query?query="+url.QueryEscape(`{ vulnerabilities { id packages { purl } } }`), nil))
+	want = `{"data":{"vulnerabilities":[{"id":"GHSA-1234","packages":[{"purl":"pkg:golang/foo@v1"}]}]}}`
+	if got := strings.TrimSpace(rec.Body.String()); got != want {
+		t.Errorf("GET response = %s, want %s", got, want)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/query?query="+url.QueryEscape(`{ packages { unknown } }`), nil))
+	if !strings.Contains(rec.Body.String(), `Cannot query field \"unknown\"`) {
+		t.Errorf("invalid query response = %s, want an error", rec.Body)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/query", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("DELETE status = %d, want %d", rec.Code, http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,127 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package artifactrepo
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+const (
+	CollectorArtifactory = "ARTIFACTORY"
+	// artifactoryTokenEnv is the env variable to hold an access token of
+	// Artifactory, used instead of the user and password if set
+	artifactoryTokenEnv    = "ARTIFACTORY_TOKEN"
+	artifactoryUserEnv     = "ARTIFACTORY_USER"
+	artifactoryPasswordEnv = "ARTIFACTORY_PASSWORD"
+)
+
+type artifactory struct {
+	r *repoCollector
+}
+
+// NewArtifactoryCollector returns a collector for the files of the JFrog
+// Artifactory instance at baseURL, e.g. https://acme.jfrog.io/artifactory,
+// under repoPath, given as repo or repo/folder. The files whose name matches
+// one of patterns, or DefaultPatterns if there are none, are collected.
+// Patterns use the path.Match syntax, e.g. `*.spdx.json`. Artifactory is
+// authenticated with ARTIFACTORY_TOKEN, or ARTIFACTORY_USER and
+// ARTIFACTORY_PASSWORD, if set. With a non zero pollInterval, the repository
+// is polled and only new or changed files are collected.
+func NewArtifactoryCollector(ctx context.Context, baseURL string, repoPath string, patterns []string, pollInterval time.Duration, opts ...Option) (*repoCollector, error) {
+	r, err := newRepoCollector(CollectorArtifactory, baseURL, repoPath, patterns, pollInterval, opts)
+	if err != nil {
+		return nil, err
+	}
+	if token := os.Getenv(artifactoryTokenEnv); token != "" {
+		r.authorize = func(req *http.Request) {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+	} else if user := os.Getenv(artifactoryUserEnv); user != "" {
+		password := os.Getenv(artifactoryPasswordEnv)
+		r.authorize = func(req *http.Request) {
+			req.SetBasicAuth(user, password)
+		}
+	}
+	r.backend = &artifactory{r: r}
+	return r, nil
+}
+
+// artifactoryFileList is the response of the file list API
+type artifactoryFileList struct {
+	Files []struct {
+		// URI is the path of the file relative to the listed folder
+		URI    string `json:"uri"`
+		Folder bool   `json:"folder"`
+		SHA2   string `json:"sha2"`
+	} `json:"files"`
+}
+
+func (a *artifactory) list(ctx context.Context) ([]file, error) {
+	var list artifactoryFileList
+	u := a.r.baseURL.String() + "/api/storage/" + escapePath(path.Join(a.r.repo, a.r.path)) + "?list&deep=1&listFolders=0"
+	if err := a.r.getJSON(ctx, u, &list); err != nil {
+		return nil, err
+	}
+	files := []file{}
+	for _, f := range list.Files {
+		if f.Folder {
+			continue
+		}
+		filePath := strings.TrimPrefix(path.Join(a.r.path, f.URI), "/")
+		files = append(files, file{
+			path:        filePath,
+			downloadURL: a.r.baseURL.String() + "/" + escapePath(path.Join(a.r.repo, filePath)),
+			sha256:      f.SHA2,
+		})
+	}
+	return files, nil
+}
+
+// artifactoryProperties is the response of the item properties API
+type artifactoryProperties struct {
+	Properties map[string][]string `json:"properties"`
+}
+
+func (a *artifactory) properties(ctx context.Context, f file, names []string) (map[string][]string, error) {
+	var props artifactoryProperties
+	u := a.r.baseURL.String() + "/api/storage/" + escapePath(path.Join(a.r.repo, f.path)) + "?properties=" + url.QueryEscape(strings.Join(names, ","))
+	err := a.r.getJSON(ctx, u, &props)
+	var statusErr *statusError
+	if errors.As(err, &statusErr) && statusErr.code == http.StatusNotFound {
+		// the file has none of the properties
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return props.Properties, nil
+}
+
+// escapePath escapes the segments of p for use in a URL path
+func escapePath(p string) string {
+	segments := strings.Split(p, "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	return strings.Join(segments, "/")
+}
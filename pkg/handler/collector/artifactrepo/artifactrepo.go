@@ -0,0 +1,315 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package artifactrepo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/guacsec/guac/pkg/handler/collector/retry"
+	"github.com/guacsec/guac/pkg/handler/processor"
+	"github.com/guacsec/guac/pkg/logging"
+)
+
+// DefaultPatterns match the sidecar files commonly holding the SBOMs and
+// attestations of the artifacts stored next to them
+var DefaultPatterns = []string{
+	"*.spdx",
+	"*.spdx.json",
+	"*.cdx.json",
+	"*.cdx.xml",
+	"*.bom.json",
+	"*sbom*.json",
+	"*.intoto.jsonl",
+}
+
+// file is a file stored in a repository
+type file struct {
+	// path of the file in the repository, without leading slash
+	path string
+	// downloadURL is the absolute URL the file is downloaded from
+	downloadURL string
+	// sha256 of the file content, if known, to collect it again once changed
+	sha256 string
+}
+
+// backend lists and reads the files of a repository manager
+type backend interface {
+	// list returns the files under the path of the collector
+	list(ctx context.Context) ([]file, error)
+	// properties returns the values of the named properties of f
+	properties(ctx context.Context, f file, names []string) (map[string][]string, error)
+}
+
+type repoCollector struct {
+	collectorType string
+	backend       backend
+	client        *http.Client
+	baseURL       *url.URL
+	// repo is the repository to collect and path the folder in it
+	repo string
+	path string
+	// patterns of the names of the sidecar files to collect
+	patterns []string
+	// propertyNames are the properties holding documents
+	propertyNames []string
+	// authorize sets the credentials of a request to the repository manager
+	authorize func(*http.Request)
+	// collected are the keys of the documents already collected, with their
+	// digest
+	collected map[string]string
+	poll      bool
+	interval  time.Duration
+}
+
+// Option configures the collectors returned by NewArtifactoryCollector and
+// NewNexusCollector
+type Option func(*repoCollector)
+
+// WithProperties also collects the documents held by the named properties of
+// every file, e.g. an SBOM attached to a jar by the build. A property value
+// that is an http(s) URL is downloaded, any other value is the document.
+// Only Artifactory supports properties.
+func WithProperties(names ...string) Option {
+	return func(r *repoCollector) {
+		r.propertyNames = append(r.propertyNames, names...)
+	}
+}
+
+// WithHTTPClient sets the client used to call the repository manager
+func WithHTTPClient(client *http.Client) Option {
+	return func(r *repoCollector) {
+		r.client = client
+	}
+}
+
+func newRepoCollector(collectorType string, baseURL string, repoPath string, patterns []string, pollInterval time.Duration, opts []Option) (*repoCollector, error) {
+	u, err := url.Parse(strings.TrimSuffix(baseURL, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid repository manager url %q: %w", baseURL, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return nil, fmt.Errorf("invalid repository manager url %q, expected an http(s) url", baseURL)
+	}
+	repo, folder, _ := strings.Cut(strings.Trim(repoPath, "/"), "/")
+	if repo == "" {
+		return nil, errors.New("repository not specified")
+	}
+	if len(patterns) == 0 {
+		patterns = DefaultPatterns
+	}
+	for _, p := range patterns {
+		if _, err := path.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("invalid file pattern %q: %w", p, err)
+		}
+	}
+	if pollInterval < 0 {
+		return nil, errors.New("poll interval must not be negative")
+	}
+	r := &repoCollector{
+		collectorType: collectorType,
+		client:        http.DefaultClient,
+		baseURL:       u,
+		repo:          repo,
+		path:          folder,
+		patterns:      patterns,
+		authorize:     func(*http.Request) {},
+		poll:          pollInterval > 0,
+		interval:      pollInterval,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r, nil
+}
+
+// Type is the collector type of the collector
+func (r *repoCollector) Type() string {
+	return r.collectorType
+}
+
+// RetrieveArtifacts get the artifacts from the collector source based on polling or one time
+func (r *repoCollector) RetrieveArtifacts(ctx context.Context, docChannel chan<- *processor.Document) error {
+	if r.backend == nil {
+		return errors.New("repository collector not initialized")
+	}
+	if err := r.getArtifacts(ctx, docChannel); err != nil {
+		return err
+	}
+	if !r.poll {
+		return nil
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(r.interval):
+		}
+		if err := r.getArtifacts(ctx, docChannel); err != nil {
+			return err
+		}
+	}
+}
+
+func (r *repoCollector) getArtifacts(ctx context.Context, docChannel chan<- *processor.Document) error {
+	logger := logging.FromContext(ctx)
+	if r.collected == nil {
+		r.collected = map[string]string{}
+	}
+	files, err := r.backend.list(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list %s: %w", r.source(""), err)
+	}
+	for _, f := range files {
+		if r.matches(f.path) {
+			r.collect(ctx, f.path, f.sha256, f.downloadURL, nil, docChannel)
+		}
+		if len(r.propertyNames) == 0 {
+			continue
+		}
+		props, err := r.backend.properties(ctx, f, r.propertyNames)
+		if err != nil {
+			logger.Warnf("failed to get the properties of %s: %v", r.source(f.path), err)
+			continue
+		}
+		for _, name := range r.propertyNames {
+			for i, value := range props[name] {
+				key := fmt.Sprintf("%s#%s[%d]", f.path, name, i)
+				if strings.HasPrefix(value, "http://") || strings.HasPrefix(value, "https://") {
+					r.collect(ctx, key, value, value, nil, docChannel)
+				} else {
+					r.collect(ctx, key, value, "", []byte(value), docChannel)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// collect sends the document of key unless it was already collected with the
+// same digest. The document is downloaded from downloadURL unless its blob is
+// given.
+func (r *repoCollector) collect(ctx context.Context, key string, digest string, downloadURL string, blob []byte, docChannel chan<- *processor.Document) {
+	logger := logging.FromContext(ctx)
+	if seen, ok := r.collected[key]; ok && (digest == "" || seen == digest) {
+		return
+	}
+	source := r.source(key)
+	if blob == nil {
+		var err error
+		blob, err = retry.ReadDocument(ctx, source, func() ([]byte, error) {
+			return r.download(ctx, downloadURL)
+		})
+		if err != nil {
+			logger.Warnf("failed to download %s: %v", source, err)
+			return
+		}
+	}
+	r.collected[key] = digest
+	if len(blob) == 0 {
+		return
+	}
+	uri := downloadURL
+	if uri == "" {
+		uri = r.baseURL.String() + "/" + r.repo + "/" + key
+	}
+	docChannel <- &processor.Document{
+		Blob:   blob,
+		Type:   processor.DocumentUnknown,
+		Format: processor.FormatUnknown,
+		SourceInformation: processor.SourceInformation{
+			Collector: r.collectorType,
+			Source:    source,
+			URI:       uri,
+		},
+	}
+}
+
+// source names the file of the repository at filePath
+func (r *repoCollector) source(filePath string) string {
+	return strings.TrimSuffix(r.repo+"/"+filePath, "/")
+}
+
+// matches reports whether the name of the file matches one of the patterns
+func (r *repoCollector) matches(filePath string) bool {
+	name := path.Base(filePath)
+	for _, p := range r.patterns {
+		if ok, _ := path.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *repoCollector) download(ctx context.Context, downloadURL string) ([]byte, error) {
+	resp, err := r.get(ctx, downloadURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		err := fmt.Errorf("unexpected status %s", resp.Status)
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, retry.Permanent(err)
+		}
+		return nil, err
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// get requests rawURL, with the credentials of the repository manager only if
+// it is served by it
+func (r *repoCollector) get(ctx context.Context, rawURL string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if req.URL.Scheme == r.baseURL.Scheme && req.URL.Host == r.baseURL.Host {
+		r.authorize(req)
+	}
+	return r.client.Do(req)
+}
+
+// getJSON decodes the JSON response to the request of rawURL into v
+func (r *repoCollector) getJSON(ctx context.Context, rawURL string, v interface{}) error {
+	resp, err := r.get(ctx, rawURL)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return &statusError{code: resp.StatusCode, status: resp.Status}
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// statusError is returned for an unexpected status of the repository manager
+type statusError struct {
+	code   int
+	status string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("unexpected status %s", e.status)
+}
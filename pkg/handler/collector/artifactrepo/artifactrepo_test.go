@@ -0,0 +1,207 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package artifactrepo
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/guacsec/guac/pkg/handler/processor"
+)
+
+// collect runs the collector once and returns the collected documents sorted
+// by source
+func collect(t *testing.T, r *repoCollector) []*processor.Document {
+	t.Helper()
+	docChan := make(chan *processor.Document, 10)
+	if err := r.RetrieveArtifacts(context.Background(), docChan); err != nil {
+		t.Fatalf("RetrieveArtifacts() error = %v", err)
+	}
+	close(docChan)
+	docs := []*processor.Document{}
+	for d := range docChan {
+		docs = append(docs, d)
+	}
+	sort.Slice(docs, func(i, j int) bool {
+		return docs[i].SourceInformation.Source < docs[j].SourceInformation.Source
+	})
+	return docs
+}
+
+func doc(collector, source, uri, blob string) *processor.Document {
+	return &processor.Document{
+		Blob:   []byte(blob),
+		Type:   processor.DocumentUnknown,
+		Format: processor.FormatUnknown,
+		SourceInformation: processor.SourceInformation{
+			Collector: collector,
+			Source:    source,
+			URI:       uri,
+		},
+	}
+}
+
+func TestArtifactoryCollector(t *testing.T) {
+	t.Setenv(artifactoryTokenEnv, "secret")
+	sbomDigest := "1111"
+	mux := http.NewServeMux()
+	mux.HandleFunc("/artifactory/api/storage/libs-release/com/acme/app", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprintf(w, `{"files":[
+			{"uri":"/1.0/app-1.0.jar","folder":false,"sha2":"aaaa"},
+			{"uri":"/1.0/app-1.0.spdx.json","folder":false,"sha2":%q},
+			{"uri":"/1.0/app-1.0.pom","folder":false,"sha2":"bbbb"}]}`, sbomDigest)
+	})
+	mux.HandleFunc("/artifactory/api/storage/libs-release/com/acme/app/1.0/app-1.0.jar", func(w http.ResponseWriter, r *http.Request) {
+		if got := r.URL.Query().Get("properties"); got != "sbom,provenance" {
+			t.Errorf("properties = %q", got)
+		}
+		fmt.Fprint(w, `{"properties":{"sbom":["{\"spdxVersion\":\"SPDX-2.3\"}"]}}`)
+	})
+	mux.HandleFunc("/artifactory/api/storage/", http.NotFound)
+	mux.HandleFunc("/artifactory/libs-release/com/acme/app/1.0/app-1.0.spdx.json", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "sbom "+sbomDigest)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	r, err := NewArtifactoryCollector(context.Background(), server.URL+"/artifactory/", "libs-release/com/acme/app", nil, 0, WithProperties("sbom", "provenance"))
+	if err != nil {
+		t.Fatalf("NewArtifactoryCollector() error = %v", err)
+	}
+	if r.Type() != CollectorArtifactory {
+		t.Errorf("Type() = %s, want %s", r.Type(), CollectorArtifactory)
+	}
+	want := []*processor.Document{
+		doc(CollectorArtifactory, "libs-release/com/acme/app/1.0/app-1.0.jar#sbom[0]",
+			server.URL+"/artifactory/libs-release/com/acme/app/1.0/app-1.0.jar#sbom[0]", `{"spdxVersion":"SPDX-2.3"}`),
+		doc(CollectorArtifactory, "libs-release/com/acme/app/1.0/app-1.0.spdx.json",
+			server.URL+"/artifactory/libs-release/com/acme/app/1.0/app-1.0.spdx.json", "sbom 1111"),
+	}
+	if got := collect(t, r); !reflect.DeepEqual(got, want) {
+		t.Errorf("first collection = %v, want %v", got, want)
+	}
+
+	// only the changed sidecar file is collected again
+	sbomDigest = "2222"
+	want = []*processor.Document{
+		doc(CollectorArtifactory, "libs-release/com/acme/app/1.0/app-1.0.spdx.json",
+			server.URL+"/artifactory/libs-release/com/acme/app/1.0/app-1.0.spdx.json", "sbom 2222"),
+	}
+	if got := collect(t, r); !reflect.DeepEqual(got, want) {
+		t.Errorf("second collection = %v, want %v", got, want)
+	}
+}
+
+func TestNexusCollector(t *testing.T) {
+	t.Setenv(nexusUserEnv, "guac")
+	t.Setenv(nexusPasswordEnv, "pass")
+	// the documents stored elsewhere must not get the credentials
+	external := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, _, ok := r.BasicAuth(); ok {
+			t.Errorf("credentials sent to %s", r.URL)
+		}
+		fmt.Fprint(w, "external")
+	}))
+	defer external.Close()
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "guac" || pass != "pass" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case r.URL.Path == nexusAssetsAPIURL && r.URL.Query().Get("repository") == "releases":
+			if r.URL.Query().Get("continuationToken") == "" {
+				fmt.Fprintf(w, `{"items":[
+					{"path":"/com/acme/app/1.0/app-1.0.cdx.json","downloadUrl":"%[1]s/repository/releases/com/acme/app/1.0/app-1.0.cdx.json","checksum":{"sha256":"aaaa"}},
+					{"path":"/com/other/lib/2.0/lib-2.0.cdx.json","downloadUrl":"%[1]s/repository/releases/com/other/lib/2.0/lib-2.0.cdx.json","checksum":{"sha256":"bbbb"}}],
+					"continuationToken":"next"}`, server.URL)
+				return
+			}
+			fmt.Fprintf(w, `{"items":[
+				{"path":"/com/acme/app/1.0/app-1.0.jar","downloadUrl":"%[1]s/repository/releases/com/acme/app/1.0/app-1.0.jar","checksum":{"sha256":"cccc"}},
+				{"path":"/com/acme/app/1.0/app-1.0.intoto.jsonl","downloadUrl":"%[2]s/app-1.0.intoto.jsonl","checksum":{"sha256":"dddd"}}],
+				"continuationToken":null}`, server.URL, external.URL)
+		case r.URL.Path == "/repository/releases/com/acme/app/1.0/app-1.0.cdx.json":
+			fmt.Fprint(w, "bom")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	r, err := NewNexusCollector(context.Background(), server.URL, "releases/com/acme", nil, 0)
+	if err != nil {
+		t.Fatalf("NewNexusCollector() error = %v", err)
+	}
+	want := []*processor.Document{
+		doc(CollectorNexus, "releases/com/acme/app/1.0/app-1.0.cdx.json",
+			server.URL+"/repository/releases/com/acme/app/1.0/app-1.0.cdx.json", "bom"),
+		doc(CollectorNexus, "releases/com/acme/app/1.0/app-1.0.intoto.jsonl",
+			external.URL+"/app-1.0.intoto.jsonl", "external"),
+	}
+	if got := collect(t, r); !reflect.DeepEqual(got, want) {
+		t.Errorf("RetrieveArtifacts() = %v, want %v", got, want)
+	}
+}
+
+func TestNewCollectorErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		new  func() (*repoCollector, error)
+	}{{
+		name: "no repository",
+		new: func() (*repoCollector, error) {
+			return NewArtifactoryCollector(context.Background(), "https://acme.jfrog.io/artifactory", "/", nil, 0)
+		},
+	}, {
+		name: "invalid url",
+		new: func() (*repoCollector, error) {
+			return NewNexusCollector(context.Background(), "nexus.acme.com", "releases", nil, 0)
+		},
+	}, {
+		name: "invalid pattern",
+		new: func() (*repoCollector, error) {
+			return NewNexusCollector(context.Background(), "https://nexus.acme.com", "releases", []string{"[*.json"}, 0)
+		},
+	}, {
+		name: "negative poll interval",
+		new: func() (*repoCollector, error) {
+			return NewArtifactoryCollector(context.Background(), "https://acme.jfrog.io/artifactory", "libs", nil, -1)
+		},
+	}, {
+		name: "nexus properties",
+		new: func() (*repoCollector, error) {
+			return NewNexusCollector(context.Background(), "https://nexus.acme.com", "releases", nil, 0, WithProperties("sbom"))
+		},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.new(); err == nil {
+				t.Errorf("expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package artifactrepo
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	CollectorNexus    = "NEXUS"
+	nexusUserEnv      = "NEXUS_USER"
+	nexusPasswordEnv  = "NEXUS_PASSWORD"
+	nexusAssetsAPIURL = "/service/rest/v1/assets"
+)
+
+type nexus struct {
+	r *repoCollector
+}
+
+// NewNexusCollector returns a collector for the files of the Sonatype Nexus
+// Repository instance at baseURL, e.g. https://nexus.acme.com, under
+// repoPath, given as repo or repo/folder. The files whose name matches one of
+// patterns, or DefaultPatterns if there are none, are collected. Patterns use
+// the path.Match syntax, e.g. `*.spdx.json`. Nexus is authenticated with
+// NEXUS_USER and NEXUS_PASSWORD if set. With a non zero pollInterval, the
+// repository is polled and only new or changed files are collected.
+func NewNexusCollector(ctx context.Context, baseURL string, repoPath string, patterns []string, pollInterval time.Duration, opts ...Option) (*repoCollector, error) {
+	r, err := newRepoCollector(CollectorNexus, baseURL, repoPath, patterns, pollInterval, opts)
+	if err != nil {
+		return nil, err
+	}
+	if len(r.propertyNames) > 0 {
+		return nil, errors.New("nexus does not support properties")
+	}
+	if user := os.Getenv(nexusUserEnv); user != "" {
+		password := os.Getenv(nexusPasswordEnv)
+		r.authorize = func(req *http.Request) {
+			req.SetBasicAuth(user, password)
+		}
+	}
+	r.backend = &nexus{r: r}
+	return r, nil
+}
+
+// nexusAssets is a page of the response of the assets API
+type nexusAssets struct {
+	Items []struct {
+		DownloadURL string `json:"downloadUrl"`
+		Path        string `json:"path"`
+		Checksum    struct {
+			SHA256 string `json:"sha256"`
+		} `json:"checksum"`
+	} `json:"items"`
+	ContinuationToken string `json:"continuationToken"`
+}
+
+func (n *nexus) list(ctx context.Context) ([]file, error) {
+	files := []file{}
+	query := url.Values{"repository": {n.r.repo}}
+	for {
+		var page nexusAssets
+		if err := n.r.getJSON(ctx, n.r.baseURL.String()+nexusAssetsAPIURL+"?"+query.Encode(), &page); err != nil {
+			return nil, err
+		}
+		for _, item := range page.Items {
+			filePath := strings.TrimPrefix(item.Path, "/")
+			// the assets API lists the whole repository
+			if n.r.path != "" && !strings.HasPrefix(filePath, n.r.path+"/") {
+				continue
+			}
+			files = append(files, file{
+				path:        filePath,
+				downloadURL: item.DownloadURL,
+				sha256:      item.Checksum.SHA256,
+			})
+		}
+		if page.ContinuationToken == "" {
+			return files, nil
+		}
+		query.Set("continuationToken", page.ContinuationToken)
+	}
+}
+
+func (n *nexus) properties(ctx context.Context, f file, names []string) (map[string][]string, error) {
+	return nil, errors.New("nexus does not support properties")
+}
@@ -0,0 +1,235 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package azblob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	azstorage "github.com/Azure/azure-storage-blob-go/azblob"
+
+	"github.com/guacsec/guac/pkg/handler/collector/retry"
+	"github.com/guacsec/guac/pkg/handler/processor"
+	"github.com/guacsec/guac/pkg/handler/processor/hashcache"
+	"github.com/guacsec/guac/pkg/logging"
+)
+
+const (
+	CollectorAzureBlob = "AZURE_BLOB"
+	// storageAccountEnv is the env variable to hold the storage account of
+	// containers given by name
+	storageAccountEnv = "AZURE_STORAGE_ACCOUNT"
+)
+
+type azureBlob struct {
+	container string
+	reader    blobReader
+	// etags of the blobs already collected, by blob name
+	etags map[string]string
+	// digests of the content already collected, so that a blob reappearing
+	// under a new etag, e.g. once undeleted, is not collected again
+	digests  map[string]bool
+	poll     bool
+	interval time.Duration
+}
+
+// blobItem is a blob listed in the container
+type blobItem struct {
+	name string
+	etag string
+}
+
+type blobReader interface {
+	listBlobs(ctx context.Context) ([]blobItem, error)
+	getReader(ctx context.Context, name string) (io.ReadCloser, error)
+}
+
+type reader struct {
+	containerURL azstorage.ContainerURL
+	// prefix of the blobs to list
+	prefix string
+}
+
+// NewAzureBlobCollector returns a collector for the blobs of container whose
+// name starts with prefix. The container is either its URL, e.g.
+// https://account.blob.core.windows.net/container, or its name in the
+// storage account set by AZURE_STORAGE_ACCOUNT. Containers given by a URL
+// with a SAS token are read with it, others authenticate with the Azure AD
+// credentials of the environment or of the managed identity. With a non zero
+// pollInterval, the container is polled and only blobs that are new or have
+// a new etag since the last poll are collected.
+func NewAzureBlobCollector(ctx context.Context, container string, prefix string, pollInterval time.Duration) (*azureBlob, error) {
+	containerURL, err := parseContainerURL(container)
+	if err != nil {
+		return nil, err
+	}
+	var credential azstorage.Credential
+	if containerURL.RawQuery != "" {
+		// the SAS token in the query authorizes the requests
+		credential = azstorage.NewAnonymousCredential()
+	} else {
+		credential, err = newTokenCredential(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get azure credentials: %w", err)
+		}
+	}
+	pipeline := azstorage.NewPipeline(credential, azstorage.PipelineOptions{})
+	return &azureBlob{
+		container: containerURL.Host + containerURL.Path,
+		reader: &reader{
+			containerURL: azstorage.NewContainerURL(*containerURL, pipeline),
+			prefix:       prefix,
+		},
+		poll:     pollInterval > 0,
+		interval: pollInterval,
+	}, nil
+}
+
+// parseContainerURL returns the URL of the container given by URL or by name
+func parseContainerURL(container string) (*url.URL, error) {
+	if container == "" {
+		return nil, errors.New("azure blob container not specified")
+	}
+	if strings.Contains(container, "://") {
+		u, err := url.Parse(container)
+		if err != nil {
+			return nil, fmt.Errorf("invalid azure blob container url: %w", err)
+		}
+		return u, nil
+	}
+	account := os.Getenv(storageAccountEnv)
+	if account == "" {
+		return nil, fmt.Errorf("%s must be set for container %s given by name", storageAccountEnv, container)
+	}
+	return url.Parse(fmt.Sprintf("https://%s.blob.core.windows.net/%s", account, container))
+}
+
+// Type is the collector type of the collector
+func (a *azureBlob) Type() string {
+	return CollectorAzureBlob
+}
+
+func (r *reader) listBlobs(ctx context.Context) ([]blobItem, error) {
+	items := []blobItem{}
+	for marker := (azstorage.Marker{}); marker.NotDone(); {
+		resp, err := r.containerURL.ListBlobsFlatSegment(ctx, marker, azstorage.ListBlobsSegmentOptions{Prefix: r.prefix})
+		if err != nil {
+			return nil, err
+		}
+		for _, b := range resp.Segment.BlobItems {
+			items = append(items, blobItem{name: b.Name, etag: string(b.Properties.Etag)})
+		}
+		marker = resp.NextMarker
+	}
+	return items, nil
+}
+
+func (r *reader) getReader(ctx context.Context, name string) (io.ReadCloser, error) {
+	resp, err := r.containerURL.NewBlobURL(name).Download(ctx, 0, azstorage.CountToEnd, azstorage.BlobAccessConditions{}, false, azstorage.ClientProvidedKeyOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body(azstorage.RetryReaderOptions{MaxRetryRequests: 3}), nil
+}
+
+// RetrieveArtifacts get the artifacts from the collector source based on polling or one time
+func (a *azureBlob) RetrieveArtifacts(ctx context.Context, docChannel chan<- *processor.Document) error {
+	if a.reader == nil {
+		return errors.New("azure blob collector not initialized")
+	}
+	if err := a.getArtifacts(ctx, docChannel); err != nil {
+		return err
+	}
+	if !a.poll {
+		return nil
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(a.interval):
+		}
+		if err := a.getArtifacts(ctx, docChannel); err != nil {
+			return err
+		}
+	}
+}
+
+func (a *azureBlob) getArtifacts(ctx context.Context, docChannel chan<- *processor.Document) error {
+	logger := logging.FromContext(ctx)
+	if a.etags == nil {
+		a.etags = map[string]string{}
+		a.digests = map[string]bool{}
+	}
+	blobs, err := a.reader.listBlobs(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list blobs of container: %s, error: %w", a.container, err)
+	}
+	for _, b := range blobs {
+		if etag, ok := a.etags[b.name]; ok && etag == b.etag {
+			continue
+		}
+		uri := "https://" + a.container + "/" + b.name
+		payload, err := retry.ReadDocument(ctx, uri, func() ([]byte, error) {
+			payload, err := a.getBlob(ctx, b.name)
+			var storageErr azstorage.StorageError
+			if errors.As(err, &storageErr) && storageErr.ServiceCode() == azstorage.ServiceCodeBlobNotFound {
+				return nil, retry.Permanent(err)
+			}
+			return payload, err
+		})
+		if err != nil {
+			logger.Warnf("failed to retrieve blob: %s from container: %s: %v", b.name, a.container, err)
+			continue
+		}
+		a.etags[b.name] = b.etag
+		if len(payload) == 0 {
+			continue
+		}
+		digest := hashcache.Digest(payload)
+		if a.digests[digest] {
+			logger.Debugf("skipping blob: %s from container: %s, its content was already collected", b.name, a.container)
+			continue
+		}
+		a.digests[digest] = true
+		docChannel <- &processor.Document{
+			Blob:   payload,
+			Type:   processor.DocumentUnknown,
+			Format: processor.FormatUnknown,
+			SourceInformation: processor.SourceInformation{
+				Collector: CollectorAzureBlob,
+				Source:    a.container + "/" + b.name,
+				URI:       uri,
+			},
+		}
+	}
+	return nil
+}
+
+func (a *azureBlob) getBlob(ctx context.Context, name string) ([]byte, error) {
+	reader, err := a.reader.getReader(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
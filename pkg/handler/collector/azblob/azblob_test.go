@@ -0,0 +1,181 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package azblob
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/guacsec/guac/pkg/handler/processor"
+)
+
+type fakeBlob struct {
+	etag    string
+	content string
+}
+
+type fakeReader struct {
+	blobs map[string]fakeBlob
+	// names of the blobs in listing order
+	names []string
+}
+
+func (f *fakeReader) listBlobs(ctx context.Context) ([]blobItem, error) {
+	items := []blobItem{}
+	for _, name := range f.names {
+		if b, ok := f.blobs[name]; ok {
+			items = append(items, blobItem{name: name, etag: b.etag})
+		}
+	}
+	return items, nil
+}
+
+func (f *fakeReader) getReader(ctx context.Context, name string) (io.ReadCloser, error) {
+	b, ok := f.blobs[name]
+	if !ok {
+		return nil, errors.New("blob not found")
+	}
+	return io.NopCloser(bytes.NewBufferString(b.content)), nil
+}
+
+func (f *fakeReader) put(name string, etag string, content string) {
+	for _, n := range f.names {
+		if n == name {
+			f.blobs[name] = fakeBlob{etag: etag, content: content}
+			return
+		}
+	}
+	f.names = append(f.names, name)
+	f.blobs[name] = fakeBlob{etag: etag, content: content}
+}
+
+func collect(t *testing.T, a *azureBlob) []string {
+	t.Helper()
+	docChannel := make(chan *processor.Document, 10)
+	if err := a.getArtifacts(context.Background(), docChannel); err != nil {
+		t.Fatalf("getArtifacts() error = %v", err)
+	}
+	close(docChannel)
+	sources := []string{}
+	for d := range docChannel {
+		sources = append(sources, d.SourceInformation.Source)
+	}
+	return sources
+}
+
+func TestAzureBlob_getArtifacts(t *testing.T) {
+	r := &fakeReader{blobs: map[string]fakeBlob{}}
+	a := &azureBlob{container: "account.blob.core.windows.net/sboms", reader: r}
+
+	r.put("a.json", "0x1", "a")
+	r.put("b.json", "0x2", "b")
+	r.put("empty.json", "0x3", "")
+	if got, want := collect(t, a), []string{"account.blob.core.windows.net/sboms/a.json", "account.blob.core.windows.net/sboms/b.json"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("first poll = %v, want %v", got, want)
+	}
+
+	// unchanged blobs are skipped
+	if got, want := collect(t, a), []string{}; !reflect.DeepEqual(got, want) {
+		t.Errorf("unchanged poll = %v, want %v", got, want)
+	}
+
+	// new etags with new content are collected again
+	r.put("a.json", "0x4", "a2")
+	r.put("c.json", "0x5", "c")
+	if got, want := collect(t, a), []string{"account.blob.core.windows.net/sboms/a.json", "account.blob.core.windows.net/sboms/c.json"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("changed poll = %v, want %v", got, want)
+	}
+
+	// a blob undeleted under a new etag, or copied, with content already
+	// collected is skipped
+	delete(r.blobs, "b.json")
+	_ = collect(t, a)
+	r.put("b.json", "0x6", "b")
+	r.put("copy.json", "0x7", "c")
+	if got, want := collect(t, a), []string{}; !reflect.DeepEqual(got, want) {
+		t.Errorf("undeleted poll = %v, want %v", got, want)
+	}
+}
+
+func TestAzureBlob_RetrieveArtifacts(t *testing.T) {
+	r := &fakeReader{blobs: map[string]fakeBlob{}}
+	r.put("sbom.json", "0x1", "inside the blob")
+	a := &azureBlob{container: "account.blob.core.windows.net/sboms", reader: r}
+
+	docChannel := make(chan *processor.Document, 1)
+	if err := a.RetrieveArtifacts(context.Background(), docChannel); err != nil {
+		t.Fatalf("RetrieveArtifacts() error = %v", err)
+	}
+	want := &processor.Document{
+		Blob:   []byte("inside the blob"),
+		Type:   processor.DocumentUnknown,
+		Format: processor.FormatUnknown,
+		SourceInformation: processor.SourceInformation{
+			Collector: CollectorAzureBlob,
+			Source:    "account.blob.core.windows.net/sboms/sbom.json",
+			URI:       "https://account.blob.core.windows.net/sboms/sbom.json",
+		},
+	}
+	if got := <-docChannel; !reflect.DeepEqual(got, want) {
+		t.Errorf("RetrieveArtifacts() = %v, want %v", got, want)
+	}
+
+	if err := (&azureBlob{}).RetrieveArtifacts(context.Background(), docChannel); err == nil {
+		t.Errorf("RetrieveArtifacts() without reader expected error")
+	}
+}
+
+func Test_parseContainerURL(t *testing.T) {
+	tests := []struct {
+		name      string
+		container string
+		account   string
+		want      string
+		wantErr   bool
+	}{{
+		name:      "url",
+		container: "https://account.blob.core.windows.net/sboms?sv=2021&sig=abc",
+		want:      "https://account.blob.core.windows.net/sboms?sv=2021&sig=abc",
+	}, {
+		name:      "name in account",
+		container: "sboms",
+		account:   "account",
+		want:      "https://account.blob.core.windows.net/sboms",
+	}, {
+		name:      "name without account",
+		container: "sboms",
+		wantErr:   true,
+	}, {
+		name:    "empty",
+		wantErr: true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(storageAccountEnv, tt.account)
+			got, err := parseContainerURL(tt.container)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseContainerURL() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && got.String() != tt.want {
+				t.Errorf("parseContainerURL() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
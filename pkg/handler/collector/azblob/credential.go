@@ -0,0 +1,199 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package azblob
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	azstorage "github.com/Azure/azure-storage-blob-go/azblob"
+
+	"github.com/guacsec/guac/pkg/logging"
+)
+
+const (
+	// storageScope is the scope of the tokens for Azure Storage
+	storageScope = "https://storage.azure.com/.default"
+	// storageResource is the resource of the managed identity tokens for
+	// Azure Storage
+	storageResource = "https://storage.azure.com/"
+
+	// env variables of the service principal authenticating with a secret
+	tenantIDEnv     = "AZURE_TENANT_ID"
+	clientIDEnv     = "AZURE_CLIENT_ID"
+	clientSecretEnv = "AZURE_CLIENT_SECRET"
+
+	// imdsTokenURL is the token endpoint of the instance metadata service
+	// of Azure VMs, also serving the managed identity of AKS pods
+	imdsTokenURL = "http://169.254.169.254/metadata/identity/oauth2/token"
+
+	// refreshMargin is how long before it expires a token is refreshed
+	refreshMargin = 5 * time.Minute
+	// retryRefresh is how long to wait to refresh a token again after
+	// failing to
+	retryRefresh = 30 * time.Second
+)
+
+// tokenClient is the client of the token endpoints, with a timeout so that
+// the instance metadata service is not waited for outside of Azure
+var tokenClient = &http.Client{Timeout: 10 * time.Second}
+
+// token is an access token and how long it is valid for
+type token struct {
+	accessToken string
+	expiresIn   time.Duration
+}
+
+// tokenSource gets a token for Azure Storage
+type tokenSource struct {
+	name     string
+	getToken func(ctx context.Context) (token, error)
+}
+
+// credentialChain returns the sources of the credential chain, in the order
+// they are tried: the service principal of the environment, then the managed
+// identity
+func credentialChain() []tokenSource {
+	sources := []tokenSource{}
+	tenantID, clientID, secret := os.Getenv(tenantIDEnv), os.Getenv(clientIDEnv), os.Getenv(clientSecretEnv)
+	if tenantID != "" && clientID != "" && secret != "" {
+		sources = append(sources, tokenSource{
+			name: "environment",
+			getToken: func(ctx context.Context) (token, error) {
+				return clientSecretToken(ctx, tenantID, clientID, secret)
+			},
+		})
+	}
+	// AZURE_CLIENT_ID selects the user assigned managed identity, if any
+	sources = append(sources, tokenSource{
+		name: "managed identity",
+		getToken: func(ctx context.Context) (token, error) {
+			return managedIdentityToken(ctx, clientID)
+		},
+	})
+	return sources
+}
+
+// newTokenCredential returns a credential with the token of the first source
+// of the credential chain that gets one, refreshed by the same source before
+// it expires
+func newTokenCredential(ctx context.Context) (azstorage.TokenCredential, error) {
+	logger := logging.FromContext(ctx)
+	var errs []string
+	for _, source := range credentialChain() {
+		t, err := source.getToken(ctx)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", source.name, err))
+			continue
+		}
+		getToken := source.getToken
+		refresh := func(credential azstorage.TokenCredential) time.Duration {
+			t, err := getToken(context.Background())
+			if err != nil {
+				logger.Warnf("failed to refresh azure token: %v", err)
+				return retryRefresh
+			}
+			credential.SetToken(t.accessToken)
+			return refreshIn(t)
+		}
+		return azstorage.NewTokenCredential(t.accessToken, refresh), nil
+	}
+	return nil, fmt.Errorf("no credential of the chain got a token: %s", strings.Join(errs, "; "))
+}
+
+// refreshIn returns how long to wait before refreshing t
+func refreshIn(t token) time.Duration {
+	if t.expiresIn <= 2*refreshMargin {
+		return t.expiresIn / 2
+	}
+	return t.expiresIn - refreshMargin
+}
+
+// clientSecretToken gets a token for the service principal with the client
+// credentials flow
+func clientSecretToken(ctx context.Context, tenantID string, clientID string, secret string) (token, error) {
+	form := url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {clientID},
+		"client_secret": {secret},
+		"scope":         {storageScope},
+	}
+	tokenURL := fmt.Sprintf("https://login.microsoftonline.com/%s/oauth2/v2.0/token", url.PathEscape(tenantID))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return token{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return requestToken(req)
+}
+
+// managedIdentityToken gets a token for the managed identity from the
+// instance metadata service, for the user assigned identity clientID if set
+func managedIdentityToken(ctx context.Context, clientID string) (token, error) {
+	query := url.Values{
+		"api-version": {"2018-02-01"},
+		"resource":    {storageResource},
+	}
+	if clientID != "" {
+		query.Set("client_id", clientID)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, imdsTokenURL+"?"+query.Encode(), nil)
+	if err != nil {
+		return token{}, err
+	}
+	req.Header.Set("Metadata", "true")
+	return requestToken(req)
+}
+
+// tokenResponse is the response of the token endpoints. expires_in is a
+// number for Azure AD, a string for the instance metadata service.
+type tokenResponse struct {
+	AccessToken      string          `json:"access_token"`
+	ExpiresIn        json.RawMessage `json:"expires_in"`
+	Error            string          `json:"error"`
+	ErrorDescription string          `json:"error_description"`
+}
+
+func requestToken(req *http.Request) (token, error) {
+	resp, err := tokenClient.Do(req)
+	if err != nil {
+		return token{}, err
+	}
+	defer resp.Body.Close()
+	var body tokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return token{}, fmt.Errorf("failed to decode token response with status %s: %w", resp.Status, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return token{}, fmt.Errorf("token request failed with status %s: %s %s", resp.Status, body.Error, body.ErrorDescription)
+	}
+	if body.AccessToken == "" {
+		return token{}, errors.New("token response has no access token")
+	}
+	seconds, err := strconv.Atoi(strings.Trim(string(body.ExpiresIn), `"`))
+	if err != nil {
+		return token{}, fmt.Errorf("invalid expires_in of token response: %w", err)
+	}
+	return token{accessToken: body.AccessToken, expiresIn: time.Duration(seconds) * time.Second}, nil
+}
@@ -0,0 +1,83 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package azblob
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func Test_requestToken(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		want    token
+		wantErr bool
+	}{{
+		name:   "azure ad",
+		status: http.StatusOK,
+		body:   `{"access_token":"abc","expires_in":3599,"token_type":"Bearer"}`,
+		want:   token{accessToken: "abc", expiresIn: 3599 * time.Second},
+	}, {
+		name:   "instance metadata service",
+		status: http.StatusOK,
+		body:   `{"access_token":"abc","expires_in":"86399","token_type":"Bearer"}`,
+		want:   token{accessToken: "abc", expiresIn: 86399 * time.Second},
+	}, {
+		name:    "error",
+		status:  http.StatusBadRequest,
+		body:    `{"error":"invalid_request","error_description":"Identity not found"}`,
+		wantErr: true,
+	}, {
+		name:    "no token",
+		status:  http.StatusOK,
+		body:    `{"expires_in":"86399"}`,
+		wantErr: true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+			req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, server.URL, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := requestToken(req)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("requestToken() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("requestToken() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_refreshIn(t *testing.T) {
+	if got := refreshIn(token{expiresIn: time.Hour}); got != 55*time.Minute {
+		t.Errorf("refreshIn(1h) = %v, want 55m", got)
+	}
+	if got := refreshIn(token{expiresIn: 4 * time.Minute}); got != 2*time.Minute {
+		t.Errorf("refreshIn(4m) = %v, want 2m", got)
+	}
+}
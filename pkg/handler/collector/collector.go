@@ -18,11 +18,19 @@ package collector
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/guacsec/guac/pkg/emitter"
+	"github.com/guacsec/guac/pkg/handler/collector/retry"
 	"github.com/guacsec/guac/pkg/handler/processor"
+	"github.com/guacsec/guac/pkg/handler/processor/hashcache"
 	"github.com/guacsec/guac/pkg/logging"
+	"github.com/guacsec/guac/pkg/metrics"
+	"github.com/guacsec/guac/pkg/tracing"
 )
 
 const (
@@ -50,67 +58,305 @@ type ErrHandler func(error) bool
 
 var (
 	documentCollectors = map[string]Collector{}
+	// collectorNames is the name of each registered collector type
+	collectorNames = map[string]string{}
+
+	emitIntervalMu sync.RWMutex
+	emitInterval   time.Duration
+
+	collectorNameMu sync.RWMutex
+	collectorName   string
+
+	documentsCollected = metrics.NewCounter("guac_collector_documents_collected_total",
+		"Documents collected, by collector name.", "collector")
+	documentReadErrors = metrics.NewCounter("guac_collector_read_errors_total",
+		"Documents the collectors failed to read after retrying.")
 )
 
+// RegisterDocumentCollector registers the collector under its type. It is
+// named as set by SetCollectorName, or else after its type.
 func RegisterDocumentCollector(c Collector, collectorType string) error {
+	return RegisterNamedDocumentCollector(c, collectorType, getCollectorName())
+}
+
+// RegisterNamedDocumentCollector registers the collector under its type,
+// with the name that labels the logs of the documents it collects and is
+// set as their SourceInformation.CollectorName. An empty name defaults to
+// DefaultCollectorName.
+func RegisterNamedDocumentCollector(c Collector, collectorType string, name string) error {
 	if _, ok := documentCollectors[collectorType]; ok {
 		return fmt.Errorf("the document collector is being overwritten: %s", collectorType)
 	}
+	if name == "" {
+		name = DefaultCollectorName(collectorType)
+	}
 	documentCollectors[collectorType] = c
+	collectorNames[collectorType] = name
+
+	return nil
+}
+
+// DefaultCollectorName returns the name of collectors of the given type
+// when none is set, the lower case type without `Collector`, e.g. `file`
+// for the FileCollector
+func DefaultCollectorName(collectorType string) string {
+	name := strings.TrimSuffix(strings.TrimPrefix(collectorType, "Collector"), "Collector")
+	if name == "" {
+		name = collectorType
+	}
+	return strings.ToLower(name)
+}
+
+// SetCollectorName sets the name of the collectors registered afterwards
+// with RegisterDocumentCollector, empty to name them after their type
+func SetCollectorName(name string) {
+	collectorNameMu.Lock()
+	defer collectorNameMu.Unlock()
+	collectorName = name
+}
+
+func getCollectorName() string {
+	collectorNameMu.RLock()
+	defer collectorNameMu.RUnlock()
+	return collectorName
+}
 
+// ErrCollectorTimeout is passed to the ErrHandler of CollectWithTimeout when
+// the collectors did not finish within the timeout
+var ErrCollectorTimeout = errors.New("collection timed out")
+
+// ErrDocumentRead is passed to the ErrHandler, wrapped, for each document that
+// a collector failed to read after retrying
+var ErrDocumentRead = retry.ErrDocumentRead
+
+// SetEmitInterval sets the minimum time between two documents emitted by
+// Collect, e.g. 10ms to emit at most 100 documents per second, 0 to emit
+// them as fast as they are collected. Collectors wait while documents are
+// paced, instead of filling up the downstream queue.
+func SetEmitInterval(interval time.Duration) error {
+	if interval < 0 {
+		return fmt.Errorf("emit interval must not be negative, got %v", interval)
+	}
+	emitIntervalMu.Lock()
+	defer emitIntervalMu.Unlock()
+	emitInterval = interval
 	return nil
 }
 
+func getEmitInterval() time.Duration {
+	emitIntervalMu.RLock()
+	defer emitIntervalMu.RUnlock()
+	return emitInterval
+}
+
 // Collect takes all the collectors and starts collecting artifacts
 // after Collect is called, no calls to RegisterDocumentCollector should happen.
 func Collect(ctx context.Context, emitter Emitter, handleErr ErrHandler) error {
+	return CollectWithTimeout(ctx, emitter, handleErr, 0)
+}
+
+// CollectWithTimeout is Collect aborting the collection after timeout, 0 for
+// no timeout. On timeout, the collectors are canceled and the documents they
+// already collected are emitted before handleErr is called with an error
+// wrapping ErrCollectorTimeout. Collectors that do not return on
+// cancellation (e.g. blocked on an unresponsive mount) are left behind.
+// Documents that collectors fail to read are passed to handleErr as errors
+// wrapping ErrDocumentRead, without stopping the collection.
+func CollectWithTimeout(ctx context.Context, emitter Emitter, handleErr ErrHandler, timeout time.Duration) error {
+	collectors := make([]namedCollector, 0, len(documentCollectors))
+	for collectorType, c := range documentCollectors {
+		name := collectorNames[collectorType]
+		if name == "" {
+			name = DefaultCollectorName(collectorType)
+		}
+		collectors = append(collectors, namedCollector{collector: c, name: name})
+	}
+	return collect(ctx, collectors, emitter, handleErr, timeout)
+}
+
+// CollectNamed is Collect with the collector c alone, named name, instead of
+// the registered collectors, so that several collectors of the same type can
+// be run, each on its own, e.g. by a daemon
+func CollectNamed(ctx context.Context, c Collector, name string, emitter Emitter, handleErr ErrHandler) error {
+	if name == "" {
+		name = DefaultCollectorName(c.Type())
+	}
+	return collect(ctx, []namedCollector{{collector: c, name: name}}, emitter, handleErr, 0)
+}
+
+// namedCollector is a collector with the name of the documents it collects
+type namedCollector struct {
+	collector Collector
+	name      string
+}
+
+// collect runs collectors, see CollectWithTimeout
+func collect(ctx context.Context, collectors []namedCollector, emitter Emitter, handleErr ErrHandler, timeout time.Duration) error {
 	// docChan to collect artifacts
 	docChan := make(chan *processor.Document, BufferChannelSize)
 	// errChan to receive error from collectors
-	errChan := make(chan error, len(documentCollectors))
+	errChan := make(chan error, len(collectors))
+	// readErrChan to receive the documents collectors failed to read
+	readErrChan := make(chan error, BufferChannelSize)
 	// logger
+	ctx = logging.WithComponent(ctx, logging.ComponentCollector)
 	logger := logging.FromContext(ctx)
+	emitter = withEmitInterval(ctx, withCollectedAt(emitter), getEmitInterval())
 
-	for _, collector := range documentCollectors {
-		c := collector
+	// timeoutChan is nil, and never ready, without a timeout
+	collectCtx := ctx
+	var timeoutChan <-chan struct{}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		collectCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+		timeoutChan = collectCtx.Done()
+	}
+	// expired reports whether collectCtx was canceled by the timeout rather
+	// than by ctx
+	expired := func() bool {
+		return errors.Is(collectCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil
+	}
+	collectDone := collectCtx.Done()
+	collectCtx = retry.WithReadErrorReporter(collectCtx, func(err error) {
+		select {
+		case readErrChan <- err:
+		case <-collectDone:
+		}
+	})
+
+	for _, nc := range collectors {
+		c, name := nc.collector, nc.name
 		go func() {
-			errChan <- c.RetrieveArtifacts(ctx, docChan)
+			errChan <- retrieveNamed(logging.WithCollectorName(collectCtx, name), c, name, docChan)
 		}()
 	}
 
-	numCollectors := len(documentCollectors)
+	emitBuffered := func() {
+		for len(docChan) > 0 {
+			d := <-docChan
+			if err := emitter(d); err != nil {
+				logger.Errorf("emit error: %v", err)
+			}
+		}
+		for len(readErrChan) > 0 {
+			documentReadErrors.Inc()
+			handleErr(<-readErrChan)
+		}
+	}
+
+	numCollectors := len(collectors)
 	collectorsDone := 0
-	for collectorsDone < numCollectors {
+	timedOut := false
+	for collectorsDone < numCollectors && !timedOut {
 		select {
 		case d := <-docChan:
 			if err := emitter(d); err != nil {
 				logger.Errorf("emit error: %v", err)
 			}
+		case err := <-readErrChan:
+			// a single document is not worth stopping the collection
+			handleErr(err)
 		case err := <-errChan:
+			collectorsDone += 1
+			// collectors stopped by the timeout are reported once below
+			if err != nil && expired() {
+				timedOut = true
+				continue
+			}
 			if !handleErr(err) {
 				return err
 			}
-			collectorsDone += 1
+		case <-timeoutChan:
+			timedOut = expired()
+			// ctx was canceled, wait for the collectors to return
+			timeoutChan = nil
 		}
 	}
-	for len(docChan) > 0 {
-		d := <-docChan
-		if err := emitter(d); err != nil {
-			logger.Errorf("emit error: %v", err)
+	emitBuffered()
+
+	if timedOut {
+		err := fmt.Errorf("%w after %v, %d of %d collectors did not finish", ErrCollectorTimeout, timeout, numCollectors-collectorsDone, numCollectors)
+		if !handleErr(err) {
+			return err
 		}
 	}
 	return nil
 }
 
-// Publish is used by NATS JetStream to stream the documents and send them to the processor
+// retrieveNamed runs the collector, setting the collector name of the
+// documents it collects before passing them to docChan. It returns once
+// all of them were passed on.
+func retrieveNamed(ctx context.Context, c Collector, name string, docChan chan<- *processor.Document) error {
+	collected := make(chan *processor.Document)
+	forwarded := make(chan struct{})
+	go func() {
+		defer close(forwarded)
+		for d := range collected {
+			if d.SourceInformation.CollectorName == "" {
+				d.SourceInformation.CollectorName = name
+			}
+			documentsCollected.Inc(d.SourceInformation.CollectorName)
+			docChan <- d
+		}
+	}()
+	err := c.RetrieveArtifacts(ctx, collected)
+	close(collected)
+	<-forwarded
+	return err
+}
+
+// withCollectedAt returns an emitter setting the collection time of the
+// documents for which the collector did not set it
+func withCollectedAt(emitter Emitter) Emitter {
+	return func(d *processor.Document) error {
+		if d.SourceInformation.CollectedAt.IsZero() {
+			d.SourceInformation.CollectedAt = time.Now().UTC()
+		}
+		return emitter(d)
+	}
+}
+
+// withEmitInterval returns an emitter waiting until at least interval has
+// passed since the previous document was emitted, or ctx is done
+func withEmitInterval(ctx context.Context, emitter Emitter, interval time.Duration) Emitter {
+	if interval <= 0 {
+		return emitter
+	}
+	var next time.Time
+	return func(d *processor.Document) error {
+		if wait := time.Until(next); wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+			}
+		}
+		next = time.Now().Add(interval)
+		return emitter(d)
+	}
+}
+
+// Publish is used by NATS JetStream to stream the documents and send them to the processor.
+// It starts the trace of the document, continued by the processor.
 func Publish(ctx context.Context, d *processor.Document) error {
 	logger := logging.FromContext(ctx)
+	ctx, span := tracing.Start(ctx, "collect")
+	defer span.End()
+	span.SetAttribute("guac.collector", d.SourceInformation.Collector)
+	span.SetAttribute("guac.source", d.SourceInformation.Source)
+
 	docByte, err := json.Marshal(d)
 	if err != nil {
 		return fmt.Errorf("failed marshal of document: %w", err)
 	}
-	err = emitter.Publish(ctx, emitter.SubjectNameDocCollected, docByte)
+	// keyed on the content digest, so that JetStream drops documents
+	// collected again within its duplicate window, and ordered by source, so
+	// that the versions of a document are ingested in the order collected
+	err = emitter.PublishWithMsgID(emitter.WithOrderingKey(ctx, d.SourceInformation.Source), emitter.SubjectNameDocCollected, hashcache.HashDocument(d), docByte)
 	if err != nil {
+		span.RecordError(err)
 		return err
 	}
 	logger.Debugf("doc published: %+v", d.SourceInformation.Source)
@@ -55,8 +55,10 @@ func TestCollect(t *testing.T) {
 			Type:   processor.DocumentUnknown,
 			Format: processor.FormatUnknown,
 			SourceInformation: processor.SourceInformation{
-				Collector: string(file.FileCollector),
-				Source:    "file:///testdata/hello",
+				Collector:     string(file.FileCollector),
+				CollectorName: "file",
+				Source:        "file:///testdata/hello",
+				URI:           file.FileURI("testdata/hello"),
 			}},
 		},
 		wantErr: false,
@@ -72,7 +74,13 @@ func TestCollect(t *testing.T) {
 				t.Error(err)
 			}
 
+			start := time.Now()
 			emit := func(d *processor.Document) error {
+				// the collection time is checked separately
+				if d.SourceInformation.CollectedAt.Before(start) {
+					t.Errorf("Collect() document collected at %v, want after %v", d.SourceInformation.CollectedAt, start)
+				}
+				d.SourceInformation.CollectedAt = time.Time{}
 				collectedDoc = append(collectedDoc, d)
 				return nil
 			}
@@ -89,6 +97,161 @@ func TestCollect(t *testing.T) {
 	}
 }
 
+// stuckCollector emits its document and then blocks without watching ctx,
+// as a collector waiting on an unresponsive mount
+type stuckCollector struct {
+	doc *processor.Document
+}
+
+func (s *stuckCollector) RetrieveArtifacts(ctx context.Context, docChannel chan<- *processor.Document) error {
+	docChannel <- s.doc
+	select {}
+}
+
+func (s *stuckCollector) Type() string {
+	return "stuck"
+}
+
+func TestCollectWithTimeout(t *testing.T) {
+	ctx := logging.WithLogger(context.Background())
+	documentCollectors = map[string]Collector{}
+	defer func() {
+		documentCollectors = map[string]Collector{}
+	}()
+
+	stuckDoc := &processor.Document{Blob: []byte("stuck")}
+	if err := RegisterDocumentCollector(&stuckCollector{doc: stuckDoc}, "stuck"); err != nil {
+		t.Fatal(err)
+	}
+	if err := RegisterDocumentCollector(file.NewFileCollector(ctx, "./testdata", true, time.Hour), file.FileCollector); err != nil {
+		t.Fatal(err)
+	}
+
+	var collectedDoc []*processor.Document
+	emit := func(d *processor.Document) error {
+		collectedDoc = append(collectedDoc, d)
+		return nil
+	}
+	var handledErrs []error
+	errHandler := func(err error) bool {
+		// the file collector stops polling gracefully on timeout
+		if err != nil {
+			handledErrs = append(handledErrs, err)
+		}
+		return err == nil || errors.Is(err, ErrCollectorTimeout)
+	}
+
+	if err := CollectWithTimeout(ctx, emit, errHandler, 100*time.Millisecond); err != nil {
+		t.Fatalf("CollectWithTimeout() error = %v", err)
+	}
+	if len(collectedDoc) != 2 {
+		t.Errorf("got %d documents, want the 2 collected before the timeout", len(collectedDoc))
+	}
+	if len(handledErrs) != 1 || !errors.Is(handledErrs[0], ErrCollectorTimeout) {
+		t.Errorf("got errors %v, want a single timeout", handledErrs)
+	}
+}
+
+// sliceCollector emits its documents as fast as they are received
+type sliceCollector struct {
+	docs []*processor.Document
+}
+
+func (s *sliceCollector) RetrieveArtifacts(ctx context.Context, docChannel chan<- *processor.Document) error {
+	for _, d := range s.docs {
+		docChannel <- d
+	}
+	return nil
+}
+
+func (s *sliceCollector) Type() string {
+	return "slice"
+}
+
+func TestRegisterNamedDocumentCollector(t *testing.T) {
+	ctx := logging.WithLogger(context.Background())
+	documentCollectors = map[string]Collector{}
+	defer func() {
+		documentCollectors = map[string]Collector{}
+		SetCollectorName("")
+	}()
+
+	SetCollectorName("nightly")
+	if err := RegisterDocumentCollector(&sliceCollector{docs: []*processor.Document{{Blob: []byte("1")}}}, "slice"); err != nil {
+		t.Fatal(err)
+	}
+	// collectors may set the name of their documents themselves
+	named := &processor.Document{Blob: []byte("2"), SourceInformation: processor.SourceInformation{CollectorName: "mirror"}}
+	if err := RegisterNamedDocumentCollector(&sliceCollector{docs: []*processor.Document{named}}, "other", "unused"); err != nil {
+		t.Fatal(err)
+	}
+
+	names := map[string]string{}
+	emit := func(d *processor.Document) error {
+		names[string(d.Blob)] = d.SourceInformation.CollectorName
+		return nil
+	}
+	if err := Collect(ctx, emit, func(err error) bool { return err == nil }); err != nil {
+		t.Fatalf("Collect() error = %v", err)
+	}
+	want := map[string]string{"1": "nightly", "2": "mirror"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("Collect() collector names = %v, want %v", names, want)
+	}
+}
+
+func TestDefaultCollectorName(t *testing.T) {
+	for collectorType, want := range map[string]string{
+		file.FileCollector:     "file",
+		"OCICollector":         "oci",
+		"GCS":                  "gcs",
+		"CollectorGitDocument": "gitdocument",
+		"Collector":            "collector",
+	} {
+		if got := DefaultCollectorName(collectorType); got != want {
+			t.Errorf("DefaultCollectorName(%q) = %q, want %q", collectorType, got, want)
+		}
+	}
+}
+
+func TestCollectWithEmitInterval(t *testing.T) {
+	ctx := logging.WithLogger(context.Background())
+	documentCollectors = map[string]Collector{}
+	defer func() {
+		documentCollectors = map[string]Collector{}
+		_ = SetEmitInterval(0)
+	}()
+
+	if err := SetEmitInterval(-time.Second); err == nil {
+		t.Error("SetEmitInterval() expected error for negative interval")
+	}
+	interval := 20 * time.Millisecond
+	if err := SetEmitInterval(interval); err != nil {
+		t.Fatal(err)
+	}
+	docs := []*processor.Document{{Blob: []byte("1")}, {Blob: []byte("2")}, {Blob: []byte("3")}}
+	if err := RegisterDocumentCollector(&sliceCollector{docs: docs}, "slice"); err != nil {
+		t.Fatal(err)
+	}
+
+	var emitted []time.Time
+	emit := func(d *processor.Document) error {
+		emitted = append(emitted, time.Now())
+		return nil
+	}
+	if err := Collect(ctx, emit, func(err error) bool { return err == nil }); err != nil {
+		t.Fatalf("Collect() error = %v", err)
+	}
+	if len(emitted) != len(docs) {
+		t.Fatalf("Collect() emitted %d documents, want %d", len(emitted), len(docs))
+	}
+	for i := 1; i < len(emitted); i++ {
+		if gap := emitted[i].Sub(emitted[i-1]); gap < interval {
+			t.Errorf("Collect() emitted document %d %v after the previous one, want at least %v", i, gap, interval)
+		}
+	}
+}
+
 func Test_Publish(t *testing.T) {
 	expectedDocTree := dochelper.DocNode(&testdata.Ite6SLSADoc)
 
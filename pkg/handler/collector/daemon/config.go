@@ -0,0 +1,182 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package daemon
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// types of the collectors of a daemon
+const (
+	TypeFile = "file"
+	TypeOCI  = "oci"
+	TypeGCS  = "gcs"
+	TypeS3   = "s3"
+)
+
+// Config declares the collectors run by a daemon, e.g.
+//
+//	collectors:
+//	  - name: sboms
+//	    type: file
+//	    paths: [/var/lib/guac/sboms]
+//	    include: ["**/*.json"]
+//	    interval: 5m
+//	  - name: images
+//	    type: oci
+//	    images: [ghcr.io/guacsec/guac, ghcr.io/guacsec/guac-data:v0.1.0]
+//	    interval: 1h
+//	  - name: attestations
+//	    type: s3
+//	    bucket: attestations
+//	    prefix: slsa/
+//	    region: eu-west-1
+//	    interval: 10m
+type Config struct {
+	Collectors []CollectorConfig `yaml:"collectors" mapstructure:"collectors"`
+}
+
+// CollectorConfig declares a collector and its schedule
+type CollectorConfig struct {
+	// Name tells the collector apart from the others, and labels the logs
+	// and documents of the collector, see processor.SourceInformation
+	Name string `yaml:"name" mapstructure:"name"`
+	// Type is the type of the collector: file, oci, gcs or s3
+	Type string `yaml:"type" mapstructure:"type"`
+	// Interval is the time between two runs of the collector, each only
+	// collecting what is new or changed for the file, gcs and s3 collectors.
+	// The collector runs once if 0.
+	Interval time.Duration `yaml:"interval" mapstructure:"interval"`
+
+	// Paths are the files and folders of a file collector, and Include and
+	// Exclude the glob patterns of the files to collect and skip, relative
+	// to each path
+	Paths   []string `yaml:"paths,omitempty" mapstructure:"paths"`
+	Include []string `yaml:"include,omitempty" mapstructure:"include"`
+	Exclude []string `yaml:"exclude,omitempty" mapstructure:"exclude"`
+
+	// Images are the images of an oci collector, repo:tag, or repo for all
+	// the tags of the repository
+	Images []string `yaml:"images,omitempty" mapstructure:"images"`
+
+	// Bucket and Prefix are the bucket and the prefix of the objects to
+	// collect of a gcs or s3 collector
+	Bucket string `yaml:"bucket,omitempty" mapstructure:"bucket"`
+	Prefix string `yaml:"prefix,omitempty" mapstructure:"prefix"`
+	// Region and Endpoint override the region and the endpoint of an s3
+	// collector, see s3.WithRegion and s3.WithEndpoint
+	Region   string `yaml:"region,omitempty" mapstructure:"region"`
+	Endpoint string `yaml:"endpoint,omitempty" mapstructure:"endpoint"`
+}
+
+// LoadConfig reads and validates the YAML config at path
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read collectors config: %w", err)
+	}
+	c, err := ParseConfig(data)
+	if err != nil {
+		return nil, fmt.Errorf("collectors config %s: %w", path, err)
+	}
+	return c, nil
+}
+
+// ParseConfig parses and validates a YAML config. Unknown fields are
+// rejected, so that typos do not silently change a collector.
+func ParseConfig(data []byte) (*Config, error) {
+	c := &Config{}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to parse collectors config: %w", err)
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Validate checks that the collectors have unique names and the fields their
+// type requires
+func (c *Config) Validate() error {
+	names := map[string]bool{}
+	for i, cc := range c.Collectors {
+		if cc.Name == "" {
+			return fmt.Errorf("collector %d has no name", i)
+		}
+		if names[cc.Name] {
+			return fmt.Errorf("collector name %q is used more than once", cc.Name)
+		}
+		names[cc.Name] = true
+		if err := cc.Validate(); err != nil {
+			return fmt.Errorf("collector %q: %w", cc.Name, err)
+		}
+	}
+	return nil
+}
+
+// Validate checks that the collector has the fields its type requires
+func (cc *CollectorConfig) Validate() error {
+	if cc.Interval < 0 {
+		return fmt.Errorf("interval must not be negative, got %v", cc.Interval)
+	}
+	switch cc.Type {
+	case TypeFile:
+		if len(cc.Paths) == 0 {
+			return errors.New("file collector requires paths")
+		}
+	case TypeOCI:
+		if len(cc.Images) == 0 {
+			return errors.New("oci collector requires images")
+		}
+	case TypeGCS, TypeS3:
+		if cc.Bucket == "" {
+			return fmt.Errorf("%s collector requires a bucket", cc.Type)
+		}
+	default:
+		return fmt.Errorf("unknown collector type %q, expected one of %s, %s, %s or %s", cc.Type, TypeFile, TypeOCI, TypeGCS, TypeS3)
+	}
+	return nil
+}
+
+// repoTags returns the tags of each repository of the images of an oci
+// collector, none for all the tags
+func (cc *CollectorConfig) repoTags() map[string][]string {
+	repoTags := map[string][]string{}
+	for _, image := range cc.Images {
+		repo, tag := image, ""
+		// the port of a registry is not a tag, e.g. localhost:5000/repo
+		if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+			repo, tag = image[:i], image[i+1:]
+		}
+		if _, ok := repoTags[repo]; !ok {
+			repoTags[repo] = []string{}
+		}
+		if tag != "" {
+			repoTags[repo] = append(repoTags[repo], tag)
+		}
+	}
+	return repoTags
+}
@@ -0,0 +1,321 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/guacsec/guac/pkg/handler/collector"
+	"github.com/guacsec/guac/pkg/handler/collector/file"
+	"github.com/guacsec/guac/pkg/handler/collector/gcs"
+	"github.com/guacsec/guac/pkg/handler/collector/oci"
+	"github.com/guacsec/guac/pkg/handler/collector/s3"
+	"github.com/guacsec/guac/pkg/handler/processor"
+	"github.com/guacsec/guac/pkg/logging"
+)
+
+// DefaultRestartDelay is the delay before a collector that ended with an
+// error is run again, when it has no interval
+const DefaultRestartDelay = time.Minute
+
+// NewCollectorFunc returns the collector declared by a collector config
+type NewCollectorFunc func(ctx context.Context, cc CollectorConfig) (collector.Collector, error)
+
+// Daemon runs the collectors of a config continuously, until its context is
+// done. Apply replaces the collectors with the ones of a new config, e.g. on
+// SIGHUP, only restarting the collectors that changed.
+type Daemon struct {
+	emitter      collector.Emitter
+	handleErr    collector.ErrHandler
+	newCollector NewCollectorFunc
+	restartDelay time.Duration
+
+	mu      sync.Mutex
+	running map[string]*runningCollector
+	wg      sync.WaitGroup
+}
+
+// runningCollector is a collector run by a daemon
+type runningCollector struct {
+	config CollectorConfig
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	// statusMu guards status apart from d.mu, held by Apply while waiting
+	// for a stopped collector to end
+	statusMu sync.Mutex
+	status   CollectorStatus
+}
+
+// CollectorState is the state of a collector run by a daemon
+type CollectorState string
+
+const (
+	// StateRunning is a collector collecting, or waiting for its next
+	// interval
+	StateRunning CollectorState = "running"
+	// StateRestarting is a collector that ended with an error, waiting to
+	// be run again
+	StateRestarting CollectorState = "restarting"
+	// StateEnded is a collector that ended without error, e.g. one without
+	// an interval that collected everything once
+	StateEnded CollectorState = "ended"
+)
+
+// CollectorStatus is the status of a collector run by a daemon
+type CollectorStatus struct {
+	Name  string
+	Type  string
+	State CollectorState
+	// Documents is the number of documents collected since it was started
+	Documents int
+	// LastDocument is when it last collected a document, zero if none
+	LastDocument time.Time
+	// Restarts is the number of times it was run again after an error
+	Restarts int
+	// Err is the error it last ended with, nil once it collects again
+	Err error
+}
+
+// Option configures a daemon
+type Option func(*Daemon)
+
+// WithNewCollector sets how the daemon creates the collectors of a config,
+// NewCollector by default
+func WithNewCollector(newCollector NewCollectorFunc) Option {
+	return func(d *Daemon) {
+		d.newCollector = newCollector
+	}
+}
+
+// WithRestartDelay sets the delay before a collector that ended with an
+// error and has no interval is run again, DefaultRestartDelay by default
+func WithRestartDelay(delay time.Duration) Option {
+	return func(d *Daemon) {
+		d.restartDelay = delay
+	}
+}
+
+// New returns a daemon passing the documents collected to emitter. handleErr
+// is passed the errors of the collectors, see collector.Collect, and a
+// collector ending with an error it does not handle is run again after its
+// interval.
+func New(emitter collector.Emitter, handleErr collector.ErrHandler, opts ...Option) *Daemon {
+	d := &Daemon{
+		emitter:      emitter,
+		handleErr:    handleErr,
+		newCollector: NewCollector,
+		restartDelay: DefaultRestartDelay,
+		running:      map[string]*runningCollector{},
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
+}
+
+// NewCollector returns the file, oci, gcs or s3 collector declared by cc,
+// polling every cc.Interval if not 0
+func NewCollector(ctx context.Context, cc CollectorConfig) (collector.Collector, error) {
+	switch cc.Type {
+	case TypeFile:
+		return file.NewFileCollectorWithPatterns(ctx, cc.Paths, cc.Include, cc.Exclude, cc.Interval > 0, cc.Interval), nil
+	case TypeOCI:
+		return oci.NewOCICollector(ctx, cc.repoTags(), cc.Interval > 0, cc.Interval), nil
+	case TypeGCS:
+		return gcs.NewGCSCollector(ctx, cc.Bucket, cc.Prefix, cc.Interval)
+	case TypeS3:
+		return s3.NewS3Collector(ctx, cc.Bucket, cc.Prefix, cc.Interval, s3.WithRegion(cc.Region), s3.WithEndpoint(cc.Endpoint))
+	default:
+		return nil, fmt.Errorf("unknown collector type %q", cc.Type)
+	}
+}
+
+// Apply runs the collectors of cfg, stopping the running collectors that are
+// no longer in cfg or whose config changed, and leaving the others running.
+// The collectors are all created before any is stopped, so that on error the
+// running collectors are left as they are.
+func (d *Daemon) Apply(ctx context.Context, cfg *Config) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	logger := logging.FromContext(ctx)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	started := map[string]collector.Collector{}
+	for _, cc := range cfg.Collectors {
+		if r, ok := d.running[cc.Name]; ok && reflect.DeepEqual(r.config, cc) {
+			continue
+		}
+		c, err := d.newCollector(ctx, cc)
+		if err != nil {
+			return fmt.Errorf("collector %q: %w", cc.Name, err)
+		}
+		started[cc.Name] = c
+	}
+
+	keep := map[string]bool{}
+	for _, cc := range cfg.Collectors {
+		keep[cc.Name] = true
+	}
+	for name, r := range d.running {
+		if _, restart := started[name]; restart || !keep[name] {
+			r.cancel()
+			<-r.done
+			delete(d.running, name)
+			logger.Infof("stopped collector %s", name)
+		}
+	}
+	for _, cc := range cfg.Collectors {
+		if c, ok := started[cc.Name]; ok {
+			d.start(ctx, cc, c)
+			logger.Infof("started %s collector %s", cc.Type, cc.Name)
+		}
+	}
+	return nil
+}
+
+// Running returns the names of the running collectors, sorted
+func (d *Daemon) Running() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	names := make([]string, 0, len(d.running))
+	for name := range d.running {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Status returns the status of the collectors, sorted by name
+func (d *Daemon) Status() []CollectorStatus {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	statuses := make([]CollectorStatus, 0, len(d.running))
+	for _, r := range d.running {
+		r.statusMu.Lock()
+		statuses = append(statuses, r.status)
+		r.statusMu.Unlock()
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Name < statuses[j].Name
+	})
+	return statuses
+}
+
+// Check returns an error naming the collectors that ended with an error and
+// wait to be run again, e.g. as a readiness check of the health endpoints
+func (d *Daemon) Check(context.Context) error {
+	var failing []string
+	for _, status := range d.Status() {
+		if status.State == StateRestarting {
+			failing = append(failing, fmt.Sprintf("%s: %v", status.Name, status.Err))
+		}
+	}
+	if len(failing) > 0 {
+		return fmt.Errorf("collectors failing: %s", strings.Join(failing, "; "))
+	}
+	return nil
+}
+
+// Wait waits for the collectors to end, once the context passed to Apply is
+// done or they all ran once
+func (d *Daemon) Wait() {
+	d.wg.Wait()
+}
+
+// start runs c until ctx is done or it ends without error, with d.mu held
+func (d *Daemon) start(ctx context.Context, cc CollectorConfig, c collector.Collector) {
+	ctx, cancel := context.WithCancel(ctx)
+	r := &runningCollector{
+		config: cc,
+		cancel: cancel,
+		done:   make(chan struct{}),
+		status: CollectorStatus{Name: cc.Name, Type: string(cc.Type), State: StateRunning},
+	}
+	d.running[cc.Name] = r
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		defer close(r.done)
+		defer cancel()
+		d.run(ctx, r, c)
+	}()
+}
+
+// updateStatus updates the status of r with update
+func (r *runningCollector) updateStatus(update func(*CollectorStatus)) {
+	r.statusMu.Lock()
+	defer r.statusMu.Unlock()
+	update(&r.status)
+}
+
+// run runs c, again after a delay each time it ends with an error, until
+// ctx is done or it ends without error
+func (d *Daemon) run(ctx context.Context, r *runningCollector, c collector.Collector) {
+	logger := logging.FromContext(ctx)
+	cc := r.config
+	delay := cc.Interval
+	if delay <= 0 {
+		delay = d.restartDelay
+	}
+	emit := func(doc *processor.Document) error {
+		r.updateStatus(func(s *CollectorStatus) {
+			s.Documents++
+			s.LastDocument = time.Now()
+			s.Err = nil
+		})
+		return d.emitter(doc)
+	}
+	for {
+		err := collector.CollectNamed(ctx, c, cc.Name, emit, d.handleErr)
+		if ctx.Err() != nil {
+			return
+		}
+		if err == nil {
+			logger.Infof("collector %s ended", cc.Name)
+			r.updateStatus(func(s *CollectorStatus) { s.State = StateEnded })
+			return
+		}
+		if errors.Is(err, context.Canceled) {
+			return
+		}
+		logger.Errorf("collector %s ended with error, running it again in %v: %v", cc.Name, delay, err)
+		r.updateStatus(func(s *CollectorStatus) {
+			s.State = StateRestarting
+			s.Err = err
+		})
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		r.updateStatus(func(s *CollectorStatus) {
+			s.State = StateRunning
+			s.Restarts++
+		})
+	}
+}
@@ -0,0 +1,338 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package daemon
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/guacsec/guac/pkg/handler/collector"
+	"github.com/guacsec/guac/pkg/handler/processor"
+	"github.com/guacsec/guac/pkg/logging"
+)
+
+func TestParseConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    *Config
+		wantErr string
+	}{{
+		name: "collectors",
+		data: `
+collectors:
+  - name: sboms
+    type: file
+    paths: [/sboms]
+    include: ["**/*.json"]
+    interval: 5m
+  - name: images
+    type: oci
+    images: [ghcr.io/guacsec/guac:v0.1.0]
+  - name: attestations
+    type: s3
+    bucket: attestations
+    region: eu-west-1
+    interval: 1h
+`,
+		want: &Config{Collectors: []CollectorConfig{{
+			Name:     "sboms",
+			Type:     TypeFile,
+			Paths:    []string{"/sboms"},
+			Include:  []string{"**/*.json"},
+			Interval: 5 * time.Minute,
+		}, {
+			Name:   "images",
+			Type:   TypeOCI,
+			Images: []string{"ghcr.io/guacsec/guac:v0.1.0"},
+		}, {
+			Name:     "attestations",
+			Type:     TypeS3,
+			Bucket:   "attestations",
+			Region:   "eu-west-1",
+			Interval: time.Hour,
+		}}},
+	}, {
+		name: "empty",
+		data: "",
+		want: &Config{},
+	}, {
+		name:    "unknown field",
+		data:    "collectors:\n  - name: sboms\n    type: file\n    path: /sboms\n",
+		wantErr: "field path not found",
+	}, {
+		name:    "unknown type",
+		data:    "collectors:\n  - name: sboms\n    type: ftp\n",
+		wantErr: "unknown collector type",
+	}, {
+		name:    "no name",
+		data:    "collectors:\n  - type: file\n    paths: [/sboms]\n",
+		wantErr: "has no name",
+	}, {
+		name:    "duplicate name",
+		data:    "collectors:\n  - name: sboms\n    type: file\n    paths: [/a]\n  - name: sboms\n    type: file\n    paths: [/b]\n",
+		wantErr: "used more than once",
+	}, {
+		name:    "missing bucket",
+		data:    "collectors:\n  - name: attestations\n    type: gcs\n",
+		wantErr: "requires a bucket",
+	}, {
+		name:    "negative interval",
+		data:    "collectors:\n  - name: sboms\n    type: file\n    paths: [/sboms]\n    interval: -1m\n",
+		wantErr: "must not be negative",
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseConfig([]byte(tt.data))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ParseConfig() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseConfig() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseConfig() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRepoTags(t *testing.T) {
+	cc := CollectorConfig{Images: []string{
+		"ghcr.io/guacsec/guac:v0.1.0",
+		"ghcr.io/guacsec/guac:v0.2.0",
+		"localhost:5000/guac",
+	}}
+	want := map[string][]string{
+		"ghcr.io/guacsec/guac": {"v0.1.0", "v0.2.0"},
+		"localhost:5000/guac":  {},
+	}
+	if got := cc.repoTags(); !reflect.DeepEqual(got, want) {
+		t.Errorf("repoTags() = %v, want %v", got, want)
+	}
+}
+
+// mockCollector emits a document with the name of its config, then waits for
+// ctx to be done
+type mockCollector struct {
+	source string
+}
+
+func (m *mockCollector) RetrieveArtifacts(ctx context.Context, docChannel chan<- *processor.Document) error {
+	docChannel <- &processor.Document{SourceInformation: processor.SourceInformation{Source: m.source}}
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (m *mockCollector) Type() string {
+	return "mock"
+}
+
+func TestDaemon_Apply(t *testing.T) {
+	ctx, cancel := context.WithCancel(logging.WithLogger(context.Background()))
+	defer cancel()
+
+	var mu sync.Mutex
+	// the number of times the collector of each source started
+	starts := map[string]int{}
+	collected := make(chan string, 10)
+	newCollector := func(ctx context.Context, cc CollectorConfig) (collector.Collector, error) {
+		if cc.Paths[0] == "/broken" {
+			return nil, errors.New("broken")
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		starts[cc.Paths[0]]++
+		return &mockCollector{source: cc.Paths[0]}, nil
+	}
+	emit := func(d *processor.Document) error {
+		collected <- d.SourceInformation.CollectorName + " " + d.SourceInformation.Source
+		return nil
+	}
+	handleErr := func(err error) bool {
+		return err == nil || errors.Is(err, context.Canceled)
+	}
+	d := New(emit, handleErr, WithNewCollector(newCollector))
+
+	waitCollected := func(want ...string) {
+		t.Helper()
+		got := map[string]bool{}
+		for len(got) < len(want) {
+			select {
+			case s := <-collected:
+				got[s] = true
+			case <-time.After(5 * time.Second):
+				t.Fatalf("timed out waiting for %v, got %v", want, got)
+			}
+		}
+		for _, s := range want {
+			if !got[s] {
+				t.Errorf("collected %v, want %v", got, want)
+			}
+		}
+	}
+	fileConfig := func(name string, path string) CollectorConfig {
+		return CollectorConfig{Name: name, Type: TypeFile, Paths: []string{path}}
+	}
+
+	if err := d.Apply(ctx, &Config{Collectors: []CollectorConfig{fileConfig("a", "/a"), fileConfig("b", "/b")}}); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	waitCollected("a /a", "b /b")
+
+	// a is left running, b is changed and c is added
+	if err := d.Apply(ctx, &Config{Collectors: []CollectorConfig{fileConfig("a", "/a"), fileConfig("b", "/b2"), fileConfig("c", "/c")}}); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	waitCollected("b /b2", "c /c")
+	if got, want := d.Running(), []string{"a", "b", "c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Running() = %v, want %v", got, want)
+	}
+
+	// a config failing to create a collector leaves the collectors running
+	if err := d.Apply(ctx, &Config{Collectors: []CollectorConfig{fileConfig("a", "/broken")}}); err == nil {
+		t.Errorf("Apply() expected error for a broken collector")
+	}
+	if got, want := d.Running(), []string{"a", "b", "c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Running() = %v after a failed Apply(), want %v", got, want)
+	}
+
+	// c is removed
+	if err := d.Apply(ctx, &Config{Collectors: []CollectorConfig{fileConfig("a", "/a"), fileConfig("b", "/b2")}}); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if got, want := d.Running(), []string{"a", "b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Running() = %v, want %v", got, want)
+	}
+
+	cancel()
+	d.Wait()
+	mu.Lock()
+	defer mu.Unlock()
+	if want := map[string]int{"/a": 1, "/b": 1, "/b2": 1, "/c": 1}; !reflect.DeepEqual(starts, want) {
+		t.Errorf("collectors started %v, want %v", starts, want)
+	}
+}
+
+// failingCollector fails the first times it is run, then emits a document
+type failingCollector struct {
+	failures int
+}
+
+func (f *failingCollector) RetrieveArtifacts(ctx context.Context, docChannel chan<- *processor.Document) error {
+	if f.failures > 0 {
+		f.failures--
+		return errors.New("unavailable")
+	}
+	docChannel <- &processor.Document{SourceInformation: processor.SourceInformation{Source: "recovered"}}
+	return nil
+}
+
+func (f *failingCollector) Type() string {
+	return "failing"
+}
+
+func TestDaemon_Restart(t *testing.T) {
+	ctx := logging.WithLogger(context.Background())
+	collected := make(chan string, 1)
+	newCollector := func(ctx context.Context, cc CollectorConfig) (collector.Collector, error) {
+		return &failingCollector{failures: 2}, nil
+	}
+	emit := func(d *processor.Document) error {
+		collected <- d.SourceInformation.Source
+		return nil
+	}
+	handleErr := func(err error) bool {
+		return err == nil
+	}
+	d := New(emit, handleErr, WithNewCollector(newCollector), WithRestartDelay(time.Millisecond))
+	if err := d.Apply(ctx, &Config{Collectors: []CollectorConfig{{Name: "a", Type: TypeFile, Paths: []string{"/a"}}}}); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	// the collector ends once it ran without error
+	d.Wait()
+	select {
+	case got := <-collected:
+		if got != "recovered" {
+			t.Errorf("collected %q, want %q", got, "recovered")
+		}
+	default:
+		t.Errorf("collected nothing, want the document of the collector run again")
+	}
+}
+
+func TestDaemon_Status(t *testing.T) {
+	ctx, cancel := context.WithCancel(logging.WithLogger(context.Background()))
+	defer cancel()
+	newCollector := func(ctx context.Context, cc CollectorConfig) (collector.Collector, error) {
+		if cc.Name == "failing" {
+			return &failingCollector{failures: 1}, nil
+		}
+		return &failingCollector{}, nil
+	}
+	emit := func(d *processor.Document) error {
+		return nil
+	}
+	handleErr := func(err error) bool {
+		return err == nil
+	}
+	// the failing collector is not run again before the end of the test
+	d := New(emit, handleErr, WithNewCollector(newCollector), WithRestartDelay(time.Hour))
+	if err := d.Apply(ctx, &Config{Collectors: []CollectorConfig{
+		{Name: "failing", Type: TypeFile, Paths: []string{"/failing"}},
+		{Name: "once", Type: TypeFile, Paths: []string{"/once"}},
+	}}); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	var statuses []CollectorStatus
+	for {
+		statuses = d.Status()
+		if statuses[0].State == StateRestarting && statuses[1].State == StateEnded {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Status() = %+v, want failing restarting and once ended", statuses)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if statuses[0].Name != "failing" || statuses[0].Documents != 0 || statuses[0].Err == nil {
+		t.Errorf("Status() of failing = %+v, want no document and its error", statuses[0])
+	}
+	if statuses[1].Name != "once" || statuses[1].Type != string(TypeFile) || statuses[1].Documents != 1 || statuses[1].LastDocument.IsZero() {
+		t.Errorf("Status() of once = %+v, want its document", statuses[1])
+	}
+	if err := d.Check(ctx); err == nil || !strings.Contains(err.Error(), "failing: unavailable") {
+		t.Errorf("Check() = %v, want the failing collector", err)
+	}
+
+	// removing the failing collector clears the check
+	if err := d.Apply(ctx, &Config{Collectors: []CollectorConfig{{Name: "once", Type: TypeFile, Paths: []string{"/once"}}}}); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if err := d.Check(ctx); err != nil {
+		t.Errorf("Check() = %v, want nil", err)
+	}
+}
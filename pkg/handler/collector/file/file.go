@@ -16,15 +16,21 @@
 package file
 
 import (
+	"bufio"
 	"context"
 	"errors"
 	"fmt"
 	"io/fs"
+	"net/url"
 	"os"
+	"path"
 	"path/filepath"
+	"strings"
 	"time"
 
+	"github.com/guacsec/guac/pkg/handler/collector/retry"
 	"github.com/guacsec/guac/pkg/handler/processor"
+	"github.com/guacsec/guac/pkg/logging"
 )
 
 const (
@@ -32,20 +38,73 @@ const (
 )
 
 type fileCollector struct {
-	path        string
+	paths       []string
+	fileList    string
+	include     []string
+	exclude     []string
 	lastChecked time.Time
 	poll        bool
-	interval    time.Duration
+	// watch watches the paths for changes instead of polling them, the
+	// interval being the time files need to be left unchanged to be collected
+	watch    bool
+	interval time.Duration
 }
 
 func NewFileCollector(ctx context.Context, path string, poll bool, interval time.Duration) *fileCollector {
+	return NewFileCollectorWithPatterns(ctx, []string{path}, nil, nil, poll, interval)
+}
+
+// NewFileCollectorWithPatterns returns a collector walking each of the root
+// paths. Files are collected if their path relative to the root matches one of
+// the include patterns (or if there are none) and none of the exclude
+// patterns. Patterns use the path.Match syntax on slash separated paths, with
+// `**` additionally matching any number of directories, e.g. `**/*.json`.
+// Directories matching an exclude pattern are not descended into.
+func NewFileCollectorWithPatterns(ctx context.Context, paths []string, include []string, exclude []string, poll bool, interval time.Duration) *fileCollector {
 	return &fileCollector{
-		path:     path,
+		paths:    paths,
+		include:  include,
+		exclude:  exclude,
 		poll:     poll,
 		interval: interval,
 	}
 }
 
+// NewFileWatchCollector returns a collector walking each of the root paths
+// like NewFileCollectorWithPatterns, and then watching them and their
+// subdirectories for new and modified files instead of polling them. A file
+// is collected once it was left unchanged for settle, so that files being
+// written are collected when complete.
+func NewFileWatchCollector(ctx context.Context, paths []string, include []string, exclude []string, settle time.Duration) *fileCollector {
+	f := NewFileCollectorWithPatterns(ctx, paths, include, exclude, false, settle)
+	f.watch = true
+	return f
+}
+
+// NewFileListCollector returns a collector reading the files listed in the
+// newline-delimited file at fileList instead of walking directories. Blank
+// lines and lines starting with `#` are ignored. Listed paths that do not
+// exist or are not regular files are logged and skipped.
+func NewFileListCollector(ctx context.Context, fileList string, poll bool, interval time.Duration) *fileCollector {
+	return &fileCollector{
+		fileList: fileList,
+		poll:     poll,
+		interval: interval,
+	}
+}
+
+// ValidatePatterns returns an error if one of the patterns is malformed
+func ValidatePatterns(patterns []string) error {
+	for _, p := range patterns {
+		for _, part := range splitPattern(p) {
+			if _, err := path.Match(part, ""); err != nil {
+				return fmt.Errorf("invalid pattern %q: %w", p, err)
+			}
+		}
+	}
+	return nil
+}
+
 // RetrieveArtifacts collects the documents from the collector. It emits each collected
 // document through the channel to be collected and processed by the upstream processor.
 // The function should block until all the artifacts are collected and return a nil error
@@ -53,72 +112,289 @@ func NewFileCollector(ctx context.Context, path string, poll bool, interval time
 // for new artifacts as they are being uploaded by polling on an interval or run once and
 // grab all the artifacts and end.
 func (f *fileCollector) RetrieveArtifacts(ctx context.Context, docChannel chan<- *processor.Document) error {
-	if _, err := os.Stat(f.path); os.IsExist(err) {
-		return fmt.Errorf("path: %s does not exist", f.path)
+	if err := ValidatePatterns(append(append([]string{}, f.include...), f.exclude...)); err != nil {
+		return err
 	}
 
-	readFunc := func(path string, dirEntry fs.DirEntry, err error) error {
-		// If the context has been canceled it contains an err which we can throw.
-		// When it gets thrown a second time will cancel the walk.
-		// See filepath.WalkDir for more info.
-		if ctx.Err() != nil {
-			return ctx.Err()
+	if f.watch {
+		return f.watchRoots(ctx, docChannel)
+	}
+	if f.poll {
+		for {
+			err := f.walkRoots(ctx, docChannel)
+			if err != nil {
+				if errors.Is(err, ctx.Err()) {
+					return nil
+				}
+				return err
+			}
+			f.lastChecked = time.Now()
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(f.interval):
+			}
 		}
-		// NOTE: Explicitly rethrowing new errors if a particular directory has an error.
-		// If we rethrow the error it kills the whole walk. Still useful to make it explicit that we ran into an error.
+	} else {
+		err := f.walkRoots(ctx, docChannel)
 		if err != nil {
-			return fmt.Errorf("path: %s is invalid", path)
+			return err
 		}
-		if dirEntry.IsDir() {
-			return nil
+		f.lastChecked = time.Now()
+	}
+
+	return nil
+}
+
+func (f *fileCollector) walkRoots(ctx context.Context, docChannel chan<- *processor.Document) error {
+	if f.fileList != "" {
+		return f.collectFileList(ctx, docChannel)
+	}
+	for _, root := range f.paths {
+		// An invalid root is a configuration error, unlike problems with
+		// entries found during the walk which are only logged.
+		if _, err := os.Stat(root); err != nil {
+			return fmt.Errorf("path: %s is invalid: %w", root, err)
+		}
+		w := &walker{
+			collector:  f,
+			root:       root,
+			docChannel: docChannel,
+			visited:    map[string]bool{},
 		}
-		if info, err := dirEntry.Info(); !info.ModTime().After(f.lastChecked) || err != nil {
+		if err := w.walk(ctx, root); err != nil {
 			return err
 		}
+	}
+	return nil
+}
 
-		blob, err := os.ReadFile(path)
+// collectFileList collects each file listed in the file list. A missing file
+// list is a configuration error, unlike problems with the listed files.
+func (f *fileCollector) collectFileList(ctx context.Context, docChannel chan<- *processor.Document) error {
+	logger := logging.FromContext(ctx)
+	paths, err := readFileList(f.fileList)
+	if err != nil {
+		return err
+	}
+	for _, p := range paths {
+		info, err := os.Stat(p)
 		if err != nil {
+			logger.Warnf("skipping listed path: %s: %v", p, err)
+			continue
+		}
+		if info.IsDir() {
+			logger.Warnf("skipping listed path: %s: is a directory", p)
+			continue
+		}
+		w := &walker{
+			collector:  f,
+			root:       p,
+			docChannel: docChannel,
+			visited:    map[string]bool{},
+		}
+		if err := w.walk(ctx, p); err != nil {
 			return err
 		}
+	}
+	return nil
+}
+
+// readFileList returns the paths listed in the file at listPath, one per line
+func readFileList(listPath string) ([]string, error) {
+	list, err := os.Open(listPath)
+	if err != nil {
+		return nil, fmt.Errorf("file list: %s is invalid: %w", listPath, err)
+	}
+	defer list.Close()
 
-		doc := &processor.Document{
-			Blob:   blob,
-			Type:   processor.DocumentUnknown,
-			Format: processor.FormatUnknown,
-			SourceInformation: processor.SourceInformation{
-				Collector: string(FileCollector),
-				Source:    fmt.Sprintf("file:///%s", path),
-			},
+	paths := []string{}
+	scanner := bufio.NewScanner(list)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
 		}
+		paths = append(paths, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read file list: %s: %w", listPath, err)
+	}
+	return paths, nil
+}
 
-		docChannel <- doc
+// walker walks a single root. Unlike filepath.WalkDir it follows symbolic
+// links to directories, keeping track of the directories visited so that
+// links pointing back up the tree do not loop forever.
+type walker struct {
+	collector  *fileCollector
+	root       string
+	docChannel chan<- *processor.Document
+	visited    map[string]bool
+	// all collects the files regardless of their modification time, e.g.
+	// files moved into a watched directory keep theirs
+	all bool
+}
 
+func (w *walker) walk(ctx context.Context, p string) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	logger := logging.FromContext(ctx)
+
+	// os.Stat follows symbolic links
+	info, err := os.Stat(p)
+	if err != nil {
+		logger.Warnf("skipping path: %s: %v", p, err)
 		return nil
 	}
 
-	if f.poll {
-		for {
-			err := filepath.WalkDir(f.path, readFunc)
-			if err != nil {
-				if errors.Is(err, ctx.Err()) {
-					return nil
-				}
+	rel, err := filepath.Rel(w.root, p)
+	if err != nil {
+		return err
+	}
+	rel = filepath.ToSlash(rel)
+
+	if info.IsDir() {
+		if rel != "." && w.collector.matchAny(w.collector.exclude, rel) {
+			return nil
+		}
+		real, err := filepath.EvalSymlinks(p)
+		if err != nil {
+			logger.Warnf("skipping directory: %s: %v", p, err)
+			return nil
+		}
+		if w.visited[real] {
+			logger.Warnf("skipping directory: %s: already visited through %s", p, real)
+			return nil
+		}
+		w.visited[real] = true
+
+		entries, err := os.ReadDir(p)
+		if err != nil {
+			// os.ReadDir returns the entries read before the error, so
+			// keep going with those
+			logger.Warnf("unable to read directory: %s: %v", p, err)
+		}
+		for _, entry := range entries {
+			if err := w.walk(ctx, filepath.Join(p, entry.Name())); err != nil {
 				return err
 			}
-			f.lastChecked = time.Now()
-			time.Sleep(f.interval)
 		}
-	} else {
-		err := filepath.WalkDir(f.path, readFunc)
-		if err != nil {
-			return err
+		return nil
+	}
+
+	// a root pointing directly to a file is always collected
+	if rel == "." {
+		rel = filepath.Base(p)
+	} else if !w.collector.included(rel) {
+		return nil
+	}
+	if !w.all && !info.ModTime().After(w.collector.lastChecked) {
+		return nil
+	}
+
+	blob, err := retry.ReadDocument(ctx, p, func() ([]byte, error) {
+		blob, err := os.ReadFile(p)
+		if errors.Is(err, fs.ErrNotExist) {
+			// removed since the directory was listed
+			return nil, retry.Permanent(err)
 		}
-		f.lastChecked = time.Now()
+		return blob, err
+	})
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		logger.Warnf("skipping file: %s: %v", p, err)
+		return nil
+	}
+
+	doc := &processor.Document{
+		Blob:   blob,
+		Type:   processor.DocumentUnknown,
+		Format: processor.FormatUnknown,
+		SourceInformation: processor.SourceInformation{
+			Collector: string(FileCollector),
+			Source:    fmt.Sprintf("file:///%s", p),
+			URI:       FileURI(p),
+		},
 	}
 
+	select {
+	case w.docChannel <- doc:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 	return nil
 }
 
+// FileURI returns the `file://` URI of the file at path p
+func FileURI(p string) string {
+	if abs, err := filepath.Abs(p); err == nil {
+		p = abs
+	}
+	u := url.URL{Scheme: "file", Path: filepath.ToSlash(p)}
+	return u.String()
+}
+
+// included reports whether the file at the relative path rel is collected
+func (f *fileCollector) included(rel string) bool {
+	if len(f.include) > 0 && !f.matchAny(f.include, rel) {
+		return false
+	}
+	return !f.matchAny(f.exclude, rel)
+}
+
+func (f *fileCollector) matchAny(patterns []string, rel string) bool {
+	for _, p := range patterns {
+		if matchPattern(p, rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchPattern matches the slash separated path name against pattern, where
+// a `**` element matches zero or more path elements. Malformed patterns never
+// match, they are rejected by ValidatePatterns before walking.
+func matchPattern(pattern string, name string) bool {
+	return matchParts(splitPattern(pattern), strings.Split(name, "/"))
+}
+
+func splitPattern(pattern string) []string {
+	return strings.Split(strings.Trim(pattern, "/"), "/")
+}
+
+func matchParts(pattern []string, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			// collapse consecutive `**` elements
+			for len(pattern) > 0 && pattern[0] == "**" {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 0 {
+				return true
+			}
+			for i := 0; i < len(name); i++ {
+				if matchParts(pattern, name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, err := path.Match(pattern[0], name[0]); !ok || err != nil {
+			return false
+		}
+		pattern = pattern[1:]
+		name = name[1:]
+	}
+	return len(name) == 0
+}
+
 // Type returns the collector type
 func (f *fileCollector) Type() string {
 	return FileCollector
@@ -17,7 +17,10 @@ package file
 
 import (
 	"context"
+	"os"
+	"path/filepath"
 	"reflect"
+	"sort"
 	"testing"
 	"time"
 
@@ -61,6 +64,7 @@ func Test_fileCollector_RetrieveArtifacts(t *testing.T) {
 			SourceInformation: processor.SourceInformation{
 				Collector: string(FileCollector),
 				Source:    "file:///testdata/hello",
+				URI:       FileURI("testdata/hello"),
 			}},
 		},
 		wantErr: false,
@@ -79,6 +83,7 @@ func Test_fileCollector_RetrieveArtifacts(t *testing.T) {
 			SourceInformation: processor.SourceInformation{
 				Collector: string(FileCollector),
 				Source:    "file:///testdata/hello",
+				URI:       FileURI("testdata/hello"),
 			}},
 		},
 		wantErr: false,
@@ -86,7 +91,7 @@ func Test_fileCollector_RetrieveArtifacts(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			f := &fileCollector{
-				path:        tt.fields.path,
+				paths:       []string{tt.fields.path},
 				lastChecked: tt.fields.lastChecked,
 				poll:        tt.fields.poll,
 				interval:    tt.fields.interval,
@@ -141,3 +146,256 @@ func Test_fileCollector_RetrieveArtifacts(t *testing.T) {
 		})
 	}
 }
+
+func Test_fileCollector_Patterns(t *testing.T) {
+	root := t.TempDir()
+	other := t.TempDir()
+	files := map[string]string{
+		"a.json":          "a",
+		"b.txt":           "b",
+		"sub/c.json":      "c",
+		"sub/deep/d.json": "d",
+		"vendor/e.json":   "e",
+	}
+	for name, content := range files {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(other, "f.json"), []byte("f"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// a symlink back to the root must not make the walk loop
+	if err := os.Symlink(root, filepath.Join(root, "sub", "loop")); err != nil {
+		t.Fatal(err)
+	}
+	// an unreadable directory must not abort the walk
+	locked := filepath.Join(root, "locked")
+	if err := os.Mkdir(locked, 0o000); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chmod(locked, 0o755)
+
+	tests := []struct {
+		name    string
+		paths   []string
+		include []string
+		exclude []string
+		want    []string
+		wantErr bool
+	}{{
+		name:  "all files",
+		paths: []string{root},
+		want:  []string{"a", "b", "c", "d", "e"},
+	}, {
+		name:    "include top level only",
+		paths:   []string{root},
+		include: []string{"*.json"},
+		want:    []string{"a"},
+	}, {
+		name:    "include recursive",
+		paths:   []string{root},
+		include: []string{"**/*.json"},
+		want:    []string{"a", "c", "d", "e"},
+	}, {
+		name:    "exclude directory",
+		paths:   []string{root},
+		include: []string{"**/*.json"},
+		exclude: []string{"vendor", "sub/deep/**"},
+		want:    []string{"a", "c"},
+	}, {
+		name:    "multiple roots",
+		paths:   []string{filepath.Join(root, "sub"), other},
+		include: []string{"*.json"},
+		want:    []string{"c", "f"},
+	}, {
+		name:    "invalid pattern",
+		paths:   []string{root},
+		include: []string{"[a-"},
+		wantErr: true,
+	}, {
+		name:    "nonexistent root",
+		paths:   []string{root, filepath.Join(root, "doesnotexist")},
+		wantErr: true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := NewFileCollectorWithPatterns(context.Background(), tt.paths, tt.include, tt.exclude, false, 0)
+			docChan := make(chan *processor.Document, len(files)+1)
+			err := f.RetrieveArtifacts(context.Background(), docChan)
+			close(docChan)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("fileCollector.RetrieveArtifacts() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			got := []string{}
+			for d := range docChan {
+				got = append(got, string(d.Blob))
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("fileCollector.RetrieveArtifacts() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_fileCollector_FileList(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{"a.json": "a", "b.json": "b", "c.json": "c"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	list := filepath.Join(dir, "list.txt")
+	content := "# documents to ingest\n" +
+		filepath.Join(dir, "a.json") + "\n\n" +
+		"  " + filepath.Join(dir, "c.json") + "  \n" +
+		filepath.Join(dir, "doesnotexist.json") + "\n" +
+		dir + "\n"
+	if err := os.WriteFile(list, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		fileList string
+		want     []string
+		wantErr  bool
+	}{{
+		name:     "listed files",
+		fileList: list,
+		want:     []string{"a", "c"},
+	}, {
+		name:     "nonexistent file list",
+		fileList: filepath.Join(dir, "doesnotexist.txt"),
+		wantErr:  true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := NewFileListCollector(context.Background(), tt.fileList, false, 0)
+			docChan := make(chan *processor.Document, 4)
+			err := f.RetrieveArtifacts(context.Background(), docChan)
+			close(docChan)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("fileCollector.RetrieveArtifacts() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			got := []string{}
+			for d := range docChan {
+				got = append(got, string(d.Blob))
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("fileCollector.RetrieveArtifacts() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_matchPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{pattern: "*.json", name: "a.json", want: true},
+		{pattern: "*.json", name: "sub/a.json", want: false},
+		{pattern: "**/*.json", name: "a.json", want: true},
+		{pattern: "**/*.json", name: "sub/deep/a.json", want: true},
+		{pattern: "sub/**", name: "sub/deep/a.json", want: true},
+		{pattern: "sub/**/a.json", name: "sub/a.json", want: true},
+		{pattern: "sub/**/a.json", name: "other/a.json", want: false},
+		{pattern: "sub/*", name: "sub/deep/a.json", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.name, func(t *testing.T) {
+			if got := matchPattern(tt.pattern, tt.name); got != tt.want {
+				t.Errorf("matchPattern(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_fileCollector_Watch(t *testing.T) {
+	root := t.TempDir()
+	other := t.TempDir()
+	write := func(dir string, name string, content string) {
+		t.Helper()
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(root, "a.json", "a")
+	write(root, "vendor/v.json", "v")
+
+	f := NewFileWatchCollector(context.Background(), []string{root}, []string{"**/*.json"}, []string{"vendor"}, 20*time.Millisecond)
+	ctx, cancel := context.WithCancel(context.Background())
+	docChan := make(chan *processor.Document, 100)
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- f.RetrieveArtifacts(ctx, docChan)
+	}()
+
+	got := map[string]bool{}
+	waitFor := func(content string) {
+		t.Helper()
+		timeout := time.After(5 * time.Second)
+		for !got[content] {
+			select {
+			case d := <-docChan:
+				got[string(d.Blob)] = true
+			case <-timeout:
+				t.Fatalf("timed out waiting for %q, collected %v", content, got)
+			}
+		}
+	}
+	waitFor("a")
+
+	write(root, "b.json", "b")
+	write(root, "b.txt", "txt")
+	write(root, "vendor/w.json", "w")
+	waitFor("b")
+
+	// files of new directories are collected
+	write(root, "sub/deep/c.json", "c")
+	waitFor("c")
+	write(root, "sub/deep/d.json", "d")
+	waitFor("d")
+
+	// files moved in are collected whatever their modification time
+	write(other, "m.json", "m")
+	old := time.Date(2009, 11, 17, 20, 34, 58, 0, time.UTC)
+	if err := os.Chtimes(filepath.Join(other, "m.json"), old, old); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(filepath.Join(other, "m.json"), filepath.Join(root, "sub", "m.json")); err != nil {
+		t.Fatal(err)
+	}
+	waitFor("m")
+
+	cancel()
+	if err := <-errChan; err != nil {
+		t.Fatalf("fileCollector.RetrieveArtifacts() error = %v", err)
+	}
+	close(docChan)
+	for d := range docChan {
+		got[string(d.Blob)] = true
+	}
+	want := map[string]bool{"a": true, "b": true, "c": true, "d": true, "m": true}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("fileCollector.RetrieveArtifacts() = %v, want %v", got, want)
+	}
+}
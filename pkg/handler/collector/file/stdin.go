@@ -0,0 +1,78 @@
+//
+// Copyright 2022 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package file
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/guacsec/guac/pkg/handler/processor"
+)
+
+const (
+	StdinCollector = "StdinCollector"
+	// StdinPath is the path standing for standard input
+	StdinPath = "-"
+)
+
+type stdinCollector struct {
+	reader io.Reader
+}
+
+// NewStdinCollector returns a collector reading a single document from r,
+// usually os.Stdin. The type and format of the document are left for the
+// processor to guess, as there is no file name to go by.
+func NewStdinCollector(ctx context.Context, r io.Reader) *stdinCollector {
+	return &stdinCollector{
+		reader: r,
+	}
+}
+
+// RetrieveArtifacts reads the document until the end of the input and emits
+// it. It returns an error if the input is empty.
+func (s *stdinCollector) RetrieveArtifacts(ctx context.Context, docChannel chan<- *processor.Document) error {
+	blob, err := io.ReadAll(s.reader)
+	if err != nil {
+		return fmt.Errorf("unable to read document from stdin: %w", err)
+	}
+	if len(blob) == 0 {
+		return errors.New("no document read from stdin")
+	}
+
+	doc := &processor.Document{
+		Blob:   blob,
+		Type:   processor.DocumentUnknown,
+		Format: processor.FormatUnknown,
+		SourceInformation: processor.SourceInformation{
+			Collector: string(StdinCollector),
+			Source:    "stdin",
+			URI:       "stdin:",
+		},
+	}
+	select {
+	case docChannel <- doc:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return nil
+}
+
+// Type returns the collector type
+func (s *stdinCollector) Type() string {
+	return StdinCollector
+}
@@ -0,0 +1,72 @@
+//
+// Copyright 2022 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package file
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/guacsec/guac/pkg/handler/processor"
+)
+
+func Test_stdinCollector_RetrieveArtifacts(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []*processor.Document
+		wantErr bool
+	}{{
+		name:    "empty input",
+		input:   "",
+		want:    []*processor.Document{},
+		wantErr: true,
+	}, {
+		name:  "document",
+		input: `{"bomFormat": "CycloneDX"}`,
+		want: []*processor.Document{{
+			Blob:   []byte(`{"bomFormat": "CycloneDX"}`),
+			Type:   processor.DocumentUnknown,
+			Format: processor.FormatUnknown,
+			SourceInformation: processor.SourceInformation{
+				Collector: string(StdinCollector),
+				Source:    "stdin",
+				URI:       "stdin:",
+			},
+		}},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewStdinCollector(context.Background(), strings.NewReader(tt.input))
+			docChan := make(chan *processor.Document, 1)
+			err := s.RetrieveArtifacts(context.Background(), docChan)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("stdinCollector.RetrieveArtifacts() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			got := []*processor.Document{}
+			for len(docChan) > 0 {
+				got = append(got, <-docChan)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("stdinCollector.RetrieveArtifacts() = %v, want %v", got, tt.want)
+			}
+			if s.Type() != StdinCollector {
+				t.Errorf("stdinCollector.Type() = %s, want %s", s.Type(), StdinCollector)
+			}
+		})
+	}
+}
@@ -0,0 +1,191 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package file
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/guacsec/guac/pkg/handler/processor"
+	"github.com/guacsec/guac/pkg/logging"
+)
+
+// fileWatcher watches the directories of the roots of a collector
+type fileWatcher struct {
+	collector *fileCollector
+	watcher   *fsnotify.Watcher
+	// roots maps each watched directory to the root it is under
+	roots map[string]string
+	// pending maps the files changed to the time of their last change
+	pending map[string]time.Time
+}
+
+// watchRoots collects the files of the roots, then the files created or
+// written under them as they are reported, until ctx is cancelled
+func (f *fileCollector) watchRoots(ctx context.Context, docChannel chan<- *processor.Document) error {
+	logger := logging.FromContext(ctx)
+	if f.interval <= 0 {
+		return fmt.Errorf("invalid settle interval %v to watch files", f.interval)
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("unable to watch files: %w", err)
+	}
+	defer watcher.Close()
+
+	w := &fileWatcher{
+		collector: f,
+		watcher:   watcher,
+		roots:     map[string]string{},
+		pending:   map[string]time.Time{},
+	}
+	// the directories are watched before they are walked so that no file
+	// created in between is missed
+	for _, root := range f.paths {
+		if _, err := os.Stat(root); err != nil {
+			return fmt.Errorf("path: %s is invalid: %w", root, err)
+		}
+		w.add(ctx, root, root, map[string]bool{})
+	}
+	start := time.Now()
+	if err := f.walkRoots(ctx, docChannel); err != nil {
+		if errors.Is(err, ctx.Err()) {
+			return nil
+		}
+		return err
+	}
+	f.lastChecked = start
+
+	ticker := time.NewTicker(f.interval / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if event.Op&(fsnotify.Create|fsnotify.Write) == 0 {
+				continue
+			}
+			root, ok := w.roots[filepath.Dir(event.Name)]
+			if !ok {
+				continue
+			}
+			info, err := os.Stat(event.Name)
+			if err != nil {
+				continue
+			}
+			if info.IsDir() {
+				// the files of a directory created or moved in may be
+				// there before it is watched
+				w.add(ctx, root, event.Name, map[string]bool{})
+				wk := &walker{collector: f, root: root, docChannel: docChannel, visited: map[string]bool{}, all: true}
+				if err := wk.walk(ctx, event.Name); err != nil {
+					if errors.Is(err, ctx.Err()) {
+						return nil
+					}
+					return err
+				}
+				continue
+			}
+			w.pending[event.Name] = time.Now()
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			if !errors.Is(err, fsnotify.ErrEventOverflow) {
+				logger.Warnf("file watch error: %v", err)
+				continue
+			}
+			// changes were lost, look for them by modification time
+			logger.Warnf("file watch overflowed, walking the paths again")
+			start := time.Now()
+			if err := f.walkRoots(ctx, docChannel); err != nil {
+				if errors.Is(err, ctx.Err()) {
+					return nil
+				}
+				return err
+			}
+			f.lastChecked = start
+		case now := <-ticker.C:
+			if err := w.collectSettled(ctx, now, docChannel); err != nil {
+				if errors.Is(err, ctx.Err()) {
+					return nil
+				}
+				return err
+			}
+		}
+	}
+}
+
+// add watches the directory p under root and its subdirectories that are
+// not excluded
+func (w *fileWatcher) add(ctx context.Context, root string, p string, visited map[string]bool) {
+	logger := logging.FromContext(ctx)
+	info, err := os.Stat(p)
+	if err != nil || !info.IsDir() {
+		return
+	}
+	rel, err := filepath.Rel(root, p)
+	if err != nil {
+		return
+	}
+	if rel = filepath.ToSlash(rel); rel != "." && w.collector.matchAny(w.collector.exclude, rel) {
+		return
+	}
+	real, err := filepath.EvalSymlinks(p)
+	if err != nil || visited[real] {
+		return
+	}
+	visited[real] = true
+
+	if err := w.watcher.Add(p); err != nil {
+		logger.Warnf("unable to watch directory: %s: %v", p, err)
+		return
+	}
+	w.roots[filepath.Clean(p)] = root
+	entries, _ := os.ReadDir(p)
+	for _, entry := range entries {
+		w.add(ctx, root, filepath.Join(p, entry.Name()), visited)
+	}
+}
+
+// collectSettled collects the pending files left unchanged since the
+// settle interval
+func (w *fileWatcher) collectSettled(ctx context.Context, now time.Time, docChannel chan<- *processor.Document) error {
+	for p, changed := range w.pending {
+		if now.Sub(changed) < w.collector.interval {
+			continue
+		}
+		delete(w.pending, p)
+		root, ok := w.roots[filepath.Dir(p)]
+		if !ok {
+			continue
+		}
+		wk := &walker{collector: w.collector, root: root, docChannel: docChannel, visited: map[string]bool{}, all: true}
+		if err := wk.walk(ctx, p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -21,25 +21,41 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
+	"cloud.google.com/go/pubsub"
 	"cloud.google.com/go/storage"
 	"google.golang.org/api/iterator"
 	"google.golang.org/api/option"
 
+	"github.com/guacsec/guac/pkg/handler/collector/retry"
 	"github.com/guacsec/guac/pkg/handler/processor"
 	"github.com/guacsec/guac/pkg/logging"
 )
 
 type gcs struct {
-	bucket       string
+	bucket string
+	// prefix of the objects to collect from notifications
+	prefix       string
 	reader       gcsReader
 	lastDownload time.Time
-	poll         bool
-	interval     time.Duration
+	// generations of the objects already collected, by object name
+	generations map[string]int64
+	poll        bool
+	interval    time.Duration
+	// notifications of the bucket, if collecting objects as they change
+	notifications notificationReceiver
+	// mu serializes the handling of notifications, received concurrently
+	mu sync.Mutex
 }
 
 const (
+	// objectFinalizeEvent is the event type of the notifications of objects
+	// created or overwritten
+	objectFinalizeEvent = "OBJECT_FINALIZE"
 	// gcsCredsEnv is the env variable to hold the json creds file
 	gcsCredsEnv = "GOOGLE_APPLICATION_CREDENTIALS"
 	// Specify the GCS bucket address
@@ -84,6 +100,97 @@ func NewGCSClient(ctx context.Context, poll bool, interval time.Duration) (*gcs,
 	return gstore, nil
 }
 
+// Option configures the collector returned by NewGCSCollector
+type Option func(*reader)
+
+// WithUserProject bills the requests to project, which is required to read
+// from requester pays buckets
+func WithUserProject(project string) Option {
+	return func(r *reader) {
+		r.userProject = project
+	}
+}
+
+// WithNotifications collects the objects as they are created or overwritten,
+// from the Pub/Sub notifications of the bucket received on subscription,
+// given as projects/<project>/subscriptions/<subscription>. The
+// notifications must use the JSON_API_V1 or NONE payload format.
+func WithNotifications(subscription string) Option {
+	return func(r *reader) {
+		r.subscription = subscription
+	}
+}
+
+// NewGCSCollector returns a collector for the objects of bucket whose name
+// starts with prefix, authenticating with the application default
+// credentials. With a non zero pollInterval, the bucket is polled and only
+// objects that are new or have a new generation since the last poll are
+// collected. With WithNotifications, the objects are collected once, then as
+// their notifications are received.
+func NewGCSCollector(ctx context.Context, bucket string, prefix string, pollInterval time.Duration, opts ...Option) (*gcs, error) {
+	if bucket == "" {
+		return nil, errors.New("gcs bucket not specified")
+	}
+	client, err := storage.NewClient(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create gcs client with application default credentials: %w", err)
+	}
+	r := &reader{client: client, bucket: bucket, prefix: prefix}
+	for _, opt := range opts {
+		opt(r)
+	}
+	g := &gcs{
+		bucket:   bucket,
+		prefix:   prefix,
+		reader:   r,
+		poll:     pollInterval > 0,
+		interval: pollInterval,
+	}
+	if r.subscription != "" {
+		if g.poll {
+			return nil, errors.New("gcs notifications cannot be used with polling")
+		}
+		g.notifications, err = newSubscriptionReceiver(ctx, r.subscription)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return g, nil
+}
+
+// notificationReceiver receives the attributes of the notifications of a
+// bucket, until ctx is done. A notification is acknowledged if handle
+// succeeds, and delivered again later otherwise.
+type notificationReceiver interface {
+	receive(ctx context.Context, handle func(attrs map[string]string) error) error
+}
+
+type subscriptionReceiver struct {
+	sub *pubsub.Subscription
+}
+
+func newSubscriptionReceiver(ctx context.Context, subscription string) (*subscriptionReceiver, error) {
+	parts := strings.Split(subscription, "/")
+	if len(parts) != 4 || parts[0] != "projects" || parts[2] != "subscriptions" || parts[1] == "" || parts[3] == "" {
+		return nil, fmt.Errorf("invalid subscription %q, expected projects/<project>/subscriptions/<subscription>", subscription)
+	}
+	client, err := pubsub.NewClient(ctx, parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("failed to create pubsub client with application default credentials: %w", err)
+	}
+	return &subscriptionReceiver{sub: client.Subscription(parts[3])}, nil
+}
+
+func (s *subscriptionReceiver) receive(ctx context.Context, handle func(attrs map[string]string) error) error {
+	return s.sub.Receive(ctx, func(ctx context.Context, msg *pubsub.Message) {
+		if err := handle(msg.Attributes); err != nil {
+			msg.Nack()
+			return
+		}
+		msg.Ack()
+	})
+}
+
 // Type is the collector type of the collector
 func (g *gcs) Type() string {
 	return CollectorGCS
@@ -97,22 +204,37 @@ type gcsReader interface {
 type reader struct {
 	client *storage.Client
 	bucket string
+	// prefix of the objects to list
+	prefix string
+	// project billed for requester pays buckets
+	userProject string
+	// subscription to the notifications of the bucket
+	subscription string
+}
+
+func (r *reader) bucketHandle() *storage.BucketHandle {
+	b := r.client.Bucket(r.bucket)
+	if r.userProject != "" {
+		b = b.UserProject(r.userProject)
+	}
+	return b
 }
 
 func (r *reader) getIterator(ctx context.Context) (*storage.ObjectIterator, error) {
 	q := &storage.Query{
+		Prefix:     r.prefix,
 		Projection: storage.ProjectionNoACL,
 	}
-	// set query to return only the Name and Updated attributes
-	err := q.SetAttrSelection([]string{"Name", "Updated"})
+	// set query to return only the Name, Updated and Generation attributes
+	err := q.SetAttrSelection([]string{"Name", "Updated", "Generation"})
 	if err != nil {
 		return nil, err
 	}
-	return r.client.Bucket(r.bucket).Objects(ctx, q), nil
+	return r.bucketHandle().Objects(ctx, q), nil
 }
 
 func (r *reader) getReader(ctx context.Context, object string) (io.ReadCloser, error) {
-	return r.client.Bucket(r.bucket).Object(object).NewReader(ctx)
+	return r.bucketHandle().Object(object).NewReader(ctx)
 }
 
 // RetrieveArtifacts get the artifacts from the collector source based on polling or one time
@@ -121,9 +243,23 @@ func (g *gcs) RetrieveArtifacts(ctx context.Context, docChannel chan<- *processo
 	if g.reader == nil {
 		return errors.New("gcs not initialized")
 	}
+	if g.notifications != nil {
+		// the objects created before the notifications are received
+		if err := g.getArtifacts(ctx, docChannel); err != nil {
+			return err
+		}
+		g.lastDownload = time.Now()
+		return g.notifications.receive(ctx, func(attrs map[string]string) error {
+			return g.handleNotification(ctx, attrs, docChannel)
+		})
+	}
 	if g.poll {
 		for {
-			time.Sleep(g.interval)
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(g.interval):
+			}
 			err := g.getArtifacts(ctx, docChannel)
 			if err != nil {
 				return err
@@ -154,36 +290,105 @@ func (g *gcs) getArtifacts(ctx context.Context, docChannel chan<- *processor.Doc
 		if err != nil {
 			return fmt.Errorf("failed to retrieve object attribute from bucket: %s, error: %w", g.bucket, err)
 		}
-		payload := []byte{}
-		if g.lastDownload.IsZero() {
-			payload, err = g.getObject(ctx, attrs.Name)
-			if err != nil {
-				logger.Warnf("failed to retrieve object: %s from bucket: %s", attrs.Name, g.bucket)
-				continue
-			}
-		} else if attrs.Updated.After(g.lastDownload) {
-			payload, err = g.getObject(ctx, attrs.Name)
-			if err != nil {
-				logger.Warnf("failed to retrieve object: %s from bucket: %s", attrs.Name, g.bucket)
-				continue
+		if !g.changed(attrs) {
+			continue
+		}
+		payload, err := retry.ReadDocument(ctx, "gs://"+g.bucket+"/"+attrs.Name, func() ([]byte, error) {
+			payload, err := g.getObject(ctx, attrs.Name)
+			if errors.Is(err, storage.ErrObjectNotExist) {
+				return nil, retry.Permanent(err)
 			}
+			return payload, err
+		})
+		if err != nil {
+			logger.Warnf("failed to retrieve object: %s from bucket: %s: %v", attrs.Name, g.bucket, err)
+			continue
 		}
+		g.generations[attrs.Name] = attrs.Generation
 		if len(payload) > 0 {
-			doc := &processor.Document{
-				Blob:   payload,
-				Type:   processor.DocumentUnknown,
-				Format: processor.FormatUnknown,
-				SourceInformation: processor.SourceInformation{
-					Collector: string(CollectorGCS),
-					Source:    g.bucket + "/" + attrs.Name,
-				},
-			}
-			docChannel <- doc
+			docChannel <- g.document(attrs.Name, payload)
 		}
 	}
 	return nil
 }
 
+// handleNotification collects the object of a notification of the bucket,
+// if it was created or overwritten with a generation not collected yet. An
+// error is returned for the notification to be delivered again.
+func (g *gcs) handleNotification(ctx context.Context, attrs map[string]string, docChannel chan<- *processor.Document) error {
+	logger := logging.FromContext(ctx)
+	name := attrs["objectId"]
+	if attrs["eventType"] != objectFinalizeEvent || attrs["bucketId"] != g.bucket || !strings.HasPrefix(name, g.prefix) {
+		return nil
+	}
+	generation, err := strconv.ParseInt(attrs["objectGeneration"], 10, 64)
+	if err != nil {
+		logger.Warnf("skipping notification of object: %s with invalid generation: %v", name, err)
+		return nil
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.generations == nil {
+		g.generations = map[string]int64{}
+	}
+	// notifications may be delivered more than once and out of order
+	if collected, ok := g.generations[name]; ok && collected >= generation {
+		return nil
+	}
+	deleted := false
+	payload, err := retry.ReadDocument(ctx, "gs://"+g.bucket+"/"+name, func() ([]byte, error) {
+		payload, err := g.getObject(ctx, name)
+		if errors.Is(err, storage.ErrObjectNotExist) {
+			deleted = true
+			return nil, retry.Permanent(err)
+		}
+		return payload, err
+	})
+	if deleted {
+		// deleted since, a later notification will have the new generation if any
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	g.generations[name] = generation
+	if len(payload) > 0 {
+		docChannel <- g.document(name, payload)
+	}
+	return nil
+}
+
+func (g *gcs) document(name string, payload []byte) *processor.Document {
+	return &processor.Document{
+		Blob:   payload,
+		Type:   processor.DocumentUnknown,
+		Format: processor.FormatUnknown,
+		SourceInformation: processor.SourceInformation{
+			Collector: string(CollectorGCS),
+			Source:    g.bucket + "/" + name,
+			URI:       "gs://" + g.bucket + "/" + name,
+		},
+	}
+}
+
+// changed reports whether the object is new or has a new generation since it
+// was collected. Objects not collected by this collector yet are considered
+// changed if they were updated after the last download.
+func (g *gcs) changed(attrs *storage.ObjectAttrs) bool {
+	if g.generations == nil {
+		g.generations = map[string]int64{}
+	}
+	if generation, ok := g.generations[attrs.Name]; ok {
+		return generation != attrs.Generation
+	}
+	if !g.lastDownload.IsZero() && !attrs.Updated.After(g.lastDownload) {
+		g.generations[attrs.Name] = attrs.Generation
+		return false
+	}
+	return true
+}
+
 func (g *gcs) getObject(ctx context.Context, object string) ([]byte, error) {
 	reader, err := g.reader.getReader(ctx, object)
 	if err != nil {
@@ -17,6 +17,7 @@ package gcs
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"reflect"
 	"testing"
@@ -49,6 +50,7 @@ func TestGCS_RetrieveArtifacts(t *testing.T) {
 		SourceInformation: processor.SourceInformation{
 			Collector: string(CollectorGCS),
 			Source:    getBucketPath() + "/some/object/file.txt",
+			URI:       "gs://" + getBucketPath() + "/some/object/file.txt",
 		},
 	}
 
@@ -139,3 +141,162 @@ func TestGCS_RetrieveArtifacts(t *testing.T) {
 		})
 	}
 }
+
+func TestGCS_generations(t *testing.T) {
+	ctx := context.Background()
+	server := fakestorage.NewServer([]fakestorage.Object{
+		{
+			ObjectAttrs: fakestorage.ObjectAttrs{BucketName: "some-bucket", Name: "docs/sbom.json"},
+			Content:     []byte("first"),
+		},
+		{
+			ObjectAttrs: fakestorage.ObjectAttrs{BucketName: "some-bucket", Name: "other/sbom.json"},
+			Content:     []byte("other"),
+		},
+	})
+	defer server.Stop()
+
+	g := &gcs{
+		bucket: "some-bucket",
+		reader: &reader{client: server.Client(), bucket: "some-bucket", prefix: "docs/"},
+	}
+	collect := func() []string {
+		docChan := make(chan *processor.Document, 10)
+		if err := g.getArtifacts(ctx, docChan); err != nil {
+			t.Fatalf("g.getArtifacts() error = %v", err)
+		}
+		close(docChan)
+		got := []string{}
+		for d := range docChan {
+			got = append(got, d.SourceInformation.Source+"="+string(d.Blob))
+		}
+		return got
+	}
+
+	if got, want := collect(), []string{"some-bucket/docs/sbom.json=first"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("first poll = %v, want %v", got, want)
+	}
+	if got := collect(); len(got) != 0 {
+		t.Errorf("poll without changes = %v, want none", got)
+	}
+	server.CreateObject(fakestorage.Object{
+		ObjectAttrs: fakestorage.ObjectAttrs{BucketName: "some-bucket", Name: "docs/sbom.json"},
+		Content:     []byte("second"),
+	})
+	if got, want := collect(), []string{"some-bucket/docs/sbom.json=second"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("poll after new generation = %v, want %v", got, want)
+	}
+}
+
+type fakeReceiver struct {
+	notifications []map[string]string
+	// acked is whether each notification was acknowledged
+	acked []bool
+}
+
+func (f *fakeReceiver) receive(ctx context.Context, handle func(attrs map[string]string) error) error {
+	for _, attrs := range f.notifications {
+		f.acked = append(f.acked, handle(attrs) == nil)
+	}
+	return nil
+}
+
+func TestGCS_notifications(t *testing.T) {
+	ctx := context.Background()
+	server := fakestorage.NewServer([]fakestorage.Object{
+		{
+			ObjectAttrs: fakestorage.ObjectAttrs{BucketName: "some-bucket", Name: "docs/old.json"},
+			Content:     []byte("old"),
+		},
+	})
+	defer server.Stop()
+
+	g := &gcs{
+		bucket: "some-bucket",
+		prefix: "docs/",
+		reader: &reader{client: server.Client(), bucket: "some-bucket", prefix: "docs/"},
+	}
+	// the object created once subscribed, as notified
+	server.CreateObject(fakestorage.Object{
+		ObjectAttrs: fakestorage.ObjectAttrs{BucketName: "some-bucket", Name: "docs/new.json"},
+		Content:     []byte("new"),
+	})
+	created, err := server.GetObject("some-bucket", "docs/new.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	generation := fmt.Sprint(created.Generation)
+	notification := func(eventType string, bucket string, name string, generation string) map[string]string {
+		return map[string]string{
+			"eventType":        eventType,
+			"bucketId":         bucket,
+			"objectId":         name,
+			"objectGeneration": generation,
+		}
+	}
+	receiver := &fakeReceiver{notifications: []map[string]string{
+		notification(objectFinalizeEvent, "some-bucket", "docs/new.json", generation),
+		// delivered again
+		notification(objectFinalizeEvent, "some-bucket", "docs/new.json", generation),
+		notification("OBJECT_DELETE", "some-bucket", "docs/new.json", generation),
+		notification(objectFinalizeEvent, "some-bucket", "other/sbom.json", "1"),
+		notification(objectFinalizeEvent, "other-bucket", "docs/new.json", generation),
+		notification(objectFinalizeEvent, "some-bucket", "docs/deleted.json", "1"),
+	}}
+	g.notifications = receiver
+
+	docChan := make(chan *processor.Document, 10)
+	if err := g.RetrieveArtifacts(ctx, docChan); err != nil {
+		t.Fatalf("g.RetrieveArtifacts() error = %v", err)
+	}
+	close(docChan)
+	got := []string{}
+	for d := range docChan {
+		got = append(got, d.SourceInformation.Source+"="+string(d.Blob))
+	}
+	// docs/new.json is collected by the initial listing, not again on its notification
+	if want := []string{"some-bucket/docs/new.json=new", "some-bucket/docs/old.json=old"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("collected = %v, want %v", got, want)
+	}
+	if want := []bool{true, true, true, true, true, true}; !reflect.DeepEqual(receiver.acked, want) {
+		t.Errorf("acked = %v, want %v", receiver.acked, want)
+	}
+}
+
+func TestGCS_handleNotification(t *testing.T) {
+	ctx := context.Background()
+	server := fakestorage.NewServer([]fakestorage.Object{
+		{
+			ObjectAttrs: fakestorage.ObjectAttrs{BucketName: "some-bucket", Name: "docs/sbom.json"},
+			Content:     []byte("first"),
+		},
+	})
+	defer server.Stop()
+	object, err := server.GetObject("some-bucket", "docs/sbom.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := &gcs{
+		bucket: "some-bucket",
+		reader: &reader{client: server.Client(), bucket: "some-bucket"},
+	}
+	docChan := make(chan *processor.Document, 10)
+	attrs := map[string]string{
+		"eventType":        objectFinalizeEvent,
+		"bucketId":         "some-bucket",
+		"objectId":         "docs/sbom.json",
+		"objectGeneration": fmt.Sprint(object.Generation),
+	}
+	for i := 0; i < 2; i++ {
+		if err := g.handleNotification(ctx, attrs, docChan); err != nil {
+			t.Fatalf("g.handleNotification() error = %v", err)
+		}
+	}
+	if len(docChan) != 1 {
+		t.Fatalf("g.handleNotification() collected %d documents, want 1", len(docChan))
+	}
+	if d := <-docChan; !reflect.DeepEqual(d, g.document("docs/sbom.json", []byte("first"))) {
+		t.Errorf("g.handleNotification() = %v", d)
+	}
+}
@@ -17,10 +17,12 @@ package git_collector
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"time"
 
 	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/guacsec/guac/pkg/handler/collector"
 	"github.com/guacsec/guac/pkg/handler/collector/file"
 	"github.com/guacsec/guac/pkg/handler/processor"
@@ -32,29 +34,74 @@ const (
 	CollectorGitDocument = "GitCollector"
 )
 
+// DefaultPatterns match the files of a repository commonly holding SBOMs and
+// attestations, relative to the root of the repository
+var DefaultPatterns = []string{
+	"**/.sbom/**",
+	"**/*.spdx",
+	"**/*.spdx.json",
+	"**/*.cdx.json",
+	"**/*.bom.json",
+	"**/sbom.json",
+	"**/bom.json",
+	"**/*.intoto.jsonl",
+	"**/*provenance*.json",
+}
+
 // gitDocumentCollector collects documents from a Git repository (GitHub, GitLab, etc.)
 // The collector clones the repository to a local directory or pulls any updates from the repository if it has been cloned previously.
 // It emits each collected document to the collector to be processed.
 // The collector can either run once and grab all the artifacts or keep running and check for new artifacts based on the polling rate.
 type gitDocumentCollector struct {
-	url           string
-	dir           string
+	url string
+	dir string
+	// branch to track, the default branch of the remote if empty
+	branch string
+	// patterns of the files to collect, relative to the root of the
+	// repository, all files if empty
+	patterns      []string
 	lastChecked   time.Time
 	poll          bool
 	interval      time.Duration
 	fileCollector collector.Collector
 }
 
-func NewGitDocumentCollector(ctx context.Context, url string, dir string, poll bool, interval time.Duration) *gitDocumentCollector {
-	fileCollector := file.NewFileCollector(ctx, dir, false, time.Second)
+// Option configures the collector returned by NewGitDocumentCollector
+type Option func(*gitDocumentCollector)
+
+// WithBranch tracks branch instead of the default branch of the remote
+func WithBranch(branch string) Option {
+	return func(g *gitDocumentCollector) {
+		g.branch = branch
+	}
+}
+
+// WithPatterns only collects the files whose path relative to the root of the
+// repository matches one of patterns, using the syntax of
+// file.NewFileCollectorWithPatterns, e.g. DefaultPatterns
+func WithPatterns(patterns []string) Option {
+	return func(g *gitDocumentCollector) {
+		g.patterns = patterns
+	}
+}
 
-	return &gitDocumentCollector{
-		url:           url,
-		dir:           dir,
-		poll:          poll,
-		interval:      interval,
-		fileCollector: fileCollector,
+// NewGitDocumentCollector returns a collector for the files of the repository
+// at url, cloned to dir. When polling, the new commits are pulled and the
+// files they changed collected.
+func NewGitDocumentCollector(ctx context.Context, url string, dir string, poll bool, interval time.Duration, opts ...Option) *gitDocumentCollector {
+	g := &gitDocumentCollector{
+		url:      url,
+		dir:      dir,
+		poll:     poll,
+		interval: interval,
 	}
+	for _, opt := range opts {
+		opt(g)
+	}
+	// the files collector only collects the files modified since its last
+	// walk, which are those the pulled commits changed
+	g.fileCollector = file.NewFileCollectorWithPatterns(ctx, []string{dir}, g.patterns, nil, false, time.Second)
+	return g
 }
 
 // RetrieveArtifacts collects the documents from the collector. It emits each collected
@@ -99,7 +146,7 @@ func (g *gitDocumentCollector) createOrPull(ctx context.Context, logger *zap.Sug
 		if err := os.Mkdir(g.dir, os.ModePerm); err != nil {
 			return err
 		}
-		err := cloneRepoToDir(logger, g.url, g.dir)
+		err := cloneRepoToDir(logger, g.url, g.dir, g.branch)
 		if err != nil {
 			return err
 		}
@@ -108,7 +155,7 @@ func (g *gitDocumentCollector) createOrPull(ctx context.Context, logger *zap.Sug
 			return err
 		}
 	} else {
-		err := pullRepo(logger, g.dir)
+		err := pullRepo(logger, g.dir, g.branch)
 		if err != nil && err != git.NoErrAlreadyUpToDate {
 			return err
 		} else if err == nil {
@@ -137,15 +184,20 @@ func checkIfDirExists(name string) (bool, error) {
 	return true, nil
 }
 
-func cloneRepoToDir(logger *zap.SugaredLogger, url string, directory string) error {
+func cloneRepoToDir(logger *zap.SugaredLogger, url string, directory string, branch string) error {
 
 	// Clone to directory
 	logger.Debugf("git clone %s %s --recursive", url, directory)
 
-	r, err := git.PlainClone(directory, false, &git.CloneOptions{
+	opts := &git.CloneOptions{
 		URL:               url,
 		RecurseSubmodules: git.DefaultSubmoduleRecursionDepth,
-	})
+	}
+	if branch != "" {
+		opts.ReferenceName = plumbing.NewBranchReferenceName(branch)
+		opts.SingleBranch = true
+	}
+	r, err := git.PlainClone(directory, false, opts)
 	if err != nil {
 		return err
 	}
@@ -165,7 +217,7 @@ func cloneRepoToDir(logger *zap.SugaredLogger, url string, directory string) err
 	return nil
 }
 
-func pullRepo(logger *zap.SugaredLogger, directory string) error {
+func pullRepo(logger *zap.SugaredLogger, directory string, branch string) error {
 	// We instantiate a new repository targeting the given path (the .git folder)
 	r, err := git.PlainOpen(directory)
 	if err != nil {
@@ -177,11 +229,30 @@ func pullRepo(logger *zap.SugaredLogger, directory string) error {
 		return err
 	}
 
+	opts := &git.PullOptions{RemoteName: "origin"}
+	if branch != "" {
+		// pulling merges into the current branch, which must be the tracked one
+		head, err := r.Head()
+		if err != nil {
+			return err
+		}
+		opts.ReferenceName = plumbing.NewBranchReferenceName(branch)
+		opts.SingleBranch = true
+		if head.Name() != opts.ReferenceName {
+			return fmt.Errorf("%s has %s checked out instead of branch %s", directory, head.Name().Short(), branch)
+		}
+	}
+
 	// Pull the latest changes from the origin remote and merge into the current branch
-	err = w.Pull(&git.PullOptions{RemoteName: "origin"})
+	err = w.Pull(opts)
 	if err != nil {
 		return err
 	}
 
+	head, err := r.Head()
+	if err != nil {
+		return err
+	}
+	logger.Debugf("pulled %s to commit %s", directory, head.Hash())
 	return nil
 }
@@ -18,9 +18,13 @@ package git_collector
 import (
 	"context"
 	"os"
+	"path/filepath"
+	"reflect"
 	"testing"
 	"time"
 
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
 	"github.com/guacsec/guac/pkg/handler/processor"
 	"github.com/guacsec/guac/pkg/logging"
 )
@@ -84,7 +88,7 @@ func Test_gitCol_RetrieveArtifacts(t *testing.T) {
 				if err := os.Mkdir(tt.fields.dir, os.ModePerm); err != nil {
 					t.Fatal(err)
 				}
-				err := cloneRepoToDir(logger, tt.fields.url, tt.fields.dir)
+				err := cloneRepoToDir(logger, tt.fields.url, tt.fields.dir, "")
 				if err != nil {
 					t.Fatal(err)
 				}
@@ -138,3 +142,87 @@ func Test_gitCol_RetrieveArtifacts(t *testing.T) {
 		})
 	}
 }
+
+// commitFiles writes the files to the worktree of the repository at dir and
+// commits them
+func commitFiles(t *testing.T, r *git.Repository, dir string, files map[string]string) {
+	t.Helper()
+	w, err := r.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Add(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err = w.Commit("update", &git.CommitOptions{
+		Author: &object.Signature{Name: "guac", Email: "guac@example.com", When: time.Now()},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_gitCol_branchAndPatterns(t *testing.T) {
+	ctx := logging.WithLogger(context.Background())
+	src := t.TempDir()
+	r, err := git.PlainInit(src, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	commitFiles(t, r, src, map[string]string{
+		"README.md":           "readme",
+		"sboms/app.spdx.json": "app",
+	})
+	head, err := r.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	branch := head.Name().Short()
+
+	dir := filepath.Join(t.TempDir(), "clone")
+	g := NewGitDocumentCollector(ctx, src, dir, false, 0, WithBranch(branch), WithPatterns(DefaultPatterns))
+	collect := func() []string {
+		docChan := make(chan *processor.Document, 10)
+		if err := g.RetrieveArtifacts(ctx, docChan); err != nil {
+			t.Fatalf("g.RetrieveArtifacts() error = %v", err)
+		}
+		close(docChan)
+		got := []string{}
+		for d := range docChan {
+			got = append(got, string(d.Blob))
+		}
+		return got
+	}
+
+	if got, want := collect(), []string{"app"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("collected after clone = %v, want %v", got, want)
+	}
+	// make sure the pulled files are modified after the last collection
+	time.Sleep(10 * time.Millisecond)
+	commitFiles(t, r, src, map[string]string{
+		"README.md":                   "new readme",
+		"lib/.sbom/lib.cdx.json":      "lib",
+		"lib/build.provenance.json":   "provenance",
+		"lib/vendor/not-an-sbom.json": "other",
+	})
+	if got, want := collect(), []string{"lib", "provenance"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("collected after new commit = %v, want %v", got, want)
+	}
+	if got := collect(); len(got) != 0 {
+		t.Errorf("collected when up to date = %v, want none", got)
+	}
+
+	other := NewGitDocumentCollector(ctx, src, dir, false, 0, WithBranch("other"))
+	if err := other.RetrieveArtifacts(ctx, make(chan *processor.Document, 10)); err == nil {
+		t.Errorf("g.RetrieveArtifacts() of another branch than the one checked out expected error")
+	}
+}
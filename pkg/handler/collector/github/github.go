@@ -0,0 +1,258 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package github
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	gh "github.com/google/go-github/v45/github"
+	"golang.org/x/oauth2"
+
+	"github.com/guacsec/guac/pkg/handler/collector/retry"
+	"github.com/guacsec/guac/pkg/handler/processor"
+	"github.com/guacsec/guac/pkg/logging"
+)
+
+const (
+	CollectorGitHubRelease = "GITHUB_RELEASE"
+	// githubTokenEnv is the env variable to hold the token authenticating to
+	// the GitHub API, to read private repositories and for a higher rate limit
+	githubTokenEnv = "GITHUB_TOKEN"
+	// releasesPerPage is the number of releases listed per request
+	releasesPerPage = 100
+)
+
+// DefaultAssetPatterns match the release assets commonly holding SBOMs and
+// attestations
+var DefaultAssetPatterns = []string{
+	"*.spdx",
+	"*.spdx.json",
+	"*.cdx.json",
+	"*.cdx.xml",
+	"*.bom.json",
+	"*sbom*.json",
+	"*.intoto.jsonl",
+}
+
+type releaseCollector struct {
+	client *gh.Client
+	// repositories to collect, as owner/repo
+	repos []string
+	// patterns of the names of the assets to collect
+	patterns   []string
+	latestOnly bool
+	// ids of the assets already collected
+	collected map[int64]bool
+	poll      bool
+	interval  time.Duration
+}
+
+// Option configures the collector returned by NewReleaseCollector
+type Option func(*releaseCollector)
+
+// WithLatestOnly only collects the assets of the latest release of each
+// repository, instead of those of all its releases
+func WithLatestOnly() Option {
+	return func(r *releaseCollector) {
+		r.latestOnly = true
+	}
+}
+
+// NewReleaseCollector returns a collector for the assets of the releases of
+// repos, given as owner/repo, whose name matches one of patterns, or
+// DefaultAssetPatterns if there are none. Patterns use the path.Match syntax,
+// e.g. `*.spdx.json`. The GitHub API is authenticated with GITHUB_TOKEN if
+// set. With a non zero pollInterval, the releases are polled and only assets
+// not collected yet are collected.
+func NewReleaseCollector(ctx context.Context, repos []string, patterns []string, pollInterval time.Duration, opts ...Option) (*releaseCollector, error) {
+	if len(repos) == 0 {
+		return nil, errors.New("github repositories not specified")
+	}
+	for _, repo := range repos {
+		if _, _, err := splitRepo(repo); err != nil {
+			return nil, err
+		}
+	}
+	if len(patterns) == 0 {
+		patterns = DefaultAssetPatterns
+	}
+	for _, p := range patterns {
+		if _, err := path.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("invalid asset pattern %q: %w", p, err)
+		}
+	}
+	var httpClient *http.Client
+	if token := os.Getenv(githubTokenEnv); token != "" {
+		httpClient = oauth2.NewClient(ctx, oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token}))
+	}
+	r := &releaseCollector{
+		client:   gh.NewClient(httpClient),
+		repos:    repos,
+		patterns: patterns,
+		poll:     pollInterval > 0,
+		interval: pollInterval,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r, nil
+}
+
+// splitRepo returns the owner and name of repo, given as owner/repo
+func splitRepo(repo string) (string, string, error) {
+	owner, name, ok := strings.Cut(repo, "/")
+	if !ok || owner == "" || name == "" || strings.Contains(name, "/") {
+		return "", "", fmt.Errorf("invalid github repository %q, expected owner/repo", repo)
+	}
+	return owner, name, nil
+}
+
+// Type is the collector type of the collector
+func (r *releaseCollector) Type() string {
+	return CollectorGitHubRelease
+}
+
+// RetrieveArtifacts get the artifacts from the collector source based on polling or one time
+func (r *releaseCollector) RetrieveArtifacts(ctx context.Context, docChannel chan<- *processor.Document) error {
+	if r.client == nil {
+		return errors.New("github release collector not initialized")
+	}
+	if err := r.getArtifacts(ctx, docChannel); err != nil {
+		return err
+	}
+	if !r.poll {
+		return nil
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(r.interval):
+		}
+		if err := r.getArtifacts(ctx, docChannel); err != nil {
+			return err
+		}
+	}
+}
+
+func (r *releaseCollector) getArtifacts(ctx context.Context, docChannel chan<- *processor.Document) error {
+	if r.collected == nil {
+		r.collected = map[int64]bool{}
+	}
+	for _, repo := range r.repos {
+		owner, name, err := splitRepo(repo)
+		if err != nil {
+			return err
+		}
+		releases, err := r.listReleases(ctx, owner, name)
+		if err != nil {
+			return fmt.Errorf("failed to list releases of %s: %w", repo, err)
+		}
+		for _, release := range releases {
+			r.collectAssets(ctx, owner, name, release, docChannel)
+		}
+	}
+	return nil
+}
+
+// listReleases returns the releases of the repository, or only its latest
+// release with WithLatestOnly
+func (r *releaseCollector) listReleases(ctx context.Context, owner string, name string) ([]*gh.RepositoryRelease, error) {
+	if r.latestOnly {
+		release, resp, err := r.client.Repositories.GetLatestRelease(ctx, owner, name)
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			// no release yet
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		return []*gh.RepositoryRelease{release}, nil
+	}
+	releases := []*gh.RepositoryRelease{}
+	opts := &gh.ListOptions{PerPage: releasesPerPage}
+	for {
+		page, resp, err := r.client.Repositories.ListReleases(ctx, owner, name, opts)
+		if err != nil {
+			return nil, err
+		}
+		releases = append(releases, page...)
+		if resp.NextPage == 0 {
+			return releases, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+func (r *releaseCollector) collectAssets(ctx context.Context, owner string, name string, release *gh.RepositoryRelease, docChannel chan<- *processor.Document) {
+	logger := logging.FromContext(ctx)
+	for _, asset := range release.Assets {
+		if r.collected[asset.GetID()] || !r.matches(asset.GetName()) {
+			continue
+		}
+		source := fmt.Sprintf("%s/%s@%s/%s", owner, name, release.GetTagName(), asset.GetName())
+		payload, err := retry.ReadDocument(ctx, asset.GetBrowserDownloadURL(), func() ([]byte, error) {
+			return r.downloadAsset(ctx, owner, name, asset.GetID())
+		})
+		if err != nil {
+			logger.Warnf("failed to download release asset: %s: %v", source, err)
+			continue
+		}
+		r.collected[asset.GetID()] = true
+		if len(payload) == 0 {
+			continue
+		}
+		docChannel <- &processor.Document{
+			Blob:   payload,
+			Type:   processor.DocumentUnknown,
+			Format: processor.FormatUnknown,
+			SourceInformation: processor.SourceInformation{
+				Collector: CollectorGitHubRelease,
+				Source:    source,
+				URI:       asset.GetBrowserDownloadURL(),
+			},
+		}
+	}
+}
+
+// matches reports whether the asset name matches one of the patterns
+func (r *releaseCollector) matches(assetName string) bool {
+	for _, p := range r.patterns {
+		if ok, _ := path.Match(p, assetName); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *releaseCollector) downloadAsset(ctx context.Context, owner string, name string, id int64) ([]byte, error) {
+	// the assets are served by redirecting to storage, which must not get the
+	// token of the API client
+	rc, _, err := r.client.Repositories.DownloadReleaseAsset(ctx, owner, name, id, http.DefaultClient)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
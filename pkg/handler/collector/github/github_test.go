@@ -0,0 +1,180 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package github
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/guacsec/guac/pkg/handler/processor"
+)
+
+// newTestServer serves two releases of guacsec/guac, the latest first, with
+// the assets of id n having the content "asset n"
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	release := func(id int, tag string, assets ...string) string {
+		list := ""
+		for i, name := range assets {
+			if i > 0 {
+				list += ","
+			}
+			assetID := id*10 + i
+			list += fmt.Sprintf(`{"id":%d,"name":%q,"browser_download_url":"https://github.com/guacsec/guac/releases/download/%s/%s"}`, assetID, name, tag, name)
+		}
+		return fmt.Sprintf(`{"id":%d,"tag_name":%q,"assets":[%s]}`, id, tag, list)
+	}
+	latest := release(2, "v0.2.0", "guac.spdx.json", "guac-linux-amd64", "guac.intoto.jsonl")
+	older := release(1, "v0.1.0", "guac.spdx.json")
+	mux.HandleFunc("/repos/guacsec/guac/releases", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "[%s,%s]", latest, older)
+	})
+	mux.HandleFunc("/repos/guacsec/guac/releases/latest", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, latest)
+	})
+	mux.HandleFunc("/repos/guacsec/guac/releases/assets/", func(w http.ResponseWriter, r *http.Request) {
+		var id int
+		if _, err := fmt.Sscanf(r.URL.Path, "/repos/guacsec/guac/releases/assets/%d", &id); err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintf(w, "asset %d", id)
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newTestCollector(t *testing.T, server *httptest.Server, patterns []string, opts ...Option) *releaseCollector {
+	t.Helper()
+	r, err := NewReleaseCollector(context.Background(), []string{"guacsec/guac"}, patterns, 0, opts...)
+	if err != nil {
+		t.Fatalf("NewReleaseCollector() error = %v", err)
+	}
+	baseURL, err := url.Parse(server.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.client.BaseURL = baseURL
+	return r
+}
+
+func collect(t *testing.T, r *releaseCollector) []*processor.Document {
+	t.Helper()
+	docChannel := make(chan *processor.Document, 10)
+	if err := r.RetrieveArtifacts(context.Background(), docChannel); err != nil {
+		t.Fatalf("RetrieveArtifacts() error = %v", err)
+	}
+	close(docChannel)
+	docs := []*processor.Document{}
+	for d := range docChannel {
+		docs = append(docs, d)
+	}
+	return docs
+}
+
+func doc(tag string, name string, content string) *processor.Document {
+	return &processor.Document{
+		Blob:   []byte(content),
+		Type:   processor.DocumentUnknown,
+		Format: processor.FormatUnknown,
+		SourceInformation: processor.SourceInformation{
+			Collector: CollectorGitHubRelease,
+			Source:    "guacsec/guac@" + tag + "/" + name,
+			URI:       "https://github.com/guacsec/guac/releases/download/" + tag + "/" + name,
+		},
+	}
+}
+
+func TestReleaseCollector_RetrieveArtifacts(t *testing.T) {
+	server := newTestServer(t)
+	tests := []struct {
+		name     string
+		patterns []string
+		opts     []Option
+		want     []*processor.Document
+	}{{
+		name: "default patterns",
+		want: []*processor.Document{
+			doc("v0.2.0", "guac.spdx.json", "asset 20"),
+			doc("v0.2.0", "guac.intoto.jsonl", "asset 22"),
+			doc("v0.1.0", "guac.spdx.json", "asset 10"),
+		},
+	}, {
+		name:     "patterns",
+		patterns: []string{"*.intoto.jsonl"},
+		want: []*processor.Document{
+			doc("v0.2.0", "guac.intoto.jsonl", "asset 22"),
+		},
+	}, {
+		name: "latest only",
+		opts: []Option{WithLatestOnly()},
+		want: []*processor.Document{
+			doc("v0.2.0", "guac.spdx.json", "asset 20"),
+			doc("v0.2.0", "guac.intoto.jsonl", "asset 22"),
+		},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestCollector(t, server, tt.patterns, tt.opts...)
+			if got := collect(t, r); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("RetrieveArtifacts() = %v, want %v", got, tt.want)
+			}
+			// the assets already collected are not collected again
+			if got := collect(t, r); len(got) != 0 {
+				t.Errorf("RetrieveArtifacts() again = %v, want none", got)
+			}
+		})
+	}
+}
+
+func TestNewReleaseCollector(t *testing.T) {
+	tests := []struct {
+		name     string
+		repos    []string
+		patterns []string
+		wantErr  bool
+	}{{
+		name:  "valid",
+		repos: []string{"guacsec/guac", "sigstore/cosign"},
+	}, {
+		name:    "no repos",
+		wantErr: true,
+	}, {
+		name:    "invalid repo",
+		repos:   []string{"guacsec"},
+		wantErr: true,
+	}, {
+		name:     "invalid pattern",
+		repos:    []string{"guacsec/guac"},
+		patterns: []string{"[*.json"},
+		wantErr:  true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewReleaseCollector(context.Background(), tt.repos, tt.patterns, 0)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewReleaseCollector() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
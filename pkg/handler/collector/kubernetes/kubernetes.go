@@ -0,0 +1,342 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/guacsec/guac/pkg/handler/collector/oci"
+	"github.com/guacsec/guac/pkg/handler/processor"
+	"github.com/guacsec/guac/pkg/logging"
+)
+
+const (
+	CollectorKubernetes = "KUBERNETES"
+
+	serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+	// watchTimeout bounds a watch request, after which the watch is resumed
+	// from the last resource version seen
+	watchTimeout = 5 * time.Minute
+	// retryDelay is the time to wait before watching again after a failure
+	retryDelay = 5 * time.Second
+	listLimit  = 500
+)
+
+// errGone is returned when the resource version to watch from is too old
+// and the pods need to be listed again
+var errGone = errors.New("resource version too old")
+
+// Config locates the API server of the cluster and authenticates to it
+type Config struct {
+	// Server is the URL of the API server, e.g. `https://10.0.0.1:443` or
+	// `http://localhost:8001` behind `kubectl proxy`
+	Server string
+	// TokenFile holds the bearer token, read again for every request as
+	// service account tokens are rotated. No token is sent if empty.
+	TokenFile string
+	// CAFile is the PEM file of the CA of the API server, the system roots
+	// are used if empty
+	CAFile string
+	// Namespace restricts the watched pods, all namespaces if empty
+	Namespace string
+}
+
+// InClusterConfig returns the config of the service account of the pod the
+// collector runs in
+func InClusterConfig() (Config, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return Config{}, errors.New("not running in a cluster, KUBERNETES_SERVICE_HOST and KUBERNETES_SERVICE_PORT are not set")
+	}
+	return Config{
+		Server:    "https://" + net.JoinHostPort(host, port),
+		TokenFile: filepath.Join(serviceAccountDir, "token"),
+		CAFile:    filepath.Join(serviceAccountDir, "ca.crt"),
+	}, nil
+}
+
+type imageFetcher interface {
+	Fetch(ctx context.Context, image string, docChannel chan<- *processor.Document) error
+}
+
+type kubernetesCollector struct {
+	cfg     Config
+	client  *http.Client
+	fetcher imageFetcher
+	// images are the images already collected
+	images map[string]bool
+	// resourceVersion is the version of the pods seen, to watch from
+	resourceVersion string
+}
+
+// NewKubernetesCollector initializes the kubernetes collector, which
+// watches the pods of the cluster and collects the SBOMs and attestations
+// attached in their registry to the images they run
+func NewKubernetesCollector(ctx context.Context, cfg Config) (*kubernetesCollector, error) {
+	if cfg.Server == "" {
+		return nil, errors.New("kubernetes API server not specified")
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if cfg.CAFile != "" {
+		caPEM, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read kubernetes CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("no certificate found in %s", cfg.CAFile)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	}
+	return &kubernetesCollector{
+		cfg:     cfg,
+		client:  &http.Client{Transport: transport},
+		fetcher: oci.NewImageFetcher(),
+		images:  map[string]bool{},
+	}, nil
+}
+
+// RetrieveArtifacts lists the pods of the cluster and then watches them,
+// collecting the artifacts of their images as they start running, until ctx
+// is cancelled
+func (k *kubernetesCollector) RetrieveArtifacts(ctx context.Context, docChannel chan<- *processor.Document) error {
+	logger := logging.FromContext(ctx)
+	listed := false
+	for {
+		var err error
+		if k.resourceVersion == "" {
+			err = k.list(ctx, docChannel)
+			if err != nil && !listed && ctx.Err() == nil {
+				return err
+			}
+			listed = listed || err == nil
+		}
+		if err == nil {
+			err = k.watch(ctx, docChannel)
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+		if errors.Is(err, errGone) {
+			logger.Infof("kubernetes pod watch expired, listing the pods again")
+			k.resourceVersion = ""
+			continue
+		}
+		if err != nil {
+			logger.Warnf("failed to watch kubernetes pods, retrying in %v: %v", retryDelay, err)
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(retryDelay):
+			}
+		}
+	}
+}
+
+// Type is the collector type of the collector
+func (k *kubernetesCollector) Type() string {
+	return CollectorKubernetes
+}
+
+// pod is the part of a pod used
+type pod struct {
+	Metadata struct {
+		Namespace       string `json:"namespace"`
+		Name            string `json:"name"`
+		ResourceVersion string `json:"resourceVersion"`
+	} `json:"metadata"`
+	Status struct {
+		InitContainerStatuses      []containerStatus `json:"initContainerStatuses"`
+		ContainerStatuses          []containerStatus `json:"containerStatuses"`
+		EphemeralContainerStatuses []containerStatus `json:"ephemeralContainerStatuses"`
+	} `json:"status"`
+}
+
+type containerStatus struct {
+	Image   string `json:"image"`
+	ImageID string `json:"imageID"`
+}
+
+// list collects the images of all the pods, one page at a time
+func (k *kubernetesCollector) list(ctx context.Context, docChannel chan<- *processor.Document) error {
+	continueToken := ""
+	for {
+		query := url.Values{"limit": {fmt.Sprint(listLimit)}}
+		if continueToken != "" {
+			query.Set("continue", continueToken)
+		}
+		var podList struct {
+			Metadata struct {
+				ResourceVersion string `json:"resourceVersion"`
+				Continue        string `json:"continue"`
+			} `json:"metadata"`
+			Items []pod `json:"items"`
+		}
+		resp, err := k.get(ctx, query)
+		if err != nil {
+			return fmt.Errorf("failed to list kubernetes pods: %w", err)
+		}
+		err = json.NewDecoder(resp.Body).Decode(&podList)
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("failed to decode kubernetes pods: %w", err)
+		}
+		for i := range podList.Items {
+			k.collectPod(ctx, &podList.Items[i], docChannel)
+		}
+		if podList.Metadata.Continue == "" {
+			k.resourceVersion = podList.Metadata.ResourceVersion
+			return nil
+		}
+		continueToken = podList.Metadata.Continue
+	}
+}
+
+// watch collects the images of the pods added or modified since the last
+// resource version seen, until the watch ends
+func (k *kubernetesCollector) watch(ctx context.Context, docChannel chan<- *processor.Document) error {
+	query := url.Values{
+		"watch":               {"true"},
+		"allowWatchBookmarks": {"true"},
+		"resourceVersion":     {k.resourceVersion},
+		"timeoutSeconds":      {fmt.Sprint(int(watchTimeout.Seconds()))},
+	}
+	resp, err := k.get(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var event struct {
+			Type   string          `json:"type"`
+			Object json.RawMessage `json:"object"`
+		}
+		if err := decoder.Decode(&event); errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("failed to decode kubernetes pod event: %w", err)
+		}
+		if event.Type == "ERROR" {
+			var status struct {
+				Code    int    `json:"code"`
+				Message string `json:"message"`
+			}
+			_ = json.Unmarshal(event.Object, &status)
+			if status.Code == http.StatusGone {
+				return errGone
+			}
+			return fmt.Errorf("kubernetes pod watch error %d: %s", status.Code, status.Message)
+		}
+		var p pod
+		if err := json.Unmarshal(event.Object, &p); err != nil {
+			return fmt.Errorf("failed to decode kubernetes pod: %w", err)
+		}
+		if p.Metadata.ResourceVersion != "" {
+			k.resourceVersion = p.Metadata.ResourceVersion
+		}
+		if event.Type == "ADDED" || event.Type == "MODIFIED" {
+			k.collectPod(ctx, &p, docChannel)
+		}
+	}
+}
+
+// collectPod collects the artifacts of the images the containers of p run
+// that were not collected yet. Images that fail to be collected are tried
+// again on the next change of a pod running them.
+func (k *kubernetesCollector) collectPod(ctx context.Context, p *pod, docChannel chan<- *processor.Document) {
+	logger := logging.FromContext(ctx)
+	statuses := append(append(append([]containerStatus{}, p.Status.InitContainerStatuses...),
+		p.Status.ContainerStatuses...), p.Status.EphemeralContainerStatuses...)
+	for _, status := range statuses {
+		image := imageRef(status)
+		if image == "" || k.images[image] {
+			continue
+		}
+		if err := k.fetcher.Fetch(ctx, image, docChannel); err != nil {
+			logger.Warnf("failed to collect image %s of pod %s/%s: %v", image, p.Metadata.Namespace, p.Metadata.Name, err)
+			continue
+		}
+		k.images[image] = true
+	}
+}
+
+// imageRef returns the reference to the image a container runs, by digest
+// when the container runtime reports it, or else by the tag the image was
+// pulled with. It is empty until the image is pulled.
+func imageRef(status containerStatus) string {
+	if status.ImageID == "" {
+		return ""
+	}
+	// e.g. docker-pullable://nginx@sha256:... with dockershim
+	id := status.ImageID
+	if _, rest, ok := strings.Cut(id, "://"); ok {
+		id = rest
+	}
+	if strings.Contains(id, "@") {
+		return id
+	}
+	// the ID is the digest of the local image config, which cannot be
+	// looked up in the registry
+	return status.Image
+}
+
+func (k *kubernetesCollector) get(ctx context.Context, query url.Values) (*http.Response, error) {
+	path := "/api/v1/pods"
+	if k.cfg.Namespace != "" {
+		path = "/api/v1/namespaces/" + url.PathEscape(k.cfg.Namespace) + "/pods"
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(k.cfg.Server, "/")+path+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if k.cfg.TokenFile != "" {
+		token, err := os.ReadFile(k.cfg.TokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read kubernetes token: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusGone {
+		resp.Body.Close()
+		return nil, errGone
+	}
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		resp.Body.Close()
+		return nil, fmt.Errorf("GET %s: %s: %s", path, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return resp, nil
+}
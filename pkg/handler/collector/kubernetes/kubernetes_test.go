@@ -0,0 +1,187 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/guacsec/guac/pkg/handler/processor"
+)
+
+type fakeFetcher struct {
+	mu     sync.Mutex
+	images []string
+	fail   map[string]int
+	done   chan struct{}
+	want   int
+}
+
+func (f *fakeFetcher) Fetch(ctx context.Context, image string, docChannel chan<- *processor.Document) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.fail[image] > 0 {
+		f.fail[image]--
+		return fmt.Errorf("registry unavailable")
+	}
+	f.images = append(f.images, image)
+	if len(f.images) == f.want {
+		close(f.done)
+	}
+	return nil
+}
+
+const (
+	podA = `{"metadata": {"namespace": "default", "name": "a", "resourceVersion": "5"}, "status": {"containerStatuses": [
+		{"image": "docker.io/library/nginx:1.23", "imageID": "docker.io/library/nginx@sha256:aaa"}]}}`
+	podBPending = `{"metadata": {"namespace": "default", "name": "b", "resourceVersion": "6"}, "status": {"containerStatuses": [
+		{"image": "gcr.io/project/app:v1", "imageID": ""}]}}`
+	podBRunning = `{"metadata": {"namespace": "default", "name": "b", "resourceVersion": "11"}, "status": {"containerStatuses": [
+		{"image": "gcr.io/project/app:v1", "imageID": "docker-pullable://gcr.io/project/app@sha256:bbb"}]}}`
+	podC = `{"metadata": {"namespace": "default", "name": "c", "resourceVersion": "12"}, "status": {"initContainerStatuses": [
+		{"image": "docker.io/library/nginx:1.23", "imageID": "docker.io/library/nginx@sha256:aaa"}], "containerStatuses": [
+		{"image": "quay.io/org/tool:latest", "imageID": "sha256:ccc"}]}}`
+)
+
+func TestKubernetesCollector(t *testing.T) {
+	var mu sync.Mutex
+	lists, watches := 0, 0
+	var tokens []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/namespaces/default/pods" {
+			http.NotFound(w, r)
+			return
+		}
+		mu.Lock()
+		tokens = append(tokens, r.Header.Get("Authorization"))
+		if r.URL.Query().Get("watch") != "true" {
+			lists++
+			list := lists
+			mu.Unlock()
+			if r.URL.Query().Get("continue") == "" {
+				fmt.Fprintf(w, `{"metadata": {"resourceVersion": "10", "continue": "next"}, "items": [%s]}`, podA)
+			} else {
+				// the list of the n-th listing is at resource version 10*n
+				fmt.Fprintf(w, `{"metadata": {"resourceVersion": "%d"}, "items": [%s]}`, 10*(list/2), podBPending)
+			}
+			return
+		}
+		watches++
+		watch := watches
+		mu.Unlock()
+		switch watch {
+		case 1:
+			if rv := r.URL.Query().Get("resourceVersion"); rv != "10" {
+				t.Errorf("watching from resource version %q, want 10", rv)
+			}
+			fmt.Fprintf(w, `{"type": "MODIFIED", "object": %s}`+"\n", podBRunning)
+			fmt.Fprintf(w, `{"type": "ERROR", "object": {"kind": "Status", "code": 410, "message": "too old"}}`+"\n")
+		case 2:
+			if rv := r.URL.Query().Get("resourceVersion"); rv != "20" {
+				t.Errorf("watching again from resource version %q, want 20 after listing again", rv)
+			}
+			fmt.Fprintf(w, `{"type": "BOOKMARK", "object": {"metadata": {"resourceVersion": "21"}}}`+"\n")
+		case 3:
+			if rv := r.URL.Query().Get("resourceVersion"); rv != "21" {
+				t.Errorf("resuming watch from resource version %q, want 21", rv)
+			}
+			fmt.Fprintf(w, `{"type": "ADDED", "object": %s}`+"\n", podC)
+			fmt.Fprintf(w, `{"type": "MODIFIED", "object": %s}`+"\n", podC)
+		default:
+			<-r.Context().Done()
+		}
+	}))
+	defer server.Close()
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("secret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	k, err := NewKubernetesCollector(context.Background(), Config{Server: server.URL, TokenFile: tokenFile, Namespace: "default"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fetcher := &fakeFetcher{
+		fail: map[string]int{"quay.io/org/tool:latest": 1},
+		done: make(chan struct{}),
+		want: 3,
+	}
+	k.fetcher = fetcher
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- k.RetrieveArtifacts(ctx, make(chan *processor.Document))
+	}()
+	select {
+	case <-fetcher.done:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("timed out, collected %v", fetcher.images)
+	}
+	cancel()
+	if err := <-errChan; err != nil {
+		t.Fatalf("RetrieveArtifacts() error = %v", err)
+	}
+
+	// each image is collected once, the tag of the image with a local ID is
+	// collected again after failing
+	want := []string{"docker.io/library/nginx@sha256:aaa", "gcr.io/project/app@sha256:bbb", "quay.io/org/tool:latest"}
+	if !reflect.DeepEqual(fetcher.images, want) {
+		t.Errorf("collected images %v, want %v", fetcher.images, want)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if lists != 4 {
+		t.Errorf("listed %d pages of pods, want 4", lists)
+	}
+	for _, token := range tokens {
+		if token != "Bearer secret" {
+			t.Errorf("unexpected authorization %q", token)
+		}
+	}
+}
+
+func TestImageRef(t *testing.T) {
+	tests := []struct {
+		status containerStatus
+		want   string
+	}{{
+		status: containerStatus{Image: "nginx:1.23", ImageID: "docker-pullable://nginx@sha256:aaa"},
+		want:   "nginx@sha256:aaa",
+	}, {
+		status: containerStatus{Image: "docker.io/library/nginx:1.23", ImageID: "docker.io/library/nginx@sha256:aaa"},
+		want:   "docker.io/library/nginx@sha256:aaa",
+	}, {
+		status: containerStatus{Image: "quay.io/org/tool:latest", ImageID: "sha256:ccc"},
+		want:   "quay.io/org/tool:latest",
+	}, {
+		status: containerStatus{Image: "quay.io/org/tool:latest"},
+		want:   "",
+	}}
+	for _, tt := range tests {
+		if got := imageRef(tt.status); got != tt.want {
+			t.Errorf("imageRef(%+v) = %q, want %q", tt.status, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+//
+// Copyright 2022 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ndjson
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/guacsec/guac/pkg/handler/collector/file"
+	"github.com/guacsec/guac/pkg/handler/processor"
+	"github.com/guacsec/guac/pkg/logging"
+)
+
+const (
+	NDJSONCollector = "NDJSONCollector"
+)
+
+type ndjsonCollector struct {
+	paths []string
+}
+
+// NewNDJSONCollector returns a collector streaming each of the
+// newline-delimited JSON files at paths, emitting one document per line.
+// Files are read line by line, so that large exports are not loaded in
+// memory. Blank lines are ignored and lines that are not valid JSON are
+// logged with their line number and skipped.
+func NewNDJSONCollector(ctx context.Context, paths []string) *ndjsonCollector {
+	return &ndjsonCollector{
+		paths: paths,
+	}
+}
+
+// RetrieveArtifacts collects the documents from the collector. It emits each collected
+// document through the channel to be collected and processed by the upstream processor.
+// The function should block until all the artifacts are collected and return a nil error
+// or return an error from the collector crashing.
+func (n *ndjsonCollector) RetrieveArtifacts(ctx context.Context, docChannel chan<- *processor.Document) error {
+	for _, p := range n.paths {
+		if err := n.collectFile(ctx, p, docChannel); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (n *ndjsonCollector) collectFile(ctx context.Context, p string, docChannel chan<- *processor.Document) error {
+	logger := logging.FromContext(ctx)
+
+	info, err := os.Stat(p)
+	if err != nil {
+		return fmt.Errorf("path: %s is invalid: %w", p, err)
+	}
+	if info.IsDir() {
+		return fmt.Errorf("path: %s is a directory, expected a newline-delimited JSON file", p)
+	}
+	f, err := os.Open(p)
+	if err != nil {
+		return fmt.Errorf("path: %s is invalid: %w", p, err)
+	}
+	defer f.Close()
+
+	uri := file.FileURI(p)
+	reader := bufio.NewReader(f)
+	for lineNum := 1; ; lineNum++ {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		// unlike bufio.Scanner, ReadBytes does not limit the line length
+		line, err := reader.ReadBytes('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("unable to read file: %s: line %d: %w", p, lineNum, err)
+		}
+		eof := err != nil
+
+		line = bytes.TrimSpace(line)
+		switch {
+		case len(line) == 0:
+		case !json.Valid(line):
+			logger.Warnf("skipping line: %s:%d: invalid JSON", p, lineNum)
+		default:
+			doc := &processor.Document{
+				Blob:   line,
+				Type:   processor.DocumentUnknown,
+				Format: processor.FormatJSON,
+				SourceInformation: processor.SourceInformation{
+					Collector: string(NDJSONCollector),
+					Source:    fmt.Sprintf("file:///%s#L%d", p, lineNum),
+					URI:       fmt.Sprintf("%s#L%d", uri, lineNum),
+				},
+			}
+			select {
+			case docChannel <- doc:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		if eof {
+			return nil
+		}
+	}
+}
+
+// Type returns the collector type
+func (n *ndjsonCollector) Type() string {
+	return NDJSONCollector
+}
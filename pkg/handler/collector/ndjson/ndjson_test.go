@@ -0,0 +1,102 @@
+//
+// Copyright 2022 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ndjson
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/guacsec/guac/pkg/handler/collector/file"
+	"github.com/guacsec/guac/pkg/handler/processor"
+)
+
+func Test_ndjsonCollector_RetrieveArtifacts(t *testing.T) {
+	doc := func(blob string, line string) *processor.Document {
+		return &processor.Document{
+			Blob:   []byte(blob),
+			Type:   processor.DocumentUnknown,
+			Format: processor.FormatJSON,
+			SourceInformation: processor.SourceInformation{
+				Collector: string(NDJSONCollector),
+				Source:    "file:///testdata/export.ndjson#L" + line,
+				URI:       file.FileURI("testdata/export.ndjson") + "#L" + line,
+			},
+		}
+	}
+	tests := []struct {
+		name    string
+		paths   []string
+		want    []*processor.Document
+		wantErr bool
+	}{{
+		name:    "nonexistent file path",
+		paths:   []string{"./doesnotexist"},
+		want:    []*processor.Document{},
+		wantErr: true,
+	}, {
+		name:    "directory",
+		paths:   []string{"testdata"},
+		want:    []*processor.Document{},
+		wantErr: true,
+	}, {
+		name:  "one document per line, skipping invalid lines",
+		paths: []string{"testdata/export.ndjson"},
+		want: []*processor.Document{
+			doc(`{"_type":"https://in-toto.io/Statement/v0.1","subject":[]}`, "1"),
+			doc(`{"_type":"https://in-toto.io/Statement/v0.1","predicateType":"x"}`, "4"),
+		},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := NewNDJSONCollector(context.Background(), tt.paths)
+			// unbuffered, the collector must not read ahead of the consumer
+			docChan := make(chan *processor.Document)
+			errChan := make(chan error, 1)
+			go func() {
+				errChan <- n.RetrieveArtifacts(context.Background(), docChan)
+			}()
+
+			s := []*processor.Document{}
+			for done := false; !done; {
+				select {
+				case d := <-docChan:
+					s = append(s, d)
+				case err := <-errChan:
+					if (err != nil) != tt.wantErr {
+						t.Errorf("ndjsonCollector.RetrieveArtifacts() = %v, want %v", err, tt.wantErr)
+					}
+					done = true
+				}
+			}
+			if !reflect.DeepEqual(s, tt.want) {
+				t.Errorf("ndjsonCollector.RetrieveArtifacts() = %v, want %v", s, tt.want)
+			}
+			if n.Type() != NDJSONCollector {
+				t.Errorf("ndjsonCollector.Type() = %s, want %s", n.Type(), NDJSONCollector)
+			}
+		})
+	}
+}
+
+func Test_ndjsonCollector_Canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	n := NewNDJSONCollector(ctx, []string{"testdata/export.ndjson"})
+	if err := n.RetrieveArtifacts(ctx, make(chan *processor.Document)); err == nil {
+		t.Error("ndjsonCollector.RetrieveArtifacts() expected error on canceled context")
+	}
+}
@@ -33,6 +33,13 @@ const (
 	OCICollector = "OCICollector"
 )
 
+// signatureArtifactTypes are the artifact types of the signature referrers,
+// which are not collected
+var signatureArtifactTypes = []string{
+	"application/vnd.dev.cosign.artifact.sig.v1+json",
+	"application/vnd.cncf.notary.signature",
+}
+
 type ociCollector struct {
 	repoTags      map[string][]string
 	checkedDigest map[string][]string
@@ -83,6 +90,8 @@ func (o *ociCollector) RetrieveArtifacts(ctx context.Context, docChannel chan<-
 }
 
 func (o *ociCollector) getTagsAndFetch(ctx context.Context, repo string, tags []string, docChannel chan<- *processor.Document) error {
+	// the docker config also configures the credential helpers, e.g.
+	// docker-credential-gcr, used to log into the registries
 	rcOpts := []regclient.Opt{}
 	rcOpts = append(rcOpts, regclient.WithDockerCreds())
 	rcOpts = append(rcOpts, regclient.WithDockerCerts())
@@ -187,41 +196,90 @@ func (o *ociCollector) fetchOCIArtifacts(ctx context.Context, repo string, rc *r
 				logger.Error(err)
 				continue
 			}
-
-			// go through layers in reverse
-			mi, ok := m.(manifest.Imager)
-			if !ok {
-				return fmt.Errorf("reference is not a known image media type")
-			}
-			layers, err := mi.GetLayers()
-			if err != nil {
+			if err := fetchLayers(ctx, rc, r, m, imageTag, docChannel); err != nil {
 				return err
 			}
-			for i := len(layers) - 1; i >= 0; i-- {
-				blob, err := rc.BlobGet(ctx, r, layers[i])
-				if err != nil {
-					return fmt.Errorf("failed pulling layer %d: %w", i, err)
-				}
-				btr1, err := blob.RawBody()
-				if err != nil {
-					return err
-				}
-
-				doc := &processor.Document{
-					Blob:   btr1,
-					Type:   processor.DocumentUnknown,
-					Format: processor.FormatUnknown,
-					SourceInformation: processor.SourceInformation{
-						Collector: string(OCICollector),
-						Source:    imageTag,
-					},
-				}
-				docChannel <- doc
-			}
 			o.checkedDigest[repo] = append(o.checkedDigest[repo], digestTag)
 		}
 	}
 
+	subject := image
+	subject.Digest = digest.String()
+	return o.fetchReferrers(ctx, repo, rc, subject, docChannel)
+}
+
+// fetchReferrers collects the artifacts attached to the image with the OCI
+// referrers API, e.g. SBOMs and attestations pushed with `oras attach` or
+// cosign in OCI 1.1 mode. On registries without the API, regclient falls back
+// to the referrers tag schema. Signatures are skipped as they are not
+// documents GUAC ingests.
+func (o *ociCollector) fetchReferrers(ctx context.Context, repo string, rc *regclient.RegClient, subject ref.Ref, docChannel chan<- *processor.Document) error {
+	logger := logging.FromContext(ctx)
+
+	rl, err := rc.ReferrerList(ctx, subject)
+	if err != nil {
+		// the image may have no referrers, or the registry may not allow listing them
+		logger.Debugf("unable to list referrers of %s: %v", subject.CommonName(), err)
+		return nil
+	}
+	for _, desc := range rl.Descriptors {
+		if contains(signatureArtifactTypes, desc.ArtifactType) {
+			continue
+		}
+		referrerDigest := desc.Digest.String()
+		if contains(o.checkedDigest[repo], referrerDigest) {
+			continue
+		}
+		r := subject
+		r.Digest = referrerDigest
+		m, err := rc.ManifestGet(ctx, r)
+		if err != nil {
+			logger.Errorf("failed retrieving referrer %s of %s: %v", referrerDigest, subject.CommonName(), err)
+			continue
+		}
+		source := fmt.Sprintf("%v@%v", repo, referrerDigest)
+		if err := fetchLayers(ctx, rc, r, m, source, docChannel); err != nil {
+			return err
+		}
+		o.checkedDigest[repo] = append(o.checkedDigest[repo], referrerDigest)
+	}
+	return nil
+}
+
+// fetchLayers emits the layers of the artifact manifest m as documents
+// collected from source
+func fetchLayers(ctx context.Context, rc *regclient.RegClient, r ref.Ref, m manifest.Manifest, source string, docChannel chan<- *processor.Document) error {
+	// go through layers in reverse
+	mi, ok := m.(manifest.Imager)
+	if !ok {
+		return fmt.Errorf("reference is not a known image media type")
+	}
+	layers, err := mi.GetLayers()
+	if err != nil {
+		return err
+	}
+	for i := len(layers) - 1; i >= 0; i-- {
+		blob, err := rc.BlobGet(ctx, r, layers[i])
+		if err != nil {
+			return fmt.Errorf("failed pulling layer %d: %w", i, err)
+		}
+		btr1, err := blob.RawBody()
+		if err != nil {
+			return err
+		}
+
+		doc := &processor.Document{
+			Blob:   btr1,
+			Type:   processor.DocumentUnknown,
+			Format: processor.FormatUnknown,
+			SourceInformation: processor.SourceInformation{
+				Collector: string(OCICollector),
+				Source:    source,
+				URI:       "oci://" + source,
+			},
+		}
+		docChannel <- doc
+	}
 	return nil
 }
 
@@ -234,6 +292,32 @@ func contains(elems []string, v string) bool {
 	return false
 }
 
+// ImageFetcher collects the artifacts attached to single images, for
+// collectors discovering images elsewhere, e.g. in a cluster. Artifacts
+// already collected by the fetcher are not collected again.
+type ImageFetcher struct {
+	o *ociCollector
+}
+
+// NewImageFetcher initializes an ImageFetcher
+func NewImageFetcher() *ImageFetcher {
+	return &ImageFetcher{o: NewOCICollector(context.Background(), nil, false, 0)}
+}
+
+// Fetch collects the SBOMs and attestations attached to image, a reference
+// such as `registry/repo@sha256:...` or `registry/repo:tag`
+func (f *ImageFetcher) Fetch(ctx context.Context, image string, docChannel chan<- *processor.Document) error {
+	r, err := ref.New(image)
+	if err != nil {
+		return err
+	}
+	rc := regclient.New(regclient.WithDockerCreds(), regclient.WithDockerCerts())
+	defer rc.Close(ctx, r)
+
+	repo := fmt.Sprintf("%v/%v", r.Registry, r.Repository)
+	return f.o.fetchOCIArtifacts(ctx, repo, rc, r, docChannel)
+}
+
 // Type is the collector type of the collector
 func (o *ociCollector) Type() string {
 	return OCICollector
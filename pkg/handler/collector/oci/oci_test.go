@@ -58,6 +58,7 @@ func Test_ociCollector_RetrieveArtifacts(t *testing.T) {
 				SourceInformation: processor.SourceInformation{
 					Collector: string(OCICollector),
 					Source:    "ghcr.io/guacsec/go-multi-test:sha256-a743268cd3c56f921f3fb706cc0425c8ab78119fd433e38bb7c5dcd5635b0d10.sbom",
+					URI:       "oci://ghcr.io/guacsec/go-multi-test:sha256-a743268cd3c56f921f3fb706cc0425c8ab78119fd433e38bb7c5dcd5635b0d10.sbom",
 				},
 			},
 			{
@@ -67,6 +68,7 @@ func Test_ociCollector_RetrieveArtifacts(t *testing.T) {
 				SourceInformation: processor.SourceInformation{
 					Collector: string(OCICollector),
 					Source:    "ghcr.io/guacsec/go-multi-test:sha256-1bc7e53e25de5c00ecaeca1473ab56bfaf4e39cea747edcf7db467389a287931.sbom",
+					URI:       "oci://ghcr.io/guacsec/go-multi-test:sha256-1bc7e53e25de5c00ecaeca1473ab56bfaf4e39cea747edcf7db467389a287931.sbom",
 				},
 			},
 			{
@@ -76,6 +78,7 @@ func Test_ociCollector_RetrieveArtifacts(t *testing.T) {
 				SourceInformation: processor.SourceInformation{
 					Collector: string(OCICollector),
 					Source:    "ghcr.io/guacsec/go-multi-test:sha256-534035553d1270a98dab3512fde0987e7709ec6b878c8fd60fdaf0d8e1611979.sbom",
+					URI:       "oci://ghcr.io/guacsec/go-multi-test:sha256-534035553d1270a98dab3512fde0987e7709ec6b878c8fd60fdaf0d8e1611979.sbom",
 				},
 			},
 		},
@@ -97,6 +100,7 @@ func Test_ociCollector_RetrieveArtifacts(t *testing.T) {
 				SourceInformation: processor.SourceInformation{
 					Collector: string(OCICollector),
 					Source:    "ghcr.io/guacsec/guac-test-image:sha256-9e183c89765d92a440f44ac7059385c778cbadad0ee8fe3208360efb07c0ba09.att",
+					URI:       "oci://ghcr.io/guacsec/guac-test-image:sha256-9e183c89765d92a440f44ac7059385c778cbadad0ee8fe3208360efb07c0ba09.att",
 				},
 			},
 			{
@@ -106,6 +110,7 @@ func Test_ociCollector_RetrieveArtifacts(t *testing.T) {
 				SourceInformation: processor.SourceInformation{
 					Collector: string(OCICollector),
 					Source:    "ghcr.io/guacsec/guac-test-image:sha256-9e183c89765d92a440f44ac7059385c778cbadad0ee8fe3208360efb07c0ba09.sbom",
+					URI:       "oci://ghcr.io/guacsec/guac-test-image:sha256-9e183c89765d92a440f44ac7059385c778cbadad0ee8fe3208360efb07c0ba09.sbom",
 				},
 			},
 		},
@@ -127,6 +132,7 @@ func Test_ociCollector_RetrieveArtifacts(t *testing.T) {
 				SourceInformation: processor.SourceInformation{
 					Collector: string(OCICollector),
 					Source:    "ghcr.io/guacsec/guac-test-image:sha256-9e183c89765d92a440f44ac7059385c778cbadad0ee8fe3208360efb07c0ba09.att",
+					URI:       "oci://ghcr.io/guacsec/guac-test-image:sha256-9e183c89765d92a440f44ac7059385c778cbadad0ee8fe3208360efb07c0ba09.att",
 				},
 			},
 			{
@@ -136,6 +142,7 @@ func Test_ociCollector_RetrieveArtifacts(t *testing.T) {
 				SourceInformation: processor.SourceInformation{
 					Collector: string(OCICollector),
 					Source:    "ghcr.io/guacsec/guac-test-image:sha256-9e183c89765d92a440f44ac7059385c778cbadad0ee8fe3208360efb07c0ba09.sbom",
+					URI:       "oci://ghcr.io/guacsec/guac-test-image:sha256-9e183c89765d92a440f44ac7059385c778cbadad0ee8fe3208360efb07c0ba09.sbom",
 				},
 			},
 		},
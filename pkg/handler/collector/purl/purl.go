@@ -0,0 +1,271 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package purl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/guacsec/guac/pkg/assembler"
+	"github.com/guacsec/guac/pkg/certifier"
+	"github.com/guacsec/guac/pkg/certifier/depsdev"
+	"github.com/guacsec/guac/pkg/certifier/osv"
+	"github.com/guacsec/guac/pkg/handler/collector/oci"
+	"github.com/guacsec/guac/pkg/handler/processor"
+	"github.com/guacsec/guac/pkg/logging"
+)
+
+const (
+	CollectorPurl = "PURL"
+	// PurlsPath is the path purls are submitted to
+	PurlsPath = "/purls"
+
+	shutdownTimeout = 10 * time.Second
+	// defaultOCIRegistry is the registry of the oci purls without
+	// repository_url
+	defaultOCIRegistry = "docker.io/library/"
+)
+
+// imageFetcher collects the artifacts attached to an image
+type imageFetcher interface {
+	Fetch(ctx context.Context, image string, docChannel chan<- *processor.Document) error
+}
+
+type purlCollector struct {
+	purls []string
+	// addr to serve the purls endpoint on, empty to collect purls once
+	addr       string
+	fetcher    imageFetcher
+	certifiers []func() certifier.Certifier
+	// mu serializes the collection of the submitted purls, the image fetcher
+	// keeping track of the artifacts already collected
+	mu sync.Mutex
+}
+
+// Option configures the purl collector
+type Option func(*purlCollector)
+
+// WithAddr keeps the collector running after the given purls are collected,
+// serving the purls endpoint on addr to collect the purls submitted to it
+func WithAddr(addr string) Option {
+	return func(p *purlCollector) {
+		p.addr = addr
+	}
+}
+
+// WithCertifiers sets the certifiers run on each purl, instead of deps.dev
+// and OSV
+func WithCertifiers(certifiers ...func() certifier.Certifier) Option {
+	return func(p *purlCollector) {
+		p.certifiers = certifiers
+	}
+}
+
+// NewPurlCollector initializes a collector resolving the metadata of single
+// packages given by their purl: the SBOMs and attestations attached to the
+// image of an oci purl in its registry, and the documents generated by the
+// deps.dev and OSV certifiers for any purl.
+func NewPurlCollector(ctx context.Context, purls []string, opts ...Option) (*purlCollector, error) {
+	for _, purl := range purls {
+		if err := ValidatePurl(purl); err != nil {
+			return nil, err
+		}
+	}
+	p := &purlCollector{
+		purls:      purls,
+		fetcher:    oci.NewImageFetcher(),
+		certifiers: []func() certifier.Certifier{depsdev.NewDepsDevCertifier, osv.NewOSVCertificationParser},
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	if len(p.purls) == 0 && p.addr == "" {
+		return nil, errors.New("purl collector needs purls or an address to serve")
+	}
+	return p, nil
+}
+
+// ValidatePurl checks that purl has the `pkg:type/name` structure of a
+// package URL
+func ValidatePurl(purl string) error {
+	rest := strings.TrimPrefix(purl, "pkg:")
+	if rest == purl {
+		return fmt.Errorf("purl %q does not start with pkg:", purl)
+	}
+	rest, _, _ = strings.Cut(rest, "#")
+	rest, _, _ = strings.Cut(rest, "?")
+	purlType, name, found := strings.Cut(strings.TrimLeft(rest, "/"), "/")
+	if !found || purlType == "" || strings.Trim(name, "/") == "" {
+		return fmt.Errorf("purl %q has no type or name", purl)
+	}
+	return nil
+}
+
+// Type is the collector type of the collector
+func (p *purlCollector) Type() string {
+	return CollectorPurl
+}
+
+// RetrieveArtifacts collects the given purls, then serves the purls endpoint
+// until ctx is cancelled if the collector has an address
+func (p *purlCollector) RetrieveArtifacts(ctx context.Context, docChannel chan<- *processor.Document) error {
+	logger := logging.FromContext(ctx)
+	for _, purl := range p.purls {
+		p.collect(ctx, purl, docChannel)
+	}
+	if p.addr == "" {
+		return nil
+	}
+
+	srv := &http.Server{
+		Addr:              p.addr,
+		Handler:           p.Handler(ctx, docChannel),
+		ReadHeaderTimeout: shutdownTimeout,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+
+	logger.Infof("purl collector listening on %s%s", p.addr, PurlsPath)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("purl collector failed to serve: %w", err)
+	}
+	return nil
+}
+
+// collectResponse is the response of the purls endpoint
+type collectResponse struct {
+	// Documents is the number of documents collected for each purl
+	Documents map[string]int `json:"documents"`
+}
+
+// Handler returns the HTTP handler of the purls endpoint, collecting the
+// purls given by the repeatable `purl` query parameter, e.g.
+// `POST /purls?purl=pkg:npm/lodash@4.17.21`. The response is sent once the
+// documents were emitted to docChannel, with their number for each purl.
+func (p *purlCollector) Handler(ctx context.Context, docChannel chan<- *processor.Document) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(PurlsPath, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		purls := r.URL.Query()["purl"]
+		if len(purls) == 0 {
+			http.Error(w, "no purl given", http.StatusBadRequest)
+			return
+		}
+		for _, purl := range purls {
+			if err := ValidatePurl(purl); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
+		resp := collectResponse{Documents: map[string]int{}}
+		for _, purl := range purls {
+			if ctx.Err() != nil {
+				http.Error(w, "collector is shutting down", http.StatusServiceUnavailable)
+				return
+			}
+			resp.Documents[purl] = p.collect(ctx, purl, docChannel)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(resp)
+	})
+	return mux
+}
+
+// collect emits the documents found for purl and returns their number.
+// Sources failing are logged and skipped.
+func (p *purlCollector) collect(ctx context.Context, purl string, docChannel chan<- *processor.Document) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	logger := logging.FromContext(ctx)
+
+	docs := make(chan *processor.Document)
+	go func() {
+		defer close(docs)
+		if image, ok := imageFromPurl(purl); ok {
+			if err := p.fetcher.Fetch(ctx, image, docs); err != nil {
+				logger.Warnf("failed to fetch the artifacts of %s: %v", image, err)
+			}
+		}
+		component := &certifier.Component{Package: assembler.PackageNode{Purl: purl}}
+		for _, newCertifier := range p.certifiers {
+			if err := newCertifier().CertifyComponent(ctx, component, docs); err != nil {
+				logger.Warnf("failed to certify %s: %v", purl, err)
+			}
+		}
+	}()
+
+	n := 0
+	for d := range docs {
+		docChannel <- d
+		n++
+	}
+	logger.Infof("collected %d documents for %s", n, purl)
+	return n
+}
+
+// imageFromPurl returns the image reference of an oci purl, e.g.
+// `ghcr.io/guacsec/guac@sha256:...` for
+// `pkg:oci/guac@sha256:...?repository_url=ghcr.io/guacsec/guac`
+func imageFromPurl(purl string) (string, bool) {
+	rest := strings.TrimPrefix(purl, "pkg:")
+	rest, _, _ = strings.Cut(rest, "#")
+	rest, rawQualifiers, _ := strings.Cut(rest, "?")
+	purlType, nameVersion, _ := strings.Cut(strings.TrimLeft(rest, "/"), "/")
+	if strings.ToLower(purlType) != "oci" {
+		return "", false
+	}
+	name, version, _ := strings.Cut(nameVersion, "@")
+	name, err := url.PathUnescape(name)
+	if err != nil || name == "" {
+		return "", false
+	}
+	if version, err = url.PathUnescape(version); err != nil {
+		return "", false
+	}
+	qualifiers, err := url.ParseQuery(rawQualifiers)
+	if err != nil {
+		return "", false
+	}
+
+	repo := qualifiers.Get("repository_url")
+	if repo == "" {
+		repo = defaultOCIRegistry + name
+	}
+	switch {
+	case version != "":
+		return repo + "@" + version, true
+	case qualifiers.Get("tag") != "":
+		return repo + ":" + qualifiers.Get("tag"), true
+	default:
+		return repo, true
+	}
+}
@@ -0,0 +1,196 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package purl
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/guacsec/guac/pkg/certifier"
+	"github.com/guacsec/guac/pkg/handler/processor"
+)
+
+type fakeFetcher struct {
+	images []string
+}
+
+func (f *fakeFetcher) Fetch(ctx context.Context, image string, docChannel chan<- *processor.Document) error {
+	f.images = append(f.images, image)
+	docChannel <- &processor.Document{Blob: []byte("sbom of " + image)}
+	return nil
+}
+
+type fakeCertifier struct{}
+
+func (fakeCertifier) CertifyComponent(ctx context.Context, rootComponent *certifier.Component, docChannel chan<- *processor.Document) error {
+	docChannel <- &processor.Document{Blob: []byte("certified " + rootComponent.Package.Purl)}
+	return nil
+}
+
+func newFakeCertifier() certifier.Certifier {
+	return fakeCertifier{}
+}
+
+func blobs(docs []*processor.Document) []string {
+	result := []string{}
+	for _, d := range docs {
+		result = append(result, string(d.Blob))
+	}
+	return result
+}
+
+func TestPurlCollector(t *testing.T) {
+	purls := []string{
+		"pkg:npm/%40angular/core@15.0.0",
+		"pkg:oci/guac@sha256%3Aabcd?repository_url=ghcr.io/guacsec/guac",
+	}
+	p, err := NewPurlCollector(context.Background(), purls, WithCertifiers(newFakeCertifier))
+	if err != nil {
+		t.Fatalf("NewPurlCollector() error = %v", err)
+	}
+	fetcher := &fakeFetcher{}
+	p.fetcher = fetcher
+	if p.Type() != CollectorPurl {
+		t.Errorf("Type() = %s, want %s", p.Type(), CollectorPurl)
+	}
+
+	docChan := make(chan *processor.Document, 10)
+	if err := p.RetrieveArtifacts(context.Background(), docChan); err != nil {
+		t.Fatalf("RetrieveArtifacts() error = %v", err)
+	}
+	close(docChan)
+	docs := []*processor.Document{}
+	for d := range docChan {
+		docs = append(docs, d)
+	}
+	want := []string{
+		"certified pkg:npm/%40angular/core@15.0.0",
+		"sbom of ghcr.io/guacsec/guac@sha256:abcd",
+		"certified pkg:oci/guac@sha256%3Aabcd?repository_url=ghcr.io/guacsec/guac",
+	}
+	if got := blobs(docs); !reflect.DeepEqual(got, want) {
+		t.Errorf("RetrieveArtifacts() = %v, want %v", got, want)
+	}
+}
+
+func TestPurlCollectorHandler(t *testing.T) {
+	p, err := NewPurlCollector(context.Background(), nil, WithAddr("localhost:0"), WithCertifiers(newFakeCertifier))
+	if err != nil {
+		t.Fatalf("NewPurlCollector() error = %v", err)
+	}
+	p.fetcher = &fakeFetcher{}
+	docChan := make(chan *processor.Document, 10)
+	server := httptest.NewServer(p.Handler(context.Background(), docChan))
+	defer server.Close()
+
+	tests := []struct {
+		name       string
+		method     string
+		purls      []string
+		wantStatus int
+		wantDocs   map[string]int
+	}{{
+		name:       "collect",
+		method:     http.MethodPost,
+		purls:      []string{"pkg:pypi/django@4.1", "pkg:oci/alpine@sha256:abcd"},
+		wantStatus: http.StatusOK,
+		wantDocs:   map[string]int{"pkg:pypi/django@4.1": 1, "pkg:oci/alpine@sha256:abcd": 2},
+	}, {
+		name:       "no purl",
+		method:     http.MethodPost,
+		wantStatus: http.StatusBadRequest,
+	}, {
+		name:       "invalid purl",
+		method:     http.MethodPost,
+		purls:      []string{"pkg:pypi/django@4.1", "django"},
+		wantStatus: http.StatusBadRequest,
+	}, {
+		name:       "wrong method",
+		method:     http.MethodGet,
+		purls:      []string{"pkg:pypi/django@4.1"},
+		wantStatus: http.StatusMethodNotAllowed,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, server.URL+PurlsPath+"?"+url.Values{"purl": tt.purls}.Encode(), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if tt.wantDocs == nil {
+				return
+			}
+			var got collectResponse
+			if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got.Documents, tt.wantDocs) {
+				t.Errorf("documents = %v, want %v", got.Documents, tt.wantDocs)
+			}
+		})
+	}
+}
+
+func TestImageFromPurl(t *testing.T) {
+	tests := []struct {
+		purl   string
+		want   string
+		wantOK bool
+	}{{
+		purl:   "pkg:oci/guac@sha256%3Aabcd?repository_url=ghcr.io/guacsec/guac&tag=v0.1.0",
+		want:   "ghcr.io/guacsec/guac@sha256:abcd",
+		wantOK: true,
+	}, {
+		purl:   "pkg:oci/guac?repository_url=ghcr.io/guacsec/guac&tag=v0.1.0",
+		want:   "ghcr.io/guacsec/guac:v0.1.0",
+		wantOK: true,
+	}, {
+		purl:   "pkg:oci/alpine",
+		want:   "docker.io/library/alpine",
+		wantOK: true,
+	}, {
+		purl: "pkg:npm/lodash@4.17.21",
+	}}
+	for _, tt := range tests {
+		t.Run(tt.purl, func(t *testing.T) {
+			got, ok := imageFromPurl(tt.purl)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("imageFromPurl() = %q, %v, want %q, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestNewPurlCollectorErrors(t *testing.T) {
+	if _, err := NewPurlCollector(context.Background(), nil); err == nil {
+		t.Errorf("expected an error without purls or address")
+	}
+	if _, err := NewPurlCollector(context.Background(), []string{"lodash"}); err == nil {
+		t.Errorf("expected an error for an invalid purl")
+	}
+}
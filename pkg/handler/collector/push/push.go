@@ -0,0 +1,230 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package push
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/guacsec/guac/pkg/handler/processor"
+	"github.com/guacsec/guac/pkg/logging"
+	"net/url"
+)
+
+const (
+	CollectorPush = "PUSH"
+	// DocumentsPath is the path documents are pushed to
+	DocumentsPath = "/documents"
+	// DefaultMaxSize is the default size limit of a pushed document
+	DefaultMaxSize int64 = 10 << 20
+
+	shutdownTimeout = 10 * time.Second
+)
+
+type pushCollector struct {
+	addr string
+	// tokenDigests are the SHA-256 digests of the accepted tokens, compared
+	// in constant time
+	tokenDigests [][sha256.Size]byte
+	maxSize      int64
+	certFile     string
+	keyFile      string
+}
+
+// Option configures the push collector
+type Option func(*pushCollector)
+
+// WithMaxSize sets the size limit in bytes of a pushed document, larger
+// documents are rejected
+func WithMaxSize(size int64) Option {
+	return func(p *pushCollector) {
+		p.maxSize = size
+	}
+}
+
+// WithTLS serves the endpoint over TLS with the certificate and key of the
+// given PEM files
+func WithTLS(certFile, keyFile string) Option {
+	return func(p *pushCollector) {
+		p.certFile = certFile
+		p.keyFile = keyFile
+	}
+}
+
+// NewPushCollector initializes the push collector listening on addr.
+// Documents are accepted from the requests authenticated by one of tokens
+// as a bearer token.
+func NewPushCollector(ctx context.Context, addr string, tokens []string, opts ...Option) (*pushCollector, error) {
+	p := &pushCollector{
+		addr:    addr,
+		maxSize: DefaultMaxSize,
+	}
+	for _, token := range tokens {
+		if token = strings.TrimSpace(token); token != "" {
+			p.tokenDigests = append(p.tokenDigests, sha256.Sum256([]byte(token)))
+		}
+	}
+	if len(p.tokenDigests) == 0 {
+		return nil, errors.New("push collector needs at least one token")
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	if p.maxSize <= 0 {
+		return nil, fmt.Errorf("invalid document size limit %d", p.maxSize)
+	}
+	if (p.certFile == "") != (p.keyFile == "") {
+		return nil, errors.New("push collector TLS needs both a certificate and a key")
+	}
+	return p, nil
+}
+
+// RetrieveArtifacts serves the documents endpoint and emits the pushed
+// documents until ctx is cancelled
+func (p *pushCollector) RetrieveArtifacts(ctx context.Context, docChannel chan<- *processor.Document) error {
+	logger := logging.FromContext(ctx)
+	srv := &http.Server{
+		Addr:              p.addr,
+		Handler:           p.Handler(ctx, docChannel),
+		ReadHeaderTimeout: shutdownTimeout,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+
+	logger.Infof("push collector listening on %s%s", p.addr, DocumentsPath)
+	var err error
+	if p.certFile != "" {
+		err = srv.ListenAndServeTLS(p.certFile, p.keyFile)
+	} else {
+		err = srv.ListenAndServe()
+	}
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("push collector failed to serve: %w", err)
+	}
+	return nil
+}
+
+// Type is the collector type of the collector
+func (p *pushCollector) Type() string {
+	return CollectorPush
+}
+
+// Handler returns the HTTP handler of the documents endpoint, emitting the
+// pushed documents to docChannel. A document is accepted once it was
+// emitted, so that a busy pipeline slows down the clients pushing to it.
+//
+// The source of a document is given by the `source` query parameter, e.g.
+// `POST /documents?source=ci/my-repo/build-42`, and defaults to the address
+// of the client.
+func (p *pushCollector) Handler(ctx context.Context, docChannel chan<- *processor.Document) http.Handler {
+	logger := logging.FromContext(ctx)
+	mux := http.NewServeMux()
+	mux.HandleFunc(DocumentsPath, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if !p.authorized(r) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="guac"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if r.ContentLength > p.maxSize {
+			http.Error(w, fmt.Sprintf("document larger than %d bytes", p.maxSize), http.StatusRequestEntityTooLarge)
+			return
+		}
+		// one byte past the limit tells apart documents that are too large
+		blob, err := io.ReadAll(io.LimitReader(r.Body, p.maxSize+1))
+		if err != nil {
+			http.Error(w, "failed to read document", http.StatusBadRequest)
+			return
+		}
+		if int64(len(blob)) > p.maxSize {
+			http.Error(w, fmt.Sprintf("document larger than %d bytes", p.maxSize), http.StatusRequestEntityTooLarge)
+			return
+		}
+		if len(blob) == 0 {
+			http.Error(w, "empty document", http.StatusBadRequest)
+			return
+		}
+
+		source := r.URL.Query().Get("source")
+		if source == "" {
+			source = r.RemoteAddr
+		}
+		doc := &processor.Document{
+			Blob:   blob,
+			Type:   processor.DocumentUnknown,
+			Format: processor.FormatUnknown,
+			SourceInformation: processor.SourceInformation{
+				Collector: CollectorPush,
+				Source:    source,
+				URI:       requestURI(r),
+			},
+		}
+		select {
+		case docChannel <- doc:
+		case <-r.Context().Done():
+			return
+		case <-ctx.Done():
+			http.Error(w, "collector is shutting down", http.StatusServiceUnavailable)
+			return
+		}
+		logger.Debugf("accepted pushed document of %d bytes from %s", len(blob), source)
+		w.WriteHeader(http.StatusAccepted)
+	})
+	return mux
+}
+
+// authorized returns whether the request has the bearer token of one of the
+// accepted tokens
+func (p *pushCollector) authorized(r *http.Request) bool {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return false
+	}
+	digest := sha256.Sum256([]byte(strings.TrimSpace(token)))
+	authorized := 0
+	for _, d := range p.tokenDigests {
+		authorized |= subtle.ConstantTimeCompare(digest[:], d[:])
+	}
+	return authorized == 1
+}
+
+// requestURI returns the URL the document was pushed to, keeping only the
+// source parameter of the query
+func requestURI(r *http.Request) string {
+	u := url.URL{Scheme: "http", Host: r.Host, Path: r.URL.Path}
+	if r.TLS != nil {
+		u.Scheme = "https"
+	}
+	if source := r.URL.Query().Get("source"); source != "" {
+		u.RawQuery = url.Values{"source": {source}}.Encode()
+	}
+	return u.String()
+}
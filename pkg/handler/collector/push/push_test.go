@@ -0,0 +1,155 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package push
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/guacsec/guac/pkg/handler/processor"
+)
+
+func TestPushCollector_Handler(t *testing.T) {
+	ctx := context.Background()
+	p, err := NewPushCollector(ctx, "localhost:0", []string{"token1", " token2 "}, WithMaxSize(16))
+	if err != nil {
+		t.Fatal(err)
+	}
+	docChannel := make(chan *processor.Document, 10)
+	server := httptest.NewServer(p.Handler(ctx, docChannel))
+	defer server.Close()
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		token      string
+		body       io.Reader
+		wantStatus int
+		wantSource string
+		wantURI    string
+	}{{
+		name:       "accepted",
+		method:     http.MethodPost,
+		path:       DocumentsPath + "?source=ci/build-1",
+		token:      "token1",
+		body:       strings.NewReader(`{"sbom": true}`),
+		wantStatus: http.StatusAccepted,
+		wantSource: "ci/build-1",
+		wantURI:    server.URL + DocumentsPath + "?source=ci%2Fbuild-1",
+	}, {
+		name:       "second token",
+		method:     http.MethodPost,
+		path:       DocumentsPath,
+		token:      "token2",
+		body:       strings.NewReader(`{}`),
+		wantStatus: http.StatusAccepted,
+		wantSource: "127.0.0.1",
+		wantURI:    server.URL + DocumentsPath,
+	}, {
+		name:       "no token",
+		method:     http.MethodPost,
+		path:       DocumentsPath,
+		body:       strings.NewReader(`{}`),
+		wantStatus: http.StatusUnauthorized,
+	}, {
+		name:       "wrong token",
+		method:     http.MethodPost,
+		path:       DocumentsPath,
+		token:      "token3",
+		body:       strings.NewReader(`{}`),
+		wantStatus: http.StatusUnauthorized,
+	}, {
+		name:       "get",
+		method:     http.MethodGet,
+		path:       DocumentsPath,
+		token:      "token1",
+		wantStatus: http.StatusMethodNotAllowed,
+	}, {
+		name:       "too large",
+		method:     http.MethodPost,
+		path:       DocumentsPath,
+		token:      "token1",
+		body:       strings.NewReader(strings.Repeat("a", 17)),
+		wantStatus: http.StatusRequestEntityTooLarge,
+	}, {
+		name:       "too large without length",
+		method:     http.MethodPost,
+		path:       DocumentsPath,
+		token:      "token1",
+		body:       io.MultiReader(strings.NewReader(strings.Repeat("a", 10)), strings.NewReader(strings.Repeat("a", 10))),
+		wantStatus: http.StatusRequestEntityTooLarge,
+	}, {
+		name:       "empty",
+		method:     http.MethodPost,
+		path:       DocumentsPath,
+		token:      "token1",
+		body:       strings.NewReader(""),
+		wantStatus: http.StatusBadRequest,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, server.URL+tt.path, tt.body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("got status %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if tt.wantStatus != http.StatusAccepted {
+				if len(docChannel) != 0 {
+					t.Errorf("rejected document was emitted")
+				}
+				return
+			}
+			doc := <-docChannel
+			if doc.SourceInformation.Collector != CollectorPush || !strings.HasPrefix(doc.SourceInformation.Source, tt.wantSource) {
+				t.Errorf("unexpected source information %+v", doc.SourceInformation)
+			}
+			if doc.SourceInformation.URI != tt.wantURI {
+				t.Errorf("URI = %q, want %q", doc.SourceInformation.URI, tt.wantURI)
+			}
+			if doc.Type != processor.DocumentUnknown || doc.Format != processor.FormatUnknown || len(doc.Blob) == 0 {
+				t.Errorf("unexpected document %+v", doc)
+			}
+		})
+	}
+}
+
+func TestNewPushCollector_invalid(t *testing.T) {
+	ctx := context.Background()
+	if _, err := NewPushCollector(ctx, ":0", []string{" "}); err == nil {
+		t.Error("expected an error without tokens")
+	}
+	if _, err := NewPushCollector(ctx, ":0", []string{"token"}, WithMaxSize(0)); err == nil {
+		t.Error("expected an error for a zero size limit")
+	}
+	if _, err := NewPushCollector(ctx, ":0", []string{"token"}, WithTLS("cert.pem", "")); err == nil {
+		t.Error("expected an error for TLS without a key")
+	}
+}
@@ -0,0 +1,559 @@
+//
+// Copyright 2022 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rekor
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/guacsec/guac/pkg/handler/processor"
+	"github.com/guacsec/guac/pkg/logging"
+)
+
+const (
+	RekorCollector = "RekorCollector"
+	// DefaultRekorURL is the public Sigstore Rekor instance
+	DefaultRekorURL = "https://rekor.sigstore.dev"
+	// pageSize is the number of entries fetched per request, the maximum
+	// accepted by Rekor
+	pageSize = 10
+	// HashedRekordPredicateType is the predicate type of the in-toto
+	// statements synthesized from `hashedrekord` entries, whose predicate is
+	// the entry spec
+	HashedRekordPredicateType = "http://rekor.sigstore.dev/types/hashedrekord/hashedrekord_v0_0_1_schema.json"
+)
+
+// entry kinds with a document to collect
+const (
+	KindIntoto       = "intoto"
+	KindDSSE         = "dsse"
+	KindHashedRekord = "hashedrekord"
+)
+
+// SupportedKinds are the entry kinds the collector can collect
+var SupportedKinds = []string{KindIntoto, KindDSSE, KindHashedRekord}
+
+// Query selects the Rekor entries to collect, either the entries of the
+// given subject digests (e.g. `sha256:...`), the entries integrated in the
+// log between Since and Until, or with Tail the entries integrated from the
+// time collection starts. A zero Until collects up to the latest entry.
+//
+// Entries of a time range or of the tail can be restricted to those with a
+// subject name matching Subjects or a subject digest returned by
+// KnownSubjects, which is called again before each poll (e.g. to follow the
+// artifacts of the graph). Only the entries of Kinds are collected, all the
+// SupportedKinds if empty.
+type Query struct {
+	Digests       []string
+	Since         time.Time
+	Until         time.Time
+	Tail          bool
+	Kinds         []string
+	Subjects      *regexp.Regexp
+	KnownSubjects func(ctx context.Context) ([]string, error)
+}
+
+// filtered returns whether the query restricts entries by subject
+func (q Query) filtered() bool {
+	return q.Subjects != nil || q.KnownSubjects != nil
+}
+
+type rekorCollector struct {
+	url    string
+	client *http.Client
+	query  Query
+	// seen are the log indices already collected, across polls
+	seen map[int64]bool
+	// nextIndex is the first log index not yet scanned of a time range or
+	// tail query
+	nextIndex int64
+	kinds     map[string]bool
+	// knownDigests are the digests of KnownSubjects of the current poll
+	knownDigests map[string]bool
+	poll         bool
+	interval     time.Duration
+}
+
+// NewRekorCollector initializes the rekor collector for the Rekor instance
+// at url. When polling, new entries matching query are collected every
+// interval.
+func NewRekorCollector(ctx context.Context, url string, query Query, poll bool, interval time.Duration) (*rekorCollector, error) {
+	modes := 0
+	for _, set := range []bool{len(query.Digests) > 0, !query.Since.IsZero(), query.Tail} {
+		if set {
+			modes++
+		}
+	}
+	if modes == 0 {
+		return nil, errors.New("rekor query needs subject digests, a start time or to tail the log")
+	}
+	if modes > 1 {
+		return nil, errors.New("rekor query takes one of subject digests, a time range or tailing the log")
+	}
+	if !query.Until.IsZero() && (query.Tail || query.Until.Before(query.Since)) {
+		return nil, errors.New("rekor query ends before it starts")
+	}
+	if len(query.Digests) > 0 && query.filtered() {
+		return nil, errors.New("rekor query by subject digests cannot be restricted by subjects")
+	}
+	kinds := map[string]bool{}
+	for _, k := range query.Kinds {
+		if !containsString(SupportedKinds, k) {
+			return nil, fmt.Errorf("unsupported rekor entry kind %q, expected one of %s", k, strings.Join(SupportedKinds, ", "))
+		}
+		kinds[k] = true
+	}
+	if len(kinds) == 0 {
+		for _, k := range SupportedKinds {
+			kinds[k] = true
+		}
+	}
+	for _, d := range query.Digests {
+		if !strings.Contains(d, ":") {
+			return nil, fmt.Errorf("digest %q is not of the form algorithm:value", d)
+		}
+	}
+	return &rekorCollector{
+		url:       strings.TrimSuffix(url, "/"),
+		client:    http.DefaultClient,
+		query:     query,
+		seen:      map[int64]bool{},
+		nextIndex: -1,
+		kinds:     kinds,
+		poll:      poll,
+		interval:  interval,
+	}, nil
+}
+
+// RetrieveArtifacts collects the attestations of the Rekor entries matching
+// the query, once or by polling
+func (r *rekorCollector) RetrieveArtifacts(ctx context.Context, docChannel chan<- *processor.Document) error {
+	for {
+		if err := r.collect(ctx, docChannel); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		if !r.poll {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(r.interval):
+		}
+	}
+}
+
+// Type is the collector type of the collector
+func (r *rekorCollector) Type() string {
+	return RekorCollector
+}
+
+func (r *rekorCollector) collect(ctx context.Context, docChannel chan<- *processor.Document) error {
+	if r.query.KnownSubjects != nil {
+		digests, err := r.query.KnownSubjects(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to get the known subjects: %w", err)
+		}
+		r.knownDigests = map[string]bool{}
+		for _, d := range digests {
+			r.knownDigests[strings.ToLower(d)] = true
+		}
+	}
+	if len(r.query.Digests) > 0 {
+		return r.collectDigests(ctx, docChannel)
+	}
+	return r.collectRange(ctx, docChannel)
+}
+
+// collectDigests collects the entries indexed under the query digests
+func (r *rekorCollector) collectDigests(ctx context.Context, docChannel chan<- *processor.Document) error {
+	uuids := []string{}
+	for _, d := range r.query.Digests {
+		var found []string
+		if err := r.post(ctx, "/api/v1/index/retrieve", map[string]interface{}{"hash": strings.ToLower(d)}, &found); err != nil {
+			return fmt.Errorf("failed to search rekor for %s: %w", d, err)
+		}
+		uuids = append(uuids, found...)
+	}
+	for start := 0; start < len(uuids); start += pageSize {
+		end := start + pageSize
+		if end > len(uuids) {
+			end = len(uuids)
+		}
+		entries, err := r.retrieve(ctx, map[string]interface{}{"entryUUIDs": uuids[start:end]})
+		if err != nil {
+			return err
+		}
+		if err := r.emit(ctx, entries, docChannel); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// collectRange collects the entries integrated in the query time range,
+// scanning the log by index from the first entry of the range, or from the
+// end of the log when tailing it
+func (r *rekorCollector) collectRange(ctx context.Context, docChannel chan<- *processor.Document) error {
+	size, err := r.treeSize(ctx)
+	if err != nil {
+		return err
+	}
+	if r.nextIndex < 0 && r.query.Tail {
+		r.nextIndex = size
+	} else if r.nextIndex < 0 {
+		if r.nextIndex, err = r.firstIndexSince(ctx, size); err != nil {
+			return err
+		}
+	}
+	for r.nextIndex < size {
+		indices := []int64{}
+		for i := r.nextIndex; i < size && len(indices) < pageSize; i++ {
+			indices = append(indices, i)
+		}
+		entries, err := r.retrieve(ctx, map[string]interface{}{"logIndexes": indices})
+		if err != nil {
+			return err
+		}
+		inRange := []logEntry{}
+		for _, e := range entries {
+			if !r.query.Until.IsZero() && time.Unix(e.IntegratedTime, 0).After(r.query.Until) {
+				// the end of the range is reached, nothing more to poll
+				r.poll = false
+				return r.emit(ctx, inRange, docChannel)
+			}
+			if !time.Unix(e.IntegratedTime, 0).Before(r.query.Since) {
+				inRange = append(inRange, e)
+			}
+		}
+		if err := r.emit(ctx, inRange, docChannel); err != nil {
+			return err
+		}
+		r.nextIndex += int64(len(indices))
+	}
+	return nil
+}
+
+// firstIndexSince returns the first log index integrated at or after the
+// query start, searching the log of the given size by bisection as entries
+// are integrated in index order
+func (r *rekorCollector) firstIndexSince(ctx context.Context, size int64) (int64, error) {
+	var searchErr error
+	index := sort.Search(int(size), func(i int) bool {
+		if searchErr != nil {
+			return true
+		}
+		entries, err := r.retrieve(ctx, map[string]interface{}{"logIndexes": []int64{int64(i)}})
+		if err != nil {
+			searchErr = err
+			return true
+		}
+		if len(entries) == 0 {
+			searchErr = fmt.Errorf("rekor entry %d not found", i)
+			return true
+		}
+		return !time.Unix(entries[0].IntegratedTime, 0).Before(r.query.Since)
+	})
+	return int64(index), searchErr
+}
+
+// logEntry is a Rekor log entry, as returned by the entries API
+type logEntry struct {
+	UUID           string `json:"-"`
+	Body           string `json:"body"`
+	IntegratedTime int64  `json:"integratedTime"`
+	LogIndex       int64  `json:"logIndex"`
+	Attestation    *struct {
+		Data string `json:"data"`
+	} `json:"attestation,omitempty"`
+}
+
+// retrieve fetches the entries matching the search request, sorted by log
+// index
+func (r *rekorCollector) retrieve(ctx context.Context, request map[string]interface{}) ([]logEntry, error) {
+	// each element of the response maps an entry UUID to the entry
+	var response []map[string]logEntry
+	if err := r.post(ctx, "/api/v1/log/entries/retrieve", request, &response); err != nil {
+		return nil, fmt.Errorf("failed to retrieve rekor entries: %w", err)
+	}
+	entries := []logEntry{}
+	for _, m := range response {
+		for uuid, e := range m {
+			e.UUID = uuid
+			entries = append(entries, e)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].LogIndex < entries[j].LogIndex })
+	return entries, nil
+}
+
+// emit sends the attestation of each entry not collected before. Entries
+// whose attestation cannot be read are logged and skipped.
+func (r *rekorCollector) emit(ctx context.Context, entries []logEntry, docChannel chan<- *processor.Document) error {
+	logger := logging.FromContext(ctx)
+	for _, e := range entries {
+		if r.seen[e.LogIndex] {
+			continue
+		}
+		r.seen[e.LogIndex] = true
+
+		content, err := parseEntry(e)
+		if err != nil {
+			logger.Warnf("skipping rekor entry %d (%s): %v", e.LogIndex, e.UUID, err)
+			continue
+		}
+		if !r.kinds[content.kind] || !r.matches(content.subjects) {
+			continue
+		}
+		if content.blob == nil {
+			logger.Warnf("skipping rekor entry %d (%s): no inline attestation in %s entry", e.LogIndex, e.UUID, content.kind)
+			continue
+		}
+		entryURL := fmt.Sprintf("%s/api/v1/log/entries/%s", r.url, e.UUID)
+		doc := &processor.Document{
+			Blob:   content.blob,
+			Type:   processor.DocumentUnknown,
+			Format: processor.FormatUnknown,
+			SourceInformation: processor.SourceInformation{
+				Collector: RekorCollector,
+				Source:    entryURL,
+				URI:       entryURL,
+			},
+		}
+		select {
+		case docChannel <- doc:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// subject is a subject of an entry, with its digests as `algorithm:value`
+type subject struct {
+	name    string
+	digests []string
+}
+
+// entryContent is the document of an entry and the subjects it is about
+type entryContent struct {
+	kind string
+	// blob is nil if the entry has no document to collect
+	blob     []byte
+	subjects []subject
+}
+
+// parseEntry returns the content of e. Rekor keeps in-toto attestations next
+// to the entry, while the body of `dsse` and `intoto` entries only has the
+// envelope inline when it was not too large. `hashedrekord` entries are
+// collected as a statement about their artifact digest.
+func parseEntry(e logEntry) (*entryContent, error) {
+	raw, err := base64.StdEncoding.DecodeString(e.Body)
+	if err != nil {
+		return nil, fmt.Errorf("invalid body encoding: %w", err)
+	}
+	var body struct {
+		Kind string          `json:"kind"`
+		Spec json.RawMessage `json:"spec"`
+	}
+	if err := json.Unmarshal(raw, &body); err != nil {
+		return nil, fmt.Errorf("invalid body: %w", err)
+	}
+	content := &entryContent{kind: body.Kind}
+	switch body.Kind {
+	case KindHashedRekord:
+		if content.blob, content.subjects, err = hashedRekordStatement(body.Spec); err != nil {
+			return nil, err
+		}
+		return content, nil
+	case KindIntoto, KindDSSE:
+	default:
+		return content, nil
+	}
+
+	if e.Attestation != nil && e.Attestation.Data != "" {
+		if content.blob, err = base64.StdEncoding.DecodeString(e.Attestation.Data); err != nil {
+			return nil, fmt.Errorf("invalid attestation encoding: %w", err)
+		}
+		content.subjects = statementSubjects(content.blob)
+		return content, nil
+	}
+	var spec struct {
+		Content struct {
+			Envelope json.RawMessage `json:"envelope"`
+		} `json:"content"`
+	}
+	var envelope struct {
+		Payload string `json:"payload"`
+	}
+	if json.Unmarshal(body.Spec, &spec) != nil || len(spec.Content.Envelope) == 0 ||
+		json.Unmarshal(spec.Content.Envelope, &envelope) != nil || envelope.Payload == "" {
+		return content, nil
+	}
+	content.blob = spec.Content.Envelope
+	if payload, err := base64.StdEncoding.DecodeString(envelope.Payload); err == nil {
+		content.subjects = statementSubjects(payload)
+	}
+	return content, nil
+}
+
+// statementSubjects returns the subjects of an in-toto statement, none if
+// it cannot be read
+func statementSubjects(statement []byte) []subject {
+	var s struct {
+		Subject []struct {
+			Name   string            `json:"name"`
+			Digest map[string]string `json:"digest"`
+		} `json:"subject"`
+	}
+	if json.Unmarshal(statement, &s) != nil {
+		return nil
+	}
+	subjects := []subject{}
+	for _, sub := range s.Subject {
+		digests := []string{}
+		for algorithm, value := range sub.Digest {
+			digests = append(digests, strings.ToLower(algorithm+":"+value))
+		}
+		subjects = append(subjects, subject{name: sub.Name, digests: digests})
+	}
+	return subjects
+}
+
+// hashedRekordStatement returns an in-toto statement with the artifact digest
+// of a `hashedrekord` entry as subject and its spec as predicate
+func hashedRekordStatement(spec json.RawMessage) ([]byte, []subject, error) {
+	var s struct {
+		Data struct {
+			Hash struct {
+				Algorithm string `json:"algorithm"`
+				Value     string `json:"value"`
+			} `json:"hash"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(spec, &s); err != nil || s.Data.Hash.Algorithm == "" || s.Data.Hash.Value == "" {
+		return nil, nil, errors.New("no artifact digest in hashedrekord entry")
+	}
+	digest := strings.ToLower(s.Data.Hash.Algorithm + ":" + s.Data.Hash.Value)
+	statement := map[string]interface{}{
+		"_type":         "https://in-toto.io/Statement/v0.1",
+		"predicateType": HashedRekordPredicateType,
+		"subject": []map[string]interface{}{{
+			"name":   digest,
+			"digest": map[string]string{strings.ToLower(s.Data.Hash.Algorithm): strings.ToLower(s.Data.Hash.Value)},
+		}},
+		"predicate": spec,
+	}
+	blob, err := json.Marshal(statement)
+	if err != nil {
+		return nil, nil, err
+	}
+	return blob, []subject{{name: digest, digests: []string{digest}}}, nil
+}
+
+// matches returns whether an entry with subjects is selected by the subject
+// restrictions of the query
+func (r *rekorCollector) matches(subjects []subject) bool {
+	if !r.query.filtered() {
+		return true
+	}
+	for _, s := range subjects {
+		if r.query.Subjects != nil && r.query.Subjects.MatchString(s.name) {
+			return true
+		}
+		for _, d := range s.digests {
+			if r.knownDigests[d] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// treeSize returns the number of entries in the log from its latest
+// checkpoint. Log indices continue across shards, so the entries of the
+// inactive shards are counted too.
+func (r *rekorCollector) treeSize(ctx context.Context) (int64, error) {
+	var info struct {
+		TreeSize       int64 `json:"treeSize"`
+		InactiveShards []struct {
+			TreeSize int64 `json:"treeSize"`
+		} `json:"inactiveShards"`
+	}
+	if err := r.do(ctx, http.MethodGet, "/api/v1/log", nil, &info); err != nil {
+		return 0, fmt.Errorf("failed to get rekor log info: %w", err)
+	}
+	size := info.TreeSize
+	for _, shard := range info.InactiveShards {
+		size += shard.TreeSize
+	}
+	return size, nil
+}
+
+func (r *rekorCollector) post(ctx context.Context, path string, request interface{}, response interface{}) error {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	return r.do(ctx, http.MethodPost, path, body, response)
+}
+
+func (r *rekorCollector) do(ctx context.Context, method string, path string, body []byte, response interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, r.url+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return json.NewDecoder(resp.Body).Decode(response)
+}
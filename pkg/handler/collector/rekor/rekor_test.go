@@ -0,0 +1,320 @@
+//
+// Copyright 2022 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rekor
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/guacsec/guac/pkg/handler/processor"
+)
+
+// fakeRekor serves entries i = 0..size-1, integrated at 1000+10*i, indexed
+// under the digest sha256:<i%3> of subject artifact<i%3>. Every fifth entry
+// has no inline attestation, the others alternate between attestation data
+// and an inline envelope. The entries from hashedRekordFrom, if set, are
+// hashedrekord entries. The first inactive entries are in an inactive shard.
+type fakeRekor struct {
+	size             int64
+	inactive         int64
+	hashedRekordFrom int64
+	requests         int
+}
+
+func (f *fakeRekor) uuid(i int64) string {
+	return fmt.Sprintf("uuid%d", i)
+}
+
+func (f *fakeRekor) entry(i int64) logEntry {
+	e := logEntry{IntegratedTime: 1000 + 10*i, LogIndex: i}
+	content := fmt.Sprintf(`{"entry": %d, "subject": [{"name": "artifact%d", "digest": {"sha256": "%d"}}]}`, i, i%3, i%3)
+	switch {
+	case f.hashedRekordFrom > 0 && i >= f.hashedRekordFrom:
+		e.Body = base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf(`{"kind": "hashedrekord", "spec": {"data": {"hash": {"algorithm": "sha256", "value": "%d"}}}}`, i%3)))
+	case i%5 == 4:
+		e.Body = base64.StdEncoding.EncodeToString([]byte(`{"kind": "dsse", "spec": {"envelopeHash": {}}}`))
+	case i%2 == 0:
+		e.Body = base64.StdEncoding.EncodeToString([]byte(`{"kind": "intoto", "spec": {}}`))
+		e.Attestation = &struct {
+			Data string `json:"data"`
+		}{Data: base64.StdEncoding.EncodeToString([]byte(content))}
+	default:
+		envelope := fmt.Sprintf(`{"payloadType": "application/vnd.in-toto+json", "payload": %q}`, base64.StdEncoding.EncodeToString([]byte(content)))
+		e.Body = base64.StdEncoding.EncodeToString([]byte(`{"kind": "intoto", "spec": {"content": {"envelope": ` + envelope + `}}}`))
+	}
+	return e
+}
+
+func (f *fakeRekor) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	f.requests++
+	switch req.URL.Path {
+	case "/api/v1/log":
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"treeSize":       f.size - f.inactive,
+			"inactiveShards": []map[string]int64{{"treeSize": f.inactive}},
+		})
+	case "/api/v1/index/retrieve":
+		var search struct {
+			Hash string `json:"hash"`
+		}
+		_ = json.NewDecoder(req.Body).Decode(&search)
+		uuids := []string{}
+		for i := int64(0); i < f.size; i++ {
+			if search.Hash == fmt.Sprintf("sha256:%d", i%3) {
+				uuids = append(uuids, f.uuid(i))
+			}
+		}
+		_ = json.NewEncoder(w).Encode(uuids)
+	case "/api/v1/log/entries/retrieve":
+		var search struct {
+			EntryUUIDs []string `json:"entryUUIDs"`
+			LogIndexes []int64  `json:"logIndexes"`
+		}
+		_ = json.NewDecoder(req.Body).Decode(&search)
+		if len(search.EntryUUIDs)+len(search.LogIndexes) > pageSize {
+			http.Error(w, "too many entries", http.StatusUnprocessableEntity)
+			return
+		}
+		response := []map[string]logEntry{}
+		for i := int64(0); i < f.size; i++ {
+			for _, u := range search.EntryUUIDs {
+				if u == f.uuid(i) {
+					response = append(response, map[string]logEntry{u: f.entry(i)})
+				}
+			}
+			for _, index := range search.LogIndexes {
+				if index == i {
+					response = append(response, map[string]logEntry{f.uuid(i): f.entry(i)})
+				}
+			}
+		}
+		_ = json.NewEncoder(w).Encode(response)
+	default:
+		http.NotFound(w, req)
+	}
+}
+
+// collectedEntries returns the entry numbers of the collected documents
+func collectedEntries(t *testing.T, docs []*processor.Document) []int {
+	t.Helper()
+	entries := []int{}
+	for _, d := range docs {
+		var content struct {
+			Entry   *int   `json:"entry"`
+			Payload string `json:"payload"`
+		}
+		if err := json.Unmarshal(d.Blob, &content); err != nil {
+			t.Fatalf("unexpected document %s: %v", d.Blob, err)
+		}
+		if content.Entry == nil {
+			payload, err := base64.StdEncoding.DecodeString(content.Payload)
+			if err != nil {
+				t.Fatalf("unexpected envelope %s: %v", d.Blob, err)
+			}
+			if err := json.Unmarshal(payload, &content); err != nil || content.Entry == nil {
+				t.Fatalf("unexpected envelope payload %s: %v", payload, err)
+			}
+		}
+		if d.SourceInformation.Collector != RekorCollector {
+			t.Errorf("unexpected collector %q", d.SourceInformation.Collector)
+		}
+		entries = append(entries, *content.Entry)
+	}
+	return entries
+}
+
+func collect(t *testing.T, c *rekorCollector) []*processor.Document {
+	t.Helper()
+	docChan := make(chan *processor.Document, 100)
+	if err := c.RetrieveArtifacts(context.Background(), docChan); err != nil {
+		t.Fatalf("RetrieveArtifacts() error = %v", err)
+	}
+	close(docChan)
+	docs := []*processor.Document{}
+	for d := range docChan {
+		docs = append(docs, d)
+	}
+	return docs
+}
+
+func TestRekorCollector_digests(t *testing.T) {
+	fake := &fakeRekor{size: 40}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	c, err := NewRekorCollector(context.Background(), server.URL, Query{Digests: []string{"sha256:1", "SHA256:2"}}, false, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := collectedEntries(t, collect(t, c))
+	sort.Ints(got)
+	// entries of digests 1 and 2, skipping every fifth entry
+	want := []int{1, 2, 5, 7, 8, 10, 11, 13, 16, 17, 20, 22, 23, 25, 26, 28, 31, 32, 35, 37, 38}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("collected entries %v, want %v", got, want)
+	}
+
+	// entries already collected are not collected again
+	if docs := collect(t, c); len(docs) != 0 {
+		t.Errorf("collected %d entries again", len(docs))
+	}
+}
+
+func TestRekorCollector_timeRange(t *testing.T) {
+	fake := &fakeRekor{size: 100}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	c, err := NewRekorCollector(context.Background(), server.URL, Query{Since: time.Unix(1205, 0), Until: time.Unix(1450, 0)}, true, time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// polling ends once the end of the range is reached
+	got := collectedEntries(t, collect(t, c))
+	want := []int{}
+	for i := 21; i <= 45; i++ {
+		if i%5 != 4 {
+			want = append(want, i)
+		}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("collected entries %v, want %v", got, want)
+	}
+}
+
+func TestRekorCollector_openRange(t *testing.T) {
+	fake := &fakeRekor{size: 12}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	c, err := NewRekorCollector(context.Background(), server.URL, Query{Since: time.Unix(1090, 0)}, false, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := collectedEntries(t, collect(t, c)), []int{10, 11}; !reflect.DeepEqual(got, want) {
+		t.Errorf("collected entries %v, want %v", got, want)
+	}
+
+	// the next collection continues from the end of the log
+	fake.size = 15
+	if got, want := collectedEntries(t, collect(t, c)), []int{12, 13}; !reflect.DeepEqual(got, want) {
+		t.Errorf("collected entries %v, want %v", got, want)
+	}
+}
+
+func TestNewRekorCollector_invalidQuery(t *testing.T) {
+	for _, q := range []Query{
+		{},
+		{Digests: []string{"sha256:1"}, Since: time.Unix(1, 0)},
+		{Since: time.Unix(10, 0), Until: time.Unix(1, 0)},
+		{Digests: []string{"abc"}},
+		{Tail: true, Since: time.Unix(1, 0)},
+		{Tail: true, Until: time.Unix(1, 0)},
+		{Digests: []string{"sha256:1"}, Subjects: regexp.MustCompile(`.`)},
+		{Tail: true, Kinds: []string{"rekord"}},
+	} {
+		if _, err := NewRekorCollector(context.Background(), DefaultRekorURL, q, false, time.Second); err == nil {
+			t.Errorf("expected an error for query %+v", q)
+		}
+	}
+}
+
+func TestRekorCollector_tail(t *testing.T) {
+	fake := &fakeRekor{size: 12, inactive: 5}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	c, err := NewRekorCollector(context.Background(), server.URL, Query{Tail: true}, false, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// tailing starts at the end of the log, across shards
+	if docs := collect(t, c); len(docs) != 0 {
+		t.Errorf("collected %d entries before the log grew", len(docs))
+	}
+	fake.size = 16
+	if got, want := collectedEntries(t, collect(t, c)), []int{12, 13, 15}; !reflect.DeepEqual(got, want) {
+		t.Errorf("collected entries %v, want %v", got, want)
+	}
+}
+
+func TestRekorCollector_subjects(t *testing.T) {
+	fake := &fakeRekor{size: 12}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	knownCalls := 0
+	query := Query{
+		Since:    time.Unix(1000, 0),
+		Subjects: regexp.MustCompile(`^artifact1$`),
+		KnownSubjects: func(ctx context.Context) ([]string, error) {
+			knownCalls++
+			return []string{"SHA256:2"}, nil
+		},
+	}
+	c, err := NewRekorCollector(context.Background(), server.URL, query, false, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// entries of artifact1 by name and of artifact2 by digest
+	if got, want := collectedEntries(t, collect(t, c)), []int{1, 2, 5, 7, 8, 10, 11}; !reflect.DeepEqual(got, want) {
+		t.Errorf("collected entries %v, want %v", got, want)
+	}
+	if knownCalls != 1 {
+		t.Errorf("known subjects listed %d times, want 1", knownCalls)
+	}
+}
+
+func TestRekorCollector_hashedRekord(t *testing.T) {
+	fake := &fakeRekor{size: 6, hashedRekordFrom: 3}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	c, err := NewRekorCollector(context.Background(), server.URL, Query{Since: time.Unix(1000, 0), Kinds: []string{KindHashedRekord}}, false, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	docs := collect(t, c)
+	got := []string{}
+	for _, d := range docs {
+		var statement struct {
+			PredicateType string `json:"predicateType"`
+			Subject       []struct {
+				Digest map[string]string `json:"digest"`
+			} `json:"subject"`
+		}
+		if err := json.Unmarshal(d.Blob, &statement); err != nil || len(statement.Subject) != 1 {
+			t.Fatalf("unexpected document %s: %v", d.Blob, err)
+		}
+		if statement.PredicateType != HashedRekordPredicateType {
+			t.Errorf("unexpected predicate type %q", statement.PredicateType)
+		}
+		got = append(got, statement.Subject[0].Digest["sha256"])
+	}
+	// only the hashedrekord entries 3, 4 and 5 are collected
+	if want := []string{"0", "1", "2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("collected digests %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,157 @@
+//
+// Copyright 2022 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Policy is the policy collectors use to retry reading a document before
+// giving up on it
+type Policy struct {
+	// Attempts is the maximum number of reads of a document, at least 1
+	Attempts int
+	// Backoff is the delay before the first retry, doubled after each
+	// retry up to MaxBackoff
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+// DefaultPolicy retries a failed read twice, after 100ms and 200ms
+var DefaultPolicy = Policy{
+	Attempts:   3,
+	Backoff:    100 * time.Millisecond,
+	MaxBackoff: 5 * time.Second,
+}
+
+// ErrDocumentRead is wrapped by the errors reported for the documents a
+// collector failed to read after retrying
+var ErrDocumentRead = errors.New("failed to read document")
+
+var (
+	policyLock sync.RWMutex
+	policy     = DefaultPolicy
+)
+
+// Validate returns an error if the policy would never read a document or
+// wait a negative duration
+func (p Policy) Validate() error {
+	if p.Attempts < 1 {
+		return fmt.Errorf("retry attempts must be at least 1, got %d", p.Attempts)
+	}
+	if p.Backoff < 0 || p.MaxBackoff < 0 {
+		return fmt.Errorf("retry backoff must not be negative")
+	}
+	return nil
+}
+
+// SetPolicy sets the policy used by ReadDocument
+func SetPolicy(p Policy) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	policyLock.Lock()
+	defer policyLock.Unlock()
+	policy = p
+	return nil
+}
+
+// GetPolicy returns the policy used by ReadDocument
+func GetPolicy() Policy {
+	policyLock.RLock()
+	defer policyLock.RUnlock()
+	return policy
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
+
+// Permanent marks err as not worth retrying, e.g. for a document that no
+// longer exists
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// Do calls op until it succeeds, returns a permanent error or the attempts of
+// the policy are exhausted, waiting for the backoff between calls. The error
+// of the last call is returned, or the context error if ctx is done first.
+func (p Policy) Do(ctx context.Context, op func() error) error {
+	backoff := p.Backoff
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = op()
+		var permanent *permanentError
+		if err == nil || errors.As(err, &permanent) || attempt >= p.Attempts {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if p.MaxBackoff > 0 && backoff > p.MaxBackoff {
+			backoff = p.MaxBackoff
+		}
+	}
+}
+
+// ReadDocument calls read with the policy set by SetPolicy. If the document
+// cannot be read, the error (wrapping ErrDocumentRead) is passed to the
+// reporter of ctx and returned, for the collector to skip the document.
+func ReadDocument(ctx context.Context, source string, read func() ([]byte, error)) ([]byte, error) {
+	var blob []byte
+	err := GetPolicy().Do(ctx, func() error {
+		var err error
+		blob, err = read()
+		return err
+	})
+	if err == nil {
+		return blob, nil
+	}
+	if ctx.Err() != nil {
+		return nil, err
+	}
+	err = fmt.Errorf("%w: %s: %v", ErrDocumentRead, source, err)
+	if report, ok := ctx.Value(readErrorReporterKey{}).(func(error)); ok {
+		report(err)
+	}
+	return nil, err
+}
+
+type readErrorReporterKey struct{}
+
+// WithReadErrorReporter returns a context for collectors whose read errors
+// are passed to report
+func WithReadErrorReporter(ctx context.Context, report func(error)) context.Context {
+	return context.WithValue(ctx, readErrorReporterKey{}, report)
+}
@@ -0,0 +1,133 @@
+//
+// Copyright 2022 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package retry
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPolicyDo(t *testing.T) {
+	errTransient := errors.New("transient")
+	p := Policy{Attempts: 3, Backoff: time.Millisecond, MaxBackoff: 2 * time.Millisecond}
+	tests := []struct {
+		name      string
+		failures  int
+		err       error
+		wantCalls int
+		wantErr   bool
+	}{{
+		name:      "success",
+		wantCalls: 1,
+	}, {
+		name:      "transient failure",
+		failures:  2,
+		err:       errTransient,
+		wantCalls: 3,
+	}, {
+		name:      "persistent failure",
+		failures:  5,
+		err:       errTransient,
+		wantCalls: 3,
+		wantErr:   true,
+	}, {
+		name:      "permanent failure",
+		failures:  5,
+		err:       Permanent(errTransient),
+		wantCalls: 1,
+		wantErr:   true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			err := p.Do(context.Background(), func() error {
+				calls++
+				if calls <= tt.failures {
+					return tt.err
+				}
+				return nil
+			})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Do() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, errTransient) {
+				t.Errorf("Do() error = %v, want it to wrap %v", err, errTransient)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("Do() called op %d times, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestPolicyDoCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := Policy{Attempts: 3, Backoff: time.Hour}
+	calls := 0
+	err := p.Do(ctx, func() error {
+		calls++
+		cancel()
+		return errors.New("transient")
+	})
+	if !errors.Is(err, context.Canceled) || calls != 1 {
+		t.Errorf("Do() = %v after %d calls, want context.Canceled after 1", err, calls)
+	}
+}
+
+func TestReadDocument(t *testing.T) {
+	if err := SetPolicy(Policy{Attempts: 2}); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = SetPolicy(DefaultPolicy)
+	}()
+
+	var reported []error
+	ctx := WithReadErrorReporter(context.Background(), func(err error) {
+		reported = append(reported, err)
+	})
+
+	blob, err := ReadDocument(ctx, "good", func() ([]byte, error) {
+		return []byte("hello"), nil
+	})
+	if err != nil || string(blob) != "hello" {
+		t.Errorf("ReadDocument() = %q, %v, want hello", blob, err)
+	}
+
+	_, err = ReadDocument(ctx, "bad", func() ([]byte, error) {
+		return nil, errors.New("input/output error")
+	})
+	if !errors.Is(err, ErrDocumentRead) {
+		t.Errorf("ReadDocument() error = %v, want it to wrap ErrDocumentRead", err)
+	}
+	if len(reported) != 1 || reported[0] != err {
+		t.Errorf("reported %v, want %v", reported, err)
+	}
+}
+
+func TestSetPolicy(t *testing.T) {
+	if err := SetPolicy(Policy{Attempts: 0}); err == nil {
+		t.Error("SetPolicy() expected error for no attempts")
+	}
+	if err := SetPolicy(Policy{Attempts: 1, Backoff: -time.Second}); err == nil {
+		t.Error("SetPolicy() expected error for negative backoff")
+	}
+	if got := GetPolicy(); got != DefaultPolicy {
+		t.Errorf("GetPolicy() = %v after invalid policies, want %v", got, DefaultPolicy)
+	}
+}
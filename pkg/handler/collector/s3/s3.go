@@ -0,0 +1,244 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package s3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	awss3 "github.com/aws/aws-sdk-go/service/s3"
+
+	"github.com/guacsec/guac/pkg/handler/collector/retry"
+	"github.com/guacsec/guac/pkg/handler/processor"
+	"github.com/guacsec/guac/pkg/logging"
+)
+
+const (
+	CollectorS3 = "S3"
+	// defaultEndpointRegion is the region used with a custom endpoint when
+	// none is configured, as S3 compatible stores such as MinIO accept any
+	defaultEndpointRegion = "us-east-1"
+)
+
+type s3Collector struct {
+	bucket string
+	reader objectReader
+	// etags of the objects already collected, by key
+	etags    map[string]string
+	poll     bool
+	interval time.Duration
+}
+
+// object is an object listed in the bucket
+type object struct {
+	key  string
+	etag string
+}
+
+type objectReader interface {
+	listObjects(ctx context.Context) ([]object, error)
+	getReader(ctx context.Context, key string) (io.ReadCloser, error)
+}
+
+type reader struct {
+	client *awss3.S3
+	bucket string
+	// prefix of the objects to list
+	prefix string
+}
+
+type options struct {
+	endpoint string
+	region   string
+}
+
+// Option configures the collector returned by NewS3Collector
+type Option func(*options)
+
+// WithEndpoint sets the endpoint of an S3 compatible store, e.g.
+// http://minio:9000, addressing the buckets by path
+func WithEndpoint(endpoint string) Option {
+	return func(o *options) {
+		o.endpoint = endpoint
+	}
+}
+
+// WithRegion sets the region of the bucket, overriding the region of the
+// environment and shared config
+func WithRegion(region string) Option {
+	return func(o *options) {
+		o.region = region
+	}
+}
+
+// NewS3Collector returns a collector for the objects of bucket whose key
+// starts with prefix, authenticating with the default AWS credential chain:
+// the environment, the shared credentials and config files, then the
+// container or instance role. With a non zero pollInterval, the bucket is
+// polled and only objects that are new or have a new etag since the last
+// poll are collected.
+func NewS3Collector(ctx context.Context, bucket string, prefix string, pollInterval time.Duration, opts ...Option) (*s3Collector, error) {
+	if bucket == "" {
+		return nil, errors.New("s3 bucket not specified")
+	}
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	cfg := aws.NewConfig()
+	if o.region != "" {
+		cfg = cfg.WithRegion(o.region)
+	}
+	if o.endpoint != "" {
+		cfg = cfg.WithEndpoint(o.endpoint).WithS3ForcePathStyle(true)
+	}
+	sess, err := session.NewSessionWithOptions(session.Options{
+		Config:            *cfg,
+		SharedConfigState: session.SharedConfigEnable,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create s3 session: %w", err)
+	}
+	if o.endpoint != "" && aws.StringValue(sess.Config.Region) == "" {
+		sess.Config.Region = aws.String(defaultEndpointRegion)
+	}
+	return &s3Collector{
+		bucket: bucket,
+		reader: &reader{
+			client: awss3.New(sess),
+			bucket: bucket,
+			prefix: prefix,
+		},
+		poll:     pollInterval > 0,
+		interval: pollInterval,
+	}, nil
+}
+
+// Type is the collector type of the collector
+func (s *s3Collector) Type() string {
+	return CollectorS3
+}
+
+func (r *reader) listObjects(ctx context.Context) ([]object, error) {
+	objects := []object{}
+	input := &awss3.ListObjectsV2Input{
+		Bucket: aws.String(r.bucket),
+		Prefix: aws.String(r.prefix),
+	}
+	err := r.client.ListObjectsV2PagesWithContext(ctx, input, func(page *awss3.ListObjectsV2Output, lastPage bool) bool {
+		for _, o := range page.Contents {
+			objects = append(objects, object{key: aws.StringValue(o.Key), etag: aws.StringValue(o.ETag)})
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return objects, nil
+}
+
+func (r *reader) getReader(ctx context.Context, key string) (io.ReadCloser, error) {
+	out, err := r.client.GetObjectWithContext(ctx, &awss3.GetObjectInput{
+		Bucket: aws.String(r.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out.Body, nil
+}
+
+// RetrieveArtifacts get the artifacts from the collector source based on polling or one time
+func (s *s3Collector) RetrieveArtifacts(ctx context.Context, docChannel chan<- *processor.Document) error {
+	if s.reader == nil {
+		return errors.New("s3 collector not initialized")
+	}
+	if err := s.getArtifacts(ctx, docChannel); err != nil {
+		return err
+	}
+	if !s.poll {
+		return nil
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(s.interval):
+		}
+		if err := s.getArtifacts(ctx, docChannel); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *s3Collector) getArtifacts(ctx context.Context, docChannel chan<- *processor.Document) error {
+	logger := logging.FromContext(ctx)
+	if s.etags == nil {
+		s.etags = map[string]string{}
+	}
+	objects, err := s.reader.listObjects(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list objects of bucket: %s, error: %w", s.bucket, err)
+	}
+	for _, o := range objects {
+		if etag, ok := s.etags[o.key]; ok && etag == o.etag {
+			continue
+		}
+		uri := "s3://" + s.bucket + "/" + o.key
+		payload, err := retry.ReadDocument(ctx, uri, func() ([]byte, error) {
+			payload, err := s.getObject(ctx, o.key)
+			var awsErr awserr.Error
+			if errors.As(err, &awsErr) && awsErr.Code() == awss3.ErrCodeNoSuchKey {
+				return nil, retry.Permanent(err)
+			}
+			return payload, err
+		})
+		if err != nil {
+			logger.Warnf("failed to retrieve object: %s from bucket: %s: %v", o.key, s.bucket, err)
+			continue
+		}
+		s.etags[o.key] = o.etag
+		if len(payload) == 0 {
+			continue
+		}
+		docChannel <- &processor.Document{
+			Blob:   payload,
+			Type:   processor.DocumentUnknown,
+			Format: processor.FormatUnknown,
+			SourceInformation: processor.SourceInformation{
+				Collector: CollectorS3,
+				Source:    s.bucket + "/" + o.key,
+				URI:       uri,
+			},
+		}
+	}
+	return nil
+}
+
+func (s *s3Collector) getObject(ctx context.Context, key string) ([]byte, error) {
+	reader, err := s.reader.getReader(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
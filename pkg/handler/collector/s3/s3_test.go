@@ -0,0 +1,137 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package s3
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/guacsec/guac/pkg/handler/processor"
+)
+
+type fakeObject struct {
+	key     string
+	etag    string
+	content string
+}
+
+type fakeReader struct {
+	objects []fakeObject
+}
+
+func (f *fakeReader) listObjects(ctx context.Context) ([]object, error) {
+	objects := []object{}
+	for _, o := range f.objects {
+		objects = append(objects, object{key: o.key, etag: o.etag})
+	}
+	return objects, nil
+}
+
+func (f *fakeReader) getReader(ctx context.Context, key string) (io.ReadCloser, error) {
+	for _, o := range f.objects {
+		if o.key == key {
+			return io.NopCloser(bytes.NewBufferString(o.content)), nil
+		}
+	}
+	return nil, errors.New("no such key")
+}
+
+func collect(t *testing.T, s *s3Collector) []*processor.Document {
+	t.Helper()
+	docChannel := make(chan *processor.Document, 10)
+	if err := s.getArtifacts(context.Background(), docChannel); err != nil {
+		t.Fatalf("getArtifacts() error = %v", err)
+	}
+	close(docChannel)
+	docs := []*processor.Document{}
+	for d := range docChannel {
+		docs = append(docs, d)
+	}
+	return docs
+}
+
+func doc(key string, content string) *processor.Document {
+	return &processor.Document{
+		Blob:   []byte(content),
+		Type:   processor.DocumentUnknown,
+		Format: processor.FormatUnknown,
+		SourceInformation: processor.SourceInformation{
+			Collector: CollectorS3,
+			Source:    "sboms/" + key,
+			URI:       "s3://sboms/" + key,
+		},
+	}
+}
+
+func TestS3_getArtifacts(t *testing.T) {
+	r := &fakeReader{objects: []fakeObject{
+		{key: "ci/a.spdx.json", etag: `"1"`, content: "a"},
+		{key: "ci/empty.json", etag: `"2"`},
+	}}
+	s := &s3Collector{bucket: "sboms", reader: r}
+
+	if got, want := collect(t, s), []*processor.Document{doc("ci/a.spdx.json", "a")}; !reflect.DeepEqual(got, want) {
+		t.Errorf("first poll = %v, want %v", got, want)
+	}
+
+	// unchanged objects are skipped, new and overwritten ones collected
+	r.objects = append(r.objects, fakeObject{key: "ci/b.spdx.json", etag: `"3"`, content: "b"})
+	if got, want := collect(t, s), []*processor.Document{doc("ci/b.spdx.json", "b")}; !reflect.DeepEqual(got, want) {
+		t.Errorf("second poll = %v, want %v", got, want)
+	}
+	r.objects[0] = fakeObject{key: "ci/a.spdx.json", etag: `"4"`, content: "a2"}
+	if got, want := collect(t, s), []*processor.Document{doc("ci/a.spdx.json", "a2")}; !reflect.DeepEqual(got, want) {
+		t.Errorf("third poll = %v, want %v", got, want)
+	}
+}
+
+func TestS3_RetrieveArtifacts(t *testing.T) {
+	if err := (&s3Collector{}).RetrieveArtifacts(context.Background(), make(chan *processor.Document)); err == nil {
+		t.Errorf("RetrieveArtifacts() without reader expected error")
+	}
+
+	// polling ends with the context
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &s3Collector{
+		bucket:   "sboms",
+		reader:   &fakeReader{objects: []fakeObject{{key: "a.json", etag: `"1"`, content: "a"}}},
+		poll:     true,
+		interval: time.Millisecond,
+	}
+	docChannel := make(chan *processor.Document, 1)
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- s.RetrieveArtifacts(ctx, docChannel)
+	}()
+	if got, want := <-docChannel, doc("a.json", "a"); !reflect.DeepEqual(got, want) {
+		t.Errorf("RetrieveArtifacts() = %v, want %v", got, want)
+	}
+	cancel()
+	if err := <-errChan; err != nil {
+		t.Errorf("RetrieveArtifacts() error = %v", err)
+	}
+}
+
+func TestNewS3Collector(t *testing.T) {
+	if _, err := NewS3Collector(context.Background(), "", "", 0); err == nil {
+		t.Errorf("NewS3Collector() without bucket expected error")
+	}
+}
@@ -0,0 +1,187 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package csaf
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/guacsec/guac/pkg/handler/processor"
+)
+
+// CategoryVEX is the document category of CSAF VEX documents
+const CategoryVEX = "csaf_vex"
+
+// CSAF is a CSAF document, of which the VEX profile is supported. See
+// https://docs.oasis-open.org/csaf/csaf/v2.0/csaf-v2.0.html
+type CSAF struct {
+	Document struct {
+		Category    string `json:"category"`
+		CsafVersion string `json:"csaf_version"`
+		Title       string `json:"title"`
+		Tracking    struct {
+			ID                 string `json:"id"`
+			CurrentReleaseDate string `json:"current_release_date"`
+		} `json:"tracking"`
+	} `json:"document"`
+	ProductTree     ProductTree     `json:"product_tree"`
+	Vulnerabilities []Vulnerability `json:"vulnerabilities"`
+}
+
+// ProductTree holds the products the vulnerabilities refer to by product id,
+// in a tree of branches, as full product names or as relationships between
+// products
+type ProductTree struct {
+	Branches         []Branch          `json:"branches"`
+	FullProductNames []FullProductName `json:"full_product_names"`
+	Relationships    []struct {
+		Category        string          `json:"category"`
+		FullProductName FullProductName `json:"full_product_name"`
+	} `json:"relationships"`
+}
+
+// Branch is a branch of the product tree, either a leaf with a product or a
+// node with more branches
+type Branch struct {
+	Category string           `json:"category"`
+	Name     string           `json:"name"`
+	Branches []Branch         `json:"branches"`
+	Product  *FullProductName `json:"product"`
+}
+
+// FullProductName is a product and the identifiers that name it
+type FullProductName struct {
+	ProductID                   string `json:"product_id"`
+	Name                        string `json:"name"`
+	ProductIdentificationHelper *struct {
+		Purl   string `json:"purl"`
+		Hashes []struct {
+			FileName   string `json:"filename"`
+			FileHashes []struct {
+				Algorithm string `json:"algorithm"`
+				Value     string `json:"value"`
+			} `json:"file_hashes"`
+		} `json:"hashes"`
+	} `json:"product_identification_helper"`
+}
+
+// Products returns the products of the tree keyed by product id
+func (t ProductTree) Products() map[string]FullProductName {
+	products := map[string]FullProductName{}
+	var walk func(branches []Branch)
+	walk = func(branches []Branch) {
+		for _, b := range branches {
+			if b.Product != nil {
+				products[b.Product.ProductID] = *b.Product
+			}
+			walk(b.Branches)
+		}
+	}
+	walk(t.Branches)
+	for _, p := range t.FullProductNames {
+		products[p.ProductID] = p
+	}
+	for _, r := range t.Relationships {
+		products[r.FullProductName.ProductID] = r.FullProductName
+	}
+	return products
+}
+
+// Vulnerability is a vulnerability and its status for the products
+type Vulnerability struct {
+	CVE string `json:"cve"`
+	IDs []struct {
+		SystemName string `json:"system_name"`
+		Text       string `json:"text"`
+	} `json:"ids"`
+	ProductStatus map[string][]string `json:"product_status"`
+	Flags         []struct {
+		Label      string   `json:"label"`
+		ProductIDs []string `json:"product_ids"`
+	} `json:"flags"`
+	Threats []struct {
+		Category   string   `json:"category"`
+		Details    string   `json:"details"`
+		ProductIDs []string `json:"product_ids"`
+	} `json:"threats"`
+}
+
+// ID returns the CVE of the vulnerability, or else its first id
+func (v Vulnerability) ID() string {
+	if v.CVE != "" {
+		return v.CVE
+	}
+	if len(v.IDs) > 0 {
+		return v.IDs[0].Text
+	}
+	return ""
+}
+
+// ParseCSAF parses a CSAF document
+func ParseCSAF(blob []byte) (*CSAF, error) {
+	var csaf CSAF
+	if err := json.Unmarshal(blob, &csaf); err != nil {
+		return nil, err
+	}
+	return &csaf, nil
+}
+
+// CSAFProcessor processes CSAF VEX documents.
+// Currently only supports JSON CSAF documents
+type CSAFProcessor struct {
+}
+
+func (p *CSAFProcessor) ValidateSchema(d *processor.Document) error {
+	if d.Type != processor.DocumentCsaf {
+		return fmt.Errorf("expected document type: %v, actual document type: %v", processor.DocumentCsaf, d.Type)
+	}
+
+	switch d.Format {
+	case processor.FormatJSON:
+		csaf, err := ParseCSAF(d.Blob)
+		if err != nil {
+			return err
+		}
+		if csaf.Document.Category != CategoryVEX {
+			return fmt.Errorf("unsupported CSAF document category: %q", csaf.Document.Category)
+		}
+		if csaf.Document.CsafVersion == "" {
+			return fmt.Errorf("missing required CSAF fields")
+		}
+		for i, v := range csaf.Vulnerabilities {
+			if v.ID() == "" {
+				return fmt.Errorf("vulnerability %d has no id", i)
+			}
+		}
+		return nil
+	}
+
+	return fmt.Errorf("unable to support parsing of CSAF document format: %v", d.Format)
+}
+
+// Unpack takes in the document and tries to unpack it
+// if there is a valid decomposition of sub-documents.
+//
+// Returns empty list and nil error if nothing to unpack
+// Returns unpacked list and nil error if successfully unpacked
+func (p *CSAFProcessor) Unpack(d *processor.Document) ([]*processor.Document, error) {
+	if d.Type != processor.DocumentCsaf {
+		return nil, fmt.Errorf("expected document type: %v, actual document type: %v", processor.DocumentCsaf, d.Type)
+	}
+
+	// CSAF doesn't unpack into additional documents at the moment.
+	return []*processor.Document{}, nil
+}
@@ -0,0 +1,102 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package csaf
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/guacsec/guac/internal/testing/testdata"
+	"github.com/guacsec/guac/pkg/handler/processor"
+)
+
+func TestCSAFProcessor_ValidateSchema(t *testing.T) {
+	testCases := []struct {
+		name      string
+		doc       processor.Document
+		expectErr bool
+	}{{
+		name: "CSAF VEX document",
+		doc: processor.Document{
+			Blob:   testdata.CsafVEXExample,
+			Format: processor.FormatJSON,
+			Type:   processor.DocumentCsaf,
+		},
+	}, {
+		name: "security advisory",
+		doc: processor.Document{
+			Blob:   []byte(`{"document": {"category": "csaf_security_advisory", "csaf_version": "2.0"}}`),
+			Format: processor.FormatJSON,
+			Type:   processor.DocumentCsaf,
+		},
+		expectErr: true,
+	}, {
+		name: "vulnerability without id",
+		doc: processor.Document{
+			Blob:   []byte(`{"document": {"category": "csaf_vex", "csaf_version": "2.0"}, "vulnerabilities": [{"product_status": {}}]}`),
+			Format: processor.FormatJSON,
+			Type:   processor.DocumentCsaf,
+		},
+		expectErr: true,
+	}, {
+		name: "unsupported format",
+		doc: processor.Document{
+			Blob:   testdata.CsafVEXExample,
+			Format: processor.FormatXML,
+			Type:   processor.DocumentCsaf,
+		},
+		expectErr: true,
+	}}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			d := CSAFProcessor{}
+			if err := d.ValidateSchema(&tt.doc); (err != nil) != tt.expectErr {
+				t.Errorf("CSAFProcessor.ValidateSchema() error = %v, expectErr %v", err, tt.expectErr)
+			}
+		})
+	}
+}
+
+func TestCSAFProcessor_Unpack(t *testing.T) {
+	d := CSAFProcessor{}
+	actual, err := d.Unpack(&processor.Document{Blob: testdata.CsafVEXExample, Format: processor.FormatJSON, Type: processor.DocumentCsaf})
+	if err != nil {
+		t.Fatalf("CSAFProcessor.Unpack() error = %v", err)
+	}
+	if !reflect.DeepEqual(actual, []*processor.Document{}) {
+		t.Errorf("CSAFProcessor.Unpack() = %v, expected no documents", actual)
+	}
+}
+
+func TestProductTree_Products(t *testing.T) {
+	csaf, err := ParseCSAF(testdata.CsafVEXExample)
+	if err != nil {
+		t.Fatal(err)
+	}
+	products := csaf.ProductTree.Products()
+	names := map[string]string{}
+	for id, p := range products {
+		names[id] = p.Name
+	}
+	want := map[string]string{
+		"CSAFPID-0001": "Example Product 4.2",
+		"CSAFPID-0002": "Example Product 4.3",
+		"CSAFPID-0003": "Example Library 1.0",
+	}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("Products() = %v, want %v", names, want)
+	}
+}
@@ -24,7 +24,7 @@ import (
 )
 
 // CycloneDXProcessor processes CycloneDXProcessor documents.
-// Currently only supports CycloneDX-JSON documents
+// Supports CycloneDX-JSON and CycloneDX-XML documents
 type CycloneDXProcessor struct {
 }
 
@@ -40,6 +40,12 @@ func (p *CycloneDXProcessor) ValidateSchema(d *processor.Document) error {
 		decoder := cdx.NewBOMDecoder(reader, cdx.BOMFileFormatJSON)
 		err := decoder.Decode(bom)
 		return err
+	case processor.FormatXML:
+		reader := bytes.NewReader(d.Blob)
+		bom := new(cdx.BOM)
+		decoder := cdx.NewBOMDecoder(reader, cdx.BOMFileFormatXML)
+		err := decoder.Decode(bom)
+		return err
 	}
 
 	return fmt.Errorf("unable to support parsing of CycloneDX document format: %v", d.Format)
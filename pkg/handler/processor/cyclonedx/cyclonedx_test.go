@@ -98,6 +98,15 @@ func TestCycloneDXProcessor_ValidateSchema(t *testing.T) {
 			SourceInformation: processor.SourceInformation{},
 		},
 		expectErr: false,
+	}, {
+		name: "valid CycloneDX XML document",
+		doc: processor.Document{
+			Blob:              testdata.CycloneDXExampleSmallDepsXML,
+			Format:            processor.FormatXML,
+			Type:              processor.DocumentCycloneDX,
+			SourceInformation: processor.SourceInformation{},
+		},
+		expectErr: false,
 	}, {
 		name: "invalid CycloneDX document",
 		doc: processor.Document{
@@ -0,0 +1,124 @@
+//
+// Copyright 2022 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package depsnapshot
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/guacsec/guac/pkg/handler/processor"
+)
+
+// Relationships of a resolved dependency to the manifest
+const (
+	RelationshipDirect   = "direct"
+	RelationshipIndirect = "indirect"
+)
+
+// Snapshot is a GitHub dependency submission snapshot, the dependencies
+// resolved from one or more manifests of a repository at a commit. See
+// https://docs.github.com/en/rest/dependency-graph/dependency-submission
+type Snapshot struct {
+	Version  int    `json:"version"`
+	Sha      string `json:"sha"`
+	Ref      string `json:"ref"`
+	Detector struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+		URL     string `json:"url"`
+	} `json:"detector"`
+	Scanned   string              `json:"scanned"`
+	Manifests map[string]Manifest `json:"manifests"`
+}
+
+// Manifest is a manifest file and the dependencies resolved from it, keyed
+// by a name unique within the manifest
+type Manifest struct {
+	Name string `json:"name"`
+	File *struct {
+		SourceLocation string `json:"source_location"`
+	} `json:"file"`
+	Resolved map[string]Dependency `json:"resolved"`
+}
+
+// Path returns the path of the manifest file in the repository, or its name
+// if the snapshot does not give one
+func (m Manifest) Path() string {
+	if m.File != nil && m.File.SourceLocation != "" {
+		return m.File.SourceLocation
+	}
+	return m.Name
+}
+
+// Dependency is a package resolved from a manifest
+type Dependency struct {
+	PackageURL string `json:"package_url"`
+	// Relationship is RelationshipDirect if the manifest declares the
+	// dependency, RelationshipIndirect if it is pulled in by another one
+	Relationship string `json:"relationship"`
+	Scope        string `json:"scope"`
+	// Dependencies are the dependencies of the package, given by their key
+	// in the resolved dependencies of the manifest or by their purl
+	Dependencies []string `json:"dependencies"`
+}
+
+// ParseSnapshot parses a dependency snapshot
+func ParseSnapshot(blob []byte) (*Snapshot, error) {
+	var snapshot Snapshot
+	if err := json.Unmarshal(blob, &snapshot); err != nil {
+		return nil, err
+	}
+	return &snapshot, nil
+}
+
+// DependencySnapshotProcessor processes GitHub dependency submission
+// snapshots. Only JSON snapshots are supported.
+type DependencySnapshotProcessor struct {
+}
+
+func (p *DependencySnapshotProcessor) ValidateSchema(d *processor.Document) error {
+	if d.Type != processor.DocumentDependencySnapshot {
+		return fmt.Errorf("expected document type: %v, actual document type: %v", processor.DocumentDependencySnapshot, d.Type)
+	}
+
+	switch d.Format {
+	case processor.FormatJSON:
+		snapshot, err := ParseSnapshot(d.Blob)
+		if err != nil {
+			return err
+		}
+		if snapshot.Detector.Name == "" || snapshot.Sha == "" || snapshot.Manifests == nil {
+			return fmt.Errorf("missing required dependency snapshot fields")
+		}
+		return nil
+	}
+
+	return fmt.Errorf("unable to support parsing of dependency snapshot format: %v", d.Format)
+}
+
+// Unpack takes in the document and tries to unpack it
+// if there is a valid decomposition of sub-documents.
+//
+// Returns empty list and nil error if nothing to unpack
+// Returns unpacked list and nil error if successfully unpacked
+func (p *DependencySnapshotProcessor) Unpack(d *processor.Document) ([]*processor.Document, error) {
+	if d.Type != processor.DocumentDependencySnapshot {
+		return nil, fmt.Errorf("expected document type: %v, actual document type: %v", processor.DocumentDependencySnapshot, d.Type)
+	}
+
+	// the manifests of a snapshot are parsed together
+	return []*processor.Document{}, nil
+}
@@ -0,0 +1,113 @@
+//
+// Copyright 2022 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package depsnapshot
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/guacsec/guac/internal/testing/testdata"
+	"github.com/guacsec/guac/pkg/handler/processor"
+)
+
+func TestDependencySnapshotProcessor_Unpack(t *testing.T) {
+	testCases := []struct {
+		name      string
+		doc       processor.Document
+		expected  []*processor.Document
+		expectErr bool
+	}{{
+		name: "dependency snapshot",
+		doc: processor.Document{
+			Blob:   testdata.DependencySnapshotExample,
+			Format: processor.FormatJSON,
+			Type:   processor.DocumentDependencySnapshot,
+		},
+		expected: []*processor.Document{},
+	}, {
+		name: "incorrect type",
+		doc: processor.Document{
+			Blob:   testdata.DependencySnapshotExample,
+			Format: processor.FormatJSON,
+			Type:   processor.DocumentUnknown,
+		},
+		expectErr: true,
+	}}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			d := DependencySnapshotProcessor{}
+			actual, err := d.Unpack(&tt.doc)
+			if (err != nil) != tt.expectErr {
+				t.Errorf("DependencySnapshotProcessor.Unpack() error = %v, expectErr %v", err, tt.expectErr)
+			}
+			if !reflect.DeepEqual(actual, tt.expected) {
+				t.Errorf("DependencySnapshotProcessor.Unpack() = %v, expected %v", actual, tt.expected)
+			}
+		})
+	}
+}
+
+func TestDependencySnapshotProcessor_ValidateSchema(t *testing.T) {
+	testCases := []struct {
+		name      string
+		doc       processor.Document
+		expectErr bool
+	}{{
+		name: "dependency snapshot",
+		doc: processor.Document{
+			Blob:   testdata.DependencySnapshotExample,
+			Format: processor.FormatJSON,
+			Type:   processor.DocumentDependencySnapshot,
+		},
+	}, {
+		name: "missing detector",
+		doc: processor.Document{
+			Blob:   []byte(`{"version": 0, "sha": "ce587453ced02b1526dfb4cb910479d431683101", "manifests": {}}`),
+			Format: processor.FormatJSON,
+			Type:   processor.DocumentDependencySnapshot,
+		},
+		expectErr: true,
+	}, {
+		name: "unsupported format",
+		doc: processor.Document{
+			Blob:   testdata.DependencySnapshotExample,
+			Format: processor.FormatXML,
+			Type:   processor.DocumentDependencySnapshot,
+		},
+		expectErr: true,
+	}}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			d := DependencySnapshotProcessor{}
+			if err := d.ValidateSchema(&tt.doc); (err != nil) != tt.expectErr {
+				t.Errorf("DependencySnapshotProcessor.ValidateSchema() error = %v, expectErr %v", err, tt.expectErr)
+			}
+		})
+	}
+}
+
+func TestManifest_Path(t *testing.T) {
+	snapshot, err := ParseSnapshot(testdata.DependencySnapshotExample)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := snapshot.Manifests["package-lock.json"].Path(); got != "src/package-lock.json" {
+		t.Errorf("Path() = %q, want src/package-lock.json", got)
+	}
+	if got := (Manifest{Name: "go.mod"}).Path(); got != "go.mod" {
+		t.Errorf("Path() = %q, want go.mod", got)
+	}
+}
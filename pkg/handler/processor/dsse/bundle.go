@@ -0,0 +1,139 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dsse
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// BundleMediaType prefixes the media type of Sigstore bundles, e.g.
+// "application/vnd.dev.sigstore.bundle+json;version=0.1"
+const BundleMediaType = "application/vnd.dev.sigstore.bundle"
+
+// Bundle is a Sigstore bundle of a DSSE envelope, carrying the material to
+// verify it offline: the Fulcio certificate of a keyless signature and the
+// entries of the envelope in the Rekor transparency log. See
+// https://github.com/sigstore/protobuf-specs
+type Bundle struct {
+	MediaType            string               `json:"mediaType"`
+	VerificationMaterial VerificationMaterial `json:"verificationMaterial"`
+	DSSEEnvelope         json.RawMessage      `json:"dsseEnvelope"`
+}
+
+type VerificationMaterial struct {
+	// X509CertificateChain is the signing certificate followed by its
+	// intermediates, Certificate only the signing certificate
+	X509CertificateChain *CertificateChain      `json:"x509CertificateChain,omitempty"`
+	Certificate          *Certificate           `json:"certificate,omitempty"`
+	TlogEntries          []TransparencyLogEntry `json:"tlogEntries,omitempty"`
+}
+
+type CertificateChain struct {
+	Certificates []Certificate `json:"certificates"`
+}
+
+type Certificate struct {
+	// RawBytes is the DER encoding of the certificate
+	RawBytes []byte `json:"rawBytes"`
+}
+
+type TransparencyLogEntry struct {
+	LogIndex          Int64             `json:"logIndex"`
+	LogID             LogID             `json:"logId"`
+	KindVersion       KindVersion       `json:"kindVersion"`
+	IntegratedTime    Int64             `json:"integratedTime"`
+	InclusionPromise  *InclusionPromise `json:"inclusionPromise,omitempty"`
+	InclusionProof    *InclusionProof   `json:"inclusionProof,omitempty"`
+	CanonicalizedBody []byte            `json:"canonicalizedBody"`
+}
+
+type LogID struct {
+	// KeyID is the SHA-256 hash of the DER public key of the log
+	KeyID []byte `json:"keyId"`
+}
+
+type KindVersion struct {
+	Kind    string `json:"kind"`
+	Version string `json:"version"`
+}
+
+// InclusionPromise is the signed entry timestamp of the log, the promise to
+// include the entry at its integrated time
+type InclusionPromise struct {
+	SignedEntryTimestamp []byte `json:"signedEntryTimestamp"`
+}
+
+// InclusionProof is the Merkle audit path of the entry to the root of the log
+// tree, whose size and hash are signed by the checkpoint
+type InclusionProof struct {
+	LogIndex   Int64      `json:"logIndex"`
+	RootHash   []byte     `json:"rootHash"`
+	TreeSize   Int64      `json:"treeSize"`
+	Hashes     [][]byte   `json:"hashes"`
+	Checkpoint Checkpoint `json:"checkpoint"`
+}
+
+type Checkpoint struct {
+	Envelope string `json:"envelope"`
+}
+
+// Int64 is encoded as a string by the bundles, following the protobuf JSON
+// mapping, but is also accepted as a number
+type Int64 int64
+
+func (i *Int64) UnmarshalJSON(b []byte) error {
+	s := strings.Trim(string(b), `"`)
+	v, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid int64 %s: %w", b, err)
+	}
+	*i = Int64(v)
+	return nil
+}
+
+// Certificates returns the DER encoded certificates of the bundle, the signing
+// certificate first
+func (b *Bundle) Certificates() [][]byte {
+	var certs [][]byte
+	if chain := b.VerificationMaterial.X509CertificateChain; chain != nil {
+		for _, c := range chain.Certificates {
+			certs = append(certs, c.RawBytes)
+		}
+	}
+	if c := b.VerificationMaterial.Certificate; c != nil && len(certs) == 0 {
+		certs = append(certs, c.RawBytes)
+	}
+	return certs
+}
+
+// ParseBundle parses the Sigstore bundle of a DSSE envelope, failing for any
+// other document
+func ParseBundle(b []byte) (*Bundle, error) {
+	bundle := Bundle{}
+	if err := json.Unmarshal(b, &bundle); err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(bundle.MediaType, BundleMediaType) {
+		return nil, fmt.Errorf("not a Sigstore bundle: media type %q", bundle.MediaType)
+	}
+	if len(bundle.DSSEEnvelope) == 0 {
+		return nil, fmt.Errorf("the Sigstore bundle does not hold a DSSE envelope")
+	}
+	return &bundle, nil
+}
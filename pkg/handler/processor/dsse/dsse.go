@@ -84,7 +84,11 @@ func (d *DSSEProcessor) Unpack(i *processor.Document) ([]*processor.Document, er
 	return []*processor.Document{doc}, nil
 }
 
+// parseDSSE parses the DSSE envelope, or the one of a Sigstore bundle
 func parseDSSE(b []byte) (*dsse.Envelope, error) {
+	if bundle, err := ParseBundle(b); err == nil {
+		b = bundle.DSSEEnvelope
+	}
 	envelope := dsse.Envelope{}
 	if err := json.Unmarshal(b, &envelope); err != nil {
 		return nil, err
@@ -105,6 +105,30 @@ var (
 			Source:    "TestSource",
 		},
 	}
+	ite6BundleDoc = processor.Document{
+		Blob: []byte(`
+		{
+			"mediaType": "application/vnd.dev.sigstore.bundle+json;version=0.1",
+			"verificationMaterial": {
+				"x509CertificateChain": {"certificates": [{"rawBytes": "MIIB"}]},
+				"tlogEntries": [{
+					"logIndex": "7",
+					"logId": {"keyId": "wNI9atQGlz+VWfO6LRygH4QUfY/8W4RFwiT5i5WRgB0="},
+					"kindVersion": {"kind": "intoto", "version": "0.0.2"},
+					"integratedTime": "1680000000",
+					"inclusionPromise": {"signedEntryTimestamp": "MEUC"},
+					"canonicalizedBody": "e30="
+				}]
+			},
+			"dsseEnvelope": ` + string(ite6Payload) + `
+		}`),
+		Type:   processor.DocumentDSSE,
+		Format: processor.FormatJSON,
+		SourceInformation: processor.SourceInformation{
+			Collector: "TestCollector",
+			Source:    "TestSource",
+		},
+	}
 	incorrectTypeDoc = processor.Document{
 		Blob:   []byte("not a DSSE Envelope"),
 		Type:   processor.DocumentUnknown,
@@ -132,6 +156,11 @@ func TestDSSEProcessor_Unpack(t *testing.T) {
 		doc:       ite6DSSEDoc,
 		expected:  []*processor.Document{&ite6SLSADoc},
 		expectErr: false,
+	}, {
+		name:      "Sigstore bundle with ITE6",
+		doc:       ite6BundleDoc,
+		expected:  []*processor.Document{&ite6SLSADoc},
+		expectErr: false,
 	}, {
 		name:      "Incorrect type",
 		doc:       incorrectTypeDoc,
@@ -161,6 +190,10 @@ func TestDSSEProcessor_ValidateSchema(t *testing.T) {
 		name:      "Valid DSSE Envelope",
 		doc:       unknownDSSEDoc,
 		expectErr: false,
+	}, {
+		name:      "Valid Sigstore bundle",
+		doc:       ite6BundleDoc,
+		expectErr: false,
 	}, {
 		name:      "Invalid DSSE Envelope",
 		doc:       incorrectTypeDoc,
@@ -176,3 +209,20 @@ func TestDSSEProcessor_ValidateSchema(t *testing.T) {
 		})
 	}
 }
+
+func TestParseBundle(t *testing.T) {
+	bundle, err := ParseBundle(ite6BundleDoc.Blob)
+	if err != nil {
+		t.Fatalf("ParseBundle() error = %v", err)
+	}
+	entries := bundle.VerificationMaterial.TlogEntries
+	if len(entries) != 1 || entries[0].LogIndex != 7 || entries[0].IntegratedTime != 1680000000 {
+		t.Errorf("ParseBundle() tlog entries = %+v", entries)
+	}
+	if certs := bundle.Certificates(); len(certs) != 1 {
+		t.Errorf("Bundle.Certificates() = %v, expected one certificate", certs)
+	}
+	if _, err := ParseBundle(ite6Payload); err == nil {
+		t.Errorf("ParseBundle() of a DSSE envelope did not fail")
+	}
+}
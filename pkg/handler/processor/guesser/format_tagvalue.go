@@ -0,0 +1,74 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package guesser
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/guacsec/guac/pkg/handler/processor"
+)
+
+type tagValueFormatGuesser struct{}
+
+// GuessFormat expects the `Tag: value` lines of an SPDX tag-value document,
+// including its SPDXVersion tag
+func (_ *tagValueFormatGuesser) GuessFormat(blob []byte) processor.FormatType {
+	if isTagValue(blob) {
+		return processor.FormatTagValue
+	}
+	return processor.FormatUnknown
+}
+
+// isTagValue reports whether every line of blob is a `Tag: value` pair, a
+// comment or part of a multi-line <text> value, with an SPDXVersion tag
+func isTagValue(blob []byte) bool {
+	inText := false
+	hasVersion := false
+	for _, line := range strings.Split(string(blob), "\n") {
+		line = strings.TrimSpace(line)
+		if inText {
+			inText = !strings.Contains(line, "</text>")
+			continue
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		tag, value, found := strings.Cut(line, ":")
+		if !found || !isTag(tag) {
+			return false
+		}
+		if tag == "SPDXVersion" {
+			hasVersion = true
+		}
+		if i := strings.Index(value, "<text>"); i >= 0 {
+			inText = !strings.Contains(value[i:], "</text>")
+		}
+	}
+	return hasVersion && !inText
+}
+
+func isTag(tag string) bool {
+	if tag == "" {
+		return false
+	}
+	for _, r := range tag {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return true
+}
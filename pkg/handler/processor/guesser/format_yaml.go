@@ -0,0 +1,41 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package guesser
+
+import (
+	"encoding/json"
+
+	"github.com/guacsec/guac/pkg/handler/processor"
+	"gopkg.in/yaml.v3"
+)
+
+type yamlFormatGuesser struct{}
+
+// GuessFormat expects a YAML mapping. JSON, JSON lines and SPDX tag-value
+// documents, which are mostly valid YAML too, are left to their guessers.
+func (_ *yamlFormatGuesser) GuessFormat(blob []byte) processor.FormatType {
+	if json.Valid(blob) || isTagValue(blob) {
+		return processor.FormatUnknown
+	}
+	if f := (&jsonLinesFormatGuesser{}).GuessFormat(blob); f != processor.FormatUnknown {
+		return processor.FormatUnknown
+	}
+	var m map[string]interface{}
+	if err := yaml.Unmarshal(blob, &m); err == nil && len(m) > 0 {
+		return processor.FormatYAML
+	}
+	return processor.FormatUnknown
+}
@@ -139,6 +139,16 @@ func Test_GuessDocument(t *testing.T) {
 		},
 		expectedType:   processor.DocumentScorecard,
 		expectedFormat: processor.FormatJSON,
+	}, {
+		name: "valid dependency snapshot Document",
+		document: &processor.Document{
+			Blob:              testdata.DependencySnapshotExample,
+			Type:              processor.DocumentUnknown,
+			Format:            processor.FormatUnknown,
+			SourceInformation: processor.SourceInformation{},
+		},
+		expectedType:   processor.DocumentDependencySnapshot,
+		expectedFormat: processor.FormatJSON,
 	}, {
 		name: "valid big cyclonedx Document",
 		document: &processor.Document{
@@ -60,3 +60,28 @@ func Test_cyclonedxTypeGuesser_GuessDocumentType(t *testing.T) {
 		})
 	}
 }
+
+func Test_cyclonedxTypeGuesser_GuessDocumentTypeXML(t *testing.T) {
+	testCases := []struct {
+		name     string
+		blob     []byte
+		expected processor.DocumentType
+	}{{
+		name:     "other XML document",
+		blob:     []byte(`<project xmlns="http://maven.apache.org/POM/4.0.0"><artifactId>guac</artifactId></project>`),
+		expected: processor.DocumentUnknown,
+	}, {
+		name:     "valid cyclonedx XML Document",
+		blob:     testdata.CycloneDXExampleSmallDepsXML,
+		expected: processor.DocumentCycloneDX,
+	}}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			guesser := &cycloneDXTypeGuesser{}
+			f := guesser.GuessDocumentType(tt.blob, processor.FormatXML)
+			if f != tt.expected {
+				t.Errorf("got the wrong format, got %v, expected %v", f, tt.expected)
+			}
+		})
+	}
+}
@@ -17,6 +17,7 @@ package guesser
 
 import (
 	"bytes"
+	"strings"
 
 	cdx "github.com/CycloneDX/cyclonedx-go"
 	"github.com/guacsec/guac/pkg/handler/processor"
@@ -26,6 +27,9 @@ type cycloneDXTypeGuesser struct{}
 
 const (
 	cycloneDXFormat = "CycloneDX"
+	// cycloneDXXMLNamespace prefixes the namespace of XML BOMs, followed by
+	// the spec version
+	cycloneDXXMLNamespace = "http://cyclonedx.org/schema/bom/"
 )
 
 func (_ *cycloneDXTypeGuesser) GuessDocumentType(blob []byte, format processor.FormatType) processor.DocumentType {
@@ -41,6 +45,15 @@ func (_ *cycloneDXTypeGuesser) GuessDocumentType(blob []byte, format processor.F
 				return processor.DocumentCycloneDX
 			}
 		}
+	case processor.FormatXML:
+		bom := new(cdx.BOM)
+		decoder := cdx.NewBOMDecoder(reader, cdx.BOMFileFormatXML)
+		err := decoder.Decode(bom)
+		if err == nil {
+			if strings.HasPrefix(bom.XMLNS, cycloneDXXMLNamespace) {
+				return processor.DocumentCycloneDX
+			}
+		}
 	}
 	return processor.DocumentUnknown
 }
@@ -19,14 +19,22 @@ import (
 	"encoding/json"
 
 	"github.com/guacsec/guac/pkg/handler/processor"
+	dsse_processor "github.com/guacsec/guac/pkg/handler/processor/dsse"
 	"github.com/secure-systems-lab/go-securesystemslib/dsse"
 )
 
 type dsseTypeGuesser struct{}
 
 func (_ *dsseTypeGuesser) GuessDocumentType(blob []byte, format processor.FormatType) processor.DocumentType {
+	if format != processor.FormatJSON {
+		return processor.DocumentUnknown
+	}
+	// Sigstore bundles are verified and unpacked as the DSSE envelope they hold
+	if bundle, err := dsse_processor.ParseBundle(blob); err == nil {
+		blob = bundle.DSSEEnvelope
+	}
 	var envelope dsse.Envelope
-	if json.Unmarshal(blob, &envelope) == nil && format == processor.FormatJSON {
+	if json.Unmarshal(blob, &envelope) == nil {
 		if envelope.Payload != "" && envelope.PayloadType != "" && len(envelope.Signatures) > 0 {
 			return processor.DocumentDSSE
 		}
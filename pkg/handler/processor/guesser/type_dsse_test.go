@@ -44,6 +44,21 @@ func Test_DSSETypeGuesser(t *testing.T) {
 			]
 		}`),
 		expected: processor.DocumentDSSE,
+	}, {
+		name: "valid Sigstore bundle",
+		blob: []byte(`
+		{
+			"mediaType": "application/vnd.dev.sigstore.bundle+json;version=0.1",
+			"verificationMaterial": {
+				"x509CertificateChain": {"certificates": [{"rawBytes": "MIIB"}]}
+			},
+			"dsseEnvelope": {
+				"payload": "aGVsbG8gd29ybGQ=",
+				"payloadType": "http://example.com/HelloWorld",
+				"signatures": [{"sig": "MEUCIQ=="}]
+			}
+		}`),
+		expected: processor.DocumentDSSE,
 	}}
 
 	for _, tt := range testCases {
@@ -27,6 +27,12 @@ func init() {
 	_ = RegisterDocumentTypeGuesser(&spdxTypeGuesser{}, "spdx")
 	_ = RegisterDocumentTypeGuesser(&scorecardTypeGuesser{}, "scorecard")
 	_ = RegisterDocumentTypeGuesser(&cycloneDXTypeGuesser{}, "cyclonedx")
+	_ = RegisterDocumentTypeGuesser(&depSnapshotTypeGuesser{}, "depsnapshot")
+	_ = RegisterDocumentTypeGuesser(&openVEXTypeGuesser{}, "openvex")
+	_ = RegisterDocumentTypeGuesser(&csafTypeGuesser{}, "csaf")
+	_ = RegisterDocumentTypeGuesser(&osvTypeGuesser{}, "osv")
+	_ = RegisterDocumentTypeGuesser(&syftTypeGuesser{}, "syft")
+	_ = RegisterDocumentTypeGuesser(&sarifTypeGuesser{}, "sarif")
 }
 
 // DocumentTypeGuesser guesses the document type based on the blob and format given
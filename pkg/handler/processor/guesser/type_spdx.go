@@ -20,6 +20,8 @@ import (
 
 	"github.com/guacsec/guac/pkg/handler/processor"
 	spdx_json "github.com/spdx/tools-golang/json"
+	"github.com/spdx/tools-golang/tvloader"
+	"gopkg.in/yaml.v3"
 )
 
 type spdxTypeGuesser struct{}
@@ -34,6 +36,23 @@ func (_ *spdxTypeGuesser) GuessDocumentType(blob []byte, format processor.Format
 				return processor.DocumentSPDX
 			}
 		}
+	case processor.FormatYAML:
+		var doc interface{}
+		if err := yaml.Unmarshal(blob, &doc); err != nil {
+			return processor.DocumentUnknown
+		}
+		m, ok := doc.(map[string]interface{})
+		if !ok || m["spdxVersion"] == nil {
+			return processor.DocumentUnknown
+		}
+		return processor.DocumentSPDX
+	case processor.FormatTagValue:
+		spdxDoc, err := tvloader.Load2_2(reader)
+		if err == nil {
+			if spdxDoc.DocumentName != "" {
+				return processor.DocumentSPDX
+			}
+		}
 	}
 	return processor.DocumentUnknown
 }
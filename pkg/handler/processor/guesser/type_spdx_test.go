@@ -56,3 +56,36 @@ func Test_spdxTypeGuesser_GuessDocumentType(t *testing.T) {
 		})
 	}
 }
+
+func Test_spdxTypeGuesser_GuessDocumentType_otherFormats(t *testing.T) {
+	testCases := []struct {
+		name     string
+		blob     []byte
+		format   processor.FormatType
+		expected processor.DocumentType
+	}{{
+		name:     "valid small spdx YAML Document",
+		blob:     testdata.SpdxExampleSmallYAML,
+		format:   processor.FormatYAML,
+		expected: processor.DocumentSPDX,
+	}, {
+		name:     "invalid spdx YAML Document",
+		blob:     []byte("abc: def\n"),
+		format:   processor.FormatYAML,
+		expected: processor.DocumentUnknown,
+	}, {
+		name:     "valid small spdx tag-value Document",
+		blob:     testdata.SpdxExampleSmallTagValue,
+		format:   processor.FormatTagValue,
+		expected: processor.DocumentSPDX,
+	}}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			guesser := &spdxTypeGuesser{}
+			f := guesser.GuessDocumentType(tt.blob, tt.format)
+			if f != tt.expected {
+				t.Errorf("got the wrong format, got %v, expected %v", f, tt.expected)
+			}
+		})
+	}
+}
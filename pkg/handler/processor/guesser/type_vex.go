@@ -0,0 +1,50 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package guesser
+
+import (
+	"strings"
+
+	"github.com/guacsec/guac/pkg/handler/processor"
+	"github.com/guacsec/guac/pkg/handler/processor/csaf"
+	"github.com/guacsec/guac/pkg/handler/processor/openvex"
+)
+
+type openVEXTypeGuesser struct{}
+
+func (_ *openVEXTypeGuesser) GuessDocumentType(blob []byte, format processor.FormatType) processor.DocumentType {
+	if format != processor.FormatJSON {
+		return processor.DocumentUnknown
+	}
+	vex, err := openvex.ParseVEX(blob)
+	if err == nil && strings.HasPrefix(vex.Context, openvex.ContextPrefix) {
+		return processor.DocumentOpenVEX
+	}
+	return processor.DocumentUnknown
+}
+
+type csafTypeGuesser struct{}
+
+func (_ *csafTypeGuesser) GuessDocumentType(blob []byte, format processor.FormatType) processor.DocumentType {
+	if format != processor.FormatJSON {
+		return processor.DocumentUnknown
+	}
+	doc, err := csaf.ParseCSAF(blob)
+	if err == nil && doc.Document.Category == csaf.CategoryVEX && doc.Document.CsafVersion != "" {
+		return processor.DocumentCsaf
+	}
+	return processor.DocumentUnknown
+}
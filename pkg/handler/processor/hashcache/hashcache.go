@@ -0,0 +1,236 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hashcache
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/guacsec/guac/pkg/handler/processor"
+)
+
+// DefaultDigestAlgorithm is the digest algorithm of document identities
+// unless SetDigestAlgorithm selects another one
+const DefaultDigestAlgorithm = "sha256"
+
+var (
+	digestMu        sync.RWMutex
+	digestAlgorithm = DefaultDigestAlgorithm
+	digestFuncs     = map[string]func() hash.Hash{
+		"sha256": sha256.New,
+		"sha512": sha512.New,
+	}
+)
+
+// RegisterDigestAlgorithm makes the digest algorithm available to
+// SetDigestAlgorithm under name
+func RegisterDigestAlgorithm(name string, h func() hash.Hash) error {
+	digestMu.Lock()
+	defer digestMu.Unlock()
+	if _, ok := digestFuncs[name]; ok {
+		return fmt.Errorf("the digest algorithm is being overwritten: %s", name)
+	}
+	digestFuncs[name] = h
+	return nil
+}
+
+// SetDigestAlgorithm sets the digest algorithm of document identities: the
+// Nats-Msg-Id used by JetStream to drop duplicates, the hashes of the seen
+// cache and the names of quarantined documents
+func SetDigestAlgorithm(name string) error {
+	digestMu.Lock()
+	defer digestMu.Unlock()
+	if _, ok := digestFuncs[name]; !ok {
+		return fmt.Errorf("unknown digest algorithm: %q", name)
+	}
+	digestAlgorithm = name
+	return nil
+}
+
+// Digest returns the digest of data as `algorithm:hex`, e.g. `sha256:44136f...`.
+// The algorithm is part of the digest so that digests of different algorithms
+// never collide.
+func Digest(data []byte) string {
+	digestMu.RLock()
+	name, newHash := digestAlgorithm, digestFuncs[digestAlgorithm]
+	digestMu.RUnlock()
+	h := newHash()
+	h.Write(data)
+	return name + ":" + hex.EncodeToString(h.Sum(nil))
+}
+
+// HashDocument returns the Digest of the document blob
+func HashDocument(d *processor.Document) string {
+	return Digest(d.Blob)
+}
+
+type entry struct {
+	hash    string
+	addedAt time.Time
+}
+
+// Cache is a bounded set of document hashes that have already been fully
+// ingested. Entries are evicted in least recently used order once the cache
+// is full and expire after the configured TTL. A Cache is safe for
+// concurrent use.
+type Cache struct {
+	mu      sync.Mutex
+	size    int
+	ttl     time.Duration
+	order   *list.List
+	entries map[string]*list.Element
+	now     func() time.Time
+}
+
+// New returns a cache holding at most size hashes, each for at most ttl.
+// A ttl of zero means entries never expire.
+func New(size int, ttl time.Duration) *Cache {
+	return &Cache{
+		size:    size,
+		ttl:     ttl,
+		order:   list.New(),
+		entries: map[string]*list.Element{},
+		now:     time.Now,
+	}
+}
+
+// Seen returns true if hash was added to the cache and has not expired yet
+func (c *Cache) Seen(hash string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[hash]
+	if !ok {
+		return false
+	}
+	if c.expired(elem.Value.(*entry)) {
+		c.remove(elem)
+		return false
+	}
+	c.order.MoveToFront(elem)
+	return true
+}
+
+// Add records hash as ingested, evicting the least recently used entry if
+// the cache is full
+func (c *Cache) Add(hash string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.add(hash, c.now())
+}
+
+// Len returns the number of hashes in the cache, including expired ones
+// which have not been evicted yet
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// Load adds the hashes stored in the file at path by Save. A missing file is
+// not an error, so that the first run can start with an empty cache. Bare
+// hex sha256 hashes saved before digests were prefixed by their algorithm
+// are loaded as `sha256:` digests.
+func (c *Cache) Load(path string) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("failed to read hash cache file: %w", err)
+	}
+	stored := map[string]time.Time{}
+	if err := json.Unmarshal(b, &stored); err != nil {
+		return fmt.Errorf("failed to unmarshal hash cache file: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for hash, addedAt := range stored {
+		if isLegacySHA256(hash) {
+			hash = "sha256:" + hash
+		}
+		if !c.expired(&entry{hash: hash, addedAt: addedAt}) {
+			c.add(hash, addedAt)
+		}
+	}
+	return nil
+}
+
+// Save writes the non-expired hashes of the cache to the file at path
+func (c *Cache) Save(path string) error {
+	c.mu.Lock()
+	stored := map[string]time.Time{}
+	for elem := c.order.Front(); elem != nil; elem = elem.Next() {
+		e := elem.Value.(*entry)
+		if !c.expired(e) {
+			stored[e.hash] = e.addedAt
+		}
+	}
+	c.mu.Unlock()
+
+	b, err := json.Marshal(stored)
+	if err != nil {
+		return fmt.Errorf("failed to marshal hash cache: %w", err)
+	}
+	if err := os.WriteFile(path, b, 0600); err != nil {
+		return fmt.Errorf("failed to write hash cache file: %w", err)
+	}
+	return nil
+}
+
+// isLegacySHA256 returns whether hash is a hex sha256 hash without the
+// algorithm prefix
+func isLegacySHA256(hash string) bool {
+	if len(hash) != hex.EncodedLen(sha256.Size) {
+		return false
+	}
+	_, err := hex.DecodeString(hash)
+	return err == nil
+}
+
+func (c *Cache) add(hash string, addedAt time.Time) {
+	if c.size <= 0 {
+		return
+	}
+	if elem, ok := c.entries[hash]; ok {
+		elem.Value.(*entry).addedAt = addedAt
+		c.order.MoveToFront(elem)
+		return
+	}
+	c.entries[hash] = c.order.PushFront(&entry{hash: hash, addedAt: addedAt})
+	for c.order.Len() > c.size {
+		c.remove(c.order.Back())
+	}
+}
+
+func (c *Cache) remove(elem *list.Element) {
+	c.order.Remove(elem)
+	delete(c.entries, elem.Value.(*entry).hash)
+}
+
+func (c *Cache) expired(e *entry) bool {
+	return c.ttl > 0 && c.now().Sub(e.addedAt) > c.ttl
+}
@@ -0,0 +1,184 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hashcache
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"encoding/json"
+	"github.com/guacsec/guac/pkg/handler/processor"
+	"os"
+)
+
+func TestHashDocument(t *testing.T) {
+	a := &processor.Document{Blob: []byte("{}"), SourceInformation: processor.SourceInformation{Source: "a"}}
+	b := &processor.Document{Blob: []byte("{}"), SourceInformation: processor.SourceInformation{Source: "b"}}
+	c := &processor.Document{Blob: []byte("[]")}
+
+	if HashDocument(a) != HashDocument(b) {
+		t.Errorf("documents with the same blob should have the same hash")
+	}
+	if HashDocument(a) == HashDocument(c) {
+		t.Errorf("documents with different blobs should have different hashes")
+	}
+	if got, want := HashDocument(a), "sha256:44136fa355b3678a1146ad16f7e8649e94fb4fc21fe77e8310c060f61caaff8a"; got != want {
+		t.Errorf("HashDocument() = %v, want %v", got, want)
+	}
+}
+
+func TestSetDigestAlgorithm(t *testing.T) {
+	defer func() {
+		_ = SetDigestAlgorithm(DefaultDigestAlgorithm)
+	}()
+	if err := SetDigestAlgorithm("md5"); err == nil {
+		t.Errorf("SetDigestAlgorithm() expected error for an unknown algorithm")
+	}
+	if err := SetDigestAlgorithm("sha512"); err != nil {
+		t.Fatal(err)
+	}
+	want := "sha512:27c74670adb75075fad058d5ceaf7b20c4e7786c83bae8a32f626f9782af34c9a33c2046ef60fd2a7878d378e29fec851806bbd9a67878f3a9f1cda4830763fd"
+	if got := HashDocument(&processor.Document{Blob: []byte("{}")}); got != want {
+		t.Errorf("HashDocument() = %v, want %v", got, want)
+	}
+	if err := RegisterDigestAlgorithm("sha512", nil); err == nil {
+		t.Errorf("RegisterDigestAlgorithm() expected error for an existing algorithm")
+	}
+}
+
+func TestCache(t *testing.T) {
+	now := time.Unix(0, 0)
+	tests := []struct {
+		name     string
+		size     int
+		ttl      time.Duration
+		add      []string
+		advance  time.Duration
+		wantSeen map[string]bool
+	}{{
+		name:     "seen after add",
+		size:     10,
+		add:      []string{"a", "b"},
+		wantSeen: map[string]bool{"a": true, "b": true, "c": false},
+	}, {
+		name:     "evicts least recently used",
+		size:     2,
+		add:      []string{"a", "b", "c"},
+		wantSeen: map[string]bool{"a": false, "b": true, "c": true},
+	}, {
+		name:     "expired entries are not seen",
+		size:     10,
+		ttl:      time.Minute,
+		add:      []string{"a"},
+		advance:  2 * time.Minute,
+		wantSeen: map[string]bool{"a": false},
+	}, {
+		name:     "zero ttl never expires",
+		size:     10,
+		add:      []string{"a"},
+		advance:  time.Hour,
+		wantSeen: map[string]bool{"a": true},
+	}, {
+		name:     "zero size disables cache",
+		size:     0,
+		add:      []string{"a"},
+		wantSeen: map[string]bool{"a": false},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New(tt.size, tt.ttl)
+			current := now
+			c.now = func() time.Time { return current }
+			for _, h := range tt.add {
+				c.Add(h)
+			}
+			current = current.Add(tt.advance)
+			for h, want := range tt.wantSeen {
+				if got := c.Seen(h); got != want {
+					t.Errorf("Seen(%q) = %v, want %v", h, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestCache_SaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.json")
+
+	empty := New(10, time.Hour)
+	if err := empty.Load(path); err != nil {
+		t.Fatalf("loading a missing file should not fail: %v", err)
+	}
+
+	c := New(10, time.Hour)
+	c.Add("a")
+	c.Add("b")
+	if err := c.Save(path); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	loaded := New(10, time.Hour)
+	if err := loaded.Load(path); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !loaded.Seen("a") || !loaded.Seen("b") || loaded.Len() != 2 {
+		t.Errorf("loaded cache does not contain saved hashes")
+	}
+}
+
+func TestCache_LoadLegacy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.json")
+	doc := &processor.Document{Blob: []byte("{}")}
+	legacy := "44136fa355b3678a1146ad16f7e8649e94fb4fc21fe77e8310c060f61caaff8a"
+	b, err := json.Marshal(map[string]time.Time{legacy: time.Now()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, b, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	c := New(10, time.Hour)
+	if err := c.Load(path); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !c.Seen(HashDocument(doc)) {
+		t.Errorf("legacy hash %s was not loaded as a sha256 digest", legacy)
+	}
+}
+
+func TestCache_Concurrent(t *testing.T) {
+	c := New(50, time.Hour)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				h := fmt.Sprintf("%d-%d", i, j)
+				c.Add(h)
+				c.Seen(h)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if c.Len() != 50 {
+		t.Errorf("Len() = %v, want 50", c.Len())
+	}
+}
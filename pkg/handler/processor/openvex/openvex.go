@@ -0,0 +1,160 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package openvex
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/guacsec/guac/pkg/handler/processor"
+)
+
+// ContextPrefix is the prefix of the `@context` of OpenVEX documents, which
+// is followed by the version of the specification
+const ContextPrefix = "https://openvex.dev/ns"
+
+// Statuses of a vulnerability for a product
+const (
+	StatusNotAffected        = "not_affected"
+	StatusAffected           = "affected"
+	StatusFixed              = "fixed"
+	StatusUnderInvestigation = "under_investigation"
+)
+
+// VEX is an OpenVEX document, the statements of an author on the impact of
+// vulnerabilities on products. See https://github.com/openvex/spec
+type VEX struct {
+	Context    string      `json:"@context"`
+	ID         string      `json:"@id"`
+	Author     string      `json:"author"`
+	Timestamp  string      `json:"timestamp"`
+	Version    json.Number `json:"version"`
+	Statements []Statement `json:"statements"`
+}
+
+// Statement is the status of a vulnerability for a set of products
+type Statement struct {
+	Vulnerability   Vulnerability `json:"vulnerability"`
+	Products        []Product     `json:"products"`
+	Status          string        `json:"status"`
+	Justification   string        `json:"justification"`
+	ImpactStatement string        `json:"impact_statement"`
+	ActionStatement string        `json:"action_statement"`
+}
+
+// Vulnerability is the vulnerability of a statement. Versions of the
+// specification before v0.2.0 give it as a plain string.
+type Vulnerability struct {
+	Name    string   `json:"name"`
+	ID      string   `json:"@id"`
+	Aliases []string `json:"aliases"`
+}
+
+func (v *Vulnerability) UnmarshalJSON(b []byte) error {
+	if err := json.Unmarshal(b, &v.Name); err == nil {
+		return nil
+	}
+	type vulnerability Vulnerability
+	return json.Unmarshal(b, (*vulnerability)(v))
+}
+
+// Product is a product of a statement, identified by its `@id` (usually a
+// purl) and its identifiers and hashes. Versions of the specification before
+// v0.2.0 give it as a plain string.
+type Product struct {
+	ID          string            `json:"@id"`
+	Identifiers map[string]string `json:"identifiers"`
+	Hashes      map[string]string `json:"hashes"`
+}
+
+func (p *Product) UnmarshalJSON(b []byte) error {
+	if err := json.Unmarshal(b, &p.ID); err == nil {
+		return nil
+	}
+	type product Product
+	return json.Unmarshal(b, (*product)(p))
+}
+
+// Purl returns the purl of the product, from its identifiers or its `@id`,
+// or an empty string if it has none
+func (p Product) Purl() string {
+	if purl := p.Identifiers["purl"]; purl != "" {
+		return purl
+	}
+	if strings.HasPrefix(p.ID, "pkg:") {
+		return p.ID
+	}
+	return ""
+}
+
+// ParseVEX parses an OpenVEX document
+func ParseVEX(blob []byte) (*VEX, error) {
+	var vex VEX
+	if err := json.Unmarshal(blob, &vex); err != nil {
+		return nil, err
+	}
+	return &vex, nil
+}
+
+// OpenVEXProcessor processes OpenVEX documents.
+// Currently only supports JSON OpenVEX documents
+type OpenVEXProcessor struct {
+}
+
+func (p *OpenVEXProcessor) ValidateSchema(d *processor.Document) error {
+	if d.Type != processor.DocumentOpenVEX {
+		return fmt.Errorf("expected document type: %v, actual document type: %v", processor.DocumentOpenVEX, d.Type)
+	}
+
+	switch d.Format {
+	case processor.FormatJSON:
+		vex, err := ParseVEX(d.Blob)
+		if err != nil {
+			return err
+		}
+		if !strings.HasPrefix(vex.Context, ContextPrefix) {
+			return fmt.Errorf("unexpected OpenVEX context: %q", vex.Context)
+		}
+		for i, s := range vex.Statements {
+			if s.Vulnerability.Name == "" && s.Vulnerability.ID == "" {
+				return fmt.Errorf("statement %d has no vulnerability", i)
+			}
+			switch s.Status {
+			case StatusNotAffected, StatusAffected, StatusFixed, StatusUnderInvestigation:
+			default:
+				return fmt.Errorf("statement %d has an invalid status: %q", i, s.Status)
+			}
+		}
+		return nil
+	}
+
+	return fmt.Errorf("unable to support parsing of OpenVEX document format: %v", d.Format)
+}
+
+// Unpack takes in the document and tries to unpack it
+// if there is a valid decomposition of sub-documents.
+//
+// Returns empty list and nil error if nothing to unpack
+// Returns unpacked list and nil error if successfully unpacked
+func (p *OpenVEXProcessor) Unpack(d *processor.Document) ([]*processor.Document, error) {
+	if d.Type != processor.DocumentOpenVEX {
+		return nil, fmt.Errorf("expected document type: %v, actual document type: %v", processor.DocumentOpenVEX, d.Type)
+	}
+
+	// OpenVEX doesn't unpack into additional documents at the moment.
+	return []*processor.Document{}, nil
+}
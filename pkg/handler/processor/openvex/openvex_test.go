@@ -0,0 +1,112 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package openvex
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/guacsec/guac/internal/testing/testdata"
+	"github.com/guacsec/guac/pkg/handler/processor"
+)
+
+func TestOpenVEXProcessor_ValidateSchema(t *testing.T) {
+	testCases := []struct {
+		name      string
+		doc       processor.Document
+		expectErr bool
+	}{{
+		name: "OpenVEX document",
+		doc: processor.Document{
+			Blob:   testdata.OpenVEXExample,
+			Format: processor.FormatJSON,
+			Type:   processor.DocumentOpenVEX,
+		},
+	}, {
+		name: "invalid status",
+		doc: processor.Document{
+			Blob:   []byte(`{"@context": "https://openvex.dev/ns", "statements": [{"vulnerability": "CVE-2023-1234", "status": "exploitable"}]}`),
+			Format: processor.FormatJSON,
+			Type:   processor.DocumentOpenVEX,
+		},
+		expectErr: true,
+	}, {
+		name: "missing vulnerability",
+		doc: processor.Document{
+			Blob:   []byte(`{"@context": "https://openvex.dev/ns", "statements": [{"status": "fixed"}]}`),
+			Format: processor.FormatJSON,
+			Type:   processor.DocumentOpenVEX,
+		},
+		expectErr: true,
+	}, {
+		name: "unsupported format",
+		doc: processor.Document{
+			Blob:   testdata.OpenVEXExample,
+			Format: processor.FormatXML,
+			Type:   processor.DocumentOpenVEX,
+		},
+		expectErr: true,
+	}, {
+		name: "incorrect type",
+		doc: processor.Document{
+			Blob:   testdata.OpenVEXExample,
+			Format: processor.FormatJSON,
+			Type:   processor.DocumentCsaf,
+		},
+		expectErr: true,
+	}}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			d := OpenVEXProcessor{}
+			if err := d.ValidateSchema(&tt.doc); (err != nil) != tt.expectErr {
+				t.Errorf("OpenVEXProcessor.ValidateSchema() error = %v, expectErr %v", err, tt.expectErr)
+			}
+		})
+	}
+}
+
+func TestOpenVEXProcessor_Unpack(t *testing.T) {
+	d := OpenVEXProcessor{}
+	actual, err := d.Unpack(&processor.Document{Blob: testdata.OpenVEXExample, Format: processor.FormatJSON, Type: processor.DocumentOpenVEX})
+	if err != nil {
+		t.Fatalf("OpenVEXProcessor.Unpack() error = %v", err)
+	}
+	if !reflect.DeepEqual(actual, []*processor.Document{}) {
+		t.Errorf("OpenVEXProcessor.Unpack() = %v, expected no documents", actual)
+	}
+}
+
+func TestParseVEX(t *testing.T) {
+	vex, err := ParseVEX(testdata.OpenVEXExample)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vex.Statements) != 3 {
+		t.Fatalf("got %d statements, want 3", len(vex.Statements))
+	}
+	// statements of OpenVEX before v0.2.0 give the vulnerability and
+	// products as strings
+	legacy := vex.Statements[1]
+	if legacy.Vulnerability.Name != "CVE-2023-5678" {
+		t.Errorf("vulnerability = %v, want CVE-2023-5678", legacy.Vulnerability)
+	}
+	if got := legacy.Products[0].Purl(); got != "pkg:apk/wolfi/git@2.39.0-r1?arch=x86_64" {
+		t.Errorf("Purl() = %q", got)
+	}
+	if got := vex.Statements[0].Products[1].Purl(); got != "" {
+		t.Errorf("Purl() = %q, want none", got)
+	}
+}
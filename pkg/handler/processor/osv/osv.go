@@ -0,0 +1,197 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package osv
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/guacsec/guac/pkg/handler/processor"
+)
+
+// Entry is an OSV vulnerability entry, as served by OSV.dev and the
+// advisory databases that export to it. See https://ossf.github.io/osv-schema/
+type Entry struct {
+	SchemaVersion string     `json:"schema_version"`
+	ID            string     `json:"id"`
+	Modified      string     `json:"modified"`
+	Published     string     `json:"published"`
+	Withdrawn     string     `json:"withdrawn"`
+	Aliases       []string   `json:"aliases"`
+	Summary       string     `json:"summary"`
+	Affected      []Affected `json:"affected"`
+}
+
+// Affected is a package affected by the vulnerability, the version ranges
+// that are affected and, for ecosystems with enumerable versions, the
+// affected versions
+type Affected struct {
+	Package  Package  `json:"package"`
+	Ranges   []Range  `json:"ranges"`
+	Versions []string `json:"versions"`
+}
+
+// Package is a package of an ecosystem, optionally given by its purl
+type Package struct {
+	Ecosystem string `json:"ecosystem"`
+	Name      string `json:"name"`
+	Purl      string `json:"purl"`
+}
+
+// Range is a range of affected versions given as the events of the version
+// history, e.g. the version that introduced the vulnerability and the one
+// that fixed it, of the version scheme of Type
+type Range struct {
+	Type   string  `json:"type"`
+	Repo   string  `json:"repo"`
+	Events []Event `json:"events"`
+}
+
+// Event is an event of a range, only one field of which is set
+type Event struct {
+	Introduced   string `json:"introduced"`
+	Fixed        string `json:"fixed"`
+	LastAffected string `json:"last_affected"`
+	Limit        string `json:"limit"`
+}
+
+// purlTypes maps the OSV ecosystems to purl types
+var purlTypes = map[string]string{
+	"crates.io": "cargo",
+	"Go":        "golang",
+	"Hex":       "hex",
+	"Maven":     "maven",
+	"npm":       "npm",
+	"NuGet":     "nuget",
+	"Packagist": "composer",
+	"Pub":       "pub",
+	"PyPI":      "pypi",
+	"RubyGems":  "gem",
+}
+
+// PackageURL returns the purl of the package without version, from the
+// entry or else from its ecosystem and name, or an empty string if the
+// ecosystem has no purl type
+func (p Package) PackageURL() string {
+	if p.Purl != "" {
+		return p.Purl
+	}
+	purlType, ok := purlTypes[p.Ecosystem]
+	if !ok || p.Name == "" {
+		return ""
+	}
+	name := p.Name
+	if purlType == "maven" {
+		// maven packages are named group:artifact
+		name = strings.Replace(name, ":", "/", 1)
+	}
+	segments := strings.Split(name, "/")
+	for i := range segments {
+		// `@` separates the version of a purl, e.g. of npm scoped packages
+		segments[i] = strings.ReplaceAll(url.PathEscape(segments[i]), "@", "%40")
+	}
+	return "pkg:" + purlType + "/" + strings.Join(segments, "/")
+}
+
+// String returns the range as its type followed by version constraints,
+// e.g. `SEMVER >=1.0.0 <1.2.3`, with the affected intervals separated by
+// commas
+func (r Range) String() string {
+	var intervals []string
+	var cur []string
+	open := false
+	closeInterval := func() {
+		if !open {
+			return
+		}
+		if len(cur) == 0 {
+			cur = []string{"*"}
+		}
+		intervals = append(intervals, strings.Join(cur, " "))
+		cur, open = nil, false
+	}
+	for _, e := range r.Events {
+		switch {
+		case e.Introduced != "":
+			closeInterval()
+			open = true
+			if e.Introduced != "0" {
+				cur = append(cur, ">="+e.Introduced)
+			}
+		case e.Fixed != "" && open:
+			cur = append(cur, "<"+e.Fixed)
+			closeInterval()
+		case e.LastAffected != "" && open:
+			cur = append(cur, "<="+e.LastAffected)
+			closeInterval()
+		case e.Limit != "" && open:
+			cur = append(cur, "<"+e.Limit)
+			closeInterval()
+		}
+	}
+	closeInterval()
+	return strings.TrimSpace(r.Type + " " + strings.Join(intervals, ", "))
+}
+
+// ParseEntry parses an OSV entry
+func ParseEntry(blob []byte) (*Entry, error) {
+	var entry Entry
+	if err := json.Unmarshal(blob, &entry); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// OSVProcessor processes OSV vulnerability entries.
+// Currently only supports JSON OSV entries
+type OSVProcessor struct {
+}
+
+func (p *OSVProcessor) ValidateSchema(d *processor.Document) error {
+	if d.Type != processor.DocumentOSV {
+		return fmt.Errorf("expected document type: %v, actual document type: %v", processor.DocumentOSV, d.Type)
+	}
+
+	switch d.Format {
+	case processor.FormatJSON:
+		entry, err := ParseEntry(d.Blob)
+		if err != nil {
+			return err
+		}
+		if entry.ID == "" || entry.Modified == "" {
+			return fmt.Errorf("missing required OSV fields")
+		}
+		return nil
+	}
+
+	return fmt.Errorf("unable to support parsing of OSV document format: %v", d.Format)
+}
+
+// Unpack takes in the document and tries to unpack it
+// if there is a valid decomposition of sub-documents.
+//
+// Returns empty list and nil error if nothing to unpack
+// Returns unpacked list and nil error if successfully unpacked
+func (p *OSVProcessor) Unpack(d *processor.Document) ([]*processor.Document, error) {
+	if d.Type != processor.DocumentOSV {
+		return nil, fmt.Errorf("expected document type: %v, actual document type: %v", processor.DocumentOSV, d.Type)
+	}
+
+	// OSV entries don't unpack into additional documents.
+	return []*processor.Document{}, nil
+}
@@ -0,0 +1,119 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package osv
+
+import (
+	"testing"
+
+	"github.com/guacsec/guac/internal/testing/testdata"
+	"github.com/guacsec/guac/pkg/handler/processor"
+)
+
+func TestOSVProcessor_ValidateSchema(t *testing.T) {
+	testCases := []struct {
+		name      string
+		doc       processor.Document
+		expectErr bool
+	}{{
+		name: "OSV entry",
+		doc: processor.Document{
+			Blob:   testdata.OSVExample,
+			Format: processor.FormatJSON,
+			Type:   processor.DocumentOSV,
+		},
+	}, {
+		name: "missing modified",
+		doc: processor.Document{
+			Blob:   []byte(`{"id": "GHSA-599f-7c49-w659", "affected": []}`),
+			Format: processor.FormatJSON,
+			Type:   processor.DocumentOSV,
+		},
+		expectErr: true,
+	}, {
+		name: "unsupported format",
+		doc: processor.Document{
+			Blob:   testdata.OSVExample,
+			Format: processor.FormatXML,
+			Type:   processor.DocumentOSV,
+		},
+		expectErr: true,
+	}, {
+		name: "incorrect type",
+		doc: processor.Document{
+			Blob:   testdata.OSVExample,
+			Format: processor.FormatJSON,
+			Type:   processor.DocumentITE6Vul,
+		},
+		expectErr: true,
+	}}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			d := OSVProcessor{}
+			if err := d.ValidateSchema(&tt.doc); (err != nil) != tt.expectErr {
+				t.Errorf("OSVProcessor.ValidateSchema() error = %v, expectErr %v", err, tt.expectErr)
+			}
+		})
+	}
+}
+
+func TestPackage_PackageURL(t *testing.T) {
+	tests := []struct {
+		pkg  Package
+		want string
+	}{
+		{Package{Ecosystem: "Maven", Name: "org.apache.commons:commons-text", Purl: "pkg:maven/org.apache.commons/commons-text"}, "pkg:maven/org.apache.commons/commons-text"},
+		{Package{Ecosystem: "Maven", Name: "org.apache.commons:commons-text"}, "pkg:maven/org.apache.commons/commons-text"},
+		{Package{Ecosystem: "npm", Name: "@example/text"}, "pkg:npm/%40example/text"},
+		{Package{Ecosystem: "PyPI", Name: "jinja2"}, "pkg:pypi/jinja2"},
+		{Package{Ecosystem: "OSS-Fuzz", Name: "jinja2"}, ""},
+	}
+	for _, tt := range tests {
+		if got := tt.pkg.PackageURL(); got != tt.want {
+			t.Errorf("PackageURL() of %v = %q, want %q", tt.pkg, got, tt.want)
+		}
+	}
+}
+
+func TestRange_String(t *testing.T) {
+	tests := []struct {
+		name string
+		r    Range
+		want string
+	}{{
+		name: "introduced and fixed",
+		r:    Range{Type: "SEMVER", Events: []Event{{Introduced: "1.0.0"}, {Fixed: "1.2.3"}}},
+		want: "SEMVER >=1.0.0 <1.2.3",
+	}, {
+		name: "all versions before last affected",
+		r:    Range{Type: "ECOSYSTEM", Events: []Event{{Introduced: "0"}, {LastAffected: "2.1.0"}}},
+		want: "ECOSYSTEM <=2.1.0",
+	}, {
+		name: "open range",
+		r:    Range{Type: "SEMVER", Events: []Event{{Introduced: "0"}}},
+		want: "SEMVER *",
+	}, {
+		name: "several intervals",
+		r:    Range{Type: "ECOSYSTEM", Events: []Event{{Introduced: "1.0"}, {Fixed: "1.4"}, {Introduced: "2.0"}, {Fixed: "2.1"}, {Introduced: "3.0"}}},
+		want: "ECOSYSTEM >=1.0 <1.4, >=2.0 <2.1, >=3.0",
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.r.String(); got != tt.want {
+				t.Errorf("String() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
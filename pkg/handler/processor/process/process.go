@@ -16,24 +16,47 @@
 package process
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"encoding/xml"
+	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/guacsec/guac/pkg/emitter"
 	"github.com/guacsec/guac/pkg/handler/processor"
+	"github.com/guacsec/guac/pkg/handler/processor/csaf"
 	"github.com/guacsec/guac/pkg/handler/processor/cyclonedx"
+	"github.com/guacsec/guac/pkg/handler/processor/depsnapshot"
 	"github.com/guacsec/guac/pkg/handler/processor/dsse"
 	"github.com/guacsec/guac/pkg/handler/processor/guesser"
 	"github.com/guacsec/guac/pkg/handler/processor/ite6"
+	"github.com/guacsec/guac/pkg/handler/processor/openvex"
+	"github.com/guacsec/guac/pkg/handler/processor/osv"
+	"github.com/guacsec/guac/pkg/handler/processor/sarif"
 	"github.com/guacsec/guac/pkg/handler/processor/scorecard"
 	"github.com/guacsec/guac/pkg/handler/processor/spdx"
+	"github.com/guacsec/guac/pkg/handler/processor/syft"
 	"github.com/guacsec/guac/pkg/logging"
+	"github.com/guacsec/guac/pkg/metrics"
+	"github.com/guacsec/guac/pkg/tracing"
 	uuid "github.com/satori/go.uuid"
+	"gopkg.in/yaml.v3"
 )
 
 var (
 	documentProcessors = map[processor.DocumentType]processor.DocumentProcessor{}
+
+	documentsProcessed = metrics.NewCounter("guac_processor_documents_processed_total",
+		"Documents processed, by document type.", "document_type")
+	documentsFailed = metrics.NewCounter("guac_processor_documents_failed_total",
+		"Documents that failed to process, unsupported ones aside.")
+	documentsUnsupported = metrics.NewCounter("guac_processor_documents_unsupported_total",
+		"Documents of a type or format that no processor handles.")
+	processDuration = metrics.NewHistogram("guac_processor_process_duration_seconds",
+		"Time taken to process a document and the documents it holds.", nil)
 )
 
 func init() {
@@ -44,6 +67,12 @@ func init() {
 	_ = RegisterDocumentProcessor(&spdx.SPDXProcessor{}, processor.DocumentSPDX)
 	_ = RegisterDocumentProcessor(&scorecard.ScorecardProcessor{}, processor.DocumentScorecard)
 	_ = RegisterDocumentProcessor(&cyclonedx.CycloneDXProcessor{}, processor.DocumentCycloneDX)
+	_ = RegisterDocumentProcessor(&depsnapshot.DependencySnapshotProcessor{}, processor.DocumentDependencySnapshot)
+	_ = RegisterDocumentProcessor(&openvex.OpenVEXProcessor{}, processor.DocumentOpenVEX)
+	_ = RegisterDocumentProcessor(&csaf.CSAFProcessor{}, processor.DocumentCsaf)
+	_ = RegisterDocumentProcessor(&osv.OSVProcessor{}, processor.DocumentOSV)
+	_ = RegisterDocumentProcessor(&syft.SyftProcessor{}, processor.DocumentSyft)
+	_ = RegisterDocumentProcessor(&sarif.SARIFProcessor{}, processor.DocumentSARIF)
 }
 
 func RegisterDocumentProcessor(p processor.DocumentProcessor, d processor.DocumentType) error {
@@ -54,33 +83,57 @@ func RegisterDocumentProcessor(p processor.DocumentProcessor, d processor.Docume
 	return nil
 }
 
+// RegisteredDocumentProcessors returns the name of the processor registered
+// for each document type, e.g. "spdx.SPDXProcessor"
+func RegisteredDocumentProcessors() map[processor.DocumentType]string {
+	processors := make(map[processor.DocumentType]string, len(documentProcessors))
+	for t, p := range documentProcessors {
+		processors[t] = strings.TrimPrefix(fmt.Sprintf("%T", p), "*")
+	}
+	return processors
+}
+
 // Subscribe is used by NATS JetStream to stream the documents received from the collector
-// and process them them via Process
-func Subscribe(ctx context.Context, transportFunc func(processor.DocumentTree) error) error {
+// and process them them via Process. transportFunc is given the context of the
+// document, carrying its trace. Documents that fail to process are moved to the
+// dead-letter subject.
+func Subscribe(ctx context.Context, transportFunc func(context.Context, processor.DocumentTree) error) error {
+	return SubscribeDurable(ctx, emitter.DurableProcessor, transportFunc)
+}
+
+// SubscribeDurable is like Subscribe, consuming the documents with the durable
+// consumer of the given name, which is shared by the processors using it.
+func SubscribeDurable(ctx context.Context, durable string, transportFunc func(context.Context, processor.DocumentTree) error) error {
 	logger := logging.FromContext(ctx)
 
 	id := uuid.NewV4().String()
-	psub, err := emitter.NewPubSub(ctx, id, emitter.SubjectNameDocCollected, emitter.DurableProcessor, emitter.BackOffTimer)
+	psub, err := emitter.NewPubSub(ctx, id, emitter.SubjectNameDocCollected, durable, emitter.BackOffTimer)
 	if err != nil {
 		return err
 	}
 
-	processFunc := func(d []byte) error {
+	processFunc := func(ctx context.Context, d []byte) error {
 		doc := processor.Document{}
 		err := json.Unmarshal(d, &doc)
 		if err != nil {
 			fmtErr := fmt.Errorf("[processor: %s] failed unmarshal the document bytes: %w", id, err)
 			logger.Error(fmtErr)
-			return err
+			documentsFailed.Inc()
+			return deadLetter(ctx, durable, d, fmtErr)
+		}
+		// the document tree is published under the span of the processing
+		traceCtx, docTree, err := ProcessWithTrace(ctx, &doc)
+		if errors.Is(err, ErrUnsupportedDocument) {
+			// already counted, redelivering it would not help
+			return nil
 		}
-		docTree, err := Process(ctx, &doc)
 		if err != nil {
 			fmtErr := fmt.Errorf("[processor: %s] failed process document: %w", id, err)
 			logger.Error(fmtErr)
-			return fmtErr
+			return deadLetter(traceCtx, durable, d, fmtErr)
 		}
 
-		err = transportFunc(docTree)
+		err = transportFunc(traceCtx, docTree)
 		if err != nil {
 			fmtErr := fmt.Errorf("[processor: %s] failed transportFunc: %w", id, err)
 			logger.Error(fmtErr)
@@ -91,21 +144,64 @@ func Subscribe(ctx context.Context, transportFunc func(processor.DocumentTree) e
 		return nil
 	}
 
-	err = psub.GetDataFromNats(ctx, processFunc)
+	err = psub.GetDataFromNatsWithContext(ctx, processFunc)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
+// deadLetter moves the document the durable consumer failed to process to the
+// dead-letter subject, since delivering it again would not help. Documents
+// that processed but failed to be published are delivered again instead, see
+// emitter.GetDeadLetterAttempts.
+func deadLetter(ctx context.Context, durable string, d []byte, err error) error {
+	if pubErr := emitter.PublishDeadLetter(ctx, emitter.DeadLetter{
+		Subject:  emitter.SubjectNameDocCollected,
+		Consumer: durable,
+		Attempts: 1,
+		Error:    err.Error(),
+		FailedAt: time.Now().UTC(),
+		Data:     d,
+	}); pubErr != nil {
+		return pubErr
+	}
+	logging.FromContext(ctx).Warnf("moved document to %s: %v", emitter.SubjectNameDocDeadLetter, err)
+	return nil
+}
+
 // Process processes the documents received from the collector to determine
-// their format and document type.
+// their format and document type. Documents of a type or format that no
+// processor handles are counted and fail with an error wrapping
+// ErrUnsupportedDocument.
 func Process(ctx context.Context, i *processor.Document) (processor.DocumentTree, error) {
+	_, docTree, err := ProcessWithTrace(ctx, i)
+	return docTree, err
+}
+
+// ProcessWithTrace is Process also returning ctx with the span of the
+// processing, so that the stages the document tree is passed on to continue
+// the trace of the document under it
+func ProcessWithTrace(ctx context.Context, i *processor.Document) (context.Context, processor.DocumentTree, error) {
+	spanCtx, span := tracing.Start(ctx, "process")
+	defer span.End()
+	span.SetAttribute("guac.source", i.SourceInformation.Source)
+	defer processDuration.ObserveSince(time.Now())
+
+	ctx = logging.WithComponent(logging.WithCollectorName(spanCtx, i.SourceInformation.CollectorName), logging.ComponentProcessor)
 	node, err := processHelper(ctx, i)
 	if err != nil {
-		return nil, err
+		span.RecordError(err)
+		if errors.Is(err, ErrUnsupportedDocument) {
+			documentsUnsupported.Inc()
+		} else {
+			documentsFailed.Inc()
+		}
+		return spanCtx, nil, err
 	}
-	return processor.DocumentTree(node), nil
+	span.SetAttribute("guac.document_type", string(node.Document.Type))
+	documentsProcessed.Inc(string(node.Document.Type))
+	return spanCtx, processor.DocumentTree(node), nil
 }
 
 func processHelper(ctx context.Context, doc *processor.Document) (*processor.DocumentNode, error) {
@@ -135,14 +231,24 @@ func processDocument(ctx context.Context, i *processor.Document) ([]*processor.D
 	}
 
 	if err := validateFormat(i); err != nil {
+		if errors.Is(err, ErrUnsupportedDocument) {
+			unsupported.record(ctx, i, err)
+		}
 		return nil, err
 	}
 
 	err := validateDocument(i)
 	if err != nil {
+		if errors.Is(err, ErrUnsupportedDocument) {
+			unsupported.record(ctx, i, err)
+		}
 		return nil, err
 	}
 
+	if err := normalizeDocument(i); err != nil {
+		return nil, fmt.Errorf("unable to normalize document: %w", err)
+	}
+
 	ds, err := unpackDocument(i)
 	if err != nil {
 		return nil, fmt.Errorf("unable to unpack document: %w", err)
@@ -169,10 +275,22 @@ func validateFormat(i *processor.Document) error {
 		if !json.Valid(i.Blob) {
 			return fmt.Errorf("invalid JSON document")
 		}
+	case processor.FormatXML:
+		if err := xml.Unmarshal(i.Blob, new(interface{})); err != nil {
+			return fmt.Errorf("invalid XML document: %w", err)
+		}
+	case processor.FormatYAML:
+		if err := yaml.Unmarshal(i.Blob, new(interface{})); err != nil {
+			return fmt.Errorf("invalid YAML document: %w", err)
+		}
+	case processor.FormatTagValue:
+		if len(bytes.TrimSpace(i.Blob)) == 0 {
+			return fmt.Errorf("invalid tag-value document: empty document")
+		}
 	case processor.FormatUnknown:
 		return nil
 	default:
-		return fmt.Errorf("invalid document format type: %v", i.Format)
+		return fmt.Errorf("%w: invalid document format type: %v", ErrUnsupportedDocument, i.Format)
 	}
 	return nil
 }
@@ -180,12 +298,22 @@ func validateFormat(i *processor.Document) error {
 func validateDocument(i *processor.Document) error {
 	p, ok := documentProcessors[i.Type]
 	if !ok {
-		return fmt.Errorf("no document processor registered for type: %s", i.Type)
+		return fmt.Errorf("%w: no document processor registered for type: %s", ErrUnsupportedDocument, i.Type)
 	}
 
 	return p.ValidateSchema(i)
 }
 
+// normalizeDocument converts the document to the format its parser reads if
+// the processor of its type handles more than one format
+func normalizeDocument(i *processor.Document) error {
+	n, ok := documentProcessors[i.Type].(processor.DocumentNormalizer)
+	if !ok {
+		return nil
+	}
+	return n.Normalize(i)
+}
+
 func unpackDocument(i *processor.Document) ([]*processor.Document, error) {
 	p, ok := documentProcessors[i.Type]
 	if !ok {
@@ -301,6 +301,26 @@ func Test_SimpleDocProcessTest(t *testing.T) {
 		expectErr: true,
 	}, {
 
+		name: "bad XML format",
+		doc: processor.Document{
+			Blob:              []byte(`<bom><components></bom>`),
+			Type:              simpledoc.SimpleDocType,
+			Format:            processor.FormatXML,
+			SourceInformation: processor.SourceInformation{},
+		},
+		expectErr: true,
+	}, {
+
+		name: "bad YAML format",
+		doc: processor.Document{
+			Blob:              []byte("issuer: [google.com\n"),
+			Type:              simpledoc.SimpleDocType,
+			Format:            processor.FormatYAML,
+			SourceInformation: processor.SourceInformation{},
+		},
+		expectErr: true,
+	}, {
+
 		name: "bad format type",
 		doc: processor.Document{
 			Blob: []byte(`{
@@ -558,11 +578,12 @@ func Test_ProcessSubscribe(t *testing.T) {
 	defer natsTest.Shutdown()
 
 	testCases := []struct {
-		name       string
-		doc        processor.Document
-		wantErr    bool
-		expected   processor.DocumentTree
-		errMessage string
+		name           string
+		doc            processor.Document
+		wantErr        bool
+		expected       processor.DocumentTree
+		errMessage     string
+		wantDeadLetter string
 	}{{
 		name: "simple test",
 		doc: processor.Document{
@@ -651,8 +672,9 @@ func Test_ProcessSubscribe(t *testing.T) {
 			Format:            processor.FormatJSON,
 			SourceInformation: processor.SourceInformation{},
 		},
-		wantErr:    true,
-		errMessage: "failed process document: invalid JSON document",
+		wantErr:        true,
+		errMessage:     "context deadline exceeded",
+		wantDeadLetter: "failed process document: invalid JSON document",
 	}}
 
 	// Register
@@ -686,19 +708,17 @@ func Test_ProcessSubscribe(t *testing.T) {
 			if err != nil {
 				t.Fatalf("unexpected error on emit: %v", err)
 			}
-			var cancel context.CancelFunc
-
-			ctx, cancel = context.WithTimeout(ctx, 1*time.Second)
+			subCtx, cancel := context.WithTimeout(ctx, 1*time.Second)
 			defer cancel()
 
-			transportFunc := func(d processor.DocumentTree) error {
+			transportFunc := func(_ context.Context, d processor.DocumentTree) error {
 				if !dochelper.DocTreeEqual(d, tt.expected) {
 					t.Errorf("doc tree did not match up, got\n%s, \nexpected\n%s", dochelper.StringTree(d), dochelper.StringTree(tt.expected))
 				}
 				return nil
 			}
 
-			err = Subscribe(ctx, transportFunc)
+			err = Subscribe(subCtx, transportFunc)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("nats emitter Subscribe test errored = %v, want %v", err, tt.wantErr)
 			}
@@ -707,6 +727,20 @@ func Test_ProcessSubscribe(t *testing.T) {
 					t.Errorf("nats emitter Subscribe test errored = %v, want %v", err, tt.errMessage)
 				}
 			}
+
+			deadLetters, err := emitter.DeadLetters(ctx)
+			if err != nil {
+				t.Fatalf("unexpected error listing dead letters: %v", err)
+			}
+			if tt.wantDeadLetter == "" {
+				if len(deadLetters) != 0 {
+					t.Errorf("got dead letters %+v, want none", deadLetters)
+				}
+				return
+			}
+			if len(deadLetters) != 1 || !strings.Contains(deadLetters[0].Error, tt.wantDeadLetter) || deadLetters[0].Subject != emitter.SubjectNameDocCollected {
+				t.Errorf("got dead letters %+v, want one with error %q", deadLetters, tt.wantDeadLetter)
+			}
 		})
 	}
 }
@@ -0,0 +1,160 @@
+//
+// Copyright 2022 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package process
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/guacsec/guac/pkg/handler/processor"
+	"github.com/guacsec/guac/pkg/handler/processor/hashcache"
+	"github.com/guacsec/guac/pkg/logging"
+)
+
+// ErrUnsupportedDocument is wrapped by the errors of Process for documents
+// whose type or format no processor handles
+var ErrUnsupportedDocument = errors.New("unsupported document")
+
+var unsupported = &unsupportedDocuments{counts: map[string]int{}}
+
+// unsupportedDocuments counts the unsupported documents per type and format
+// and optionally keeps a copy of them in a quarantine directory
+type unsupportedDocuments struct {
+	mu            sync.Mutex
+	counts        map[string]int
+	quarantineDir string
+}
+
+// quarantineInfo is written next to a quarantined document
+type quarantineInfo struct {
+	SourceInformation processor.SourceInformation `json:"sourceInformation"`
+	Type              processor.DocumentType      `json:"type"`
+	Format            processor.FormatType        `json:"format"`
+	Error             string                      `json:"error"`
+}
+
+// SetQuarantineDir makes Process write unsupported documents to dir, creating
+// it if needed. An empty dir disables the quarantine.
+func SetQuarantineDir(dir string) error {
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("unable to create quarantine directory: %w", err)
+		}
+	}
+	unsupported.mu.Lock()
+	defer unsupported.mu.Unlock()
+	unsupported.quarantineDir = dir
+	return nil
+}
+
+// UnsupportedDocuments returns the number of unsupported documents seen so far
+// keyed by "<type>/<format>"
+func UnsupportedDocuments() map[string]int {
+	unsupported.mu.Lock()
+	defer unsupported.mu.Unlock()
+	counts := make(map[string]int, len(unsupported.counts))
+	for k, v := range unsupported.counts {
+		counts[k] = v
+	}
+	return counts
+}
+
+// ResetUnsupportedDocuments clears the counts of unsupported documents
+func ResetUnsupportedDocuments() {
+	unsupported.mu.Lock()
+	defer unsupported.mu.Unlock()
+	unsupported.counts = map[string]int{}
+}
+
+// LogUnsupportedDocuments logs the counts of unsupported documents, most
+// frequent first, so that runs show which document types are missing support
+func LogUnsupportedDocuments(ctx context.Context) {
+	logger := logging.FromContext(ctx)
+	counts := UnsupportedDocuments()
+	if len(counts) == 0 {
+		return
+	}
+	keys := make([]string, 0, len(counts))
+	total := 0
+	for k, v := range counts {
+		keys = append(keys, k)
+		total += v
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	logger.Warnf("skipped %d unsupported documents", total)
+	for _, k := range keys {
+		logger.Warnf("  %s: %d", k, counts[k])
+	}
+}
+
+// record counts an unsupported document, logging only the first one of its
+// type and format, and quarantines it
+func (u *unsupportedDocuments) record(ctx context.Context, d *processor.Document, err error) {
+	logger := logging.FromContext(ctx)
+	key := fmt.Sprintf("%s/%s", d.Type, d.Format)
+
+	u.mu.Lock()
+	u.counts[key]++
+	first := u.counts[key] == 1
+	dir := u.quarantineDir
+	u.mu.Unlock()
+
+	if first {
+		logger.Warnf("unsupported document %s from %s: %v, further %s documents are only counted", key, d.SourceInformation.Source, err, key)
+	}
+	if dir != "" {
+		if qErr := quarantine(dir, d, err); qErr != nil {
+			logger.Warnf("unable to quarantine document from %s: %v", d.SourceInformation.Source, qErr)
+		}
+	}
+}
+
+// quarantine writes the document to dir under its content hash, with its
+// source information in a .json file of the same name
+func quarantine(dir string, d *processor.Document, err error) error {
+	name := filepath.Join(dir, quarantineName(d))
+	info, mErr := json.MarshalIndent(quarantineInfo{
+		SourceInformation: d.SourceInformation,
+		Type:              d.Type,
+		Format:            d.Format,
+		Error:             err.Error(),
+	}, "", "  ")
+	if mErr != nil {
+		return mErr
+	}
+	if wErr := os.WriteFile(name, d.Blob, 0o644); wErr != nil {
+		return wErr
+	}
+	return os.WriteFile(name+".json", info, 0o644)
+}
+
+// quarantineName is the file name of a quarantined document: its digest with
+// the ':' after the algorithm replaced, as it is not allowed in all file systems
+func quarantineName(d *processor.Document) string {
+	return strings.Replace(hashcache.HashDocument(d), ":", "-", 1)
+}
@@ -0,0 +1,93 @@
+//
+// Copyright 2022 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package process
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/guacsec/guac/pkg/handler/processor"
+	"github.com/guacsec/guac/pkg/logging"
+)
+
+func Test_UnsupportedDocuments(t *testing.T) {
+	ctx := logging.WithLogger(context.Background())
+	dir := filepath.Join(t.TempDir(), "quarantine")
+	if err := SetQuarantineDir(dir); err != nil {
+		t.Fatal(err)
+	}
+	ResetUnsupportedDocuments()
+	defer func() {
+		_ = SetQuarantineDir("")
+		ResetUnsupportedDocuments()
+	}()
+
+	docs := []*processor.Document{{
+		Blob:              []byte("<xml></xml>"),
+		Format:            processor.FormatXML,
+		SourceInformation: processor.SourceInformation{Source: "a.xml"},
+	}, {
+		Blob:              []byte("<other></other>"),
+		Format:            processor.FormatXML,
+		SourceInformation: processor.SourceInformation{Source: "b.xml"},
+	}, {
+		Blob:              []byte(`{"unknown": "document"}`),
+		Type:              "UNREGISTERED",
+		Format:            processor.FormatJSON,
+		SourceInformation: processor.SourceInformation{Source: "c.json"},
+	}}
+	for _, d := range docs {
+		_, err := Process(ctx, d)
+		if !errors.Is(err, ErrUnsupportedDocument) {
+			t.Errorf("Process(%s) error = %v, want %v", d.SourceInformation.Source, err, ErrUnsupportedDocument)
+		}
+	}
+
+	want := map[string]int{
+		string(processor.DocumentUnknown) + "/" + string(processor.FormatXML): 2,
+		"UNREGISTERED/" + string(processor.FormatJSON):                        1,
+	}
+	if got := UnsupportedDocuments(); !reflect.DeepEqual(got, want) {
+		t.Errorf("UnsupportedDocuments() = %v, want %v", got, want)
+	}
+
+	for _, d := range docs {
+		name := filepath.Join(dir, quarantineName(d))
+		blob, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatalf("document %s not quarantined: %v", d.SourceInformation.Source, err)
+		}
+		if string(blob) != string(d.Blob) {
+			t.Errorf("quarantined document = %s, want %s", blob, d.Blob)
+		}
+		b, err := os.ReadFile(name + ".json")
+		if err != nil {
+			t.Fatal(err)
+		}
+		info := quarantineInfo{}
+		if err := json.Unmarshal(b, &info); err != nil {
+			t.Fatal(err)
+		}
+		if info.SourceInformation.Source != d.SourceInformation.Source || info.Format != d.Format {
+			t.Errorf("quarantine info = %+v, want source %s and format %s", info, d.SourceInformation.Source, d.Format)
+		}
+	}
+}
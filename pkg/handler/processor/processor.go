@@ -15,6 +15,8 @@
 
 package processor
 
+import "time"
+
 type DocumentProcessor interface {
 	// ValidateSchema validates the schema of the document
 	ValidateSchema(i *Document) error
@@ -28,6 +30,13 @@ type DocumentProcessor interface {
 	Unpack(i *Document) ([]*Document, error)
 }
 
+// DocumentNormalizer is implemented by the processors of document types that
+// come in several formats. Normalize converts the validated document in
+// place to the single format the parser of the type reads.
+type DocumentNormalizer interface {
+	Normalize(i *Document) error
+}
+
 // Document describes the input for a processor to run. This input can
 // come from a collector or from the processor itself (run recursively).
 type Document struct {
@@ -60,7 +69,20 @@ const (
 	DocumentJsonLines   DocumentType = "JSON_LINES"
 	DocumentScorecard   DocumentType = "SCORECARD"
 	DocumentCycloneDX   DocumentType = "CycloneDX"
-	DocumentUnknown     DocumentType = "UNKNOWN"
+	// DocumentDependencySnapshot is the GitHub dependency submission
+	// snapshot format
+	DocumentDependencySnapshot DocumentType = "DEPENDENCY_SNAPSHOT"
+	// DocumentOpenVEX and DocumentCsaf are the OpenVEX and CSAF VEX
+	// vulnerability exploitability documents
+	DocumentOpenVEX DocumentType = "OPEN_VEX"
+	DocumentCsaf    DocumentType = "CSAF"
+	// DocumentOSV is an entry of the OSV vulnerability feeds
+	DocumentOSV DocumentType = "OSV"
+	// DocumentSyft is the native JSON output of syft
+	DocumentSyft DocumentType = "SYFT"
+	// DocumentSARIF is a SARIF log of static analysis and scanner findings
+	DocumentSARIF   DocumentType = "SARIF"
+	DocumentUnknown DocumentType = "UNKNOWN"
 )
 
 // FormatType describes the document format for malform checks
@@ -71,13 +93,25 @@ const (
 	FormatJSON      FormatType = "JSON"
 	FormatJSONLines FormatType = "JSON_LINES"
 	FormatXML       FormatType = "XML"
-	FormatUnknown   FormatType = "UNKNOWN"
+	FormatYAML      FormatType = "YAML"
+	// FormatTagValue is the `Tag: value` text format of SPDX documents
+	FormatTagValue FormatType = "TAG_VALUE"
+	FormatUnknown  FormatType = "UNKNOWN"
 )
 
 // SourceInformation provides additional information about where the document comes from
 type SourceInformation struct {
 	// Collector describes the name of the collector providing this information
 	Collector string
+	// CollectorName is the name of the collector instance, which tells apart
+	// collectors of the same type, e.g. `file` or the --collector-name flag
+	CollectorName string
 	// Source describes the source which the collector got this information
 	Source string
+	// URI locates the document uniformly across collectors, e.g.
+	// `file:///path/to/sbom.json`, `gs://bucket/key` or `oci://registry/repo:tag`
+	URI string
+	// CollectedAt is the time the document was collected. It is set when
+	// the document is emitted if the collector did not set it.
+	CollectedAt time.Time
 }
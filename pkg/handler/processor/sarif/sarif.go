@@ -0,0 +1,262 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sarif
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/guacsec/guac/pkg/handler/processor"
+)
+
+// Version is the version of SARIF supported
+const Version = "2.1.0"
+
+// Log is a SARIF log, the results of one or more runs of static analysis
+// tools or scanners. See https://docs.oasis-open.org/sarif/sarif/v2.1.0/sarif-v2.1.0.html
+type Log struct {
+	Schema  string `json:"$schema"`
+	Version string `json:"version"`
+	Runs    []Run  `json:"runs"`
+}
+
+// Run is the invocation of a tool and its results
+type Run struct {
+	Tool struct {
+		Driver ToolComponent `json:"driver"`
+	} `json:"tool"`
+	Results   []Result   `json:"results"`
+	Artifacts []Artifact `json:"artifacts"`
+	// VersionControlProvenance are the repositories and revisions that were
+	// analyzed
+	VersionControlProvenance []VersionControlDetails `json:"versionControlProvenance"`
+}
+
+// ToolComponent is the tool of a run and the rules it checks
+type ToolComponent struct {
+	Name            string                `json:"name"`
+	Version         string                `json:"version"`
+	SemanticVersion string                `json:"semanticVersion"`
+	InformationURI  string                `json:"informationUri"`
+	Rules           []ReportingDescriptor `json:"rules"`
+}
+
+// ToolVersion returns the version of the tool
+func (t ToolComponent) ToolVersion() string {
+	if t.SemanticVersion != "" {
+		return t.SemanticVersion
+	}
+	return t.Version
+}
+
+// ReportingDescriptor is a rule checked by a tool
+type ReportingDescriptor struct {
+	ID                   string   `json:"id"`
+	Name                 string   `json:"name"`
+	ShortDescription     *Message `json:"shortDescription"`
+	HelpURI              string   `json:"helpUri"`
+	DefaultConfiguration *struct {
+		Level string `json:"level"`
+	} `json:"defaultConfiguration"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+// SecuritySeverity returns the security severity of the rule, a score from
+// 0.0 to 10.0 set by security scanners in the `security-severity` property
+func (r ReportingDescriptor) SecuritySeverity() (float64, bool) {
+	switch s := r.Properties["security-severity"].(type) {
+	case string:
+		score, err := strconv.ParseFloat(s, 64)
+		return score, err == nil
+	case float64:
+		return s, true
+	}
+	return 0, false
+}
+
+// Result is a finding of a tool
+type Result struct {
+	RuleID    string `json:"ruleId"`
+	RuleIndex *int   `json:"ruleIndex"`
+	Rule      *struct {
+		ID    string `json:"id"`
+		Index *int   `json:"index"`
+	} `json:"rule"`
+	Level               string                 `json:"level"`
+	Message             Message                `json:"message"`
+	Locations           []Location             `json:"locations"`
+	Fingerprints        map[string]string      `json:"fingerprints"`
+	PartialFingerprints map[string]string      `json:"partialFingerprints"`
+	Suppressions        []Suppression          `json:"suppressions"`
+	Properties          map[string]interface{} `json:"properties"`
+}
+
+// Message is a text message
+type Message struct {
+	Text     string `json:"text"`
+	Markdown string `json:"markdown"`
+}
+
+// Location is where a result was found
+type Location struct {
+	PhysicalLocation *PhysicalLocation `json:"physicalLocation"`
+	LogicalLocations []LogicalLocation `json:"logicalLocations"`
+}
+
+// PhysicalLocation is a region of an artifact, such as a file
+type PhysicalLocation struct {
+	ArtifactLocation ArtifactLocation `json:"artifactLocation"`
+	Region           *struct {
+		StartLine int `json:"startLine"`
+	} `json:"region"`
+}
+
+// ArtifactLocation refers to an artifact by URI, and by index in the
+// artifacts of the run if it is described there
+type ArtifactLocation struct {
+	URI       string `json:"uri"`
+	URIBaseID string `json:"uriBaseId"`
+	Index     *int   `json:"index"`
+}
+
+// LogicalLocation is a named construct a result was found in, such as a
+// function or a package
+type LogicalLocation struct {
+	Name               string `json:"name"`
+	FullyQualifiedName string `json:"fullyQualifiedName"`
+	Kind               string `json:"kind"`
+}
+
+// Artifact is an artifact analyzed by a run, with the digests of its
+// content keyed by algorithm, e.g. sha-256
+type Artifact struct {
+	Location ArtifactLocation  `json:"location"`
+	Hashes   map[string]string `json:"hashes"`
+}
+
+// VersionControlDetails is a revision of a repository
+type VersionControlDetails struct {
+	RepositoryURI string `json:"repositoryUri"`
+	RevisionID    string `json:"revisionId"`
+	Branch        string `json:"branch"`
+}
+
+// Suppression is the suppression of a result, e.g. a dismissed alert
+type Suppression struct {
+	Kind          string `json:"kind"`
+	Status        string `json:"status"`
+	Justification string `json:"justification"`
+}
+
+// Accepted reports whether the suppression is in effect: it is not under
+// review nor rejected
+func (s Suppression) Accepted() bool {
+	return s.Status == "" || s.Status == "accepted"
+}
+
+// RuleOf returns the rule of the result, given by index or id, or nil if the
+// tool does not describe it
+func (r *Run) RuleOf(result Result) *ReportingDescriptor {
+	rules := r.Tool.Driver.Rules
+	index := result.RuleIndex
+	if index == nil && result.Rule != nil {
+		index = result.Rule.Index
+	}
+	if index != nil && *index >= 0 && *index < len(rules) {
+		return &rules[*index]
+	}
+	id := result.RuleID
+	if id == "" && result.Rule != nil {
+		id = result.Rule.ID
+	}
+	for i := range rules {
+		if rules[i].ID == id {
+			return &rules[i]
+		}
+	}
+	return nil
+}
+
+// ArtifactOf returns the artifact of the run the location refers to, by
+// index or URI, or nil if the run does not describe it
+func (r *Run) ArtifactOf(loc ArtifactLocation) *Artifact {
+	if loc.Index != nil && *loc.Index >= 0 && *loc.Index < len(r.Artifacts) {
+		return &r.Artifacts[*loc.Index]
+	}
+	for i := range r.Artifacts {
+		if loc.URI != "" && r.Artifacts[i].Location.URI == loc.URI {
+			return &r.Artifacts[i]
+		}
+	}
+	return nil
+}
+
+// ParseLog parses a SARIF log
+func ParseLog(blob []byte) (*Log, error) {
+	var log Log
+	if err := json.Unmarshal(blob, &log); err != nil {
+		return nil, err
+	}
+	return &log, nil
+}
+
+// SARIFProcessor processes SARIF logs. Only JSON logs of SARIF 2.1.0 are
+// supported.
+type SARIFProcessor struct {
+}
+
+func (p *SARIFProcessor) ValidateSchema(d *processor.Document) error {
+	if d.Type != processor.DocumentSARIF {
+		return fmt.Errorf("expected document type: %v, actual document type: %v", processor.DocumentSARIF, d.Type)
+	}
+
+	switch d.Format {
+	case processor.FormatJSON:
+		log, err := ParseLog(d.Blob)
+		if err != nil {
+			return err
+		}
+		if log.Version != Version {
+			return fmt.Errorf("unsupported SARIF version: %q", log.Version)
+		}
+		if log.Runs == nil {
+			return fmt.Errorf("missing required SARIF fields")
+		}
+		for _, run := range log.Runs {
+			if run.Tool.Driver.Name == "" {
+				return fmt.Errorf("missing the tool of a SARIF run")
+			}
+		}
+		return nil
+	}
+
+	return fmt.Errorf("unable to support parsing of SARIF document format: %v", d.Format)
+}
+
+// Unpack takes in the document and tries to unpack it
+// if there is a valid decomposition of sub-documents.
+//
+// Returns empty list and nil error if nothing to unpack
+// Returns unpacked list and nil error if successfully unpacked
+func (p *SARIFProcessor) Unpack(d *processor.Document) ([]*processor.Document, error) {
+	if d.Type != processor.DocumentSARIF {
+		return nil, fmt.Errorf("expected document type: %v, actual document type: %v", processor.DocumentSARIF, d.Type)
+	}
+
+	// the runs of a log are parsed together
+	return []*processor.Document{}, nil
+}
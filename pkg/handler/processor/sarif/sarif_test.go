@@ -0,0 +1,108 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sarif
+
+import (
+	"testing"
+
+	"github.com/guacsec/guac/internal/testing/testdata"
+	"github.com/guacsec/guac/pkg/handler/processor"
+)
+
+func TestSARIFProcessor_ValidateSchema(t *testing.T) {
+	testCases := []struct {
+		name      string
+		doc       processor.Document
+		expectErr bool
+	}{{
+		name: "SARIF log",
+		doc: processor.Document{
+			Blob:   testdata.SARIFExample,
+			Format: processor.FormatJSON,
+			Type:   processor.DocumentSARIF,
+		},
+	}, {
+		name: "unsupported version",
+		doc: processor.Document{
+			Blob:   []byte(`{"version": "1.0.0", "runs": []}`),
+			Format: processor.FormatJSON,
+			Type:   processor.DocumentSARIF,
+		},
+		expectErr: true,
+	}, {
+		name: "missing tool",
+		doc: processor.Document{
+			Blob:   []byte(`{"version": "2.1.0", "runs": [{"results": []}]}`),
+			Format: processor.FormatJSON,
+			Type:   processor.DocumentSARIF,
+		},
+		expectErr: true,
+	}, {
+		name: "unsupported format",
+		doc: processor.Document{
+			Blob:   testdata.SARIFExample,
+			Format: processor.FormatXML,
+			Type:   processor.DocumentSARIF,
+		},
+		expectErr: true,
+	}, {
+		name: "incorrect type",
+		doc: processor.Document{
+			Blob:   testdata.SARIFExample,
+			Format: processor.FormatJSON,
+			Type:   processor.DocumentSPDX,
+		},
+		expectErr: true,
+	}}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			d := SARIFProcessor{}
+			if err := d.ValidateSchema(&tt.doc); (err != nil) != tt.expectErr {
+				t.Errorf("SARIFProcessor.ValidateSchema() error = %v, expectErr %v", err, tt.expectErr)
+			}
+		})
+	}
+}
+
+func TestRun_RuleOf(t *testing.T) {
+	one := 1
+	run := Run{}
+	run.Tool.Driver.Rules = []ReportingDescriptor{{ID: "a"}, {ID: "b"}}
+	tests := []struct {
+		name   string
+		result Result
+		want   string
+	}{
+		{"by index", Result{RuleID: "a", RuleIndex: &one}, "b"},
+		{"by id", Result{RuleID: "b"}, "b"},
+		{"by rule reference", Result{Rule: &struct {
+			ID    string `json:"id"`
+			Index *int   `json:"index"`
+		}{ID: "a"}}, "a"},
+		{"unknown", Result{RuleID: "c"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ""
+			if rule := run.RuleOf(tt.result); rule != nil {
+				got = rule.ID
+			}
+			if got != tt.want {
+				t.Errorf("RuleOf() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
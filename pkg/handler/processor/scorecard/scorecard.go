@@ -18,11 +18,29 @@ package scorecard
 import (
 	"encoding/json"
 	"fmt"
+	"strings"
 
 	"github.com/guacsec/guac/pkg/handler/processor"
 	sc "github.com/ossf/scorecard/v4/pkg"
 )
 
+// PackageMetadataPrefix prefixes the metadata entries of a scorecard result
+// that name a package the scored repository is the source of, e.g.
+// "package=pkg:golang/github.com/ossf/scorecard/v4@v4.8.0"
+const PackageMetadataPrefix = "package="
+
+// Packages returns the purls of the packages named in the metadata of the
+// scorecard result
+func Packages(s *sc.JSONScorecardResultV2) []string {
+	purls := []string{}
+	for _, m := range s.Metadata {
+		if strings.HasPrefix(m, PackageMetadataPrefix) {
+			purls = append(purls, strings.TrimPrefix(m, PackageMetadataPrefix))
+		}
+	}
+	return purls
+}
+
 // ScorecardProcessor processes Scorecard documents.
 // Currently only supports JSON Scorecard documents
 type ScorecardProcessor struct {
@@ -17,14 +17,17 @@ package spdx
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 
 	"github.com/guacsec/guac/pkg/handler/processor"
 	spdx_json "github.com/spdx/tools-golang/json"
+	"github.com/spdx/tools-golang/tvloader"
+	"gopkg.in/yaml.v3"
 )
 
-// SPDXProcessor processes SPDX documents.
-// Currently only supports JSON SPDX documents
+// SPDXProcessor processes SPDX documents in the JSON, YAML and tag-value
+// formats. The latter two are normalized to JSON for the parser.
 type SPDXProcessor struct {
 }
 
@@ -38,6 +41,16 @@ func (p *SPDXProcessor) ValidateSchema(d *processor.Document) error {
 		reader := bytes.NewReader(d.Blob)
 		_, err := spdx_json.Load2_2(reader)
 		return err
+	case processor.FormatYAML:
+		blob, err := yamlToJSON(d.Blob)
+		if err != nil {
+			return err
+		}
+		_, err = spdx_json.Load2_2(bytes.NewReader(blob))
+		return err
+	case processor.FormatTagValue:
+		_, err := tvloader.Load2_2(bytes.NewReader(d.Blob))
+		return err
 	}
 
 	return fmt.Errorf("unable to support parsing of SPDX document format: %v", d.Format)
@@ -56,3 +69,52 @@ func (p *SPDXProcessor) Unpack(d *processor.Document) ([]*processor.Document, er
 	// SPDX doesn't unpack into additional documents at the moment.
 	return []*processor.Document{}, nil
 }
+
+// Normalize converts YAML and tag-value SPDX documents to JSON, the only
+// format the SPDX parser reads. JSON documents are left untouched.
+func (p *SPDXProcessor) Normalize(d *processor.Document) error {
+	if d.Type != processor.DocumentSPDX {
+		return fmt.Errorf("expected document type: %v, actual document type: %v", processor.DocumentSPDX, d.Type)
+	}
+
+	var blob []byte
+	switch d.Format {
+	case processor.FormatJSON:
+		return nil
+	case processor.FormatYAML:
+		b, err := yamlToJSON(d.Blob)
+		if err != nil {
+			return err
+		}
+		blob = b
+	case processor.FormatTagValue:
+		doc, err := tvloader.Load2_2(bytes.NewReader(d.Blob))
+		if err != nil {
+			return fmt.Errorf("unable to load SPDX tag-value document: %w", err)
+		}
+		var buf bytes.Buffer
+		if err := spdx_json.Save2_2(doc, &buf); err != nil {
+			return fmt.Errorf("unable to convert SPDX tag-value document to JSON: %w", err)
+		}
+		blob = buf.Bytes()
+	default:
+		return fmt.Errorf("unable to support parsing of SPDX document format: %v", d.Format)
+	}
+
+	d.Blob = blob
+	d.Format = processor.FormatJSON
+	return nil
+}
+
+// yamlToJSON re-encodes a YAML document as JSON
+func yamlToJSON(blob []byte) ([]byte, error) {
+	var doc interface{}
+	if err := yaml.Unmarshal(blob, &doc); err != nil {
+		return nil, fmt.Errorf("unable to unmarshal YAML document: %w", err)
+	}
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("unable to convert YAML document to JSON: %w", err)
+	}
+	return b, nil
+}
@@ -16,6 +16,7 @@
 package spdx
 
 import (
+	"bytes"
 	"reflect"
 	"testing"
 
@@ -93,6 +94,24 @@ func TestSPDXProcessor_ValidateSchema(t *testing.T) {
 			SourceInformation: processor.SourceInformation{},
 		},
 		expectErr: false,
+	}, {
+		name: "valid small SPDX YAML document",
+		doc: processor.Document{
+			Blob:              testdata.SpdxExampleSmallYAML,
+			Format:            processor.FormatYAML,
+			Type:              processor.DocumentSPDX,
+			SourceInformation: processor.SourceInformation{},
+		},
+		expectErr: false,
+	}, {
+		name: "valid small SPDX tag-value document",
+		doc: processor.Document{
+			Blob:              testdata.SpdxExampleSmallTagValue,
+			Format:            processor.FormatTagValue,
+			Type:              processor.DocumentSPDX,
+			SourceInformation: processor.SourceInformation{},
+		},
+		expectErr: false,
 	}, {
 		name: "invalid SPDX document",
 		doc: processor.Document{
@@ -122,3 +141,61 @@ func TestSPDXProcessor_ValidateSchema(t *testing.T) {
 		})
 	}
 }
+
+func TestSPDXProcessor_Normalize(t *testing.T) {
+	testCases := []struct {
+		name      string
+		doc       processor.Document
+		expectErr bool
+	}{{
+		name: "JSON document",
+		doc: processor.Document{
+			Blob:   testdata.SpdxExampleSmall,
+			Format: processor.FormatJSON,
+			Type:   processor.DocumentSPDX,
+		},
+	}, {
+		name: "YAML document",
+		doc: processor.Document{
+			Blob:   testdata.SpdxExampleSmallYAML,
+			Format: processor.FormatYAML,
+			Type:   processor.DocumentSPDX,
+		},
+	}, {
+		name: "tag-value document",
+		doc: processor.Document{
+			Blob:   testdata.SpdxExampleSmallTagValue,
+			Format: processor.FormatTagValue,
+			Type:   processor.DocumentSPDX,
+		},
+	}, {
+		name: "unknown format",
+		doc: processor.Document{
+			Blob:   testdata.SpdxExampleSmall,
+			Format: processor.FormatUnknown,
+			Type:   processor.DocumentSPDX,
+		},
+		expectErr: true,
+	}}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			d := SPDXProcessor{}
+			err := d.Normalize(&tt.doc)
+			if (err != nil) != tt.expectErr {
+				t.Fatalf("SPDXProcessor.Normalize() error = %v, expectErr %v", err, tt.expectErr)
+			}
+			if tt.expectErr {
+				return
+			}
+			if tt.doc.Format != processor.FormatJSON {
+				t.Errorf("SPDXProcessor.Normalize() format = %v, expected %v", tt.doc.Format, processor.FormatJSON)
+			}
+			if err := d.ValidateSchema(&tt.doc); err != nil {
+				t.Errorf("normalized document does not validate: %v", err)
+			}
+			if !bytes.Contains(tt.doc.Blob, []byte("hello-imports")) {
+				t.Errorf("normalized document lost its name: %s", tt.doc.Blob)
+			}
+		})
+	}
+}
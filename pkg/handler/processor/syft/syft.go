@@ -0,0 +1,227 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package syft
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/guacsec/guac/pkg/handler/processor"
+)
+
+// Types of the source a syft document was generated from
+const (
+	SourceImage     = "image"
+	SourceDirectory = "directory"
+	SourceFile      = "file"
+)
+
+// Types of the relationships between the artifacts and files of a syft
+// document
+const (
+	RelationshipContains               = "contains"
+	RelationshipDependencyOf           = "dependency-of"
+	RelationshipEvidentBy              = "evident-by"
+	RelationshipOwnershipByFileOverlap = "ownership-by-file-overlap"
+)
+
+// Document is the native JSON output of syft, `syft -o json`: the packages
+// cataloged from a source, the files with their digests and the
+// relationships between them. See https://github.com/anchore/syft/tree/main/schema/json
+type Document struct {
+	Artifacts             []Package      `json:"artifacts"`
+	ArtifactRelationships []Relationship `json:"artifactRelationships"`
+	Files                 []File         `json:"files"`
+	Source                Source         `json:"source"`
+	Descriptor            struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	} `json:"descriptor"`
+	Schema struct {
+		Version string `json:"version"`
+		URL     string `json:"url"`
+	} `json:"schema"`
+}
+
+// Package is a package cataloged by syft
+type Package struct {
+	ID        string     `json:"id"`
+	Name      string     `json:"name"`
+	Version   string     `json:"version"`
+	Type      string     `json:"type"`
+	FoundBy   string     `json:"foundBy"`
+	Locations []Location `json:"locations"`
+	Licenses  []License  `json:"licenses"`
+	Language  string     `json:"language"`
+	CPEs      []CPE      `json:"cpes"`
+	PURL      string     `json:"purl"`
+}
+
+// License is a license of a package. Schemas before 8.0.0 give it as a
+// plain string.
+type License struct {
+	Value          string `json:"value"`
+	SPDXExpression string `json:"spdxExpression"`
+	Type           string `json:"type"`
+}
+
+func (l *License) UnmarshalJSON(b []byte) error {
+	if err := json.Unmarshal(b, &l.Value); err == nil {
+		return nil
+	}
+	type license License
+	return json.Unmarshal(b, (*license)(l))
+}
+
+// Expression returns the SPDX expression of the license, or its value if
+// syft could not make one of it
+func (l License) Expression() string {
+	if l.SPDXExpression != "" {
+		return l.SPDXExpression
+	}
+	return l.Value
+}
+
+// CPE is a CPE of a package. Schemas before 11.0.0 give it as a plain
+// string.
+type CPE struct {
+	CPE    string `json:"cpe"`
+	Source string `json:"source"`
+}
+
+func (c *CPE) UnmarshalJSON(b []byte) error {
+	if err := json.Unmarshal(b, &c.CPE); err == nil {
+		return nil
+	}
+	type cpe CPE
+	return json.Unmarshal(b, (*cpe)(c))
+}
+
+// Location is where a package or file was found in the source
+type Location struct {
+	Path    string `json:"path"`
+	LayerID string `json:"layerID"`
+}
+
+// Relationship relates a parent to a child artifact or file, each given by
+// its id
+type Relationship struct {
+	Parent string `json:"parent"`
+	Child  string `json:"child"`
+	Type   string `json:"type"`
+}
+
+// File is a file cataloged by syft, with the digests of its content
+type File struct {
+	ID       string   `json:"id"`
+	Location Location `json:"location"`
+	Digests  []Digest `json:"digests"`
+}
+
+// Digest is a digest of the content of a file
+type Digest struct {
+	Algorithm string `json:"algorithm"`
+	Value     string `json:"value"`
+}
+
+// Source is what syft cataloged: a container image, a directory or a file.
+// Schemas before 7.0.0 describe it in Target, later ones in Metadata, which
+// is a plain path for directories and files of the older schemas.
+type Source struct {
+	ID       string          `json:"id"`
+	Name     string          `json:"name"`
+	Version  string          `json:"version"`
+	Type     string          `json:"type"`
+	Target   json.RawMessage `json:"target"`
+	Metadata json.RawMessage `json:"metadata"`
+}
+
+// SourceMetadata describes a source. Only the fields of its type are set.
+type SourceMetadata struct {
+	// Path is the path of a directory or file
+	Path string `json:"path"`
+	// UserInput is the reference of an image as given to syft, e.g.
+	// ghcr.io/guacsec/guac:v0.1.0
+	UserInput      string   `json:"userInput"`
+	ImageID        string   `json:"imageID"`
+	ManifestDigest string   `json:"manifestDigest"`
+	Tags           []string `json:"tags"`
+	RepoDigests    []string `json:"repoDigests"`
+}
+
+// Describe returns the metadata of the source
+func (s Source) Describe() SourceMetadata {
+	var metadata SourceMetadata
+	for _, raw := range []json.RawMessage{s.Metadata, s.Target} {
+		if len(raw) == 0 {
+			continue
+		}
+		if err := json.Unmarshal(raw, &metadata.Path); err == nil {
+			return metadata
+		}
+		if err := json.Unmarshal(raw, &metadata); err == nil {
+			return metadata
+		}
+	}
+	return metadata
+}
+
+// ParseDocument parses a syft document
+func ParseDocument(blob []byte) (*Document, error) {
+	var doc Document
+	if err := json.Unmarshal(blob, &doc); err != nil {
+		return nil, err
+	}
+	return &doc, nil
+}
+
+// SyftProcessor processes the native JSON documents of syft
+type SyftProcessor struct {
+}
+
+func (p *SyftProcessor) ValidateSchema(d *processor.Document) error {
+	if d.Type != processor.DocumentSyft {
+		return fmt.Errorf("expected document type: %v, actual document type: %v", processor.DocumentSyft, d.Type)
+	}
+
+	switch d.Format {
+	case processor.FormatJSON:
+		doc, err := ParseDocument(d.Blob)
+		if err != nil {
+			return err
+		}
+		if doc.Descriptor.Name == "" || doc.Schema.Version == "" || doc.Artifacts == nil {
+			return fmt.Errorf("missing required syft document fields")
+		}
+		return nil
+	}
+
+	return fmt.Errorf("unable to support parsing of syft document format: %v", d.Format)
+}
+
+// Unpack takes in the document and tries to unpack it
+// if there is a valid decomposition of sub-documents.
+//
+// Returns empty list and nil error if nothing to unpack
+// Returns unpacked list and nil error if successfully unpacked
+func (p *SyftProcessor) Unpack(d *processor.Document) ([]*processor.Document, error) {
+	if d.Type != processor.DocumentSyft {
+		return nil, fmt.Errorf("expected document type: %v, actual document type: %v", processor.DocumentSyft, d.Type)
+	}
+
+	// syft documents are not split into sub-documents
+	return []*processor.Document{}, nil
+}
@@ -0,0 +1,120 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package syft
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/guacsec/guac/internal/testing/testdata"
+	"github.com/guacsec/guac/pkg/handler/processor"
+)
+
+func TestSyftProcessor_ValidateSchema(t *testing.T) {
+	testCases := []struct {
+		name      string
+		doc       processor.Document
+		expectErr bool
+	}{{
+		name: "syft document",
+		doc: processor.Document{
+			Blob:   testdata.SyftExample,
+			Format: processor.FormatJSON,
+			Type:   processor.DocumentSyft,
+		},
+	}, {
+		name: "missing artifacts",
+		doc: processor.Document{
+			Blob:   []byte(`{"descriptor": {"name": "syft"}, "schema": {"version": "6.1.0"}}`),
+			Format: processor.FormatJSON,
+			Type:   processor.DocumentSyft,
+		},
+		expectErr: true,
+	}, {
+		name: "unsupported format",
+		doc: processor.Document{
+			Blob:   testdata.SyftExample,
+			Format: processor.FormatXML,
+			Type:   processor.DocumentSyft,
+		},
+		expectErr: true,
+	}, {
+		name: "incorrect type",
+		doc: processor.Document{
+			Blob:   testdata.SyftExample,
+			Format: processor.FormatJSON,
+			Type:   processor.DocumentSPDX,
+		},
+		expectErr: true,
+	}}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			d := SyftProcessor{}
+			if err := d.ValidateSchema(&tt.doc); (err != nil) != tt.expectErr {
+				t.Errorf("SyftProcessor.ValidateSchema() error = %v, expectErr %v", err, tt.expectErr)
+			}
+		})
+	}
+}
+
+func TestParseDocument_schemas(t *testing.T) {
+	legacy := []byte(`{
+		"artifacts": [{"id": "a", "licenses": ["MIT"], "cpes": ["cpe:2.3:a:x:y:1:*:*:*:*:*:*:*"]}],
+		"source": {"id": "s", "type": "directory", "target": "/src"},
+		"descriptor": {"name": "syft"},
+		"schema": {"version": "6.1.0"}
+	}`)
+	current := []byte(`{
+		"artifacts": [{
+			"id": "a",
+			"licenses": [{"value": "MIT License", "spdxExpression": "MIT", "type": "declared"}],
+			"cpes": [{"cpe": "cpe:2.3:a:x:y:1:*:*:*:*:*:*:*", "source": "syft-generated"}]
+		}],
+		"source": {"id": "s", "type": "directory", "metadata": {"path": "/src"}},
+		"descriptor": {"name": "syft"},
+		"schema": {"version": "16.0.0"}
+	}`)
+	for name, blob := range map[string][]byte{"legacy": legacy, "current": current} {
+		t.Run(name, func(t *testing.T) {
+			doc, err := ParseDocument(blob)
+			if err != nil {
+				t.Fatalf("ParseDocument() error = %v", err)
+			}
+			p := doc.Artifacts[0]
+			if len(p.Licenses) != 1 || p.Licenses[0].Expression() != "MIT" {
+				t.Errorf("licenses = %v, want MIT", p.Licenses)
+			}
+			if len(p.CPEs) != 1 || p.CPEs[0].CPE != "cpe:2.3:a:x:y:1:*:*:*:*:*:*:*" {
+				t.Errorf("cpes = %v", p.CPEs)
+			}
+			if got := doc.Source.Describe(); !reflect.DeepEqual(got, SourceMetadata{Path: "/src"}) {
+				t.Errorf("Describe() = %v, want the /src path", got)
+			}
+		})
+	}
+}
+
+func TestSource_Describe_image(t *testing.T) {
+	doc, err := ParseDocument(testdata.SyftExample)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := doc.Source.Describe()
+	if got.UserInput != "ghcr.io/guacsec/alpine:3.16.2" ||
+		got.ManifestDigest != "sha256:8e8d8f5a0f2b8a1e5a1b2c3d4e5f60718293a4b5c6d7e8f90a1b2c3d4e5f6071" {
+		t.Errorf("Describe() = %+v, want the image of the example", got)
+	}
+}
@@ -0,0 +1,204 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/guacsec/guac/pkg/logging"
+)
+
+const (
+	// LivenessPath reports whether the process is up
+	LivenessPath = "/healthz"
+	// ReadinessPath reports whether the connections of the pipeline are healthy
+	ReadinessPath = "/readyz"
+
+	// checkTimeout bounds every check so that a hanging connection is
+	// reported as failing instead of blocking the probe
+	checkTimeout = 5 * time.Second
+)
+
+// Check reports an error if the dependency it checks is not usable
+type Check func(ctx context.Context) error
+
+// Server serves liveness and readiness endpoints. Readiness is reported once
+// MarkStarted was called and as long as all registered checks pass; checks
+// run on every request so that a dropped connection flips readiness.
+// Liveness is reported as long as all registered liveness checks pass, so
+// that a wedged process, e.g. stuck on a hung write, is restarted.
+type Server struct {
+	addr           string
+	mu             sync.RWMutex
+	started        bool
+	checks         map[string]Check
+	livenessChecks map[string]Check
+}
+
+type response struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+// NewServer returns a health server that listens on addr
+func NewServer(addr string) *Server {
+	return &Server{
+		addr:           addr,
+		checks:         map[string]Check{},
+		livenessChecks: map[string]Check{},
+	}
+}
+
+// AddReadinessCheck registers a check that must pass for the server to
+// report ready
+func (s *Server) AddReadinessCheck(name string, check Check) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.checks[name] = check
+}
+
+// AddLivenessCheck registers a check that must pass for the server to report
+// alive. Unlike a readiness check, failing it gets the process restarted, so
+// it should only fail when the process cannot recover by itself, see
+// Watchdog.
+func (s *Server) AddLivenessCheck(name string, check Check) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.livenessChecks[name] = check
+}
+
+// MarkStarted is called once all connections were established
+func (s *Server) MarkStarted() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.started = true
+}
+
+// Handler returns the HTTP handler serving the health endpoints
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(LivenessPath, s.serveLiveness)
+	mux.HandleFunc(ReadinessPath, s.serveReadiness)
+	return mux
+}
+
+// ListenAndServe serves the health endpoints until ctx is cancelled
+func (s *Server) ListenAndServe(ctx context.Context) error {
+	logger := logging.FromContext(ctx)
+	srv := &http.Server{
+		Addr:              s.addr,
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: checkTimeout,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), checkTimeout)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+	logger.Infof("health server listening on %s", s.addr)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func (s *Server) serveLiveness(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	checks := make(map[string]Check, len(s.livenessChecks))
+	for name, check := range s.livenessChecks {
+		checks[name] = check
+	}
+	s.mu.RUnlock()
+
+	resp := response{Status: "ok"}
+	results, alive := runChecks(r.Context(), checks)
+	if len(results) > 0 {
+		resp.Checks = results
+	}
+	status := http.StatusOK
+	if !alive {
+		resp.Status = "stalled"
+		status = http.StatusServiceUnavailable
+	}
+	writeResponse(w, status, resp)
+}
+
+func (s *Server) serveReadiness(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	started := s.started
+	checks := make(map[string]Check, len(s.checks))
+	for name, check := range s.checks {
+		checks[name] = check
+	}
+	s.mu.RUnlock()
+
+	resp := response{Status: "ready"}
+	if !started {
+		resp.Status = "starting"
+	}
+	var passed bool
+	resp.Checks, passed = runChecks(r.Context(), checks)
+
+	status := http.StatusOK
+	if !started || !passed {
+		status = http.StatusServiceUnavailable
+		if started {
+			resp.Status = "not ready"
+		}
+	}
+	writeResponse(w, status, resp)
+}
+
+// runChecks runs the checks concurrently, each bounded by checkTimeout, and
+// returns their results by name and whether they all passed
+func runChecks(ctx context.Context, checks map[string]Check) (map[string]string, bool) {
+	results := map[string]string{}
+	passed := true
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, check := range checks {
+		wg.Add(1)
+		go func(name string, check Check) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+			defer cancel()
+			err := check(ctx)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				results[name] = err.Error()
+				passed = false
+			} else {
+				results[name] = "ok"
+			}
+		}(name, check)
+	}
+	wg.Wait()
+	return results, passed
+}
+
+func writeResponse(w http.ResponseWriter, status int, resp response) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(resp)
+}
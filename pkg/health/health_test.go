@@ -0,0 +1,131 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestServer_Readiness(t *testing.T) {
+	ok := func(ctx context.Context) error { return nil }
+	failing := func(ctx context.Context) error { return errors.New("connection lost") }
+	hanging := func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	tests := []struct {
+		name       string
+		started    bool
+		checks     map[string]Check
+		wantStatus int
+		wantBody   response
+	}{{
+		name:       "not started",
+		checks:     map[string]Check{"graphdb": ok},
+		wantStatus: http.StatusServiceUnavailable,
+		wantBody:   response{Status: "starting", Checks: map[string]string{"graphdb": "ok"}},
+	}, {
+		name:       "all checks pass",
+		started:    true,
+		checks:     map[string]Check{"graphdb": ok, "jetstream": ok},
+		wantStatus: http.StatusOK,
+		wantBody:   response{Status: "ready", Checks: map[string]string{"graphdb": "ok", "jetstream": "ok"}},
+	}, {
+		name:       "failing check",
+		started:    true,
+		checks:     map[string]Check{"graphdb": failing, "jetstream": ok},
+		wantStatus: http.StatusServiceUnavailable,
+		wantBody:   response{Status: "not ready", Checks: map[string]string{"graphdb": "connection lost", "jetstream": "ok"}},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewServer("")
+			for name, check := range tt.checks {
+				s.AddReadinessCheck(name, check)
+			}
+			if tt.started {
+				s.MarkStarted()
+			}
+			rec := httptest.NewRecorder()
+			s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, ReadinessPath, nil))
+			if rec.Code != tt.wantStatus {
+				t.Errorf("got status %d, want %d", rec.Code, tt.wantStatus)
+			}
+			var got response
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+				t.Fatalf("invalid response body: %v", err)
+			}
+			if got.Status != tt.wantBody.Status || len(got.Checks) != len(tt.wantBody.Checks) {
+				t.Errorf("got body %+v, want %+v", got, tt.wantBody)
+			}
+			for name, want := range tt.wantBody.Checks {
+				if got.Checks[name] != want {
+					t.Errorf("check %s: got %q, want %q", name, got.Checks[name], want)
+				}
+			}
+		})
+	}
+
+	t.Run("cancelled request", func(t *testing.T) {
+		s := NewServer("")
+		s.AddReadinessCheck("graphdb", hanging)
+		s.MarkStarted()
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		rec := httptest.NewRecorder()
+		s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, ReadinessPath, nil).WithContext(ctx))
+		if rec.Code != http.StatusServiceUnavailable {
+			t.Errorf("got status %d, want %d", rec.Code, http.StatusServiceUnavailable)
+		}
+	})
+}
+
+func TestServer_Liveness(t *testing.T) {
+	s := NewServer("")
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, LivenessPath, nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("got status %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	// liveness does not depend on the readiness checks nor on being started
+	s.AddReadinessCheck("graphdb", func(ctx context.Context) error { return errors.New("connection lost") })
+	rec = httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, LivenessPath, nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("got status %d with a failing readiness check, want %d", rec.Code, http.StatusOK)
+	}
+
+	s.AddLivenessCheck("stall", func(ctx context.Context) error { return errors.New("store graph running for 20m") })
+	rec = httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, LivenessPath, nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("got status %d with a failing liveness check, want %d", rec.Code, http.StatusServiceUnavailable)
+	}
+	var got response
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("invalid response body: %v", err)
+	}
+	if got.Status != "stalled" || got.Checks["stall"] != "store graph running for 20m" {
+		t.Errorf("got body %+v, want the stall reported", got)
+	}
+}
@@ -0,0 +1,103 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package health
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DefaultStallTimeout is the time an operation watched by a Watchdog may run
+// before the process is reported stalled
+const DefaultStallTimeout = 15 * time.Minute
+
+// Watchdog reports a stage as stalled once an operation it watches, e.g.
+// publishing a document or storing its graphs, runs for longer than its
+// timeout, which a hung connection would otherwise leave unnoticed while
+// the process is up. Its Check is meant as a liveness check. A nil Watchdog
+// watches nothing, so that the stages need not check whether the health
+// endpoints are served.
+type Watchdog struct {
+	timeout time.Duration
+	now     func() time.Time
+
+	mu      sync.Mutex
+	next    uint64
+	running map[uint64]watched
+}
+
+// watched is an operation running under a Watchdog
+type watched struct {
+	name    string
+	started time.Time
+}
+
+// NewWatchdog returns a watchdog reporting the operations running for longer
+// than timeout, DefaultStallTimeout if 0
+func NewWatchdog(timeout time.Duration) *Watchdog {
+	if timeout <= 0 {
+		timeout = DefaultStallTimeout
+	}
+	return &Watchdog{
+		timeout: timeout,
+		now:     time.Now,
+		running: map[uint64]watched{},
+	}
+}
+
+// Watch starts watching the operation name, returning the func to call once
+// it ends
+func (w *Watchdog) Watch(name string) (done func()) {
+	if w == nil {
+		return func() {}
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	id := w.next
+	w.next++
+	w.running[id] = watched{name: name, started: w.now()}
+	return func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		delete(w.running, id)
+	}
+}
+
+// Check returns an error naming the longest running operation if it runs for
+// longer than the timeout
+func (w *Watchdog) Check(context.Context) error {
+	if w == nil {
+		return nil
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var oldest *watched
+	for id := range w.running {
+		op := w.running[id]
+		if oldest == nil || op.started.Before(oldest.started) {
+			oldest = &op
+		}
+	}
+	if oldest == nil {
+		return nil
+	}
+	if running := w.now().Sub(oldest.started); running > w.timeout {
+		return fmt.Errorf("%s running for %v, longer than %v", oldest.name, running.Round(time.Second), w.timeout)
+	}
+	return nil
+}
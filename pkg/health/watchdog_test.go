@@ -0,0 +1,65 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package health
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWatchdog(t *testing.T) {
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	w := NewWatchdog(time.Minute)
+	w.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	if err := w.Check(ctx); err != nil {
+		t.Errorf("Check() = %v with nothing running, want nil", err)
+	}
+	storeDone := w.Watch("store graph")
+	now = now.Add(30 * time.Second)
+	publishDone := w.Watch("publish")
+	if err := w.Check(ctx); err != nil {
+		t.Errorf("Check() = %v within the timeout, want nil", err)
+	}
+
+	now = now.Add(45 * time.Second)
+	err := w.Check(ctx)
+	if err == nil || !strings.Contains(err.Error(), "store graph running for 1m15s") {
+		t.Errorf("Check() = %v, want the store graph stalled", err)
+	}
+
+	// the operation ending clears the stall
+	storeDone()
+	if err := w.Check(ctx); err != nil {
+		t.Errorf("Check() = %v once the stalled operation ended, want nil", err)
+	}
+	publishDone()
+	now = now.Add(time.Hour)
+	if err := w.Check(ctx); err != nil {
+		t.Errorf("Check() = %v once all operations ended, want nil", err)
+	}
+}
+
+func TestWatchdog_Nil(t *testing.T) {
+	var w *Watchdog
+	w.Watch("store graph")()
+	if err := w.Check(context.Background()); err != nil {
+		t.Errorf("Check() = %v for a nil watchdog, want nil", err)
+	}
+}
@@ -0,0 +1,161 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kms
+
+import (
+	"context"
+	"crypto"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/guacsec/guac/pkg/ingestor/key"
+	"github.com/guacsec/guac/pkg/logging"
+	sigkms "github.com/sigstore/sigstore/pkg/signature/kms"
+)
+
+// DefaultRefreshInterval is how long a public key read from a KMS is used
+// before reading it again, so that a rotated key is picked up
+const DefaultRefreshInterval = 5 * time.Minute
+
+// IsReference reports whether ref is the URI of a key in one of the
+// supported KMS, e.g. awskms:///arn:aws:kms:us-east-1:1234:key/abcd,
+// gcpkms://projects/p/locations/l/keyRings/r/cryptoKeys/k, azurekms://vault.vault.azure.net/key
+// or hashivault://key
+func IsReference(ref string) bool {
+	for _, scheme := range sigkms.SupportedProviders() {
+		if strings.HasPrefix(ref, scheme) {
+			return true
+		}
+	}
+	return false
+}
+
+// kmsKey is the public key last read from a KMS
+type kmsKey struct {
+	key     *key.Key
+	fetched time.Time
+}
+
+type kmsProvider struct {
+	refreshInterval time.Duration
+
+	mu sync.Mutex
+	// refs are the KMS URIs of the keys by ID
+	refs map[string]string
+	// current is the last key read from each KMS URI
+	current map[string]kmsKey
+	// byHash holds every key read, including the ones rotated since, so that
+	// the signatures made before a rotation are still verified
+	byHash map[string]*key.Key
+	// now is replaced in tests
+	now func() time.Time
+}
+
+// NewKMSProvider returns a key provider reading the public keys of the
+// references added with AddReference from their KMS. Keys are read again
+// once refreshInterval has passed, DefaultRefreshInterval if it is not
+// positive.
+func NewKMSProvider(refreshInterval time.Duration) *kmsProvider {
+	if refreshInterval <= 0 {
+		refreshInterval = DefaultRefreshInterval
+	}
+	return &kmsProvider{
+		refreshInterval: refreshInterval,
+		refs:            map[string]string{},
+		current:         map[string]kmsKey{},
+		byHash:          map[string]*key.Key{},
+		now:             time.Now,
+	}
+}
+
+// AddReference makes the key at the KMS URI ref retrievable by id. A key
+// is also retrieved by its hash, which is the key ID of DSSE signatures.
+func (m *kmsProvider) AddReference(id string, ref string) error {
+	if !IsReference(ref) {
+		return fmt.Errorf("unsupported KMS key reference %q, expected one of %v", ref, sigkms.SupportedProviders())
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.refs[id] = ref
+	return nil
+}
+
+func (m *kmsProvider) RetrieveKey(ctx context.Context, id string) (*key.Key, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if ref, ok := m.refs[id]; ok {
+		return m.fetch(ctx, ref)
+	}
+	// the key ID of a signature is the hash of the current key, or of a
+	// key rotated since
+	for _, ref := range m.refs {
+		k, err := m.fetch(ctx, ref)
+		if err != nil {
+			return nil, err
+		}
+		if k.Hash == id {
+			return k, nil
+		}
+	}
+	if k, ok := m.byHash[id]; ok {
+		return k, nil
+	}
+	return nil, nil
+}
+
+// fetch returns the public key at ref, read from its KMS if the last read
+// is older than the refresh interval
+func (m *kmsProvider) fetch(ctx context.Context, ref string) (*key.Key, error) {
+	if cached, ok := m.current[ref]; ok && m.now().Sub(cached.fetched) < m.refreshInterval {
+		return cached.key, nil
+	}
+	sv, err := sigkms.Get(ctx, ref, crypto.SHA256)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load KMS key %s: %w", ref, err)
+	}
+	pub, err := sv.PublicKey()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read public key of KMS key %s: %w", ref, err)
+	}
+	k, err := key.NewKey(pub)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key of KMS key %s: %w", ref, err)
+	}
+	if previous, ok := m.current[ref]; ok && previous.key.Hash != k.Hash {
+		logging.FromContext(ctx).Infof("KMS key %s was rotated", ref)
+	}
+	m.current[ref] = kmsKey{key: k, fetched: m.now()}
+	m.byHash[k.Hash] = k
+	return k, nil
+}
+
+func (m *kmsProvider) StoreKey(ctx context.Context, id string, pk *key.Key) error {
+	return errors.New("keys are stored in the KMS, use AddReference")
+}
+
+func (m *kmsProvider) DeleteKey(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.refs, id)
+	return nil
+}
+
+func (m *kmsProvider) Type() key.KeyProviderType {
+	return "kms"
+}
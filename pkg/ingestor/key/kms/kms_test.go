@@ -0,0 +1,121 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kms
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"github.com/guacsec/guac/pkg/ingestor/key"
+	"github.com/guacsec/guac/pkg/logging"
+	"github.com/sigstore/sigstore/pkg/signature/kms/fake"
+)
+
+const fakeRef = fake.ReferenceScheme + "release-key"
+
+func newPrivateKey(t *testing.T) *ecdsa.PrivateKey {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return priv
+}
+
+func Test_IsReference(t *testing.T) {
+	for ref, want := range map[string]bool{
+		"awskms:///arn:aws:kms:us-east-1:123456789012:key/abcd":   true,
+		"gcpkms://projects/p/locations/l/keyRings/r/cryptoKeys/k": true,
+		"azurekms://vault.vault.azure.net/key":                    true,
+		"hashivault://release":                                    true,
+		"key.pem":                                                 false,
+		"s3://bucket/key.pem":                                     false,
+	} {
+		if got := IsReference(ref); got != want {
+			t.Errorf("IsReference(%q) = %v, want %v", ref, got, want)
+		}
+	}
+}
+
+func Test_kmsProvider_RetrieveKey(t *testing.T) {
+	priv := newPrivateKey(t)
+	ctx := context.WithValue(logging.WithLogger(context.Background()), fake.KmsCtxKey{}, priv)
+	want, err := key.NewKey(&priv.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	provider := NewKMSProvider(0)
+	if err := provider.AddReference("release", fakeRef); err != nil {
+		t.Fatalf("AddReference() error = %v", err)
+	}
+	if err := provider.AddReference("local", "key.pem"); err == nil {
+		t.Errorf("AddReference() expected error for a reference that is not a KMS URI")
+	}
+
+	for _, id := range []string{"release", want.Hash} {
+		got, err := provider.RetrieveKey(ctx, id)
+		if err != nil {
+			t.Fatalf("RetrieveKey(%q) error = %v", id, err)
+		}
+		if got == nil || got.Hash != want.Hash {
+			t.Errorf("RetrieveKey(%q) = %v, want %v", id, got, want)
+		}
+	}
+	if got, err := provider.RetrieveKey(ctx, "unknown"); got != nil || err != nil {
+		t.Errorf("RetrieveKey() of an unknown key = %v, %v, want nil, nil", got, err)
+	}
+	if err := provider.StoreKey(ctx, "release", want); err == nil {
+		t.Errorf("StoreKey() expected error")
+	}
+}
+
+func Test_kmsProvider_rotation(t *testing.T) {
+	old := newPrivateKey(t)
+	rotated := newPrivateKey(t)
+	oldKey, _ := key.NewKey(&old.PublicKey)
+	rotatedKey, _ := key.NewKey(&rotated.PublicKey)
+
+	provider := NewKMSProvider(time.Minute)
+	now := time.Now()
+	provider.now = func() time.Time { return now }
+	if err := provider.AddReference("release", fakeRef); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.WithValue(logging.WithLogger(context.Background()), fake.KmsCtxKey{}, old)
+	if got, err := provider.RetrieveKey(ctx, "release"); err != nil || got.Hash != oldKey.Hash {
+		t.Fatalf("RetrieveKey() = %v, %v, want the key before rotation", got, err)
+	}
+
+	// the key is rotated in the KMS, and read again after the refresh interval
+	ctx = context.WithValue(ctx, fake.KmsCtxKey{}, rotated)
+	if got, _ := provider.RetrieveKey(ctx, "release"); got.Hash != oldKey.Hash {
+		t.Errorf("RetrieveKey() before the refresh interval = %v, want the cached key", got)
+	}
+	now = now.Add(time.Minute)
+	if got, _ := provider.RetrieveKey(ctx, "release"); got.Hash != rotatedKey.Hash {
+		t.Errorf("RetrieveKey() after the refresh interval = %v, want the rotated key", got)
+	}
+
+	// signatures made before the rotation are still verified
+	if got, _ := provider.RetrieveKey(ctx, oldKey.Hash); got == nil || got.Hash != oldKey.Hash {
+		t.Errorf("RetrieveKey() of the rotated key hash = %v, want %v", got, oldKey)
+	}
+}
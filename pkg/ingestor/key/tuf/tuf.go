@@ -0,0 +1,184 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tuf
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/guacsec/guac/pkg/ingestor/key"
+	"github.com/guacsec/guac/pkg/logging"
+	"github.com/sigstore/sigstore/pkg/cryptoutils"
+	"github.com/theupdateframework/go-tuf/client"
+	"github.com/theupdateframework/go-tuf/data"
+)
+
+// DefaultRefreshInterval is how long the keys of a TUF repository are used
+// before updating them, so that added, rotated and revoked keys are picked up
+const DefaultRefreshInterval = 5 * time.Minute
+
+// keySuffix is the suffix of the targets holding a PEM public key
+const keySuffix = ".pem"
+
+// tufClient is the part of the go-tuf client used, replaced in tests
+type tufClient interface {
+	Update() (data.TargetFiles, error)
+	Targets() (data.TargetFiles, error)
+	Download(name string, dest client.Destination) error
+}
+
+type tufProvider struct {
+	client          tufClient
+	refreshInterval time.Duration
+
+	mu sync.Mutex
+	// keys are the public keys of the targets by key ID, the target name
+	// without its .pem suffix
+	keys map[string]*key.Key
+	// byHash are the same keys by hash, the key ID of DSSE signatures
+	byHash  map[string]*key.Key
+	updated time.Time
+	// now is replaced in tests
+	now func() time.Time
+}
+
+// NewTUFProvider returns a key provider for the PEM public keys that are the
+// targets of the TUF repository at mirror, e.g. `team-a/release.pem` for the
+// key ID `team-a/release`. The metadata is verified starting from the trusted
+// rootJSON, and updated once refreshInterval has passed,
+// DefaultRefreshInterval if it is not positive. Keys removed from the
+// repository are no longer retrieved after an update.
+func NewTUFProvider(ctx context.Context, mirror string, rootJSON []byte, refreshInterval time.Duration) (*tufProvider, error) {
+	remote, err := client.HTTPRemoteStore(mirror, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid TUF mirror %s: %w", mirror, err)
+	}
+	c := client.NewClient(client.MemoryLocalStore(), remote)
+	if err := c.Init(rootJSON); err != nil {
+		return nil, fmt.Errorf("invalid TUF root: %w", err)
+	}
+	return newTUFProvider(ctx, c, refreshInterval)
+}
+
+func newTUFProvider(ctx context.Context, c tufClient, refreshInterval time.Duration) (*tufProvider, error) {
+	if refreshInterval <= 0 {
+		refreshInterval = DefaultRefreshInterval
+	}
+	t := &tufProvider{
+		client:          c,
+		refreshInterval: refreshInterval,
+		keys:            map[string]*key.Key{},
+		byHash:          map[string]*key.Key{},
+		now:             time.Now,
+	}
+	// fail early on an unreachable or invalid repository
+	if err := t.update(ctx); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// update replaces the keys with the targets of the repository, they are left
+// unchanged if it fails
+func (t *tufProvider) update(ctx context.Context) error {
+	if _, err := t.client.Update(); err != nil {
+		return fmt.Errorf("failed to update TUF metadata: %w", err)
+	}
+	targets, err := t.client.Targets()
+	if err != nil {
+		return fmt.Errorf("failed to read TUF targets: %w", err)
+	}
+	names := make([]string, 0, len(targets))
+	for name := range targets {
+		if strings.HasSuffix(name, keySuffix) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	keys := map[string]*key.Key{}
+	byHash := map[string]*key.Key{}
+	for _, name := range names {
+		dest := &destination{}
+		if err := t.client.Download(name, dest); err != nil {
+			return fmt.Errorf("failed to download TUF target %s: %w", name, err)
+		}
+		pub, err := cryptoutils.UnmarshalPEMToPublicKey(dest.Bytes())
+		if err != nil {
+			return fmt.Errorf("invalid public key in TUF target %s: %w", name, err)
+		}
+		k, err := key.NewKey(pub)
+		if err != nil {
+			return fmt.Errorf("invalid public key in TUF target %s: %w", name, err)
+		}
+		keys[strings.TrimSuffix(name, keySuffix)] = k
+		byHash[k.Hash] = k
+	}
+	if len(keys) != len(t.keys) && !t.updated.IsZero() {
+		logging.FromContext(ctx).Infof("TUF repository now has %d keys, had %d", len(keys), len(t.keys))
+	}
+	t.keys = keys
+	t.byHash = byHash
+	t.updated = t.now()
+	return nil
+}
+
+func (t *tufProvider) RetrieveKey(ctx context.Context, id string) (*key.Key, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.now().Sub(t.updated) >= t.refreshInterval {
+		if err := t.update(ctx); err != nil {
+			// the metadata verification failing is a hard error: the keys
+			// may have been revoked
+			return nil, err
+		}
+	}
+	if k, ok := t.keys[id]; ok {
+		return k, nil
+	}
+	if k, ok := t.byHash[id]; ok {
+		return k, nil
+	}
+	return nil, nil
+}
+
+func (t *tufProvider) StoreKey(ctx context.Context, id string, pk *key.Key) error {
+	return errors.New("keys are stored in the TUF repository")
+}
+
+func (t *tufProvider) DeleteKey(ctx context.Context, id string) error {
+	return errors.New("keys are deleted from the TUF repository")
+}
+
+func (t *tufProvider) Type() key.KeyProviderType {
+	return "tuf"
+}
+
+// destination buffers a downloaded target
+type destination struct {
+	bytes.Buffer
+}
+
+func (d *destination) Delete() error {
+	d.Reset()
+	return nil
+}
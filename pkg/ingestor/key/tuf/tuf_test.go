@@ -0,0 +1,150 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tuf
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/guacsec/guac/pkg/ingestor/key"
+	"github.com/guacsec/guac/pkg/logging"
+	"github.com/sigstore/sigstore/pkg/cryptoutils"
+	"github.com/theupdateframework/go-tuf/client"
+	"github.com/theupdateframework/go-tuf/data"
+)
+
+// fakeClient serves the targets of a verified repository
+type fakeClient struct {
+	targets   map[string][]byte
+	updateErr error
+}
+
+func (f *fakeClient) Update() (data.TargetFiles, error) {
+	if f.updateErr != nil {
+		return nil, f.updateErr
+	}
+	return f.Targets()
+}
+
+func (f *fakeClient) Targets() (data.TargetFiles, error) {
+	files := data.TargetFiles{}
+	for name, b := range f.targets {
+		files[name] = data.TargetFileMeta{FileMeta: data.FileMeta{Length: int64(len(b))}}
+	}
+	return files, nil
+}
+
+func (f *fakeClient) Download(name string, dest client.Destination) error {
+	b, ok := f.targets[name]
+	if !ok {
+		return fmt.Errorf("unknown target %s", name)
+	}
+	_, err := dest.Write(b)
+	return err
+}
+
+func newPublicKey(t *testing.T) ([]byte, *key.Key) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pemBytes, err := cryptoutils.MarshalPublicKeyToPEM(&priv.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	k, err := key.NewKey(&priv.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pemBytes, k
+}
+
+func Test_tufProvider_RetrieveKey(t *testing.T) {
+	ctx := logging.WithLogger(context.Background())
+	releasePEM, release := newPublicKey(t)
+	repo := &fakeClient{targets: map[string][]byte{
+		"team-a/release.pem": releasePEM,
+		"README.md":          []byte("not a key"),
+	}}
+	provider, err := newTUFProvider(ctx, repo, 0)
+	if err != nil {
+		t.Fatalf("newTUFProvider() error = %v", err)
+	}
+
+	for _, id := range []string{"team-a/release", release.Hash} {
+		got, err := provider.RetrieveKey(ctx, id)
+		if err != nil {
+			t.Fatalf("RetrieveKey(%q) error = %v", id, err)
+		}
+		if got == nil || got.Hash != release.Hash {
+			t.Errorf("RetrieveKey(%q) = %v, want %v", id, got, release)
+		}
+	}
+	if got, err := provider.RetrieveKey(ctx, "README"); got != nil || err != nil {
+		t.Errorf("RetrieveKey() of a target that is not a key = %v, %v, want nil, nil", got, err)
+	}
+	if err := provider.StoreKey(ctx, "release", release); err == nil {
+		t.Errorf("StoreKey() expected error")
+	}
+}
+
+func Test_tufProvider_refresh(t *testing.T) {
+	ctx := logging.WithLogger(context.Background())
+	releasePEM, release := newPublicKey(t)
+	rotatedPEM, rotated := newPublicKey(t)
+	repo := &fakeClient{targets: map[string][]byte{"release.pem": releasePEM}}
+	provider, err := newTUFProvider(ctx, repo, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	provider.now = func() time.Time { return now }
+	provider.updated = now
+
+	// the key is rotated in the repository, and read again after the refresh
+	// interval
+	repo.targets = map[string][]byte{"release.pem": rotatedPEM}
+	if got, _ := provider.RetrieveKey(ctx, "release"); got.Hash != release.Hash {
+		t.Errorf("RetrieveKey() before the refresh interval = %v, want the previous key", got)
+	}
+	now = now.Add(time.Minute)
+	if got, _ := provider.RetrieveKey(ctx, "release"); got.Hash != rotated.Hash {
+		t.Errorf("RetrieveKey() after the refresh interval = %v, want the rotated key", got)
+	}
+	// the previous key was revoked
+	if got, _ := provider.RetrieveKey(ctx, release.Hash); got != nil {
+		t.Errorf("RetrieveKey() of the revoked key = %v, want nil", got)
+	}
+
+	repo.updateErr = errors.New("expired timestamp")
+	now = now.Add(time.Minute)
+	if _, err := provider.RetrieveKey(ctx, "release"); err == nil {
+		t.Errorf("RetrieveKey() expected error when the metadata cannot be updated")
+	}
+}
+
+func Test_newTUFProvider_invalidKey(t *testing.T) {
+	repo := &fakeClient{targets: map[string][]byte{"release.pem": []byte("not a key")}}
+	if _, err := newTUFProvider(logging.WithLogger(context.Background()), repo, 0); err == nil {
+		t.Errorf("newTUFProvider() expected error for an invalid key")
+	}
+}
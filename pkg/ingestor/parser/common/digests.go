@@ -0,0 +1,61 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"sort"
+	"strings"
+)
+
+// preferredDigestAlgorithm is used to identify artifacts known by several
+// digests, so that the same artifact gets the same primary digest
+const preferredDigestAlgorithm = "sha256"
+
+// ArtifactDigests splits the `algorithm:value` digests of an artifact into the
+// primary digest and the alternate digests of assembler.ArtifactNode. The
+// sha256 digest is primary if present, otherwise the first in sorted order.
+func ArtifactDigests(digests []string) (string, []string) {
+	if len(digests) == 0 {
+		return "", nil
+	}
+	sorted := append([]string{}, digests...)
+	sort.Strings(sorted)
+	primary := 0
+	for i, d := range sorted {
+		if strings.HasPrefix(strings.ToLower(d), preferredDigestAlgorithm+":") {
+			primary = i
+			break
+		}
+	}
+	var alternates []string
+	for i, d := range sorted {
+		if i != primary {
+			alternates = append(alternates, d)
+		}
+	}
+	return sorted[primary], alternates
+}
+
+// DigestAlgorithm names a hash algorithm as in SPDX documents and in-toto
+// digest sets, e.g. sha256 for SHA-256, so that all formats give the same
+// digests
+func DigestAlgorithm(algorithm string) string {
+	algorithm = strings.ToLower(algorithm)
+	if strings.HasPrefix(algorithm, "sha-") {
+		algorithm = "sha" + strings.TrimPrefix(algorithm, "sha-")
+	}
+	return algorithm
+}
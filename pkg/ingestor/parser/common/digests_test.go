@@ -0,0 +1,68 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestArtifactDigests(t *testing.T) {
+	tests := []struct {
+		name           string
+		digests        []string
+		wantPrimary    string
+		wantAlternates []string
+	}{{
+		name: "none",
+	}, {
+		name:        "single",
+		digests:     []string{"sha1:abc"},
+		wantPrimary: "sha1:abc",
+	}, {
+		name:           "sha256 preferred",
+		digests:        []string{"sha512:def", "sha1:abc", "SHA256:123"},
+		wantPrimary:    "SHA256:123",
+		wantAlternates: []string{"sha1:abc", "sha512:def"},
+	}, {
+		name:           "sorted without sha256",
+		digests:        []string{"sha512:def", "sha1:abc"},
+		wantPrimary:    "sha1:abc",
+		wantAlternates: []string{"sha512:def"},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			primary, alternates := ArtifactDigests(tt.digests)
+			if primary != tt.wantPrimary || !reflect.DeepEqual(alternates, tt.wantAlternates) {
+				t.Errorf("ArtifactDigests() = %v, %v, want %v, %v", primary, alternates, tt.wantPrimary, tt.wantAlternates)
+			}
+		})
+	}
+}
+
+func TestDigestAlgorithm(t *testing.T) {
+	tests := map[string]string{
+		"SHA-256":  "sha256",
+		"sha-512":  "sha512",
+		"SHA3-512": "sha3-512",
+		"md5":      "md5",
+	}
+	for algorithm, want := range tests {
+		if got := DigestAlgorithm(algorithm); got != want {
+			t.Errorf("DigestAlgorithm(%q) = %q, want %q", algorithm, got, want)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/guacsec/guac/pkg/assembler"
+	"github.com/guacsec/guac/pkg/handler/processor"
+)
+
+// DocumentMetadataType is the metadata type of the node describing an SBOM
+// document
+const DocumentMetadataType = "document"
+
+// Formats stamped on the document node by the SBOM parsers
+const (
+	DocumentFormatSPDX      = "spdx"
+	DocumentFormatCycloneDX = "cyclonedx"
+	DocumentFormatSyft      = "syft"
+)
+
+// CreateDocumentNode returns the metadata node of an SBOM document, carrying
+// the format and specification version of the document. The node is
+// identified by id, such as the SPDX document namespace or the CycloneDX
+// serial number, or by the digest of the document when id is empty.
+func CreateDocumentNode(doc *processor.Document, id string, format string, specVersion string) assembler.MetadataNode {
+	if id == "" {
+		sum := sha256.Sum256(doc.Blob)
+		id = "sha256:" + hex.EncodeToString(sum[:])
+	}
+	details := assembler.SourceProperties(doc.SourceInformation)
+	details["format"] = format
+	details["specVersion"] = specVersion
+	return assembler.MetadataNode{
+		MetadataType: DocumentMetadataType,
+		ID:           id,
+		Details:      details,
+	}
+}
+
+// CreateDocumentEdge returns the edge from the document node to the top level
+// package described by the document, or nil if the document does not
+// describe an identifiable package
+func CreateDocumentEdge(document assembler.MetadataNode, pkg assembler.PackageNode) []assembler.GuacEdge {
+	if pkg.Purl == "" {
+		return nil
+	}
+	return []assembler.GuacEdge{assembler.MetadataForEdge{
+		MetadataNode: document,
+		ForPackage:   pkg,
+	}}
+}
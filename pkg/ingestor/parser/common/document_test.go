@@ -0,0 +1,81 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/guacsec/guac/pkg/assembler"
+	"github.com/guacsec/guac/pkg/handler/processor"
+)
+
+func TestCreateDocumentNode(t *testing.T) {
+	doc := &processor.Document{
+		Blob:              []byte("{}"),
+		SourceInformation: processor.SourceInformation{Collector: "TestCollector", Source: "TestSource"},
+	}
+	tests := []struct {
+		name string
+		id   string
+		want assembler.MetadataNode
+	}{{
+		name: "identified by the document",
+		id:   "urn:uuid:6a44e622-2983-4566-bf90-f87b6103ebaf",
+		want: assembler.MetadataNode{
+			MetadataType: DocumentMetadataType,
+			ID:           "urn:uuid:6a44e622-2983-4566-bf90-f87b6103ebaf",
+			Details: map[string]interface{}{
+				"format":      DocumentFormatCycloneDX,
+				"specVersion": "1.5",
+				"source":      "TestSource",
+				"collector":   "TestCollector",
+			},
+		},
+	}, {
+		name: "identified by digest",
+		want: assembler.MetadataNode{
+			MetadataType: DocumentMetadataType,
+			ID:           "sha256:44136fa355b3678a1146ad16f7e8649e94fb4fc21fe77e8310c060f61caaff8a",
+			Details: map[string]interface{}{
+				"format":      DocumentFormatCycloneDX,
+				"specVersion": "1.5",
+				"source":      "TestSource",
+				"collector":   "TestCollector",
+			},
+		},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := CreateDocumentNode(doc, tt.id, DocumentFormatCycloneDX, "1.5")
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CreateDocumentNode() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreateDocumentEdge(t *testing.T) {
+	document := assembler.MetadataNode{MetadataType: DocumentMetadataType, ID: "urn:uuid:1"}
+	if edges := CreateDocumentEdge(document, assembler.PackageNode{Name: "image"}); edges != nil {
+		t.Errorf("CreateDocumentEdge() = %v, want no edge for a package without purl", edges)
+	}
+	pkg := assembler.PackageNode{Name: "image", Purl: "pkg:oci/image"}
+	want := []assembler.GuacEdge{assembler.MetadataForEdge{MetadataNode: document, ForPackage: pkg}}
+	if edges := CreateDocumentEdge(document, pkg); !reflect.DeepEqual(edges, want) {
+		t.Errorf("CreateDocumentEdge() = %v, want %v", edges, want)
+	}
+}
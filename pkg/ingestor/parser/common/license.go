@@ -0,0 +1,247 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/guacsec/guac/pkg/assembler"
+	"github.com/guacsec/guac/pkg/handler/processor"
+)
+
+// maxLicenseAlternatives bounds the size of the disjunctive normal form of a
+// license expression, which grows exponentially with nested AND of ORs
+const maxLicenseAlternatives = 64
+
+var licenseIDRegex = regexp.MustCompile(`^(DocumentRef-[A-Za-z0-9.\-]+:)?[A-Za-z0-9.\-]+\+?$`)
+
+// LicenseTerm is a single license of an SPDX license expression, with the
+// optional exception given by `WITH`
+type LicenseTerm struct {
+	License   string
+	Exception string
+}
+
+func (t LicenseTerm) String() string {
+	if t.Exception != "" {
+		return t.License + " WITH " + t.Exception
+	}
+	return t.License
+}
+
+// ParseLicenseExpression parses an SPDX license expression and returns it in
+// disjunctive normal form: a list of alternatives, each being a list of
+// licenses that all apply (e.g. `(MIT OR Apache-2.0) AND BSD-3-Clause`
+// returns `[[MIT BSD-3-Clause] [Apache-2.0 BSD-3-Clause]]`).
+func ParseLicenseExpression(expr string) ([][]LicenseTerm, error) {
+	p := &licenseExprParser{tokens: tokenizeLicenseExpression(expr)}
+	if len(p.tokens) == 0 {
+		return nil, fmt.Errorf("empty license expression")
+	}
+	dnf, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos != len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q in license expression", p.tokens[p.pos])
+	}
+	return dnf, nil
+}
+
+// CreateLicenseGraph returns the license nodes and the edges from pkg to them
+// for the license expression expr. Values that are not valid expressions,
+// such as NOASSERTION, result in a single raw node holding expr.
+func CreateLicenseGraph(pkg assembler.PackageNode, expr string, s processor.SourceInformation) ([]assembler.LicenseNode, []assembler.HasLicenseEdge) {
+	expr = strings.TrimSpace(expr)
+	if expr == "" {
+		return nil, nil
+	}
+
+	dnf, err := ParseLicenseExpression(expr)
+	if err != nil || isSpecialLicenseValue(expr) {
+		node := assembler.LicenseNode{
+			ID:       expr,
+			Raw:      true,
+			NodeData: *assembler.NewObjectMetadata(s),
+		}
+		return []assembler.LicenseNode{node}, []assembler.HasLicenseEdge{{
+			PackageNode: pkg,
+			LicenseNode: node,
+			Expression:  expr,
+		}}
+	}
+
+	alternatives := make([]string, len(dnf))
+	for i, terms := range dnf {
+		parts := make([]string, len(terms))
+		for j, t := range terms {
+			parts[j] = t.String()
+		}
+		alternatives[i] = strings.Join(parts, " AND ")
+	}
+
+	nodes := []assembler.LicenseNode{}
+	edges := []assembler.HasLicenseEdge{}
+	seen := map[LicenseTerm]int{}
+	for i, terms := range dnf {
+		for _, t := range terms {
+			if idx, ok := seen[t]; ok {
+				if !containsString(edges[idx].Alternatives, alternatives[i]) {
+					edges[idx].Alternatives = append(edges[idx].Alternatives, alternatives[i])
+				}
+				continue
+			}
+			node := assembler.LicenseNode{
+				ID:       t.License,
+				NodeData: *assembler.NewObjectMetadata(s),
+			}
+			seen[t] = len(edges)
+			nodes = append(nodes, node)
+			edges = append(edges, assembler.HasLicenseEdge{
+				PackageNode:  pkg,
+				LicenseNode:  node,
+				Expression:   expr,
+				Alternatives: []string{alternatives[i]},
+				Exception:    t.Exception,
+			})
+		}
+	}
+	return nodes, edges
+}
+
+// isSpecialLicenseValue reports values that SPDX allows in place of a
+// license expression
+func isSpecialLicenseValue(expr string) bool {
+	return expr == "NOASSERTION" || expr == "NONE"
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func tokenizeLicenseExpression(expr string) []string {
+	expr = strings.ReplaceAll(expr, "(", " ( ")
+	expr = strings.ReplaceAll(expr, ")", " ) ")
+	return strings.Fields(expr)
+}
+
+type licenseExprParser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *licenseExprParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+// isOperator matches the SPDX operators, which producers also emit in
+// lower case
+func (p *licenseExprParser) isOperator(op string) bool {
+	return strings.EqualFold(p.peek(), op)
+}
+
+// parseOr parses `and-expr (OR and-expr)*`
+func (p *licenseExprParser) parseOr() ([][]LicenseTerm, error) {
+	dnf, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOperator("OR") {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		dnf = append(dnf, right...)
+		if len(dnf) > maxLicenseAlternatives {
+			return nil, fmt.Errorf("license expression has too many alternatives")
+		}
+	}
+	return dnf, nil
+}
+
+// parseAnd parses `with-expr (AND with-expr)*`
+func (p *licenseExprParser) parseAnd() ([][]LicenseTerm, error) {
+	dnf, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOperator("AND") {
+		p.pos++
+		right, err := p.parsePrimary()
+		if err != nil {
+			return nil, err
+		}
+		if len(dnf)*len(right) > maxLicenseAlternatives {
+			return nil, fmt.Errorf("license expression has too many alternatives")
+		}
+		product := [][]LicenseTerm{}
+		for _, l := range dnf {
+			for _, r := range right {
+				terms := append(append([]LicenseTerm{}, l...), r...)
+				product = append(product, terms)
+			}
+		}
+		dnf = product
+	}
+	return dnf, nil
+}
+
+// parsePrimary parses `license-id [WITH exception-id]` or `( or-expr )`
+func (p *licenseExprParser) parsePrimary() ([][]LicenseTerm, error) {
+	tok := p.peek()
+	switch {
+	case tok == "":
+		return nil, fmt.Errorf("unexpected end of license expression")
+	case tok == "(":
+		p.pos++
+		dnf, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ")" {
+			return nil, fmt.Errorf("missing closing parenthesis in license expression")
+		}
+		p.pos++
+		return dnf, nil
+	case p.isOperator("AND"), p.isOperator("OR"), p.isOperator("WITH"), tok == ")":
+		return nil, fmt.Errorf("unexpected %q in license expression", tok)
+	case !licenseIDRegex.MatchString(tok):
+		return nil, fmt.Errorf("invalid license identifier %q", tok)
+	}
+	p.pos++
+	term := LicenseTerm{License: tok}
+	if p.isOperator("WITH") {
+		p.pos++
+		exception := p.peek()
+		if exception == "" || !licenseIDRegex.MatchString(exception) {
+			return nil, fmt.Errorf("invalid license exception %q", exception)
+		}
+		p.pos++
+		term.Exception = exception
+	}
+	return [][]LicenseTerm{{term}}, nil
+}
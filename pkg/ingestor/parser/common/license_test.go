@@ -0,0 +1,160 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/guacsec/guac/pkg/assembler"
+	"github.com/guacsec/guac/pkg/handler/processor"
+)
+
+func TestParseLicenseExpression(t *testing.T) {
+	tests := []struct {
+		name    string
+		expr    string
+		want    [][]LicenseTerm
+		wantErr bool
+	}{{
+		name: "single license",
+		expr: "MIT",
+		want: [][]LicenseTerm{{{License: "MIT"}}},
+	}, {
+		name: "or",
+		expr: "(MIT OR Apache-2.0)",
+		want: [][]LicenseTerm{{{License: "MIT"}}, {{License: "Apache-2.0"}}},
+	}, {
+		name: "and binds tighter than or",
+		expr: "MIT OR Apache-2.0 AND BSD-3-Clause",
+		want: [][]LicenseTerm{{{License: "MIT"}}, {{License: "Apache-2.0"}, {License: "BSD-3-Clause"}}},
+	}, {
+		name: "and of or",
+		expr: "(MIT OR Apache-2.0) AND BSD-3-Clause",
+		want: [][]LicenseTerm{
+			{{License: "MIT"}, {License: "BSD-3-Clause"}},
+			{{License: "Apache-2.0"}, {License: "BSD-3-Clause"}},
+		},
+	}, {
+		name: "with exception and lower case operators",
+		expr: "GPL-2.0-only with Classpath-exception-2.0 or LicenseRef-custom",
+		want: [][]LicenseTerm{
+			{{License: "GPL-2.0-only", Exception: "Classpath-exception-2.0"}},
+			{{License: "LicenseRef-custom"}},
+		},
+	}, {
+		name: "or later and document ref",
+		expr: "GPL-2.0+ AND DocumentRef-other:LicenseRef-1",
+		want: [][]LicenseTerm{{{License: "GPL-2.0+"}, {License: "DocumentRef-other:LicenseRef-1"}}},
+	}, {
+		name:    "empty",
+		expr:    "  ",
+		wantErr: true,
+	}, {
+		name:    "unbalanced parenthesis",
+		expr:    "(MIT OR Apache-2.0",
+		wantErr: true,
+	}, {
+		name:    "dangling operator",
+		expr:    "MIT AND",
+		wantErr: true,
+	}, {
+		name:    "free text",
+		expr:    "Apache License 2.0",
+		wantErr: true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseLicenseExpression(tt.expr)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseLicenseExpression() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseLicenseExpression() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreateLicenseGraph(t *testing.T) {
+	s := processor.SourceInformation{Collector: "TestCollector", Source: "TestSource"}
+	pkg := assembler.PackageNode{Name: "pkg", Purl: "pkg:generic/pkg@1.0.0"}
+	license := func(id string, raw bool) assembler.LicenseNode {
+		return assembler.LicenseNode{ID: id, Raw: raw, NodeData: *assembler.NewObjectMetadata(s)}
+	}
+
+	tests := []struct {
+		name      string
+		expr      string
+		wantNodes []assembler.LicenseNode
+		wantEdges []assembler.HasLicenseEdge
+	}{{
+		name: "empty",
+		expr: "",
+	}, {
+		name:      "noassertion",
+		expr:      "NOASSERTION",
+		wantNodes: []assembler.LicenseNode{license("NOASSERTION", true)},
+		wantEdges: []assembler.HasLicenseEdge{{PackageNode: pkg, LicenseNode: license("NOASSERTION", true), Expression: "NOASSERTION"}},
+	}, {
+		name:      "malformed",
+		expr:      "MIT OR (Apache-2.0",
+		wantNodes: []assembler.LicenseNode{license("MIT OR (Apache-2.0", true)},
+		wantEdges: []assembler.HasLicenseEdge{{PackageNode: pkg, LicenseNode: license("MIT OR (Apache-2.0", true), Expression: "MIT OR (Apache-2.0"}},
+	}, {
+		name:      "and of or",
+		expr:      "(MIT OR LicenseRef-acme) AND BSD-3-Clause",
+		wantNodes: []assembler.LicenseNode{license("MIT", false), license("BSD-3-Clause", false), license("LicenseRef-acme", false)},
+		wantEdges: []assembler.HasLicenseEdge{{
+			PackageNode:  pkg,
+			LicenseNode:  license("MIT", false),
+			Expression:   "(MIT OR LicenseRef-acme) AND BSD-3-Clause",
+			Alternatives: []string{"MIT AND BSD-3-Clause"},
+		}, {
+			PackageNode:  pkg,
+			LicenseNode:  license("BSD-3-Clause", false),
+			Expression:   "(MIT OR LicenseRef-acme) AND BSD-3-Clause",
+			Alternatives: []string{"MIT AND BSD-3-Clause", "LicenseRef-acme AND BSD-3-Clause"},
+		}, {
+			PackageNode:  pkg,
+			LicenseNode:  license("LicenseRef-acme", false),
+			Expression:   "(MIT OR LicenseRef-acme) AND BSD-3-Clause",
+			Alternatives: []string{"LicenseRef-acme AND BSD-3-Clause"},
+		}},
+	}, {
+		name:      "exception",
+		expr:      "GPL-2.0-only WITH Classpath-exception-2.0",
+		wantNodes: []assembler.LicenseNode{license("GPL-2.0-only", false)},
+		wantEdges: []assembler.HasLicenseEdge{{
+			PackageNode:  pkg,
+			LicenseNode:  license("GPL-2.0-only", false),
+			Expression:   "GPL-2.0-only WITH Classpath-exception-2.0",
+			Alternatives: []string{"GPL-2.0-only WITH Classpath-exception-2.0"},
+			Exception:    "Classpath-exception-2.0",
+		}},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nodes, edges := CreateLicenseGraph(pkg, tt.expr, s)
+			if !reflect.DeepEqual(nodes, tt.wantNodes) {
+				t.Errorf("CreateLicenseGraph() nodes = %v, want %v", nodes, tt.wantNodes)
+			}
+			if !reflect.DeepEqual(edges, tt.wantEdges) {
+				t.Errorf("CreateLicenseGraph() edges = %v, want %v", edges, tt.wantEdges)
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+//
+// Copyright 2022 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"net/url"
+
+	"github.com/guacsec/guac/pkg/assembler"
+)
+
+// Sources of the fallback key of a package without a purl, recorded as the
+// key_source property of the package node
+const (
+	// PackageKeyNameVersion keys the package on its group, name and version,
+	// so that the same package merges across documents
+	PackageKeyNameVersion = "name_version"
+	// PackageKeyRef keys the package on its reference within the document,
+	// such as the CycloneDX bom-ref or the SPDX identifier, when it has no
+	// name
+	PackageKeyRef = "ref"
+)
+
+// SetFallbackPackageKey gives pkg a purl to be merged on when the document
+// did not give one, so that packages without purls become distinct nodes
+// instead of colliding on an empty purl. The key is
+// `pkg:guac/generic/group/name@version` if the package has a name, or
+// `pkg:guac/ref/ref?document=document` otherwise, and how it was derived is
+// recorded in KeySource. pkg is left unchanged if it has a purl or nothing
+// to derive one from.
+func SetFallbackPackageKey(pkg *assembler.PackageNode, group string, document string, ref string) {
+	if pkg.Purl != "" {
+		return
+	}
+	if pkg.Name != "" {
+		key := "pkg:guac/generic/"
+		if group != "" {
+			key += url.PathEscape(group) + "/"
+		}
+		key += url.PathEscape(pkg.Name)
+		if pkg.Version != "" {
+			key += "@" + url.PathEscape(pkg.Version)
+		}
+		pkg.Purl = key
+		pkg.KeySource = PackageKeyNameVersion
+		return
+	}
+	if ref != "" {
+		// a reference is only unique within its document
+		pkg.Purl = "pkg:guac/ref/" + url.PathEscape(ref) + "?document=" + url.QueryEscape(document)
+		pkg.KeySource = PackageKeyRef
+	}
+}
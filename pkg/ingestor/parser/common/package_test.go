@@ -0,0 +1,71 @@
+//
+// Copyright 2022 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"testing"
+
+	"github.com/guacsec/guac/pkg/assembler"
+)
+
+func TestSetFallbackPackageKey(t *testing.T) {
+	tests := []struct {
+		name          string
+		pkg           assembler.PackageNode
+		group         string
+		ref           string
+		wantPurl      string
+		wantKeySource string
+	}{{
+		name:     "purl is kept",
+		pkg:      assembler.PackageNode{Name: "quote", Purl: "pkg:golang/rsc.io/quote@v1.5.2"},
+		ref:      "quote",
+		wantPurl: "pkg:golang/rsc.io/quote@v1.5.2",
+	}, {
+		name:          "group name and version",
+		pkg:           assembler.PackageNode{Name: "quote", Version: "v1.5.2"},
+		group:         "rsc.io",
+		wantPurl:      "pkg:guac/generic/rsc.io/quote@v1.5.2",
+		wantKeySource: PackageKeyNameVersion,
+	}, {
+		name:          "name only is escaped",
+		pkg:           assembler.PackageNode{Name: "my lib/core"},
+		ref:           "lib",
+		wantPurl:      "pkg:guac/generic/my%20lib%2Fcore",
+		wantKeySource: PackageKeyNameVersion,
+	}, {
+		name:          "ref scoped to the document",
+		pkg:           assembler.PackageNode{},
+		ref:           "SPDXRef-go-module-rsc.io/quote",
+		wantPurl:      "pkg:guac/ref/SPDXRef-go-module-rsc.io%2Fquote?document=https%3A%2F%2Fexample.com%2Fdoc",
+		wantKeySource: PackageKeyRef,
+	}, {
+		name: "nothing to derive a key from",
+		pkg:  assembler.PackageNode{Version: "1.0.0"},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pkg := tt.pkg
+			SetFallbackPackageKey(&pkg, tt.group, "https://example.com/doc", tt.ref)
+			if pkg.Purl != tt.wantPurl {
+				t.Errorf("Purl = %q, want %q", pkg.Purl, tt.wantPurl)
+			}
+			if pkg.KeySource != tt.wantKeySource {
+				t.Errorf("KeySource = %q, want %q", pkg.KeySource, tt.wantKeySource)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+//
+// Copyright 2022 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/guacsec/guac/pkg/assembler"
+	"github.com/guacsec/guac/pkg/handler/processor"
+)
+
+// vulnerabilityIDRegex matches the vulnerability identifiers commonly found
+// in advisory URLs (CVE, GitHub and OSV database identifiers)
+var vulnerabilityIDRegex = regexp.MustCompile(`(?i)\b(CVE-\d{4}-\d{4,}|GHSA(?:-[23456789cfghjmpqrvwx]{4}){3}|GO-\d{4}-\d{4,}|PYSEC-\d{4}-\d+|RUSTSEC-\d{4}-\d{4,})\b`)
+
+// CreateReferenceGraph returns the external reference node for rawURL and the
+// edge from pkg to it, labeled with refType. Advisory references are also
+// linked to the vulnerabilities whose ids appear in the URL, so that they
+// merge with the vulnerabilities found by other documents. Empty and
+// malformed URLs are skipped.
+func CreateReferenceGraph(pkg assembler.PackageNode, refType string, rawURL string, s processor.SourceInformation) ([]assembler.GuacNode, []assembler.GuacEdge) {
+	rawURL = strings.TrimSpace(rawURL)
+	if !isReferenceURL(rawURL) {
+		return nil, nil
+	}
+	ref := assembler.ExternalReferenceNode{
+		URL:      rawURL,
+		NodeData: *assembler.NewObjectMetadata(s),
+	}
+	nodes := []assembler.GuacNode{ref}
+	edges := []assembler.GuacEdge{assembler.ReferencesEdge{
+		PackageNode:           pkg,
+		ExternalReferenceNode: ref,
+		ReferenceType:         refType,
+	}}
+	if !IsAdvisoryReference(refType) {
+		return nodes, edges
+	}
+	for _, id := range VulnerabilityIDs(rawURL) {
+		vuln := assembler.VulnerabilityNode{
+			ID:       id,
+			NodeData: *assembler.NewObjectMetadata(s),
+		}
+		nodes = append(nodes, vuln)
+		edges = append(edges, assembler.ReferencesEdge{
+			VulnerabilityNode:     vuln,
+			ExternalReferenceNode: ref,
+			ReferenceType:         refType,
+		})
+	}
+	return nodes, edges
+}
+
+// IsAdvisoryReference reports whether refType is the type of a security
+// advisory reference in CycloneDX (`advisories`) or SPDX (`advisory`)
+func IsAdvisoryReference(refType string) bool {
+	return strings.EqualFold(refType, "advisories") || strings.EqualFold(refType, "advisory")
+}
+
+// VulnerabilityIDs returns the vulnerability ids found in s, in their
+// canonical case (e.g. `CVE-2022-1234`, `GHSA-57j2-w4cx-62h2`)
+func VulnerabilityIDs(s string) []string {
+	ids := []string{}
+	for _, match := range vulnerabilityIDRegex.FindAllString(s, -1) {
+		id := strings.ToUpper(match)
+		if strings.HasPrefix(id, "GHSA-") {
+			id = "GHSA-" + strings.ToLower(match[len("GHSA-"):])
+		}
+		if !containsString(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// isReferenceURL reports whether s is an absolute URL, such as
+// `https://github.com/guacsec/guac` or `git+ssh://git@github.com/guacsec/guac`
+func isReferenceURL(s string) bool {
+	if s == "" {
+		return false
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return false
+	}
+	return u.Scheme != "" && (u.Host != "" || u.Opaque != "")
+}
@@ -0,0 +1,117 @@
+//
+// Copyright 2022 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/guacsec/guac/pkg/assembler"
+	"github.com/guacsec/guac/pkg/handler/processor"
+)
+
+func TestVulnerabilityIDs(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		want []string
+	}{{
+		name: "github advisory",
+		s:    "https://github.com/advisories/ghsa-57J2-w4cx-62h2",
+		want: []string{"GHSA-57j2-w4cx-62h2"},
+	}, {
+		name: "cve repeated",
+		s:    "https://nvd.nist.gov/vuln/detail/cve-2022-24785?id=CVE-2022-24785",
+		want: []string{"CVE-2022-24785"},
+	}, {
+		name: "osv",
+		s:    "https://osv.dev/vulnerability/GO-2022-0969",
+		want: []string{"GO-2022-0969"},
+	}, {
+		name: "none",
+		s:    "https://example.com/security",
+		want: []string{},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := VulnerabilityIDs(tt.s); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("VulnerabilityIDs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreateReferenceGraph(t *testing.T) {
+	s := processor.SourceInformation{Collector: "TestCollector", Source: "TestSource"}
+	pkg := assembler.PackageNode{Name: "pkg", Purl: "pkg:generic/pkg@1.0.0"}
+	ref := func(url string) assembler.ExternalReferenceNode {
+		return assembler.ExternalReferenceNode{URL: url, NodeData: *assembler.NewObjectMetadata(s)}
+	}
+	advisory := ref("https://github.com/advisories/GHSA-57j2-w4cx-62h2")
+	vuln := assembler.VulnerabilityNode{ID: "GHSA-57j2-w4cx-62h2", NodeData: *assembler.NewObjectMetadata(s)}
+
+	tests := []struct {
+		name      string
+		refType   string
+		url       string
+		wantNodes []assembler.GuacNode
+		wantEdges []assembler.GuacEdge
+	}{{
+		name:    "empty",
+		refType: "vcs",
+		url:     " ",
+	}, {
+		name:    "malformed",
+		refType: "vcs",
+		url:     "git@github.com:guacsec/guac.git",
+	}, {
+		name:    "no scheme",
+		refType: "website",
+		url:     "NOASSERTION",
+	}, {
+		name:      "vcs",
+		refType:   "vcs",
+		url:       "git+https://github.com/guacsec/guac",
+		wantNodes: []assembler.GuacNode{ref("git+https://github.com/guacsec/guac")},
+		wantEdges: []assembler.GuacEdge{assembler.ReferencesEdge{PackageNode: pkg, ExternalReferenceNode: ref("git+https://github.com/guacsec/guac"), ReferenceType: "vcs"}},
+	}, {
+		name:      "advisory",
+		refType:   "advisories",
+		url:       advisory.URL,
+		wantNodes: []assembler.GuacNode{advisory, vuln},
+		wantEdges: []assembler.GuacEdge{
+			assembler.ReferencesEdge{PackageNode: pkg, ExternalReferenceNode: advisory, ReferenceType: "advisories"},
+			assembler.ReferencesEdge{VulnerabilityNode: vuln, ExternalReferenceNode: advisory, ReferenceType: "advisories"},
+		},
+	}, {
+		name:      "vulnerability id in other reference",
+		refType:   "website",
+		url:       advisory.URL,
+		wantNodes: []assembler.GuacNode{advisory},
+		wantEdges: []assembler.GuacEdge{assembler.ReferencesEdge{PackageNode: pkg, ExternalReferenceNode: advisory, ReferenceType: "website"}},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nodes, edges := CreateReferenceGraph(pkg, tt.refType, tt.url, s)
+			if !reflect.DeepEqual(nodes, tt.wantNodes) {
+				t.Errorf("CreateReferenceGraph() nodes = %v, want %v", nodes, tt.wantNodes)
+			}
+			if !reflect.DeepEqual(edges, tt.wantEdges) {
+				t.Errorf("CreateReferenceGraph() edges = %v, want %v", edges, tt.wantEdges)
+			}
+		})
+	}
+}
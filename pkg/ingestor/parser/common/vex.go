@@ -0,0 +1,78 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"github.com/guacsec/guac/pkg/assembler"
+	"github.com/guacsec/guac/pkg/handler/processor"
+)
+
+// CreateVEXProduct returns the node of a product a VEX statement is about: a
+// package if it has a purl, else an artifact if it has `algorithm:value`
+// digests, else a package keyed on its name. ref identifies the product in
+// the document.
+func CreateVEXProduct(purl string, name string, digests []string, ref string, s processor.SourceInformation) assembler.GuacNode {
+	if purl == "" && len(digests) > 0 {
+		digest, alternates := ArtifactDigests(digests)
+		return assembler.ArtifactNode{
+			Name:             name,
+			Digest:           digest,
+			AlternateDigests: alternates,
+			NodeData:         *assembler.NewObjectMetadata(s),
+		}
+	}
+	pkg := assembler.PackageNode{
+		Purl:     purl,
+		NodeData: *assembler.NewObjectMetadata(s),
+	}
+	if len(digests) > 0 {
+		pkg.Digest = digests
+	}
+	if purl == "" {
+		pkg.Name = name
+		SetFallbackPackageKey(&pkg, "", s.Source, ref)
+	}
+	return pkg
+}
+
+// VEXProductKey returns the key a product node from CreateVEXProduct is
+// merged on, to deduplicate the products of a document
+func VEXProductKey(product assembler.GuacNode) string {
+	switch p := product.(type) {
+	case assembler.ArtifactNode:
+		return p.Digest
+	case assembler.PackageNode:
+		return p.Purl
+	}
+	return ""
+}
+
+// CreateAffectsEdge returns the edge asserting the VEX status of the
+// vulnerability for a product node from CreateVEXProduct
+func CreateAffectsEdge(vuln assembler.VulnerabilityNode, product assembler.GuacNode, status string, justification string) assembler.AffectsEdge {
+	e := assembler.AffectsEdge{
+		VulnerabilityNode: vuln,
+		AnalysisState:     status,
+		Justification:     justification,
+	}
+	switch p := product.(type) {
+	case assembler.ArtifactNode:
+		e.ArtifactNode = p
+	case assembler.PackageNode:
+		e.PackageNode = p
+	}
+	return e
+}
@@ -0,0 +1,65 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/guacsec/guac/pkg/assembler"
+	"github.com/guacsec/guac/pkg/handler/processor"
+)
+
+func TestCreateVEXProduct(t *testing.T) {
+	s := processor.SourceInformation{Collector: "TestCollector", Source: "https://example.com/vex"}
+	tests := []struct {
+		name    string
+		purl    string
+		digests []string
+		want    assembler.GuacNode
+		wantKey string
+	}{{
+		name:    "purl",
+		purl:    "pkg:npm/tunnel@0.0.6",
+		digests: []string{"sha256:123"},
+		want:    assembler.PackageNode{Purl: "pkg:npm/tunnel@0.0.6", Digest: []string{"sha256:123"}, NodeData: *assembler.NewObjectMetadata(s)},
+		wantKey: "pkg:npm/tunnel@0.0.6",
+	}, {
+		name:    "digests",
+		digests: []string{"sha512:456", "sha256:123"},
+		want:    assembler.ArtifactNode{Name: "tunnel", Digest: "sha256:123", AlternateDigests: []string{"sha512:456"}, NodeData: *assembler.NewObjectMetadata(s)},
+		wantKey: "sha256:123",
+	}, {
+		name:    "name",
+		want:    assembler.PackageNode{Name: "tunnel", Purl: "pkg:guac/generic/tunnel", KeySource: PackageKeyNameVersion, NodeData: *assembler.NewObjectMetadata(s)},
+		wantKey: "pkg:guac/generic/tunnel",
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := CreateVEXProduct(tt.purl, "tunnel", tt.digests, "ref", s)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CreateVEXProduct() = %v, want %v", got, tt.want)
+			}
+			if key := VEXProductKey(got); key != tt.wantKey {
+				t.Errorf("VEXProductKey() = %q, want %q", key, tt.wantKey)
+			}
+			e := CreateAffectsEdge(assembler.VulnerabilityNode{ID: "CVE-2023-1234"}, got, "not_affected", "component_not_present")
+			if _, u := e.Nodes(); !reflect.DeepEqual(u, tt.want) {
+				t.Errorf("CreateAffectsEdge() affects %v, want %v", u, tt.want)
+			}
+		})
+	}
+}
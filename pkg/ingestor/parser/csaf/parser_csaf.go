@@ -0,0 +1,186 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package csaf
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/guacsec/guac/pkg/assembler"
+	"github.com/guacsec/guac/pkg/handler/processor"
+	"github.com/guacsec/guac/pkg/handler/processor/csaf"
+	"github.com/guacsec/guac/pkg/ingestor/parser/common"
+)
+
+// statuses maps the product status categories of a CSAF vulnerability to
+// the VEX statuses of OpenVEX and CycloneDX. Recommended products have no
+// status.
+var statuses = map[string]string{
+	"known_not_affected":  "not_affected",
+	"fixed":               "fixed",
+	"first_fixed":         "fixed",
+	"known_affected":      "affected",
+	"first_affected":      "affected",
+	"last_affected":       "affected",
+	"under_investigation": "under_investigation",
+}
+
+type csafParser struct {
+	doc      *processor.Document
+	products map[string]csaf.FullProductName
+	// vulns and nodes are keyed so that a vulnerability or product
+	// referenced several times is a single node
+	vulns   map[string]assembler.VulnerabilityNode
+	nodes   map[string]assembler.GuacNode
+	affects []assembler.AffectsEdge
+}
+
+// NewCSAFParser initializes the csafParser
+func NewCSAFParser() common.DocumentParser {
+	return &csafParser{
+		vulns:   map[string]assembler.VulnerabilityNode{},
+		nodes:   map[string]assembler.GuacNode{},
+		affects: []assembler.AffectsEdge{},
+	}
+}
+
+// Parse breaks out the document into the graph components
+func (c *csafParser) Parse(ctx context.Context, doc *processor.Document) error {
+	if doc.Type != processor.DocumentCsaf {
+		return fmt.Errorf("expected document type: %v, actual document type: %v", processor.DocumentCsaf, doc.Type)
+	}
+	if doc.Format != processor.FormatJSON {
+		return fmt.Errorf("unable to support parsing of CSAF document format: %v", doc.Format)
+	}
+	c.doc = doc
+	csafDoc, err := csaf.ParseCSAF(doc.Blob)
+	if err != nil {
+		return fmt.Errorf("failed to parse CSAF document: %w", err)
+	}
+	c.products = csafDoc.ProductTree.Products()
+	for _, v := range csafDoc.Vulnerabilities {
+		c.addVulnerability(v)
+	}
+	return nil
+}
+
+// addVulnerability creates the vulnerability node and an edge with the
+// status of the vulnerability to each product of its product status
+func (c *csafParser) addVulnerability(v csaf.Vulnerability) {
+	id := v.ID()
+	if id == "" {
+		return
+	}
+	vuln, ok := c.vulns[id]
+	if !ok {
+		vuln = assembler.VulnerabilityNode{ID: id, NodeData: *assembler.NewObjectMetadata(c.doc.SourceInformation)}
+		c.vulns[id] = vuln
+	}
+	// the justification of a product not affected is given by a flag
+	justifications := map[string]string{}
+	for _, flag := range v.Flags {
+		for _, productID := range flag.ProductIDs {
+			justifications[productID] = flag.Label
+		}
+	}
+	categories := make([]string, 0, len(v.ProductStatus))
+	for category := range v.ProductStatus {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+	for _, category := range categories {
+		status, ok := statuses[category]
+		if !ok {
+			continue
+		}
+		for _, productID := range v.ProductStatus[category] {
+			node := c.addProduct(productID)
+			if node == nil {
+				continue
+			}
+			c.affects = append(c.affects, common.CreateAffectsEdge(vuln, node, status, justifications[productID]))
+		}
+	}
+}
+
+// addProduct returns the node of the product with the given id, nil if it
+// cannot be identified
+func (c *csafParser) addProduct(productID string) assembler.GuacNode {
+	product, ok := c.products[productID]
+	if !ok {
+		product = csaf.FullProductName{ProductID: productID, Name: productID}
+	}
+	purl := ""
+	digests := []string{}
+	if helper := product.ProductIdentificationHelper; helper != nil {
+		purl = helper.Purl
+		for _, h := range helper.Hashes {
+			for _, fh := range h.FileHashes {
+				digests = append(digests, common.DigestAlgorithm(fh.Algorithm)+":"+fh.Value)
+			}
+		}
+	}
+	name := product.Name
+	if name == "" {
+		name = productID
+	}
+	node := common.CreateVEXProduct(purl, name, digests, productID, c.doc.SourceInformation)
+	key := common.VEXProductKey(node)
+	if key == "" {
+		return nil
+	}
+	if existing, ok := c.nodes[key]; ok {
+		return existing
+	}
+	c.nodes[key] = node
+	return node
+}
+
+// CreateNodes creates the GuacNode for the graph inputs
+func (c *csafParser) CreateNodes(ctx context.Context) []assembler.GuacNode {
+	nodes := []assembler.GuacNode{}
+	for _, id := range sortedKeys(c.vulns) {
+		nodes = append(nodes, c.vulns[id])
+	}
+	for _, key := range sortedKeys(c.nodes) {
+		nodes = append(nodes, c.nodes[key])
+	}
+	return nodes
+}
+
+// CreateEdges creates the GuacEdges that form the relationship for the graph inputs
+func (c *csafParser) CreateEdges(ctx context.Context, foundIdentities []assembler.IdentityNode) []assembler.GuacEdge {
+	edges := []assembler.GuacEdge{}
+	for _, e := range c.affects {
+		edges = append(edges, e)
+	}
+	return edges
+}
+
+// GetIdentities gets the identity node from the document if they exist
+func (c *csafParser) GetIdentities(ctx context.Context) []assembler.IdentityNode {
+	return nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,94 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package csaf
+
+import (
+	"context"
+	"testing"
+
+	"github.com/guacsec/guac/internal/testing/testdata"
+	"github.com/guacsec/guac/pkg/assembler"
+	"github.com/guacsec/guac/pkg/handler/processor"
+)
+
+func Test_csafParser(t *testing.T) {
+	ctx := context.Background()
+	source := processor.SourceInformation{Collector: "TestCollector", Source: "TestSource"}
+	vuln := func(id string) assembler.VulnerabilityNode {
+		return assembler.VulnerabilityNode{ID: id, NodeData: *assembler.NewObjectMetadata(source)}
+	}
+	product := assembler.PackageNode{Purl: "pkg:generic/example-product@4.2", NodeData: *assembler.NewObjectMetadata(source)}
+	tarball := assembler.ArtifactNode{
+		Name:     "Example Product 4.3",
+		Digest:   "sha256:9a3c1e9bd1c3d4e5f60718293a4b5c6d7e8f90a1b2c3d4e5f60718293a4b5c6d",
+		NodeData: *assembler.NewObjectMetadata(source),
+	}
+	library := assembler.PackageNode{
+		Name:      "Example Library 1.0",
+		Purl:      "pkg:guac/generic/Example%20Library%201.0",
+		KeySource: "name_version",
+		NodeData:  *assembler.NewObjectMetadata(source),
+	}
+
+	tests := []struct {
+		name      string
+		doc       *processor.Document
+		wantNodes []assembler.GuacNode
+		wantEdges []assembler.GuacEdge
+		wantErr   bool
+	}{{
+		name: "CSAF VEX document",
+		doc: &processor.Document{
+			Blob:              testdata.CsafVEXExample,
+			Format:            processor.FormatJSON,
+			Type:              processor.DocumentCsaf,
+			SourceInformation: source,
+		},
+		wantNodes: []assembler.GuacNode{vuln("CVE-2023-0001"), vuln("EX-2023-17"), product, tarball, library},
+		wantEdges: []assembler.GuacEdge{
+			assembler.AffectsEdge{VulnerabilityNode: vuln("CVE-2023-0001"), PackageNode: library, AnalysisState: "affected"},
+			assembler.AffectsEdge{VulnerabilityNode: vuln("CVE-2023-0001"), PackageNode: product, AnalysisState: "not_affected", Justification: "vulnerable_code_not_present"},
+			assembler.AffectsEdge{VulnerabilityNode: vuln("CVE-2023-0001"), ArtifactNode: tarball, AnalysisState: "not_affected"},
+			assembler.AffectsEdge{VulnerabilityNode: vuln("EX-2023-17"), PackageNode: library, AnalysisState: "fixed"},
+		},
+	}, {
+		name: "unsupported format",
+		doc: &processor.Document{
+			Blob:   testdata.CsafVEXExample,
+			Format: processor.FormatXML,
+			Type:   processor.DocumentCsaf,
+		},
+		wantErr: true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewCSAFParser()
+			err := p.Parse(ctx, tt.doc)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("csafParser.Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if nodes := p.CreateNodes(ctx); !testdata.GuacNodeSliceEqual(nodes, tt.wantNodes) {
+				t.Errorf("csafParser.CreateNodes() = %v, want %v", nodes, tt.wantNodes)
+			}
+			if edges := p.CreateEdges(ctx, nil); !testdata.GuacEdgeSliceEqual(edges, tt.wantEdges) {
+				t.Errorf("csafParser.CreateEdges() = %v, want %v", edges, tt.wantEdges)
+			}
+		})
+	}
+}
@@ -16,6 +16,7 @@
 package cyclonedx
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -30,7 +31,32 @@ import (
 type cyclonedxParser struct {
 	doc           *processor.Document
 	rootComponent component
-	pkgMap        map[string]*component
+	rootRef       string
+	// pkgMap indexes the components by bom-ref and purlMap by purl,
+	// including the nested components
+	pkgMap       map[string]*component
+	purlMap      map[string]*component
+	vulns        []vulnerability
+	licenses     []assembler.LicenseNode
+	licenseEdges []assembler.HasLicenseEdge
+	references   []assembler.GuacNode
+	refEdges     []assembler.GuacEdge
+	document     assembler.MetadataNode
+}
+
+// xmlNamespacePrefix prefixes the spec version in the namespace of an XML BOM
+const xmlNamespacePrefix = "http://cyclonedx.org/schema/bom/"
+
+// bomHeader holds the fields identifying a BOM, read separately as the spec
+// version is not a plain string in the CycloneDX library
+type bomHeader struct {
+	SpecVersion  string `json:"specVersion"`
+	SerialNumber string `json:"serialNumber"`
+}
+
+type vulnerability struct {
+	vulnNode assembler.VulnerabilityNode
+	affects  []assembler.AffectsEdge
 }
 
 type component struct {
@@ -42,29 +68,49 @@ func NewCycloneDXParser() common.DocumentParser {
 	return &cyclonedxParser{
 		rootComponent: component{},
 		pkgMap:        map[string]*component{},
+		purlMap:       map[string]*component{},
+		vulns:         []vulnerability{},
+		licenses:      []assembler.LicenseNode{},
+		licenseEdges:  []assembler.HasLicenseEdge{},
+		references:    []assembler.GuacNode{},
+		refEdges:      []assembler.GuacEdge{},
 	}
 }
 
 func (c *cyclonedxParser) CreateNodes(ctx context.Context) []assembler.GuacNode {
 	nodes := []assembler.GuacNode{}
+	nodes = append(nodes, c.document)
 	nodes = append(nodes, c.rootComponent.curPackage)
 	for _, p := range c.rootComponent.depPackages {
 		nodes = append(nodes, p.curPackage)
 	}
+	for _, v := range c.vulns {
+		nodes = append(nodes, v.vulnNode)
+	}
+	for _, l := range c.licenses {
+		nodes = append(nodes, l)
+	}
+	nodes = append(nodes, c.references...)
 	return nodes
 }
 
 func addEdges(curPkg component, edges *[]assembler.GuacEdge, visited map[string]bool) {
 	// this could happen if we image purl creation fails for rootPackage
 	// we need better solution to support different image name formats in SBOM
-	if curPkg.curPackage.Name == "" {
+	// packages are visited by purl, which is unique even for components
+	// without one thanks to the fallback key, or by name if there is none
+	key := curPkg.curPackage.Purl
+	if key == "" {
+		key = curPkg.curPackage.Name
+	}
+	if key == "" {
 		return
 	}
 	// Exit the function if the package has already been visited
-	if visited[curPkg.curPackage.Name] {
+	if visited[key] {
 		return
 	}
-	visited[curPkg.curPackage.Name] = true
+	visited[key] = true
 
 	for _, dep := range curPkg.depPackages {
 		// Append the dependency edge to the edges slice
@@ -78,12 +124,14 @@ func addEdges(curPkg component, edges *[]assembler.GuacEdge, visited map[string]
 // Parse breaks out the document into the graph components
 func (c *cyclonedxParser) Parse(ctx context.Context, doc *processor.Document) error {
 	c.doc = doc
-	cdxBom, err := parseCycloneDXBOM(doc.Blob)
+	cdxBom, header, err := parseCycloneDXBOM(doc)
 	if err != nil {
 		return fmt.Errorf("failed to parse cyclonedx BOM: %w", err)
 	}
+	c.document = common.CreateDocumentNode(doc, header.SerialNumber, common.DocumentFormatCycloneDX, header.SpecVersion)
 	c.addRootPackage(cdxBom)
 	c.addPackages(cdxBom)
+	c.addVulnerabilities(cdxBom)
 
 	return nil
 }
@@ -96,13 +144,23 @@ func (c *cyclonedxParser) GetIdentities(ctx context.Context) []assembler.Identit
 func (c *cyclonedxParser) CreateEdges(ctx context.Context, foundIdentities []assembler.IdentityNode) []assembler.GuacEdge {
 	edges := []assembler.GuacEdge{}
 	visited := make(map[string]bool)
+	edges = append(edges, common.CreateDocumentEdge(c.document, c.rootComponent.curPackage)...)
 	addEdges(c.rootComponent, &edges, visited)
+	for _, v := range c.vulns {
+		for _, a := range v.affects {
+			edges = append(edges, a)
+		}
+	}
+	for _, l := range c.licenseEdges {
+		edges = append(edges, l)
+	}
+	edges = append(edges, c.refEdges...)
 	return edges
 }
 
 func (c *cyclonedxParser) addRootPackage(cdxBom *cdx.BOM) {
 	// oci purl: pkg:oci/debian@sha256%3A244fd47e07d10?repository_url=ghcr.io/debian&tag=bullseye
-	if cdxBom.Metadata.Component != nil {
+	if cdxBom.Metadata != nil && cdxBom.Metadata.Component != nil {
 		rootPackage := assembler.PackageNode{}
 		rootPackage.Name = cdxBom.Metadata.Component.Name
 		rootPackage.NodeData = *assembler.NewObjectMetadata(c.doc.SourceInformation)
@@ -117,24 +175,60 @@ func (c *cyclonedxParser) addRootPackage(cdxBom *cdx.BOM) {
 				rootPackage.Version = cdxBom.Metadata.Component.Version
 				rootPackage.Digest = append(rootPackage.Digest, cdxBom.Metadata.Component.Version)
 				rootPackage.Tags = []string{"CONTAINER"}
+			} else {
+				rootPackage.Version = cdxBom.Metadata.Component.Version
+				common.SetFallbackPackageKey(&rootPackage, cdxBom.Metadata.Component.Group, c.document.ID, cdxBom.Metadata.Component.BOMRef)
 			}
 		}
+		rootPackage.Digest = append(rootPackage.Digest, getDigests(cdxBom.Metadata.Component.Hashes)...)
 		c.rootComponent = component{
 			curPackage:  rootPackage,
 			depPackages: []*component{},
 		}
+		c.rootRef = cdxBom.Metadata.Component.BOMRef
+		if rootPackage.Purl != "" {
+			c.purlMap[rootPackage.Purl] = &c.rootComponent
+		}
+		c.addLicenses(rootPackage, cdxBom.Metadata.Component.Licenses)
+		c.addReferences(rootPackage, cdxBom.Metadata.Component.ExternalReferences)
 	}
 }
 
 func (c *cyclonedxParser) addPackages(cdxBom *cdx.BOM) {
-	for _, comp := range *cdxBom.Components {
+	c.addComponents(cdxBom.Components)
+
+	if cdxBom.Dependencies == nil {
+		return
+	}
+	for _, deps := range *cdxBom.Dependencies {
+		currPkg, found := c.pkgMap[deps.Ref]
+		if !found {
+			continue
+		}
+		if deps.Dependencies != nil {
+			for _, depPkg := range *deps.Dependencies {
+				if depPkg, exist := c.pkgMap[depPkg]; exist {
+					currPkg.depPackages = append(currPkg.depPackages, depPkg)
+				}
+			}
+		}
+	}
+}
+
+// addComponents creates the packages of the components, including the
+// components nested in them, e.g. the parts of an assembly
+func (c *cyclonedxParser) addComponents(comps *[]cdx.Component) {
+	if comps == nil {
+		return
+	}
+	for _, comp := range *comps {
 		// skipping over the "operating-system" type as it does not contain
 		// the required purl for package node. Currently there is no use-case
 		// to capture OS for GUAC.
 		if comp.Type != cdx.ComponentTypeOS {
 			curPkg := assembler.PackageNode{
-				Name: comp.Name,
-				// Digest: []string{comp.Version},
+				Name:     comp.Name,
+				Digest:   getDigests(comp.Hashes),
 				Purl:     comp.PackageURL,
 				Version:  comp.Version,
 				NodeData: *assembler.NewObjectMetadata(c.doc.SourceInformation),
@@ -142,37 +236,191 @@ func (c *cyclonedxParser) addPackages(cdxBom *cdx.BOM) {
 			if comp.CPE != "" {
 				curPkg.CPEs = []string{comp.CPE}
 			}
+			common.SetFallbackPackageKey(&curPkg, comp.Group, c.document.ID, comp.BOMRef)
 			parentPkg := component{
 				curPackage:  curPkg,
 				depPackages: []*component{},
 			}
 			c.rootComponent.depPackages = append(c.rootComponent.depPackages, &parentPkg)
 			c.pkgMap[comp.BOMRef] = &parentPkg
+			// the root component or the first component wins when several
+			// share a purl
+			if _, found := c.purlMap[curPkg.Purl]; !found && curPkg.Purl != "" {
+				c.purlMap[curPkg.Purl] = &parentPkg
+			}
+			c.addLicenses(curPkg, comp.Licenses)
+			c.addReferences(curPkg, comp.ExternalReferences)
 		}
+		c.addComponents(comp.Components)
 	}
+}
 
-	if cdxBom.Dependencies == nil {
+// getDigests returns the hashes of a component as `algorithm:value` digests,
+// with the algorithm named as in SPDX documents, e.g. sha256 for SHA-256, so
+// that both formats give the same digests
+func getDigests(hashes *[]cdx.Hash) []string {
+	if hashes == nil {
+		return nil
+	}
+	var digests []string
+	for _, h := range *hashes {
+		if h.Value == "" {
+			continue
+		}
+		digests = append(digests, common.DigestAlgorithm(string(h.Algorithm))+":"+h.Value)
+	}
+	return digests
+}
+
+// addLicenses creates the license nodes of a component. Each entry of the
+// licenses array is either an SPDX expression or a single license given by
+// SPDX id or free-form name, which is kept as a raw node if it is not a valid
+// expression.
+func (c *cyclonedxParser) addLicenses(pkg assembler.PackageNode, licenses *cdx.Licenses) {
+	if licenses == nil {
 		return
 	}
-	for _, deps := range *cdxBom.Dependencies {
-		currPkg, found := c.pkgMap[deps.Ref]
-		if !found {
+	for _, choice := range *licenses {
+		expr := choice.Expression
+		if expr == "" && choice.License != nil {
+			expr = choice.License.ID
+			if expr == "" {
+				expr = choice.License.Name
+			}
+		}
+		nodes, edges := common.CreateLicenseGraph(pkg, expr, c.doc.SourceInformation)
+		c.licenses = append(c.licenses, nodes...)
+		c.licenseEdges = append(c.licenseEdges, edges...)
+	}
+}
+
+// addReferences creates the external reference nodes of a component, such as
+// its VCS repository, website or advisories
+func (c *cyclonedxParser) addReferences(pkg assembler.PackageNode, refs *[]cdx.ExternalReference) {
+	if refs == nil {
+		return
+	}
+	for _, ref := range *refs {
+		nodes, edges := common.CreateReferenceGraph(pkg, string(ref.Type), ref.URL, c.doc.SourceInformation)
+		c.references = append(c.references, nodes...)
+		c.refEdges = append(c.refEdges, edges...)
+	}
+}
+
+// addVulnerabilities creates a vulnerability node for each entry of the VEX
+// "vulnerabilities" array, along with an edge to each affected component.
+// Affected components are referenced by their bom-ref, but some producers
+// use the purl instead, so both are looked up.
+func (c *cyclonedxParser) addVulnerabilities(cdxBom *cdx.BOM) {
+	if cdxBom.Vulnerabilities == nil {
+		return
+	}
+	for _, vuln := range *cdxBom.Vulnerabilities {
+		id := getVulnerabilityID(vuln)
+		if id == "" {
 			continue
 		}
-		if deps.Dependencies != nil {
-			for _, depPkg := range *deps.Dependencies {
-				if depPkg, exist := c.pkgMap[depPkg]; exist {
-					currPkg.depPackages = append(currPkg.depPackages, depPkg)
+		v := vulnerability{
+			vulnNode: assembler.VulnerabilityNode{
+				ID:       id,
+				NodeData: *assembler.NewObjectMetadata(c.doc.SourceInformation),
+			},
+			affects: []assembler.AffectsEdge{},
+		}
+		if vuln.Affects != nil {
+			for _, affect := range *vuln.Affects {
+				pkg, found := c.getComponentByRef(affect.Ref)
+				if !found {
+					continue
+				}
+				e := assembler.AffectsEdge{
+					VulnerabilityNode: v.vulnNode,
+					PackageNode:       pkg.curPackage,
+				}
+				if vuln.Analysis != nil {
+					e.AnalysisState = string(vuln.Analysis.State)
+					e.Justification = string(vuln.Analysis.Justification)
+				}
+				if rating := getCVSSRating(vuln); rating != nil {
+					e.Score = *rating.Score
+					e.ScoreMethod = string(rating.Method)
 				}
+				v.affects = append(v.affects, e)
 			}
 		}
+		c.vulns = append(c.vulns, v)
 	}
 }
 
-func parseCycloneDXBOM(d []byte) (*cdx.BOM, error) {
+// getVulnerabilityID returns the id of the vulnerability, falling back to
+// the first alias found in its references if the id is not set.
+func getVulnerabilityID(vuln cdx.Vulnerability) string {
+	if vuln.ID != "" {
+		return vuln.ID
+	}
+	if vuln.References != nil {
+		for _, ref := range *vuln.References {
+			if ref.ID != "" {
+				return ref.ID
+			}
+		}
+	}
+	return ""
+}
+
+// getCVSSRating returns the rating with the most recent CVSS scoring method
+// that has a score, or nil if there is none.
+func getCVSSRating(vuln cdx.Vulnerability) *cdx.VulnerabilityRating {
+	if vuln.Ratings == nil {
+		return nil
+	}
+	methods := []cdx.ScoringMethod{cdx.ScoringMethodCVSSv31, cdx.ScoringMethodCVSSv3, cdx.ScoringMethodCVSSv2}
+	for _, method := range methods {
+		for i, rating := range *vuln.Ratings {
+			if rating.Method == method && rating.Score != nil {
+				return &(*vuln.Ratings)[i]
+			}
+		}
+	}
+	return nil
+}
+
+// getComponentByRef finds the component referenced by either its bom-ref or purl
+func (c *cyclonedxParser) getComponentByRef(ref string) (*component, bool) {
+	if pkg, found := c.pkgMap[ref]; found {
+		return pkg, true
+	}
+	if ref == "" {
+		return nil, false
+	}
+	if ref == c.rootRef {
+		return &c.rootComponent, true
+	}
+	pkg, found := c.purlMap[ref]
+	return pkg, found
+}
+
+// parseCycloneDXBOM decodes the JSON or XML BOM of the document, along with
+// its header
+func parseCycloneDXBOM(doc *processor.Document) (*cdx.BOM, bomHeader, error) {
 	bom := cdx.BOM{}
-	if err := json.Unmarshal(d, &bom); err != nil {
-		return nil, err
+	var header bomHeader
+	if doc.Format == processor.FormatXML {
+		if err := cdx.NewBOMDecoder(bytes.NewReader(doc.Blob), cdx.BOMFileFormatXML).Decode(&bom); err != nil {
+			return nil, header, err
+		}
+		if !strings.HasPrefix(bom.XMLNS, xmlNamespacePrefix) {
+			return nil, header, fmt.Errorf("unexpected namespace %q", bom.XMLNS)
+		}
+		header.SpecVersion = strings.TrimPrefix(bom.XMLNS, xmlNamespacePrefix)
+		header.SerialNumber = bom.SerialNumber
+		return &bom, header, nil
+	}
+	if err := json.Unmarshal(doc.Blob, &bom); err != nil {
+		return nil, header, err
+	}
+	if err := json.Unmarshal(doc.Blob, &header); err != nil {
+		return nil, header, err
 	}
-	return &bom, nil
+	return &bom, header, nil
 }
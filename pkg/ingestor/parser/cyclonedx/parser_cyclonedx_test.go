@@ -17,6 +17,7 @@ package cyclonedx
 
 import (
 	"context"
+	"reflect"
 	"testing"
 
 	"github.com/guacsec/guac/internal/testing/testdata"
@@ -61,6 +62,20 @@ func Test_cyclonedxParser(t *testing.T) {
 		wantNodes: testdata.CycloneDXQuarkusNodes,
 		wantEdges: testdata.CyloneDXQuarkusEdges,
 		wantErr:   false,
+	}, {
+		name: "valid small CycloneDX XML document with package dependencies",
+		doc: &processor.Document{
+			Blob:   testdata.CycloneDXExampleSmallDepsXML,
+			Format: processor.FormatXML,
+			Type:   processor.DocumentCycloneDX,
+			SourceInformation: processor.SourceInformation{
+				Collector: "TestCollector",
+				Source:    "TestSource",
+			},
+		},
+		wantNodes: testdata.CycloneDXQuarkusNodes,
+		wantEdges: testdata.CyloneDXQuarkusEdges,
+		wantErr:   false,
 	}, {
 		name: "valid CycloneDX document where dependencies are missing dependsOn properties",
 		doc: &processor.Document{
@@ -75,6 +90,20 @@ func Test_cyclonedxParser(t *testing.T) {
 		wantNodes: testdata.NpmMissingDependsOnCycloneDXNodes,
 		wantEdges: testdata.NpmMissingDependsOnCycloneDXEdges,
 		wantErr:   false,
+	}, {
+		name: "valid CycloneDX document with VEX vulnerabilities",
+		doc: &processor.Document{
+			Blob:   testdata.CycloneDXVEXExample,
+			Format: processor.FormatJSON,
+			Type:   processor.DocumentCycloneDX,
+			SourceInformation: processor.SourceInformation{
+				Collector: "TestCollector",
+				Source:    "TestSource",
+			},
+		},
+		wantNodes: testdata.CycloneDXVEXNodes,
+		wantEdges: testdata.CycloneDXVEXEdges,
+		wantErr:   false,
 	},
 	}
 	for _, tt := range tests {
@@ -136,3 +165,153 @@ func Test_addEdgesRecursive(t *testing.T) {
 	visited = make(map[string]bool)
 	addEdges(packageA, &e, visited)
 }
+
+func Test_cyclonedxParser_missingPurls(t *testing.T) {
+	ctx := logging.WithLogger(context.Background())
+	doc := &processor.Document{
+		Blob: []byte(`{
+			"bomFormat": "CycloneDX",
+			"specVersion": "1.4",
+			"serialNumber": "urn:uuid:3e671687-395b-41f5-a30f-a58921a69b79",
+			"version": 1,
+			"metadata": {"component": {"bom-ref": "app", "type": "application", "name": "app", "purl": "pkg:generic/app@1.0.0"}},
+			"components": [
+				{"bom-ref": "lib-1", "type": "library", "group": "acme", "name": "lib", "version": "1.0.0"},
+				{"bom-ref": "lib-2", "type": "library", "group": "acme", "name": "lib", "version": "2.0.0"},
+				{"bom-ref": "unnamed", "type": "library", "name": ""}
+			],
+			"dependencies": [{"ref": "lib-1", "dependsOn": ["unnamed"]}]
+		}`),
+		Format: processor.FormatJSON,
+		Type:   processor.DocumentCycloneDX,
+	}
+	s := NewCycloneDXParser()
+	if err := s.Parse(ctx, doc); err != nil {
+		t.Fatalf("cyclonedxParser.Parse() error = %v", err)
+	}
+
+	want := map[string]string{
+		"pkg:generic/app@1.0.0":           "",
+		"pkg:guac/generic/acme/lib@1.0.0": "name_version",
+		"pkg:guac/generic/acme/lib@2.0.0": "name_version",
+		"pkg:guac/ref/unnamed?document=urn%3Auuid%3A3e671687-395b-41f5-a30f-a58921a69b79": "ref",
+	}
+	got := map[string]string{}
+	for _, n := range s.CreateNodes(ctx) {
+		if pkg, ok := n.(assembler.PackageNode); ok {
+			if _, dup := got[pkg.Purl]; dup {
+				t.Errorf("duplicate package node %q", pkg.Purl)
+			}
+			got[pkg.Purl] = pkg.KeySource
+		}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("package nodes = %v, want %v", got, want)
+	}
+
+	var dependsOn int
+	for _, e := range s.CreateEdges(ctx, nil) {
+		if d, ok := e.(assembler.DependsOnEdge); ok && d.PackageNode.Purl == "pkg:guac/generic/acme/lib@1.0.0" {
+			dependsOn++
+		}
+	}
+	if dependsOn != 1 {
+		t.Errorf("got %d dependencies of lib@1.0.0, want 1", dependsOn)
+	}
+}
+
+func Test_cyclonedxParser_nestedComponentsAndHashes(t *testing.T) {
+	ctx := logging.WithLogger(context.Background())
+	doc := &processor.Document{
+		Blob: []byte(`{
+			"bomFormat": "CycloneDX",
+			"specVersion": "1.3",
+			"serialNumber": "urn:uuid:8f0e4a53-40bb-4d1d-a1b4-7f1c94c57c8e",
+			"version": 1,
+			"metadata": {"component": {"bom-ref": "app", "type": "application", "name": "app", "purl": "pkg:generic/app@1.0.0",
+				"hashes": [{"alg": "SHA-256", "content": "ab12"}]}},
+			"components": [
+				{"bom-ref": "framework", "type": "framework", "name": "framework", "purl": "pkg:maven/acme/framework@2.0.0",
+					"hashes": [{"alg": "SHA-1", "content": "cd34"}, {"alg": "SHA3-256", "content": "ef56"}],
+					"components": [
+						{"bom-ref": "core", "type": "library", "name": "core", "purl": "pkg:maven/acme/core@2.0.0"}
+					]}
+			],
+			"dependencies": [{"ref": "core", "dependsOn": ["framework"]}]
+		}`),
+		Format: processor.FormatJSON,
+		Type:   processor.DocumentCycloneDX,
+	}
+	s := NewCycloneDXParser()
+	if err := s.Parse(ctx, doc); err != nil {
+		t.Fatalf("cyclonedxParser.Parse() error = %v", err)
+	}
+
+	want := map[string][]string{
+		"pkg:generic/app@1.0.0":          {"sha256:ab12"},
+		"pkg:maven/acme/framework@2.0.0": {"sha1:cd34", "sha3-256:ef56"},
+		"pkg:maven/acme/core@2.0.0":      nil,
+	}
+	got := map[string][]string{}
+	for _, n := range s.CreateNodes(ctx) {
+		if pkg, ok := n.(assembler.PackageNode); ok {
+			got[pkg.Purl] = pkg.Digest
+		}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("package digests = %v, want %v", got, want)
+	}
+
+	var dependsOn int
+	for _, e := range s.CreateEdges(ctx, nil) {
+		if d, ok := e.(assembler.DependsOnEdge); ok && d.PackageNode.Purl == "pkg:maven/acme/core@2.0.0" && d.PackageDependency.Purl == "pkg:maven/acme/framework@2.0.0" {
+			dependsOn++
+		}
+	}
+	if dependsOn != 1 {
+		t.Errorf("got %d dependencies of core on framework, want 1", dependsOn)
+	}
+}
+
+func Test_cyclonedxParser_affectsRefs(t *testing.T) {
+	ctx := logging.WithLogger(context.Background())
+	doc := &processor.Document{
+		Blob: []byte(`{
+			"bomFormat": "CycloneDX",
+			"specVersion": "1.4",
+			"version": 1,
+			"metadata": {"component": {"bom-ref": "app", "type": "application", "name": "app", "purl": "pkg:generic/app@1.0.0"}},
+			"components": [
+				{"bom-ref": "framework", "type": "framework", "name": "framework", "purl": "pkg:maven/acme/framework@2.0.0",
+					"components": [
+						{"bom-ref": "core", "type": "library", "name": "core", "purl": "pkg:maven/acme/core@2.0.0"}
+					]}
+			],
+			"vulnerabilities": [
+				{"id": "CVE-2023-0001", "affects": [{"ref": "core"}, {"ref": "app"}]},
+				{"id": "CVE-2023-0002", "affects": [{"ref": "pkg:maven/acme/core@2.0.0"}, {"ref": "pkg:generic/app@1.0.0"}, {"ref": "unknown"}]}
+			]
+		}`),
+		Format: processor.FormatJSON,
+		Type:   processor.DocumentCycloneDX,
+	}
+	s := NewCycloneDXParser()
+	if err := s.Parse(ctx, doc); err != nil {
+		t.Fatalf("cyclonedxParser.Parse() error = %v", err)
+	}
+
+	// nested components and the root are found by bom-ref or purl
+	want := map[string][]string{
+		"CVE-2023-0001": {"pkg:maven/acme/core@2.0.0", "pkg:generic/app@1.0.0"},
+		"CVE-2023-0002": {"pkg:maven/acme/core@2.0.0", "pkg:generic/app@1.0.0"},
+	}
+	got := map[string][]string{}
+	for _, e := range s.CreateEdges(ctx, nil) {
+		if a, ok := e.(assembler.AffectsEdge); ok {
+			got[a.VulnerabilityNode.ID] = append(got[a.VulnerabilityNode.ID], a.PackageNode.Purl)
+		}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("affected packages = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,191 @@
+//
+// Copyright 2022 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package depsnapshot
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/guacsec/guac/pkg/assembler"
+	"github.com/guacsec/guac/pkg/handler/processor"
+	"github.com/guacsec/guac/pkg/handler/processor/depsnapshot"
+	"github.com/guacsec/guac/pkg/ingestor/parser/common"
+)
+
+// manifestTag tags the package nodes standing for the manifests of a
+// snapshot, which depend on the dependencies the manifests declare
+const manifestTag = "MANIFEST"
+
+type depSnapshotParser struct {
+	doc *processor.Document
+	// packages are the package nodes keyed by purl, shared by the manifests
+	packages map[string]assembler.PackageNode
+	edges    []assembler.GuacEdge
+}
+
+// NewDepSnapshotParser initializes the depSnapshotParser
+func NewDepSnapshotParser() common.DocumentParser {
+	return &depSnapshotParser{
+		packages: map[string]assembler.PackageNode{},
+		edges:    []assembler.GuacEdge{},
+	}
+}
+
+// Parse breaks out the document into the graph components
+func (p *depSnapshotParser) Parse(ctx context.Context, doc *processor.Document) error {
+	if doc.Type != processor.DocumentDependencySnapshot {
+		return fmt.Errorf("expected document type: %v, actual document type: %v", processor.DocumentDependencySnapshot, doc.Type)
+	}
+	if doc.Format != processor.FormatJSON {
+		return fmt.Errorf("unable to support parsing of dependency snapshot format: %v", doc.Format)
+	}
+	p.doc = doc
+	snapshot, err := depsnapshot.ParseSnapshot(doc.Blob)
+	if err != nil {
+		return fmt.Errorf("failed to parse dependency snapshot: %w", err)
+	}
+
+	// manifests are parsed in a stable order so that the nodes and edges
+	// do not depend on map iteration
+	names := make([]string, 0, len(snapshot.Manifests))
+	for name := range snapshot.Manifests {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		p.addManifest(snapshot, snapshot.Manifests[name])
+	}
+	return nil
+}
+
+// addManifest adds the package of the manifest, depending on the direct
+// dependencies, and the dependency graph resolved from it
+func (p *depSnapshotParser) addManifest(snapshot *depsnapshot.Snapshot, manifest depsnapshot.Manifest) {
+	path := manifest.Path()
+	root := p.manifestPackage(snapshot, path)
+
+	keys := make([]string, 0, len(manifest.Resolved))
+	for key := range manifest.Resolved {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	resolved := map[string]assembler.PackageNode{}
+	for _, key := range keys {
+		resolved[key] = p.addPackage(key, manifest.Resolved[key].PackageURL)
+	}
+	// dependencies are given by key in the resolved dependencies, or by purl
+	byPurl := map[string]string{}
+	for key, dep := range manifest.Resolved {
+		if dep.PackageURL != "" {
+			byPurl[dep.PackageURL] = key
+		}
+	}
+
+	for _, key := range keys {
+		dep := manifest.Resolved[key]
+		// a dependency without a relationship is assumed to be declared by
+		// the manifest, so that it is not left out of the graph
+		if dep.Relationship != depsnapshot.RelationshipIndirect {
+			p.edges = append(p.edges, assembler.DependsOnEdge{
+				PackageNode:       root,
+				PackageDependency: resolved[key],
+				Manifest:          path,
+				Relationship:      dep.Relationship,
+			})
+		}
+		for _, ref := range dep.Dependencies {
+			depKey := ref
+			if _, ok := manifest.Resolved[ref]; !ok {
+				depKey = byPurl[ref]
+			}
+			var pkg assembler.PackageNode
+			relationship := ""
+			if depKey != "" {
+				pkg = resolved[depKey]
+				relationship = manifest.Resolved[depKey].Relationship
+			} else if strings.HasPrefix(ref, "pkg:") {
+				// a dependency missing from the resolved dependencies is
+				// still known by its purl
+				pkg = p.addPackage(ref, ref)
+			} else {
+				continue
+			}
+			p.edges = append(p.edges, assembler.DependsOnEdge{
+				PackageNode:       resolved[key],
+				PackageDependency: pkg,
+				Manifest:          path,
+				Relationship:      relationship,
+			})
+		}
+	}
+}
+
+// manifestPackage returns the package node standing for the manifest at
+// path, keyed on the path and the commit of the snapshot
+func (p *depSnapshotParser) manifestPackage(snapshot *depsnapshot.Snapshot, path string) assembler.PackageNode {
+	root := assembler.PackageNode{
+		Name:     path,
+		Version:  snapshot.Sha,
+		Tags:     []string{manifestTag},
+		NodeData: *assembler.NewObjectMetadata(p.doc.SourceInformation),
+	}
+	common.SetFallbackPackageKey(&root, "", p.doc.SourceInformation.Source, path)
+	p.packages[root.Purl] = root
+	return root
+}
+
+// addPackage returns the package node of the resolved dependency named key,
+// keyed on its purl or on its name if it has none
+func (p *depSnapshotParser) addPackage(key string, purl string) assembler.PackageNode {
+	pkg := assembler.PackageNode{
+		Name:     key,
+		Purl:     purl,
+		NodeData: *assembler.NewObjectMetadata(p.doc.SourceInformation),
+	}
+	common.SetFallbackPackageKey(&pkg, "", p.doc.SourceInformation.Source, key)
+	if existing, ok := p.packages[pkg.Purl]; ok {
+		return existing
+	}
+	p.packages[pkg.Purl] = pkg
+	return pkg
+}
+
+// CreateNodes creates the GuacNode for the graph inputs
+func (p *depSnapshotParser) CreateNodes(ctx context.Context) []assembler.GuacNode {
+	purls := make([]string, 0, len(p.packages))
+	for purl := range p.packages {
+		purls = append(purls, purl)
+	}
+	sort.Strings(purls)
+	nodes := []assembler.GuacNode{}
+	for _, purl := range purls {
+		nodes = append(nodes, p.packages[purl])
+	}
+	return nodes
+}
+
+// CreateEdges creates the GuacEdges that form the relationship for the graph inputs
+func (p *depSnapshotParser) CreateEdges(ctx context.Context, foundIdentities []assembler.IdentityNode) []assembler.GuacEdge {
+	return p.edges
+}
+
+// GetIdentities gets the identity node from the document if they exist
+func (p *depSnapshotParser) GetIdentities(ctx context.Context) []assembler.IdentityNode {
+	return nil
+}
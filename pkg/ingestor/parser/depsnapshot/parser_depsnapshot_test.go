@@ -0,0 +1,124 @@
+//
+// Copyright 2022 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package depsnapshot
+
+import (
+	"context"
+	"testing"
+
+	"github.com/guacsec/guac/internal/testing/testdata"
+	"github.com/guacsec/guac/pkg/assembler"
+	"github.com/guacsec/guac/pkg/handler/processor"
+)
+
+const sha = "ce587453ced02b1526dfb4cb910479d431683101"
+
+func Test_depSnapshotParser(t *testing.T) {
+	ctx := context.Background()
+	source := processor.SourceInformation{Collector: "TestCollector", Source: "TestSource"}
+	pkg := func(name string, purl string) assembler.PackageNode {
+		return assembler.PackageNode{Name: name, Purl: purl, NodeData: *assembler.NewObjectMetadata(source)}
+	}
+	manifest := func(path string, purl string) assembler.PackageNode {
+		return assembler.PackageNode{Name: path, Version: sha, Purl: purl, Tags: []string{"MANIFEST"}, KeySource: "name_version",
+			NodeData: *assembler.NewObjectMetadata(source)}
+	}
+	npmManifest := manifest("src/package-lock.json", "pkg:guac/generic/src%2Fpackage-lock.json@"+sha)
+	goManifest := manifest("go.mod", "pkg:guac/generic/go.mod@"+sha)
+	core := pkg("@actions/core", "pkg:npm/%40actions/core@1.1.9")
+	httpClient := pkg("@actions/http-client", "pkg:npm/%40actions/http-client@1.0.7")
+	tunnel := pkg("tunnel", "pkg:npm/tunnel@0.0.6")
+	uuid := pkg("github.com/google/uuid", "pkg:golang/github.com/google/uuid@v1.3.0")
+
+	tests := []struct {
+		name      string
+		doc       *processor.Document
+		wantNodes []assembler.GuacNode
+		wantEdges []assembler.GuacEdge
+		wantErr   bool
+	}{{
+		name: "snapshot with two manifests",
+		doc: &processor.Document{
+			Blob:              testdata.DependencySnapshotExample,
+			Format:            processor.FormatJSON,
+			Type:              processor.DocumentDependencySnapshot,
+			SourceInformation: source,
+		},
+		wantNodes: []assembler.GuacNode{npmManifest, goManifest, core, httpClient, tunnel, uuid},
+		wantEdges: []assembler.GuacEdge{
+			assembler.DependsOnEdge{PackageNode: npmManifest, PackageDependency: core, Manifest: "src/package-lock.json", Relationship: "direct"},
+			assembler.DependsOnEdge{PackageNode: core, PackageDependency: httpClient, Manifest: "src/package-lock.json", Relationship: "indirect"},
+			assembler.DependsOnEdge{PackageNode: httpClient, PackageDependency: tunnel, Manifest: "src/package-lock.json", Relationship: "indirect"},
+			assembler.DependsOnEdge{PackageNode: goManifest, PackageDependency: uuid, Manifest: "go.mod", Relationship: "direct"},
+		},
+	}, {
+		name: "dependencies given by purl and shared across manifests",
+		doc: &processor.Document{
+			Blob: []byte(`{
+				"version": 0,
+				"sha": "` + sha + `",
+				"detector": {"name": "octo-detector"},
+				"manifests": {
+					"a": {"name": "a", "resolved": {
+						"core": {"package_url": "pkg:npm/%40actions/core@1.1.9", "dependencies": ["pkg:npm/tunnel@0.0.6"]}
+					}},
+					"b": {"name": "b", "resolved": {
+						"core": {"package_url": "pkg:npm/%40actions/core@1.1.9", "relationship": "indirect"}
+					}}
+				}
+			}`),
+			Format:            processor.FormatJSON,
+			Type:              processor.DocumentDependencySnapshot,
+			SourceInformation: source,
+		},
+		wantNodes: []assembler.GuacNode{
+			manifest("a", "pkg:guac/generic/a@"+sha),
+			manifest("b", "pkg:guac/generic/b@"+sha),
+			pkg("core", "pkg:npm/%40actions/core@1.1.9"),
+			pkg("pkg:npm/tunnel@0.0.6", "pkg:npm/tunnel@0.0.6"),
+		},
+		wantEdges: []assembler.GuacEdge{
+			assembler.DependsOnEdge{PackageNode: manifest("a", "pkg:guac/generic/a@"+sha), PackageDependency: pkg("core", "pkg:npm/%40actions/core@1.1.9"), Manifest: "a"},
+			assembler.DependsOnEdge{PackageNode: pkg("core", "pkg:npm/%40actions/core@1.1.9"), PackageDependency: pkg("pkg:npm/tunnel@0.0.6", "pkg:npm/tunnel@0.0.6"), Manifest: "a"},
+		},
+	}, {
+		name: "wrong document type",
+		doc: &processor.Document{
+			Blob:   testdata.DependencySnapshotExample,
+			Format: processor.FormatJSON,
+			Type:   processor.DocumentSPDX,
+		},
+		wantErr: true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewDepSnapshotParser()
+			err := p.Parse(ctx, tt.doc)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("depSnapshotParser.Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if nodes := p.CreateNodes(ctx); !testdata.GuacNodeSliceEqual(nodes, tt.wantNodes) {
+				t.Errorf("depSnapshotParser.CreateNodes() = %v, want %v", nodes, tt.wantNodes)
+			}
+			if edges := p.CreateEdges(ctx, nil); !testdata.GuacEdgeSliceEqual(edges, tt.wantEdges) {
+				t.Errorf("depSnapshotParser.CreateEdges() = %v, want %v", edges, tt.wantEdges)
+			}
+		})
+	}
+}
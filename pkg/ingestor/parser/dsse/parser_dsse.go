@@ -22,7 +22,9 @@ import (
 
 	"github.com/guacsec/guac/pkg/assembler"
 	"github.com/guacsec/guac/pkg/handler/processor"
+	dsse_processor "github.com/guacsec/guac/pkg/handler/processor/dsse"
 	"github.com/guacsec/guac/pkg/ingestor/parser/common"
+	"github.com/guacsec/guac/pkg/ingestor/policy"
 	"github.com/guacsec/guac/pkg/ingestor/verifier"
 	"github.com/sigstore/sigstore/pkg/cryptoutils"
 )
@@ -39,21 +41,38 @@ func NewDSSEParser() common.DocumentParser {
 	}
 }
 
-// Parse breaks out the document into the graph components
+// Parse breaks out the document into the graph components. The envelope is
+// rejected if the trust policy does not allow its signers to sign its payload.
 func (d *dsseParser) Parse(ctx context.Context, doc *processor.Document) error {
 	d.doc = doc
-	err := d.getIdentity(ctx)
+	identities, err := verifier.VerifyIdentity(ctx, d.doc)
+	if err != nil {
+		return err
+	}
+	if err := d.enforcePolicy(identities); err != nil {
+		return err
+	}
+	err = d.getIdentity(identities)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func (d *dsseParser) getIdentity(ctx context.Context) error {
-	identities, err := verifier.VerifyIdentity(ctx, d.doc)
+func (d *dsseParser) enforcePolicy(identities []verifier.Identity) error {
+	payloads, err := (&dsse_processor.DSSEProcessor{}).Unpack(d.doc)
 	if err != nil {
 		return err
 	}
+	for _, payload := range payloads {
+		if err := policy.Enforce(identities, payload.Blob); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *dsseParser) getIdentity(identities []verifier.Identity) error {
 	for _, i := range identities {
 		pemBytes, err := cryptoutils.MarshalPublicKeyToPEM(i.Key.Val)
 		if err != nil {
@@ -61,7 +80,9 @@ func (d *dsseParser) getIdentity(ctx context.Context) error {
 		}
 		d.identities = append(d.identities, assembler.IdentityNode{
 			ID: i.ID, Digest: i.Key.Hash, Key: base64.StdEncoding.EncodeToString(pemBytes),
-			KeyType: string(i.Key.Type), KeyScheme: string(i.Key.Scheme), NodeData: *assembler.NewObjectMetadata(d.doc.SourceInformation)})
+			KeyType: string(i.Key.Type), KeyScheme: string(i.Key.Scheme),
+			Issuer: i.Issuer, SubjectAlternativeName: i.SubjectAlternativeName,
+			NodeData: *assembler.NewObjectMetadata(d.doc.SourceInformation)})
 	}
 	return nil
 }
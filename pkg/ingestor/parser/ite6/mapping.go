@@ -0,0 +1,260 @@
+//
+// Copyright 2022 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ite6
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/guacsec/guac/pkg/assembler"
+	"gopkg.in/yaml.v3"
+)
+
+// defaultMaterialsPath is the predicate field holding the materials when a
+// mapping declares an edge but no materials path
+const defaultMaterialsPath = "materials"
+
+var (
+	mappingLock      sync.RWMutex
+	predicateMapping = &PredicateMapping{}
+)
+
+// PredicateMapping configures how in-toto attestations that have no
+// dedicated parser are turned into graph nodes and edges, based on their
+// predicate type. For example:
+//
+//	predicates:
+//	- predicateType: https://example.com/build/v1
+//	  edge: BuiltFrom
+//	  materials: inputs
+//	  attributes:
+//	    builder_id: builder.id
+//	    finished_on: metadata.finishedOn
+type PredicateMapping struct {
+	Predicates []PredicateRule `yaml:"predicates"`
+}
+
+// PredicateRule is the mapping of a single predicate type
+type PredicateRule struct {
+	// PredicateType is matched exactly, or as a prefix if it ends with `*`
+	PredicateType string `yaml:"predicateType"`
+	// Edge is the label of the edges from the subjects to the materials, no
+	// materials are ingested if empty
+	Edge string `yaml:"edge"`
+	// Materials is the path to the list of materials in the predicate, each
+	// material having a `uri` (or `name`) and a `digest` set. Defaults to
+	// `materials` if Edge is set.
+	Materials string `yaml:"materials"`
+	// Attributes maps attestation node attributes to the path of the
+	// predicate field they are lifted from
+	Attributes map[string]string `yaml:"attributes"`
+}
+
+// LoadPredicateMapping reads and validates the mapping in the YAML file path
+func LoadPredicateMapping(path string) (*PredicateMapping, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read predicate mapping: %w", err)
+	}
+	m, err := ParsePredicateMapping(data)
+	if err != nil {
+		return nil, fmt.Errorf("predicate mapping %s: %w", path, err)
+	}
+	return m, nil
+}
+
+// ParsePredicateMapping parses and validates a YAML predicate mapping.
+// Unknown fields are rejected, so that typos do not silently disable a rule.
+func ParsePredicateMapping(data []byte) (*PredicateMapping, error) {
+	m := &PredicateMapping{}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(m); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to parse predicate mapping: %w", err)
+	}
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+	for i := range m.Predicates {
+		if m.Predicates[i].Edge != "" && m.Predicates[i].Materials == "" {
+			m.Predicates[i].Materials = defaultMaterialsPath
+		}
+	}
+	return m, nil
+}
+
+// Validate returns an error listing every invalid rule of the mapping
+func (m *PredicateMapping) Validate() error {
+	// attributes are stored next to the attestation node properties
+	reserved := assembler.AttestationNode{}.PropertyNames()
+	problems := []string{}
+	seen := map[string]int{}
+	for i, rule := range m.Predicates {
+		location := fmt.Sprintf("predicates[%d]", i)
+		if rule.PredicateType == "" {
+			problems = append(problems, location+": predicateType is required")
+		} else {
+			location += " (" + rule.PredicateType + ")"
+			if j, ok := seen[rule.PredicateType]; ok {
+				problems = append(problems, fmt.Sprintf("%s: duplicate of predicates[%d]", location, j))
+			} else {
+				seen[rule.PredicateType] = i
+			}
+			if strings.Contains(strings.TrimSuffix(rule.PredicateType, "*"), "*") {
+				problems = append(problems, location+": predicateType may only end with a wildcard")
+			}
+		}
+		if rule.Edge != "" {
+			if err := assembler.ValidateLabel(rule.Edge); err != nil {
+				problems = append(problems, fmt.Sprintf("%s: edge: %v", location, err))
+			}
+		} else if rule.Materials != "" {
+			problems = append(problems, location+": materials requires an edge label")
+		}
+		if rule.Materials != "" && !validPath(rule.Materials) {
+			problems = append(problems, fmt.Sprintf("%s: materials: invalid path %q", location, rule.Materials))
+		}
+		for _, name := range sortedKeys(rule.Attributes) {
+			if err := assembler.ValidateLabel(name); err != nil {
+				problems = append(problems, fmt.Sprintf("%s: attribute name: %v", location, err))
+			} else if containsString(reserved, name) {
+				problems = append(problems, fmt.Sprintf("%s: attribute %q conflicts with an attestation property", location, name))
+			}
+			if !validPath(rule.Attributes[name]) {
+				problems = append(problems, fmt.Sprintf("%s: attribute %q: invalid path %q", location, name, rule.Attributes[name]))
+			}
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid predicate mapping: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// Lookup returns the rule for predicateType: the exact match if any, else the
+// wildcard rule with the longest matching prefix, or nil.
+func (m *PredicateMapping) Lookup(predicateType string) *PredicateRule {
+	var found *PredicateRule
+	longest := -1
+	for i, rule := range m.Predicates {
+		if rule.PredicateType == predicateType {
+			return &m.Predicates[i]
+		}
+		prefix := strings.TrimSuffix(rule.PredicateType, "*")
+		if prefix != rule.PredicateType && strings.HasPrefix(predicateType, prefix) && len(prefix) > longest {
+			found = &m.Predicates[i]
+			longest = len(prefix)
+		}
+	}
+	return found
+}
+
+// SetPredicateMapping sets the mapping used by the parsers returned by
+// NewITE6Parser. It should be validated beforehand.
+func SetPredicateMapping(m *PredicateMapping) {
+	mappingLock.Lock()
+	defer mappingLock.Unlock()
+	if m == nil {
+		m = &PredicateMapping{}
+	}
+	predicateMapping = m
+}
+
+func getPredicateMapping() *PredicateMapping {
+	mappingLock.RLock()
+	defer mappingLock.RUnlock()
+	return predicateMapping
+}
+
+// validPath reports whether path is a non empty list of dot separated keys
+func validPath(path string) bool {
+	if path == "" {
+		return false
+	}
+	for _, key := range strings.Split(path, ".") {
+		if key == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// lookupPath returns the value at the dot separated path in v. Numeric keys
+// index lists.
+func lookupPath(v interface{}, path string) (interface{}, bool) {
+	for _, key := range strings.Split(path, ".") {
+		switch value := v.(type) {
+		case map[string]interface{}:
+			next, ok := value[key]
+			if !ok {
+				return nil, false
+			}
+			v = next
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(value) {
+				return nil, false
+			}
+			v = value[i]
+		default:
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// attributeValue converts a predicate field to a node property value. Lists
+// and objects are stored as JSON, as the graph cannot hold nested values.
+func attributeValue(v interface{}) (interface{}, bool) {
+	switch value := v.(type) {
+	case nil:
+		return nil, false
+	case string, bool, float64:
+		return value, true
+	default:
+		b, err := json.Marshal(value)
+		if err != nil {
+			return nil, false
+		}
+		return string(b), true
+	}
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
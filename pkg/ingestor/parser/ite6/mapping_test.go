@@ -0,0 +1,153 @@
+//
+// Copyright 2022 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ite6
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParsePredicateMapping(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    *PredicateMapping
+		wantErr string
+	}{{
+		name: "empty",
+		data: "",
+		want: &PredicateMapping{},
+	}, {
+		name: "default materials path",
+		data: `
+predicates:
+- predicateType: https://example.com/build/v1
+  edge: BuiltFrom
+  attributes:
+    builder_id: builder.id
+- predicateType: https://example.com/review/*
+  attributes:
+    reviewer: reviewer.name
+`,
+		want: &PredicateMapping{Predicates: []PredicateRule{{
+			PredicateType: "https://example.com/build/v1",
+			Edge:          "BuiltFrom",
+			Materials:     "materials",
+			Attributes:    map[string]string{"builder_id": "builder.id"},
+		}, {
+			PredicateType: "https://example.com/review/*",
+			Attributes:    map[string]string{"reviewer": "reviewer.name"},
+		}}},
+	}, {
+		name:    "unknown field",
+		data:    "predicates:\n- predicateType: https://example.com/build/v1\n  edges: BuiltFrom\n",
+		wantErr: "field edges not found",
+	}, {
+		name:    "missing predicate type",
+		data:    "predicates:\n- edge: BuiltFrom\n",
+		wantErr: "predicates[0]: predicateType is required",
+	}, {
+		name:    "duplicate predicate type",
+		data:    "predicates:\n- predicateType: a\n- predicateType: a\n",
+		wantErr: "predicates[1] (a): duplicate of predicates[0]",
+	}, {
+		name:    "invalid edge label",
+		data:    "predicates:\n- predicateType: a\n  edge: Built From\n",
+		wantErr: `predicates[0] (a): edge: invalid label "Built From"`,
+	}, {
+		name:    "materials without edge",
+		data:    "predicates:\n- predicateType: a\n  materials: inputs\n",
+		wantErr: "predicates[0] (a): materials requires an edge label",
+	}, {
+		name:    "invalid attribute name",
+		data:    "predicates:\n- predicateType: a\n  attributes:\n    builder-id: builder.id\n",
+		wantErr: `predicates[0] (a): attribute name: invalid label "builder-id"`,
+	}, {
+		name:    "reserved attribute name",
+		data:    "predicates:\n- predicateType: a\n  attributes:\n    digest: subject.digest\n",
+		wantErr: `predicates[0] (a): attribute "digest" conflicts with an attestation property`,
+	}, {
+		name:    "invalid attribute path",
+		data:    "predicates:\n- predicateType: a\n  attributes:\n    builder_id: builder..id\n",
+		wantErr: `predicates[0] (a): attribute "builder_id": invalid path "builder..id"`,
+	}, {
+		name:    "wildcard not at the end",
+		data:    "predicates:\n- predicateType: https://*/v1\n",
+		wantErr: "predicateType may only end with a wildcard",
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParsePredicateMapping([]byte(tt.data))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ParsePredicateMapping() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParsePredicateMapping() unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParsePredicateMapping() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadPredicateMapping(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mapping.yaml")
+	if err := os.WriteFile(path, []byte("predicates:\n- predicateType: a\n  edge: Bad Label\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	_, err := LoadPredicateMapping(path)
+	if err == nil || !strings.Contains(err.Error(), path) {
+		t.Errorf("LoadPredicateMapping() error = %v, want it to name %s", err, path)
+	}
+	if _, err := LoadPredicateMapping(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("LoadPredicateMapping() expected error for missing file")
+	}
+}
+
+func TestPredicateMappingLookup(t *testing.T) {
+	m := &PredicateMapping{Predicates: []PredicateRule{
+		{PredicateType: "https://example.com/*", Edge: "Any"},
+		{PredicateType: "https://example.com/build/*", Edge: "Build"},
+		{PredicateType: "https://example.com/build/v1", Edge: "BuildV1"},
+	}}
+	tests := []struct {
+		predicateType string
+		want          string
+	}{
+		{predicateType: "https://example.com/build/v1", want: "BuildV1"},
+		{predicateType: "https://example.com/build/v2", want: "Build"},
+		{predicateType: "https://example.com/test/v1", want: "Any"},
+		{predicateType: "https://other.com/build/v1", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.predicateType, func(t *testing.T) {
+			got := ""
+			if rule := m.Lookup(tt.predicateType); rule != nil {
+				got = rule.Edge
+			}
+			if got != tt.want {
+				t.Errorf("Lookup() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
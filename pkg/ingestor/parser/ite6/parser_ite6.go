@@ -0,0 +1,195 @@
+//
+// Copyright 2022 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ite6
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/guacsec/guac/pkg/assembler"
+	"github.com/guacsec/guac/pkg/handler/processor"
+	"github.com/guacsec/guac/pkg/ingestor/parser/common"
+	"github.com/guacsec/guac/pkg/logging"
+	"github.com/in-toto/in-toto-golang/in_toto"
+)
+
+const (
+	algorithmSHA256 string = "sha256"
+)
+
+// ite6Parser parses in-toto statements without a dedicated parser. Every
+// statement gets an attestation node for its subjects; the predicate mapping
+// set by SetPredicateMapping adds attributes and material edges for known
+// predicate types.
+type ite6Parser struct {
+	doc         *processor.Document
+	rule        *PredicateRule
+	subjects    []assembler.ArtifactNode
+	materials   []assembler.ArtifactNode
+	attestation assembler.AttestationNode
+}
+
+// NewITE6Parser initializes the ite6Parser
+func NewITE6Parser() common.DocumentParser {
+	return &ite6Parser{
+		subjects:  []assembler.ArtifactNode{},
+		materials: []assembler.ArtifactNode{},
+	}
+}
+
+// Parse breaks out the document into the graph components
+func (p *ite6Parser) Parse(ctx context.Context, doc *processor.Document) error {
+	logger := logging.FromContext(ctx)
+	p.doc = doc
+	statement := in_toto.Statement{}
+	if err := json.Unmarshal(doc.Blob, &statement); err != nil {
+		return fmt.Errorf("failed to parse in-toto statement: %w", err)
+	}
+	// the predicate is decoded into generic maps and lists
+	predicate := statement.Predicate
+
+	p.rule = getPredicateMapping().Lookup(statement.PredicateType)
+	if p.rule == nil {
+		logger.Debugf("no predicate mapping for %q, ingesting subjects only", statement.PredicateType)
+	}
+	p.getSubjects(statement.Subject)
+	p.getAttestation(statement.PredicateType, predicate)
+	if p.rule != nil && p.rule.Edge != "" {
+		p.getMaterials(predicate)
+	}
+	return nil
+}
+
+func (p *ite6Parser) getSubjects(subjects []in_toto.Subject) {
+	for _, sub := range subjects {
+		if len(sub.Digest) == 0 {
+			continue
+		}
+		digest, alternates := common.ArtifactDigests(getDigests(sub.Digest))
+		p.subjects = append(p.subjects, assembler.ArtifactNode{
+			Name: sub.Name, Digest: digest, AlternateDigests: alternates, NodeData: *assembler.NewObjectMetadata(p.doc.SourceInformation)})
+	}
+}
+
+func (p *ite6Parser) getAttestation(predicateType string, predicate interface{}) {
+	h := sha256.Sum256(p.doc.Blob)
+	p.attestation = assembler.AttestationNode{
+		FilePath:        p.doc.SourceInformation.Source,
+		Digest:          algorithmSHA256 + ":" + hex.EncodeToString(h[:]),
+		AttestationType: predicateType,
+		NodeData:        *assembler.NewObjectMetadata(p.doc.SourceInformation),
+	}
+	if p.rule == nil || len(p.rule.Attributes) == 0 {
+		return
+	}
+	payload := map[string]interface{}{}
+	for name, path := range p.rule.Attributes {
+		field, ok := lookupPath(predicate, path)
+		if !ok {
+			continue
+		}
+		if value, ok := attributeValue(field); ok {
+			payload[name] = value
+		}
+	}
+	if len(payload) > 0 {
+		p.attestation.Payload = payload
+	}
+}
+
+// getMaterials adds an artifact node for each material with a digest, in the
+// list found at the materials path of the rule
+func (p *ite6Parser) getMaterials(predicate interface{}) {
+	field, ok := lookupPath(predicate, p.rule.Materials)
+	if !ok {
+		return
+	}
+	list, ok := field.([]interface{})
+	if !ok {
+		return
+	}
+	for _, entry := range list {
+		material, ok := entry.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		set := map[string]string{}
+		if digests, ok := material["digest"].(map[string]interface{}); ok {
+			for alg, d := range digests {
+				if s, ok := d.(string); ok && s != "" {
+					set[alg] = s
+				}
+			}
+		}
+		if len(set) == 0 {
+			continue
+		}
+		name, _ := material["uri"].(string)
+		if name == "" {
+			name, _ = material["name"].(string)
+		}
+		digest, alternates := common.ArtifactDigests(getDigests(set))
+		p.materials = append(p.materials, assembler.ArtifactNode{
+			Name: name, Digest: digest, AlternateDigests: alternates, NodeData: *assembler.NewObjectMetadata(p.doc.SourceInformation)})
+	}
+}
+
+// getDigests returns the digest set of a subject or material as
+// `algorithm:value` digests
+func getDigests(set map[string]string) []string {
+	digests := []string{}
+	for alg, ds := range set {
+		digests = append(digests, alg+":"+strings.Trim(ds, "'"))
+	}
+	return digests
+}
+
+// CreateNodes creates the GuacNode for the graph inputs
+func (p *ite6Parser) CreateNodes(ctx context.Context) []assembler.GuacNode {
+	nodes := []assembler.GuacNode{}
+	for _, sub := range p.subjects {
+		nodes = append(nodes, sub)
+	}
+	nodes = append(nodes, p.attestation)
+	for _, m := range p.materials {
+		nodes = append(nodes, m)
+	}
+	return nodes
+}
+
+// CreateEdges creates the GuacEdges that form the relationship for the graph inputs
+func (p *ite6Parser) CreateEdges(ctx context.Context, foundIdentities []assembler.IdentityNode) []assembler.GuacEdge {
+	edges := []assembler.GuacEdge{}
+	for _, i := range foundIdentities {
+		edges = append(edges, assembler.IdentityForEdge{IdentityNode: i, AttestationNode: p.attestation})
+	}
+	for _, sub := range p.subjects {
+		edges = append(edges, assembler.AttestationForEdge{AttestationNode: p.attestation, ForArtifact: sub})
+		for _, m := range p.materials {
+			edges = append(edges, assembler.MaterialEdge{ArtifactNode: sub, MaterialArtifact: m, Label: p.rule.Edge})
+		}
+	}
+	return edges
+}
+
+// GetIdentities gets the identity node from the document if they exist
+func (p *ite6Parser) GetIdentities(ctx context.Context) []assembler.IdentityNode {
+	return nil
+}
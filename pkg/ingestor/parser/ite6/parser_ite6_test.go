@@ -0,0 +1,135 @@
+//
+// Copyright 2022 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ite6
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"reflect"
+	"testing"
+
+	"github.com/guacsec/guac/pkg/assembler"
+	"github.com/guacsec/guac/pkg/handler/processor"
+	"github.com/guacsec/guac/pkg/logging"
+)
+
+var buildStatement = []byte(`{
+	"_type": "https://in-toto.io/Statement/v0.1",
+	"subject": [{"name": "app.tar.gz", "digest": {"sha256": "1234"}}],
+	"predicateType": "https://example.com/build/v1",
+	"predicate": {
+		"builder": {"id": "https://example.com/builder", "version": 2},
+		"reproducible": true,
+		"params": {"target": "linux"},
+		"inputs": [
+			{"uri": "git+https://example.com/app", "digest": {"sha1": "abcd"}},
+			{"name": "go1.20.tar.gz", "digest": {"sha256": "5678"}},
+			{"uri": "https://example.com/no-digest"}
+		]
+	}
+}`)
+
+func Test_ite6Parser(t *testing.T) {
+	ctx := logging.WithLogger(context.Background())
+	doc := &processor.Document{
+		Blob:   buildStatement,
+		Type:   processor.DocumentITE6Generic,
+		Format: processor.FormatJSON,
+		SourceInformation: processor.SourceInformation{
+			Collector: "TestCollector",
+			Source:    "TestSource",
+		},
+	}
+	metadata := *assembler.NewObjectMetadata(doc.SourceInformation)
+	h := sha256.Sum256(doc.Blob)
+	subject := assembler.ArtifactNode{Name: "app.tar.gz", Digest: "sha256:1234", NodeData: metadata}
+	attestation := assembler.AttestationNode{
+		FilePath:        "TestSource",
+		Digest:          "sha256:" + hex.EncodeToString(h[:]),
+		AttestationType: "https://example.com/build/v1",
+		NodeData:        metadata,
+	}
+	source := assembler.ArtifactNode{Name: "git+https://example.com/app", Digest: "sha1:abcd", NodeData: metadata}
+	toolchain := assembler.ArtifactNode{Name: "go1.20.tar.gz", Digest: "sha256:5678", NodeData: metadata}
+
+	mapped := attestation
+	mapped.Payload = map[string]interface{}{
+		"builder_id":      "https://example.com/builder",
+		"builder_version": float64(2),
+		"reproducible":    true,
+		"params":          `{"target":"linux"}`,
+	}
+	firstInput := attestation
+	firstInput.Payload = map[string]interface{}{"first_input": "git+https://example.com/app"}
+
+	tests := []struct {
+		name      string
+		mapping   *PredicateMapping
+		wantNodes []assembler.GuacNode
+		wantEdges []assembler.GuacEdge
+	}{{
+		name:      "unknown predicate",
+		mapping:   nil,
+		wantNodes: []assembler.GuacNode{subject, attestation},
+		wantEdges: []assembler.GuacEdge{assembler.AttestationForEdge{AttestationNode: attestation, ForArtifact: subject}},
+	}, {
+		name: "mapped predicate",
+		mapping: &PredicateMapping{Predicates: []PredicateRule{{
+			PredicateType: "https://example.com/build/*",
+			Edge:          "BuiltFrom",
+			Materials:     "inputs",
+			Attributes: map[string]string{
+				"builder_id":      "builder.id",
+				"builder_version": "builder.version",
+				"reproducible":    "reproducible",
+				"params":          "params",
+				"missing":         "builder.missing",
+			},
+		}}},
+		wantNodes: []assembler.GuacNode{subject, mapped, source, toolchain},
+		wantEdges: []assembler.GuacEdge{
+			assembler.AttestationForEdge{AttestationNode: mapped, ForArtifact: subject},
+			assembler.MaterialEdge{ArtifactNode: subject, MaterialArtifact: source, Label: "BuiltFrom"},
+			assembler.MaterialEdge{ArtifactNode: subject, MaterialArtifact: toolchain, Label: "BuiltFrom"},
+		},
+	}, {
+		name: "attributes only",
+		mapping: &PredicateMapping{Predicates: []PredicateRule{{
+			PredicateType: "https://example.com/build/v1",
+			Attributes:    map[string]string{"first_input": "inputs.0.uri"},
+		}}},
+		wantNodes: []assembler.GuacNode{subject, firstInput},
+		wantEdges: []assembler.GuacEdge{assembler.AttestationForEdge{AttestationNode: firstInput, ForArtifact: subject}},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetPredicateMapping(tt.mapping)
+			defer SetPredicateMapping(nil)
+
+			p := NewITE6Parser()
+			if err := p.Parse(ctx, doc); err != nil {
+				t.Fatalf("ite6.Parse() error = %v", err)
+			}
+			if nodes := p.CreateNodes(ctx); !reflect.DeepEqual(nodes, tt.wantNodes) {
+				t.Errorf("ite6.CreateNodes() = %v, want %v", nodes, tt.wantNodes)
+			}
+			if edges := p.CreateEdges(ctx, nil); !reflect.DeepEqual(edges, tt.wantEdges) {
+				t.Errorf("ite6.CreateEdges() = %v, want %v", edges, tt.wantEdges)
+			}
+		})
+	}
+}
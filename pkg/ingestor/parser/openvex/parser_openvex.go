@@ -0,0 +1,142 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package openvex
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/guacsec/guac/pkg/assembler"
+	"github.com/guacsec/guac/pkg/handler/processor"
+	"github.com/guacsec/guac/pkg/handler/processor/openvex"
+	"github.com/guacsec/guac/pkg/ingestor/parser/common"
+)
+
+type openVEXParser struct {
+	doc *processor.Document
+	// vulns and products are keyed so that a vulnerability or product in
+	// several statements is a single node
+	vulns    map[string]assembler.VulnerabilityNode
+	products map[string]assembler.GuacNode
+	affects  []assembler.AffectsEdge
+}
+
+// NewOpenVEXParser initializes the openVEXParser
+func NewOpenVEXParser() common.DocumentParser {
+	return &openVEXParser{
+		vulns:    map[string]assembler.VulnerabilityNode{},
+		products: map[string]assembler.GuacNode{},
+		affects:  []assembler.AffectsEdge{},
+	}
+}
+
+// Parse breaks out the document into the graph components
+func (p *openVEXParser) Parse(ctx context.Context, doc *processor.Document) error {
+	if doc.Type != processor.DocumentOpenVEX {
+		return fmt.Errorf("expected document type: %v, actual document type: %v", processor.DocumentOpenVEX, doc.Type)
+	}
+	if doc.Format != processor.FormatJSON {
+		return fmt.Errorf("unable to support parsing of OpenVEX document format: %v", doc.Format)
+	}
+	p.doc = doc
+	vex, err := openvex.ParseVEX(doc.Blob)
+	if err != nil {
+		return fmt.Errorf("failed to parse OpenVEX document: %w", err)
+	}
+	for _, s := range vex.Statements {
+		p.addStatement(s)
+	}
+	return nil
+}
+
+// addStatement creates the vulnerability node of the statement and an edge
+// with the status of the vulnerability to each of its products
+func (p *openVEXParser) addStatement(s openvex.Statement) {
+	id := s.Vulnerability.Name
+	if id == "" {
+		id = s.Vulnerability.ID
+	}
+	if id == "" {
+		return
+	}
+	vuln, ok := p.vulns[id]
+	if !ok {
+		vuln = assembler.VulnerabilityNode{ID: id, NodeData: *assembler.NewObjectMetadata(p.doc.SourceInformation)}
+		p.vulns[id] = vuln
+	}
+	for _, product := range s.Products {
+		node := p.addProduct(product)
+		if node == nil {
+			continue
+		}
+		p.affects = append(p.affects, common.CreateAffectsEdge(vuln, node, s.Status, s.Justification))
+	}
+}
+
+// addProduct returns the node of the product, nil if it cannot be identified
+func (p *openVEXParser) addProduct(product openvex.Product) assembler.GuacNode {
+	digests := []string{}
+	for alg, value := range product.Hashes {
+		digests = append(digests, common.DigestAlgorithm(alg)+":"+value)
+	}
+	sort.Strings(digests)
+	node := common.CreateVEXProduct(product.Purl(), product.ID, digests, product.ID, p.doc.SourceInformation)
+	key := common.VEXProductKey(node)
+	if key == "" {
+		return nil
+	}
+	if existing, ok := p.products[key]; ok {
+		return existing
+	}
+	p.products[key] = node
+	return node
+}
+
+// CreateNodes creates the GuacNode for the graph inputs
+func (p *openVEXParser) CreateNodes(ctx context.Context) []assembler.GuacNode {
+	nodes := []assembler.GuacNode{}
+	for _, id := range sortedKeys(p.vulns) {
+		nodes = append(nodes, p.vulns[id])
+	}
+	for _, key := range sortedKeys(p.products) {
+		nodes = append(nodes, p.products[key])
+	}
+	return nodes
+}
+
+// CreateEdges creates the GuacEdges that form the relationship for the graph inputs
+func (p *openVEXParser) CreateEdges(ctx context.Context, foundIdentities []assembler.IdentityNode) []assembler.GuacEdge {
+	edges := []assembler.GuacEdge{}
+	for _, e := range p.affects {
+		edges = append(edges, e)
+	}
+	return edges
+}
+
+// GetIdentities gets the identity node from the document if they exist
+func (p *openVEXParser) GetIdentities(ctx context.Context) []assembler.IdentityNode {
+	return nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,92 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package openvex
+
+import (
+	"context"
+	"testing"
+
+	"github.com/guacsec/guac/internal/testing/testdata"
+	"github.com/guacsec/guac/pkg/assembler"
+	"github.com/guacsec/guac/pkg/handler/processor"
+)
+
+func Test_openVEXParser(t *testing.T) {
+	ctx := context.Background()
+	source := processor.SourceInformation{Collector: "TestCollector", Source: "TestSource"}
+	vuln := func(id string) assembler.VulnerabilityNode {
+		return assembler.VulnerabilityNode{ID: id, NodeData: *assembler.NewObjectMetadata(source)}
+	}
+	pkg := func(purl string) assembler.PackageNode {
+		return assembler.PackageNode{Purl: purl, NodeData: *assembler.NewObjectMetadata(source)}
+	}
+	git := pkg("pkg:apk/wolfi/git@2.39.0-r1?arch=x86_64")
+	curl := pkg("pkg:apk/wolfi/curl@8.0.1-r0")
+	image := assembler.ArtifactNode{
+		Name:     "https://example.com/images/app.tar",
+		Digest:   "sha256:402fbd2e9b4c4d7a9a4be1d4f6c4d3b6e2c3c4b5a6d7e8f9a0b1c2d3e4f5a6b7",
+		NodeData: *assembler.NewObjectMetadata(source),
+	}
+
+	tests := []struct {
+		name      string
+		doc       *processor.Document
+		wantNodes []assembler.GuacNode
+		wantEdges []assembler.GuacEdge
+		wantErr   bool
+	}{{
+		name: "OpenVEX document",
+		doc: &processor.Document{
+			Blob:              testdata.OpenVEXExample,
+			Format:            processor.FormatJSON,
+			Type:              processor.DocumentOpenVEX,
+			SourceInformation: source,
+		},
+		wantNodes: []assembler.GuacNode{vuln("CVE-2023-1234"), vuln("CVE-2023-5678"), vuln("CVE-2023-9999"), git, curl, image},
+		wantEdges: []assembler.GuacEdge{
+			assembler.AffectsEdge{VulnerabilityNode: vuln("CVE-2023-1234"), PackageNode: git, AnalysisState: "not_affected", Justification: "vulnerable_code_not_in_execute_path"},
+			assembler.AffectsEdge{VulnerabilityNode: vuln("CVE-2023-1234"), ArtifactNode: image, AnalysisState: "not_affected", Justification: "vulnerable_code_not_in_execute_path"},
+			assembler.AffectsEdge{VulnerabilityNode: vuln("CVE-2023-5678"), PackageNode: git, AnalysisState: "fixed"},
+			assembler.AffectsEdge{VulnerabilityNode: vuln("CVE-2023-9999"), PackageNode: curl, AnalysisState: "under_investigation"},
+		},
+	}, {
+		name: "wrong document type",
+		doc: &processor.Document{
+			Blob:   testdata.OpenVEXExample,
+			Format: processor.FormatJSON,
+			Type:   processor.DocumentCsaf,
+		},
+		wantErr: true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewOpenVEXParser()
+			err := p.Parse(ctx, tt.doc)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("openVEXParser.Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if nodes := p.CreateNodes(ctx); !testdata.GuacNodeSliceEqual(nodes, tt.wantNodes) {
+				t.Errorf("openVEXParser.CreateNodes() = %v, want %v", nodes, tt.wantNodes)
+			}
+			if edges := p.CreateEdges(ctx, nil); !testdata.GuacEdgeSliceEqual(edges, tt.wantEdges) {
+				t.Errorf("openVEXParser.CreateEdges() = %v, want %v", edges, tt.wantEdges)
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package osv
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"github.com/guacsec/guac/pkg/assembler"
+	"github.com/guacsec/guac/pkg/handler/processor"
+	"github.com/guacsec/guac/pkg/handler/processor/osv"
+	"github.com/guacsec/guac/pkg/ingestor/parser/common"
+)
+
+// statusAffected is the analysis state of the packages of an OSV entry, as
+// in OpenVEX
+const statusAffected = "affected"
+
+type osvParser struct {
+	doc      *processor.Document
+	vuln     assembler.VulnerabilityNode
+	packages []assembler.PackageNode
+	affects  []assembler.AffectsEdge
+}
+
+// NewOSVParser initializes the osvParser
+func NewOSVParser() common.DocumentParser {
+	return &osvParser{
+		packages: []assembler.PackageNode{},
+		affects:  []assembler.AffectsEdge{},
+	}
+}
+
+// Parse breaks out the document into the graph components
+func (o *osvParser) Parse(ctx context.Context, doc *processor.Document) error {
+	if doc.Type != processor.DocumentOSV {
+		return fmt.Errorf("expected document type: %v, actual document type: %v", processor.DocumentOSV, doc.Type)
+	}
+	if doc.Format != processor.FormatJSON {
+		return fmt.Errorf("unable to support parsing of OSV document format: %v", doc.Format)
+	}
+	o.doc = doc
+	entry, err := osv.ParseEntry(doc.Blob)
+	if err != nil {
+		return fmt.Errorf("failed to parse OSV entry: %w", err)
+	}
+	o.vuln = assembler.VulnerabilityNode{
+		ID:       entry.ID,
+		Aliases:  entry.Aliases,
+		NodeData: *assembler.NewObjectMetadata(doc.SourceInformation),
+	}
+	// a withdrawn entry no longer affects its packages
+	if entry.Withdrawn != "" {
+		return nil
+	}
+	for _, affected := range entry.Affected {
+		o.addAffected(affected)
+	}
+	return nil
+}
+
+// addAffected links the vulnerability to each affected version of the
+// package, or to the package without version if the entry only gives the
+// affected ranges
+func (o *osvParser) addAffected(affected osv.Affected) {
+	purl := affected.Package.PackageURL()
+	if purl == "" {
+		return
+	}
+	var ranges []string
+	for _, r := range affected.Ranges {
+		ranges = append(ranges, r.String())
+	}
+	if len(affected.Versions) == 0 {
+		o.addPackage(assembler.PackageNode{Name: affected.Package.Name, Purl: purl}, ranges)
+		return
+	}
+	for _, version := range affected.Versions {
+		o.addPackage(assembler.PackageNode{
+			Name:    affected.Package.Name,
+			Version: version,
+			Purl:    purl + "@" + url.PathEscape(version),
+		}, ranges)
+	}
+}
+
+func (o *osvParser) addPackage(pkg assembler.PackageNode, ranges []string) {
+	pkg.NodeData = *assembler.NewObjectMetadata(o.doc.SourceInformation)
+	o.packages = append(o.packages, pkg)
+	o.affects = append(o.affects, assembler.AffectsEdge{
+		VulnerabilityNode: o.vuln,
+		PackageNode:       pkg,
+		AnalysisState:     statusAffected,
+		AffectedRanges:    ranges,
+	})
+}
+
+// CreateNodes creates the GuacNode for the graph inputs
+func (o *osvParser) CreateNodes(ctx context.Context) []assembler.GuacNode {
+	nodes := []assembler.GuacNode{o.vuln}
+	for _, p := range o.packages {
+		nodes = append(nodes, p)
+	}
+	return nodes
+}
+
+// CreateEdges creates the GuacEdges that form the relationship for the graph inputs
+func (o *osvParser) CreateEdges(ctx context.Context, foundIdentities []assembler.IdentityNode) []assembler.GuacEdge {
+	edges := []assembler.GuacEdge{}
+	for _, e := range o.affects {
+		edges = append(edges, e)
+	}
+	return edges
+}
+
+// GetIdentities gets the identity node from the document if they exist
+func (o *osvParser) GetIdentities(ctx context.Context) []assembler.IdentityNode {
+	return nil
+}
@@ -0,0 +1,116 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package osv
+
+import (
+	"context"
+	"testing"
+
+	"github.com/guacsec/guac/internal/testing/testdata"
+	"github.com/guacsec/guac/pkg/assembler"
+	"github.com/guacsec/guac/pkg/handler/processor"
+)
+
+func Test_osvParser(t *testing.T) {
+	ctx := context.Background()
+	source := processor.SourceInformation{Collector: "TestCollector", Source: "TestSource"}
+	vuln := assembler.VulnerabilityNode{
+		ID:       "GHSA-599f-7c49-w659",
+		Aliases:  []string{"CVE-2022-42889"},
+		NodeData: *assembler.NewObjectMetadata(source),
+	}
+	commonsText := func(version string) assembler.PackageNode {
+		return assembler.PackageNode{
+			Name:     "org.apache.commons:commons-text",
+			Version:  version,
+			Purl:     "pkg:maven/org.apache.commons/commons-text@" + version,
+			NodeData: *assembler.NewObjectMetadata(source),
+		}
+	}
+	npmText := assembler.PackageNode{
+		Name:     "@example/text",
+		Purl:     "pkg:npm/%40example/text",
+		NodeData: *assembler.NewObjectMetadata(source),
+	}
+	commonsRanges := []string{"ECOSYSTEM >=1.5 <1.10.0"}
+
+	tests := []struct {
+		name      string
+		doc       *processor.Document
+		wantNodes []assembler.GuacNode
+		wantEdges []assembler.GuacEdge
+		wantErr   bool
+	}{{
+		name: "OSV entry",
+		doc: &processor.Document{
+			Blob:              testdata.OSVExample,
+			Format:            processor.FormatJSON,
+			Type:              processor.DocumentOSV,
+			SourceInformation: source,
+		},
+		wantNodes: []assembler.GuacNode{vuln, commonsText("1.5"), commonsText("1.6"), commonsText("1.7"), commonsText("1.8"), commonsText("1.9"), npmText},
+		wantEdges: []assembler.GuacEdge{
+			assembler.AffectsEdge{VulnerabilityNode: vuln, PackageNode: commonsText("1.5"), AnalysisState: "affected", AffectedRanges: commonsRanges},
+			assembler.AffectsEdge{VulnerabilityNode: vuln, PackageNode: commonsText("1.6"), AnalysisState: "affected", AffectedRanges: commonsRanges},
+			assembler.AffectsEdge{VulnerabilityNode: vuln, PackageNode: commonsText("1.7"), AnalysisState: "affected", AffectedRanges: commonsRanges},
+			assembler.AffectsEdge{VulnerabilityNode: vuln, PackageNode: commonsText("1.8"), AnalysisState: "affected", AffectedRanges: commonsRanges},
+			assembler.AffectsEdge{VulnerabilityNode: vuln, PackageNode: commonsText("1.9"), AnalysisState: "affected", AffectedRanges: commonsRanges},
+			assembler.AffectsEdge{VulnerabilityNode: vuln, PackageNode: npmText, AnalysisState: "affected", AffectedRanges: []string{"SEMVER <=2.1.0"}},
+		},
+	}, {
+		name: "withdrawn entry",
+		doc: &processor.Document{
+			Blob: []byte(`{
+				"id": "GHSA-599f-7c49-w659",
+				"modified": "2023-01-11T05:04:33.611295Z",
+				"withdrawn": "2023-01-11T05:04:33.611295Z",
+				"aliases": ["CVE-2022-42889"],
+				"affected": [{"package": {"ecosystem": "npm", "name": "@example/text"}}]
+			}`),
+			Format:            processor.FormatJSON,
+			Type:              processor.DocumentOSV,
+			SourceInformation: source,
+		},
+		wantNodes: []assembler.GuacNode{vuln},
+		wantEdges: []assembler.GuacEdge{},
+	}, {
+		name: "wrong document type",
+		doc: &processor.Document{
+			Blob:   testdata.OSVExample,
+			Format: processor.FormatJSON,
+			Type:   processor.DocumentITE6Vul,
+		},
+		wantErr: true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewOSVParser()
+			err := p.Parse(ctx, tt.doc)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("osvParser.Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if nodes := p.CreateNodes(ctx); !testdata.GuacNodeSliceEqual(nodes, tt.wantNodes) {
+				t.Errorf("osvParser.CreateNodes() = %v, want %v", nodes, tt.wantNodes)
+			}
+			if edges := p.CreateEdges(ctx, nil); !testdata.GuacEdgeSliceEqual(edges, tt.wantEdges) {
+				t.Errorf("osvParser.CreateEdges() = %v, want %v", edges, tt.wantEdges)
+			}
+		})
+	}
+}
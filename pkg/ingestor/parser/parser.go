@@ -18,38 +18,72 @@ package parser
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/guacsec/guac/pkg/assembler"
 	"github.com/guacsec/guac/pkg/emitter"
 	"github.com/guacsec/guac/pkg/handler/processor"
+	"github.com/guacsec/guac/pkg/handler/processor/hashcache"
 	"github.com/guacsec/guac/pkg/ingestor/parser/common"
+	"github.com/guacsec/guac/pkg/ingestor/parser/csaf"
 	"github.com/guacsec/guac/pkg/ingestor/parser/cyclonedx"
+	"github.com/guacsec/guac/pkg/ingestor/parser/depsnapshot"
 	"github.com/guacsec/guac/pkg/ingestor/parser/dsse"
+	"github.com/guacsec/guac/pkg/ingestor/parser/ite6"
+	"github.com/guacsec/guac/pkg/ingestor/parser/openvex"
+	"github.com/guacsec/guac/pkg/ingestor/parser/osv"
+	"github.com/guacsec/guac/pkg/ingestor/parser/sarif"
 	"github.com/guacsec/guac/pkg/ingestor/parser/scorecard"
 	"github.com/guacsec/guac/pkg/ingestor/parser/slsa"
 	"github.com/guacsec/guac/pkg/ingestor/parser/spdx"
+	"github.com/guacsec/guac/pkg/ingestor/parser/syft"
 	certify_vuln "github.com/guacsec/guac/pkg/ingestor/parser/vuln"
 	"github.com/guacsec/guac/pkg/logging"
+	"github.com/guacsec/guac/pkg/metrics"
+	"github.com/guacsec/guac/pkg/tracing"
 	uuid "github.com/satori/go.uuid"
 )
 
 func init() {
 	_ = RegisterDocumentParser(dsse.NewDSSEParser, processor.DocumentDSSE)
 	_ = RegisterDocumentParser(slsa.NewSLSAParser, processor.DocumentITE6SLSA)
+	_ = RegisterDocumentParser(ite6.NewITE6Parser, processor.DocumentITE6Generic)
 	_ = RegisterDocumentParser(certify_vuln.NewVulnCertificationParser, processor.DocumentITE6Vul)
 	_ = RegisterDocumentParser(spdx.NewSpdxParser, processor.DocumentSPDX)
 	_ = RegisterDocumentParser(cyclonedx.NewCycloneDXParser, processor.DocumentCycloneDX)
 	_ = RegisterDocumentParser(scorecard.NewScorecardParser, processor.DocumentScorecard)
+	_ = RegisterDocumentParser(depsnapshot.NewDepSnapshotParser, processor.DocumentDependencySnapshot)
+	_ = RegisterDocumentParser(openvex.NewOpenVEXParser, processor.DocumentOpenVEX)
+	_ = RegisterDocumentParser(csaf.NewCSAFParser, processor.DocumentCsaf)
+	_ = RegisterDocumentParser(osv.NewOSVParser, processor.DocumentOSV)
+	_ = RegisterDocumentParser(syft.NewSyftParser, processor.DocumentSyft)
+	_ = RegisterDocumentParser(sarif.NewSARIFParser, processor.DocumentSARIF)
 }
 
 var (
 	documentParser = map[processor.DocumentType]func() common.DocumentParser{}
+
+	documentsParsed = metrics.NewCounter("guac_parser_documents_parsed_total",
+		"Documents parsed into graph inputs.")
+	documentsFailed = metrics.NewCounter("guac_parser_documents_failed_total",
+		"Documents that failed to parse.")
+	parseDuration = metrics.NewHistogram("guac_parser_parse_duration_seconds",
+		"Time taken to parse a document tree into graph inputs.", nil)
 )
 
 type docTreeBuilder struct {
 	identities    []assembler.IdentityNode
 	graphBuilders []*common.GraphBuilder
+	// sources are the documents parsed by graphBuilders, which assert the
+	// nodes and edges of their graph
+	sources   []assembler.DocumentNode
+	documents int
+	failures  []*DocumentError
 }
 
 func newDocTreeBuilder() *docTreeBuilder {
@@ -67,85 +101,214 @@ func RegisterDocumentParser(p func() common.DocumentParser, d processor.Document
 	return nil
 }
 
+// RegisteredDocumentParsers returns the name of the parser registered for
+// each document type, e.g. "slsa.slsaParser"
+func RegisteredDocumentParsers() map[processor.DocumentType]string {
+	parsers := make(map[processor.DocumentType]string, len(documentParser))
+	for t, p := range documentParser {
+		parsers[t] = strings.TrimPrefix(fmt.Sprintf("%T", p()), "*")
+	}
+	return parsers
+}
+
 // Subscribe is used by NATS JetStream to stream the documents received from the processor
-// and parse them them via ParseDocumentTree
-func Subscribe(ctx context.Context, transportFunc func([]assembler.Graph) error) error {
-	logger := logging.FromContext(ctx)
+// and parse them them via ParseDocumentTree. transportFunc is given the context of
+// the document tree, carrying its trace. Document trees that fail to store are moved to
+// the retry subject, see SubscribeRetries, and the ones that fail to parse to the
+// dead-letter subject.
+func Subscribe(ctx context.Context, transportFunc func(context.Context, []assembler.Graph) error) error {
+	return SubscribeWithCache(ctx, emitter.DurableIngestor, transportFunc, nil)
+}
 
+// SubscribeWithCache is like Subscribe, consuming the document trees with the
+// durable consumer of the given name, but skips document trees whose root
+// document content hash is found in seen. The hash is added to seen once the
+// whole document tree was stored by transportFunc, so that a document failing
+// to parse or to store is ingested again. A nil seen disables the check.
+func SubscribeWithCache(ctx context.Context, durable string, transportFunc func(context.Context, []assembler.Graph) error, seen *hashcache.Cache) error {
 	id := uuid.NewV4().String()
-	psub, err := emitter.NewPubSub(ctx, id, emitter.SubjectNameDocProcessed, emitter.DurableIngestor, emitter.BackOffTimer)
+	psub, err := emitter.NewPubSub(ctx, id, emitter.SubjectNameDocProcessed, durable, emitter.BackOffTimer)
 	if err != nil {
 		return err
 	}
 
-	parserFunc := func(d []byte) error {
-		docNode := processor.DocumentNode{}
-		err := json.Unmarshal(d, &docNode)
-		if err != nil {
-			fmtErr := fmt.Errorf("[ingestor: %s] failed unmarshal the document tree bytes: %w", id, err)
-			logger.Error(fmtErr)
-			return err
-		}
-		assemblerInputs, err := ParseDocumentTree(ctx, processor.DocumentTree(&docNode))
-		if err != nil {
-			fmtErr := fmt.Errorf("[ingestor: %s] failed parse document: %w", id, err)
-			logger.Error(fmtErr)
-			return fmtErr
-		}
-
-		err = transportFunc(assemblerInputs)
+	parserFunc := func(ctx context.Context, d []byte) error {
+		retryable, err := ingest(ctx, id, d, transportFunc, seen)
 		if err != nil {
-			fmtErr := fmt.Errorf("[ingestor: %s] failed transportFunc: %w", id, err)
-			logger.Error(fmtErr)
-			return fmtErr
+			// the document tree is moved out of the way so that the
+			// ingestor carries on with the next one
+			return requeue(ctx, durable, d, 1, retryable, err)
 		}
-
-		logger.Infof("[ingestor: %s] ingested docTree: %+v", id, processor.DocumentTree(&docNode).Document.SourceInformation)
 		return nil
 	}
 
-	err = psub.GetDataFromNats(ctx, parserFunc)
+	err = psub.GetDataFromNatsWithContext(ctx, parserFunc)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
+// ingest parses the document tree and stores its graph with transportFunc.
+// Failing to store it is assumed to be transient and worth retrying, unlike
+// failing to parse it. Document trees found in seen, if not nil, are skipped
+// and the ones fully stored are added to it. The parsing and storing are
+// traced under a single span, continuing the trace of the document.
+func ingest(ctx context.Context, id string, d []byte, transportFunc func(context.Context, []assembler.Graph) error, seen *hashcache.Cache) (bool, error) {
+	ctx, span := tracing.Start(ctx, "ingest")
+	defer span.End()
+	logger := logging.FromContext(ctx)
+
+	docNode := processor.DocumentNode{}
+	err := json.Unmarshal(d, &docNode)
+	if err != nil {
+		fmtErr := fmt.Errorf("[ingestor: %s] failed unmarshal the document tree bytes: %w", id, err)
+		logger.Error(fmtErr)
+		span.RecordError(fmtErr)
+		return false, fmtErr
+	}
+	if docNode.Document != nil {
+		span.SetAttribute("guac.source", docNode.Document.SourceInformation.Source)
+	}
+	var hash string
+	if seen != nil && docNode.Document != nil {
+		hash = hashcache.HashDocument(docNode.Document)
+		if seen.Seen(hash) {
+			logger.Infof("[ingestor: %s] skipping unchanged docTree: %+v", id, docNode.Document.SourceInformation)
+			span.SetAttribute("guac.skipped", true)
+			return false, nil
+		}
+	}
+	assemblerInputs, parseErr := ParseDocumentTree(ctx, processor.DocumentTree(&docNode))
+	if parseErr != nil {
+		fmtErr := fmt.Errorf("[ingestor: %s] failed parse document: %w", id, parseErr)
+		logger.Error(fmtErr)
+		// the documents that did parse are still ingested, retrying
+		// would not fix the others
+		var treeErr *TreeError
+		if !errors.As(parseErr, &treeErr) || !treeErr.Partial() {
+			span.RecordError(fmtErr)
+			return false, fmtErr
+		}
+	}
+
+	err = transportFunc(ctx, assemblerInputs)
+	if err != nil {
+		fmtErr := fmt.Errorf("[ingestor: %s] failed transportFunc: %w", id, err)
+		logger.Error(fmtErr)
+		span.RecordError(fmtErr)
+		return true, fmtErr
+	}
+	if hash != "" && parseErr == nil {
+		seen.Add(hash)
+	}
+
+	logger.Infof("[ingestor: %s] ingested docTree: %+v", id, processor.DocumentTree(&docNode).Document.SourceInformation)
+	return false, nil
+}
+
+// DocumentError is the failure to parse one document of a document tree
+type DocumentError struct {
+	// Source of the document
+	Source string
+	// Type of the document
+	Type processor.DocumentType
+	// Path of the document in the tree: "/" for the root, "/0/1" for the
+	// second child of the first child of the root
+	Path string
+	Err  error
+}
+
+func (e *DocumentError) Error() string {
+	return fmt.Sprintf("%s (%s document at %s): %v", e.Source, e.Type, e.Path, e.Err)
+}
+
+func (e *DocumentError) Unwrap() error {
+	return e.Err
+}
+
+// TreeError is returned by ParseDocumentTree when documents of the tree fail
+// to parse, listing each of them
+type TreeError struct {
+	// Documents is the number of documents in the tree
+	Documents int
+	Failures  []*DocumentError
+}
+
+func (e *TreeError) Error() string {
+	msgs := make([]string, len(e.Failures))
+	for i, f := range e.Failures {
+		msgs[i] = f.Error()
+	}
+	return fmt.Sprintf("failed to parse %d of %d documents: %s", len(e.Failures), e.Documents, strings.Join(msgs, "; "))
+}
+
+// Partial reports whether some documents of the tree were parsed
+func (e *TreeError) Partial() bool {
+	return len(e.Failures) < e.Documents
+}
+
 // ParseDocumentTree takes the DocumentTree and create graph inputs (nodes and edges) per document node.
+// A document failing to parse does not stop its siblings from being parsed: their graph inputs are
+// returned together with a *TreeError listing the failures. The documents under a failed one are
+// skipped. The graph input of each document is asserted by the document, see assembler.AssertedBy.
 func ParseDocumentTree(ctx context.Context, docTree processor.DocumentTree) ([]assembler.Graph, error) {
+	ctx, span := tracing.Start(ctx, "parse")
+	defer span.End()
+	defer parseDuration.ObserveSince(time.Now())
+	if docTree != nil && docTree.Document != nil {
+		ctx = logging.WithCollectorName(ctx, docTree.Document.SourceInformation.CollectorName)
+		span.SetAttribute("guac.source", docTree.Document.SourceInformation.Source)
+	}
+	ctx = logging.WithComponent(ctx, logging.ComponentIngestor)
 	assemblerInputs := []assembler.Graph{}
 	docTreeBuilder := newDocTreeBuilder()
-	err := docTreeBuilder.parse(ctx, docTree)
-	if err != nil {
-		return nil, err
-	}
-	for _, builder := range docTreeBuilder.graphBuilders {
+	docTreeBuilder.parse(ctx, docTree, "/")
+	for i, builder := range docTreeBuilder.graphBuilders {
 		assemblerInput := builder.CreateAssemblerInput(ctx, docTreeBuilder.identities)
-		assemblerInputs = append(assemblerInputs, assemblerInput)
+		assemblerInputs = append(assemblerInputs, assembler.AssertedBy(assemblerInput, docTreeBuilder.sources[i]))
+	}
+	span.SetAttribute("guac.documents", docTreeBuilder.documents)
+	documentsParsed.Add(float64(len(docTreeBuilder.graphBuilders)))
+	documentsFailed.Add(float64(len(docTreeBuilder.failures)))
+	if len(docTreeBuilder.failures) > 0 {
+		err := &TreeError{
+			Documents: docTreeBuilder.documents,
+			Failures:  docTreeBuilder.failures,
+		}
+		span.RecordError(err)
+		return assemblerInputs, err
 	}
 	return assemblerInputs, nil
 }
 
-func (t *docTreeBuilder) parse(ctx context.Context, root processor.DocumentTree) error {
+func (t *docTreeBuilder) parse(ctx context.Context, root processor.DocumentTree, treePath string) {
+	t.documents++
 	builder, err := parseHelper(ctx, root.Document)
 	if err != nil {
-		return err
+		t.failures = append(t.failures, &DocumentError{
+			Source: root.Document.SourceInformation.Source,
+			Type:   root.Document.Type,
+			Path:   treePath,
+			Err:    err,
+		})
+		// children are vouched for by their parent (e.g. the payload of an
+		// envelope whose signatures failed to verify), so none of them is
+		// ingested when it does not parse
+		if len(root.Children) > 0 {
+			logging.FromContext(ctx).Warnf("skipping the %d documents under %s that failed to parse", len(root.Children), treePath)
+		}
+		return
 	}
-
 	t.graphBuilders = append(t.graphBuilders, builder)
+	source := assembler.NewDocumentNode(root.Document.Blob, root.Document.SourceInformation)
+	source.DocumentType = string(root.Document.Type)
+	t.sources = append(t.sources, source)
 	t.identities = append(t.identities, builder.GetIdentities()...)
 
-	if len(root.Children) == 0 {
-		return nil
+	for i, c := range root.Children {
+		t.parse(ctx, c, path.Join(treePath, strconv.Itoa(i)))
 	}
-
-	for _, c := range root.Children {
-		err := t.parse(ctx, c)
-		if err != nil {
-			return err
-		}
-	}
-	return nil
 }
 
 func parseHelper(ctx context.Context, doc *processor.Document) (*common.GraphBuilder, error) {
@@ -19,6 +19,7 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -29,6 +30,7 @@ import (
 	"github.com/guacsec/guac/pkg/assembler"
 	"github.com/guacsec/guac/pkg/emitter"
 	"github.com/guacsec/guac/pkg/handler/processor"
+	"github.com/guacsec/guac/pkg/handler/processor/hashcache"
 	"github.com/guacsec/guac/pkg/ingestor/verifier"
 	"github.com/guacsec/guac/pkg/logging"
 )
@@ -117,6 +119,137 @@ func TestParseDocumentTree(t *testing.T) {
 	}
 }
 
+func TestParseDocumentTree_partial(t *testing.T) {
+	ctx := logging.WithLogger(context.Background())
+	badDoc := &processor.Document{
+		Blob:   []byte("{}"),
+		Format: processor.FormatJSON,
+		Type:   processor.DocumentUnknown,
+		SourceInformation: processor.SourceInformation{
+			Collector: "TestCollector",
+			Source:    "BadSource",
+		},
+	}
+	tree := processor.DocumentTree(&processor.DocumentNode{
+		Document: spdxDocTree.Document,
+		Children: []*processor.DocumentNode{
+			{Document: badDoc, Children: []*processor.DocumentNode{}},
+			{Document: badDoc, Children: []*processor.DocumentNode{}},
+		},
+	})
+
+	got, err := ParseDocumentTree(ctx, tree)
+	var treeErr *TreeError
+	if !errors.As(err, &treeErr) {
+		t.Fatalf("ParseDocumentTree() error = %v, want a *TreeError", err)
+	}
+	if !treeErr.Partial() || treeErr.Documents != 3 || len(treeErr.Failures) != 2 {
+		t.Errorf("ParseDocumentTree() error = %+v, want 2 of 3 documents failing", treeErr)
+	}
+	for i, f := range treeErr.Failures {
+		if wantPath := "/" + strconv.Itoa(i); f.Source != "BadSource" || f.Path != wantPath {
+			t.Errorf("ParseDocumentTree() failure = %v, want BadSource at %s", f, wantPath)
+		}
+	}
+	if len(got) != len(spdxGraphInput) {
+		t.Fatalf("ParseDocumentTree() = %v, want %v", got, spdxGraphInput)
+	}
+	compare(t, got[0].Edges, spdxGraphInput[0].Edges, got[0].Nodes, spdxGraphInput[0].Nodes)
+	wantDoc := assembler.NewDocumentNode(spdxDocTree.Document.Blob, spdxDocTree.Document.SourceInformation)
+	wantDoc.DocumentType = string(spdxDocTree.Document.Type)
+	if len(got[0].Documents) != 1 || got[0].Documents[0] != wantDoc {
+		t.Errorf("ParseDocumentTree() documents = %v, want the spdx document", got[0].Documents)
+	}
+}
+
+func TestParseDocumentTree_unverifiedEnvelope(t *testing.T) {
+	ctx := logging.WithLogger(context.Background())
+	err := verifier.RegisterVerifier(mockverifier.NewMockSigstoreVerifier(), "sigstore")
+	if err != nil {
+		if !strings.Contains(err.Error(), "the verification provider is being overwritten") {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+	// the mock verifier fails for envelopes without signatures
+	unsigned, err := json.Marshal(map[string]interface{}{
+		"payloadType": "https://in-toto.io/Statement/v0.1",
+		"payload":     "e30=",
+		"signatures":  []interface{}{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	envelope := testdata.Ite6DSSEDoc
+	envelope.Blob = unsigned
+	tree := processor.DocumentTree(&processor.DocumentNode{
+		Document: &envelope,
+		Children: dsseDocTree.Children,
+	})
+
+	got, err := ParseDocumentTree(ctx, tree)
+	var treeErr *TreeError
+	if !errors.As(err, &treeErr) {
+		t.Fatalf("ParseDocumentTree() error = %v, want a *TreeError", err)
+	}
+	if treeErr.Partial() || len(treeErr.Failures) != 1 || treeErr.Failures[0].Path != "/" {
+		t.Errorf("ParseDocumentTree() error = %+v, want only the envelope failing", treeErr)
+	}
+	if len(got) != 0 {
+		t.Errorf("ParseDocumentTree() = %v, want no graph for the payload of an unverified envelope", got)
+	}
+}
+
+func Test_ingest_seenCache(t *testing.T) {
+	ctx := logging.WithLogger(context.Background())
+	d, err := json.Marshal(&spdxDocTree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := hashcache.New(10, time.Hour)
+	stored := 0
+	storeErr := errors.New("graph database unavailable")
+	transportFunc := func(_ context.Context, _ []assembler.Graph) error {
+		stored++
+		return storeErr
+	}
+
+	if retryable, err := ingest(ctx, "test", d, transportFunc, seen); err == nil || !retryable {
+		t.Fatalf("ingest() = %v, %v, want a retryable error", retryable, err)
+	}
+	if seen.Len() != 0 {
+		t.Fatalf("ingest() recorded the hash of a document tree that failed to store")
+	}
+	storeErr = nil
+	if _, err := ingest(ctx, "test", d, transportFunc, seen); err != nil {
+		t.Fatalf("ingest() error = %v", err)
+	}
+	if _, err := ingest(ctx, "test", d, transportFunc, seen); err != nil {
+		t.Fatalf("ingest() error = %v", err)
+	}
+	if stored != 2 {
+		t.Errorf("ingest() stored the document tree %d times, want 2", stored)
+	}
+}
+
+func TestRegisteredDocumentParsers(t *testing.T) {
+	parsers := RegisteredDocumentParsers()
+	want := map[processor.DocumentType]string{
+		processor.DocumentDSSE:               "dsse.dsseParser",
+		processor.DocumentITE6SLSA:           "slsa.slsaParser",
+		processor.DocumentSPDX:               "spdx.spdxParser",
+		processor.DocumentCycloneDX:          "cyclonedx.cyclonedxParser",
+		processor.DocumentDependencySnapshot: "depsnapshot.depSnapshotParser",
+	}
+	for docType, name := range want {
+		if parsers[docType] != name {
+			t.Errorf("RegisteredDocumentParsers()[%s] = %q, want %q", docType, parsers[docType], name)
+		}
+	}
+	if _, ok := parsers[processor.DocumentUnknown]; ok {
+		t.Errorf("RegisteredDocumentParsers() has a parser for %s", processor.DocumentUnknown)
+	}
+}
+
 func compare(t *testing.T, gotEdges, wantEdges []assembler.GuacEdge, gotNodes, wantNodes []assembler.GuacNode) {
 	if !testdata.GuacEdgeSliceEqual(gotEdges, wantEdges) {
 		t.Errorf("ParseDocumentTree() = %v, want %v", gotEdges, wantEdges)
@@ -182,7 +315,7 @@ func Test_ParserSubscribe(t *testing.T) {
 			ctx, cancel = context.WithTimeout(ctx, time.Second)
 			defer cancel()
 
-			transportFunc := func(d []assembler.Graph) error {
+			transportFunc := func(_ context.Context, d []assembler.Graph) error {
 				if len(d) != len(tt.want) {
 					t.Errorf("ParseDocumentTree() = %v, want %v", d, tt.want)
 				}
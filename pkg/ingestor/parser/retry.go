@@ -0,0 +1,173 @@
+//
+// Copyright 2022 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package parser
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/guacsec/guac/pkg/assembler"
+	"github.com/guacsec/guac/pkg/emitter"
+	"github.com/guacsec/guac/pkg/logging"
+	uuid "github.com/satori/go.uuid"
+)
+
+// RetryPolicy is how the ingestor retries the document trees it failed to
+// store, e.g. because of a transient graph db error
+type RetryPolicy struct {
+	// MaxAttempts is the number of times a document tree is stored before
+	// it is moved to the dead-letter subject, at least 1
+	MaxAttempts int
+	// Delay is how long a document tree waits on the retry subject before
+	// it is stored again. It is the same for every attempt, so that the
+	// documents on the retry subject are due in order.
+	Delay time.Duration
+}
+
+// DefaultRetryPolicy stores a document tree up to 5 times, a minute apart
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 5,
+	Delay:       time.Minute,
+}
+
+var (
+	retryPolicyLock sync.RWMutex
+	retryPolicy     = DefaultRetryPolicy
+)
+
+// Validate returns an error if the policy would never store a document tree
+// or wait a negative duration
+func (p RetryPolicy) Validate() error {
+	if p.MaxAttempts < 1 {
+		return fmt.Errorf("max attempts must be at least 1, got %d", p.MaxAttempts)
+	}
+	if p.Delay < 0 {
+		return fmt.Errorf("retry delay must not be negative, got %v", p.Delay)
+	}
+	return nil
+}
+
+// SetRetryPolicy sets the policy used by Subscribe and SubscribeRetries
+func SetRetryPolicy(p RetryPolicy) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	retryPolicyLock.Lock()
+	defer retryPolicyLock.Unlock()
+	retryPolicy = p
+	return nil
+}
+
+// GetRetryPolicy returns the policy used by Subscribe and SubscribeRetries
+func GetRetryPolicy() RetryPolicy {
+	retryPolicyLock.RLock()
+	defer retryPolicyLock.RUnlock()
+	return retryPolicy
+}
+
+// FailedDocument is a document tree the ingestor failed to store, as
+// published on the retry subject
+type FailedDocument struct {
+	// Attempts is the number of times the document tree failed to ingest
+	Attempts int `json:"attempts"`
+	// RetryAt is when the document tree is due to be stored again
+	RetryAt time.Time `json:"retryAt,omitempty"`
+	// Error is the error of the last attempt
+	Error string `json:"error"`
+	// DocumentTree is the document tree as published on the processed
+	// subject
+	DocumentTree json.RawMessage `json:"documentTree"`
+}
+
+// requeue publishes the document tree that the durable consumer failed to
+// ingest to the retry subject, or to the dead-letter subject if it is not
+// worth retrying or has no attempts left. Re-driving it from the dead-letter
+// subject publishes it on the processed subject again.
+func requeue(ctx context.Context, durable string, tree []byte, attempts int, retryable bool, err error) error {
+	policy := GetRetryPolicy()
+	if !retryable || attempts >= policy.MaxAttempts {
+		if pubErr := emitter.PublishDeadLetter(ctx, emitter.DeadLetter{
+			Subject:  emitter.SubjectNameDocProcessed,
+			Consumer: durable,
+			Attempts: attempts,
+			Error:    err.Error(),
+			FailedAt: time.Now().UTC(),
+			Data:     tree,
+		}); pubErr != nil {
+			return pubErr
+		}
+		logging.FromContext(ctx).Warnf("moved document tree to %s after %d failed attempts: %v", emitter.SubjectNameDocDeadLetter, attempts, err)
+		return nil
+	}
+	failed := FailedDocument{
+		Attempts:     attempts,
+		RetryAt:      time.Now().Add(policy.Delay).UTC(),
+		Error:        err.Error(),
+		DocumentTree: tree,
+	}
+	data, err := json.Marshal(failed)
+	if err != nil {
+		return fmt.Errorf("failed to marshal failed document: %w", err)
+	}
+	if err := emitter.Publish(ctx, emitter.SubjectNameDocRetry, data); err != nil {
+		return err
+	}
+	logging.FromContext(ctx).Warnf("moved document tree to %s after %d failed attempts: %s", emitter.SubjectNameDocRetry, attempts, failed.Error)
+	return nil
+}
+
+// SubscribeRetries stores again the document trees on the retry subject once
+// they are due, moving them back to the retry subject if they fail again and
+// to the dead-letter subject once they have no attempts left
+func SubscribeRetries(ctx context.Context, transportFunc func(context.Context, []assembler.Graph) error) error {
+	logger := logging.FromContext(ctx)
+
+	id := uuid.NewV4().String()
+	psub, err := emitter.NewPubSub(ctx, id, emitter.SubjectNameDocRetry, emitter.DurableRetry, emitter.BackOffTimer)
+	if err != nil {
+		return err
+	}
+
+	retryFunc := func(ctx context.Context, d []byte) error {
+		var failed FailedDocument
+		if err := json.Unmarshal(d, &failed); err != nil {
+			logger.Errorf("[retry: %s] dropping malformed failed document: %v", id, err)
+			return nil
+		}
+		if wait := time.Until(failed.RetryAt); wait > 0 {
+			select {
+			case <-ctx.Done():
+				// put the document back for the next ingestor, with a new
+				// message ID since the content is unchanged
+				if err := emitter.PublishWithMsgID(ctx, emitter.SubjectNameDocRetry, uuid.NewV4().String(), d); err != nil {
+					logger.Errorf("[retry: %s] failed to put back document: %v", id, err)
+				}
+				return ctx.Err()
+			case <-time.After(wait):
+			}
+		}
+		retryable, err := ingest(ctx, id, failed.DocumentTree, transportFunc, nil)
+		if err != nil {
+			return requeue(ctx, emitter.DurableRetry, failed.DocumentTree, failed.Attempts+1, retryable, err)
+		}
+		return nil
+	}
+
+	return psub.GetDataFromNatsWithContext(ctx, retryFunc)
+}
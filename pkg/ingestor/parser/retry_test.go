@@ -0,0 +1,120 @@
+//
+// Copyright 2022 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package parser
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	nats_test "github.com/guacsec/guac/internal/testing/nats"
+	"github.com/guacsec/guac/pkg/assembler"
+	"github.com/guacsec/guac/pkg/emitter"
+	"github.com/guacsec/guac/pkg/handler/processor"
+	"github.com/guacsec/guac/pkg/logging"
+)
+
+func TestSetRetryPolicy(t *testing.T) {
+	defer func() {
+		_ = SetRetryPolicy(DefaultRetryPolicy)
+	}()
+	if err := SetRetryPolicy(RetryPolicy{MaxAttempts: 0}); err == nil {
+		t.Errorf("SetRetryPolicy() expected error for 0 attempts")
+	}
+	if err := SetRetryPolicy(RetryPolicy{MaxAttempts: 1, Delay: -time.Second}); err == nil {
+		t.Errorf("SetRetryPolicy() expected error for a negative delay")
+	}
+	want := RetryPolicy{MaxAttempts: 2, Delay: time.Second}
+	if err := SetRetryPolicy(want); err != nil {
+		t.Fatal(err)
+	}
+	if got := GetRetryPolicy(); got != want {
+		t.Errorf("GetRetryPolicy() = %v, want %v", got, want)
+	}
+}
+
+func Test_SubscribeRetries(t *testing.T) {
+	natsTest := nats_test.NewNatsTestServer()
+	url, err := natsTest.EnableJetStreamForTest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer natsTest.Shutdown()
+
+	if err := SetRetryPolicy(RetryPolicy{MaxAttempts: 2}); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = SetRetryPolicy(DefaultRetryPolicy)
+	}()
+
+	ctx := logging.WithLogger(context.Background())
+	jetStream := emitter.NewJetStream(url, "", "")
+	ctx, err = jetStream.JetStreamInit(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error initializing jetstream: %v", err)
+	}
+	defer jetStream.Close()
+
+	if err := testPublish(ctx, processor.DocumentTree(&spdxDocTree)); err != nil {
+		t.Fatalf("unexpected error on emit: %v", err)
+	}
+	// a document tree that does not parse is not retried
+	if err := emitter.Publish(ctx, emitter.SubjectNameDocProcessed, []byte("not a document tree")); err != nil {
+		t.Fatalf("unexpected error on emit: %v", err)
+	}
+
+	subCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	var attempts int32
+	transportFunc := func(_ context.Context, _ []assembler.Graph) error {
+		atomic.AddInt32(&attempts, 1)
+		return errors.New("graph db unavailable")
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- SubscribeRetries(subCtx, transportFunc)
+	}()
+	if err := Subscribe(subCtx, transportFunc); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Subscribe() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if err := <-done; !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("SubscribeRetries() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if got := atomic.LoadInt32(&attempts); got != 2 {
+		t.Errorf("document tree stored %d times, want 2", got)
+	}
+
+	deadLetters, err := emitter.DeadLetters(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error listing dead letters: %v", err)
+	}
+	if len(deadLetters) != 2 {
+		t.Fatalf("got %d dead letters, want 2", len(deadLetters))
+	}
+	wantAttempts := map[int]bool{1: true, 2: true}
+	for _, d := range deadLetters {
+		if !wantAttempts[d.Attempts] || d.Subject != emitter.SubjectNameDocProcessed || d.Error == "" {
+			t.Errorf("unexpected dead letter %+v", d.DeadLetter)
+		}
+		delete(wantAttempts, d.Attempts)
+	}
+	if retries, err := emitter.QueuedMessages(ctx, emitter.SubjectNameDocRetry); err != nil || len(retries) != 0 {
+		t.Errorf("got %d documents to retry, error %v, want none", len(retries), err)
+	}
+}
@@ -0,0 +1,345 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sarif
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/guacsec/guac/pkg/assembler"
+	"github.com/guacsec/guac/pkg/handler/processor"
+	"github.com/guacsec/guac/pkg/handler/processor/sarif"
+	"github.com/guacsec/guac/pkg/ingestor/parser/common"
+)
+
+const (
+	// resultMetadataType is the metadata type of the nodes of the results
+	resultMetadataType = "sarif_result"
+	// scoreMethodSecuritySeverity is the score method of the security
+	// severity of the rules of security scanners
+	scoreMethodSecuritySeverity = "security-severity"
+	// defaultLevel is the level of a result that neither it nor its rule
+	// set, as in the SARIF specification
+	defaultLevel = "warning"
+)
+
+// Analysis states of the vulnerabilities reported by the results, as in
+// OpenVEX
+const (
+	statusAffected    = "affected"
+	statusNotAffected = "not_affected"
+)
+
+type sarifParser struct {
+	doc *processor.Document
+	// targets are the packages and artifacts the results are about, each
+	// once, and targetKeys the keys they are deduplicated on
+	targets    []assembler.GuacNode
+	targetKeys map[string]bool
+	findings   []assembler.MetadataNode
+	vulns      []assembler.VulnerabilityNode
+	vulnIDs    map[string]bool
+	edges      []assembler.GuacEdge
+}
+
+// NewSARIFParser initializes the sarifParser
+func NewSARIFParser() common.DocumentParser {
+	return &sarifParser{
+		targets:    []assembler.GuacNode{},
+		targetKeys: map[string]bool{},
+		findings:   []assembler.MetadataNode{},
+		vulns:      []assembler.VulnerabilityNode{},
+		vulnIDs:    map[string]bool{},
+		edges:      []assembler.GuacEdge{},
+	}
+}
+
+// Parse breaks out the document into the graph components
+func (s *sarifParser) Parse(ctx context.Context, doc *processor.Document) error {
+	if doc.Type != processor.DocumentSARIF {
+		return fmt.Errorf("expected document type: %v, actual document type: %v", processor.DocumentSARIF, doc.Type)
+	}
+	if doc.Format != processor.FormatJSON {
+		return fmt.Errorf("unable to support parsing of SARIF document format: %v", doc.Format)
+	}
+	s.doc = doc
+	log, err := sarif.ParseLog(doc.Blob)
+	if err != nil {
+		return fmt.Errorf("failed to parse SARIF log: %w", err)
+	}
+	for i := range log.Runs {
+		run := &log.Runs[i]
+		for _, result := range run.Results {
+			s.addResult(run, result)
+		}
+	}
+	return nil
+}
+
+// addResult creates the node of the finding of the result for the package
+// or artifact it is about, and links the vulnerabilities its rule is about
+// to the package or artifact. Results that cannot be attached to a package
+// or an artifact are skipped.
+func (s *sarifParser) addResult(run *sarif.Run, result sarif.Result) {
+	target := s.getTarget(run, result)
+	if target == nil {
+		return
+	}
+	targetKey := common.VEXProductKey(target)
+	if !s.targetKeys[targetKey] {
+		s.targetKeys[targetKey] = true
+		s.targets = append(s.targets, target)
+	}
+
+	driver := run.Tool.Driver
+	rule := run.RuleOf(result)
+	ruleID := result.RuleID
+	if ruleID == "" && rule != nil {
+		ruleID = rule.ID
+	}
+	level := result.Level
+	if level == "" && rule != nil && rule.DefaultConfiguration != nil {
+		level = rule.DefaultConfiguration.Level
+	}
+	if level == "" {
+		level = defaultLevel
+	}
+	suppressed, justification := isSuppressed(result)
+	location := resultLocation(result)
+
+	finding := assembler.MetadataNode{
+		MetadataType: resultMetadataType,
+		ID:           fmt.Sprintf("%s:%s:%s:%s", targetKey, driver.Name, ruleID, resultKey(result, location)),
+		Details: map[string]interface{}{
+			"tool":         driver.Name,
+			"tool_version": driver.ToolVersion(),
+			"rule":         ruleID,
+			"level":        level,
+			"message":      result.Message.Text,
+			"location":     location,
+			"suppressed":   suppressed,
+		},
+	}
+	severity, hasSeverity := 0.0, false
+	if rule != nil {
+		if severity, hasSeverity = rule.SecuritySeverity(); hasSeverity {
+			finding.Details["security_severity"] = severity
+		}
+		if rule.HelpURI != "" {
+			finding.Details["help_uri"] = rule.HelpURI
+		}
+	}
+	s.findings = append(s.findings, finding)
+	s.edges = append(s.edges, metadataForEdge(finding, target))
+
+	status := statusAffected
+	if suppressed {
+		status = statusNotAffected
+	}
+	for _, id := range common.VulnerabilityIDs(ruleID) {
+		vuln := assembler.VulnerabilityNode{
+			ID:       id,
+			NodeData: *assembler.NewObjectMetadata(s.doc.SourceInformation),
+		}
+		if !s.vulnIDs[id] {
+			s.vulnIDs[id] = true
+			s.vulns = append(s.vulns, vuln)
+		}
+		affects := common.CreateAffectsEdge(vuln, target, status, justification)
+		if hasSeverity {
+			affects.Score = severity
+			affects.ScoreMethod = scoreMethodSecuritySeverity
+		}
+		s.edges = append(s.edges, affects)
+	}
+}
+
+// getTarget returns the node of what the result is about:
+//   - the package of the purl of the result, given in its `purl` property or
+//     as a location
+//   - else the artifact of its location, if the run gives its digests
+//   - else the repository at the revision the run analyzed
+func (s *sarifParser) getTarget(run *sarif.Run, result sarif.Result) assembler.GuacNode {
+	if purl := resultPurl(result); purl != "" {
+		return assembler.PackageNode{
+			Purl:     purl,
+			NodeData: *assembler.NewObjectMetadata(s.doc.SourceInformation),
+		}
+	}
+	for _, loc := range result.Locations {
+		if loc.PhysicalLocation == nil {
+			continue
+		}
+		artifact := run.ArtifactOf(loc.PhysicalLocation.ArtifactLocation)
+		if artifact == nil {
+			continue
+		}
+		digests := []string{}
+		for alg, value := range artifact.Hashes {
+			if value != "" {
+				digests = append(digests, common.DigestAlgorithm(alg)+":"+value)
+			}
+		}
+		if len(digests) == 0 {
+			continue
+		}
+		name := artifact.Location.URI
+		if name == "" {
+			name = loc.PhysicalLocation.ArtifactLocation.URI
+		}
+		node := assembler.ArtifactNode{
+			Name:     name,
+			NodeData: *assembler.NewObjectMetadata(s.doc.SourceInformation),
+		}
+		node.Digest, node.AlternateDigests = common.ArtifactDigests(digests)
+		return node
+	}
+	for _, vcs := range run.VersionControlProvenance {
+		if vcs.RepositoryURI == "" || vcs.RevisionID == "" {
+			continue
+		}
+		return assembler.ArtifactNode{
+			Name:     repositoryName(vcs.RepositoryURI),
+			Digest:   revisionDigest(vcs.RevisionID),
+			NodeData: *assembler.NewObjectMetadata(s.doc.SourceInformation),
+		}
+	}
+	return nil
+}
+
+// resultPurl returns the purl of the package the result is about, or an
+// empty string if it does not give one
+func resultPurl(result sarif.Result) string {
+	if purl, ok := result.Properties["purl"].(string); ok && strings.HasPrefix(purl, "pkg:") {
+		return purl
+	}
+	for _, loc := range result.Locations {
+		for _, logical := range loc.LogicalLocations {
+			for _, name := range []string{logical.FullyQualifiedName, logical.Name} {
+				if strings.HasPrefix(name, "pkg:") {
+					return name
+				}
+			}
+		}
+		if loc.PhysicalLocation != nil && strings.HasPrefix(loc.PhysicalLocation.ArtifactLocation.URI, "pkg:") {
+			return loc.PhysicalLocation.ArtifactLocation.URI
+		}
+	}
+	return ""
+}
+
+// resultLocation returns the first physical location of the result as
+// `uri:line`, or just the URI if it has no region
+func resultLocation(result sarif.Result) string {
+	for _, loc := range result.Locations {
+		if loc.PhysicalLocation == nil || loc.PhysicalLocation.ArtifactLocation.URI == "" {
+			continue
+		}
+		uri := loc.PhysicalLocation.ArtifactLocation.URI
+		if loc.PhysicalLocation.Region != nil && loc.PhysicalLocation.Region.StartLine > 0 {
+			return fmt.Sprintf("%s:%d", uri, loc.PhysicalLocation.Region.StartLine)
+		}
+		return uri
+	}
+	return ""
+}
+
+// resultKey identifies the result among the results of its rule: by its
+// fingerprint if the tool computes one, which is stable as the code around
+// the result moves, else by its location
+func resultKey(result sarif.Result, location string) string {
+	for _, fingerprints := range []map[string]string{result.Fingerprints, result.PartialFingerprints} {
+		keys := make([]string, 0, len(fingerprints))
+		for k := range fingerprints {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if fingerprints[k] != "" {
+				return fingerprints[k]
+			}
+		}
+	}
+	return location
+}
+
+// isSuppressed reports whether the result is suppressed, e.g. dismissed as
+// a false positive, with the justification of the suppression
+func isSuppressed(result sarif.Result) (bool, string) {
+	for _, suppression := range result.Suppressions {
+		if suppression.Accepted() {
+			return true, suppression.Justification
+		}
+	}
+	return false, ""
+}
+
+// repositoryName names the artifact of a revision of a repository as the
+// scorecard parser does, e.g. git+https://github.com/guacsec/guac
+func repositoryName(uri string) string {
+	uri = strings.TrimSuffix(uri, ".git")
+	if strings.HasPrefix(uri, "git+") {
+		return uri
+	}
+	return "git+" + uri
+}
+
+// revisionDigest returns the digest of a git revision
+func revisionDigest(revision string) string {
+	switch len(revision) {
+	case 40:
+		return "sha1:" + revision
+	case 64:
+		return "sha256:" + revision
+	}
+	return revision
+}
+
+func metadataForEdge(finding assembler.MetadataNode, target assembler.GuacNode) assembler.MetadataForEdge {
+	e := assembler.MetadataForEdge{MetadataNode: finding}
+	switch t := target.(type) {
+	case assembler.ArtifactNode:
+		e.ForArtifact = t
+	case assembler.PackageNode:
+		e.ForPackage = t
+	}
+	return e
+}
+
+// CreateNodes creates the GuacNode for the graph inputs
+func (s *sarifParser) CreateNodes(ctx context.Context) []assembler.GuacNode {
+	nodes := []assembler.GuacNode{}
+	nodes = append(nodes, s.targets...)
+	for _, f := range s.findings {
+		nodes = append(nodes, f)
+	}
+	for _, v := range s.vulns {
+		nodes = append(nodes, v)
+	}
+	return nodes
+}
+
+// CreateEdges creates the GuacEdges that form the relationship for the graph inputs
+func (s *sarifParser) CreateEdges(ctx context.Context, foundIdentities []assembler.IdentityNode) []assembler.GuacEdge {
+	return s.edges
+}
+
+// GetIdentities gets the identity node from the document if they exist
+func (s *sarifParser) GetIdentities(ctx context.Context) []assembler.IdentityNode {
+	return nil
+}
@@ -0,0 +1,226 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sarif
+
+import (
+	"context"
+	"testing"
+
+	"github.com/guacsec/guac/internal/testing/testdata"
+	"github.com/guacsec/guac/pkg/assembler"
+	"github.com/guacsec/guac/pkg/handler/processor"
+)
+
+func Test_sarifParser(t *testing.T) {
+	ctx := context.Background()
+	source := processor.SourceInformation{Collector: "TestCollector", Source: "TestSource"}
+	nodeData := *assembler.NewObjectMetadata(source)
+
+	repo := assembler.ArtifactNode{
+		Name:     "git+https://github.com/guacsec/guac",
+		Digest:   "sha1:6b4fe20a7f3a2e0b0f1a5c1e9d8c7b6a5f4e3d2c",
+		NodeData: nodeData,
+	}
+	krb5 := assembler.PackageNode{
+		Purl:     "pkg:apk/alpine/krb5-libs@1.19.3-r0?arch=x86_64&distro=3.16.2",
+		NodeData: nodeData,
+	}
+	guacone := assembler.ArtifactNode{
+		Name:     "usr/local/bin/guacone",
+		Digest:   "sha256:5d2c0b6ef0a6b2a2c1d4e7f8a9b0c1d2e3f4a5b6c7d8e9f0a1b2c3d4e5f6a7b8",
+		NodeData: nodeData,
+	}
+	sqlInjection := assembler.MetadataNode{
+		MetadataType: "sarif_result",
+		ID:           "sha1:6b4fe20a7f3a2e0b0f1a5c1e9d8c7b6a5f4e3d2c:CodeQL:go/sql-injection:3f2a1b0c9d8e7f60:1",
+		Details: map[string]interface{}{
+			"tool":              "CodeQL",
+			"tool_version":      "2.12.1",
+			"rule":              "go/sql-injection",
+			"level":             "error",
+			"message":           "This query depends on a user-provided value.",
+			"location":          "pkg/db/query.go:42",
+			"suppressed":        false,
+			"security_severity": 8.8,
+		},
+	}
+	logInjection := assembler.MetadataNode{
+		MetadataType: "sarif_result",
+		ID:           "sha1:6b4fe20a7f3a2e0b0f1a5c1e9d8c7b6a5f4e3d2c:CodeQL:go/log-injection:pkg/db/query.go:57",
+		Details: map[string]interface{}{
+			"tool":              "CodeQL",
+			"tool_version":      "2.12.1",
+			"rule":              "go/log-injection",
+			"level":             "warning",
+			"message":           "This log entry depends on a user-provided value.",
+			"location":          "pkg/db/query.go:57",
+			"suppressed":        true,
+			"security_severity": 7.8,
+		},
+	}
+	krb5Finding := assembler.MetadataNode{
+		MetadataType: "sarif_result",
+		ID:           "pkg:apk/alpine/krb5-libs@1.19.3-r0?arch=x86_64&distro=3.16.2:Trivy:CVE-2022-42898:library/alpine",
+		Details: map[string]interface{}{
+			"tool":              "Trivy",
+			"tool_version":      "0.37.1",
+			"rule":              "CVE-2022-42898",
+			"level":             "error",
+			"message":           "Package: krb5-libs\nInstalled Version: 1.19.3-r0\nVulnerability CVE-2022-42898\nSeverity: HIGH\nFixed Version: 1.19.4-r0",
+			"location":          "library/alpine",
+			"suppressed":        false,
+			"security_severity": 8.8,
+			"help_uri":          "https://avd.aquasec.com/nvd/cve-2022-42898",
+		},
+	}
+	netFinding := assembler.MetadataNode{
+		MetadataType: "sarif_result",
+		ID:           "sha256:5d2c0b6ef0a6b2a2c1d4e7f8a9b0c1d2e3f4a5b6c7d8e9f0a1b2c3d4e5f6a7b8:Trivy:CVE-2022-41723:usr/local/bin/guacone",
+		Details: map[string]interface{}{
+			"tool":              "Trivy",
+			"tool_version":      "0.37.1",
+			"rule":              "CVE-2022-41723",
+			"level":             "warning",
+			"message":           "Package: golang.org/x/net\nInstalled Version: v0.5.0\nVulnerability CVE-2022-41723\nSeverity: HIGH\nFixed Version: 0.7.0",
+			"location":          "usr/local/bin/guacone",
+			"suppressed":        false,
+			"security_severity": 7.5,
+			"help_uri":          "https://avd.aquasec.com/nvd/cve-2022-41723",
+		},
+	}
+	cve42898 := assembler.VulnerabilityNode{ID: "CVE-2022-42898", NodeData: nodeData}
+	cve41723 := assembler.VulnerabilityNode{ID: "CVE-2022-41723", NodeData: nodeData}
+
+	tests := []struct {
+		name      string
+		doc       *processor.Document
+		wantNodes []assembler.GuacNode
+		wantEdges []assembler.GuacEdge
+		wantErr   bool
+	}{{
+		name: "code findings and vulnerabilities",
+		doc: &processor.Document{
+			Blob:              testdata.SARIFExample,
+			Format:            processor.FormatJSON,
+			Type:              processor.DocumentSARIF,
+			SourceInformation: source,
+		},
+		wantNodes: []assembler.GuacNode{
+			repo, krb5, guacone, sqlInjection, logInjection, krb5Finding, netFinding, cve42898, cve41723,
+		},
+		wantEdges: []assembler.GuacEdge{
+			assembler.MetadataForEdge{MetadataNode: sqlInjection, ForArtifact: repo},
+			assembler.MetadataForEdge{MetadataNode: logInjection, ForArtifact: repo},
+			assembler.MetadataForEdge{MetadataNode: krb5Finding, ForPackage: krb5},
+			assembler.AffectsEdge{VulnerabilityNode: cve42898, PackageNode: krb5, AnalysisState: "affected", Score: 8.8, ScoreMethod: "security-severity"},
+			assembler.MetadataForEdge{MetadataNode: netFinding, ForArtifact: guacone},
+			assembler.AffectsEdge{VulnerabilityNode: cve41723, ArtifactNode: guacone, AnalysisState: "affected", Score: 7.5, ScoreMethod: "security-severity"},
+		},
+	}, {
+		name: "suppressed vulnerability without rules or a target",
+		doc: &processor.Document{
+			Blob: []byte(`{
+				"version": "2.1.0",
+				"runs": [{
+					"tool": {"driver": {"name": "grype", "version": "0.56.0"}},
+					"results": [
+						{
+							"ruleId": "GHSA-69CG-P879-7622-golang.org/x/net",
+							"message": {"text": "not reachable"},
+							"locations": [{"logicalLocations": [{"fullyQualifiedName": "pkg:golang/golang.org/x/net@v0.5.0"}]}],
+							"suppressions": [{"kind": "inSource", "justification": "vulnerable_code_not_in_execute_path"}]
+						},
+						{
+							"ruleId": "CVE-2022-1234",
+							"message": {"text": "no target"},
+							"locations": [{"physicalLocation": {"artifactLocation": {"uri": "README.md"}}}]
+						}
+					]
+				}]
+			}`),
+			Format:            processor.FormatJSON,
+			Type:              processor.DocumentSARIF,
+			SourceInformation: source,
+		},
+		wantNodes: []assembler.GuacNode{
+			assembler.PackageNode{Purl: "pkg:golang/golang.org/x/net@v0.5.0", NodeData: nodeData},
+			assembler.MetadataNode{
+				MetadataType: "sarif_result",
+				ID:           "pkg:golang/golang.org/x/net@v0.5.0:grype:GHSA-69CG-P879-7622-golang.org/x/net:",
+				Details: map[string]interface{}{
+					"tool":         "grype",
+					"tool_version": "0.56.0",
+					"rule":         "GHSA-69CG-P879-7622-golang.org/x/net",
+					"level":        "warning",
+					"message":      "not reachable",
+					"location":     "",
+					"suppressed":   true,
+				},
+			},
+			assembler.VulnerabilityNode{ID: "GHSA-69cg-p879-7622", NodeData: nodeData},
+		},
+		wantEdges: []assembler.GuacEdge{
+			assembler.MetadataForEdge{
+				MetadataNode: assembler.MetadataNode{
+					MetadataType: "sarif_result",
+					ID:           "pkg:golang/golang.org/x/net@v0.5.0:grype:GHSA-69CG-P879-7622-golang.org/x/net:",
+					Details: map[string]interface{}{
+						"tool":         "grype",
+						"tool_version": "0.56.0",
+						"rule":         "GHSA-69CG-P879-7622-golang.org/x/net",
+						"level":        "warning",
+						"message":      "not reachable",
+						"location":     "",
+						"suppressed":   true,
+					},
+				},
+				ForPackage: assembler.PackageNode{Purl: "pkg:golang/golang.org/x/net@v0.5.0", NodeData: nodeData},
+			},
+			assembler.AffectsEdge{
+				VulnerabilityNode: assembler.VulnerabilityNode{ID: "GHSA-69cg-p879-7622", NodeData: nodeData},
+				PackageNode:       assembler.PackageNode{Purl: "pkg:golang/golang.org/x/net@v0.5.0", NodeData: nodeData},
+				AnalysisState:     "not_affected",
+				Justification:     "vulnerable_code_not_in_execute_path",
+			},
+		},
+	}, {
+		name: "wrong document type",
+		doc: &processor.Document{
+			Blob:   testdata.SARIFExample,
+			Format: processor.FormatJSON,
+			Type:   processor.DocumentSPDX,
+		},
+		wantErr: true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewSARIFParser()
+			err := p.Parse(ctx, tt.doc)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("sarifParser.Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if nodes := p.CreateNodes(ctx); !testdata.GuacNodeSliceEqual(nodes, tt.wantNodes) {
+				t.Errorf("sarifParser.CreateNodes() = %v, want %v", nodes, tt.wantNodes)
+			}
+			if edges := p.CreateEdges(ctx, nil); !testdata.GuacEdgeSliceEqual(edges, tt.wantEdges) {
+				t.Errorf("sarifParser.CreateEdges() = %v, want %v", edges, tt.wantEdges)
+			}
+		})
+	}
+}
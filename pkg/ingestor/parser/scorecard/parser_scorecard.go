@@ -23,6 +23,7 @@ import (
 
 	"github.com/guacsec/guac/pkg/assembler"
 	"github.com/guacsec/guac/pkg/handler/processor"
+	"github.com/guacsec/guac/pkg/handler/processor/scorecard"
 	"github.com/guacsec/guac/pkg/ingestor/parser/common"
 	sc "github.com/ossf/scorecard/v4/pkg"
 )
@@ -32,6 +33,12 @@ type scorecardParser struct {
 	// artifactNode should have a 1:1 mapping to the index
 	// of scorecardNodes.
 	artifactNodes []assembler.ArtifactNode
+	// checkNodes are the results of the checks of the scorecards, for the
+	// artifact of the same index
+	checkNodes [][]assembler.MetadataNode
+	// packageNodes are the packages built from the scored repositories, for
+	// the scorecard of the same index
+	packageNodes [][]assembler.PackageNode
 }
 
 // NewSLSAParser initializes the slsaParser
@@ -39,6 +46,8 @@ func NewScorecardParser() common.DocumentParser {
 	return &scorecardParser{
 		scorecardNodes: []assembler.MetadataNode{},
 		artifactNodes:  []assembler.ArtifactNode{},
+		checkNodes:     [][]assembler.MetadataNode{},
+		packageNodes:   [][]assembler.PackageNode{},
 	}
 }
 
@@ -57,6 +66,8 @@ func (p *scorecardParser) Parse(ctx context.Context, doc *processor.Document) er
 		}
 		p.scorecardNodes = append(p.scorecardNodes, getMetadataNode(&scorecard))
 		p.artifactNodes = append(p.artifactNodes, getArtifactNode(&scorecard))
+		p.checkNodes = append(p.checkNodes, getCheckNodes(&scorecard))
+		p.packageNodes = append(p.packageNodes, getPackageNodes(&scorecard))
 		return nil
 	}
 	return fmt.Errorf("unable to support parsing of Scorecard document format: %v", doc.Format)
@@ -71,6 +82,16 @@ func (p *scorecardParser) CreateNodes(ctx context.Context) []assembler.GuacNode
 	for _, n := range p.artifactNodes {
 		nodes = append(nodes, n)
 	}
+	for _, checks := range p.checkNodes {
+		for _, n := range checks {
+			nodes = append(nodes, n)
+		}
+	}
+	for _, pkgs := range p.packageNodes {
+		for _, n := range pkgs {
+			nodes = append(nodes, n)
+		}
+	}
 
 	return nodes
 }
@@ -84,6 +105,18 @@ func (p *scorecardParser) CreateEdges(ctx context.Context, foundIdentities []ass
 			MetadataNode: s,
 			ForArtifact:  p.artifactNodes[i],
 		})
+		for _, c := range p.checkNodes[i] {
+			edges = append(edges, assembler.MetadataForEdge{
+				MetadataNode: c,
+				ForArtifact:  p.artifactNodes[i],
+			})
+		}
+		for _, pkg := range p.packageNodes[i] {
+			edges = append(edges, assembler.MetadataForEdge{
+				MetadataNode: s,
+				ForPackage:   pkg,
+			})
+		}
 	}
 	return edges
 }
@@ -116,6 +149,38 @@ func getMetadataNode(s *sc.JSONScorecardResultV2) assembler.MetadataNode {
 	return mnNode
 }
 
+// getCheckNodes returns a node for the result of each check of the
+// scorecard, identified by the repository, commit and check name
+func getCheckNodes(s *sc.JSONScorecardResultV2) []assembler.MetadataNode {
+	checks := []assembler.MetadataNode{}
+	for _, c := range s.Checks {
+		checks = append(checks, assembler.MetadataNode{
+			MetadataType: "scorecard_check",
+			ID:           fmt.Sprintf("%v:%v", metadataId(s), c.Name),
+			Details: map[string]interface{}{
+				"check":             c.Name,
+				"score":             c.Score,
+				"reason":            c.Reason,
+				"documentation":     c.Doc.URL,
+				"commit":            hashToDigest(s.Repo.Commit),
+				"scorecard_version": s.Scorecard.Version,
+				"scorecard_commit":  hashToDigest(s.Scorecard.Commit),
+			},
+		})
+	}
+	return checks
+}
+
+// getPackageNodes returns the packages the metadata of the scorecard names as
+// built from the scored repository
+func getPackageNodes(s *sc.JSONScorecardResultV2) []assembler.PackageNode {
+	pkgs := []assembler.PackageNode{}
+	for _, purl := range scorecard.Packages(s) {
+		pkgs = append(pkgs, assembler.PackageNode{Purl: purl})
+	}
+	return pkgs
+}
+
 func getArtifactNode(s *sc.JSONScorecardResultV2) assembler.ArtifactNode {
 	return assembler.ArtifactNode{
 		Name:   sourceUri(s.Repo.Name),
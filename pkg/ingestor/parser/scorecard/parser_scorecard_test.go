@@ -17,7 +17,9 @@ package scorecard
 
 import (
 	"context"
+	"encoding/json"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/guacsec/guac/internal/testing/testdata"
@@ -26,8 +28,101 @@ import (
 	"github.com/guacsec/guac/pkg/logging"
 )
 
+var (
+	k8sScorecard = assembler.MetadataNode{
+		MetadataType: "scorecard",
+		ID:           "github.com/kubernetes/kubernetes:5835544ca568b757a8ecae5c153f317e5736700e",
+		Details: map[string]interface{}{
+			"repo":                "git+https://github.com/kubernetes/kubernetes",
+			"commit":              "sha1:5835544ca568b757a8ecae5c153f317e5736700e",
+			"scorecard_version":   "v4.7.0",
+			"scorecard_commit":    "sha1:7cd6406aef0b80a819402e631919293d5eb6adcf",
+			"score":               8.9,
+			"Binary_Artifacts":    10,
+			"CI_Tests":            10,
+			"Code_Review":         7,
+			"Dangerous_Workflow":  10,
+			"License":             10,
+			"Pinned_Dependencies": 2,
+			"Security_Policy":     10,
+			"Token_Permissions":   10,
+			"Vulnerabilities":     10,
+		},
+	}
+	k8sArtifact = assembler.ArtifactNode{
+		Name:   "git+https://github.com/kubernetes/kubernetes",
+		Digest: "sha1:5835544ca568b757a8ecae5c153f317e5736700e",
+	}
+	k8sChecks = []assembler.MetadataNode{
+		k8sCheck("Binary-Artifacts", 10, "no binaries found in the repo"),
+		k8sCheck("CI-Tests", 10, "26 out of 26 merged PRs checked by a CI test -- score normalized to 10"),
+		k8sCheck("Code-Review", 7, "16 out of last 16 changesets reviewed before merge -- score normalized to 7"),
+		k8sCheck("Dangerous-Workflow", 10, "no dangerous workflow patterns detected"),
+		k8sCheck("License", 10, "license file detected"),
+		k8sCheck("Pinned-Dependencies", 2, "dependency not pinned by hash detected -- score normalized to 2"),
+		k8sCheck("Security-Policy", 10, "security policy file detected"),
+		k8sCheck("Token-Permissions", 10, "tokens are read-only in GitHub workflows"),
+		k8sCheck("Vulnerabilities", 10, "no vulnerabilities detected"),
+	}
+)
+
+func k8sCheck(name string, score int, reason string) assembler.MetadataNode {
+	return assembler.MetadataNode{
+		MetadataType: "scorecard_check",
+		ID:           "github.com/kubernetes/kubernetes:5835544ca568b757a8ecae5c153f317e5736700e:" + name,
+		Details: map[string]interface{}{
+			"check":             name,
+			"score":             score,
+			"reason":            reason,
+			"documentation":     "https://github.com/ossf/scorecard/blob/7cd6406aef0b80a819402e631919293d5eb6adcf/docs/checks.md#" + strings.ToLower(name),
+			"commit":            "sha1:5835544ca568b757a8ecae5c153f317e5736700e",
+			"scorecard_version": "v4.7.0",
+			"scorecard_commit":  "sha1:7cd6406aef0b80a819402e631919293d5eb6adcf",
+		},
+	}
+}
+
+func k8sNodes(pkgs ...assembler.PackageNode) []assembler.GuacNode {
+	nodes := []assembler.GuacNode{k8sScorecard, k8sArtifact}
+	for _, c := range k8sChecks {
+		nodes = append(nodes, c)
+	}
+	for _, pkg := range pkgs {
+		nodes = append(nodes, pkg)
+	}
+	return nodes
+}
+
+func k8sEdges(pkgs ...assembler.PackageNode) []assembler.GuacEdge {
+	edges := []assembler.GuacEdge{
+		assembler.MetadataForEdge{MetadataNode: k8sScorecard, ForArtifact: k8sArtifact},
+	}
+	for _, c := range k8sChecks {
+		edges = append(edges, assembler.MetadataForEdge{MetadataNode: c, ForArtifact: k8sArtifact})
+	}
+	for _, pkg := range pkgs {
+		edges = append(edges, assembler.MetadataForEdge{MetadataNode: k8sScorecard, ForPackage: pkg})
+	}
+	return edges
+}
+
+// withMetadata returns the example scorecard with the given metadata
+func withMetadata(t *testing.T, metadata ...string) []byte {
+	var result map[string]interface{}
+	if err := json.Unmarshal(testdata.ScorecardExample, &result); err != nil {
+		t.Fatal(err)
+	}
+	result["metadata"] = metadata
+	blob, err := json.Marshal(result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return blob
+}
+
 func Test_scorecardParser(t *testing.T) {
 	ctx := logging.WithLogger(context.Background())
+	kubectl := assembler.PackageNode{Purl: "pkg:golang/github.com/kubernetes/kubernetes@v1.26.0"}
 	tests := []struct {
 		name      string
 		doc       *processor.Document
@@ -42,61 +137,20 @@ func Test_scorecardParser(t *testing.T) {
 			Format:            processor.FormatJSON,
 			SourceInformation: processor.SourceInformation{},
 		},
-		wantNodes: []assembler.GuacNode{
-			assembler.MetadataNode{
-				MetadataType: "scorecard",
-				ID:           "github.com/kubernetes/kubernetes:5835544ca568b757a8ecae5c153f317e5736700e",
-				Details: map[string]interface{}{
-					"repo":                "git+https://github.com/kubernetes/kubernetes",
-					"commit":              "sha1:5835544ca568b757a8ecae5c153f317e5736700e",
-					"scorecard_version":   "v4.7.0",
-					"scorecard_commit":    "sha1:7cd6406aef0b80a819402e631919293d5eb6adcf",
-					"score":               8.9,
-					"Binary_Artifacts":    10,
-					"CI_Tests":            10,
-					"Code_Review":         7,
-					"Dangerous_Workflow":  10,
-					"License":             10,
-					"Pinned_Dependencies": 2,
-					"Security_Policy":     10,
-					"Token_Permissions":   10,
-					"Vulnerabilities":     10,
-				},
-			},
-			assembler.ArtifactNode{
-				Name:   "git+https://github.com/kubernetes/kubernetes",
-				Digest: "sha1:5835544ca568b757a8ecae5c153f317e5736700e",
-			},
-		},
-		wantEdges: []assembler.GuacEdge{
-			assembler.MetadataForEdge{
-				MetadataNode: assembler.MetadataNode{
-					MetadataType: "scorecard",
-					ID:           "github.com/kubernetes/kubernetes:5835544ca568b757a8ecae5c153f317e5736700e",
-					Details: map[string]interface{}{
-						"repo":                "git+https://github.com/kubernetes/kubernetes",
-						"commit":              "sha1:5835544ca568b757a8ecae5c153f317e5736700e",
-						"scorecard_version":   "v4.7.0",
-						"scorecard_commit":    "sha1:7cd6406aef0b80a819402e631919293d5eb6adcf",
-						"score":               8.9,
-						"Binary_Artifacts":    10,
-						"CI_Tests":            10,
-						"Code_Review":         7,
-						"Dangerous_Workflow":  10,
-						"License":             10,
-						"Pinned_Dependencies": 2,
-						"Security_Policy":     10,
-						"Token_Permissions":   10,
-						"Vulnerabilities":     10,
-					},
-				},
-				ForArtifact: assembler.ArtifactNode{
-					Name:   "git+https://github.com/kubernetes/kubernetes",
-					Digest: "sha1:5835544ca568b757a8ecae5c153f317e5736700e",
-				},
-			},
+		wantNodes: k8sNodes(),
+		wantEdges: k8sEdges(),
+		wantErr:   false,
+	}, {
+		name: "packages in metadata",
+		doc: &processor.Document{
+			Blob:              withMetadata(t, "team=sig-release", "package="+kubectl.Purl),
+			Type:              processor.DocumentScorecard,
+			Format:            processor.FormatJSON,
+			SourceInformation: processor.SourceInformation{},
 		},
-		wantErr: false,
+		wantNodes: k8sNodes(kubectl),
+		wantEdges: k8sEdges(kubectl),
+		wantErr:   false,
 	}}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -22,6 +22,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/guacsec/guac/pkg/assembler"
 	"github.com/guacsec/guac/pkg/handler/processor"
@@ -31,23 +32,74 @@ import (
 
 const (
 	algorithmSHA256 string = "sha256"
+	// predicateSLSAProvenanceV1 is the predicate type of SLSA v1.0
+	// provenance, which moved the builder and materials of v0.2 under
+	// `runDetails` and `buildDefinition`
+	predicateSLSAProvenanceV1 string = "https://slsa.dev/provenance/v1"
 )
 
+// resourceDescriptorV1 is an artifact referenced by SLSA v1.0 provenance
+type resourceDescriptorV1 struct {
+	URI    string            `json:"uri"`
+	Name   string            `json:"name"`
+	Digest map[string]string `json:"digest"`
+}
+
+// provenanceV1 is the part of a SLSA v1.0 provenance statement that the
+// v0.2 in_toto.ProvenanceStatement does not decode
+type provenanceV1 struct {
+	Predicate struct {
+		BuildDefinition struct {
+			BuildType            string                 `json:"buildType"`
+			ExternalParameters   interface{}            `json:"externalParameters"`
+			ResolvedDependencies []resourceDescriptorV1 `json:"resolvedDependencies"`
+		} `json:"buildDefinition"`
+		RunDetails struct {
+			Builder struct {
+				ID string `json:"id"`
+			} `json:"builder"`
+			Byproducts []resourceDescriptorV1 `json:"byproducts"`
+			Metadata   struct {
+				StartedOn  *time.Time `json:"startedOn"`
+				FinishedOn *time.Time `json:"finishedOn"`
+			} `json:"metadata"`
+		} `json:"runDetails"`
+	} `json:"predicate"`
+}
+
+// build is the builder of the provenance and the build it ran
+type build struct {
+	builder    assembler.BuilderNode
+	buildType  string
+	parameters string
+}
+
+// packageKey identifies the package of a subject by the purl naming the
+// subject and its digest
+type packageKey struct {
+	purl   string
+	digest string
+}
+
 type slsaParser struct {
 	doc          *processor.Document
 	subjects     []assembler.ArtifactNode
+	packages     map[packageKey]assembler.PackageNode
 	dependencies []assembler.ArtifactNode
+	byproducts   []assembler.ArtifactNode
 	attestations []assembler.AttestationNode
-	builders     []assembler.BuilderNode
+	builders     []build
 }
 
 // NewSLSAParser initializes the slsaParser
 func NewSLSAParser() common.DocumentParser {
 	return &slsaParser{
 		subjects:     []assembler.ArtifactNode{},
+		packages:     map[packageKey]assembler.PackageNode{},
 		dependencies: []assembler.ArtifactNode{},
+		byproducts:   []assembler.ArtifactNode{},
 		attestations: []assembler.AttestationNode{},
-		builders:     []assembler.BuilderNode{},
+		builders:     []build{},
 	}
 }
 
@@ -59,43 +111,170 @@ func (s *slsaParser) Parse(ctx context.Context, doc *processor.Document) error {
 		return fmt.Errorf("failed to parse slsa predicate: %w", err)
 	}
 	s.getSubject(statement)
+	if statement.PredicateType == predicateSLSAProvenanceV1 {
+		v1 := provenanceV1{}
+		if err := json.Unmarshal(doc.Blob, &v1); err != nil {
+			return fmt.Errorf("failed to parse slsa v1 predicate: %w", err)
+		}
+		metadata := v1.Predicate.RunDetails.Metadata
+		s.getAttestation(doc.Blob, buildTime(metadata.StartedOn, metadata.FinishedOn))
+		s.getDependencyV1(&v1)
+		s.getByproductsV1(&v1)
+		s.getBuilderV1(&v1)
+		return nil
+	}
+	if metadata := statement.Predicate.Metadata; metadata != nil {
+		s.getAttestation(doc.Blob, buildTime(metadata.BuildStartedOn, metadata.BuildFinishedOn))
+	} else {
+		s.getAttestation(doc.Blob, time.Time{})
+	}
 	s.getDependency(statement)
-	s.getAttestation(doc.Blob)
 	s.getBuilder(statement)
 	return nil
 }
 
+// buildTime returns the time the build finished, or started if the provenance
+// does not say when it finished, zero if it says neither
+func buildTime(startedOn *time.Time, finishedOn *time.Time) time.Time {
+	if finishedOn != nil {
+		return *finishedOn
+	}
+	if startedOn != nil {
+		return *startedOn
+	}
+	return time.Time{}
+}
+
 func (s *slsaParser) getSubject(statement *in_toto.ProvenanceStatement) {
 	// append artifact node for the subjects
 	for _, sub := range statement.Subject {
-		for alg, ds := range sub.Digest {
-			s.subjects = append(s.subjects, assembler.ArtifactNode{
-				Name: sub.Name, Digest: alg + ":" + strings.Trim(ds, "'"), NodeData: *assembler.NewObjectMetadata(s.doc.SourceInformation)})
+		digests := getDigests(sub.Digest)
+		if len(digests) == 0 {
+			continue
+		}
+		digest, alternates := common.ArtifactDigests(digests)
+		s.subjects = append(s.subjects, assembler.ArtifactNode{
+			Name: sub.Name, Digest: digest, AlternateDigests: alternates, NodeData: *assembler.NewObjectMetadata(s.doc.SourceInformation)})
+		// Packages are merged on their purl, so a subject named by its purl
+		// gets a stub package that a later SBOM for the package merges into.
+		if isPurl(sub.Name) {
+			s.packages[packageKey{purl: sub.Name, digest: digest}] = assembler.PackageNode{
+				Purl: sub.Name, Digest: append([]string{digest}, alternates...), NodeData: *assembler.NewObjectMetadata(s.doc.SourceInformation)}
 		}
 	}
 }
 
+// isPurl reports whether name looks like a package URL
+// (`pkg:type/namespace/name@version`)
+func isPurl(name string) bool {
+	rest := strings.TrimPrefix(name, "pkg:")
+	if rest == name {
+		return false
+	}
+	purlType, purlName, found := strings.Cut(rest, "/")
+	return found && purlType != "" && purlName != ""
+}
+
 func (s *slsaParser) getDependency(statement *in_toto.ProvenanceStatement) {
 	// append dependency nodes for the materials
 	for _, mat := range statement.Predicate.Materials {
-		for alg, ds := range mat.Digest {
+		digests := getDigests(mat.Digest)
+		if len(digests) == 0 {
+			continue
+		}
+		digest, alternates := common.ArtifactDigests(digests)
+		s.dependencies = append(s.dependencies, assembler.ArtifactNode{
+			Name: mat.URI, Digest: digest, AlternateDigests: alternates, NodeData: *assembler.NewObjectMetadata(s.doc.SourceInformation)})
+	}
+}
+
+func (s *slsaParser) getDependencyV1(v1 *provenanceV1) {
+	// append dependency nodes for the resolved dependencies
+	s.dependencies = append(s.dependencies, s.resourceArtifacts(v1.Predicate.BuildDefinition.ResolvedDependencies)...)
+}
+
+func (s *slsaParser) getByproductsV1(v1 *provenanceV1) {
+	// append artifact nodes for the byproducts, such as logs or SBOMs,
+	// that the build produced besides its subjects
+	s.byproducts = append(s.byproducts, s.resourceArtifacts(v1.Predicate.RunDetails.Byproducts)...)
+}
 
-			s.dependencies = append(s.dependencies, assembler.ArtifactNode{
-				Name: mat.URI, Digest: alg + ":" + strings.Trim(ds, "'"), NodeData: *assembler.NewObjectMetadata(s.doc.SourceInformation)})
+// resourceArtifacts returns the artifact nodes of the resource descriptors
+// that have a digest, named by their uri or else their name
+func (s *slsaParser) resourceArtifacts(resources []resourceDescriptorV1) []assembler.ArtifactNode {
+	artifacts := []assembler.ArtifactNode{}
+	for _, res := range resources {
+		digests := getDigests(res.Digest)
+		if len(digests) == 0 {
+			continue
+		}
+		name := res.URI
+		if name == "" {
+			name = res.Name
 		}
+		digest, alternates := common.ArtifactDigests(digests)
+		artifacts = append(artifacts, assembler.ArtifactNode{
+			Name: name, Digest: digest, AlternateDigests: alternates, NodeData: *assembler.NewObjectMetadata(s.doc.SourceInformation)})
 	}
+	return artifacts
 }
 
-func (s *slsaParser) getAttestation(blob []byte) {
+// getDigests returns the digest set of a subject or material as
+// `algorithm:value` digests, leaving out the empty ones
+func getDigests(set map[string]string) []string {
+	digests := []string{}
+	for alg, ds := range set {
+		ds = strings.Trim(ds, "'")
+		if alg == "" || ds == "" {
+			continue
+		}
+		digests = append(digests, alg+":"+ds)
+	}
+	return digests
+}
+
+func (s *slsaParser) getAttestation(blob []byte, statementTime time.Time) {
 	h := sha256.Sum256(blob)
 	s.attestations = append(s.attestations, assembler.AttestationNode{
-		FilePath: s.doc.SourceInformation.Source, Digest: algorithmSHA256 + ":" + hex.EncodeToString(h[:]), NodeData: *assembler.NewObjectMetadata(s.doc.SourceInformation)})
+		FilePath: s.doc.SourceInformation.Source, Digest: algorithmSHA256 + ":" + hex.EncodeToString(h[:]),
+		StatementTime: statementTime, NodeData: *assembler.NewObjectMetadata(s.doc.SourceInformation)})
 }
 
 func (s *slsaParser) getBuilder(statement *in_toto.ProvenanceStatement) {
 	// append builder node for builder
-	s.builders = append(s.builders, assembler.BuilderNode{
-		BuilderType: statement.Predicate.BuildType, BuilderId: statement.Predicate.Builder.ID, NodeData: *assembler.NewObjectMetadata(s.doc.SourceInformation)})
+	s.addBuilder(statement.Predicate.Builder.ID, statement.Predicate.BuildType, statement.Predicate.Invocation.Parameters)
+}
+
+func (s *slsaParser) getBuilderV1(v1 *provenanceV1) {
+	// the external parameters are the v1.0 equivalent of the v0.2
+	// invocation parameters
+	s.addBuilder(v1.Predicate.RunDetails.Builder.ID, v1.Predicate.BuildDefinition.BuildType, v1.Predicate.BuildDefinition.ExternalParameters)
+}
+
+// addBuilder appends the builder with the given id, if any, and the build of
+// the given type and parameters it ran
+func (s *slsaParser) addBuilder(id string, buildType string, parameters interface{}) {
+	if id == "" {
+		return
+	}
+	s.builders = append(s.builders, build{
+		builder:    assembler.BuilderNode{BuilderId: id, NodeData: *assembler.NewObjectMetadata(s.doc.SourceInformation)},
+		buildType:  buildType,
+		parameters: encodeParameters(parameters),
+	})
+}
+
+// encodeParameters returns the parameters as JSON, or an empty string if
+// there are none
+func encodeParameters(parameters interface{}) string {
+	if parameters == nil {
+		return ""
+	}
+	encoded, err := json.Marshal(parameters)
+	if err != nil {
+		return ""
+	}
+	return string(encoded)
 }
 
 func parseSlsaPredicate(p []byte) (*in_toto.ProvenanceStatement, error) {
@@ -111,6 +290,9 @@ func (s *slsaParser) CreateNodes(ctx context.Context) []assembler.GuacNode {
 	nodes := []assembler.GuacNode{}
 	for _, sub := range s.subjects {
 		nodes = append(nodes, sub)
+		if pkg, ok := s.packages[packageKey{purl: sub.Name, digest: sub.Digest}]; ok {
+			nodes = append(nodes, pkg)
+		}
 	}
 	for _, a := range s.attestations {
 		nodes = append(nodes, a)
@@ -118,8 +300,11 @@ func (s *slsaParser) CreateNodes(ctx context.Context) []assembler.GuacNode {
 	for _, d := range s.dependencies {
 		nodes = append(nodes, d)
 	}
+	for _, bp := range s.byproducts {
+		nodes = append(nodes, bp)
+	}
 	for _, b := range s.builders {
-		nodes = append(nodes, b)
+		nodes = append(nodes, b.builder)
 	}
 	return nodes
 }
@@ -132,17 +317,39 @@ func (s *slsaParser) CreateEdges(ctx context.Context, foundIdentities []assemble
 			edges = append(edges, assembler.IdentityForEdge{IdentityNode: i, AttestationNode: a})
 		}
 	}
+	for _, b := range s.builders {
+		for _, a := range s.attestations {
+			edges = append(edges, assembler.BuiltByEdge{AttestationNode: a, BuilderNode: b.builder, BuildType: b.buildType, Parameters: b.parameters})
+		}
+	}
 	for _, sub := range s.subjects {
-		for _, build := range s.builders {
-			edges = append(edges, assembler.BuiltByEdge{ArtifactNode: sub, BuilderNode: build})
+		for _, b := range s.builders {
+			edges = append(edges, assembler.BuiltByEdge{ArtifactNode: sub, BuilderNode: b.builder})
 		}
 		for _, a := range s.attestations {
 			edges = append(edges, assembler.AttestationForEdge{AttestationNode: a, ForArtifact: sub})
 		}
+		// the attestation links the subject and its package, so that the
+		// provenance of a package can be found from either: the package
+		// named by the subject and the stored ones sharing its digest
+		edges = append(edges, assembler.PackageOfDigestEdge{ArtifactNode: sub})
+		if pkg, ok := s.packages[packageKey{purl: sub.Name, digest: sub.Digest}]; ok {
+			edges = append(edges, assembler.PackageOfEdge{ArtifactNode: sub, PackageNode: pkg})
+			for _, a := range s.attestations {
+				edges = append(edges, assembler.AttestationForEdge{AttestationNode: a, ForPackage: pkg})
+			}
+		}
 		for _, d := range s.dependencies {
 			edges = append(edges, assembler.DependsOnEdge{ArtifactNode: sub, ArtifactDependency: d})
 		}
 	}
+	// byproducts are built by the builder like the subjects, but the
+	// attestation is not for them
+	for _, bp := range s.byproducts {
+		for _, b := range s.builders {
+			edges = append(edges, assembler.BuiltByEdge{ArtifactNode: bp, BuilderNode: b.builder})
+		}
+	}
 	return edges
 }
 
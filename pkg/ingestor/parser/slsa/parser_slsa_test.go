@@ -61,3 +61,251 @@ func Test_slsaParser(t *testing.T) {
 		})
 	}
 }
+
+func Test_slsaParser_packageSubject(t *testing.T) {
+	ctx := logging.WithLogger(context.Background())
+	doc := &processor.Document{
+		Blob: []byte(`{
+			"_type": "https://in-toto.io/Statement/v0.1",
+			"subject": [
+				{"name": "pkg:npm/left-pad@1.3.0", "digest": {"sha512": "abcd", "sha256": "1234"}},
+				{"name": "pkg:npm/%40scope/left-pad@1.3.0", "digest": {"sha256": "1234"}},
+				{"name": "left-pad.tgz", "digest": {"sha256": "5678"}},
+				{"name": "pkg:npm/right-pad@1.0.0", "digest": {"sha256": ""}}
+			],
+			"predicateType": "https://slsa.dev/provenance/v0.2",
+			"predicate": {"builder": {"id": "https://example.com/builder"}, "buildType": "https://example.com/build"}
+		}`),
+		Type:   processor.DocumentITE6SLSA,
+		Format: processor.FormatJSON,
+		SourceInformation: processor.SourceInformation{
+			Collector: "TestCollector",
+			Source:    "TestSource",
+		},
+	}
+	s := NewSLSAParser()
+	if err := s.Parse(ctx, doc); err != nil {
+		t.Fatalf("slsa.Parse() error = %v", err)
+	}
+
+	metadata := *assembler.NewObjectMetadata(doc.SourceInformation)
+	// subjects sharing a digest get a package each, the one without digest
+	// is skipped
+	wantPkgs := []assembler.PackageNode{{
+		Purl:     "pkg:npm/left-pad@1.3.0",
+		Digest:   []string{"sha256:1234", "sha512:abcd"},
+		NodeData: metadata,
+	}, {
+		Purl:     "pkg:npm/%40scope/left-pad@1.3.0",
+		Digest:   []string{"sha256:1234"},
+		NodeData: metadata,
+	}}
+	packages := []assembler.PackageNode{}
+	subjects := 0
+	for _, n := range s.CreateNodes(ctx) {
+		switch node := n.(type) {
+		case assembler.PackageNode:
+			packages = append(packages, node)
+		case assembler.ArtifactNode:
+			if node.Name == "pkg:npm/right-pad@1.0.0" {
+				t.Errorf("slsa.CreateNodes() has the subject without digest %v", node)
+			}
+			subjects++
+		}
+	}
+	if !reflect.DeepEqual(packages, wantPkgs) {
+		t.Errorf("slsa.CreateNodes() packages = %v, want %v", packages, wantPkgs)
+	}
+
+	packageOf := 0
+	packageOfDigest := 0
+	attestationForPackage := 0
+	for _, e := range s.CreateEdges(ctx, nil) {
+		switch edge := e.(type) {
+		case assembler.PackageOfEdge:
+			packageOf++
+			if edge.ArtifactNode.Name != edge.PackageNode.Purl {
+				t.Errorf("unexpected PackageOf edge %v", edge)
+			}
+		case assembler.PackageOfDigestEdge:
+			// links the subject to the stored packages sharing its digest
+			packageOfDigest++
+		case assembler.AttestationForEdge:
+			if !reflect.DeepEqual(edge.ForPackage, assembler.PackageNode{}) {
+				attestationForPackage++
+			}
+		}
+	}
+	if packageOf != 2 || attestationForPackage != 2 {
+		t.Errorf("got %d PackageOf and %d Attestation edges to packages, want 2 and 2", packageOf, attestationForPackage)
+	}
+	if packageOfDigest != subjects || subjects != 3 {
+		t.Errorf("got %d PackageOf edges by digest for %d subjects, want 3", packageOfDigest, subjects)
+	}
+}
+
+func Test_slsaParser_builder(t *testing.T) {
+	ctx := logging.WithLogger(context.Background())
+	tests := []struct {
+		name         string
+		blob         string
+		wantBuilder  string
+		wantType     string
+		wantParams   string
+		wantMaterial string
+	}{{
+		name: "v0.2",
+		blob: `{
+			"_type": "https://in-toto.io/Statement/v0.1",
+			"subject": [{"name": "app", "digest": {"sha256": "1234"}}],
+			"predicateType": "https://slsa.dev/provenance/v0.2",
+			"predicate": {
+				"builder": {"id": "https://example.com/builder@v1"},
+				"buildType": "https://example.com/build@v1",
+				"invocation": {"parameters": {"target": "release"}},
+				"materials": [{"uri": "git+https://example.com/app", "digest": {"sha1": "abcd"}}]
+			}
+		}`,
+		wantBuilder:  "https://example.com/builder@v1",
+		wantType:     "https://example.com/build@v1",
+		wantParams:   `{"target":"release"}`,
+		wantMaterial: "git+https://example.com/app",
+	}, {
+		name: "v1.0",
+		blob: `{
+			"_type": "https://in-toto.io/Statement/v1",
+			"subject": [{"name": "app", "digest": {"sha256": "1234"}}],
+			"predicateType": "https://slsa.dev/provenance/v1",
+			"predicate": {
+				"buildDefinition": {
+					"buildType": "https://example.com/build@v2",
+					"externalParameters": {"workflow": {"path": ".github/workflows/release.yml"}},
+					"resolvedDependencies": [{"uri": "git+https://example.com/app", "digest": {"gitCommit": "abcd"}}]
+				},
+				"runDetails": {"builder": {"id": "https://example.com/builder@v2"}}
+			}
+		}`,
+		wantBuilder:  "https://example.com/builder@v2",
+		wantType:     "https://example.com/build@v2",
+		wantParams:   `{"workflow":{"path":".github/workflows/release.yml"}}`,
+		wantMaterial: "git+https://example.com/app",
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := &processor.Document{
+				Blob:   []byte(tt.blob),
+				Type:   processor.DocumentITE6SLSA,
+				Format: processor.FormatJSON,
+				SourceInformation: processor.SourceInformation{
+					Collector: "TestCollector",
+					Source:    "TestSource",
+				},
+			}
+			s := NewSLSAParser()
+			if err := s.Parse(ctx, doc); err != nil {
+				t.Fatalf("slsa.Parse() error = %v", err)
+			}
+			wantBuilder := assembler.BuilderNode{
+				BuilderId: tt.wantBuilder,
+				NodeData:  *assembler.NewObjectMetadata(doc.SourceInformation),
+			}
+
+			builders := []assembler.BuilderNode{}
+			materials := []string{}
+			for _, n := range s.CreateNodes(ctx) {
+				switch node := n.(type) {
+				case assembler.BuilderNode:
+					builders = append(builders, node)
+				case assembler.ArtifactNode:
+					if node.Name != "app" {
+						materials = append(materials, node.Name)
+					}
+				}
+			}
+			if !reflect.DeepEqual(builders, []assembler.BuilderNode{wantBuilder}) {
+				t.Errorf("slsa.CreateNodes() builders = %v, want %v", builders, wantBuilder)
+			}
+			if !reflect.DeepEqual(materials, []string{tt.wantMaterial}) {
+				t.Errorf("slsa.CreateNodes() materials = %v, want %v", materials, tt.wantMaterial)
+			}
+
+			builtBy := 0
+			for _, e := range s.CreateEdges(ctx, nil) {
+				edge, ok := e.(assembler.BuiltByEdge)
+				if !ok || reflect.DeepEqual(edge.AttestationNode, assembler.AttestationNode{}) {
+					continue
+				}
+				builtBy++
+				if edge.BuilderNode != wantBuilder || edge.BuildType != tt.wantType || edge.Parameters != tt.wantParams {
+					t.Errorf("unexpected BuiltBy edge %v", edge)
+				}
+			}
+			if builtBy != 1 {
+				t.Errorf("got %d BuiltBy edges from the attestation, want 1", builtBy)
+			}
+		})
+	}
+}
+
+func Test_slsaParser_byproducts(t *testing.T) {
+	ctx := logging.WithLogger(context.Background())
+	doc := &processor.Document{
+		Blob: []byte(`{
+			"_type": "https://in-toto.io/Statement/v1",
+			"subject": [{"name": "app", "digest": {"sha256": "1234"}}],
+			"predicateType": "https://slsa.dev/provenance/v1",
+			"predicate": {
+				"buildDefinition": {"buildType": "https://example.com/build@v2"},
+				"runDetails": {
+					"builder": {"id": "https://example.com/builder@v2"},
+					"byproducts": [
+						{"name": "build.log", "digest": {"sha256": "5678"}},
+						{"name": "no-digest.log"}
+					]
+				}
+			}
+		}`),
+		Type:   processor.DocumentITE6SLSA,
+		Format: processor.FormatJSON,
+		SourceInformation: processor.SourceInformation{
+			Collector: "TestCollector",
+			Source:    "TestSource",
+		},
+	}
+	s := NewSLSAParser()
+	if err := s.Parse(ctx, doc); err != nil {
+		t.Fatalf("slsa.Parse() error = %v", err)
+	}
+	wantByproduct := assembler.ArtifactNode{
+		Name:     "build.log",
+		Digest:   "sha256:5678",
+		NodeData: *assembler.NewObjectMetadata(doc.SourceInformation),
+	}
+
+	artifacts := []string{}
+	for _, n := range s.CreateNodes(ctx) {
+		if node, ok := n.(assembler.ArtifactNode); ok {
+			artifacts = append(artifacts, node.Name)
+		}
+	}
+	if !reflect.DeepEqual(artifacts, []string{"app", "build.log"}) {
+		t.Errorf("slsa.CreateNodes() artifacts = %v, want [app build.log]", artifacts)
+	}
+
+	builtBy := false
+	for _, e := range s.CreateEdges(ctx, nil) {
+		switch edge := e.(type) {
+		case assembler.BuiltByEdge:
+			if reflect.DeepEqual(edge.ArtifactNode, wantByproduct) {
+				builtBy = true
+			}
+		case assembler.AttestationForEdge:
+			if reflect.DeepEqual(edge.ForArtifact, wantByproduct) {
+				t.Errorf("unexpected attestation for byproduct %v", edge)
+			}
+		}
+	}
+	if !builtBy {
+		t.Errorf("slsa.CreateEdges() has no BuiltBy edge for byproduct %v", wantByproduct)
+	}
+}
@@ -32,16 +32,25 @@ import (
 )
 
 type spdxParser struct {
-	doc      *processor.Document
-	packages map[string][]assembler.PackageNode
-	files    map[string][]assembler.ArtifactNode
-	spdxDoc  *v2_2.Document
+	doc          *processor.Document
+	packages     map[string][]assembler.PackageNode
+	files        map[string][]assembler.ArtifactNode
+	licenses     []assembler.LicenseNode
+	licenseEdges []assembler.HasLicenseEdge
+	references   []assembler.GuacNode
+	refEdges     []assembler.GuacEdge
+	spdxDoc      *v2_2.Document
+	document     assembler.MetadataNode
 }
 
 func NewSpdxParser() common.DocumentParser {
 	return &spdxParser{
-		packages: map[string][]assembler.PackageNode{},
-		files:    map[string][]assembler.ArtifactNode{},
+		packages:     map[string][]assembler.PackageNode{},
+		files:        map[string][]assembler.ArtifactNode{},
+		licenses:     []assembler.LicenseNode{},
+		licenseEdges: []assembler.HasLicenseEdge{},
+		references:   []assembler.GuacNode{},
+		refEdges:     []assembler.GuacEdge{},
 	}
 }
 
@@ -52,6 +61,7 @@ func (s *spdxParser) Parse(ctx context.Context, doc *processor.Document) error {
 		return fmt.Errorf("failed to parse SPDX document: %w", err)
 	}
 	s.spdxDoc = spdxDoc
+	s.document = common.CreateDocumentNode(doc, spdxDoc.DocumentNamespace, common.DocumentFormatSPDX, strings.TrimPrefix(spdxDoc.SPDXVersion, "SPDX-"))
 	s.getPackages()
 	s.getFiles()
 	return nil
@@ -95,9 +105,40 @@ func (s *spdxParser) getPackages() {
 		for _, checksum := range pac.PackageChecksums {
 			currentPackage.Digest = append(currentPackage.Digest, strings.ToLower(string(checksum.Algorithm))+":"+checksum.Value)
 		}
+		common.SetFallbackPackageKey(&currentPackage, "", s.spdxDoc.DocumentNamespace, string(pac.PackageSPDXIdentifier))
 		currentPackage.Tags = getPackageTags(currentPackage)
 		s.packages[string(pac.PackageSPDXIdentifier)] = append(s.packages[string(pac.PackageSPDXIdentifier)], currentPackage)
+
+		licenses, licenseEdges := common.CreateLicenseGraph(currentPackage, getPackageLicense(pac), s.doc.SourceInformation)
+		s.licenses = append(s.licenses, licenses...)
+		s.licenseEdges = append(s.licenseEdges, licenseEdges...)
+		s.getReferences(currentPackage, pac)
+	}
+}
+
+// getReferences creates the external reference nodes of a package, other
+// than the purl and CPEs which identify the package itself
+func (s *spdxParser) getReferences(pkg assembler.PackageNode, pac *v2_2.Package) {
+	for _, ext := range pac.PackageExternalReferences {
+		if strings.HasPrefix(ext.RefType, "cpe") || ext.RefType == spdx_common.TypePackageManagerPURL {
+			continue
+		}
+		nodes, edges := common.CreateReferenceGraph(pkg, ext.RefType, ext.Locator, s.doc.SourceInformation)
+		s.references = append(s.references, nodes...)
+		s.refEdges = append(s.refEdges, edges...)
+	}
+}
+
+// getPackageLicense returns the concluded license of the package, falling
+// back to the declared one when no conclusion was made
+func getPackageLicense(pac *v2_2.Package) string {
+	if pac.PackageLicenseConcluded != "" && pac.PackageLicenseConcluded != "NOASSERTION" {
+		return pac.PackageLicenseConcluded
+	}
+	if pac.PackageLicenseDeclared != "" {
+		return pac.PackageLicenseDeclared
 	}
+	return pac.PackageLicenseConcluded
 }
 
 func getPackageTags(p assembler.PackageNode) []string {
@@ -109,14 +150,20 @@ func getPackageTags(p assembler.PackageNode) []string {
 
 func (s *spdxParser) getFiles() {
 	for _, file := range s.spdxDoc.Files {
-		currentFile := assembler.ArtifactNode{}
+		// a file is a single artifact known by all of its checksums
+		digests := []string{}
 		for _, checksum := range file.Checksums {
-			currentFile.Name = file.FileName
-			currentFile.Digest = strings.ToLower(string(checksum.Algorithm)) + ":" + checksum.Value
-			currentFile.Tags = getTags(file)
-			currentFile.NodeData = *assembler.NewObjectMetadata(s.doc.SourceInformation)
-			s.files[string(file.FileSPDXIdentifier)] = append(s.files[string(file.FileSPDXIdentifier)], currentFile)
+			digests = append(digests, strings.ToLower(string(checksum.Algorithm))+":"+checksum.Value)
+		}
+		if len(digests) == 0 {
+			continue
 		}
+		currentFile := assembler.ArtifactNode{}
+		currentFile.Name = file.FileName
+		currentFile.Digest, currentFile.AlternateDigests = common.ArtifactDigests(digests)
+		currentFile.Tags = getTags(file)
+		currentFile.NodeData = *assembler.NewObjectMetadata(s.doc.SourceInformation)
+		s.files[string(file.FileSPDXIdentifier)] = append(s.files[string(file.FileSPDXIdentifier)], currentFile)
 	}
 }
 
@@ -134,7 +181,7 @@ func parseSpdxBlob(p []byte) (*v2_2.Document, error) {
 }
 
 func (s *spdxParser) CreateNodes(ctx context.Context) []assembler.GuacNode {
-	nodes := []assembler.GuacNode{}
+	nodes := []assembler.GuacNode{s.document}
 	for _, packNodes := range s.packages {
 		for _, packNode := range packNodes {
 			nodes = append(nodes, packNode)
@@ -145,6 +192,10 @@ func (s *spdxParser) CreateNodes(ctx context.Context) []assembler.GuacNode {
 			nodes = append(nodes, fileNode)
 		}
 	}
+	for _, licenseNode := range s.licenses {
+		nodes = append(nodes, licenseNode)
+	}
+	nodes = append(nodes, s.references...)
 	return nodes
 }
 
@@ -168,8 +219,13 @@ func (s *spdxParser) CreateEdges(ctx context.Context, foundIdentities []assemble
 	toplevel := s.getPackageElement("SPDXRef-DOCUMENT")
 	// adding top level package edge manually for all depends on package
 	if toplevel != nil {
+		edges = append(edges, common.CreateDocumentEdge(s.document, toplevel[0])...)
 		edges = append(edges, createTopLevelEdges(toplevel[0], s.packages, s.files)...)
 	}
+	for _, licenseEdge := range s.licenseEdges {
+		edges = append(edges, licenseEdge)
+	}
+	edges = append(edges, s.refEdges...)
 	for _, rel := range s.spdxDoc.Relationships {
 		foundPackNodes := s.getPackageElement("SPDXRef-" + string(rel.RefA.ElementRefID))
 		foundFileNodes := s.getFileElement("SPDXRef-" + string(rel.RefA.ElementRefID))
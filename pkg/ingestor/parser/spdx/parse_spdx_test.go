@@ -17,6 +17,7 @@ package spdx
 
 import (
 	"context"
+	"reflect"
 	"testing"
 
 	"github.com/guacsec/guac/internal/testing/testdata"
@@ -68,3 +69,41 @@ func Test_spdxParser(t *testing.T) {
 		})
 	}
 }
+
+func Test_spdxParser_missingPurls(t *testing.T) {
+	ctx := logging.WithLogger(context.Background())
+	doc := &processor.Document{
+		Blob:   testdata.SpdxExampleSmall,
+		Format: processor.FormatJSON,
+		Type:   processor.DocumentSPDX,
+	}
+	s := NewSpdxParser()
+	if err := s.Parse(ctx, doc); err != nil {
+		t.Fatalf("spdxParser.Parse() error = %v", err)
+	}
+
+	// none of the packages has a purl or a name, so each is keyed on its
+	// SPDX identifier
+	want := map[string]bool{
+		"pkg:guac/ref/SPDXRef-go-module-golang.org%2Fx%2Ftext?document=https%3A%2F%2Fswinslow.net%2Fspdx-examples%2Fexample7%2Fhello-imports": true,
+		"pkg:guac/ref/SPDXRef-go-module-rsc.io%2Fquote?document=https%3A%2F%2Fswinslow.net%2Fspdx-examples%2Fexample7%2Fhello-imports":        true,
+		"pkg:guac/ref/SPDXRef-go-module-rsc.io%2Fsampler?document=https%3A%2F%2Fswinslow.net%2Fspdx-examples%2Fexample7%2Fhello-imports":      true,
+	}
+	got := map[string]bool{}
+	for _, n := range s.CreateNodes(ctx) {
+		pkg, ok := n.(assembler.PackageNode)
+		if !ok {
+			continue
+		}
+		if got[pkg.Purl] {
+			t.Errorf("duplicate package node %q", pkg.Purl)
+		}
+		if pkg.KeySource != "ref" {
+			t.Errorf("package %q KeySource = %q, want ref", pkg.Purl, pkg.KeySource)
+		}
+		got[pkg.Purl] = true
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("package nodes = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,261 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package syft
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/guacsec/guac/pkg/assembler"
+	"github.com/guacsec/guac/pkg/handler/processor"
+	"github.com/guacsec/guac/pkg/handler/processor/syft"
+	"github.com/guacsec/guac/pkg/ingestor/parser/common"
+)
+
+type syftParser struct {
+	doc         *processor.Document
+	syftDoc     *syft.Document
+	document    assembler.MetadataNode
+	rootPackage assembler.PackageNode
+	// packages and files are in the order of the document, and keyed by
+	// their syft id in packageIDs and fileIDs
+	packages     []assembler.PackageNode
+	packageIDs   map[string]assembler.PackageNode
+	files        []assembler.ArtifactNode
+	fileIDs      map[string]assembler.ArtifactNode
+	licenses     []assembler.LicenseNode
+	licenseEdges []assembler.HasLicenseEdge
+}
+
+// NewSyftParser initializes the syftParser
+func NewSyftParser() common.DocumentParser {
+	return &syftParser{
+		packages:     []assembler.PackageNode{},
+		packageIDs:   map[string]assembler.PackageNode{},
+		files:        []assembler.ArtifactNode{},
+		fileIDs:      map[string]assembler.ArtifactNode{},
+		licenses:     []assembler.LicenseNode{},
+		licenseEdges: []assembler.HasLicenseEdge{},
+	}
+}
+
+// Parse breaks out the document into the graph components
+func (s *syftParser) Parse(ctx context.Context, doc *processor.Document) error {
+	if doc.Type != processor.DocumentSyft {
+		return fmt.Errorf("expected document type: %v, actual document type: %v", processor.DocumentSyft, doc.Type)
+	}
+	if doc.Format != processor.FormatJSON {
+		return fmt.Errorf("unable to support parsing of syft document format: %v", doc.Format)
+	}
+	s.doc = doc
+	syftDoc, err := syft.ParseDocument(doc.Blob)
+	if err != nil {
+		return fmt.Errorf("failed to parse syft document: %w", err)
+	}
+	s.syftDoc = syftDoc
+	// syft documents have no identifier, the document node is keyed on
+	// the digest of the document
+	s.document = common.CreateDocumentNode(doc, "", common.DocumentFormatSyft, syftDoc.Schema.Version)
+	s.getRootPackage()
+	s.getPackages()
+	s.getFiles()
+	return nil
+}
+
+// getRootPackage creates the package of the source syft cataloged: an OCI
+// package for an image, or a package keyed on the name or path of a
+// directory or file
+func (s *syftParser) getRootPackage() {
+	source := s.syftDoc.Source
+	metadata := source.Describe()
+	root := assembler.PackageNode{
+		NodeData: *assembler.NewObjectMetadata(s.doc.SourceInformation),
+	}
+	if source.Type == syft.SourceImage && metadata.UserInput != "" {
+		root.Name = metadata.UserInput
+		root.Purl = ociPurl(metadata.UserInput, metadata.ManifestDigest)
+		if metadata.ManifestDigest != "" {
+			root.Digest = []string{metadata.ManifestDigest}
+		}
+		root.Tags = []string{"CONTAINER"}
+	} else {
+		root.Name = source.Name
+		if root.Name == "" {
+			root.Name = metadata.Path
+		}
+		root.Version = source.Version
+		common.SetFallbackPackageKey(&root, "", s.document.ID, source.ID)
+	}
+	s.rootPackage = root
+}
+
+// ociPurl returns the OCI purl of the image reference, e.g.
+// pkg:oci/alpine@sha256%3A...?repository_url=ghcr.io/guacsec/alpine&tag=3.16.2
+func ociPurl(ref string, digest string) string {
+	if i := strings.Index(ref, "@"); i >= 0 {
+		if digest == "" {
+			digest = ref[i+1:]
+		}
+		ref = ref[:i]
+	}
+	repo, tag := ref, ""
+	if i := strings.LastIndex(ref, ":"); i > strings.LastIndex(ref, "/") {
+		repo, tag = ref[:i], ref[i+1:]
+	}
+	purl := "pkg:oci/" + repo[strings.LastIndex(repo, "/")+1:]
+	if digest != "" {
+		purl += "@" + strings.ReplaceAll(digest, ":", "%3A")
+	}
+	purl += "?repository_url=" + repo
+	if tag != "" {
+		purl += "&tag=" + tag
+	}
+	return purl
+}
+
+func (s *syftParser) getPackages() {
+	for _, p := range s.syftDoc.Artifacts {
+		pkg := assembler.PackageNode{
+			Name:     p.Name,
+			Version:  p.Version,
+			Purl:     p.PURL,
+			NodeData: *assembler.NewObjectMetadata(s.doc.SourceInformation),
+		}
+		for _, cpe := range p.CPEs {
+			pkg.CPEs = append(pkg.CPEs, cpe.CPE)
+		}
+		if strings.HasPrefix(pkg.Purl, "pkg:oci/") {
+			pkg.Tags = []string{"CONTAINER"}
+		}
+		common.SetFallbackPackageKey(&pkg, "", s.document.ID, p.ID)
+		s.packages = append(s.packages, pkg)
+		s.packageIDs[p.ID] = pkg
+
+		for _, l := range p.Licenses {
+			nodes, edges := common.CreateLicenseGraph(pkg, l.Expression(), s.doc.SourceInformation)
+			s.licenses = append(s.licenses, nodes...)
+			s.licenseEdges = append(s.licenseEdges, edges...)
+		}
+	}
+}
+
+// getFiles creates an artifact for each file known by the digests of its
+// content, as a file without digests cannot be identified
+func (s *syftParser) getFiles() {
+	for _, f := range s.syftDoc.Files {
+		digests := []string{}
+		for _, d := range f.Digests {
+			if d.Value != "" {
+				digests = append(digests, common.DigestAlgorithm(d.Algorithm)+":"+d.Value)
+			}
+		}
+		if len(digests) == 0 {
+			continue
+		}
+		file := assembler.ArtifactNode{
+			Name:     f.Location.Path,
+			NodeData: *assembler.NewObjectMetadata(s.doc.SourceInformation),
+		}
+		file.Digest, file.AlternateDigests = common.ArtifactDigests(digests)
+		s.files = append(s.files, file)
+		s.fileIDs[f.ID] = file
+	}
+}
+
+// CreateNodes creates the GuacNode for the graph inputs
+func (s *syftParser) CreateNodes(ctx context.Context) []assembler.GuacNode {
+	nodes := []assembler.GuacNode{s.document}
+	if s.rootPackage.Purl != "" {
+		nodes = append(nodes, s.rootPackage)
+	}
+	for _, p := range s.packages {
+		nodes = append(nodes, p)
+	}
+	for _, f := range s.files {
+		nodes = append(nodes, f)
+	}
+	for _, l := range s.licenses {
+		nodes = append(nodes, l)
+	}
+	return nodes
+}
+
+// CreateEdges creates the GuacEdges that form the relationship for the graph inputs
+func (s *syftParser) CreateEdges(ctx context.Context, foundIdentities []assembler.IdentityNode) []assembler.GuacEdge {
+	edges := []assembler.GuacEdge{}
+	// the source depends on all of its packages and contains all of its
+	// files
+	if s.rootPackage.Purl != "" {
+		edges = append(edges, common.CreateDocumentEdge(s.document, s.rootPackage)...)
+		for _, p := range s.packages {
+			if p.Purl != s.rootPackage.Purl {
+				edges = append(edges, assembler.DependsOnEdge{PackageNode: s.rootPackage, PackageDependency: p})
+			}
+		}
+		for _, f := range s.files {
+			edges = append(edges, assembler.ContainsEdge{PackageNode: s.rootPackage, ContainedArtifact: f})
+		}
+	}
+	for _, l := range s.licenseEdges {
+		edges = append(edges, l)
+	}
+	for _, rel := range s.syftDoc.ArtifactRelationships {
+		if edge := s.getRelationshipEdge(rel); edge != nil {
+			edges = append(edges, edge)
+		}
+	}
+	return edges
+}
+
+// getRelationshipEdge returns the edge of the relationship between two
+// packages or a package and a file, or nil if the relationship does not map
+// to an edge or relates something that was not ingested, e.g. a file without
+// digests
+//
+//   - a package that contains or is evident by a file contains its artifact
+//   - a package that contains another, or owns the files of another, depends
+//     on it
+//   - a package that is a dependency of another is depended on by it
+func (s *syftParser) getRelationshipEdge(rel syft.Relationship) assembler.GuacEdge {
+	parent, ok := s.packageIDs[rel.Parent]
+	if !ok {
+		return nil
+	}
+	if file, ok := s.fileIDs[rel.Child]; ok {
+		switch rel.Type {
+		case syft.RelationshipContains, syft.RelationshipEvidentBy:
+			return assembler.ContainsEdge{PackageNode: parent, ContainedArtifact: file}
+		}
+		return nil
+	}
+	child, ok := s.packageIDs[rel.Child]
+	if !ok {
+		return nil
+	}
+	switch rel.Type {
+	case syft.RelationshipContains, syft.RelationshipOwnershipByFileOverlap:
+		return assembler.DependsOnEdge{PackageNode: parent, PackageDependency: child}
+	case syft.RelationshipDependencyOf:
+		return assembler.DependsOnEdge{PackageNode: child, PackageDependency: parent}
+	}
+	return nil
+}
+
+// GetIdentities gets the identity node from the document if they exist
+func (s *syftParser) GetIdentities(ctx context.Context) []assembler.IdentityNode {
+	return nil
+}
@@ -0,0 +1,236 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package syft
+
+import (
+	"context"
+	"testing"
+
+	"github.com/guacsec/guac/internal/testing/testdata"
+	"github.com/guacsec/guac/pkg/assembler"
+	"github.com/guacsec/guac/pkg/handler/processor"
+	"github.com/guacsec/guac/pkg/ingestor/parser/common"
+)
+
+func Test_syftParser(t *testing.T) {
+	ctx := context.Background()
+	source := processor.SourceInformation{Collector: "TestCollector", Source: "TestSource"}
+	nodeData := *assembler.NewObjectMetadata(source)
+
+	imageDoc := &processor.Document{
+		Blob:              testdata.SyftExample,
+		Format:            processor.FormatJSON,
+		Type:              processor.DocumentSyft,
+		SourceInformation: source,
+	}
+	imageDocument := common.CreateDocumentNode(imageDoc, "", common.DocumentFormatSyft, "6.1.0")
+	image := assembler.PackageNode{
+		Name:     "ghcr.io/guacsec/alpine:3.16.2",
+		Purl:     "pkg:oci/alpine@sha256%3A8e8d8f5a0f2b8a1e5a1b2c3d4e5f60718293a4b5c6d7e8f90a1b2c3d4e5f6071?repository_url=ghcr.io/guacsec/alpine&tag=3.16.2",
+		Digest:   []string{"sha256:8e8d8f5a0f2b8a1e5a1b2c3d4e5f60718293a4b5c6d7e8f90a1b2c3d4e5f6071"},
+		Tags:     []string{"CONTAINER"},
+		NodeData: nodeData,
+	}
+	baselayout := assembler.PackageNode{
+		Name:     "alpine-baselayout",
+		Version:  "3.2.0-r23",
+		Purl:     "pkg:apk/alpine/alpine-baselayout@3.2.0-r23?arch=x86_64&upstream=alpine-baselayout&distro=alpine-3.16.2",
+		CPEs:     []string{"cpe:2.3:a:alpine-baselayout:alpine-baselayout:3.2.0-r23:*:*:*:*:*:*:*"},
+		NodeData: nodeData,
+	}
+	musl := assembler.PackageNode{
+		Name:     "musl",
+		Version:  "1.2.3-r0",
+		Purl:     "pkg:apk/alpine/musl@1.2.3-r0?arch=x86_64&upstream=musl&distro=alpine-3.16.2",
+		CPEs:     []string{"cpe:2.3:a:musl-libc:musl:1.2.3-r0:*:*:*:*:*:*:*"},
+		NodeData: nodeData,
+	}
+	busybox := assembler.PackageNode{
+		Name:      "busybox",
+		Version:   "1.35.0",
+		Purl:      "pkg:guac/generic/busybox@1.35.0",
+		KeySource: common.PackageKeyNameVersion,
+		NodeData:  nodeData,
+	}
+	busyboxFile := assembler.ArtifactNode{
+		Name:             "/bin/busybox",
+		Digest:           "sha256:3a8a5f1ac3d35de4d9a3c1a40b6bb2b3a0d5e6f7a8b9c0d1e2f3a4b5c6d7e8f9",
+		AlternateDigests: []string{"sha1:6a1f8e3d2c4b5a697887766554433221100ffeed"},
+		NodeData:         nodeData,
+	}
+	muslFile := assembler.ArtifactNode{
+		Name:     "/lib/ld-musl-x86_64.so.1",
+		Digest:   "sha256:9c1f2e3d4c5b6a798897a6b5c4d3e2f1a0b9c8d7e6f5a4b3c2d1e0f9a8b7c6d5",
+		NodeData: nodeData,
+	}
+	gplNodes, gplEdges := common.CreateLicenseGraph(baselayout, "GPL-2.0-only", source)
+	mitNodes, mitEdges := common.CreateLicenseGraph(musl, "MIT", source)
+
+	dirDoc := &processor.Document{
+		Blob: []byte(`{
+			"artifacts": [
+				{
+					"id": "a1",
+					"name": "golang.org/x/text",
+					"version": "v0.6.0",
+					"type": "go-module",
+					"licenses": [{"value": "BSD-3-Clause", "spdxExpression": "BSD-3-Clause", "type": "declared"}],
+					"cpes": [{"cpe": "cpe:2.3:a:golang:text:v0.6.0:*:*:*:*:*:*:*", "source": "syft-generated"}],
+					"purl": "pkg:golang/golang.org/x/text@v0.6.0"
+				},
+				{
+					"id": "a2",
+					"name": "github.com/guacsec/guac",
+					"version": "v0.1.0",
+					"type": "go-module",
+					"licenses": [],
+					"cpes": [],
+					"purl": "pkg:golang/github.com/guacsec/guac@v0.1.0"
+				}
+			],
+			"artifactRelationships": [
+				{"parent": "a1", "child": "a2", "type": "dependency-of"},
+				{"parent": "a2", "child": "unknown", "type": "contains"}
+			],
+			"source": {
+				"id": "d1",
+				"name": "guac",
+				"version": "v0.1.0",
+				"type": "directory",
+				"metadata": {"path": "/src/guac"}
+			},
+			"descriptor": {"name": "syft", "version": "1.0.0"},
+			"schema": {"version": "16.0.0", "url": "https://raw.githubusercontent.com/anchore/syft/main/schema/json/schema-16.0.0.json"}
+		}`),
+		Format:            processor.FormatJSON,
+		Type:              processor.DocumentSyft,
+		SourceInformation: source,
+	}
+	dirDocument := common.CreateDocumentNode(dirDoc, "", common.DocumentFormatSyft, "16.0.0")
+	dir := assembler.PackageNode{
+		Name:      "guac",
+		Version:   "v0.1.0",
+		Purl:      "pkg:guac/generic/guac@v0.1.0",
+		KeySource: common.PackageKeyNameVersion,
+		NodeData:  nodeData,
+	}
+	text := assembler.PackageNode{
+		Name:     "golang.org/x/text",
+		Version:  "v0.6.0",
+		Purl:     "pkg:golang/golang.org/x/text@v0.6.0",
+		CPEs:     []string{"cpe:2.3:a:golang:text:v0.6.0:*:*:*:*:*:*:*"},
+		NodeData: nodeData,
+	}
+	guac := assembler.PackageNode{
+		Name:     "github.com/guacsec/guac",
+		Version:  "v0.1.0",
+		Purl:     "pkg:golang/github.com/guacsec/guac@v0.1.0",
+		NodeData: nodeData,
+	}
+	bsdNodes, bsdEdges := common.CreateLicenseGraph(text, "BSD-3-Clause", source)
+
+	tests := []struct {
+		name      string
+		doc       *processor.Document
+		wantNodes []assembler.GuacNode
+		wantEdges []assembler.GuacEdge
+		wantErr   bool
+	}{{
+		name: "image",
+		doc:  imageDoc,
+		wantNodes: []assembler.GuacNode{
+			imageDocument, image, baselayout, musl, busybox, busyboxFile, muslFile, gplNodes[0], mitNodes[0],
+		},
+		wantEdges: []assembler.GuacEdge{
+			assembler.MetadataForEdge{MetadataNode: imageDocument, ForPackage: image},
+			assembler.DependsOnEdge{PackageNode: image, PackageDependency: baselayout},
+			assembler.DependsOnEdge{PackageNode: image, PackageDependency: musl},
+			assembler.DependsOnEdge{PackageNode: image, PackageDependency: busybox},
+			assembler.ContainsEdge{PackageNode: image, ContainedArtifact: busyboxFile},
+			assembler.ContainsEdge{PackageNode: image, ContainedArtifact: muslFile},
+			gplEdges[0],
+			mitEdges[0],
+			assembler.DependsOnEdge{PackageNode: baselayout, PackageDependency: musl},
+			assembler.DependsOnEdge{PackageNode: baselayout, PackageDependency: busybox},
+			assembler.ContainsEdge{PackageNode: musl, ContainedArtifact: muslFile},
+			assembler.ContainsEdge{PackageNode: busybox, ContainedArtifact: busyboxFile},
+		},
+	}, {
+		name: "directory with the current schema",
+		doc:  dirDoc,
+		wantNodes: []assembler.GuacNode{
+			dirDocument, dir, text, guac, bsdNodes[0],
+		},
+		wantEdges: []assembler.GuacEdge{
+			assembler.MetadataForEdge{MetadataNode: dirDocument, ForPackage: dir},
+			assembler.DependsOnEdge{PackageNode: dir, PackageDependency: text},
+			assembler.DependsOnEdge{PackageNode: dir, PackageDependency: guac},
+			bsdEdges[0],
+			assembler.DependsOnEdge{PackageNode: guac, PackageDependency: text},
+		},
+	}, {
+		name: "wrong document type",
+		doc: &processor.Document{
+			Blob:   testdata.SyftExample,
+			Format: processor.FormatJSON,
+			Type:   processor.DocumentSPDX,
+		},
+		wantErr: true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewSyftParser()
+			err := p.Parse(ctx, tt.doc)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("syftParser.Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if nodes := p.CreateNodes(ctx); !testdata.GuacNodeSliceEqual(nodes, tt.wantNodes) {
+				t.Errorf("syftParser.CreateNodes() = %v, want %v", nodes, tt.wantNodes)
+			}
+			if edges := p.CreateEdges(ctx, nil); !testdata.GuacEdgeSliceEqual(edges, tt.wantEdges) {
+				t.Errorf("syftParser.CreateEdges() = %v, want %v", edges, tt.wantEdges)
+			}
+		})
+	}
+}
+
+func Test_ociPurl(t *testing.T) {
+	tests := []struct {
+		ref    string
+		digest string
+		want   string
+	}{{
+		ref:    "ghcr.io/guacsec/alpine:3.16.2",
+		digest: "sha256:abc",
+		want:   "pkg:oci/alpine@sha256%3Aabc?repository_url=ghcr.io/guacsec/alpine&tag=3.16.2",
+	}, {
+		ref:  "localhost:5000/alpine@sha256:abc",
+		want: "pkg:oci/alpine@sha256%3Aabc?repository_url=localhost:5000/alpine",
+	}, {
+		ref:  "alpine",
+		want: "pkg:oci/alpine?repository_url=alpine",
+	}}
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			if got := ociPurl(tt.ref, tt.digest); got != tt.want {
+				t.Errorf("ociPurl() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -95,6 +95,9 @@ func (c *vulnCertificationParser) getAttestation(blob []byte, source string, sta
 		Payload:         map[string]interface{}{},
 		NodeData:        *assembler.NewObjectMetadata(c.doc.SourceInformation),
 	}
+	if statement.Predicate.Metadata.ScannedOn != nil {
+		attNode.StatementTime = *statement.Predicate.Metadata.ScannedOn
+	}
 	attNode.Payload["invocation_parameters"] = statement.Predicate.Invocation.Parameters
 	attNode.Payload["invocation_uri"] = statement.Predicate.Invocation.Uri
 	attNode.Payload["invocation_eventID"] = statement.Predicate.Invocation.EventID
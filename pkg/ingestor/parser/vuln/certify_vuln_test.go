@@ -18,6 +18,7 @@ package certify_vuln
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/guacsec/guac/internal/testing/testdata"
 	"github.com/guacsec/guac/pkg/assembler"
@@ -45,6 +46,7 @@ var (
 		FilePath:        "TestSource",
 		Digest:          "sha256:8546e78671836aab74c2dd554b4939547ae950ff3ecc89fcbedf69a7b11bbe0c",
 		AttestationType: attestationType,
+		StatementTime:   time.Date(2022, 11, 21, 17, 45, 50, 520000000, time.UTC),
 		Payload: map[string]interface{}{
 			"invocation_parameters":    []string{""},
 			"invocation_eventID":       "",
@@ -0,0 +1,262 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/guacsec/guac/pkg/ingestor/verifier"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	policyLock  sync.RWMutex
+	trustPolicy *TrustPolicy
+)
+
+// TrustPolicy lists the signers trusted by the ingestor, and what each of
+// them may sign. A signed document is only ingested if one of its verified
+// signatures is from a signer allowed to sign its predicate type and all its
+// subjects. For example:
+//
+//	signers:
+//	- name: team-a
+//	  keyID: team-a-release
+//	  key: keys/team-a.pem
+//	  predicateTypes:
+//	  - https://slsa.dev/provenance/v0.2
+//	  subjects:
+//	  - pkg:oci/team-a/*
+//	- name: team-b ci
+//	  issuer: https://token.actions.githubusercontent.com
+//	  subjectAlternativeName: https://github.com/example/team-b/*
+//	  predicateTypes:
+//	  - https://in-toto.io/attestation/*
+type TrustPolicy struct {
+	Signers []Signer `yaml:"signers"`
+}
+
+// Signer is a signing key, or a keyless signing identity, and the statements
+// it is trusted to sign. Patterns are matched exactly, or as a prefix if they
+// end with `*`.
+type Signer struct {
+	// Name identifies the signer in errors, defaults to its key ID or
+	// identity
+	Name string `yaml:"name"`
+	// KeyID matches the key ID of a signature, or the hash of its key
+	KeyID string `yaml:"keyID"`
+	// Key is the path to the PEM public key, or the URI of the KMS key,
+	// registered under KeyID. It may be left empty for keys registered
+	// otherwise.
+	Key string `yaml:"key"`
+	// Issuer is the OIDC issuer of a keyless signature, any if empty
+	Issuer string `yaml:"issuer"`
+	// SubjectAlternativeName is the pattern of the identity of a keyless
+	// signature
+	SubjectAlternativeName string `yaml:"subjectAlternativeName"`
+	// PredicateTypes are the patterns of the predicate types the signer may
+	// sign
+	PredicateTypes []string `yaml:"predicateTypes"`
+	// Subjects are the patterns of the subject names the signer may sign,
+	// any if empty
+	Subjects []string `yaml:"subjects"`
+}
+
+// LoadTrustPolicy reads and validates the policy in the YAML file path
+func LoadTrustPolicy(path string) (*TrustPolicy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read trust policy: %w", err)
+	}
+	p, err := ParseTrustPolicy(data)
+	if err != nil {
+		return nil, fmt.Errorf("trust policy %s: %w", path, err)
+	}
+	return p, nil
+}
+
+// ParseTrustPolicy parses and validates a YAML trust policy. Unknown fields
+// are rejected, so that a typo does not silently widen what a signer may sign.
+func ParseTrustPolicy(data []byte) (*TrustPolicy, error) {
+	p := &TrustPolicy{}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(p); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to parse trust policy: %w", err)
+	}
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	for i := range p.Signers {
+		if p.Signers[i].Name == "" {
+			p.Signers[i].Name = p.Signers[i].KeyID + p.Signers[i].SubjectAlternativeName
+		}
+	}
+	return p, nil
+}
+
+// Validate returns an error listing every invalid signer of the policy
+func (p *TrustPolicy) Validate() error {
+	problems := []string{}
+	if len(p.Signers) == 0 {
+		problems = append(problems, "no signers")
+	}
+	for i, s := range p.Signers {
+		location := fmt.Sprintf("signers[%d]", i)
+		if s.Name != "" {
+			location += " (" + s.Name + ")"
+		}
+		switch {
+		case s.KeyID == "" && s.SubjectAlternativeName == "":
+			problems = append(problems, location+": keyID or subjectAlternativeName is required")
+		case s.KeyID != "" && s.SubjectAlternativeName != "":
+			problems = append(problems, location+": keyID and subjectAlternativeName are exclusive")
+		}
+		if s.Key != "" && s.KeyID == "" {
+			problems = append(problems, location+": key requires a keyID")
+		}
+		if s.Issuer != "" && s.SubjectAlternativeName == "" {
+			problems = append(problems, location+": issuer requires a subjectAlternativeName")
+		}
+		if len(s.PredicateTypes) == 0 {
+			problems = append(problems, location+": predicateTypes is required")
+		}
+		patterns := append([]string{s.SubjectAlternativeName}, s.PredicateTypes...)
+		for _, pattern := range append(patterns, s.Subjects...) {
+			if strings.Contains(strings.TrimSuffix(pattern, "*"), "*") {
+				problems = append(problems, fmt.Sprintf("%s: %q may only end with a wildcard", location, pattern))
+			}
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid trust policy: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// identifies reports whether identity is the signer
+func (s *Signer) identifies(identity verifier.Identity) bool {
+	if s.KeyID != "" {
+		return identity.ID == s.KeyID || identity.Key.Hash == s.KeyID
+	}
+	if s.Issuer != "" && identity.Issuer != s.Issuer {
+		return false
+	}
+	return identity.SubjectAlternativeName != "" && matches(s.SubjectAlternativeName, identity.SubjectAlternativeName)
+}
+
+// allows returns why the signer may not sign the statement, or nil
+func (s *Signer) allows(predicateType string, subjects []string) error {
+	if !matchesAny(s.PredicateTypes, predicateType) {
+		return fmt.Errorf("signer %s may not sign predicate type %q", s.Name, predicateType)
+	}
+	if len(s.Subjects) == 0 {
+		return nil
+	}
+	for _, subject := range subjects {
+		if !matchesAny(s.Subjects, subject) {
+			return fmt.Errorf("signer %s may not sign subject %q", s.Name, subject)
+		}
+	}
+	return nil
+}
+
+// statement holds the fields of an in-toto statement the policy applies to
+type statement struct {
+	PredicateType string `json:"predicateType"`
+	Subject       []struct {
+		Name string `json:"name"`
+	} `json:"subject"`
+}
+
+// Allow returns an error if none of the verified identities that signed the
+// payload, an in-toto statement, may sign its predicate type and subjects
+func (p *TrustPolicy) Allow(identities []verifier.Identity, payload []byte) error {
+	st := statement{}
+	if err := json.Unmarshal(payload, &st); err != nil || st.PredicateType == "" {
+		return errors.New("signed payload is not an in-toto statement")
+	}
+	subjects := []string{}
+	for _, s := range st.Subject {
+		subjects = append(subjects, s.Name)
+	}
+
+	denied := []string{}
+	for _, identity := range identities {
+		if !identity.Verified {
+			continue
+		}
+		for i := range p.Signers {
+			if !p.Signers[i].identifies(identity) {
+				continue
+			}
+			err := p.Signers[i].allows(st.PredicateType, subjects)
+			if err == nil {
+				return nil
+			}
+			denied = append(denied, err.Error())
+		}
+	}
+	if len(denied) > 0 {
+		return fmt.Errorf("rejected by the trust policy: %s", strings.Join(denied, "; "))
+	}
+	return errors.New("rejected by the trust policy: no verified signature from a trusted signer")
+}
+
+// SetTrustPolicy sets the policy enforced by Enforce, nil to ingest every
+// signed document. It should be validated beforehand.
+func SetTrustPolicy(p *TrustPolicy) {
+	policyLock.Lock()
+	defer policyLock.Unlock()
+	trustPolicy = p
+}
+
+// Enforce applies the policy set by SetTrustPolicy, if any, to the payload
+// signed by identities
+func Enforce(identities []verifier.Identity, payload []byte) error {
+	policyLock.RLock()
+	p := trustPolicy
+	policyLock.RUnlock()
+	if p == nil {
+		return nil
+	}
+	return p.Allow(identities, payload)
+}
+
+func matchesAny(patterns []string, value string) bool {
+	for _, pattern := range patterns {
+		if matches(pattern, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// matches reports whether value is pattern, or starts with it if it ends
+// with a wildcard
+func matches(pattern string, value string) bool {
+	if prefix := strings.TrimSuffix(pattern, "*"); prefix != pattern {
+		return strings.HasPrefix(value, prefix)
+	}
+	return pattern == value
+}
@@ -0,0 +1,208 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/guacsec/guac/pkg/ingestor/key"
+	"github.com/guacsec/guac/pkg/ingestor/verifier"
+)
+
+const testPolicy = `
+signers:
+- name: team-a
+  keyID: team-a-release
+  key: keys/team-a.pem
+  predicateTypes:
+  - https://slsa.dev/provenance/v0.2
+  subjects:
+  - pkg:oci/team-a/*
+- issuer: https://token.actions.githubusercontent.com
+  subjectAlternativeName: https://github.com/example/team-b/*
+  predicateTypes:
+  - https://in-toto.io/attestation/*
+`
+
+func TestParseTrustPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    *TrustPolicy
+		wantErr string
+	}{{
+		name: "signers",
+		data: testPolicy,
+		want: &TrustPolicy{Signers: []Signer{{
+			Name:           "team-a",
+			KeyID:          "team-a-release",
+			Key:            "keys/team-a.pem",
+			PredicateTypes: []string{"https://slsa.dev/provenance/v0.2"},
+			Subjects:       []string{"pkg:oci/team-a/*"},
+		}, {
+			Name:                   "https://github.com/example/team-b/*",
+			Issuer:                 "https://token.actions.githubusercontent.com",
+			SubjectAlternativeName: "https://github.com/example/team-b/*",
+			PredicateTypes:         []string{"https://in-toto.io/attestation/*"},
+		}}},
+	}, {
+		name:    "empty",
+		data:    "",
+		wantErr: "invalid trust policy: no signers",
+	}, {
+		name:    "unknown field",
+		data:    "signers:\n- keyId: a\n  predicateTypes: [a]\n",
+		wantErr: "field keyId not found",
+	}, {
+		name:    "no signer identity",
+		data:    "signers:\n- name: a\n  predicateTypes: [a]\n",
+		wantErr: "signers[0] (a): keyID or subjectAlternativeName is required",
+	}, {
+		name:    "key and identity",
+		data:    "signers:\n- keyID: a\n  subjectAlternativeName: b\n  predicateTypes: [a]\n",
+		wantErr: "signers[0]: keyID and subjectAlternativeName are exclusive",
+	}, {
+		name:    "issuer without identity",
+		data:    "signers:\n- keyID: a\n  issuer: b\n  predicateTypes: [a]\n",
+		wantErr: "signers[0]: issuer requires a subjectAlternativeName",
+	}, {
+		name:    "no predicate types",
+		data:    "signers:\n- keyID: a\n",
+		wantErr: "signers[0]: predicateTypes is required",
+	}, {
+		name:    "wildcard in the middle",
+		data:    "signers:\n- keyID: a\n  predicateTypes: [a]\n  subjects: [\"pkg:*/team-a\"]\n",
+		wantErr: `signers[0]: "pkg:*/team-a" may only end with a wildcard`,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseTrustPolicy([]byte(tt.data))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ParseTrustPolicy() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseTrustPolicy() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseTrustPolicy() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTrustPolicy_Allow(t *testing.T) {
+	p, err := ParseTrustPolicy([]byte(testPolicy))
+	if err != nil {
+		t.Fatal(err)
+	}
+	teamA := verifier.Identity{ID: "team-a-release", Key: key.Key{Hash: "1234"}, Verified: true}
+	teamB := verifier.Identity{
+		ID:                     "https://github.com/example/team-b/.github/workflows/release.yml@refs/heads/main",
+		Issuer:                 "https://token.actions.githubusercontent.com",
+		SubjectAlternativeName: "https://github.com/example/team-b/.github/workflows/release.yml@refs/heads/main",
+		Verified:               true,
+	}
+	slsa := `{"_type": "https://in-toto.io/Statement/v0.1", "predicateType": "https://slsa.dev/provenance/v0.2",
+		"subject": [{"name": "pkg:oci/team-a/app", "digest": {"sha256": "abcd"}}]}`
+
+	tests := []struct {
+		name       string
+		identities []verifier.Identity
+		payload    string
+		wantErr    string
+	}{{
+		name:       "allowed key",
+		identities: []verifier.Identity{teamA},
+		payload:    slsa,
+	}, {
+		name:       "key matched by hash",
+		identities: []verifier.Identity{{ID: "other", Key: key.Key{Hash: "team-a-release"}, Verified: true}},
+		payload:    slsa,
+	}, {
+		name:       "allowed identity",
+		identities: []verifier.Identity{teamB},
+		payload:    `{"predicateType": "https://in-toto.io/attestation/vulns/v0.1", "subject": [{"name": "anything"}]}`,
+	}, {
+		name:       "one of the signatures allowed",
+		identities: []verifier.Identity{teamB, teamA},
+		payload:    slsa,
+	}, {
+		name:       "unverified signature",
+		identities: []verifier.Identity{{ID: "team-a-release", Key: key.Key{Hash: "1234"}}},
+		payload:    slsa,
+		wantErr:    "no verified signature from a trusted signer",
+	}, {
+		name:       "unknown signer",
+		identities: []verifier.Identity{{ID: "team-c", Verified: true}},
+		payload:    slsa,
+		wantErr:    "no verified signature from a trusted signer",
+	}, {
+		name:       "other issuer",
+		identities: []verifier.Identity{{SubjectAlternativeName: teamB.SubjectAlternativeName, Issuer: "https://accounts.google.com", Verified: true}},
+		payload:    `{"predicateType": "https://in-toto.io/attestation/vulns/v0.1"}`,
+		wantErr:    "no verified signature from a trusted signer",
+	}, {
+		name:       "predicate type not allowed",
+		identities: []verifier.Identity{teamB},
+		payload:    slsa,
+		wantErr:    `signer https://github.com/example/team-b/* may not sign predicate type "https://slsa.dev/provenance/v0.2"`,
+	}, {
+		name:       "subject not allowed",
+		identities: []verifier.Identity{teamA},
+		payload:    `{"predicateType": "https://slsa.dev/provenance/v0.2", "subject": [{"name": "pkg:oci/team-a/app"}, {"name": "pkg:oci/team-b/app"}]}`,
+		wantErr:    `signer team-a may not sign subject "pkg:oci/team-b/app"`,
+	}, {
+		name:       "not a statement",
+		identities: []verifier.Identity{teamA},
+		payload:    `{"spdxVersion": "SPDX-2.2"}`,
+		wantErr:    "signed payload is not an in-toto statement",
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := p.Allow(tt.identities, []byte(tt.payload))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Allow() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Allow() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestEnforce(t *testing.T) {
+	defer SetTrustPolicy(nil)
+	payload := []byte(`{"predicateType": "https://slsa.dev/provenance/v0.2"}`)
+	if err := Enforce(nil, payload); err != nil {
+		t.Errorf("Enforce() without a policy error = %v", err)
+	}
+	p, err := ParseTrustPolicy([]byte(testPolicy))
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetTrustPolicy(p)
+	if err := Enforce(nil, payload); err == nil {
+		t.Errorf("Enforce() expected the unsigned payload to be rejected")
+	}
+}
@@ -0,0 +1,424 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sigstore_verifier
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/bits"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-openapi/strfmt"
+	dsse_processor "github.com/guacsec/guac/pkg/handler/processor/dsse"
+	"github.com/sigstore/rekor/pkg/generated/models"
+	"github.com/sigstore/sigstore/pkg/signature"
+)
+
+var (
+	// fulcioIssuerOID is the extension of the Fulcio certificates holding
+	// the OIDC issuer of the signer as a DER UTF8String, superseding the raw
+	// string of fulcioIssuerV1OID
+	fulcioIssuerOID   = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 8}
+	fulcioIssuerV1OID = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 1}
+)
+
+// ParseCertificates parses the PEM encoded certificates, e.g. the Fulcio
+// roots and intermediates to pass to WithFulcio
+func ParseCertificates(pemBytes []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, pemBytes = pem.Decode(pemBytes)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("no PEM certificate found")
+	}
+	return certs, nil
+}
+
+// ParsePublicKeys parses the PEM encoded public keys, e.g. the Rekor log keys
+// to pass to WithRekor
+func ParsePublicKeys(pemBytes []byte) ([]crypto.PublicKey, error) {
+	var keys []crypto.PublicKey
+	for {
+		var block *pem.Block
+		block, pemBytes = pem.Decode(pemBytes)
+		if block == nil {
+			break
+		}
+		if block.Type != "PUBLIC KEY" {
+			continue
+		}
+		k, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("no PEM public key found")
+	}
+	return keys, nil
+}
+
+// logID is the ID of the transparency log signing with the key: the hex
+// SHA-256 hash of its DER encoding
+func logID(k crypto.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(k)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.Sum256(der)
+	return hex.EncodeToString(h[:]), nil
+}
+
+// signingCertificates returns the certificate chain of a keyless signature,
+// the signing certificate first, either given by the signature or by the
+// bundle of the envelope. It returns nil for a signature by a public key.
+func signingCertificates(sig envelopeSignature, bundle *dsse_processor.Bundle) ([]*x509.Certificate, error) {
+	if sig.Cert != "" {
+		certs, err := ParseCertificates([]byte(sig.Cert))
+		if err != nil {
+			return nil, fmt.Errorf("invalid signing certificate: %w", err)
+		}
+		return certs, nil
+	}
+	if bundle == nil {
+		return nil, nil
+	}
+	var certs []*x509.Certificate
+	for _, der := range bundle.Certificates() {
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, fmt.Errorf("invalid signing certificate: %w", err)
+		}
+		certs = append(certs, cert)
+	}
+	return certs, nil
+}
+
+// certificateIdentity returns the OIDC issuer and the subject alternative
+// name of the signer certified by the Fulcio certificate
+func certificateIdentity(cert *x509.Certificate) (string, string) {
+	var issuer string
+	for _, ext := range cert.Extensions {
+		switch {
+		case ext.Id.Equal(fulcioIssuerOID):
+			var s string
+			if _, err := asn1.Unmarshal(ext.Value, &s); err == nil {
+				issuer = s
+			}
+		case ext.Id.Equal(fulcioIssuerV1OID) && issuer == "":
+			issuer = string(ext.Value)
+		}
+	}
+	var san string
+	switch {
+	case len(cert.EmailAddresses) > 0:
+		san = cert.EmailAddresses[0]
+	case len(cert.URIs) > 0:
+		san = cert.URIs[0].String()
+	}
+	return issuer, san
+}
+
+// verifyChain verifies that the signing certificate chains up to the Fulcio
+// roots and was valid when the signature was logged. The intermediates given
+// along the signing certificate are used to build the chain, the roots they
+// may include are not trusted.
+func (d *sigstoreVerifier) verifyChain(certs []*x509.Certificate, signedAt time.Time) error {
+	if d.fulcioRoots == nil {
+		return errors.New("no Fulcio root is trusted")
+	}
+	intermediates := x509.NewCertPool()
+	for _, c := range d.fulcioIntermediates {
+		intermediates.AddCert(c)
+	}
+	for _, c := range certs[1:] {
+		intermediates.AddCert(c)
+	}
+	_, err := certs[0].Verify(x509.VerifyOptions{
+		Roots:         d.fulcioRoots,
+		Intermediates: intermediates,
+		CurrentTime:   signedAt,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+	})
+	return err
+}
+
+// verifyTransparencyLog verifies that one of the Rekor entries of the bundle
+// logs the signature by the signing certificate of the payload, and returns
+// the time it was integrated in the log at
+func (d *sigstoreVerifier) verifyTransparencyLog(bundle *dsse_processor.Bundle, cert *x509.Certificate, payload []byte) (time.Time, error) {
+	if bundle == nil || len(bundle.VerificationMaterial.TlogEntries) == 0 {
+		return time.Time{}, errors.New("no transparency log entry")
+	}
+	payloadHash := sha256.Sum256(payload)
+	var err error
+	for _, entry := range bundle.VerificationMaterial.TlogEntries {
+		if err = d.verifyTransparencyLogEntry(entry, cert, hex.EncodeToString(payloadHash[:])); err == nil {
+			return time.Unix(int64(entry.IntegratedTime), 0), nil
+		}
+	}
+	return time.Time{}, err
+}
+
+func (d *sigstoreVerifier) verifyTransparencyLogEntry(entry dsse_processor.TransparencyLogEntry, cert *x509.Certificate, payloadHash string) error {
+	id := hex.EncodeToString(entry.LogID.KeyID)
+	pub, ok := d.rekorKeys[id]
+	if !ok {
+		return fmt.Errorf("untrusted transparency log %s", id)
+	}
+	if err := entryLogsSignature(entry.CanonicalizedBody, cert, payloadHash); err != nil {
+		return err
+	}
+	// the integrated time is only signed by the inclusion promise
+	if entry.InclusionPromise == nil {
+		return errors.New("no inclusion promise in the transparency log entry")
+	}
+	if err := verifyInclusionPromise(entry, id, pub); err != nil {
+		return fmt.Errorf("invalid inclusion promise: %w", err)
+	}
+	if entry.InclusionProof != nil {
+		if err := verifyInclusionProof(entry.CanonicalizedBody, entry.InclusionProof, pub); err != nil {
+			return fmt.Errorf("invalid inclusion proof: %w", err)
+		}
+	}
+	return nil
+}
+
+// entryLogsSignature checks that the Rekor entry, of kind hashedrekord or
+// intoto, holds the signing certificate and the hash of the payload
+func entryLogsSignature(body []byte, cert *x509.Certificate, payloadHash string) error {
+	var base struct {
+		Kind       string `json:"kind"`
+		APIVersion string `json:"apiVersion"`
+	}
+	if err := json.Unmarshal(body, &base); err != nil {
+		return fmt.Errorf("invalid transparency log entry: %w", err)
+	}
+	var hashes []*string
+	var pemCerts []strfmt.Base64
+	switch base.Kind {
+	case "hashedrekord":
+		entry := models.Hashedrekord{}
+		if err := json.Unmarshal(body, &entry); err != nil {
+			return fmt.Errorf("invalid hashedrekord entry: %w", err)
+		}
+		spec := models.HashedrekordV001Schema{}
+		if err := unmarshalSpec(entry.Spec, &spec); err != nil {
+			return fmt.Errorf("invalid hashedrekord entry: %w", err)
+		}
+		if spec.Data != nil && spec.Data.Hash != nil {
+			hashes = append(hashes, spec.Data.Hash.Value)
+		}
+		if spec.Signature != nil && spec.Signature.PublicKey != nil {
+			pemCerts = append(pemCerts, spec.Signature.PublicKey.Content)
+		}
+	case "intoto":
+		entry := models.Intoto{}
+		if err := json.Unmarshal(body, &entry); err != nil {
+			return fmt.Errorf("invalid intoto entry: %w", err)
+		}
+		switch base.APIVersion {
+		case "0.0.1":
+			spec := models.IntotoV001Schema{}
+			if err := unmarshalSpec(entry.Spec, &spec); err != nil {
+				return fmt.Errorf("invalid intoto entry: %w", err)
+			}
+			if spec.Content != nil && spec.Content.PayloadHash != nil {
+				hashes = append(hashes, spec.Content.PayloadHash.Value)
+			}
+			if spec.PublicKey != nil {
+				pemCerts = append(pemCerts, *spec.PublicKey)
+			}
+		case "0.0.2":
+			spec := models.IntotoV002Schema{}
+			if err := unmarshalSpec(entry.Spec, &spec); err != nil {
+				return fmt.Errorf("invalid intoto entry: %w", err)
+			}
+			if spec.Content != nil && spec.Content.PayloadHash != nil {
+				hashes = append(hashes, spec.Content.PayloadHash.Value)
+			}
+			if spec.Content != nil && spec.Content.Envelope != nil {
+				for _, s := range spec.Content.Envelope.Signatures {
+					if s != nil && s.PublicKey != nil {
+						pemCerts = append(pemCerts, *s.PublicKey)
+					}
+				}
+			}
+		default:
+			return fmt.Errorf("unsupported intoto entry version %q", base.APIVersion)
+		}
+	default:
+		return fmt.Errorf("unsupported transparency log entry kind %q", base.Kind)
+	}
+
+	foundCert := false
+	for _, c := range pemCerts {
+		if block, _ := pem.Decode(c); block != nil && bytes.Equal(block.Bytes, cert.Raw) {
+			foundCert = true
+		}
+	}
+	if !foundCert {
+		return errors.New("the transparency log entry does not hold the signing certificate")
+	}
+	foundHash := false
+	for _, h := range hashes {
+		if h != nil && strings.EqualFold(*h, payloadHash) {
+			foundHash = true
+		}
+	}
+	if !foundHash {
+		return errors.New("the transparency log entry does not hold the payload hash")
+	}
+	return nil
+}
+
+// unmarshalSpec decodes the spec of an entry, left generic by the models of
+// the entry kinds, into the schema of its version
+func unmarshalSpec(spec interface{}, schema interface{}) error {
+	b, err := json.Marshal(spec)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, schema)
+}
+
+// verifyInclusionPromise verifies the signed entry timestamp of the log,
+// signing the canonical JSON of the entry
+func verifyInclusionPromise(entry dsse_processor.TransparencyLogEntry, id string, pub crypto.PublicKey) error {
+	promised, err := json.Marshal(struct {
+		Body           string `json:"body"`
+		IntegratedTime int64  `json:"integratedTime"`
+		LogID          string `json:"logID"`
+		LogIndex       int64  `json:"logIndex"`
+	}{
+		Body:           base64.StdEncoding.EncodeToString(entry.CanonicalizedBody),
+		IntegratedTime: int64(entry.IntegratedTime),
+		LogID:          id,
+		LogIndex:       int64(entry.LogIndex),
+	})
+	if err != nil {
+		return err
+	}
+	return verifyLogSignature(pub, entry.InclusionPromise.SignedEntryTimestamp, promised)
+}
+
+// verifyInclusionProof verifies the RFC 6962 audit path of the entry to the
+// root hash of the tree, and the checkpoint of the log signing that root
+func verifyInclusionProof(body []byte, proof *dsse_processor.InclusionProof, pub crypto.PublicKey) error {
+	index, size := uint64(proof.LogIndex), uint64(proof.TreeSize)
+	if proof.LogIndex < 0 || index >= size {
+		return fmt.Errorf("index %d out of the tree of size %d", index, size)
+	}
+	inner := bits.Len64(index ^ (size - 1))
+	border := bits.OnesCount64(index >> uint(inner))
+	if len(proof.Hashes) != inner+border {
+		return fmt.Errorf("expected %d hashes, got %d", inner+border, len(proof.Hashes))
+	}
+	root := hashLeaf(body)
+	for i, h := range proof.Hashes[:inner] {
+		if (index>>uint(i))&1 == 0 {
+			root = hashChildren(root, h)
+		} else {
+			root = hashChildren(h, root)
+		}
+	}
+	for _, h := range proof.Hashes[inner:] {
+		root = hashChildren(h, root)
+	}
+	if !bytes.Equal(root, proof.RootHash) {
+		return errors.New("the audit path does not lead to the root hash")
+	}
+	return verifyCheckpoint(proof.Checkpoint.Envelope, size, proof.RootHash, pub)
+}
+
+func hashLeaf(leaf []byte) []byte {
+	h := sha256.Sum256(append([]byte{0}, leaf...))
+	return h[:]
+}
+
+func hashChildren(l, r []byte) []byte {
+	b := append([]byte{1}, l...)
+	h := sha256.Sum256(append(b, r...))
+	return h[:]
+}
+
+// verifyCheckpoint verifies the signed note of the log stating its size and
+// root hash: the origin, size and base64 root hash lines, a blank line and the
+// signature lines "— <name> <base64 key hint and signature>"
+func verifyCheckpoint(checkpoint string, size uint64, rootHash []byte, pub crypto.PublicKey) error {
+	text, sigs, ok := strings.Cut(checkpoint, "\n\n")
+	if !ok {
+		return errors.New("unsigned checkpoint")
+	}
+	text += "\n"
+	lines := strings.Split(text, "\n")
+	if len(lines) < 3 {
+		return errors.New("invalid checkpoint")
+	}
+	if lines[1] != strconv.FormatUint(size, 10) || lines[2] != base64.StdEncoding.EncodeToString(rootHash) {
+		return errors.New("the checkpoint does not sign the root hash of the inclusion proof")
+	}
+	for _, line := range strings.Split(sigs, "\n") {
+		if !strings.HasPrefix(line, "— ") {
+			continue
+		}
+		fields := strings.Fields(strings.TrimPrefix(line, "— "))
+		if len(fields) != 2 {
+			continue
+		}
+		sig, err := base64.StdEncoding.DecodeString(fields[1])
+		if err != nil || len(sig) <= 4 {
+			continue
+		}
+		if verifyLogSignature(pub, sig[4:], []byte(text)) == nil {
+			return nil
+		}
+	}
+	return errors.New("the checkpoint is not signed by the log")
+}
+
+func verifyLogSignature(pub crypto.PublicKey, sig, message []byte) error {
+	vfr, err := signature.LoadVerifier(pub, crypto.SHA256)
+	if err != nil {
+		return fmt.Errorf("could not load verifier: %w", err)
+	}
+	return vfr.VerifySignature(bytes.NewReader(sig), bytes.NewReader(message))
+}
@@ -0,0 +1,384 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sigstore_verifier
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	dsse_processor "github.com/guacsec/guac/pkg/handler/processor/dsse"
+	"github.com/guacsec/guac/pkg/ingestor/key"
+	"github.com/guacsec/guac/pkg/ingestor/verifier"
+	"github.com/guacsec/guac/pkg/logging"
+	"github.com/sigstore/sigstore/pkg/signature"
+	"github.com/sigstore/sigstore/pkg/signature/dsse"
+)
+
+const (
+	keylessIssuer  = "https://token.actions.githubusercontent.com"
+	keylessSubject = "https://github.com/guacsec/guac/.github/workflows/release.yaml@refs/tags/v0.1.0"
+)
+
+// keylessFixture is a Fulcio root, a signing certificate it issued and a
+// Rekor log, all expired or signed an hour ago like in a real bundle
+type keylessFixture struct {
+	t        *testing.T
+	root     *x509.Certificate
+	leaf     *x509.Certificate
+	leafKey  *ecdsa.PrivateKey
+	rekorKey *ecdsa.PrivateKey
+	signedAt time.Time
+}
+
+func newKeylessFixture(t *testing.T) *keylessFixture {
+	t.Helper()
+	f := &keylessFixture{t: t, signedAt: time.Now().Add(-time.Hour).Truncate(time.Second)}
+	rootKey := f.newKey()
+	f.root = f.newCert(&x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "fulcio-test"},
+		NotBefore:             f.signedAt.Add(-time.Hour),
+		NotAfter:              f.signedAt.Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, nil, &rootKey.PublicKey, rootKey)
+
+	issuer, err := asn1.MarshalWithParams(keylessIssuer, "utf8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	subject, err := url.Parse(keylessSubject)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.leafKey = f.newKey()
+	f.leaf = f.newCert(&x509.Certificate{
+		SerialNumber:    big.NewInt(2),
+		NotBefore:       f.signedAt.Add(-time.Minute),
+		NotAfter:        f.signedAt.Add(9 * time.Minute),
+		KeyUsage:        x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:     []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+		URIs:            []*url.URL{subject},
+		ExtraExtensions: []pkix.Extension{{Id: fulcioIssuerOID, Value: issuer}},
+	}, f.root, &f.leafKey.PublicKey, rootKey)
+	f.rekorKey = f.newKey()
+	return f
+}
+
+func (f *keylessFixture) newKey() *ecdsa.PrivateKey {
+	k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	return k
+}
+
+func (f *keylessFixture) newCert(template, parent *x509.Certificate, pub crypto.PublicKey, priv crypto.Signer) *x509.Certificate {
+	if parent == nil {
+		parent = template
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, pub, priv)
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	return cert
+}
+
+func (f *keylessFixture) leafPEM() []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: f.leaf.Raw})
+}
+
+// envelope signs the payload with the key of the signing certificate
+func (f *keylessFixture) envelope(payload []byte) []byte {
+	signer, err := signature.LoadECDSASigner(f.leafKey, crypto.SHA256)
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	env, err := dsse.WrapSigner(signer, "application/vnd.in-toto+json").SignMessage(bytes.NewReader(payload))
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	return env
+}
+
+func (f *keylessFixture) logSign(message []byte) []byte {
+	h := sha256.Sum256(message)
+	sig, err := ecdsa.SignASN1(rand.Reader, f.rekorKey, h[:])
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	return sig
+}
+
+// intotoBody is an intoto entry logging the payload hash of an envelope
+// signed with the signing certificate
+func (f *keylessFixture) intotoBody(payloadHash string) []byte {
+	body, err := json.Marshal(map[string]interface{}{
+		"apiVersion": "0.0.2",
+		"kind":       "intoto",
+		"spec": map[string]interface{}{
+			"content": map[string]interface{}{
+				"envelope": map[string]interface{}{
+					"payloadType": "application/vnd.in-toto+json",
+					"signatures": []map[string]string{{
+						"sig":       base64.StdEncoding.EncodeToString([]byte("sig")),
+						"publicKey": base64.StdEncoding.EncodeToString(f.leafPEM()),
+					}},
+				},
+				"payloadHash": map[string]string{"algorithm": "sha256", "value": payloadHash},
+			},
+		},
+	})
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	return body
+}
+
+// hashedrekordBody is a hashedrekord entry logging the signature of the
+// payload hash by the signing certificate
+func (f *keylessFixture) hashedrekordBody(payloadHash string) []byte {
+	body, err := json.Marshal(map[string]interface{}{
+		"apiVersion": "0.0.1",
+		"kind":       "hashedrekord",
+		"spec": map[string]interface{}{
+			"data": map[string]interface{}{
+				"hash": map[string]string{"algorithm": "sha256", "value": payloadHash},
+			},
+			"signature": map[string]interface{}{
+				"content":   base64.StdEncoding.EncodeToString([]byte("sig")),
+				"publicKey": map[string]string{"content": base64.StdEncoding.EncodeToString(f.leafPEM())},
+			},
+		},
+	})
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	return body
+}
+
+// entry logs the body, second of a tree of two, with its inclusion promise
+// and proof
+func (f *keylessFixture) entry(body []byte) dsse_processor.TransparencyLogEntry {
+	var kind struct {
+		Kind       string `json:"kind"`
+		APIVersion string `json:"apiVersion"`
+	}
+	if err := json.Unmarshal(body, &kind); err != nil {
+		f.t.Fatal(err)
+	}
+	id, err := logID(&f.rekorKey.PublicKey)
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	keyID, _ := hex.DecodeString(id)
+	entry := dsse_processor.TransparencyLogEntry{
+		LogIndex:          1,
+		LogID:             dsse_processor.LogID{KeyID: keyID},
+		KindVersion:       dsse_processor.KindVersion{Kind: kind.Kind, Version: kind.APIVersion},
+		IntegratedTime:    dsse_processor.Int64(f.signedAt.Unix()),
+		CanonicalizedBody: body,
+	}
+	promised := `{"body":"` + base64.StdEncoding.EncodeToString(body) + `","integratedTime":` +
+		big.NewInt(f.signedAt.Unix()).String() + `,"logID":"` + id + `","logIndex":1}`
+	entry.InclusionPromise = &dsse_processor.InclusionPromise{SignedEntryTimestamp: f.logSign([]byte(promised))}
+
+	sibling := hashLeaf([]byte("first entry"))
+	root := hashChildren(sibling, hashLeaf(body))
+	checkpoint := "rekor.test - 1\n2\n" + base64.StdEncoding.EncodeToString(root) + "\n"
+	sig := append([]byte{0, 1, 2, 3}, f.logSign([]byte(checkpoint))...)
+	entry.InclusionProof = &dsse_processor.InclusionProof{
+		LogIndex: 1,
+		RootHash: root,
+		TreeSize: 2,
+		Hashes:   [][]byte{sibling},
+		Checkpoint: dsse_processor.Checkpoint{
+			Envelope: checkpoint + "\n— rekor.test " + base64.StdEncoding.EncodeToString(sig) + "\n",
+		},
+	}
+	return entry
+}
+
+func (f *keylessFixture) bundle(envelope []byte, entries ...dsse_processor.TransparencyLogEntry) []byte {
+	b, err := json.Marshal(dsse_processor.Bundle{
+		MediaType: "application/vnd.dev.sigstore.bundle+json;version=0.1",
+		VerificationMaterial: dsse_processor.VerificationMaterial{
+			X509CertificateChain: &dsse_processor.CertificateChain{
+				Certificates: []dsse_processor.Certificate{{RawBytes: f.leaf.Raw}},
+			},
+			TlogEntries: entries,
+		},
+		DSSEEnvelope: envelope,
+	})
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	return b
+}
+
+func TestSigstoreVerifier_VerifyKeyless(t *testing.T) {
+	ctx := logging.WithLogger(context.Background())
+	f := newKeylessFixture(t)
+	other := newKeylessFixture(t)
+
+	payload := []byte(`{"_type": "https://in-toto.io/Statement/v0.1"}`)
+	payloadHash := sha256.Sum256(payload)
+	envelope := f.envelope(payload)
+	entry := f.entry(f.intotoBody(hex.EncodeToString(payloadHash[:])))
+
+	noProof := entry
+	noProof.InclusionProof = nil
+	noPromise := entry
+	noPromise.InclusionPromise = nil
+	badProof := f.entry(f.intotoBody(hex.EncodeToString(payloadHash[:])))
+	badProof.InclusionProof.Hashes = [][]byte{hashLeaf([]byte("another entry"))}
+
+	// a signature with the certificate chain, as written by the SLSA
+	// generators, but without a transparency log entry
+	env, err := parseDSSE(envelope)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env.Signatures[0].Cert = string(f.leafPEM())
+	inlineCert, err := json.Marshal(env)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	leafKey, err := key.NewKey(&f.leafKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	identity := verifier.Identity{
+		ID:                     keylessSubject,
+		Key:                    *leafKey,
+		Issuer:                 keylessIssuer,
+		SubjectAlternativeName: keylessSubject,
+	}
+	verified := identity
+	verified.Verified = true
+
+	trusted := []Option{WithFulcio(f.root), WithRekor(&f.rekorKey.PublicKey)}
+	tests := []struct {
+		name    string
+		opts    []Option
+		payload []byte
+		want    []verifier.Identity
+	}{{
+		name:    "bundle",
+		opts:    trusted,
+		payload: f.bundle(envelope, entry),
+		want:    []verifier.Identity{verified},
+	}, {
+		name:    "bundle with hashedrekord entry",
+		opts:    trusted,
+		payload: f.bundle(envelope, f.entry(f.hashedrekordBody(hex.EncodeToString(payloadHash[:])))),
+		want:    []verifier.Identity{verified},
+	}, {
+		name:    "bundle without inclusion proof",
+		opts:    trusted,
+		payload: f.bundle(envelope, noProof),
+		want:    []verifier.Identity{verified},
+	}, {
+		name:    "bundle without inclusion promise",
+		opts:    trusted,
+		payload: f.bundle(envelope, noPromise),
+		want:    []verifier.Identity{identity},
+	}, {
+		name:    "invalid inclusion proof",
+		opts:    trusted,
+		payload: f.bundle(envelope, badProof),
+		want:    []verifier.Identity{identity},
+	}, {
+		name:    "entry of another payload",
+		opts:    trusted,
+		payload: f.bundle(envelope, f.entry(f.intotoBody(hex.EncodeToString(make([]byte, 32))))),
+		want:    []verifier.Identity{identity},
+	}, {
+		name:    "untrusted Fulcio root",
+		opts:    []Option{WithFulcio(other.root), WithRekor(&f.rekorKey.PublicKey)},
+		payload: f.bundle(envelope, entry),
+		want:    []verifier.Identity{identity},
+	}, {
+		name:    "untrusted Rekor log",
+		opts:    []Option{WithFulcio(f.root), WithRekor(&other.rekorKey.PublicKey)},
+		payload: f.bundle(envelope, entry),
+		want:    []verifier.Identity{identity},
+	}, {
+		name:    "no trusted roots",
+		payload: f.bundle(envelope, entry),
+		want:    []verifier.Identity{identity},
+	}, {
+		name:    "signed by another key",
+		opts:    trusted,
+		payload: f.bundle(other.envelope(payload), entry),
+		want:    []verifier.Identity{identity},
+	}, {
+		name:    "certificate in the envelope without transparency log entry",
+		opts:    trusted,
+		payload: inlineCert,
+		want:    []verifier.Identity{identity},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewSigstoreAndKeyVerifier(tt.opts...)
+			got, err := d.Verify(ctx, tt.payload)
+			if err != nil {
+				t.Fatalf("Verify() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Verify() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCertificates(t *testing.T) {
+	f := newKeylessFixture(t)
+	rootPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: f.root.Raw})
+	certs, err := ParseCertificates(append(f.leafPEM(), rootPEM...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(certs) != 2 || !certs[0].Equal(f.leaf) || !certs[1].Equal(f.root) {
+		t.Errorf("ParseCertificates() = %v, want the leaf and root certificates", certs)
+	}
+	if _, err := ParseCertificates([]byte(ecdsaPub)); err == nil {
+		t.Error("ParseCertificates() of a public key did not fail")
+	}
+}
@@ -19,33 +19,103 @@ import (
 	"bytes"
 	"context"
 	"crypto"
+	"crypto/x509"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 
+	dsse_processor "github.com/guacsec/guac/pkg/handler/processor/dsse"
 	"github.com/guacsec/guac/pkg/ingestor/key"
 	"github.com/guacsec/guac/pkg/ingestor/verifier"
-	"github.com/secure-systems-lab/go-securesystemslib/dsse"
+	"github.com/guacsec/guac/pkg/logging"
 	"github.com/sigstore/sigstore/pkg/signature"
 	sig_dsse "github.com/sigstore/sigstore/pkg/signature/dsse"
 )
 
 type sigstoreVerifier struct {
+	fulcioRoots         *x509.CertPool
+	fulcioIntermediates []*x509.Certificate
+	// rekorKeys are the keys of the trusted transparency logs by log ID
+	rekorKeys map[string]crypto.PublicKey
 }
 
-// NewSigstoreVerifier initializes the sigstore verifier
-func NewSigstoreAndKeyVerifier() *sigstoreVerifier {
-	return &sigstoreVerifier{}
+// Option configures the verifier returned by NewSigstoreAndKeyVerifier
+type Option func(*sigstoreVerifier)
+
+// WithFulcio verifies the keyless signatures whose certificate chains up to
+// one of the self-signed certs, possibly through the others
+func WithFulcio(certs ...*x509.Certificate) Option {
+	return func(d *sigstoreVerifier) {
+		for _, c := range certs {
+			if bytes.Equal(c.RawIssuer, c.RawSubject) && c.CheckSignatureFrom(c) == nil {
+				if d.fulcioRoots == nil {
+					d.fulcioRoots = x509.NewCertPool()
+				}
+				d.fulcioRoots.AddCert(c)
+			} else {
+				d.fulcioIntermediates = append(d.fulcioIntermediates, c)
+			}
+		}
+	}
+}
+
+// WithRekor trusts the entries of the Rekor transparency logs signed by keys,
+// which keyless signatures must be logged in
+func WithRekor(keys ...crypto.PublicKey) Option {
+	return func(d *sigstoreVerifier) {
+		for _, k := range keys {
+			if id, err := logID(k); err == nil {
+				d.rekorKeys[id] = k
+			}
+		}
+	}
+}
+
+// NewSigstoreVerifier initializes the sigstore verifier. Signatures are
+// verified with the key found for their key ID, or for keyless signatures
+// with the key of their Fulcio certificate, see WithFulcio and WithRekor.
+func NewSigstoreAndKeyVerifier(opts ...Option) *sigstoreVerifier {
+	d := &sigstoreVerifier{
+		rekorKeys: map[string]crypto.PublicKey{},
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
 }
 
-// Verify validates that the signature is valid for the payload
+// Verify validates that the signature is valid for the payload, a DSSE
+// envelope or the Sigstore bundle of one.
+//
+// A keyless signature is only verified if its Fulcio certificate was valid
+// when it was logged in the transparency log, which requires the Rekor entry
+// of the bundle. Its identity is returned nonetheless, unverified.
 // TODO: this currently only supports SHA256 hash function when validating signatures
 func (d *sigstoreVerifier) Verify(ctx context.Context, payloadBytes []byte) ([]verifier.Identity, error) {
 	identities := []verifier.Identity{}
+	var bundle *dsse_processor.Bundle
+	if b, err := dsse_processor.ParseBundle(payloadBytes); err == nil {
+		bundle = b
+		payloadBytes = b.DSSEEnvelope
+	}
 	envelope, err := parseDSSE(payloadBytes)
 	if err != nil {
 		return nil, err
 	}
 	for _, signature := range envelope.Signatures {
+		certs, err := signingCertificates(signature, bundle)
+		if err != nil {
+			return nil, err
+		}
+		if certs != nil {
+			foundIdentity, err := d.verifyKeyless(ctx, envelope, payloadBytes, signature.KeyID, certs, bundle)
+			if err != nil {
+				return nil, err
+			}
+			identities = append(identities, *foundIdentity)
+			continue
+		}
+
 		key, err := key.Find(ctx, signature.KeyID)
 		if err != nil {
 			return nil, err
@@ -68,6 +138,48 @@ func (d *sigstoreVerifier) Verify(ctx context.Context, payloadBytes []byte) ([]v
 	return identities, nil
 }
 
+// verifyKeyless returns the identity certified by the signing certificate,
+// the first of certs. Its ID is the key ID of the signature, if any, or else
+// the subject alternative name of the certificate.
+func (d *sigstoreVerifier) verifyKeyless(ctx context.Context, env *envelope, envelopeBytes []byte, keyID string, certs []*x509.Certificate, bundle *dsse_processor.Bundle) (*verifier.Identity, error) {
+	logger := logging.FromContext(ctx)
+	cert := certs[0]
+	k, err := key.NewKey(cert.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signing certificate key: %w", err)
+	}
+	issuer, san := certificateIdentity(cert)
+	foundIdentity := &verifier.Identity{
+		ID:                     keyID,
+		Key:                    *k,
+		Issuer:                 issuer,
+		SubjectAlternativeName: san,
+	}
+	if foundIdentity.ID == "" {
+		foundIdentity.ID = san
+	}
+
+	if err := verifySignature(k.Val, envelopeBytes); err != nil {
+		logger.Debugf("keyless signature of %s not verified: %v", san, err)
+		return foundIdentity, nil
+	}
+	payload, err := base64.StdEncoding.DecodeString(env.Payload)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DSSE payload: %w", err)
+	}
+	signedAt, err := d.verifyTransparencyLog(bundle, cert, payload)
+	if err != nil {
+		logger.Debugf("keyless signature of %s not verified: %v", san, err)
+		return foundIdentity, nil
+	}
+	if err := d.verifyChain(certs, signedAt); err != nil {
+		logger.Debugf("keyless signature of %s not verified: %v", san, err)
+		return foundIdentity, nil
+	}
+	foundIdentity.Verified = true
+	return foundIdentity, nil
+}
+
 // Type returns the type of the verifier
 func (d *sigstoreVerifier) Type() verifier.VerifierType {
 	return "sigstore"
@@ -87,8 +199,22 @@ func verifySignature(k crypto.PublicKey, payload []byte) error {
 	return nil
 }
 
-func parseDSSE(b []byte) (*dsse.Envelope, error) {
-	envelope := dsse.Envelope{}
+// envelope is a DSSE envelope whose signatures may carry the Fulcio
+// certificate chain of a keyless signature, in PEM
+type envelope struct {
+	PayloadType string              `json:"payloadType"`
+	Payload     string              `json:"payload"`
+	Signatures  []envelopeSignature `json:"signatures"`
+}
+
+type envelopeSignature struct {
+	KeyID string `json:"keyid"`
+	Sig   string `json:"sig"`
+	Cert  string `json:"cert,omitempty"`
+}
+
+func parseDSSE(b []byte) (*envelope, error) {
+	envelope := envelope{}
 	if err := json.Unmarshal(b, &envelope); err != nil {
 		return nil, err
 	}
@@ -47,10 +47,16 @@ type Verifier interface {
 // identity has been verified, usually based on signature matching the key.
 // This shouldn't be used to indicate that the Identity is trusted in any
 // way.
+//
+// Issuer and SubjectAlternativeName are set for keyless signatures, whose
+// key is certified by a Fulcio certificate: they are the OIDC issuer and the
+// identity (e.g. an email address or a workflow URI) of the signer.
 type Identity struct {
-	ID       string
-	Key      key.Key
-	Verified bool
+	ID                     string
+	Key                    key.Key
+	Verified               bool
+	Issuer                 string
+	SubjectAlternativeName string
 }
 
 var (
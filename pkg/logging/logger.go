@@ -17,16 +17,51 @@ package logging
 
 import (
 	"context"
+	"fmt"
+	"strings"
+	"sync"
 
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Components of the pipeline that can be given their own log level
+const (
+	ComponentCollector = "collector"
+	ComponentProcessor = "processor"
+	ComponentIngestor  = "ingestor"
+	ComponentAssembler = "assembler"
+	ComponentCertifier = "certifier"
+	ComponentEmitter   = "emitter"
 )
 
 var logger *zap.SugaredLogger
 
+// levels holds the log levels of the logger, which can be changed with
+// Configure once loggers have been handed out
+var levels = &componentLevels{level: zapcore.InfoLevel}
+
+// CollectorNameKey is the logger field holding the name of the collector
+// that collected the document being handled
+const CollectorNameKey = "collector_name"
+
 type loggerKey struct{}
 
+// collectorNameKey holds the name given to WithCollectorName
+type collectorNameKey struct{}
+
+// componentBaseKey holds the logger that WithComponent named after a
+// component, so that the name of a later component replaces it instead of
+// being nested under it
+type componentBaseKey struct{}
+
 func init() {
-	zapLogger, _ := zap.NewProduction()
+	config := zap.NewProductionConfig()
+	// filtering is done by the componentCore
+	config.Level = zap.NewAtomicLevelAt(zapcore.DebugLevel)
+	zapLogger, _ := config.Build(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return &componentCore{Core: core, levels: levels}
+	}))
 
 	// flushes buffer, if any
 	defer func() {
@@ -37,10 +72,92 @@ func init() {
 	logger = zapLogger.Sugar()
 }
 
+// Configure sets the log level (debug, info, warn or error) and the per
+// component overrides given as `component=level` pairs separated by commas,
+// e.g. `collector=debug,assembler=warn`. An empty level keeps info.
+func Configure(level string, components string) error {
+	l := zapcore.InfoLevel
+	if level != "" {
+		var err error
+		if l, err = parseLevel(level); err != nil {
+			return err
+		}
+	}
+	overrides := map[string]zapcore.Level{}
+	for _, pair := range strings.Split(components, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		name, value, found := strings.Cut(pair, "=")
+		name = strings.TrimSpace(name)
+		if !found || name == "" {
+			return fmt.Errorf("invalid component log level %q, expected component=level", pair)
+		}
+		cl, err := parseLevel(value)
+		if err != nil {
+			return fmt.Errorf("invalid log level for component %s: %w", name, err)
+		}
+		overrides[name] = cl
+	}
+	levels.set(l, overrides)
+	return nil
+}
+
+func parseLevel(level string) (zapcore.Level, error) {
+	switch strings.ToLower(strings.TrimSpace(level)) {
+	case "debug":
+		return zapcore.DebugLevel, nil
+	case "info":
+		return zapcore.InfoLevel, nil
+	case "warn":
+		return zapcore.WarnLevel, nil
+	case "error":
+		return zapcore.ErrorLevel, nil
+	default:
+		return zapcore.InfoLevel, fmt.Errorf("unknown log level %q, expected debug, info, warn or error", level)
+	}
+}
+
 func WithLogger(ctx context.Context) context.Context {
 	return context.WithValue(ctx, loggerKey{}, logger)
 }
 
+// WithComponent returns a context whose logger logs as the given component,
+// using the log level configured for it. The logger is derived from the one
+// of ctx, keeping its fields, and replaces the component it was logging as.
+// Contexts without a logger are returned as is.
+func WithComponent(ctx context.Context, component string) context.Context {
+	l, ok := ctx.Value(loggerKey{}).(*zap.SugaredLogger)
+	if !ok {
+		return ctx
+	}
+	if base, ok := ctx.Value(componentBaseKey{}).(*zap.SugaredLogger); ok {
+		l = base
+	}
+	ctx = context.WithValue(ctx, componentBaseKey{}, l)
+	return context.WithValue(ctx, loggerKey{}, l.Named(component))
+}
+
+// WithCollectorName returns a context whose logger adds the name of the
+// collector of the document being handled to each entry, including in the
+// later pipeline components. An empty name or a context without a logger
+// is returned as is.
+func WithCollectorName(ctx context.Context, name string) context.Context {
+	l, ok := ctx.Value(loggerKey{}).(*zap.SugaredLogger)
+	if !ok || name == "" {
+		return ctx
+	}
+	if current, _ := ctx.Value(collectorNameKey{}).(string); current == name {
+		return ctx
+	}
+	ctx = context.WithValue(ctx, collectorNameKey{}, name)
+	if base, ok := ctx.Value(componentBaseKey{}).(*zap.SugaredLogger); ok {
+		ctx = context.WithValue(ctx, componentBaseKey{}, base.With(CollectorNameKey, name))
+	}
+	return context.WithValue(ctx, loggerKey{}, l.With(CollectorNameKey, name))
+}
+
 func FromContext(ctx context.Context) *zap.SugaredLogger {
 	if logger, ok := ctx.Value(loggerKey{}).(*zap.SugaredLogger); ok {
 		return logger
@@ -48,3 +165,76 @@ func FromContext(ctx context.Context) *zap.SugaredLogger {
 
 	return zap.NewNop().Sugar()
 }
+
+// componentLevels is the log level of each component, falling back to the
+// default level
+type componentLevels struct {
+	mu         sync.RWMutex
+	level      zapcore.Level
+	components map[string]zapcore.Level
+	// min is the lowest of all levels
+	min zapcore.Level
+}
+
+func (c *componentLevels) set(level zapcore.Level, components map[string]zapcore.Level) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.level = level
+	c.components = components
+	c.min = level
+	for _, l := range components {
+		if l < c.min {
+			c.min = l
+		}
+	}
+}
+
+// enabled reports whether any component logs at level
+func (c *componentLevels) enabled(level zapcore.Level) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.min.Enabled(level)
+}
+
+// forLogger returns the level of the logger with the given name. Named
+// loggers under a component (e.g. `collector.oci`) use the level of the
+// most specific configured name.
+func (c *componentLevels) forLogger(name string) zapcore.Level {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for name != "" {
+		if l, ok := c.components[name]; ok {
+			return l
+		}
+		i := strings.LastIndex(name, ".")
+		if i < 0 {
+			break
+		}
+		name = name[:i]
+	}
+	return c.level
+}
+
+// componentCore filters entries by the level of the logger they are logged
+// with
+type componentCore struct {
+	zapcore.Core
+	levels *componentLevels
+}
+
+func (c *componentCore) Enabled(level zapcore.Level) bool {
+	return c.levels.enabled(level)
+}
+
+func (c *componentCore) With(fields []zapcore.Field) zapcore.Core {
+	return &componentCore{Core: c.Core.With(fields), levels: c.levels}
+}
+
+// Check leaves the entries enabled for their logger to the wrapped core, so
+// that its own checks (e.g. sampling) still apply
+func (c *componentCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !c.levels.forLogger(entry.LoggerName).Enabled(entry.Level) {
+		return checked
+	}
+	return c.Core.Check(entry, checked)
+}
@@ -0,0 +1,193 @@
+//
+// Copyright 2022 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logging
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestConfigure(t *testing.T) {
+	defer func() {
+		_ = Configure("", "")
+	}()
+
+	tests := []struct {
+		name       string
+		level      string
+		components string
+		want       map[string]zapcore.Level
+		wantErr    bool
+	}{{
+		name: "default",
+		want: map[string]zapcore.Level{"": zapcore.InfoLevel, "collector": zapcore.InfoLevel},
+	}, {
+		name:  "level",
+		level: "WARN",
+		want:  map[string]zapcore.Level{"": zapcore.WarnLevel, "assembler": zapcore.WarnLevel},
+	}, {
+		name:       "components",
+		level:      "error",
+		components: "collector=debug, assembler=info,collector.oci=warn",
+		want: map[string]zapcore.Level{
+			"":                  zapcore.ErrorLevel,
+			"collector":         zapcore.DebugLevel,
+			"collector.file":    zapcore.DebugLevel,
+			"collector.oci":     zapcore.WarnLevel,
+			"collector.oci.tag": zapcore.WarnLevel,
+			"assembler":         zapcore.InfoLevel,
+			"processor":         zapcore.ErrorLevel,
+		},
+	}, {
+		name:    "unknown level",
+		level:   "verbose",
+		wantErr: true,
+	}, {
+		name:       "missing component level",
+		components: "collector",
+		wantErr:    true,
+	}, {
+		name:       "unknown component level",
+		components: "collector=trace",
+		wantErr:    true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Configure(tt.level, tt.components)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Configure() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			for name, want := range tt.want {
+				if got := levels.forLogger(name); got != want {
+					t.Errorf("level of %q = %v, want %v", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestComponentCore(t *testing.T) {
+	defer func() {
+		_ = Configure("", "")
+	}()
+	if err := Configure("warn", "collector=debug"); err != nil {
+		t.Fatal(err)
+	}
+
+	inner, _ := observer.New(zapcore.DebugLevel)
+	core := &componentCore{Core: inner, levels: levels}
+	if !core.Enabled(zapcore.DebugLevel) {
+		t.Errorf("core should be enabled at the lowest component level")
+	}
+	tests := []struct {
+		logger string
+		level  zapcore.Level
+		want   bool
+	}{
+		{logger: "", level: zapcore.InfoLevel, want: false},
+		{logger: "", level: zapcore.WarnLevel, want: true},
+		{logger: "collector", level: zapcore.DebugLevel, want: true},
+		{logger: "assembler", level: zapcore.DebugLevel, want: false},
+	}
+	for _, tt := range tests {
+		checked := core.Check(zapcore.Entry{LoggerName: tt.logger, Level: tt.level}, nil)
+		if got := checked != nil; got != tt.want {
+			t.Errorf("Check(%q, %v) logged = %v, want %v", tt.logger, tt.level, got, tt.want)
+		}
+	}
+}
+
+func TestWithCollectorName(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	defer func(l *zap.SugaredLogger) {
+		logger = l
+	}(logger)
+	logger = zap.New(core).Sugar()
+
+	ctx := WithCollectorName(WithLogger(context.Background()), "file")
+	// the name is kept by later components
+	FromContext(WithComponent(ctx, ComponentProcessor)).Info("processed")
+	// and not repeated when set again
+	FromContext(WithCollectorName(ctx, "file")).Info("collected")
+
+	entries := logs.AllUntimed()
+	if len(entries) != 2 {
+		t.Fatalf("got %d log entries, want 2", len(entries))
+	}
+	if entries[0].LoggerName != ComponentProcessor {
+		t.Errorf("logger name = %q, want %q", entries[0].LoggerName, ComponentProcessor)
+	}
+	for _, e := range entries {
+		fields := e.ContextMap()
+		if len(fields) != 1 || fields[CollectorNameKey] != "file" {
+			t.Errorf("entry %q fields = %v, want %s=file", e.Message, fields, CollectorNameKey)
+		}
+	}
+
+	if got := WithCollectorName(context.Background(), "file"); got != context.Background() {
+		t.Errorf("WithCollectorName() without logger should return the context as is")
+	}
+}
+
+func TestComponentCore_sampling(t *testing.T) {
+	inner, logs := observer.New(zapcore.DebugLevel)
+	sampled := zapcore.NewSamplerWithOptions(inner, time.Minute, 2, 0)
+	l := zap.New(&componentCore{Core: sampled, levels: levels})
+
+	for i := 0; i < 5; i++ {
+		l.Info("repeated")
+	}
+	if got := logs.Len(); got != 2 {
+		t.Errorf("got %d log entries, want the 2 kept by the sampler", got)
+	}
+}
+
+func TestWithComponent(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	defer func(l *zap.SugaredLogger) {
+		logger = l
+	}(logger)
+	logger = zap.New(core).Sugar()
+
+	// the fields of the logger of the context are kept
+	ctx := context.WithValue(context.Background(), loggerKey{}, logger.With("document", "sbom.json"))
+	ctx = WithComponent(ctx, ComponentProcessor)
+	FromContext(ctx).Info("processed")
+	// and a later component replaces the previous one
+	FromContext(WithComponent(ctx, ComponentIngestor)).Info("ingested")
+
+	entries := logs.AllUntimed()
+	if len(entries) != 2 {
+		t.Fatalf("got %d log entries, want 2", len(entries))
+	}
+	for i, want := range []string{ComponentProcessor, ComponentIngestor} {
+		if entries[i].LoggerName != want {
+			t.Errorf("entry %q logger name = %q, want %q", entries[i].Message, entries[i].LoggerName, want)
+		}
+		if fields := entries[i].ContextMap(); fields["document"] != "sbom.json" {
+			t.Errorf("entry %q fields = %v, want document=sbom.json", entries[i].Message, fields)
+		}
+	}
+
+	if got := WithComponent(context.Background(), ComponentIngestor); got != context.Background() {
+		t.Errorf("WithComponent() without logger should return the context as is")
+	}
+}
@@ -0,0 +1,436 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package metrics counts and times the documents going through the stages of
+// the pipeline (collect, process, parse and store), and serves them on
+// /metrics in the Prometheus text exposition format, so that a stalled
+// ingestion can be alerted on.
+//
+// The metrics are registered when declared, as package variables of the
+// packages they instrument, and are served by every process importing them,
+// whether or not its stage updates them.
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/guacsec/guac/pkg/logging"
+)
+
+const (
+	// Path is the path the metrics are served on
+	Path = "/metrics"
+
+	// contentType is the Prometheus text exposition format
+	contentType = "text/plain; version=0.0.4; charset=utf-8"
+
+	// shutdownTimeout bounds the time the metrics server takes to stop
+	shutdownTimeout = 5 * time.Second
+)
+
+// DefaultBuckets are the upper bounds in seconds of the histogram buckets of
+// durations, from 5ms to a minute
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60}
+
+// metric is a metric written in the text exposition format
+type metric interface {
+	write(w io.Writer) error
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]metric{}
+)
+
+// register registers m under name, panicking if the name is taken since the
+// metrics are declared once as package variables
+func register(name string, m metric) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, ok := registry[name]; ok {
+		panic(fmt.Sprintf("metric %s is registered more than once", name))
+	}
+	registry[name] = m
+}
+
+// desc is the name, help and label names of a metric
+type desc struct {
+	name   string
+	help   string
+	labels []string
+}
+
+// key returns the key of the values of the labels, which must be one per
+// label name
+func (d desc) key(labelValues []string) string {
+	if len(labelValues) != len(d.labels) {
+		panic(fmt.Sprintf("metric %s has labels %v, got values %v", d.name, d.labels, labelValues))
+	}
+	return strings.Join(labelValues, "\xff")
+}
+
+// header writes the HELP and TYPE lines of the metric
+func (d desc) header(w io.Writer, typ string) error {
+	_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", d.name, strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(d.help), d.name, typ)
+	return err
+}
+
+// sample writes a sample of the metric, suffix being appended to its name
+// and extra label pairs to its labels
+func (d desc) sample(w io.Writer, suffix string, key string, value float64, extra ...string) error {
+	var pairs []string
+	if len(d.labels) > 0 {
+		for i, v := range strings.Split(key, "\xff") {
+			pairs = append(pairs, d.labels[i]+`="`+escapeLabel(v)+`"`)
+		}
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, extra[i]+`="`+escapeLabel(extra[i+1])+`"`)
+	}
+	labels := ""
+	if len(pairs) > 0 {
+		labels = "{" + strings.Join(pairs, ",") + "}"
+	}
+	_, err := fmt.Fprintf(w, "%s%s%s %s\n", d.name, suffix, labels, formatValue(value))
+	return err
+}
+
+// escapeLabel escapes the backslashes, double quotes and line feeds of a
+// label value
+func escapeLabel(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+}
+
+// formatValue formats a sample value as Prometheus parses it
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// sortedKeys returns the keys of values in order, so that the samples are
+// written in a stable order
+func sortedKeys[V any](values map[string]V) []string {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Counter is a value only going up, e.g. the number of documents collected,
+// for each value of its labels
+type Counter struct {
+	desc
+	mu     sync.Mutex
+	values map[string]float64
+}
+
+// NewCounter registers a counter with the given label names
+func NewCounter(name string, help string, labels ...string) *Counter {
+	c := &Counter{desc: desc{name: name, help: help, labels: labels}, values: map[string]float64{}}
+	register(name, c)
+	return c
+}
+
+// Inc adds one to the counter of the label values
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add adds v, which must not be negative, to the counter of the label values
+func (c *Counter) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		panic(fmt.Sprintf("counter %s cannot decrease, got %v", c.name, v))
+	}
+	key := c.key(labelValues)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.values[key] += v
+}
+
+// Value returns the counter of the label values
+func (c *Counter) Value(labelValues ...string) float64 {
+	key := c.key(labelValues)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.values[key]
+}
+
+func (c *Counter) write(w io.Writer) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.header(w, "counter"); err != nil {
+		return err
+	}
+	for _, key := range sortedKeys(c.values) {
+		if err := c.sample(w, "", key, c.values[key]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Gauge is a value going up and down, e.g. the time of the last document
+// stored, for each value of its labels
+type Gauge struct {
+	desc
+	mu     sync.Mutex
+	values map[string]float64
+}
+
+// NewGauge registers a gauge with the given label names
+func NewGauge(name string, help string, labels ...string) *Gauge {
+	g := &Gauge{desc: desc{name: name, help: help, labels: labels}, values: map[string]float64{}}
+	register(name, g)
+	return g
+}
+
+// Set sets the gauge of the label values
+func (g *Gauge) Set(v float64, labelValues ...string) {
+	key := g.key(labelValues)
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.values[key] = v
+}
+
+// SetToCurrentTime sets the gauge of the label values to the current Unix
+// time in seconds
+func (g *Gauge) SetToCurrentTime(labelValues ...string) {
+	g.Set(float64(time.Now().UnixNano())/1e9, labelValues...)
+}
+
+// Value returns the gauge of the label values
+func (g *Gauge) Value(labelValues ...string) float64 {
+	key := g.key(labelValues)
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.values[key]
+}
+
+func (g *Gauge) write(w io.Writer) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if err := g.header(w, "gauge"); err != nil {
+		return err
+	}
+	for _, key := range sortedKeys(g.values) {
+		if err := g.sample(w, "", key, g.values[key]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GaugeFunc is a gauge read when the metrics are served, e.g. the number of
+// documents waiting on each subject of the broker, by the value of its label
+type GaugeFunc struct {
+	desc
+	mu sync.Mutex
+	f  func() map[string]float64
+}
+
+// NewGaugeFunc registers a gauge func with a single label, read with the
+// function set by SetFunc
+func NewGaugeFunc(name string, help string, label string) *GaugeFunc {
+	g := &GaugeFunc{desc: desc{name: name, help: help, labels: []string{label}}}
+	register(name, g)
+	return g
+}
+
+// SetFunc sets the function returning the gauge by label value, nil to
+// write no samples
+func (g *GaugeFunc) SetFunc(f func() map[string]float64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.f = f
+}
+
+func (g *GaugeFunc) write(w io.Writer) error {
+	g.mu.Lock()
+	f := g.f
+	g.mu.Unlock()
+	if err := g.header(w, "gauge"); err != nil {
+		return err
+	}
+	if f == nil {
+		return nil
+	}
+	values := f()
+	for _, key := range sortedKeys(values) {
+		if err := g.sample(w, "", key, values[key]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Histogram counts observed values, e.g. durations, in buckets, for each
+// value of its labels
+type Histogram struct {
+	desc
+	buckets []float64
+	mu      sync.Mutex
+	values  map[string]*histogramValue
+}
+
+// histogramValue is the observations of a histogram for label values
+type histogramValue struct {
+	// counts are the observations in each bucket, not cumulative
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// NewHistogram registers a histogram with the given bucket upper bounds, in
+// increasing order, DefaultBuckets if nil, and label names
+func NewHistogram(name string, help string, buckets []float64, labels ...string) *Histogram {
+	if buckets == nil {
+		buckets = DefaultBuckets
+	}
+	if !sort.Float64sAreSorted(buckets) {
+		panic(fmt.Sprintf("histogram %s buckets are not in increasing order: %v", name, buckets))
+	}
+	h := &Histogram{desc: desc{name: name, help: help, labels: labels}, buckets: buckets, values: map[string]*histogramValue{}}
+	register(name, h)
+	return h
+}
+
+// Observe adds v to the histogram of the label values
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	key := h.key(labelValues)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	hv, ok := h.values[key]
+	if !ok {
+		hv = &histogramValue{counts: make([]uint64, len(h.buckets))}
+		h.values[key] = hv
+	}
+	if i := sort.SearchFloat64s(h.buckets, v); i < len(h.buckets) {
+		hv.counts[i]++
+	}
+	hv.count++
+	hv.sum += v
+}
+
+// ObserveSince adds the seconds elapsed since start to the histogram of the
+// label values
+func (h *Histogram) ObserveSince(start time.Time, labelValues ...string) {
+	h.Observe(time.Since(start).Seconds(), labelValues...)
+}
+
+// Count returns the number of values observed for the label values
+func (h *Histogram) Count(labelValues ...string) uint64 {
+	key := h.key(labelValues)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if hv, ok := h.values[key]; ok {
+		return hv.count
+	}
+	return 0
+}
+
+func (h *Histogram) write(w io.Writer) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if err := h.header(w, "histogram"); err != nil {
+		return err
+	}
+	for _, key := range sortedKeys(h.values) {
+		hv := h.values[key]
+		var cumulative uint64
+		for i, upper := range h.buckets {
+			cumulative += hv.counts[i]
+			if err := h.sample(w, "_bucket", key, float64(cumulative), "le", formatValue(upper)); err != nil {
+				return err
+			}
+		}
+		if err := h.sample(w, "_bucket", key, float64(hv.count), "le", "+Inf"); err != nil {
+			return err
+		}
+		if err := h.sample(w, "_sum", key, hv.sum); err != nil {
+			return err
+		}
+		if err := h.sample(w, "_count", key, float64(hv.count)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Write writes the registered metrics to w in the text exposition format,
+// ordered by name
+func Write(w io.Writer) error {
+	registryMu.RLock()
+	metrics := make(map[string]metric, len(registry))
+	for name, m := range registry {
+		metrics[name] = m
+	}
+	registryMu.RUnlock()
+	for _, name := range sortedKeys(metrics) {
+		if err := metrics[name].write(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Handler returns the HTTP handler serving the registered metrics
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		_ = Write(w)
+	})
+}
+
+// ListenAndServe serves the metrics on Path at addr until ctx is cancelled
+func ListenAndServe(ctx context.Context, addr string) error {
+	logger := logging.FromContext(ctx)
+	mux := http.NewServeMux()
+	mux.Handle(Path, Handler())
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: shutdownTimeout,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+	logger.Infof("metrics server listening on %s", addr)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCounter(t *testing.T) {
+	c := NewCounter("test_counter_total", "Documents counted", "collector")
+	c.Inc("file")
+	c.Add(2, "file")
+	c.Inc("oci")
+	if got := c.Value("file"); got != 3 {
+		t.Errorf("got %v, want 3", got)
+	}
+	want := `# HELP test_counter_total Documents counted
+# TYPE test_counter_total counter
+test_counter_total{collector="file"} 3
+test_counter_total{collector="oci"} 1
+`
+	var b strings.Builder
+	if err := c.write(&b); err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", b.String(), want)
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	h := NewHistogram("test_duration_seconds", "Durations", []float64{0.1, 1})
+	h.Observe(0.05)
+	h.Observe(0.1)
+	h.Observe(0.5)
+	h.Observe(2)
+	if got := h.Count(); got != 4 {
+		t.Errorf("got count %d, want 4", got)
+	}
+	want := `# HELP test_duration_seconds Durations
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{le="0.1"} 2
+test_duration_seconds_bucket{le="1"} 3
+test_duration_seconds_bucket{le="+Inf"} 4
+test_duration_seconds_sum 2.65
+test_duration_seconds_count 4
+`
+	var b strings.Builder
+	if err := h.write(&b); err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", b.String(), want)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	g := NewGaugeFunc("test_pending_messages", "Pending messages", "subject")
+	g.SetFunc(func() map[string]float64 {
+		return map[string]float64{`DOCUMENTS."collected"`: 7}
+	})
+	NewGauge("test_last_stored_timestamp_seconds", "Last stored").Set(1700000000)
+
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, Path, nil))
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("got content type %q", ct)
+	}
+	body := rec.Body.String()
+	for _, want := range []string{
+		`test_pending_messages{subject="DOCUMENTS.\"collected\""} 7`,
+		"test_last_stored_timestamp_seconds 1.7e+09",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("missing %q in\n%s", want, body)
+		}
+	}
+	if strings.Index(body, "test_last_stored") > strings.Index(body, "test_pending") {
+		t.Errorf("metrics not ordered by name:\n%s", body)
+	}
+}
+
+func TestRegister_Duplicate(t *testing.T) {
+	NewCounter("test_duplicate_total", "Duplicate")
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic registering a metric twice")
+		}
+	}()
+	NewCounter("test_duplicate_total", "Duplicate")
+}
@@ -0,0 +1,235 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package pipeline runs the collectors, processor, parser and assembler of
+// GUAC in a single process, connected through channels instead of a broker
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/guacsec/guac/pkg/assembler"
+	"github.com/guacsec/guac/pkg/handler/collector"
+	"github.com/guacsec/guac/pkg/handler/processor"
+	"github.com/guacsec/guac/pkg/handler/processor/hashcache"
+	"github.com/guacsec/guac/pkg/handler/processor/process"
+	"github.com/guacsec/guac/pkg/ingestor/parser"
+	"github.com/guacsec/guac/pkg/logging"
+	"github.com/guacsec/guac/pkg/tracing"
+)
+
+// DefaultBufferSize is the number of documents waiting between two stages
+const DefaultBufferSize int = 100
+
+// CollectFunc runs the collectors, passing each document collected to emit,
+// e.g. with collector.CollectWithTimeout
+type CollectFunc func(ctx context.Context, emit collector.Emitter) error
+
+// AssembleFunc stores the graphs of a document tree in the graph db
+type AssembleFunc func(ctx context.Context, graphs []assembler.Graph) error
+
+// Config configures a standalone pipeline
+type Config struct {
+	// Processors is the number of documents processed concurrently, 1 if 0
+	Processors int
+	// BufferSize is the number of documents waiting between two stages,
+	// DefaultBufferSize if 0
+	BufferSize int
+	// Seen skips the documents whose hash it holds, and is given the hash of
+	// the documents fully stored. A nil Seen disables the check.
+	Seen *hashcache.Cache
+}
+
+// Stats counts the documents of a run of a standalone pipeline
+type Stats struct {
+	// Collected is the number of documents collected
+	Collected int
+	// Skipped is the number of documents found in the seen cache
+	Skipped int
+	// Unsupported is the number of documents of a type or format no
+	// processor handles
+	Unsupported int
+	// Failed is the number of documents that failed to process, parse or
+	// store, possibly partially stored
+	Failed int
+	// Ingested is the number of documents fully stored
+	Ingested int
+}
+
+// tracedDocument is a document passed on to the processors with the context
+// of its trace
+type tracedDocument struct {
+	ctx context.Context
+	doc *processor.Document
+}
+
+// tracedTree is a document tree passed on to be stored with the context of
+// its trace
+type tracedTree struct {
+	ctx  context.Context
+	tree processor.DocumentTree
+}
+
+// stats is Stats updated by the stages concurrently
+type stats struct {
+	mu sync.Mutex
+	Stats
+}
+
+func (s *stats) add(f func(*Stats)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f(&s.Stats)
+}
+
+// RunStandalone collects documents with collect, processes them with
+// process.Process, parses the document trees with parser.ParseDocumentTree
+// and stores their graphs with assemble, each stage passing the documents on
+// to the next through a channel, so that no broker is needed. The documents
+// are processed concurrently and stored one at a time, in the order
+// processed. A document failing is logged and counted, and the others carry
+// on. Each document is traced from its collection to its storage under a
+// single trace, as it is when the stages are connected through a broker. It
+// returns once all the documents collected are stored, or ctx is done,
+// with the error of collect.
+func RunStandalone(ctx context.Context, cfg Config, collect CollectFunc, assemble AssembleFunc) (Stats, error) {
+	logger := logging.FromContext(ctx)
+	processors := cfg.Processors
+	if processors <= 0 {
+		processors = 1
+	}
+	bufferSize := cfg.BufferSize
+	if bufferSize <= 0 {
+		bufferSize = DefaultBufferSize
+	}
+	var s stats
+
+	docChan := make(chan tracedDocument, bufferSize)
+	treeChan := make(chan tracedTree, bufferSize)
+
+	var processWG sync.WaitGroup
+	for i := 0; i < processors; i++ {
+		processWG.Add(1)
+		go func() {
+			defer processWG.Done()
+			for td := range docChan {
+				d := td.doc
+				traceCtx, tree, err := process.ProcessWithTrace(td.ctx, d)
+				if errors.Is(err, process.ErrUnsupportedDocument) {
+					// logged and counted by the processor
+					s.add(func(s *Stats) { s.Unsupported++ })
+					continue
+				}
+				if err != nil {
+					logger.Errorf("unable to process doc %+v: %v", d.SourceInformation, err)
+					s.add(func(s *Stats) { s.Failed++ })
+					continue
+				}
+				select {
+				case treeChan <- tracedTree{ctx: traceCtx, tree: tree}:
+				case <-ctx.Done():
+				}
+			}
+		}()
+	}
+	go func() {
+		processWG.Wait()
+		close(treeChan)
+	}()
+
+	ingested := make(chan struct{})
+	go func() {
+		defer close(ingested)
+		for tt := range treeChan {
+			if ctx.Err() != nil {
+				// the processors stop passing document trees on
+				return
+			}
+			tree := tt.tree
+			if err := ingest(tt.ctx, cfg.Seen, tree, assemble); err != nil {
+				logger.Errorf("%v", err)
+				s.add(func(s *Stats) { s.Failed++ })
+				continue
+			}
+			logger.Infof("ingested doc tree: %+v", tree.Document.SourceInformation)
+			s.add(func(s *Stats) { s.Ingested++ })
+		}
+	}()
+
+	emit := func(d *processor.Document) error {
+		// the span of the collection is the root of the trace of the
+		// document, ended once handed to the processors
+		traceCtx, span := tracing.Start(ctx, "collect")
+		defer span.End()
+		span.SetAttribute("guac.collector", d.SourceInformation.Collector)
+		span.SetAttribute("guac.source", d.SourceInformation.Source)
+		s.add(func(s *Stats) { s.Collected++ })
+		if cfg.Seen != nil && cfg.Seen.Seen(hashcache.HashDocument(d)) {
+			logger.Infof("skipping unchanged doc %+v", d.SourceInformation)
+			span.SetAttribute("guac.skipped", true)
+			s.add(func(s *Stats) { s.Skipped++ })
+			return nil
+		}
+		select {
+		case docChan <- tracedDocument{ctx: traceCtx, doc: d}:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	err := collect(ctx, emit)
+	close(docChan)
+	<-ingested
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.Stats, err
+}
+
+// ingest parses tree and stores its graphs with assemble, adding the hash of
+// its root document to seen once fully stored. The documents of the tree that
+// parsed are stored even if others did not.
+func ingest(ctx context.Context, seen *hashcache.Cache, tree processor.DocumentTree, assemble AssembleFunc) error {
+	ctx, span := tracing.Start(ctx, "ingest")
+	defer span.End()
+	span.SetAttribute("guac.source", tree.Document.SourceInformation.Source)
+	graphs, parseErr := parser.ParseDocumentTree(ctx, tree)
+	if parseErr != nil {
+		var treeErr *parser.TreeError
+		if !errors.As(parseErr, &treeErr) || !treeErr.Partial() {
+			err := fmt.Errorf("unable to parse doc tree %+v: %w", tree.Document.SourceInformation, parseErr)
+			span.RecordError(err)
+			return err
+		}
+	}
+	if err := assemble(ctx, graphs); err != nil {
+		err = fmt.Errorf("unable to store doc tree %+v: %w", tree.Document.SourceInformation, err)
+		span.RecordError(err)
+		return err
+	}
+	if parseErr != nil {
+		// not added to the seen cache so that it is ingested again
+		err := fmt.Errorf("partially ingested doc tree %+v: %w", tree.Document.SourceInformation, parseErr)
+		span.RecordError(err)
+		return err
+	}
+	if seen != nil {
+		seen.Add(hashcache.HashDocument(tree.Document))
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/guacsec/guac/internal/testing/testdata"
+	"github.com/guacsec/guac/pkg/assembler"
+	"github.com/guacsec/guac/pkg/handler/collector"
+	"github.com/guacsec/guac/pkg/handler/processor"
+	"github.com/guacsec/guac/pkg/handler/processor/hashcache"
+	"github.com/guacsec/guac/pkg/logging"
+)
+
+func collectDocs(docs ...[]byte) CollectFunc {
+	return func(ctx context.Context, emit collector.Emitter) error {
+		for _, blob := range docs {
+			d := &processor.Document{
+				Blob: blob,
+				SourceInformation: processor.SourceInformation{
+					Collector: "test",
+					Source:    "test",
+				},
+			}
+			if err := emit(d); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func TestRunStandalone(t *testing.T) {
+	ctx := logging.WithLogger(context.Background())
+	var mu sync.Mutex
+	var stored []assembler.Graph
+	assemble := func(_ context.Context, gs []assembler.Graph) error {
+		mu.Lock()
+		defer mu.Unlock()
+		stored = append(stored, gs...)
+		return nil
+	}
+	seen := hashcache.New(10, time.Hour)
+	cfg := Config{Processors: 2, Seen: seen}
+	collect := collectDocs(testdata.SpdxExampleSmall, testdata.ScorecardExample, []byte("<xml></xml>"))
+
+	got, err := RunStandalone(ctx, cfg, collect, assemble)
+	if err != nil {
+		t.Fatalf("RunStandalone() error = %v", err)
+	}
+	want := Stats{Collected: 3, Unsupported: 1, Ingested: 2}
+	if got != want {
+		t.Errorf("RunStandalone() = %+v, want %+v", got, want)
+	}
+	if len(stored) != 2 {
+		t.Errorf("RunStandalone() stored %d graphs, want 2", len(stored))
+	}
+
+	// the documents stored are skipped the next time
+	got, err = RunStandalone(ctx, cfg, collect, assemble)
+	if err != nil {
+		t.Fatalf("RunStandalone() error = %v", err)
+	}
+	want = Stats{Collected: 3, Skipped: 2, Unsupported: 1}
+	if got != want {
+		t.Errorf("RunStandalone() = %+v, want %+v", got, want)
+	}
+}
+
+func TestRunStandalone_Errors(t *testing.T) {
+	ctx := logging.WithLogger(context.Background())
+	storeErr := errors.New("graph db unavailable")
+	assemble := func(context.Context, []assembler.Graph) error {
+		return storeErr
+	}
+	collectErr := errors.New("collector failed")
+	collect := func(ctx context.Context, emit collector.Emitter) error {
+		if err := collectDocs(testdata.SpdxExampleSmall)(ctx, emit); err != nil {
+			return err
+		}
+		return collectErr
+	}
+	got, err := RunStandalone(ctx, Config{}, collect, assemble)
+	if !errors.Is(err, collectErr) {
+		t.Errorf("RunStandalone() error = %v, want %v", err, collectErr)
+	}
+	if want := (Stats{Collected: 1, Failed: 1}); got != want {
+		t.Errorf("RunStandalone() = %+v, want %+v", got, want)
+	}
+}
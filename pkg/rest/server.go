@@ -0,0 +1,316 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/guacsec/guac/pkg/assembler"
+	"github.com/guacsec/guac/pkg/logging"
+	"go.uber.org/zap"
+)
+
+const (
+	// ArtifactsPath prefixes the endpoints of an artifact, by digest
+	ArtifactsPath = "/artifacts/"
+	// PackagesPath prefixes the endpoints of a package, by purl
+	PackagesPath = "/packages/"
+	// PathsPath is the endpoint of the paths between two nodes
+	PathsPath = "/paths"
+	// EvidencePath is the endpoint of the documents asserting an edge
+	EvidencePath = "/evidence"
+	// VulnerabilitiesPath prefixes the endpoints of a vulnerability, by ID or
+	// alias
+	VulnerabilitiesPath = "/vulnerabilities/"
+
+	blastRadiusSuffix = "/blast-radius"
+)
+
+// endpoint returns the response of an endpoint about a node
+type endpoint func(n assembler.StoredNode) (interface{}, error)
+
+type server struct {
+	reader assembler.GraphReader
+	limit  int
+	logger *zap.SugaredLogger
+}
+
+// Handler serves the REST endpoints answering common questions about the
+// graph read by reader. Lists are truncated to limit elements,
+// assembler.DefaultQueryLimit if not positive.
+//
+//	GET /artifacts/{digest}                   the artifact
+//	GET /artifacts/{digest}/provenance        its attestations with builders and signers
+//	GET /artifacts/{digest}/vulnerabilities   the vulnerabilities affecting it
+//	GET /packages/{purl}                      the package
+//	GET /packages/{purl}/vulnerabilities      the vulnerabilities affecting it
+//	GET /packages/{purl}/dependencies         the packages it depends on
+//	GET /packages/{purl}/dependents           the packages depending on it
+//	GET /paths?from={purl|digest}&to={purl|digest}[&depth=N][&edges=E1,E2]
+//	                                          the dependency paths between two nodes
+//	GET /evidence?from={purl|digest}&to={purl|digest}&edge=E
+//	                                          the documents asserting the edge between two nodes
+//	GET /vulnerabilities/{id}/blast-radius[?depth=N]
+//	                                          the nodes affected by a vulnerability, and
+//	                                          the products depending on them
+//
+// The purl may be URL encoded. Errors are logged with the logger of ctx.
+func Handler(ctx context.Context, reader assembler.GraphReader, limit int) http.Handler {
+	s := &server{reader: reader, limit: limit, logger: logging.FromContext(ctx)}
+	mux := http.NewServeMux()
+	mux.Handle(ArtifactsPath, s.handle(ArtifactsPath, map[string]endpoint{
+		"":                 s.node,
+		"/provenance":      s.provenance,
+		"/vulnerabilities": s.vulnerabilities,
+	}))
+	mux.Handle(PackagesPath, s.handle(PackagesPath, map[string]endpoint{
+		"":                 s.node,
+		"/vulnerabilities": s.vulnerabilities,
+		"/dependencies":    s.dependencies,
+		"/dependents":      s.dependents,
+	}))
+	mux.HandleFunc(PathsPath, s.paths)
+	mux.HandleFunc(EvidencePath, s.evidence)
+	mux.HandleFunc(VulnerabilitiesPath, s.blastRadius)
+	return mux
+}
+
+// handle serves the endpoints under prefix, finding the node of the path
+// before calling the endpoint for its suffix
+func (s *server) handle(prefix string, endpoints map[string]endpoint) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		key := strings.TrimPrefix(r.URL.Path, prefix)
+		suffix := ""
+		for endpoint := range endpoints {
+			if endpoint != "" && strings.HasSuffix(key, endpoint) {
+				suffix = endpoint
+				break
+			}
+		}
+		key = strings.TrimSuffix(key, suffix)
+		if key == "" {
+			writeError(w, http.StatusNotFound, "missing "+strings.Trim(prefix, "/")+" key")
+			return
+		}
+
+		node, err := s.find(prefix, key)
+		if errors.Is(err, assembler.ErrNodeNotFound) {
+			writeError(w, http.StatusNotFound, "not found")
+			return
+		}
+		var result interface{}
+		if err == nil {
+			result, err = endpoints[suffix](node)
+		}
+		s.write(w, r, result, err)
+	})
+}
+
+// paths serves the paths between the package or artifact from and to, along
+// the edges, assembler.DependencyEdges by default
+func (s *server) paths(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	query := r.URL.Query()
+	depth, err := parseDepth(query.Get("depth"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	edges := assembler.DependencyEdges
+	if e := query.Get("edges"); e != "" {
+		edges = strings.Split(e, ",")
+		for _, edge := range edges {
+			if err := assembler.ValidateLabel(edge); err != nil {
+				writeError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+		}
+	}
+
+	nodes, ok := s.endpoints(w, r)
+	if !ok {
+		return
+	}
+	paths, err := s.reader.Paths(nodes[0].ID, nodes[1].ID, edges, depth, s.limit)
+	s.write(w, r, paths, err)
+}
+
+// evidence serves the documents asserting the edge between the package or
+// artifact from and to
+func (s *server) evidence(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	edge := r.URL.Query().Get("edge")
+	if edge == "" {
+		writeError(w, http.StatusBadRequest, "missing edge")
+		return
+	}
+	if err := assembler.ValidateLabel(edge); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	nodes, ok := s.endpoints(w, r)
+	if !ok {
+		return
+	}
+	docs, err := assembler.EdgeEvidence(s.reader, nodes[0], nodes[1], edge, s.limit)
+	if errors.Is(err, assembler.ErrEdgeNotFound) {
+		writeError(w, http.StatusNotFound, "edge not found")
+		return
+	}
+	s.write(w, r, docs, err)
+}
+
+// endpoints returns the packages or artifacts of the from and to parameters
+// of r, or writes the error response and returns false if one is missing
+func (s *server) endpoints(w http.ResponseWriter, r *http.Request) ([]assembler.StoredNode, bool) {
+	nodes := []assembler.StoredNode{}
+	for _, param := range []string{"from", "to"} {
+		key := r.URL.Query().Get(param)
+		if key == "" {
+			writeError(w, http.StatusBadRequest, "missing "+param)
+			return nil, false
+		}
+		n, err := assembler.FindSubject(s.reader, key)
+		if errors.Is(err, assembler.ErrNodeNotFound) {
+			writeError(w, http.StatusNotFound, param+" not found")
+			return nil, false
+		}
+		if err != nil {
+			s.write(w, r, nil, err)
+			return nil, false
+		}
+		nodes = append(nodes, n)
+	}
+	return nodes, true
+}
+
+// blastRadius serves the blast radius of a vulnerability
+func (s *server) blastRadius(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	id := strings.TrimPrefix(r.URL.Path, VulnerabilitiesPath)
+	if !strings.HasSuffix(id, blastRadiusSuffix) || id == blastRadiusSuffix {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	id = strings.TrimSuffix(id, blastRadiusSuffix)
+	depth, err := parseDepth(r.URL.Query().Get("depth"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	radius, err := assembler.VulnerabilityBlastRadius(s.reader, id, depth, s.limit)
+	if errors.Is(err, assembler.ErrNodeNotFound) {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	s.write(w, r, radius, err)
+}
+
+// parseDepth returns the depth of a path query, 0 for the default one if d
+// is empty
+func parseDepth(d string) (int, error) {
+	if d == "" {
+		return 0, nil
+	}
+	depth, err := strconv.Atoi(d)
+	if err != nil || depth < 0 || depth > assembler.MaxPathDepth {
+		return 0, fmt.Errorf("depth must be between 0 and %d", assembler.MaxPathDepth)
+	}
+	return depth, nil
+}
+
+// write writes result as the JSON response of r, or an error if the query
+// failed with err
+func (s *server) write(w http.ResponseWriter, r *http.Request, result interface{}, err error) {
+	if err != nil {
+		s.logger.Errorf("failed to query %s: %v", r.URL.Path, err)
+		writeError(w, http.StatusInternalServerError, "query failed")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		s.logger.Errorf("failed to write response of %s: %v", r.URL.Path, err)
+	}
+}
+
+// find returns the artifact with digest key, or the package with purl key
+func (s *server) find(prefix string, key string) (assembler.StoredNode, error) {
+	filter := assembler.NodeFilter{Limit: 1}
+	if prefix == ArtifactsPath {
+		filter.Label = "Artifact"
+		filter.Digest = key
+	} else {
+		filter.Label = "Package"
+		filter.Purl = key
+	}
+	nodes, err := s.reader.FindNodes(filter)
+	if err != nil {
+		return assembler.StoredNode{}, err
+	}
+	if len(nodes) == 0 {
+		return assembler.StoredNode{}, assembler.ErrNodeNotFound
+	}
+	return nodes[0], nil
+}
+
+func (s *server) node(n assembler.StoredNode) (interface{}, error) {
+	return n, nil
+}
+
+func (s *server) provenance(n assembler.StoredNode) (interface{}, error) {
+	return assembler.NodeProvenance(s.reader, n, s.limit)
+}
+
+func (s *server) vulnerabilities(n assembler.StoredNode) (interface{}, error) {
+	return assembler.NodeVulnerabilities(s.reader, n, s.limit)
+}
+
+func (s *server) dependencies(n assembler.StoredNode) (interface{}, error) {
+	return assembler.Dependencies(s.reader, n, s.limit)
+}
+
+func (s *server) dependents(n assembler.StoredNode) (interface{}, error) {
+	return assembler.Dependents(s.reader, n, s.limit)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
@@ -0,0 +1,330 @@
+//
+// Copyright 2023 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/guacsec/guac/pkg/assembler"
+)
+
+type fakeEdge struct {
+	label    string
+	from, to int64
+	props    map[string]interface{}
+}
+
+// fakeReader is a graph of two packages with an artifact each, the one of
+// the dependency with a provenance signed by an identity, vulnerabilities
+// found by VEX statements and a scan, and the document asserting the
+// dependency
+type fakeReader struct {
+	nodes []assembler.StoredNode
+	edges []fakeEdge
+}
+
+func newFakeReader() *fakeReader {
+	return &fakeReader{
+		nodes: []assembler.StoredNode{
+			{ID: 1, Label: "Package", Properties: map[string]interface{}{"purl": "pkg:golang/foo@v1"}},
+			{ID: 2, Label: "Package", Properties: map[string]interface{}{"purl": "pkg:golang/bar@v2"}},
+			{ID: 3, Label: "Artifact", Properties: map[string]interface{}{"digest": "sha256:ab"}},
+			{ID: 4, Label: "Attestation", Properties: map[string]interface{}{"attestation_type": "https://slsa.dev/provenance/v0.2"}},
+			{ID: 5, Label: "Builder", Properties: map[string]interface{}{"id": "https://github.com/Attestations/GitHubHostedActions@v1"}},
+			{ID: 6, Label: "Identity", Properties: map[string]interface{}{"id": "release"}},
+			{ID: 7, Label: "Vulnerability", Properties: map[string]interface{}{"id": "GHSA-1234", "aliases": []interface{}{"CVE-2023-1"}}},
+			{ID: 8, Label: "Attestation", Properties: map[string]interface{}{"attestation_type": "https://in-toto.io/attestation/vuln/v0.1"}},
+			{ID: 9, Label: "Vulnerability", Properties: map[string]interface{}{"id": "CVE-2023-2"}},
+			{ID: 10, Label: "Artifact", Properties: map[string]interface{}{"digest": "sha256:cd"}},
+			{ID: 11, Label: "Package", Properties: map[string]interface{}{"purl": "pkg:golang/qux@v4"}},
+			{ID: 12, Label: "Document", Properties: map[string]interface{}{"digest": "sha256:d0", "source": "bar.spdx.json"}},
+		},
+		edges: []fakeEdge{
+			{label: "DependsOn", from: 2, to: 1, props: map[string]interface{}{"documents": []interface{}{"sha256:d0", "sha256:d1"}}},
+			{label: "Attestation", from: 4, to: 3},
+			{label: "BuiltBy", from: 4, to: 5},
+			{label: "Identity", from: 6, to: 4},
+			{label: "Affects", from: 7, to: 1, props: map[string]interface{}{"analysis_state": "affected"}},
+			{label: "Attestation", from: 8, to: 1},
+			{label: "Vulnerable", from: 8, to: 9},
+			{label: "PackageOf", from: 10, to: 2},
+			{label: "PackageOf", from: 3, to: 1},
+			{label: "Affects", from: 7, to: 11, props: map[string]interface{}{"analysis_state": "not_affected"}},
+		},
+	}
+}
+
+func (f *fakeReader) FindNodes(filter assembler.NodeFilter) ([]assembler.StoredNode, error) {
+	nodes := []assembler.StoredNode{}
+	for _, n := range f.nodes {
+		if n.Label == filter.Label && (filter.Purl == "" || n.Properties["purl"] == filter.Purl) &&
+			(filter.Digest == "" || n.Properties["digest"] == filter.Digest) &&
+			(filter.ID == "" || n.Properties["id"] == filter.ID || n.Properties["aliases"] != nil && n.Properties["aliases"].([]interface{})[0] == filter.ID) {
+			nodes = append(nodes, n)
+		}
+	}
+	return nodes, nil
+}
+
+func (f *fakeReader) Neighbors(id int64, edge string, dir assembler.Direction, label string, limit int) ([]assembler.Neighbor, error) {
+	neighbors := []assembler.Neighbor{}
+	for _, e := range f.edges {
+		other := int64(0)
+		switch {
+		case dir == assembler.DirectionOut && e.from == id:
+			other = e.to
+		case dir == assembler.DirectionIn && e.to == id:
+			other = e.from
+		}
+		if other == 0 || e.label != edge {
+			continue
+		}
+		for _, n := range f.nodes {
+			if n.ID == other && (label == "" || n.Label == label) {
+				neighbors = append(neighbors, assembler.Neighbor{Edge: e.label, EdgeProperties: e.props, Node: n})
+			}
+		}
+	}
+	return neighbors, nil
+}
+
+func (f *fakeReader) Paths(from int64, to int64, edges []string, depth int, limit int) ([]assembler.Path, error) {
+	if depth <= 0 {
+		depth = assembler.DefaultPathDepth
+	}
+	paths := []assembler.Path{}
+	var walk func(id int64, path assembler.Path)
+	walk = func(id int64, path assembler.Path) {
+		if id == to && len(path.Edges) > 0 {
+			paths = append(paths, path)
+			return
+		}
+		if len(path.Edges) == depth {
+			return
+		}
+		for _, e := range f.edges {
+			if e.from != id || !contains(edges, e.label) {
+				continue
+			}
+			walk(e.to, assembler.Path{
+				Nodes: append(append([]assembler.StoredNode{}, path.Nodes...), f.node(e.to)),
+				Edges: append(append([]string{}, path.Edges...), e.label),
+			})
+		}
+	}
+	walk(from, assembler.Path{Nodes: []assembler.StoredNode{f.node(from)}})
+	return paths, nil
+}
+
+func (f *fakeReader) RootPaths(id int64, edges []string, depth int, limit int) ([]assembler.Path, error) {
+	if depth <= 0 {
+		depth = assembler.DefaultPathDepth
+	}
+	paths := []assembler.Path{}
+	var walk func(path assembler.Path)
+	walk = func(path assembler.Path) {
+		parents := []fakeEdge{}
+		for _, e := range f.edges {
+			if e.to == path.Nodes[0].ID && contains(edges, e.label) {
+				parents = append(parents, e)
+			}
+		}
+		if len(parents) == 0 {
+			paths = append(paths, path)
+			return
+		}
+		if len(path.Edges) == depth {
+			return
+		}
+		for _, e := range parents {
+			walk(assembler.Path{
+				Nodes: append([]assembler.StoredNode{f.node(e.from)}, path.Nodes...),
+				Edges: append([]string{e.label}, path.Edges...),
+			})
+		}
+	}
+	walk(assembler.Path{Nodes: []assembler.StoredNode{f.node(id)}})
+	sort.SliceStable(paths, func(i, j int) bool { return len(paths[i].Edges) < len(paths[j].Edges) })
+	return paths, nil
+}
+
+func (f *fakeReader) node(id int64) assembler.StoredNode {
+	for _, n := range f.nodes {
+		if n.ID == id {
+			return n
+		}
+	}
+	return assembler.StoredNode{}
+}
+
+func contains(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
+
+func TestHandler(t *testing.T) {
+	handler := Handler(context.Background(), newFakeReader(), 0)
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		wantStatus int
+		want       string
+	}{{
+		name:       "artifact",
+		path:       "/artifacts/sha256:ab",
+		wantStatus: http.StatusOK,
+		want:       `{"id":3,"label":"Artifact","properties":{"digest":"sha256:ab"}}`,
+	}, {
+		name:       "provenance",
+		path:       "/artifacts/sha256:ab/provenance",
+		wantStatus: http.StatusOK,
+		want: `[{"attestation":{"id":4,"label":"Attestation","properties":{"attestation_type":"https://slsa.dev/provenance/v0.2"}},` +
+			`"builders":[{"id":5,"label":"Builder","properties":{"id":"https://github.com/Attestations/GitHubHostedActions@v1"}}],` +
+			`"signers":[{"id":6,"label":"Identity","properties":{"id":"release"}}]}]`,
+	}, {
+		name:       "package vulnerabilities",
+		path:       "/packages/pkg:golang/foo@v1/vulnerabilities",
+		wantStatus: http.StatusOK,
+		want: `[{"vulnerability":{"id":7,"label":"Vulnerability","properties":{"aliases":["CVE-2023-1"],"id":"GHSA-1234"}},"edge":{"analysis_state":"affected"}},` +
+			`{"vulnerability":{"id":9,"label":"Vulnerability","properties":{"id":"CVE-2023-2"}},` +
+			`"attestation":{"id":8,"label":"Attestation","properties":{"attestation_type":"https://in-toto.io/attestation/vuln/v0.1"}}}]`,
+	}, {
+		name:       "url encoded purl dependents",
+		path:       "/packages/pkg:golang%2Ffoo@v1/dependents",
+		wantStatus: http.StatusOK,
+		want:       `[{"id":2,"label":"Package","properties":{"purl":"pkg:golang/bar@v2"}}]`,
+	}, {
+		name:       "dependencies",
+		path:       "/packages/pkg:golang/bar@v2/dependencies",
+		wantStatus: http.StatusOK,
+		want:       `[{"id":1,"label":"Package","properties":{"purl":"pkg:golang/foo@v1"}}]`,
+	}, {
+		name:       "no dependents",
+		path:       "/packages/pkg:golang/bar@v2/dependents",
+		wantStatus: http.StatusOK,
+		want:       `[]`,
+	}, {
+		name:       "unknown package",
+		path:       "/packages/pkg:golang/baz@v3/vulnerabilities",
+		wantStatus: http.StatusNotFound,
+		want:       `{"error":"not found"}`,
+	}, {
+		name:       "missing digest",
+		path:       "/artifacts/",
+		wantStatus: http.StatusNotFound,
+		want:       `{"error":"missing artifacts key"}`,
+	}, {
+		name:       "dependency path",
+		path:       "/paths?from=sha256:cd&to=pkg:golang/foo@v1",
+		wantStatus: http.StatusOK,
+		want: `[{"nodes":[{"id":10,"label":"Artifact","properties":{"digest":"sha256:cd"}},` +
+			`{"id":2,"label":"Package","properties":{"purl":"pkg:golang/bar@v2"}},` +
+			`{"id":1,"label":"Package","properties":{"purl":"pkg:golang/foo@v1"}}],"edges":["PackageOf","DependsOn"]}]`,
+	}, {
+		name:       "path too short",
+		path:       "/paths?from=sha256:cd&to=pkg:golang/foo@v1&depth=1",
+		wantStatus: http.StatusOK,
+		want:       `[]`,
+	}, {
+		name:       "path along other edges",
+		path:       "/paths?from=sha256:cd&to=pkg:golang/foo@v1&edges=DependsOn",
+		wantStatus: http.StatusOK,
+		want:       `[]`,
+	}, {
+		name:       "path from unknown node",
+		path:       "/paths?from=sha256:ef&to=pkg:golang/foo@v1",
+		wantStatus: http.StatusNotFound,
+		want:       `{"error":"from not found"}`,
+	}, {
+		name:       "path too deep",
+		path:       "/paths?from=sha256:cd&to=pkg:golang/foo@v1&depth=100",
+		wantStatus: http.StatusBadRequest,
+		want:       `{"error":"depth must be between 0 and 25"}`,
+	}, {
+		name:       "evidence",
+		path:       "/evidence?from=pkg:golang/bar@v2&to=pkg:golang/foo@v1&edge=DependsOn",
+		wantStatus: http.StatusOK,
+		want:       `[{"id":12,"label":"Document","properties":{"digest":"sha256:d0","source":"bar.spdx.json"}}]`,
+	}, {
+		name:       "evidence of a missing edge",
+		path:       "/evidence?from=pkg:golang/foo@v1&to=pkg:golang/bar@v2&edge=DependsOn",
+		wantStatus: http.StatusNotFound,
+		want:       `{"error":"edge not found"}`,
+	}, {
+		name:       "evidence without edge",
+		path:       "/evidence?from=pkg:golang/bar@v2&to=pkg:golang/foo@v1",
+		wantStatus: http.StatusBadRequest,
+		want:       `{"error":"missing edge"}`,
+	}, {
+		name:       "blast radius by alias",
+		path:       "/vulnerabilities/CVE-2023-1/blast-radius",
+		wantStatus: http.StatusOK,
+		want: `{"vulnerabilities":[{"id":7,"label":"Vulnerability","properties":{"aliases":["CVE-2023-1"],"id":"GHSA-1234"}}],` +
+			`"affected":[{"id":1,"label":"Package","properties":{"purl":"pkg:golang/foo@v1"}}],` +
+			`"artifacts":[{"id":3,"label":"Artifact","properties":{"digest":"sha256:ab"}},{"id":10,"label":"Artifact","properties":{"digest":"sha256:cd"}}],` +
+			`"products":[{"product":{"id":3,"label":"Artifact","properties":{"digest":"sha256:ab"}},"chains":[` +
+			`{"nodes":[{"id":3,"label":"Artifact","properties":{"digest":"sha256:ab"}},{"id":1,"label":"Package","properties":{"purl":"pkg:golang/foo@v1"}}],"edges":["PackageOf"]}]},` +
+			`{"product":{"id":10,"label":"Artifact","properties":{"digest":"sha256:cd"}},"chains":[` +
+			`{"nodes":[{"id":10,"label":"Artifact","properties":{"digest":"sha256:cd"}},{"id":2,"label":"Package","properties":{"purl":"pkg:golang/bar@v2"}},` +
+			`{"id":1,"label":"Package","properties":{"purl":"pkg:golang/foo@v1"}}],"edges":["PackageOf","DependsOn"]}]}]}`,
+	}, {
+		name:       "blast radius of a scan finding",
+		path:       "/vulnerabilities/CVE-2023-2/blast-radius?depth=1",
+		wantStatus: http.StatusOK,
+		want: `{"vulnerabilities":[{"id":9,"label":"Vulnerability","properties":{"id":"CVE-2023-2"}}],` +
+			`"affected":[{"id":1,"label":"Package","properties":{"purl":"pkg:golang/foo@v1"}}],` +
+			`"artifacts":[{"id":3,"label":"Artifact","properties":{"digest":"sha256:ab"}}],` +
+			`"products":[{"product":{"id":3,"label":"Artifact","properties":{"digest":"sha256:ab"}},"chains":[` +
+			`{"nodes":[{"id":3,"label":"Artifact","properties":{"digest":"sha256:ab"}},{"id":1,"label":"Package","properties":{"purl":"pkg:golang/foo@v1"}}],"edges":["PackageOf"]}]}]}`,
+	}, {
+		name:       "blast radius of unknown vulnerability",
+		path:       "/vulnerabilities/CVE-2023-3/blast-radius",
+		wantStatus: http.StatusNotFound,
+		want:       `{"error":"not found"}`,
+	}, {
+		name:       "post",
+		method:     http.MethodPost,
+		path:       "/artifacts/sha256:ab",
+		wantStatus: http.StatusMethodNotAllowed,
+		want:       `{"error":"method not allowed"}`,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			method := tt.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(method, tt.path, nil))
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got := strings.TrimSpace(rec.Body.String()); got != tt.want {
+				t.Errorf("response = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
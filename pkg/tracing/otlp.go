@@ -0,0 +1,342 @@
+//
+// Copyright 2022 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/guacsec/guac/pkg/logging"
+)
+
+const (
+	// exportInterval is the longest time a span waits to be exported
+	exportInterval = 5 * time.Second
+	// exportBatchSize is the number of queued spans triggering an export
+	exportBatchSize = 512
+	// maxQueuedSpans bounds the spans waiting to be exported, newer ones
+	// are dropped while the collector is unavailable
+	maxQueuedSpans = 4096
+
+	// OTLP span kind and status codes
+	spanKindInternal = 1
+	statusCodeError  = 2
+
+	// EnvEndpoint, EnvHeaders and EnvServiceName are the standard
+	// OpenTelemetry environment variables configuring the exporter, see
+	// https://opentelemetry.io/docs/specs/otel/protocol/exporter/
+	EnvEndpoint    = "OTEL_EXPORTER_OTLP_ENDPOINT"
+	EnvHeaders     = "OTEL_EXPORTER_OTLP_HEADERS"
+	EnvServiceName = "OTEL_SERVICE_NAME"
+)
+
+// OTLPConfig configures the export of the spans to an OTLP/HTTP collector
+type OTLPConfig struct {
+	// Endpoint is the URL of the collector, e.g. http://localhost:4318,
+	// empty to disable tracing
+	Endpoint string
+	// ServiceName is the service the spans are exported under
+	ServiceName string
+	// Headers are sent with each export, e.g. to authenticate to a hosted
+	// collector
+	Headers map[string]string
+}
+
+// WithEnv returns c with the settings it leaves empty read from the standard
+// OpenTelemetry environment variables, and the service name defaulting to
+// serviceName
+func (c OTLPConfig) WithEnv(serviceName string) (OTLPConfig, error) {
+	if c.Endpoint == "" {
+		c.Endpoint = os.Getenv(EnvEndpoint)
+	}
+	if len(c.Headers) == 0 {
+		headers, err := ParseHeaders(os.Getenv(EnvHeaders))
+		if err != nil {
+			return c, fmt.Errorf("invalid %s: %w", EnvHeaders, err)
+		}
+		c.Headers = headers
+	}
+	if c.ServiceName == "" {
+		c.ServiceName = os.Getenv(EnvServiceName)
+	}
+	if c.ServiceName == "" {
+		c.ServiceName = serviceName
+	}
+	return c, nil
+}
+
+// ParseHeaders parses the headers of OTEL_EXPORTER_OTLP_HEADERS, a comma
+// separated list of key=value pairs with URL-encoded values, e.g.
+// api-key=secret,tenant=guac
+func ParseHeaders(s string) (map[string]string, error) {
+	headers := map[string]string{}
+	for _, pair := range strings.Split(s, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(pair, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("header %q is not a key=value pair", pair)
+		}
+		value, err := url.PathUnescape(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("invalid value of header %s: %w", key, err)
+		}
+		headers[key] = value
+	}
+	return headers, nil
+}
+
+var (
+	exporterMu sync.RWMutex
+	current    *exporter
+)
+
+func getExporter() *exporter {
+	exporterMu.RLock()
+	defer exporterMu.RUnlock()
+	return current
+}
+
+// Configure enables tracing, exporting the spans with OTLP/HTTP to the
+// collector at endpoint, e.g. http://localhost:4318, under serviceName. An
+// empty endpoint disables tracing. Export failures are logged with the logger
+// of ctx.
+func Configure(ctx context.Context, endpoint string, serviceName string) error {
+	return ConfigureOTLP(ctx, OTLPConfig{Endpoint: endpoint, ServiceName: serviceName})
+}
+
+// ConfigureOTLP enables tracing as Configure does, with the headers of c
+// sent with each export
+func ConfigureOTLP(ctx context.Context, c OTLPConfig) error {
+	var e *exporter
+	if c.Endpoint != "" {
+		u, err := url.Parse(c.Endpoint)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid OTLP endpoint %q: must be an http or https URL", c.Endpoint)
+		}
+		if !strings.HasSuffix(u.Path, "/v1/traces") {
+			u.Path = strings.TrimSuffix(u.Path, "/") + "/v1/traces"
+		}
+		e = &exporter{
+			ctx:         ctx,
+			url:         u.String(),
+			serviceName: c.ServiceName,
+			headers:     c.Headers,
+			client:      &http.Client{Timeout: 10 * time.Second},
+			flush:       make(chan struct{}, 1),
+			stop:        make(chan struct{}),
+			done:        make(chan struct{}),
+		}
+		go e.run()
+	}
+
+	exporterMu.Lock()
+	previous := current
+	current = e
+	exporterMu.Unlock()
+	if previous != nil {
+		return previous.shutdown(ctx)
+	}
+	return nil
+}
+
+// Shutdown exports the spans not exported yet and disables tracing
+func Shutdown(ctx context.Context) error {
+	exporterMu.Lock()
+	e := current
+	current = nil
+	exporterMu.Unlock()
+	if e == nil {
+		return nil
+	}
+	return e.shutdown(ctx)
+}
+
+// exporter sends the ended spans in batches to an OTLP/HTTP collector
+type exporter struct {
+	ctx         context.Context
+	url         string
+	serviceName string
+	headers     map[string]string
+	client      *http.Client
+
+	mu      sync.Mutex
+	spans   []*Span
+	dropped int
+
+	flush chan struct{}
+	stop  chan struct{}
+	done  chan struct{}
+}
+
+func (e *exporter) add(s *Span) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if len(e.spans) >= maxQueuedSpans {
+		e.dropped++
+		return
+	}
+	e.spans = append(e.spans, s)
+	if len(e.spans) >= exportBatchSize {
+		select {
+		case e.flush <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (e *exporter) run() {
+	defer close(e.done)
+	ticker := time.NewTicker(exportInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-e.flush:
+		case <-e.stop:
+			e.exportQueued(e.ctx)
+			return
+		}
+		e.exportQueued(e.ctx)
+	}
+}
+
+func (e *exporter) shutdown(ctx context.Context) error {
+	close(e.stop)
+	select {
+	case <-e.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (e *exporter) exportQueued(ctx context.Context) {
+	logger := logging.FromContext(ctx)
+	e.mu.Lock()
+	spans, dropped := e.spans, e.dropped
+	e.spans, e.dropped = nil, 0
+	e.mu.Unlock()
+
+	if dropped > 0 {
+		logger.Warnf("dropped %d spans, the OTLP collector is not keeping up", dropped)
+	}
+	if len(spans) == 0 {
+		return
+	}
+	if err := e.export(ctx, spans); err != nil {
+		logger.Warnf("unable to export %d spans: %v", len(spans), err)
+	}
+}
+
+func (e *exporter) export(ctx context.Context, spans []*Span) error {
+	body, err := json.Marshal(e.request(spans))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for key, value := range e.headers {
+		req.Header.Set(key, value)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("OTLP collector responded %s", resp.Status)
+	}
+	return nil
+}
+
+// request returns the OTLP/HTTP JSON export request of the spans, see
+// https://github.com/open-telemetry/opentelemetry-proto/blob/main/docs/specification.md#json-protobuf-encoding
+func (e *exporter) request(spans []*Span) map[string]interface{} {
+	otlpSpans := make([]map[string]interface{}, 0, len(spans))
+	for _, s := range spans {
+		s.mu.Lock()
+		span := map[string]interface{}{
+			"traceId":           hex.EncodeToString(s.sc.TraceID[:]),
+			"spanId":            hex.EncodeToString(s.sc.SpanID[:]),
+			"name":              s.name,
+			"kind":              spanKindInternal,
+			"startTimeUnixNano": strconv.FormatInt(s.start.UnixNano(), 10),
+			"endTimeUnixNano":   strconv.FormatInt(s.end.UnixNano(), 10),
+			"attributes":        attributes(s.attrs),
+		}
+		if s.parentID != [8]byte{} {
+			span["parentSpanId"] = hex.EncodeToString(s.parentID[:])
+		}
+		if s.err != "" {
+			span["status"] = map[string]interface{}{"code": statusCodeError, "message": s.err}
+		}
+		s.mu.Unlock()
+		otlpSpans = append(otlpSpans, span)
+	}
+	return map[string]interface{}{
+		"resourceSpans": []interface{}{map[string]interface{}{
+			"resource": map[string]interface{}{
+				"attributes": attributes(map[string]interface{}{"service.name": e.serviceName}),
+			},
+			"scopeSpans": []interface{}{map[string]interface{}{
+				"scope": map[string]interface{}{"name": "github.com/guacsec/guac"},
+				"spans": otlpSpans,
+			}},
+		}},
+	}
+}
+
+// attributes returns the OTLP key values of attrs
+func attributes(attrs map[string]interface{}) []map[string]interface{} {
+	kvs := make([]map[string]interface{}, 0, len(attrs))
+	for k, v := range attrs {
+		kvs = append(kvs, map[string]interface{}{"key": k, "value": attributeValue(v)})
+	}
+	return kvs
+}
+
+func attributeValue(v interface{}) map[string]interface{} {
+	switch value := v.(type) {
+	case string:
+		return map[string]interface{}{"stringValue": value}
+	case bool:
+		return map[string]interface{}{"boolValue": value}
+	case int:
+		return map[string]interface{}{"intValue": strconv.Itoa(value)}
+	case int64:
+		return map[string]interface{}{"intValue": strconv.FormatInt(value, 10)}
+	case float64:
+		return map[string]interface{}{"doubleValue": value}
+	default:
+		return map[string]interface{}{"stringValue": fmt.Sprint(value)}
+	}
+}
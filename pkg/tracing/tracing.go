@@ -0,0 +1,189 @@
+//
+// Copyright 2022 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package tracing follows documents through the stages of the pipeline
+// (collect, process, parse and store) as a single trace. The trace context is
+// propagated between stages in the W3C Trace Context `traceparent` header of
+// the JetStream messages, and the spans are exported to an OpenTelemetry
+// collector with the OTLP/HTTP protocol.
+//
+// Tracing is disabled until Configure is called: Start then returns a nil
+// *Span, whose methods do nothing, but the trace context of received messages
+// is still propagated to the messages published.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TraceparentHeader is the W3C Trace Context header carrying the trace of a
+// document from one stage of the pipeline to the next
+const TraceparentHeader = "traceparent"
+
+// SpanContext identifies a span and its trace
+type SpanContext struct {
+	TraceID [16]byte
+	SpanID  [8]byte
+}
+
+// IsValid reports whether the trace and span ids are set
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != [16]byte{} && sc.SpanID != [8]byte{}
+}
+
+// Traceparent returns the span context as a traceparent header value, e.g.
+// `00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01`
+func (sc SpanContext) Traceparent() string {
+	return fmt.Sprintf("00-%s-%s-01", hex.EncodeToString(sc.TraceID[:]), hex.EncodeToString(sc.SpanID[:]))
+}
+
+// ParseTraceparent parses a traceparent header value
+func ParseTraceparent(traceparent string) (SpanContext, error) {
+	sc := SpanContext{}
+	parts := strings.Split(strings.TrimSpace(traceparent), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return sc, fmt.Errorf("invalid traceparent: %q", traceparent)
+	}
+	if _, err := hex.Decode(sc.TraceID[:], []byte(parts[1])); err != nil || len(parts[1]) != 32 {
+		return sc, fmt.Errorf("invalid trace id in traceparent: %q", traceparent)
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(parts[2])); err != nil || len(parts[2]) != 16 {
+		return sc, fmt.Errorf("invalid span id in traceparent: %q", traceparent)
+	}
+	if !sc.IsValid() {
+		return sc, fmt.Errorf("invalid traceparent: %q", traceparent)
+	}
+	return sc, nil
+}
+
+type spanKey struct{}
+
+type remoteKey struct{}
+
+// Extract returns ctx with the trace context of the traceparent header value,
+// making it the parent of the spans started from ctx. An empty or invalid
+// traceparent returns ctx unchanged.
+func Extract(ctx context.Context, traceparent string) context.Context {
+	if traceparent == "" {
+		return ctx
+	}
+	sc, err := ParseTraceparent(traceparent)
+	if err != nil {
+		return ctx
+	}
+	return context.WithValue(ctx, remoteKey{}, sc)
+}
+
+// Inject returns the traceparent header value of the span of ctx, or of the
+// trace context extracted into ctx, empty if there is none
+func Inject(ctx context.Context) string {
+	if sc := SpanContextFromContext(ctx); sc.IsValid() {
+		return sc.Traceparent()
+	}
+	return ""
+}
+
+// SpanContextFromContext returns the span context of the span started in
+// ctx, or else the one extracted into ctx
+func SpanContextFromContext(ctx context.Context) SpanContext {
+	if s, ok := ctx.Value(spanKey{}).(*Span); ok && s != nil {
+		return s.sc
+	}
+	if sc, ok := ctx.Value(remoteKey{}).(SpanContext); ok {
+		return sc
+	}
+	return SpanContext{}
+}
+
+// Span is an operation of the pipeline, e.g. the processing of a document
+type Span struct {
+	mu       sync.Mutex
+	name     string
+	sc       SpanContext
+	parentID [8]byte
+	start    time.Time
+	end      time.Time
+	attrs    map[string]interface{}
+	err      string
+	exporter *exporter
+}
+
+// Start starts a span named name, child of the span of ctx if any, and
+// returns it with a context holding it. It returns ctx and a nil *Span if
+// tracing is not configured.
+func Start(ctx context.Context, name string) (context.Context, *Span) {
+	e := getExporter()
+	if e == nil {
+		return ctx, nil
+	}
+	s := &Span{
+		name:     name,
+		start:    time.Now(),
+		attrs:    map[string]interface{}{},
+		exporter: e,
+	}
+	if parent := SpanContextFromContext(ctx); parent.IsValid() {
+		s.sc.TraceID = parent.TraceID
+		s.parentID = parent.SpanID
+	} else {
+		_, _ = rand.Read(s.sc.TraceID[:])
+	}
+	_, _ = rand.Read(s.sc.SpanID[:])
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// SetAttribute sets an attribute of the span. Values are exported as
+// strings, integers, floats or booleans, other values as their string
+// representation.
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attrs[key] = value
+}
+
+// RecordError marks the span as failed with err, if not nil
+func (s *Span) RecordError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err.Error()
+}
+
+// End ends the span and queues it for export. Calls after the first one do
+// nothing.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if !s.end.IsZero() {
+		s.mu.Unlock()
+		return
+	}
+	s.end = time.Now()
+	s.mu.Unlock()
+	s.exporter.add(s)
+}
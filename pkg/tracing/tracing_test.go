@@ -0,0 +1,259 @@
+//
+// Copyright 2022 The GUAC Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/guacsec/guac/pkg/logging"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	tests := []struct {
+		name        string
+		traceparent string
+		wantErr     bool
+	}{{
+		name:        "valid",
+		traceparent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+	}, {
+		name:        "future version with more fields",
+		traceparent: "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+	}, {
+		name:        "invalid version",
+		traceparent: "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		wantErr:     true,
+	}, {
+		name:        "zero trace id",
+		traceparent: "00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		wantErr:     true,
+	}, {
+		name:        "short span id",
+		traceparent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa-01",
+		wantErr:     true,
+	}, {
+		name:        "not hex",
+		traceparent: "00-4bf92f3577b34da6a3ce929d0e0e47zz-00f067aa0ba902b7-01",
+		wantErr:     true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sc, err := ParseTraceparent(tt.traceparent)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseTraceparent() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && sc.Traceparent() != "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01" {
+				t.Errorf("Traceparent() = %s", sc.Traceparent())
+			}
+		})
+	}
+}
+
+func TestPropagationWithoutTracing(t *testing.T) {
+	traceparent := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	ctx, span := Start(Extract(context.Background(), traceparent), "process")
+	if span != nil {
+		t.Fatalf("Start() should not return a span when tracing is not configured")
+	}
+	// the methods of a nil span do nothing
+	span.SetAttribute("key", "value")
+	span.RecordError(errors.New("error"))
+	span.End()
+	if got := Inject(ctx); got != traceparent {
+		t.Errorf("Inject() = %q, want the extracted %q", got, traceparent)
+	}
+	if got := Inject(context.Background()); got != "" {
+		t.Errorf("Inject() = %q without a trace, want empty", got)
+	}
+}
+
+func TestExport(t *testing.T) {
+	var mu sync.Mutex
+	var requests []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("unexpected request %s %s", r.URL.Path, r.Header.Get("Content-Type"))
+		}
+		req := map[string]interface{}{}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("invalid request body: %v", err)
+		}
+		mu.Lock()
+		requests = append(requests, req)
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	ctx := logging.WithLogger(context.Background())
+	if err := Configure(ctx, "ftp://localhost", "guac"); err == nil {
+		t.Errorf("Configure() expected error for a non http endpoint")
+	}
+	if err := Configure(ctx, server.URL, "guac"); err != nil {
+		t.Fatal(err)
+	}
+
+	// a collector publishing a document and a processor receiving it
+	collectCtx, collect := Start(ctx, "collect")
+	collect.SetAttribute("guac.source", "file:///sbom.json")
+	traceparent := Inject(collectCtx)
+	collect.End()
+
+	_, process := Start(Extract(ctx, traceparent), "process")
+	process.SetAttribute("guac.nodes", 3)
+	process.RecordError(errors.New("failed"))
+	process.End()
+
+	if err := Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, span := Start(ctx, "after shutdown"); span != nil {
+		t.Errorf("Start() should not return a span after Shutdown")
+	}
+
+	spans := map[string]map[string]interface{}{}
+	for _, req := range requests {
+		for _, rs := range req["resourceSpans"].([]interface{}) {
+			for _, ss := range rs.(map[string]interface{})["scopeSpans"].([]interface{}) {
+				for _, s := range ss.(map[string]interface{})["spans"].([]interface{}) {
+					span := s.(map[string]interface{})
+					spans[span["name"].(string)] = span
+				}
+			}
+		}
+	}
+	if len(spans) != 2 {
+		t.Fatalf("exported spans = %v, want collect and process", spans)
+	}
+	if spans["process"]["traceId"] != spans["collect"]["traceId"] {
+		t.Errorf("process span is not in the trace of the collect span")
+	}
+	if spans["process"]["parentSpanId"] != spans["collect"]["spanId"] {
+		t.Errorf("process span parent = %v, want %v", spans["process"]["parentSpanId"], spans["collect"]["spanId"])
+	}
+	if _, ok := spans["collect"]["parentSpanId"]; ok {
+		t.Errorf("collect span should be the root of the trace")
+	}
+	status, _ := spans["process"]["status"].(map[string]interface{})
+	if status["message"] != "failed" {
+		t.Errorf("process span status = %v, want the error", spans["process"]["status"])
+	}
+	attrs := spans["process"]["attributes"].([]interface{})
+	if len(attrs) != 1 || attrs[0].(map[string]interface{})["value"].(map[string]interface{})["intValue"] != "3" {
+		t.Errorf("process span attributes = %v, want guac.nodes=3", attrs)
+	}
+}
+
+func TestExport_Headers(t *testing.T) {
+	var mu sync.Mutex
+	var apiKeys []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		apiKeys = append(apiKeys, r.Header.Get("Api-Key"))
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	ctx := logging.WithLogger(context.Background())
+	if err := ConfigureOTLP(ctx, OTLPConfig{Endpoint: server.URL, ServiceName: "guac", Headers: map[string]string{"api-key": "secret"}}); err != nil {
+		t.Fatal(err)
+	}
+	_, span := Start(ctx, "collect")
+	span.End()
+	if err := Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(apiKeys) != 1 || apiKeys[0] != "secret" {
+		t.Errorf("exports sent api-key headers %v, want [secret]", apiKeys)
+	}
+}
+
+func TestParseHeaders(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers string
+		want    map[string]string
+		wantErr bool
+	}{{
+		name: "empty",
+		want: map[string]string{},
+	}, {
+		name:    "pairs",
+		headers: "api-key=secret, tenant = guac ,",
+		want:    map[string]string{"api-key": "secret", "tenant": "guac"},
+	}, {
+		name:    "url encoded value",
+		headers: "authorization=Basic%20Z3VhYw==",
+		want:    map[string]string{"authorization": "Basic Z3VhYw=="},
+	}, {
+		name:    "no value",
+		headers: "api-key",
+		wantErr: true,
+	}, {
+		name:    "no key",
+		headers: "=secret",
+		wantErr: true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseHeaders(tt.headers)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseHeaders() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseHeaders() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOTLPConfig_WithEnv(t *testing.T) {
+	t.Setenv(EnvEndpoint, "http://collector:4318")
+	t.Setenv(EnvHeaders, "api-key=secret")
+	t.Setenv(EnvServiceName, "")
+
+	got, err := OTLPConfig{}.WithEnv("guacone")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := OTLPConfig{Endpoint: "http://collector:4318", ServiceName: "guacone", Headers: map[string]string{"api-key": "secret"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("WithEnv() = %+v, want %+v", got, want)
+	}
+
+	// the settings given take precedence over the environment
+	t.Setenv(EnvServiceName, "ingestor")
+	c := OTLPConfig{Endpoint: "http://localhost:4318", Headers: map[string]string{"tenant": "guac"}}
+	got, err = c.WithEnv("guacone")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = OTLPConfig{Endpoint: "http://localhost:4318", ServiceName: "ingestor", Headers: map[string]string{"tenant": "guac"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("WithEnv() = %+v, want %+v", got, want)
+	}
+
+	t.Setenv(EnvHeaders, "api-key")
+	if _, err := (OTLPConfig{}).WithEnv("guacone"); err == nil {
+		t.Errorf("WithEnv() expected error for invalid %s", EnvHeaders)
+	}
+}